@@ -17,6 +17,7 @@ limitations under the License.
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -34,24 +35,52 @@ import (
 
 type generateOptions struct {
 	analyze        bool
+	linkage        bool // Annotate the executables of analyzed layers with their linkage
+	staticImages   bool // Describe the Go binaries of images built FROM scratch
 	noGitignore    bool
 	noGoModules    bool
 	noGoTransient  bool
+	classifyGoDeps bool // Relate test-only and tool go dependencies as such
+	npmModules     bool // Read npm and pnpm lockfiles in directories
+	python         bool // Read python requirements and lockfiles in directories
 	scanImages     bool
+	splitProjects  bool   // Generate a package for each project in the directories
+	dockerignore   bool   // Read exclusions from .dockerignore files
+	globalIgnore   bool   // Also read the global excludes file of git
+	upperChecksum  bool   // Write checksums in uppercase hex
+	followLinks    bool   // Scan the targets of symlinks in directories
+	goOS           string // Target GOOS to resolve go dependencies for
+	goArch         string // Target GOARCH to resolve go dependencies for
+	goImports      bool   // Look up the go-import meta tags of vanity import paths
+	licenseTools   bool   // Record the license classifier and list versions
+	normalizeVers  bool   // Normalize the versions of the packages
+	inputDigests   bool   // Record the digests of the inputs scanned
+	reproducible   bool   // Generate the same document from identical inputs
 	name           string // Name to use in the document
+	documentID     string // SPDX ID of the document
 	namespace      string
 	format         string
 	outputFile     string
 	configFile     string
 	license        string
 	licenseListVer string
+	dataLicense    string // License of the document data
 	provenancePath string // Path to export the SBOM as provenance statement
+	attestation    string // Path to export the SBOM wrapped in an in-toto statement
 	images         []string
+	platforms      []string // Platforms of the images pulled from indexes
 	imageArchives  []string
 	archives       []string
+	goBinaries     []string // Go binaries to describe from their build information
 	files          []string
 	directories    []string
+	gitRepos       []string // Git repositories to clone and describe
 	ignorePatterns []string
+	extensions     []string // Only scan the files in directories with these extensions
+	excludeExts    []string // Do not scan the files in directories with these extensions
+	maxFileSize    int64    // Do not scan the files in directories larger than this
+	supplier       string   // Supplier of the packages of directories and tarballs
+	originator     string   // Originator of the packages of directories and tarballs
 }
 
 // Validate verify options consistency
@@ -62,13 +91,24 @@ func (opts *generateOptions) Validate() error {
 		len(opts.imageArchives) == 0 &&
 		len(opts.archives) == 0 &&
 		len(opts.archives) == 0 &&
+		len(opts.goBinaries) == 0 &&
+		len(opts.gitRepos) == 0 &&
 		len(opts.directories) == 0 {
 		return errors.New("to generate a SPDX BOM you have to provide at least one image or file")
 	}
 
-	if opts.format != spdx.FormatTagValue && opts.format != spdx.FormatJSON {
-		return fmt.Errorf("unknown format provided, must be one of [%s, %s]: %s",
-			spdx.FormatTagValue, spdx.FormatJSON, opts.format)
+	if opts.format != spdx.FormatTagValue && opts.format != spdx.FormatJSON &&
+		opts.format != spdx.FormatCycloneDXJSON {
+		return fmt.Errorf("unknown format provided, must be one of [%s, %s, %s]: %s",
+			spdx.FormatTagValue, spdx.FormatJSON, spdx.FormatCycloneDXJSON, opts.format)
+	}
+
+	if opts.format == spdx.FormatCycloneDXJSON && opts.attestation != "" {
+		return errors.New("attestations can only wrap SPDX documents, not CycloneDX")
+	}
+
+	if opts.maxFileSize < 0 {
+		return fmt.Errorf("invalid maximum file size: %d", opts.maxFileSize)
 	}
 
 	// Check if specified local files exist
@@ -80,6 +120,7 @@ func (opts *generateOptions) Validate() error {
 		{opts.files, "file"},
 		{opts.directories, "directory"},
 		{opts.archives, "archive"},
+		{opts.goBinaries, "go binary"},
 	} {
 		// Check if image archives exist
 		for i, iPath := range col.Items {
@@ -157,6 +198,13 @@ completed by a later stage in your CI/CD pipeline. See the
 		"list of images",
 	)
 
+	generateCmd.PersistentFlags().StringSliceVar(
+		&genOpts.platforms,
+		"platform",
+		[]string{},
+		"only describe the images of these platforms (os/arch[/variant]) from image indexes",
+	)
+
 	generateCmd.PersistentFlags().StringSliceVarP(
 		&genOpts.files,
 		"file",
@@ -190,7 +238,14 @@ completed by a later stage in your CI/CD pipeline. See the
 		&genOpts.archives,
 		"archive",
 		[]string{},
-		"list of archives to add as packages (supports tar, tar.gz)",
+		"list of archives to add as packages (supports tar, tar.gz, zip, jar, whl, nupkg, squashfs and ext4 images)",
+	)
+
+	generateCmd.PersistentFlags().StringSliceVar(
+		&genOpts.goBinaries,
+		"go-binary",
+		[]string{},
+		"list of go binaries to add as packages with the modules compiled into them (read offline from their build information)",
 	)
 
 	generateCmd.PersistentFlags().StringSliceVarP(
@@ -201,6 +256,13 @@ completed by a later stage in your CI/CD pipeline. See the
 		"list of directories to include in the manifest as packages",
 	)
 
+	generateCmd.PersistentFlags().StringSliceVar(
+		&genOpts.gitRepos,
+		"git",
+		[]string{},
+		"list of git repositories (url[@tag, branch or commit]) to clone and include in the manifest as packages",
+	)
+
 	generateCmd.PersistentFlags().StringSliceVar(
 		&genOpts.ignorePatterns,
 		"ignore",
@@ -208,6 +270,41 @@ completed by a later stage in your CI/CD pipeline. See the
 		"list of regexp patterns to ignore when scanning directories",
 	)
 
+	generateCmd.PersistentFlags().StringSliceVar(
+		&genOpts.extensions,
+		"scan-extensions",
+		[]string{},
+		"only scan the files in directories with these extensions (eg .go,.proto), after applying the ignore patterns",
+	)
+
+	generateCmd.PersistentFlags().StringSliceVar(
+		&genOpts.excludeExts,
+		"exclude-extensions",
+		[]string{},
+		"do not scan the files in directories with these extensions (eg .png,.mp4), after applying the ignore patterns",
+	)
+
+	generateCmd.PersistentFlags().Int64Var(
+		&genOpts.maxFileSize,
+		"max-file-size",
+		0,
+		"do not scan the files in directories larger than this many bytes (0 for no limit)",
+	)
+
+	generateCmd.PersistentFlags().StringVar(
+		&genOpts.supplier,
+		"supplier",
+		"",
+		"supplier of the packages of directories and tarballs without one (eg 'Organization: Example Inc.')",
+	)
+
+	generateCmd.PersistentFlags().StringVar(
+		&genOpts.originator,
+		"originator",
+		"",
+		"originator of the packages of directories and tarballs without one (eg 'Person: Jane Doe (jane@example.com)')",
+	)
+
 	generateCmd.PersistentFlags().StringVarP(
 		&genOpts.license,
 		"license",
@@ -223,6 +320,34 @@ completed by a later stage in your CI/CD pipeline. See the
 		"don't use exclusions from .gitignore files",
 	)
 
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.dockerignore,
+		"dockerignore",
+		false,
+		"also use exclusions from .dockerignore files, as docker does in build contexts",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.globalIgnore,
+		"global-gitignore",
+		false,
+		"also use exclusions from the global excludes file of git (core.excludesFile), not only those of the directories",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.followLinks,
+		"follow-symlinks",
+		false,
+		"scan the files and directories symbolic links point to (they are skipped by default)",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.upperChecksum,
+		"uppercase-checksums",
+		false,
+		"write checksums in uppercase hex (SPDX documents use lowercase)",
+	)
+
 	generateCmd.PersistentFlags().BoolVar(
 		&genOpts.noGoModules,
 		"no-gomod",
@@ -237,6 +362,77 @@ completed by a later stage in your CI/CD pipeline. See the
 		"don't include transient go dependencies, only direct deps from go.mod",
 	)
 
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.classifyGoDeps,
+		"classify-go-deps",
+		false,
+		"relate the test-only and build tool go dependencies as such (runs go list two more times)",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.npmModules,
+		"npm",
+		false,
+		"read package-lock.json and pnpm-lock.yaml files in directories to include their npm dependencies",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.python,
+		"python",
+		false,
+		"read poetry.lock, Pipfile.lock and requirements.txt files in directories to include their python dependencies",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.licenseTools,
+		"record-license-versions",
+		false,
+		"annotate the document with the versions of the license classifier and SPDX license list used",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.inputDigests,
+		"input-digests",
+		false,
+		"annotate the document with the digests of the directories, archives and files scanned",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.reproducible,
+		"reproducible",
+		false,
+		"generate the same document from identical inputs: the namespace is derived from the contents and the "+
+			"document is dated at SOURCE_DATE_EPOCH (or the Unix epoch)",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.normalizeVers,
+		"normalize-versions",
+		false,
+		"record package versions without epochs, v prefixes or build metadata, keeping the original in an annotation",
+	)
+
+	generateCmd.PersistentFlags().StringVar(
+		&genOpts.goOS,
+		"goos",
+		"",
+		"resolve go dependencies for this operating system, leaving out those used only on others (defaults to the host)",
+	)
+
+	generateCmd.PersistentFlags().StringVar(
+		&genOpts.goArch,
+		"goarch",
+		"",
+		"resolve go dependencies for this architecture, leaving out those used only on others (defaults to the host)",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.goImports,
+		"lookup-go-imports",
+		false,
+		"look up the repositories of go modules on vanity import paths from their go-import meta tags (makes network requests)",
+	)
+
 	generateCmd.PersistentFlags().StringVarP(
 		&genOpts.namespace,
 		"namespace",
@@ -249,8 +445,8 @@ completed by a later stage in your CI/CD pipeline. See the
 		&genOpts.format,
 		"format",
 		spdx.FormatTagValue,
-		fmt.Sprintf("format of the document (supports %s, %s)",
-			spdx.FormatTagValue, spdx.FormatJSON),
+		fmt.Sprintf("format of the document (supports %s, %s, %s)",
+			spdx.FormatTagValue, spdx.FormatJSON, spdx.FormatCycloneDXJSON),
 	)
 
 	generateCmd.PersistentFlags().StringVarP(
@@ -269,6 +465,20 @@ completed by a later stage in your CI/CD pipeline. See the
 		"go deeper into images using the available analyzers",
 	)
 
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.linkage,
+		"detect-linkage",
+		false,
+		"annotate the ELF executables of analyzed image layers with whether they are statically linked",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.staticImages,
+		"scan-static-images",
+		false,
+		"describe the Go binaries of images built FROM scratch with no package database",
+	)
+
 	generateCmd.PersistentFlags().StringVarP(
 		&genOpts.configFile,
 		"config",
@@ -284,11 +494,25 @@ completed by a later stage in your CI/CD pipeline. See the
 		"path to export the SBOM as an in-toto provenance statement",
 	)
 
+	generateCmd.PersistentFlags().StringVar(
+		&genOpts.attestation,
+		"attestation",
+		"",
+		"path to export the SBOM as an in-toto statement about the artifacts it describes, ready to be signed",
+	)
+
 	generateCmd.PersistentFlags().BoolVar(
 		&genOpts.scanImages,
 		"scan-images",
 		true,
-		"scan container images to look for OS information (currently debian only)",
+		"scan container images to look for OS information (reads the dpkg, apk and rpm databases)",
+	)
+
+	generateCmd.PersistentFlags().BoolVar(
+		&genOpts.splitProjects,
+		"split-projects",
+		false,
+		"generate a package for each project (go.mod, package.json...) found in the directories",
 	)
 
 	generateCmd.PersistentFlags().StringVar(
@@ -298,6 +522,13 @@ completed by a later stage in your CI/CD pipeline. See the
 		"name for the document, in contrast to URLs, intended for humans",
 	)
 
+	generateCmd.PersistentFlags().StringVar(
+		&genOpts.documentID,
+		"document-id",
+		"",
+		"SPDX identifier of the document (defaults to SPDXRef-DOCUMENT)",
+	)
+
 	generateCmd.PersistentFlags().StringVar(
 		&genOpts.licenseListVer,
 		"license-list-version",
@@ -305,6 +536,13 @@ completed by a later stage in your CI/CD pipeline. See the
 		"version of the SPDX list to use, use 'latest' to download the latest",
 	)
 
+	generateCmd.PersistentFlags().StringVar(
+		&genOpts.dataLicense,
+		"data-license",
+		"",
+		"SPDX license of the document data (defaults to CC0-1.0)",
+	)
+
 	if err := generateCmd.MarkPersistentFlagDirname("dirs"); err != nil {
 		logrus.Error("error marking flag as directory")
 	}
@@ -328,35 +566,69 @@ func generateBOM(opts *generateOptions) error {
 	builderOpts := &spdx.DocGenerateOptions{
 		Tarballs:           opts.imageArchives,
 		Archives:           opts.archives,
+		GoBinaries:         opts.goBinaries,
 		Files:              opts.files,
 		Images:             opts.images,
+		Platforms:          opts.platforms,
 		Directories:        opts.directories,
+		GitRepositories:    opts.gitRepos,
 		Format:             opts.format,
 		OutputFile:         opts.outputFile,
 		Namespace:          opts.namespace,
 		AnalyseLayers:      opts.analyze,
+		DetectLinkage:      opts.linkage,
+		ScanStaticImages:   opts.staticImages,
+		UseDockerignore:    opts.dockerignore,
+		GlobalGitignore:    opts.globalIgnore,
+		FollowSymlinks:     opts.followLinks,
 		ProcessGoModules:   !opts.noGoModules,
+		ProcessNPMModules:  opts.npmModules,
+		ProcessPython:      opts.python,
 		OnlyDirectDeps:     !opts.noGoTransient,
+		ClassifyGoDeps:     opts.classifyGoDeps,
+		GoTargetOS:         opts.goOS,
+		GoTargetArch:       opts.goArch,
+		LookupGoImports:    opts.goImports,
+		RecordLicenseTools: opts.licenseTools,
+		NormalizeVersions:  opts.normalizeVers,
+		RecordInputDigests: opts.inputDigests,
+		Reproducible:       opts.reproducible,
 		ConfigFile:         opts.configFile,
 		License:            opts.license,
 		LicenseListVersion: opts.licenseListVer,
+		DataLicense:        opts.dataLicense,
 		ScanImages:         opts.scanImages,
 		Name:               opts.name,
+		DocumentID:         opts.documentID,
+		SplitProjects:      opts.splitProjects,
+		UppercaseChecksums: opts.upperChecksum,
 	}
 
 	// We only replace the ignore patterns one or more where defined
 	if len(opts.ignorePatterns) > 0 {
 		builderOpts.IgnorePatterns = opts.ignorePatterns
 	}
+	if len(opts.extensions) > 0 {
+		builderOpts.ScanExtensions = opts.extensions
+	}
+	if len(opts.excludeExts) > 0 {
+		builderOpts.ExcludeExtensions = opts.excludeExts
+	}
+	builderOpts.MaxFileSize = opts.maxFileSize
+	builderOpts.Supplier = opts.supplier
+	builderOpts.Originator = opts.originator
 	doc, err := builder.Generate(builderOpts)
 	if err != nil {
 		return fmt.Errorf("generating doc: %w", err)
 	}
 
 	var renderer serialize.Serializer
-	if opts.format == "json" {
+	switch opts.format {
+	case spdx.FormatJSON:
 		renderer = &serialize.JSON{}
-	} else {
+	case spdx.FormatCycloneDXJSON:
+		renderer = &serialize.CycloneDX{}
+	default:
 		renderer = &serialize.TagValue{}
 	}
 
@@ -380,5 +652,20 @@ func generateBOM(opts *generateOptions) error {
 		}
 	}
 
+	// Export the SBOM as the predicate of an in-toto statement
+	if opts.attestation != "" {
+		statement, err := spdx.NewSPDXStatement(markup, doc.Subjects())
+		if err != nil {
+			return fmt.Errorf("wrapping SBOM in an in-toto statement: %w", err)
+		}
+		data, err := json.Marshal(statement)
+		if err != nil {
+			return fmt.Errorf("serializing in-toto statement: %w", err)
+		}
+		if err := os.WriteFile(opts.attestation, data, 0o644); err != nil { //nolint:gosec // G306: Expect WriteFile
+			return fmt.Errorf("writing in-toto statement: %w", err)
+		}
+	}
+
 	return nil
 }
@@ -138,6 +138,12 @@ func validateArtifacts(opts validateOptions) error {
 	if err != nil {
 		return fmt.Errorf("opening doc: %w", err)
 	}
+	if err := doc.ValidateDescribes(); err != nil {
+		return fmt.Errorf("validating document: %w", err)
+	}
+	for _, w := range doc.ValidatePurls() {
+		logrus.Warnf("Conflicting purl %s", w)
+	}
 
 	files := []string{}
 	if opts.dir != "" {
@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v15 holds the types of a CycloneDX 1.5 JSON document, limited
+// to the fields written by bom.
+package v15
+
+const (
+	BOMFormat   = "CycloneDX"
+	SpecVersion = "1.5"
+)
+
+// Component types
+const (
+	ComponentTypeApplication     = "application"
+	ComponentTypeFramework       = "framework"
+	ComponentTypeLibrary         = "library"
+	ComponentTypeContainer       = "container"
+	ComponentTypeOperatingSystem = "operating-system"
+	ComponentTypeDevice          = "device"
+	ComponentTypeFirmware        = "firmware"
+	ComponentTypeFile            = "file"
+)
+
+type BOM struct {
+	BOMFormat    string       `json:"bomFormat"`
+	SpecVersion  string       `json:"specVersion"`
+	SerialNumber string       `json:"serialNumber,omitempty"`
+	Version      int          `json:"version"`
+	Metadata     *Metadata    `json:"metadata,omitempty"`
+	Components   []Component  `json:"components,omitempty"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+type Metadata struct {
+	Timestamp string     `json:"timestamp,omitempty"`
+	Tools     *Tools     `json:"tools,omitempty"`
+	Component *Component `json:"component,omitempty"`
+}
+
+type Tools struct {
+	Components []Component `json:"components,omitempty"`
+}
+
+type Component struct {
+	Type        string          `json:"type"`
+	BOMRef      string          `json:"bom-ref,omitempty"`
+	Name        string          `json:"name"`
+	Version     string          `json:"version,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Copyright   string          `json:"copyright,omitempty"`
+	Purl        string          `json:"purl,omitempty"`
+	Hashes      []Hash          `json:"hashes,omitempty"`
+	Licenses    []LicenseChoice `json:"licenses,omitempty"`
+	ExtRefs     []ExternalRef   `json:"externalReferences,omitempty"`
+}
+
+type Hash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// LicenseChoice holds the license of a component as an SPDX expression
+type LicenseChoice struct {
+	Expression string `json:"expression,omitempty"`
+}
+
+type ExternalRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type Dependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
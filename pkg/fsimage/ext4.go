@@ -0,0 +1,427 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsimage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// ext2/3/4 on-disk constants
+// ref: https://www.kernel.org/doc/html/latest/filesystems/ext4/index.html
+const (
+	ext4SuperblockOffset = 1024
+	ext4Magic            = 0xEF53
+	ext4RootInode        = 2
+	ext4ExtentMagic      = 0xF30A
+
+	ext4Incompat64Bit = 0x80
+
+	ext4InodeFlagExtents    = 0x80000
+	ext4InodeFlagInlineData = 0x10000000
+
+	ext4ModeTypeMask = 0xF000
+	ext4ModeDir      = 0x4000
+	ext4ModeRegular  = 0x8000
+	ext4ModeSymlink  = 0xA000
+
+	// i_block holds 60 bytes: the extent tree root, the block map or
+	// the target of short symlinks
+	ext4InodeBlockSize = 60
+
+	// Limits of the format, checked to reject corrupt images before
+	// allocating or recursing on what they hold
+	ext4MaxLogBlockSize  = 6 // 64 KiB blocks
+	ext4MinInodeSize     = 128
+	ext4MinDescSize      = 32
+	ext4MaxExtentDepth   = 5
+	ext4MaxSymlinkSize   = 4096
+	ext4MaxDirectorySize = 64 << 20
+)
+
+type ext4Reader struct {
+	r               io.ReaderAt
+	blockSize       uint64
+	inodeSize       uint64
+	inodesCount     uint32
+	inodesPerGroup  uint32
+	descSize        uint64
+	descTableOffset int64
+}
+
+func newExt4Reader(r io.ReaderAt) (*ext4Reader, error) {
+	sb := make([]byte, 1024)
+	if _, err := r.ReadAt(sb, ext4SuperblockOffset); err != nil {
+		return nil, fmt.Errorf("reading superblock: %w", err)
+	}
+	le := binary.LittleEndian
+	logBlockSize := le.Uint32(sb[0x18:])
+	if logBlockSize > ext4MaxLogBlockSize {
+		return nil, fmt.Errorf("invalid ext4 block size (log %d)", logBlockSize)
+	}
+	er := &ext4Reader{
+		r:              r,
+		blockSize:      1024 << logBlockSize,
+		inodesCount:    le.Uint32(sb[0x0:]),
+		inodesPerGroup: le.Uint32(sb[0x28:]),
+		inodeSize:      ext4MinInodeSize,
+		descSize:       ext4MinDescSize,
+	}
+	// Revision 0 filesystems have fixed size inodes
+	if le.Uint32(sb[0x4C:]) > 0 {
+		er.inodeSize = uint64(le.Uint16(sb[0x58:]))
+	}
+	incompat := le.Uint32(sb[0x60:])
+	if incompat&ext4Incompat64Bit != 0 {
+		er.descSize = uint64(le.Uint16(sb[0xFE:]))
+	}
+	if er.inodesPerGroup == 0 || er.inodesCount < ext4RootInode {
+		return nil, errors.New("invalid ext4 superblock")
+	}
+	// Inodes hold the fields read up to offset 0x70, and the group
+	// descriptors up to 0x2C when they have the 64 bit fields
+	if er.inodeSize < ext4MinInodeSize || er.inodeSize > er.blockSize || er.inodeSize&(er.inodeSize-1) != 0 {
+		return nil, fmt.Errorf("invalid ext4 inode size %d", er.inodeSize)
+	}
+	if er.descSize < ext4MinDescSize || er.descSize > er.blockSize || er.descSize&(er.descSize-1) != 0 {
+		return nil, fmt.Errorf("invalid ext4 group descriptor size %d", er.descSize)
+	}
+	// The group descriptors start on the block after the superblock
+	firstDataBlock := uint64(le.Uint32(sb[0x14:]))
+	er.descTableOffset = int64((firstDataBlock + 1) * er.blockSize)
+	return er, nil
+}
+
+type ext4Inode struct {
+	mode    uint16
+	size    uint64
+	flags   uint32
+	modTime time.Time
+	block   []byte
+}
+
+func (er *ext4Reader) readInode(num uint32) (*ext4Inode, error) {
+	if num == 0 || num > er.inodesCount {
+		return nil, fmt.Errorf("inode number %d out of range", num)
+	}
+	le := binary.LittleEndian
+	group := (num - 1) / er.inodesPerGroup
+	index := (num - 1) % er.inodesPerGroup
+
+	desc := make([]byte, er.descSize)
+	if _, err := er.r.ReadAt(desc, er.descTableOffset+int64(uint64(group)*er.descSize)); err != nil {
+		return nil, fmt.Errorf("reading group descriptor: %w", err)
+	}
+	table := uint64(le.Uint32(desc[0x8:]))
+	if er.descSize >= 64 {
+		table |= uint64(le.Uint32(desc[0x28:])) << 32
+	}
+
+	data := make([]byte, er.inodeSize)
+	if _, err := er.r.ReadAt(data, int64(table*er.blockSize+uint64(index)*er.inodeSize)); err != nil {
+		return nil, fmt.Errorf("reading inode %d: %w", num, err)
+	}
+	return &ext4Inode{
+		mode:    le.Uint16(data[0x0:]),
+		size:    uint64(le.Uint32(data[0x4:])) | uint64(le.Uint32(data[0x6C:]))<<32,
+		modTime: time.Unix(int64(le.Uint32(data[0x10:])), 0).UTC(),
+		flags:   le.Uint32(data[0x20:]),
+		block:   data[0x28 : 0x28+ext4InodeBlockSize],
+	}, nil
+}
+
+// ext4Extent maps a run of logical blocks of a file to disk blocks
+type ext4Extent struct {
+	logical  uint64
+	physical uint64
+	length   uint64
+	unwrit   bool // Uninitialized extents read as zeros
+}
+
+// extents returns the list of extents holding the data of an inode
+func (er *ext4Reader) extents(inode *ext4Inode) ([]ext4Extent, error) {
+	if inode.flags&ext4InodeFlagInlineData != 0 {
+		return nil, errors.New("inline data is not supported")
+	}
+	if inode.flags&ext4InodeFlagExtents != 0 {
+		return er.extentTree(inode.block, -1)
+	}
+	return er.blockMap(inode.block)
+}
+
+// extentTree reads the extents from an extent tree node. The depth of the
+// node is checked to be the one its parent expects, -1 for the root, so the
+// recursion ends even if corrupt nodes point back up the tree.
+func (er *ext4Reader) extentTree(node []byte, expectedDepth int) ([]ext4Extent, error) {
+	le := binary.LittleEndian
+	if le.Uint16(node[0:]) != ext4ExtentMagic {
+		return nil, errors.New("invalid extent tree header")
+	}
+	entries := int(le.Uint16(node[2:]))
+	depth := le.Uint16(node[6:])
+	if len(node) < 12+entries*12 {
+		return nil, errors.New("extent tree node out of bounds")
+	}
+	if depth > ext4MaxExtentDepth || (expectedDepth >= 0 && int(depth) != expectedDepth) {
+		return nil, fmt.Errorf("invalid extent tree depth %d", depth)
+	}
+
+	extents := []ext4Extent{}
+	for i := 0; i < entries; i++ {
+		e := node[12+i*12 : 24+i*12]
+		if depth == 0 {
+			length := uint64(le.Uint16(e[4:]))
+			unwritten := false
+			if length > 32768 {
+				length -= 32768
+				unwritten = true
+			}
+			extents = append(extents, ext4Extent{
+				logical:  uint64(le.Uint32(e[0:])),
+				physical: uint64(le.Uint16(e[6:]))<<32 | uint64(le.Uint32(e[8:])),
+				length:   length,
+				unwrit:   unwritten,
+			})
+			continue
+		}
+		leaf := uint64(le.Uint16(e[8:]))<<32 | uint64(le.Uint32(e[4:]))
+		child := make([]byte, er.blockSize)
+		if _, err := er.r.ReadAt(child, int64(leaf*er.blockSize)); err != nil {
+			return nil, fmt.Errorf("reading extent tree block: %w", err)
+		}
+		childExtents, err := er.extentTree(child, int(depth)-1)
+		if err != nil {
+			return nil, err
+		}
+		extents = append(extents, childExtents...)
+	}
+	return extents, nil
+}
+
+// blockMap reads the extents of an ext2/3 style direct/indirect block map
+func (er *ext4Reader) blockMap(block []byte) ([]ext4Extent, error) {
+	le := binary.LittleEndian
+	extents := []ext4Extent{}
+	logical := uint64(0)
+	var addBlocks func(num uint64, level int) error
+	addBlocks = func(num uint64, level int) error {
+		perBlock := er.blockSize / 4
+		if num == 0 {
+			// Holes in the map cover all the blocks under them
+			span := uint64(1)
+			for i := 0; i < level; i++ {
+				span *= perBlock
+			}
+			logical += span
+			return nil
+		}
+		if level == 0 {
+			extents = append(extents, ext4Extent{logical: logical, physical: num, length: 1})
+			logical++
+			return nil
+		}
+		data := make([]byte, er.blockSize)
+		if _, err := er.r.ReadAt(data, int64(num*er.blockSize)); err != nil {
+			return fmt.Errorf("reading indirect block: %w", err)
+		}
+		for i := uint64(0); i < perBlock; i++ {
+			if err := addBlocks(uint64(le.Uint32(data[i*4:])), level-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := 0; i < 15; i++ {
+		level := 0
+		if i >= 12 {
+			level = i - 11
+		}
+		if err := addBlocks(uint64(le.Uint32(block[i*4:])), level); err != nil {
+			return nil, err
+		}
+	}
+	return extents, nil
+}
+
+// fileReader returns a reader of the contents of an inode
+func (er *ext4Reader) fileReader(inode *ext4Inode) (io.Reader, error) {
+	extents, err := er.extents(inode)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(extents, func(i, j int) bool { return extents[i].logical < extents[j].logical })
+
+	readers := []io.Reader{}
+	pos := uint64(0)
+	for _, e := range extents {
+		start := e.logical * er.blockSize
+		if start >= inode.size {
+			break
+		}
+		// Fill holes in the file with zeros
+		if start > pos {
+			readers = append(readers, io.LimitReader(zeroReader{}, int64(start-pos)))
+		}
+		length := e.length * er.blockSize
+		if start+length > inode.size {
+			length = inode.size - start
+		}
+		if e.unwrit {
+			readers = append(readers, io.LimitReader(zeroReader{}, int64(length)))
+		} else {
+			readers = append(readers, io.NewSectionReader(er.r, int64(e.physical*er.blockSize), int64(length)))
+		}
+		pos = start + length
+	}
+	if pos < inode.size {
+		readers = append(readers, io.LimitReader(zeroReader{}, int64(inode.size-pos)))
+	}
+	return io.MultiReader(readers...), nil
+}
+
+type ext4DirEntry struct {
+	name  string
+	inode uint32
+}
+
+// readDir returns the entries of a directory, except . and ..
+func (er *ext4Reader) readDir(inode *ext4Inode) ([]ext4DirEntry, error) {
+	if inode.size > ext4MaxDirectorySize {
+		return nil, fmt.Errorf("directory of %d bytes is too large", inode.size)
+	}
+	r, err := er.fileReader(inode)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory data: %w", err)
+	}
+
+	// Hashed directories keep their index in entries with inode 0 so,
+	// as other ext4 readers, we can read them as linear directories
+	le := binary.LittleEndian
+	entries := []ext4DirEntry{}
+	for pos := 0; pos+8 <= len(data); {
+		num := le.Uint32(data[pos:])
+		recLen := int(le.Uint16(data[pos+4:]))
+		nameLen := int(data[pos+6])
+		if recLen < 8 || pos+8+nameLen > len(data) {
+			return nil, errors.New("invalid directory entry")
+		}
+		name := string(data[pos+8 : pos+8+nameLen])
+		if num != 0 && name != "." && name != ".." {
+			entries = append(entries, ext4DirEntry{name: name, inode: num})
+		}
+		pos += recLen
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, nil
+}
+
+// symlinkTarget returns the path a symbolic link points to
+func (er *ext4Reader) symlinkTarget(inode *ext4Inode) (string, error) {
+	// Short targets are stored in the inode itself
+	if inode.size < ext4InodeBlockSize && inode.flags&(ext4InodeFlagExtents|ext4InodeFlagInlineData) == 0 {
+		return string(inode.block[:inode.size]), nil
+	}
+	if inode.size > ext4MaxSymlinkSize {
+		return "", fmt.Errorf("symlink target of %d bytes is too long", inode.size)
+	}
+	r, err := er.fileReader(inode)
+	if err != nil {
+		return "", err
+	}
+	target, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("reading symlink target: %w", err)
+	}
+	return string(target), nil
+}
+
+func (er *ext4Reader) walk(fn WalkFunc) error {
+	root, err := er.readInode(ext4RootInode)
+	if err != nil {
+		return fmt.Errorf("reading root inode: %w", err)
+	}
+	if root.mode&ext4ModeTypeMask != ext4ModeDir {
+		return errors.New("root inode is not a directory")
+	}
+	return er.walkDir(root, "", fn, map[uint32]struct{}{ext4RootInode: {}})
+}
+
+// walkDir calls fn for the entries under dir. Directories are walked once,
+// visited holds the numbers of those already entered so a corrupt image
+// linking a directory to one of its parents does not loop forever.
+func (er *ext4Reader) walkDir(dir *ext4Inode, dirPath string, fn WalkFunc, visited map[uint32]struct{}) error {
+	entries, err := er.readDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading directory /%s: %w", dirPath, err)
+	}
+	for _, e := range entries {
+		entryPath := path.Join(dirPath, e.name)
+		// lost+found is created by mkfs, it is not part of the contents
+		if entryPath == "lost+found" {
+			continue
+		}
+		inode, err := er.readInode(e.inode)
+		if err != nil {
+			return fmt.Errorf("reading inode of %s: %w", entryPath, err)
+		}
+		entry := &Entry{
+			Path:    entryPath,
+			Mode:    fs.FileMode(inode.mode) & fs.ModePerm,
+			ModTime: inode.modTime,
+		}
+		var r io.Reader
+		switch inode.mode & ext4ModeTypeMask {
+		case ext4ModeDir:
+			entry.Mode |= fs.ModeDir
+		case ext4ModeRegular:
+			entry.Size = int64(inode.size)
+			if r, err = er.fileReader(inode); err != nil {
+				return fmt.Errorf("reading %s: %w", entryPath, err)
+			}
+		case ext4ModeSymlink:
+			entry.Mode |= fs.ModeSymlink
+			if entry.Linkname, err = er.symlinkTarget(inode); err != nil {
+				return fmt.Errorf("reading %s: %w", entryPath, err)
+			}
+		default:
+			entry.Mode |= fs.ModeIrregular
+		}
+		if err := fn(entry, r); err != nil {
+			return err
+		}
+		if entry.Mode.IsDir() {
+			if _, ok := visited[e.inode]; ok {
+				return fmt.Errorf("directory %s is linked more than once", entryPath)
+			}
+			visited[e.inode] = struct{}{}
+			if err := er.walkDir(inode, entryPath, fn, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
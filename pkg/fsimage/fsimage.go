@@ -0,0 +1,175 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fsimage reads the files stored in filesystem images. It supports
+// squashfs (uncompressed or gzip compressed) and ext2/3/4 images and is
+// written in pure go so that no external tools or privileges are needed to
+// mount the images.
+package fsimage
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Type is the kind of filesystem stored in an image
+type Type string
+
+const (
+	TypeSquashfs Type = "squashfs"
+	TypeExt4     Type = "ext4"
+)
+
+// ErrUnknownType is returned when the image is not of a supported type
+var ErrUnknownType = errors.New("unknown filesystem image type")
+
+// Entry is a file found in a filesystem image
+type Entry struct {
+	Path     string      // Path of the file, relative to the filesystem root
+	Mode     fs.FileMode // File type and permission bits
+	Size     int64       // Size of the file contents (regular files only)
+	Linkname string      // Target of symbolic links
+	ModTime  time.Time   // Last modification time
+}
+
+// WalkFunc is called for each file found in the image. When the entry
+// is a regular file, r reads its contents.
+type WalkFunc func(entry *Entry, r io.Reader) error
+
+// walker is the interface implemented by the filesystem readers
+type walker interface {
+	walk(fn WalkFunc) error
+}
+
+// DetectType reads the image superblock and returns the filesystem type
+func DetectType(r io.ReaderAt) (Type, error) {
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", ErrUnknownType
+		}
+		return "", fmt.Errorf("reading squashfs magic: %w", err)
+	}
+	if binary.LittleEndian.Uint32(magic) == squashfsMagic {
+		return TypeSquashfs, nil
+	}
+
+	if _, err := r.ReadAt(magic[:2], ext4SuperblockOffset+0x38); err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", ErrUnknownType
+		}
+		return "", fmt.Errorf("reading ext4 magic: %w", err)
+	}
+	if binary.LittleEndian.Uint16(magic) == ext4Magic {
+		return TypeExt4, nil
+	}
+	return "", ErrUnknownType
+}
+
+// DetectFileType returns the type of the filesystem image at path, sniffed
+// from its superblock. ErrUnknownType is returned for other files.
+func DetectFileType(path string) (Type, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening filesystem image: %w", err)
+	}
+	defer f.Close()
+	return DetectType(f)
+}
+
+// Walk calls fn for every file in the filesystem image at path. Entries
+// are visited depth first with the contents of each directory sorted by
+// name.
+func Walk(path string, fn WalkFunc) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening filesystem image: %w", err)
+	}
+	defer f.Close()
+
+	fsType, err := DetectType(f)
+	if err != nil {
+		return fmt.Errorf("detecting filesystem type: %w", err)
+	}
+	logrus.Debugf("Image %s contains a %s filesystem", path, fsType)
+
+	var w walker
+	switch fsType {
+	case TypeSquashfs:
+		w, err = newSquashfsReader(f)
+	case TypeExt4:
+		w, err = newExt4Reader(f)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s superblock: %w", fsType, err)
+	}
+	return w.walk(fn)
+}
+
+// WriteTar writes all the files in a filesystem image to a tar archive
+func WriteTar(imagePath, tarPath string) error {
+	f, err := os.Create(tarPath)
+	if err != nil {
+		return fmt.Errorf("creating tar archive: %w", err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	if err := Walk(imagePath, func(entry *Entry, r io.Reader) error {
+		hdr := &tar.Header{
+			Name:    entry.Path,
+			Mode:    int64(entry.Mode.Perm()),
+			ModTime: entry.ModTime,
+		}
+		switch {
+		case entry.Mode.IsDir():
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+		case entry.Mode&fs.ModeSymlink != 0:
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = entry.Linkname
+		case entry.Mode.IsRegular():
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = entry.Size
+		default:
+			// Devices, fifos and sockets have no contents to scan
+			return nil
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("writing header for %s: %w", entry.Path, err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := io.CopyN(tw, r, entry.Size); err != nil {
+				return fmt.Errorf("writing %s to tar archive: %w", entry.Path, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("walking filesystem image: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing tar archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsimage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ungzipFixture decompresses a gzipped test image to a temporary file
+func ungzipFixture(t *testing.T, path string) string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	out, err := os.Create(filepath.Join(t.TempDir(), strings.TrimSuffix(filepath.Base(path), ".gz")))
+	require.NoError(t, err)
+	defer out.Close()
+	_, err = io.Copy(out, gz) //nolint:gosec // Test fixture
+	require.NoError(t, err)
+	return out.Name()
+}
+
+func TestWalk(t *testing.T) {
+	for _, tc := range []struct {
+		image  string
+		fsType Type
+	}{
+		{"testdata/rootfs.squashfs", TypeSquashfs},
+		{ungzipFixture(t, "testdata/rootfs.ext4.gz"), TypeExt4},
+	} {
+		f, err := os.Open(tc.image)
+		require.NoError(t, err)
+		fsType, err := DetectType(f)
+		f.Close()
+		require.NoError(t, err)
+		require.Equal(t, tc.fsType, fsType)
+
+		entries := map[string]*Entry{}
+		contents := map[string]string{}
+		require.NoError(t, Walk(tc.image, func(entry *Entry, r io.Reader) error {
+			entries[entry.Path] = entry
+			if r != nil {
+				data, err := io.ReadAll(r)
+				if err != nil {
+					return err
+				}
+				require.Len(t, data, int(entry.Size), entry.Path)
+				contents[entry.Path] = fmt.Sprintf("%x", sha256.Sum256(data))
+			}
+			return nil
+		}), tc.image)
+
+		require.Len(t, entries, 17, tc.image)
+		require.True(t, entries["opt/empty"].Mode.IsDir())
+		require.Equal(t, fs.ModeSymlink, entries["etc/os-release"].Mode.Type())
+		require.Equal(t, "../usr/lib/os-release", entries["etc/os-release"].Linkname)
+		require.Equal(t, fs.FileMode(0o644), entries["usr/bin/hello"].Mode)
+
+		// Compare the file contents to the data used to build the images
+		require.Len(t, contents, 5)
+		require.Equal(t, map[string]string{
+			"usr/bin/README":          "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447",
+			"usr/bin/hello":           "cfa341346f681bfaf203bce4e422ac4b8b2d93330805c2de3da6e77d052438f7",
+			"usr/lib/os-release":      "ff3b041da10d93a41be4a17d8e18e2a1aa33d61cd3f27683d54dad67e0f201ba",
+			"usr/share/doc/copyright": "fe4c6e39d127bb54472b7ef5ba76dfe80eed834455c8abe234f22b6c32d77325",
+			"var/lib/dpkg/status":     "a1641f2d27f418f432af7aaaf85f0acf4ea87de5030fb35a94b453e8a9238718",
+		}, contents)
+	}
+}
+
+func TestWalkCorruptImages(t *testing.T) {
+	squashfs, err := os.ReadFile("testdata/rootfs.squashfs")
+	require.NoError(t, err)
+	ext4, err := os.ReadFile(ungzipFixture(t, "testdata/rootfs.ext4.gz"))
+	require.NoError(t, err)
+
+	// Corrupt images fail to be walked, without panicking or looping
+	for _, tc := range []struct {
+		name    string
+		image   []byte
+		corrupt func(data []byte)
+		err     string
+	}{
+		{
+			"squashfs zero block size", squashfs,
+			func(data []byte) { binary.LittleEndian.PutUint32(data[12:], 0) },
+			"invalid squashfs block size",
+		},
+		{
+			"squashfs block log mismatch", squashfs,
+			func(data []byte) { binary.LittleEndian.PutUint16(data[22:], 16) },
+			"invalid squashfs block size",
+		},
+		{
+			"ext4 huge block size", ext4,
+			func(data []byte) { binary.LittleEndian.PutUint32(data[ext4SuperblockOffset+0x18:], 7) },
+			"invalid ext4 block size",
+		},
+		{
+			"ext4 short inodes", ext4,
+			func(data []byte) { binary.LittleEndian.PutUint16(data[ext4SuperblockOffset+0x58:], 64) },
+			"invalid ext4 inode size",
+		},
+		{
+			"ext4 no inodes", ext4,
+			func(data []byte) { binary.LittleEndian.PutUint32(data[ext4SuperblockOffset:], 1) },
+			"invalid ext4 superblock",
+		},
+		{
+			"ext4 directory cycle", ext4,
+			func(data []byte) {
+				// Point the entry of opt/empty back to the root directory
+				pos := bytes.Index(data, []byte("\x05\x02empty"))
+				binary.LittleEndian.PutUint32(data[pos-6:], ext4RootInode)
+			},
+			"linked more than once",
+		},
+	} {
+		data := append([]byte{}, tc.image...)
+		tc.corrupt(data)
+		imagePath := filepath.Join(t.TempDir(), "image")
+		require.NoError(t, os.WriteFile(imagePath, data, os.FileMode(0o644)))
+		err := Walk(imagePath, func(entry *Entry, r io.Reader) error {
+			if r != nil {
+				_, err := io.Copy(io.Discard, r)
+				return err
+			}
+			return nil
+		})
+		require.Error(t, err, tc.name)
+		require.Contains(t, err.Error(), tc.err, tc.name)
+	}
+}
+
+func TestDetectFileType(t *testing.T) {
+	fsType, err := DetectFileType("testdata/rootfs.squashfs")
+	require.NoError(t, err)
+	require.Equal(t, TypeSquashfs, fsType)
+
+	// Files too short to hold a superblock are not images
+	for _, data := range []string{"", "hi", strings.Repeat("not an image\n", 200)} {
+		path := filepath.Join(t.TempDir(), "disk.img")
+		require.NoError(t, os.WriteFile(path, []byte(data), os.FileMode(0o644)))
+		_, err := DetectFileType(path)
+		require.ErrorIs(t, err, ErrUnknownType)
+	}
+}
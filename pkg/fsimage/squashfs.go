@@ -0,0 +1,523 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsimage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// Squashfs v4 on-disk constants
+// ref: https://dr-emann.github.io/squashfs/squashfs.html
+const (
+	squashfsMagic         = 0x73717368
+	squashfsMetadataSize  = 8192
+	squashfsCompGzip      = 1
+	squashfsNoFragment    = 0xFFFFFFFF
+	squashfsMetaUncomp    = 0x8000
+	squashfsBlockUncomp   = 1 << 24
+	squashfsFragmentEntry = 16
+
+	squashfsDirType        = 1
+	squashfsFileType       = 2
+	squashfsSymlinkType    = 3
+	squashfsExtDirType     = 8
+	squashfsExtFileType    = 9
+	squashfsExtSymlinkType = 10
+
+	// Limits of the format, checked to reject corrupt images before
+	// allocating or dividing by what they hold
+	squashfsMinBlockLog  = 12
+	squashfsMaxBlockLog  = 20
+	squashfsMaxDirCount  = 256
+	squashfsMaxNameSize  = 256
+	squashfsMaxLinkSize  = 4096
+	squashfsBlockSizeLen = 4
+)
+
+type squashfsSuperblock struct {
+	Magic              uint32
+	InodeCount         uint32
+	ModTime            uint32
+	BlockSize          uint32
+	FragmentCount      uint32
+	Compressor         uint16
+	BlockLog           uint16
+	Flags              uint16
+	IDCount            uint16
+	VersionMajor       uint16
+	VersionMinor       uint16
+	RootInode          uint64
+	BytesUsed          uint64
+	IDTableStart       uint64
+	XattrTableStart    uint64
+	InodeTableStart    uint64
+	DirTableStart      uint64
+	FragmentTableStart uint64
+	ExportTableStart   uint64
+}
+
+// squashfsInode holds the fields of an inode needed to walk the image
+type squashfsInode struct {
+	Type       uint16
+	Mode       fs.FileMode
+	ModTime    time.Time
+	Size       uint64
+	DirBlock   uint32
+	DirOffset  uint16
+	BlockStart uint64
+	Fragment   uint32
+	FragOffset uint32
+	BlockSizes []uint32
+	Target     string
+}
+
+type squashfsReader struct {
+	r  io.ReaderAt
+	sb squashfsSuperblock
+}
+
+func newSquashfsReader(r io.ReaderAt) (*squashfsReader, error) {
+	sr := &squashfsReader{r: r}
+	if err := binary.Read(
+		io.NewSectionReader(r, 0, int64(binary.Size(sr.sb))), binary.LittleEndian, &sr.sb,
+	); err != nil {
+		return nil, fmt.Errorf("reading superblock: %w", err)
+	}
+	if sr.sb.VersionMajor != 4 {
+		return nil, fmt.Errorf("unsupported squashfs version %d.%d", sr.sb.VersionMajor, sr.sb.VersionMinor)
+	}
+	if sr.sb.Compressor != squashfsCompGzip {
+		return nil, fmt.Errorf("unsupported squashfs compression (id %d), only gzip is supported", sr.sb.Compressor)
+	}
+	if sr.sb.BlockLog < squashfsMinBlockLog || sr.sb.BlockLog > squashfsMaxBlockLog ||
+		sr.sb.BlockSize != 1<<sr.sb.BlockLog {
+		return nil, fmt.Errorf("invalid squashfs block size %d (log %d)", sr.sb.BlockSize, sr.sb.BlockLog)
+	}
+	return sr, nil
+}
+
+// decompress inflates a block of data read from the image, which holds no
+// more than limit bytes once decompressed
+func (sr *squashfsReader) decompress(data []byte, limit int64) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("creating zlib reader: %w", err)
+	}
+	defer zr.Close()
+	res, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing block: %w", err)
+	}
+	if int64(len(res)) > limit {
+		return nil, fmt.Errorf("decompressed block is larger than %d bytes", limit)
+	}
+	return res, nil
+}
+
+// metadataReader returns a reader that reads the metadata stream
+// starting at block (an absolute position in the image) and offset
+// (a position in the uncompressed block)
+func (sr *squashfsReader) metadataReader(block uint64, offset uint16) (*squashfsMetadataReader, error) {
+	mr := &squashfsMetadataReader{sr: sr, next: block}
+	if err := mr.readBlock(); err != nil {
+		return nil, err
+	}
+	if int(offset) > len(mr.buf) {
+		return nil, errors.New("metadata offset out of bounds")
+	}
+	mr.buf = mr.buf[offset:]
+	return mr, nil
+}
+
+// squashfsMetadataReader reads a stream of metadata blocks
+type squashfsMetadataReader struct {
+	sr   *squashfsReader
+	next uint64
+	buf  []byte
+}
+
+func (mr *squashfsMetadataReader) readBlock() error {
+	header := make([]byte, 2)
+	if _, err := mr.sr.r.ReadAt(header, int64(mr.next)); err != nil {
+		return fmt.Errorf("reading metadata block header: %w", err)
+	}
+	h := binary.LittleEndian.Uint16(header)
+	size := h &^ squashfsMetaUncomp
+	if size > squashfsMetadataSize {
+		return fmt.Errorf("metadata block of %d bytes is larger than %d", size, squashfsMetadataSize)
+	}
+	data := make([]byte, size)
+	if _, err := mr.sr.r.ReadAt(data, int64(mr.next)+2); err != nil {
+		return fmt.Errorf("reading metadata block: %w", err)
+	}
+	if h&squashfsMetaUncomp == 0 {
+		var err error
+		if data, err = mr.sr.decompress(data, squashfsMetadataSize); err != nil {
+			return err
+		}
+	}
+	mr.buf = data
+	mr.next += 2 + uint64(size)
+	return nil
+}
+
+func (mr *squashfsMetadataReader) Read(p []byte) (int, error) {
+	if len(mr.buf) == 0 {
+		if err := mr.readBlock(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, mr.buf)
+	mr.buf = mr.buf[n:]
+	return n, nil
+}
+
+// readInode reads the inode pointed to by an inode reference
+func (sr *squashfsReader) readInode(ref uint64) (*squashfsInode, error) {
+	mr, err := sr.metadataReader(sr.sb.InodeTableStart+(ref>>16), uint16(ref&0xFFFF))
+	if err != nil {
+		return nil, fmt.Errorf("reading inode table: %w", err)
+	}
+
+	var header struct {
+		Type, Perms, UID, GID uint16
+		ModTime, Number       uint32
+	}
+	if err := binary.Read(mr, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("reading inode header: %w", err)
+	}
+	inode := &squashfsInode{
+		Type:    header.Type,
+		Mode:    fs.FileMode(header.Perms) & fs.ModePerm,
+		ModTime: time.Unix(int64(header.ModTime), 0).UTC(),
+	}
+
+	switch header.Type {
+	case squashfsDirType:
+		var d struct {
+			BlockStart, LinkCount uint32
+			Size, Offset          uint16
+			Parent                uint32
+		}
+		if err := binary.Read(mr, binary.LittleEndian, &d); err != nil {
+			return nil, fmt.Errorf("reading directory inode: %w", err)
+		}
+		inode.Mode |= fs.ModeDir
+		inode.DirBlock, inode.DirOffset, inode.Size = d.BlockStart, d.Offset, uint64(d.Size)
+	case squashfsExtDirType:
+		var d struct {
+			LinkCount, Size, BlockStart, Parent uint32
+			IndexCount, Offset                  uint16
+			Xattr                               uint32
+		}
+		if err := binary.Read(mr, binary.LittleEndian, &d); err != nil {
+			return nil, fmt.Errorf("reading extended directory inode: %w", err)
+		}
+		inode.Mode |= fs.ModeDir
+		inode.DirBlock, inode.DirOffset, inode.Size = d.BlockStart, d.Offset, uint64(d.Size)
+	case squashfsFileType:
+		var f struct {
+			BlockStart, Fragment, Offset, Size uint32
+		}
+		if err := binary.Read(mr, binary.LittleEndian, &f); err != nil {
+			return nil, fmt.Errorf("reading file inode: %w", err)
+		}
+		inode.BlockStart, inode.Fragment, inode.FragOffset = uint64(f.BlockStart), f.Fragment, f.Offset
+		inode.Size = uint64(f.Size)
+	case squashfsExtFileType:
+		var f struct {
+			BlockStart, Size, Sparse           uint64
+			LinkCount, Fragment, Offset, Xattr uint32
+		}
+		if err := binary.Read(mr, binary.LittleEndian, &f); err != nil {
+			return nil, fmt.Errorf("reading extended file inode: %w", err)
+		}
+		inode.BlockStart, inode.Fragment, inode.FragOffset = f.BlockStart, f.Fragment, f.Offset
+		inode.Size = f.Size
+	case squashfsSymlinkType, squashfsExtSymlinkType:
+		var l struct {
+			LinkCount, TargetSize uint32
+		}
+		if err := binary.Read(mr, binary.LittleEndian, &l); err != nil {
+			return nil, fmt.Errorf("reading symlink inode: %w", err)
+		}
+		if l.TargetSize > squashfsMaxLinkSize {
+			return nil, fmt.Errorf("symlink target of %d bytes is too long", l.TargetSize)
+		}
+		target := make([]byte, l.TargetSize)
+		if _, err := io.ReadFull(mr, target); err != nil {
+			return nil, fmt.Errorf("reading symlink target: %w", err)
+		}
+		inode.Mode |= fs.ModeSymlink
+		inode.Target = string(target)
+	default:
+		// Devices, fifos and sockets are reported without data
+		inode.Mode |= fs.ModeIrregular
+		return inode, nil
+	}
+
+	if inode.Mode.IsRegular() {
+		blocks := inode.Size / uint64(sr.sb.BlockSize)
+		if inode.Fragment == squashfsNoFragment && inode.Size%uint64(sr.sb.BlockSize) != 0 {
+			blocks++
+		}
+		// The list of block sizes is stored in the image, it cannot
+		// take more bytes than the image has
+		if blocks > sr.sb.BytesUsed/squashfsBlockSizeLen {
+			return nil, fmt.Errorf("file of %d bytes has more blocks than the image can hold", inode.Size)
+		}
+		inode.BlockSizes = make([]uint32, blocks)
+		if err := binary.Read(mr, binary.LittleEndian, &inode.BlockSizes); err != nil {
+			return nil, fmt.Errorf("reading file block list: %w", err)
+		}
+	}
+	return inode, nil
+}
+
+type squashfsDirEntry struct {
+	Name  string
+	Inode uint64
+}
+
+// readDir returns the entries in a directory inode
+func (sr *squashfsReader) readDir(inode *squashfsInode) ([]squashfsDirEntry, error) {
+	// The directory size includes three bytes for the implicit . and ..
+	if inode.Size <= 3 {
+		return nil, nil
+	}
+	mr, err := sr.metadataReader(sr.sb.DirTableStart+uint64(inode.DirBlock), inode.DirOffset)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory table: %w", err)
+	}
+	lr := io.LimitReader(mr, int64(inode.Size-3))
+
+	entries := []squashfsDirEntry{}
+	for {
+		var header struct {
+			Count, Start, Number uint32
+		}
+		if err := binary.Read(lr, binary.LittleEndian, &header); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("reading directory header: %w", err)
+		}
+		if header.Count >= squashfsMaxDirCount {
+			return nil, fmt.Errorf("directory header lists %d entries, at most %d are allowed", header.Count+1, squashfsMaxDirCount)
+		}
+		for i := uint32(0); i <= header.Count; i++ {
+			var e struct {
+				Offset    uint16
+				InodeDiff int16
+				Type      uint16
+				NameSize  uint16
+			}
+			if err := binary.Read(lr, binary.LittleEndian, &e); err != nil {
+				return nil, fmt.Errorf("reading directory entry: %w", err)
+			}
+			if e.NameSize >= squashfsMaxNameSize {
+				return nil, fmt.Errorf("directory entry name of %d bytes is too long", e.NameSize+1)
+			}
+			name := make([]byte, int(e.NameSize)+1)
+			if _, err := io.ReadFull(lr, name); err != nil {
+				return nil, fmt.Errorf("reading directory entry name: %w", err)
+			}
+			entries = append(entries, squashfsDirEntry{
+				Name:  string(name),
+				Inode: uint64(header.Start)<<16 | uint64(e.Offset),
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// readDataBlock reads a data or fragment block from the image
+func (sr *squashfsReader) readDataBlock(start uint64, size uint32) ([]byte, error) {
+	if size&^squashfsBlockUncomp > sr.sb.BlockSize {
+		return nil, fmt.Errorf("data block of %d bytes is larger than the block size", size&^squashfsBlockUncomp)
+	}
+	data := make([]byte, size&^squashfsBlockUncomp)
+	if _, err := sr.r.ReadAt(data, int64(start)); err != nil {
+		return nil, fmt.Errorf("reading data block: %w", err)
+	}
+	if size&squashfsBlockUncomp != 0 {
+		return data, nil
+	}
+	return sr.decompress(data, int64(sr.sb.BlockSize))
+}
+
+// readFragment returns the data of a file stored in a fragment block
+func (sr *squashfsReader) readFragment(index, offset uint32, size uint64) ([]byte, error) {
+	// Read the location of the metadata block listing the fragment
+	entriesPerBlock := uint32(squashfsMetadataSize / squashfsFragmentEntry)
+	ptr := make([]byte, 8)
+	if _, err := sr.r.ReadAt(
+		ptr, int64(sr.sb.FragmentTableStart)+int64(index/entriesPerBlock)*8,
+	); err != nil {
+		return nil, fmt.Errorf("reading fragment table: %w", err)
+	}
+	mr, err := sr.metadataReader(
+		binary.LittleEndian.Uint64(ptr), uint16(index%entriesPerBlock*squashfsFragmentEntry),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("reading fragment entry: %w", err)
+	}
+	var entry struct {
+		Start  uint64
+		Size   uint32
+		Unused uint32
+	}
+	if err := binary.Read(mr, binary.LittleEndian, &entry); err != nil {
+		return nil, fmt.Errorf("reading fragment entry: %w", err)
+	}
+	data, err := sr.readDataBlock(entry.Start, entry.Size)
+	if err != nil {
+		return nil, fmt.Errorf("reading fragment block: %w", err)
+	}
+	if uint64(offset)+size > uint64(len(data)) {
+		return nil, errors.New("fragment data out of bounds")
+	}
+	return data[offset : uint64(offset)+size], nil
+}
+
+// fileReader returns a reader for the contents of a file inode
+func (sr *squashfsReader) fileReader(inode *squashfsInode) io.Reader {
+	readers := []io.Reader{}
+	pos := inode.BlockStart
+	remaining := inode.Size
+	for _, size := range inode.BlockSizes {
+		start, blockSize := pos, size
+		length := uint64(sr.sb.BlockSize)
+		if remaining < length {
+			length = remaining
+		}
+		remaining -= length
+		pos += uint64(size &^ squashfsBlockUncomp)
+		readers = append(readers, &lazyReader{open: func() (io.Reader, error) {
+			// A zero size marks a sparse block
+			if blockSize == 0 {
+				return io.LimitReader(zeroReader{}, int64(length)), nil
+			}
+			data, err := sr.readDataBlock(start, blockSize)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(data), nil
+		}})
+	}
+	if remaining > 0 && inode.Fragment != squashfsNoFragment {
+		size := remaining
+		readers = append(readers, &lazyReader{open: func() (io.Reader, error) {
+			data, err := sr.readFragment(inode.Fragment, inode.FragOffset, size)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(data), nil
+		}})
+	}
+	return io.MultiReader(readers...)
+}
+
+func (sr *squashfsReader) walk(fn WalkFunc) error {
+	root, err := sr.readInode(sr.sb.RootInode)
+	if err != nil {
+		return fmt.Errorf("reading root inode: %w", err)
+	}
+	if !root.Mode.IsDir() {
+		return errors.New("root inode is not a directory")
+	}
+	return sr.walkDir(root, "", fn, map[uint64]struct{}{sr.sb.RootInode: {}})
+}
+
+// walkDir calls fn for the entries under dir. Directories are walked once,
+// visited holds the references of those already entered so a corrupt image
+// linking a directory to one of its parents does not loop forever.
+func (sr *squashfsReader) walkDir(
+	dir *squashfsInode, dirPath string, fn WalkFunc, visited map[uint64]struct{},
+) error {
+	entries, err := sr.readDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading directory %s: %w", dirPath, err)
+	}
+	for _, e := range entries {
+		inode, err := sr.readInode(e.Inode)
+		if err != nil {
+			return fmt.Errorf("reading inode of %s: %w", path.Join(dirPath, e.Name), err)
+		}
+		entry := &Entry{
+			Path:     path.Join(dirPath, e.Name),
+			Mode:     inode.Mode,
+			Linkname: inode.Target,
+			ModTime:  inode.ModTime,
+		}
+		var r io.Reader
+		if inode.Mode.IsRegular() {
+			entry.Size = int64(inode.Size)
+			r = sr.fileReader(inode)
+		}
+		if err := fn(entry, r); err != nil {
+			return err
+		}
+		if inode.Mode.IsDir() {
+			if _, ok := visited[e.Inode]; ok {
+				return fmt.Errorf("directory %s is linked more than once", entry.Path)
+			}
+			visited[e.Inode] = struct{}{}
+			if err := sr.walkDir(inode, entry.Path, fn, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lazyReader defers reading a block until its data is needed
+type lazyReader struct {
+	open func() (io.Reader, error)
+	r    io.Reader
+}
+
+func (lr *lazyReader) Read(p []byte) (int, error) {
+	if lr.r == nil {
+		r, err := lr.open()
+		if err != nil {
+			return 0, err
+		}
+		lr.r = r
+	}
+	return lr.r.Read(p)
+}
+
+// zeroReader returns an endless stream of zeros
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
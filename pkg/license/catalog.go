@@ -32,6 +32,7 @@ import (
 type CatalogOptions struct {
 	CacheDir string // Directrory to catch the license we download from SPDX.org
 	Version  string // Version of the licenses to download  (eg v3.19) or blank for latest
+	Embedded bool   // Use the minimal license list embedded in the binary instead of downloading
 }
 
 // DefaultCatalogOpts are the predetermined settings. License and cache directories
@@ -68,18 +69,35 @@ func (catalog *Catalog) Options() *CatalogOptions {
 	return catalog.opts
 }
 
-// LoadLicenses reads the license data from the downloader
+// LoadLicenses reads the license data from the downloader or, when
+// the catalog is set to use it or the download fails, from the list
+// embedded in the binary
 func (catalog *Catalog) LoadLicenses() error {
+	if catalog.opts != nil && catalog.opts.Embedded {
+		return catalog.loadEmbeddedLicenses()
+	}
 	logrus.Info("Loading license data from downloader")
 	licenses, err := catalog.Downloader.GetLicenses()
 	if err != nil {
-		return fmt.Errorf("getting licenses from downloader: %w", err)
+		logrus.Warnf("Could not get the SPDX license list, using the embedded one: %v", err)
+		return catalog.loadEmbeddedLicenses()
 	}
 	catalog.List = licenses
 	logrus.Infof("Got %d licenses from downloader", len(licenses.Licenses))
 	return nil
 }
 
+// loadEmbeddedLicenses loads the license list compiled into the binary
+func (catalog *Catalog) loadEmbeddedLicenses() error {
+	licenses, err := EmbeddedLicenseList()
+	if err != nil {
+		return fmt.Errorf("loading embedded license list: %w", err)
+	}
+	catalog.List = licenses
+	logrus.Infof("Loaded %d licenses from embedded data", len(licenses.Licenses))
+	return nil
+}
+
 // Catalog is an objec to interact with licenses and manifest creation
 type Catalog struct {
 	Downloader *Downloader     // License Downloader
@@ -0,0 +1,47 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package license
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// EmbeddedListVersion is the version string reported by the license
+// list compiled into the binary
+const EmbeddedListVersion = "embedded"
+
+// embeddedLicenses is a minimal set of the most common SPDX licenses. It
+// is used when the full license list cannot (or should not) be downloaded.
+//
+//go:embed data/licenses.json
+var embeddedLicenses []byte
+
+// EmbeddedLicenseList returns the minimal license list compiled into
+// the binary
+func EmbeddedLicenseList() (*List, error) {
+	licenses := []*License{}
+	if err := json.Unmarshal(embeddedLicenses, &licenses); err != nil {
+		return nil, fmt.Errorf("parsing embedded license data: %w", err)
+	}
+	list := &List{Version: EmbeddedListVersion}
+	for _, l := range licenses {
+		list.Add(l)
+	}
+	return list, nil
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package license
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// NoneExpression and NoAssertionExpression are the special values
+	// accepted instead of a license expression
+	NoneExpression        = "NONE"
+	NoAssertionExpression = "NOASSERTION"
+
+	// maxExpressionDepth caps the nesting of parentheses
+	maxExpressionDepth = 100
+)
+
+var (
+	// licenseIDRe matches the SPDX license list ids in license
+	// expressions, optionally followed by +
+	licenseIDRe = regexp.MustCompile(`^[a-zA-Z0-9.-]+\+?$`)
+
+	// licenseRefRe matches the LicenseRefs in license expressions,
+	// including those defined in other documents
+	licenseRefRe = regexp.MustCompile(`^(DocumentRef-[a-zA-Z0-9.-]+:)?LicenseRef-[a-zA-Z0-9.-]+$`)
+
+	// exceptionIDRe matches the license exceptions following WITH
+	exceptionIDRe = regexp.MustCompile(`^[a-zA-Z0-9.-]+$`)
+)
+
+// Expression is a parsed SPDX license expression
+type Expression struct {
+	Licenses   []string // Licenses and LicenseRefs in the expression, in order of appearance
+	Exceptions []string // License exceptions following WITH, in order of appearance
+}
+
+// ParseLicenseExpression parses an SPDX license expression, checking the
+// identifiers are well formed and the AND, OR and WITH operators and the
+// parentheses are used correctly. NONE and NOASSERTION are accepted as the
+// whole expression and parse to an expression without licenses. Whether
+// the licenses are in the SPDX list is not checked, see UnknownLicenses.
+func ParseLicenseExpression(expression string) (*Expression, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == NoneExpression || expression == NoAssertionExpression {
+		return &Expression{Licenses: []string{}, Exceptions: []string{}}, nil
+	}
+	tokens := strings.Fields(
+		strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression),
+	)
+	if len(tokens) == 0 {
+		return nil, errors.New("license expression is empty")
+	}
+	p := expressionParser{
+		tokens: tokens,
+		exp:    &Expression{Licenses: []string{}, Exceptions: []string{}},
+	}
+	if err := p.parseCompound(0); err != nil {
+		return nil, fmt.Errorf("parsing license expression %q: %w", expression, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("parsing license expression %q: unexpected %q", expression, p.tokens[p.pos])
+	}
+	return p.exp, nil
+}
+
+// IsLicenseID returns true if id is a well formed license identifier or
+// LicenseRef, as used in license expressions
+func IsLicenseID(id string) bool {
+	if strings.HasPrefix(id, "LicenseRef") || strings.HasPrefix(id, "DocumentRef") {
+		return licenseRefRe.MatchString(id)
+	}
+	return licenseIDRe.MatchString(id)
+}
+
+// UnknownLicenses returns the licenses of the expression which are not in
+// the SPDX license list used by the reader. LicenseRefs are never reported,
+// they are defined by the documents using them.
+func (r *Reader) UnknownLicenses(exp *Expression) []string {
+	unknown := []string{}
+	for _, id := range exp.Licenses {
+		if strings.HasPrefix(id, "LicenseRef-") || strings.HasPrefix(id, "DocumentRef-") {
+			continue
+		}
+		if r.LicenseFromLabel(strings.TrimSuffix(id, "+")) == nil {
+			unknown = append(unknown, id)
+		}
+	}
+	return unknown
+}
+
+// expressionParser is a recursive descent parser of license expressions:
+//
+//	compound = term { ("AND" | "OR") term }
+//	term     = "(" compound ")" | license [ "WITH" exception ]
+type expressionParser struct {
+	tokens []string
+	pos    int
+	exp    *Expression
+}
+
+func (p *expressionParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *expressionParser) parseCompound(depth int) error {
+	if depth > maxExpressionDepth {
+		return errors.New("expression is nested too deep")
+	}
+	if err := p.parseTerm(depth); err != nil {
+		return err
+	}
+	for {
+		switch p.next() {
+		case "AND", "and", "OR", "or":
+			p.pos++
+			if err := p.parseTerm(depth); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *expressionParser) parseTerm(depth int) error {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return errors.New("unexpected end of expression")
+	case tok == "(":
+		p.pos++
+		if err := p.parseCompound(depth + 1); err != nil {
+			return err
+		}
+		if p.next() != ")" {
+			return errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		return nil
+	case tok == ")" || isOperator(tok):
+		return fmt.Errorf("unexpected %q", tok)
+	case !IsLicenseID(tok):
+		return fmt.Errorf("%q is not a license identifier", tok)
+	}
+	p.exp.Licenses = appendUnique(p.exp.Licenses, tok)
+	p.pos++
+
+	if op := p.next(); op == "WITH" || op == "with" {
+		p.pos++
+		exception := p.next()
+		if exception == "" {
+			return errors.New("missing exception after WITH")
+		}
+		if isOperator(exception) || !exceptionIDRe.MatchString(exception) {
+			return fmt.Errorf("%q is not a license exception identifier", exception)
+		}
+		p.exp.Exceptions = appendUnique(p.exp.Exceptions, exception)
+		p.pos++
+	}
+	return nil
+}
+
+// isOperator returns true if the token is an operator of license
+// expressions. The spec allows them in uppercase or lowercase.
+func isOperator(tok string) bool {
+	switch tok {
+	case "AND", "and", "OR", "or", "WITH", "with":
+		return true
+	}
+	return false
+}
+
+// appendUnique appends s to the list if it is not already in it
+func appendUnique(list []string, s string) []string {
+	for _, e := range list {
+		if e == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime/debug"
 
 	licenseclassifier "github.com/google/licenseclassifier/v2"
 	"github.com/sirupsen/logrus"
@@ -179,11 +180,12 @@ func (d *ReaderDefaultImpl) Initialize(opts *ReaderOptions) error {
 	}
 
 	// Create the implementation's SPDX object
-	catalogOpts := DefaultCatalogOpts
+	catalogOpts := *DefaultCatalogOpts
 	catalogOpts.CacheDir = opts.CachePath()
 	catalogOpts.Version = opts.LicenseListVersion
+	catalogOpts.Embedded = opts.EmbeddedLicenses
 
-	catalog, err := NewCatalogWithOptions(catalogOpts)
+	catalog, err := NewCatalogWithOptions(&catalogOpts)
 	if err != nil {
 		return fmt.Errorf("creating SPDX object: %w", err)
 	}
@@ -208,6 +210,37 @@ func (d *ReaderDefaultImpl) Initialize(opts *ReaderOptions) error {
 	return nil
 }
 
+// ListVersion returns the version of the SPDX license list loaded in
+// the catalog
+func (d *ReaderDefaultImpl) ListVersion() string {
+	if d.catalog == nil || d.catalog.List == nil {
+		return ""
+	}
+	return d.catalog.List.Version
+}
+
+// classifierModule is the module path of the license classifier
+const classifierModule = "github.com/google/licenseclassifier/v2"
+
+// ClassifierVersion returns the version of the license classifier module
+// built into the binary, or an empty string if it cannot be determined
+func ClassifierVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != classifierModule {
+			continue
+		}
+		if dep.Replace != nil {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return ""
+}
+
 // Classifier returns the license classifier
 func (d *ReaderDefaultImpl) Classifier() *licenseclassifier.Classifier {
 	return d.lc
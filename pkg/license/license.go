@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -106,6 +107,7 @@ type ReaderOptions struct {
 	CacheDir            string  // Optional directory where the reader will store its downloads cache
 	LicenseDir          string  // Optional dir to store and read the SPDX licenses from
 	LicenseListVersion  string  // Version of the SPDX license list to use
+	EmbeddedLicenses    bool    // Use the license list embedded in the binary, skipping the download
 }
 
 // Validate checks the options to verify the are sane
@@ -163,6 +165,12 @@ func (r *Reader) LicenseFromFile(filePath string) (license *License, err error)
 	return license, err
 }
 
+// ListVersion returns the version of the SPDX license list used by
+// the reader to identify licenses
+func (r *Reader) ListVersion() string {
+	return r.impl.ListVersion()
+}
+
 // ReadTopLicense returns the topmost license file in a directory
 func (r *Reader) ReadTopLicense(path string) (*ClassifyResult, error) {
 	licenseFilePath := ""
@@ -232,6 +240,72 @@ func (r *Reader) ReadTopLicense(path string) (*ClassifyResult, error) {
 	return res, nil
 }
 
+// ReadTopLicenses returns the licenses of all the license files at the top
+// of a directory, eg both LICENSE-APACHE and LICENSE-MIT of dual licensed
+// projects, sorted by license ID. Files holding the same license are
+// reported once. When there are no license files at the top, the topmost
+// license found deeper in the tree is returned as in ReadTopLicense.
+func (r *Reader) ReadTopLicenses(path string) ([]*ClassifyResult, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory: %w", err)
+	}
+	licenseFiles := []string{}
+	for _, e := range entries {
+		if e.Type().IsRegular() && isTopLicenseFile(e.Name()) {
+			licenseFiles = append(licenseFiles, filepath.Join(path, e.Name()))
+		}
+	}
+
+	results := []*ClassifyResult{}
+	if len(licenseFiles) > 0 {
+		found, _, err := r.impl.ClassifyLicenseFiles(licenseFiles)
+		if err != nil {
+			return nil, fmt.Errorf("scanning top license files: %w", err)
+		}
+		seen := map[string]struct{}{}
+		for _, res := range found {
+			if res == nil || res.License == nil {
+				continue
+			}
+			if _, ok := seen[res.License.LicenseID]; ok {
+				continue
+			}
+			seen[res.License.LicenseID] = struct{}{}
+			logrus.Debugf("Found license %s in %s", res.License.LicenseID, res.File)
+			results = append(results, res)
+		}
+	}
+	if len(results) > 0 {
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].License.LicenseID < results[j].License.LicenseID
+		})
+		return results, nil
+	}
+
+	res, err := r.ReadTopLicense(path)
+	if err != nil {
+		return nil, err
+	}
+	if res != nil {
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// isTopLicenseFile returns true if the name is one of the usual names of
+// license files, alone or followed by a suffix (LICENSE-MIT, COPYING.LESSER)
+func isTopLicenseFile(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, prefix := range []string{"LICENSE", "LICENCE", "COPYING", "COPYRIGHT", "UNLICENSE"} {
+		rest, ok := strings.CutPrefix(upper, prefix)
+		if ok && (rest == "" || strings.ContainsAny(rest[:1], ".-_")) {
+			return true
+		}
+	}
+	return false
+}
+
 // ReadLicenses returns an array of all licenses found in the specified path
 func (r *Reader) ReadLicenses(path string) (
 	licenseList []*ClassifyResult, unknownPaths []string, err error,
@@ -266,6 +340,7 @@ type ReaderImplementation interface {
 	LicenseFromFile(string) (*License, error)
 	LicenseFromLabel(string) *License
 	FindLicenseFiles(string) ([]string, error)
+	ListVersion() string
 }
 
 // HasKubernetesBoilerPlate checks if a file contains the Kubernetes License boilerplate
@@ -54,22 +54,20 @@ func TestISCatalogLoadLicenses(t *testing.T) {
 	spdx.Downloader = downloader
 
 	for _, tc := range []struct {
-		mustFail        bool
 		dnLoaderReturns *license.List
 		dnLoaderError   error
+		expectedVersion string
 	}{
-		{true, nil, errors.New("Some download error")},
-		{false, &license.List{}, nil},
+		// When the download fails, the embedded list is loaded
+		{nil, errors.New("Some download error"), license.EmbeddedListVersion},
+		{&license.List{Version: "v3.20"}, nil, "v3.20"},
 	} {
 		impl := licensefakes.FakeDownloaderImplementation{}
 		impl.GetLicensesReturns(tc.dnLoaderReturns, tc.dnLoaderError)
 		downloader.SetImplementation(&impl)
 
-		if tc.mustFail {
-			require.NotNil(t, spdx.LoadLicenses())
-		} else {
-			require.Nil(t, spdx.LoadLicenses())
-		}
+		require.Nil(t, spdx.LoadLicenses())
+		require.Equal(t, tc.expectedVersion, spdx.List.Version)
 	}
 }
 
@@ -189,3 +187,56 @@ func TestParseSPDXLicense(t *testing.T) {
 	require.Equal(t, testsLicense.Name, "Apache License 2.0")
 	require.Equal(t, testsLicense.LicenseID, "Apache-2.0")
 }
+
+func TestEmbeddedLicenseList(t *testing.T) {
+	list, err := license.EmbeddedLicenseList()
+	require.NoError(t, err)
+	require.Equal(t, license.EmbeddedListVersion, list.Version)
+	for _, id := range []string{"Apache-2.0", "MIT", "BSD-3-Clause", "GPL-2.0-only"} {
+		require.Contains(t, list.Licenses, id)
+		require.NotEmpty(t, list.Licenses[id].LicenseText)
+	}
+}
+
+func TestReaderEmbeddedLicenses(t *testing.T) {
+	workDir := t.TempDir()
+
+	// No license dir is configured, the reader should classify
+	// using the licenses compiled into the binary.
+	reader, err := license.NewReaderWithOptions(&license.ReaderOptions{
+		ConfidenceThreshold: 0.9,
+		WorkDir:             workDir,
+		EmbeddedLicenses:    true,
+	})
+	require.NoError(t, err)
+
+	list, err := license.EmbeddedLicenseList()
+	require.NoError(t, err)
+	licPath := filepath.Join(workDir, "LICENSE")
+	require.NoError(t, os.WriteFile(
+		licPath, []byte(list.Licenses["Apache-2.0"].LicenseText), os.FileMode(0o644),
+	))
+
+	lic, err := reader.LicenseFromFile(licPath)
+	require.NoError(t, err)
+	require.NotNil(t, lic)
+	require.Equal(t, "Apache-2.0", lic.LicenseID)
+}
+
+func TestReaderUnknownLicenses(t *testing.T) {
+	impl := &licensefakes.FakeReaderImplementation{}
+	impl.LicenseFromLabelCalls(func(label string) *license.License {
+		if label == "MIT" || label == "GPL-2.0" {
+			return &license.License{LicenseID: label}
+		}
+		return nil
+	})
+	reader := license.Reader{}
+	require.NoError(t, reader.SetImplementation(impl))
+
+	exp, err := license.ParseLicenseExpression(
+		"MIT AND (GPL-2.0+ WITH Classpath-exception-2.0 OR Made-Up-1.0) AND LicenseRef-custom",
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Made-Up-1.0"}, reader.UnknownLicenses(exp))
+}
@@ -96,3 +96,43 @@ func TestFindLicenseFiles(t *testing.T) {
 	require.NotContains(t, res, filepath.Join(tempdir, "license.go"))
 	require.NotContains(t, res, filepath.Join(tempdir, "README.md"))
 }
+
+func TestParseLicenseExpression(t *testing.T) {
+	for expression, expected := range map[string]*Expression{
+		"MIT":                                 {Licenses: []string{"MIT"}, Exceptions: []string{}},
+		"NOASSERTION":                         {Licenses: []string{}, Exceptions: []string{}},
+		"NONE":                                {Licenses: []string{}, Exceptions: []string{}},
+		"GPL-2.0+":                            {Licenses: []string{"GPL-2.0+"}, Exceptions: []string{}},
+		"LicenseRef-my-license":               {Licenses: []string{"LicenseRef-my-license"}, Exceptions: []string{}},
+		"DocumentRef-other:LicenseRef-custom": {Licenses: []string{"DocumentRef-other:LicenseRef-custom"}, Exceptions: []string{}},
+		"MIT or Apache-2.0":                   {Licenses: []string{"MIT", "Apache-2.0"}, Exceptions: []string{}},
+		"(MIT OR Apache-2.0) AND BSD-3-Clause AND MIT": {
+			Licenses: []string{"MIT", "Apache-2.0", "BSD-3-Clause"}, Exceptions: []string{},
+		},
+		"GPL-2.0-only WITH Classpath-exception-2.0": {
+			Licenses: []string{"GPL-2.0-only"}, Exceptions: []string{"Classpath-exception-2.0"},
+		},
+		"((MIT))":                   {Licenses: []string{"MIT"}, Exceptions: []string{}},
+		"":                          nil,
+		"MIT OR":                    nil,
+		"AND MIT":                   nil,
+		"(MIT":                      nil,
+		"MIT)":                      nil,
+		"()":                        nil,
+		"MIT Apache-2.0":            nil,
+		"MIT WITH":                  nil,
+		"MIT WITH OR":               nil,
+		"GPL 2":                     nil,
+		"MIT OR NONE OR Apache_2.0": nil,
+		"LicenseRef-":               nil,
+		"DocumentRef-x:MIT":         nil,
+	} {
+		exp, err := ParseLicenseExpression(expression)
+		if expected == nil {
+			require.Error(t, err, expression)
+			continue
+		}
+		require.NoError(t, err, expression)
+		require.Equal(t, expected, exp, expression)
+	}
+}
@@ -103,6 +103,16 @@ type FakeReaderImplementation struct {
 	licenseFromLabelReturnsOnCall map[int]struct {
 		result1 *license.License
 	}
+	ListVersionStub        func() string
+	listVersionMutex       sync.RWMutex
+	listVersionArgsForCall []struct {
+	}
+	listVersionReturns struct {
+		result1 string
+	}
+	listVersionReturnsOnCall map[int]struct {
+		result1 string
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -496,6 +506,59 @@ func (fake *FakeReaderImplementation) LicenseFromLabelReturnsOnCall(i int, resul
 	}{result1}
 }
 
+func (fake *FakeReaderImplementation) ListVersion() string {
+	fake.listVersionMutex.Lock()
+	ret, specificReturn := fake.listVersionReturnsOnCall[len(fake.listVersionArgsForCall)]
+	fake.listVersionArgsForCall = append(fake.listVersionArgsForCall, struct {
+	}{})
+	stub := fake.ListVersionStub
+	fakeReturns := fake.listVersionReturns
+	fake.recordInvocation("ListVersion", []interface{}{})
+	fake.listVersionMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeReaderImplementation) ListVersionCallCount() int {
+	fake.listVersionMutex.RLock()
+	defer fake.listVersionMutex.RUnlock()
+	return len(fake.listVersionArgsForCall)
+}
+
+func (fake *FakeReaderImplementation) ListVersionCalls(stub func() string) {
+	fake.listVersionMutex.Lock()
+	defer fake.listVersionMutex.Unlock()
+	fake.ListVersionStub = stub
+}
+
+func (fake *FakeReaderImplementation) ListVersionReturns(result1 string) {
+	fake.listVersionMutex.Lock()
+	defer fake.listVersionMutex.Unlock()
+	fake.ListVersionStub = nil
+	fake.listVersionReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeReaderImplementation) ListVersionReturnsOnCall(i int, result1 string) {
+	fake.listVersionMutex.Lock()
+	defer fake.listVersionMutex.Unlock()
+	fake.ListVersionStub = nil
+	if fake.listVersionReturnsOnCall == nil {
+		fake.listVersionReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.listVersionReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
 func (fake *FakeReaderImplementation) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -511,6 +574,8 @@ func (fake *FakeReaderImplementation) Invocations() map[string][][]interface{} {
 	defer fake.licenseFromFileMutex.RUnlock()
 	fake.licenseFromLabelMutex.RLock()
 	defer fake.licenseFromLabelMutex.RUnlock()
+	fake.listVersionMutex.RLock()
+	defer fake.listVersionMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
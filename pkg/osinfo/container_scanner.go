@@ -19,6 +19,7 @@ package osinfo
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -27,14 +28,18 @@ import (
 	apk "gitlab.alpinelinux.org/alpine/go/repository"
 )
 
-const apkDBPath = "lib/apk/db/installed"
+// apkDBPaths are the locations of the apk database. Images with a merged
+// /usr have /lib as a symlink, their layers record it under usr/lib.
+var apkDBPaths = []string{"lib/apk/db/installed", "usr/lib/apk/db/installed"}
 
 // TODO: Move functions to its own implementation
 type ContainerScanner struct{}
 
 // ReadOSPackages reads a bunch of layers and extracts the os package
-// information from them, it returns the OS package and the layer where
-// they are defined. If the OS is not supported, we return a nil pointer.
+// information from them, it returns the OS packages and the last layer
+// where the package database is defined. The layer where each package was
+// installed is recorded in its entry. If the OS is not supported, we
+// return a nil pointer.
 func (ct *ContainerScanner) ReadOSPackages(layers []string) (
 	layerNum int, packages *[]PackageDBEntry, err error,
 ) {
@@ -53,6 +58,7 @@ func (ct *ContainerScanner) ReadOSPackages(layers []string) (
 	}
 
 	purlType := ""
+	purlNamespace := osKind
 
 	switch osKind {
 	case OSDebian, OSUbuntu:
@@ -61,12 +67,29 @@ func (ct *ContainerScanner) ReadOSPackages(layers []string) (
 	case OSAlpine, OSWolfi:
 		layerNum, packages, err = ct.ReadApkPackages(layers)
 		purlType = "apk"
-	default:
-		return 0, nil, nil
+	case OSFedora, OSCentos, OSRHEL:
+		layerNum, packages, err = ct.ReadRpmPackages(layers)
+		purlType = purl.TypeRPM
+		purlNamespace = rpmNamespace(osKind)
 	}
+	if err != nil {
+		return layerNum, packages, err
+	}
+	ct.setPurlData(purlType, purlNamespace, packages)
 
-	ct.setPurlData(purlType, osKind, packages)
-	return layerNum, packages, err
+	// Packages from language package managers are listed next to the
+	// OS packages, even when the OS is not supported.
+	pythonPackages, err := ct.ReadPythonPackages(layers)
+	if err != nil {
+		return layerNum, packages, fmt.Errorf("reading python packages: %w", err)
+	}
+	if len(*pythonPackages) > 0 {
+		if packages == nil {
+			packages = &[]PackageDBEntry{}
+		}
+		*packages = append(*packages, *pythonPackages...)
+	}
+	return layerNum, packages, nil
 }
 
 // setPurlData stamps al found packages with the purl type and NS
@@ -81,11 +104,11 @@ func (ct *ContainerScanner) setPurlData(ptype, pnamespace string, packages *[]Pa
 }
 
 // ReadDebianPackages scans through a set of container layers looking for the
-// last update to the debian package database. If found, extracts it and
-// sends it to parseDpkgDB to extract the package information from the file.
+// last update to the debian package database. Each copy of the database is
+// parsed with parseDpkgDB to record in which layer every package was installed.
+// It returns the packages in the last copy of the database.
 func (ct *ContainerScanner) ReadDebianPackages(layers []string) (layer int, pk *[]PackageDBEntry, err error) {
 	// Cycle the layers in order, trying to extract the dpkg database
-	dpkgDatabase := ""
 	loss := LayerScanner{}
 	for i, lp := range layers {
 		dpkgDB, err := os.CreateTemp("", "dpkg-")
@@ -93,60 +116,107 @@ func (ct *ContainerScanner) ReadDebianPackages(layers []string) (layer int, pk *
 			return 0, pk, fmt.Errorf("opening temp dpkg file: %w", err)
 		}
 		dpkgPath := dpkgDB.Name()
+		dpkgDB.Close()
 		if err := loss.extractFileFromTar(lp, "var/lib/dpkg/status", dpkgPath); err != nil {
-			os.Remove(dpkgDB.Name())
+			os.Remove(dpkgPath)
 			if _, ok := err.(ErrFileNotFoundInTar); ok {
 				continue
 			}
 			return 0, pk, fmt.Errorf("extracting dpkg database: %w", err)
 		}
 		logrus.Infof("Layer %d has a newer version of dpkg database", i)
-		dpkgDatabase = dpkgPath
+		layerPackages, err := ct.parseDpkgDB(dpkgPath)
+		os.Remove(dpkgPath)
+		if err != nil {
+			return 0, nil, fmt.Errorf("parsing dpkg database from layer %d: %w", i, err)
+		}
+		attributeLayer(pk, layerPackages, i)
+		pk = layerPackages
 		layer = i
 	}
 
-	if dpkgDatabase == "" {
+	if pk == nil {
 		logrus.Info("dbdata is blank")
-		return layer, nil, nil
 	}
-	defer os.Remove(dpkgDatabase)
-	pk, err = ct.parseDpkgDB(dpkgDatabase)
-	return layer, pk, err
+	if err := setDebianInstallReasons(layers, pk); err != nil {
+		return layer, nil, err
+	}
+	return layer, pk, nil
 }
 
-// ReadApkPackages reads the last known changed copy of the apk database
+// ReadApkPackages reads the last known changed copy of the apk database.
+// Each copy is parsed to record in which layer every package was installed.
 func (ct *ContainerScanner) ReadApkPackages(layers []string) (layer int, pk *[]PackageDBEntry, err error) {
-	apkDatabase := ""
-	loss := LayerScanner{}
 	for i, lp := range layers {
-		tmpDB, err := os.CreateTemp("", "apkdb-")
+		tmpDBPath, dbPath, err := extractFirstFile(lp, apkDBPaths)
 		if err != nil {
-			return 0, pk, fmt.Errorf("opening temporary apkdb file: %w", err)
-		}
-		tmpDBPath := tmpDB.Name()
-		if err := loss.extractFileFromTar(lp, apkDBPath, tmpDBPath); err != nil {
-			os.Remove(tmpDBPath)
-			if _, ok := err.(ErrFileNotFoundInTar); ok {
-				continue
-			}
 			return 0, pk, fmt.Errorf("extracting apk database: %w", err)
 		}
+		if dbPath == "" {
+			continue
+		}
 		logrus.Debugf("Layer %d has a newer version of apk database", i)
-		apkDatabase = tmpDBPath
+		layerPackages, err := ct.parseApkDB(tmpDBPath)
+		os.Remove(tmpDBPath)
+		if err != nil {
+			return layer, nil, fmt.Errorf("parsing apk database: %w", err)
+		}
+		attributeLayer(pk, layerPackages, i)
+		pk = layerPackages
 		layer = i
 	}
 
-	if apkDatabase == "" {
+	if pk == nil {
 		logrus.Info("apk database data is empty")
-		return layer, nil, nil
 	}
-	defer os.Remove(apkDatabase)
+	if err := setApkInstallReasons(layers, pk); err != nil {
+		return layer, nil, err
+	}
+	return layer, pk, nil
+}
 
-	pk, err = ct.parseApkDB(apkDatabase)
-	if err != nil {
-		return layer, nil, fmt.Errorf("parsing apk database: %w", err)
+// extractFirstFile extracts from a layer the first of the paths found in
+// it to a temporary file, which the caller removes. It returns the path
+// extracted, which is empty when the layer has none of them.
+func extractFirstFile(layerPath string, paths []string) (tmpPath, filePath string, err error) {
+	loss := LayerScanner{}
+	for _, p := range paths {
+		tmp, err := os.CreateTemp("", "osinfo-")
+		if err != nil {
+			return "", "", fmt.Errorf("opening temporary file: %w", err)
+		}
+		tmpPath := tmp.Name()
+		tmp.Close()
+		if err := loss.extractFileFromTar(layerPath, p, tmpPath); err != nil {
+			os.Remove(tmpPath)
+			if _, ok := err.(ErrFileNotFoundInTar); ok {
+				continue
+			}
+			return "", "", fmt.Errorf("extracting %s: %w", p, err)
+		}
+		return tmpPath, p, nil
+	}
+	return "", "", nil
+}
+
+// attributeLayer sets the layer of the packages read from the database
+// copy in layer. Packages found with the same version in the previous
+// copy of the database keep the layer where they were installed.
+func attributeLayer(previous, current *[]PackageDBEntry, layer int) {
+	installed := map[string]*PackageDBEntry{}
+	if previous != nil {
+		for i := range *previous {
+			e := &(*previous)[i]
+			installed[e.Package+"/"+e.Architecture] = e
+		}
+	}
+	for i := range *current {
+		e := &(*current)[i]
+		e.Layer = layer
+		if prev, ok := installed[e.Package+"/"+e.Architecture]; ok && prev.Version == e.Version {
+			e.Layer = prev.Layer
+		}
 	}
-	return layer, pk, err
 }
 
 type PackageDBEntry struct {
@@ -160,6 +230,8 @@ type PackageDBEntry struct {
 	HomePage        string
 	License         string // License expression
 	Checksums       map[string]string
+	Layer           int    // Index of the image layer where the package was installed
+	InstallReason   string // InstallReasonExplicit or InstallReasonDependency, empty when unknown
 }
 
 // PackageURL returns a purl representing the db entry. If the entry
@@ -167,21 +239,34 @@ type PackageDBEntry struct {
 // empty string
 func (e *PackageDBEntry) PackageURL() string {
 	// We require type, package, namespace and version at the very
-	// least to generate a purl
-	if e.Package == "" || e.Version == "" || e.Namespace == "" || e.Type == "" {
+	// least to generate a purl. PyPI packages have no namespace.
+	if e.Package == "" || e.Version == "" || e.Type == "" {
+		return ""
+	}
+	if e.Namespace == "" && e.Type != purl.TypePyPi {
 		return ""
 	}
 
 	qualifiersMap := map[string]string{}
+	version := e.Version
 
 	// Add the architecture
 	// TODO(puerco): Support adding the distro
 	if e.Architecture != "" {
 		qualifiersMap["arch"] = e.Architecture
 	}
+
+	// rpm purls record the epoch as a qualifier
+	if e.Type == purl.TypeRPM {
+		var epoch string
+		epoch, version = splitRpmEpoch(version)
+		if epoch != "" {
+			qualifiersMap["epoch"] = epoch
+		}
+	}
 	return purl.NewPackageURL(
 		e.Type, e.Namespace, e.Package,
-		e.Version, purl.QualifiersFromMap(qualifiersMap), "",
+		version, purl.QualifiersFromMap(qualifiersMap), "",
 	).ToString()
 }
 
@@ -194,8 +279,13 @@ func (ct *ContainerScanner) parseDpkgDB(dbPath string) (*[]PackageDBEntry, error
 	}
 	defer file.Close()
 	logrus.Infof("Scanning data from dpkg database in %s", dbPath)
+	return parseDpkgData(file)
+}
+
+// parseDpkgData reads the packages of a dpkg database from r
+func parseDpkgData(r io.Reader) (*[]PackageDBEntry, error) {
 	db := []PackageDBEntry{}
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	var curPkg *PackageDBEntry
 	for scanner.Scan() {
 		parts := strings.SplitN(scanner.Text(), ":", 2)
@@ -226,11 +316,7 @@ func (ct *ContainerScanner) parseDpkgDB(dbPath string) (*[]PackageDBEntry, error
 			}
 		case "Maintainer":
 			if curPkg != nil {
-				mparts := strings.SplitN(parts[1], "<", 2)
-				if len(mparts) == 2 {
-					curPkg.MaintainerName = strings.TrimSpace(mparts[0])
-					curPkg.MaintainerEmail = strings.TrimSuffix(strings.TrimSpace(mparts[1]), ">")
-				}
+				curPkg.MaintainerName, curPkg.MaintainerEmail = splitMaintainer(parts[1])
 			}
 		}
 	}
@@ -241,7 +327,7 @@ func (ct *ContainerScanner) parseDpkgDB(dbPath string) (*[]PackageDBEntry, error
 		return nil, fmt.Errorf("scanning database file: %w", err)
 	}
 
-	return &db, err
+	return &db, nil
 }
 
 func (ct *ContainerScanner) parseApkDB(dbPath string) (*[]PackageDBEntry, error) {
@@ -249,7 +335,13 @@ func (ct *ContainerScanner) parseApkDB(dbPath string) (*[]PackageDBEntry, error)
 	if err != nil {
 		return nil, fmt.Errorf("opening apkdb: %w", err)
 	}
-	apks, err := apk.ParsePackageIndex(f)
+	defer f.Close()
+	return parseApkData(f)
+}
+
+// parseApkData reads the packages of an apk database from r
+func parseApkData(r io.Reader) (*[]PackageDBEntry, error) {
+	apks, err := apk.ParsePackageIndex(r)
 	if err != nil {
 		return nil, fmt.Errorf("parsing apk db: %w", err)
 	}
@@ -263,15 +355,28 @@ func (ct *ContainerScanner) parseApkDB(dbPath string) (*[]PackageDBEntry, error)
 			cs["MD5"] = fmt.Sprintf("%x", p.Checksum)
 		}
 
+		maintainerName, maintainerEmail := splitMaintainer(p.Maintainer)
 		packages = append(packages, PackageDBEntry{
-			Package:        p.Name,
-			Version:        p.Version,
-			Architecture:   p.Arch,
-			Type:           "apk",
-			MaintainerName: p.Maintainer,
-			License:        p.License,
-			Checksums:      cs,
+			Package:         p.Name,
+			Version:         p.Version,
+			Architecture:    p.Arch,
+			Type:            "apk",
+			MaintainerName:  maintainerName,
+			MaintainerEmail: maintainerEmail,
+			License:         p.License,
+			Checksums:       cs,
 		})
 	}
 	return &packages, nil
 }
+
+// splitMaintainer splits a maintainer written as "Name <email>", as both
+// dpkg and apk record them, in its name and email. A maintainer without an
+// email is returned whole as the name.
+func splitMaintainer(maintainer string) (name, email string) {
+	parts := strings.SplitN(maintainer, "<", 2)
+	if len(parts) < 2 {
+		return strings.TrimSpace(maintainer), ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSuffix(strings.TrimSpace(parts[1]), ">")
+}
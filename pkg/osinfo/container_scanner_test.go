@@ -17,8 +17,11 @@ limitations under the License.
 package osinfo
 
 import (
+	"archive/tar"
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -64,6 +67,63 @@ func TestReadDebianPackages(t *testing.T) {
 	}
 }
 
+func TestReadDebianPackagesLayers(t *testing.T) {
+	ct := ContainerScanner{}
+
+	// The second layer installs four more packages on top of the first
+	_, packages, err := ct.ReadDebianPackages([]string{
+		"testdata/link-with-no-dots.tar.gz",
+		"testdata/dpkg-layer1.tar.gz",
+		"testdata/dpkg-layer2.tar.gz",
+	})
+	require.NoError(t, err)
+	require.Len(t, *packages, 87)
+	installed := []string{}
+	for _, p := range *packages {
+		if p.Layer == 2 {
+			installed = append(installed, p.Package)
+		} else {
+			require.Equal(t, 1, p.Layer, p.Package)
+		}
+	}
+	require.ElementsMatch(t, []string{"ca-certificates", "libssl1.1", "netbase", "openssl"}, installed)
+
+	// When a later layer removes packages, the rest keep their layer
+	_, packages, err = ct.ReadDebianPackages([]string{
+		"testdata/dpkg-layer1.tar.gz",
+		"testdata/dpkg-layer2.tar.gz",
+		"testdata/dpkg-layer1.tar.gz",
+	})
+	require.NoError(t, err)
+	require.Len(t, *packages, 83)
+	for _, p := range *packages {
+		require.Equal(t, 0, p.Layer, p.Package)
+	}
+}
+
+func TestAttributeLayer(t *testing.T) {
+	previous := &[]PackageDBEntry{
+		{Package: "kept", Version: "1.0", Layer: 0},
+		{Package: "upgraded", Version: "1.0", Layer: 0},
+		{Package: "removed", Version: "1.0", Layer: 1},
+	}
+	current := &[]PackageDBEntry{
+		{Package: "kept", Version: "1.0"},
+		{Package: "upgraded", Version: "2.0"},
+		{Package: "new", Version: "1.0"},
+	}
+	attributeLayer(previous, current, 2)
+	require.Equal(t, 0, (*current)[0].Layer)
+	require.Equal(t, 2, (*current)[1].Layer)
+	require.Equal(t, 2, (*current)[2].Layer)
+
+	// Without a previous database, all packages belong to the layer
+	attributeLayer(nil, previous, 3)
+	for _, p := range *previous {
+		require.Equal(t, 3, p.Layer)
+	}
+}
+
 func TestReadOSPackages(t *testing.T) {
 	ct := ContainerScanner{}
 	layer, packages, err := ct.ReadOSPackages([]string{
@@ -83,6 +143,132 @@ func TestReadOSPackages(t *testing.T) {
 	require.Error(t, err)
 }
 
+// writeTestLayer writes an uncompressed layer tarball with the files
+// in the map and returns its path
+func writeTestLayer(t *testing.T, files map[string]string) string {
+	layerPath := filepath.Join(t.TempDir(), "layer.tar")
+	f, err := os.Create(layerPath)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for name, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return layerPath
+}
+
+func TestReadOSPackagesMultipleManagers(t *testing.T) {
+	layer := writeTestLayer(t, map[string]string{
+		"etc/os-release": "PRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\nNAME=\"Debian GNU/Linux\"\nID=debian\n",
+		"var/lib/dpkg/status": "Package: base-files\nVersion: 12.4\nArchitecture: amd64\n\n" +
+			"Package: python3\nVersion: 3.11.2-1\nArchitecture: amd64\n\n" +
+			"Package: zlib1g\nVersion: 1:1.2.13\nArchitecture: amd64\n",
+		"usr/lib/python3/dist-packages/requests-2.28.1.dist-info/METADATA": "Metadata-Version: 2.1\n" +
+			"Name: requests\nVersion: 2.28.1\nHome-page: https://requests.readthedocs.io\n\nRequests is an HTTP library.\nName: ignored\n",
+		"usr/local/lib/python3.11/site-packages/six-1.16.0.egg-info/PKG-INFO": "Name: six\nVersion: 1.16.0\n",
+		"usr/local/lib/python3.11/site-packages/six.py":                       "# not metadata\n",
+	})
+	second := writeTestLayer(t, map[string]string{
+		"usr/local/lib/python3.11/site-packages/PyYAML-6.0.dist-info/METADATA": "Name: PyYAML\nVersion: 6.0\n",
+	})
+
+	ct := ContainerScanner{}
+	_, packages, err := ct.ReadOSPackages([]string{layer, second})
+	require.NoError(t, err)
+	require.NotNil(t, packages)
+
+	byName := map[string]*PackageDBEntry{}
+	for i := range *packages {
+		byName[(*packages)[i].Package] = &(*packages)[i]
+	}
+	require.Equal(t, purl.TypeDebian, byName["base-files"].Type)
+	require.Equal(t, "pkg:deb/debian/base-files@12.4?arch=amd64", byName["base-files"].PackageURL())
+
+	require.Equal(t, purl.TypePyPi, byName["requests"].Type)
+	require.Equal(t, "2.28.1", byName["requests"].Version)
+	require.Equal(t, "https://requests.readthedocs.io", byName["requests"].HomePage)
+	require.Equal(t, "pkg:pypi/requests@2.28.1", byName["requests"].PackageURL())
+	require.Equal(t, 0, byName["requests"].Layer)
+	require.Equal(t, "pkg:pypi/six@1.16.0", byName["six"].PackageURL())
+	require.Equal(t, 1, byName["PyYAML"].Layer)
+	require.NotContains(t, byName, "ignored")
+
+	// Headers longer than the default line limit of the scanner are read,
+	// metadata with longer lines is skipped
+	long := writeTestLayer(t, map[string]string{
+		"usr/local/lib/python3.11/site-packages/long-1.0.dist-info/METADATA": "Name: long\nVersion: 1.0\n" +
+			"License: " + strings.Repeat("x", 128*1024) + "\n",
+		"usr/local/lib/python3.11/site-packages/huge-1.0.dist-info/METADATA": "Name: huge\nVersion: 1.0\n" +
+			"License: " + strings.Repeat("x", maxPythonMetadataLine) + "\n",
+	})
+	_, packages, err = ct.ReadOSPackages([]string{long})
+	require.NoError(t, err)
+	require.Len(t, *packages, 1)
+	require.Equal(t, "long", (*packages)[0].Package)
+
+	// Python packages are found even when the OS is not supported
+	_, packages, err = ct.ReadOSPackages([]string{second})
+	require.NoError(t, err)
+	require.Len(t, *packages, 1)
+	require.Equal(t, "PyYAML", (*packages)[0].Package)
+}
+
+func TestReadDebianPackagesInstallReason(t *testing.T) {
+	status := "Package: base-files\nVersion: 12.4\nArchitecture: amd64\n\n" +
+		"Package: curl\nVersion: 7.88.1-10\nArchitecture: amd64\n\n" +
+		"Package: libcurl4\nVersion: 7.88.1-10\nArchitecture: amd64\n\n" +
+		"Package: libssl3\nVersion: 3.0.11-1\nArchitecture: amd64\n\n"
+	base := writeTestLayer(t, map[string]string{"var/lib/dpkg/status": status})
+	install := writeTestLayer(t, map[string]string{
+		"var/lib/dpkg/status": status,
+		aptExtendedStatesPath: "Package: libcurl4\nArchitecture: amd64\nAuto-Installed: 1\n\n" +
+			"Package: libssl3\nArchitecture: i386\nAuto-Installed: 1\n\n" +
+			"Package: curl\nArchitecture: amd64\nAuto-Installed: 0\n",
+	})
+
+	ct := ContainerScanner{}
+	reasons := func(layers ...string) map[string]string {
+		_, packages, err := ct.ReadDebianPackages(layers)
+		require.NoError(t, err)
+		ret := map[string]string{}
+		for _, p := range *packages {
+			ret[p.Package] = p.InstallReason
+		}
+		return ret
+	}
+
+	// Packages marked as auto installed for their architecture were
+	// pulled in as dependencies, the rest were installed explicitly
+	r := reasons(base, install)
+	require.Equal(t, InstallReasonExplicit, r["base-files"])
+	require.Equal(t, InstallReasonExplicit, r["curl"])
+	require.Equal(t, InstallReasonDependency, r["libcurl4"])
+
+	// Without the apt states the reason is unknown
+	for _, reason := range reasons(base) {
+		require.Empty(t, reason)
+	}
+}
+
+func TestParseApkWorld(t *testing.T) {
+	world := parseApkWorld([]byte("alpine-baselayout busybox\ncurl>=8.0 python3=3.11.6-r0\nfoo@testing !bar\n"))
+	require.Equal(t, map[string]struct{}{
+		"alpine-baselayout": {}, "busybox": {}, "curl": {}, "python3": {}, "foo": {},
+	}, world)
+
+	layer := writeTestLayer(t, map[string]string{apkWorldPath: "busybox\ncurl>=8.0\n"})
+	packages := &[]PackageDBEntry{{Package: "busybox"}, {Package: "curl"}, {Package: "libcurl"}}
+	require.NoError(t, setApkInstallReasons([]string{layer}, packages))
+	require.Equal(t, InstallReasonExplicit, (*packages)[0].InstallReason)
+	require.Equal(t, InstallReasonExplicit, (*packages)[1].InstallReason)
+	require.Equal(t, InstallReasonDependency, (*packages)[2].InstallReason)
+}
+
 func TestPackageURL(t *testing.T) {
 	for _, tc := range []struct {
 		dbe      PackageDBEntry
@@ -160,3 +346,119 @@ func TestParseApkDB(t *testing.T) {
 	require.Equal(t, "MPL-2.0 AND MIT", (*pk)[0].License)
 	require.Equal(t, "e07d34854d632d6491a45dd854cdabd177e990cc", (*pk)[0].Checksums["SHA1"])
 }
+
+func TestExtractFirstFile(t *testing.T) {
+	for _, dbPath := range apkDBPaths {
+		layer := writeTestLayer(t, map[string]string{dbPath: "P:busybox\nV:1.36.1-r5\n"})
+		tmpPath, found, err := extractFirstFile(layer, apkDBPaths)
+		require.NoError(t, err)
+		require.Equal(t, dbPath, found)
+		data, err := os.ReadFile(tmpPath)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(tmpPath))
+		require.Equal(t, "P:busybox\nV:1.36.1-r5\n", string(data))
+	}
+
+	layer := writeTestLayer(t, map[string]string{"etc/alpine-release": "3.18.4\n"})
+	_, found, err := extractFirstFile(layer, apkDBPaths)
+	require.NoError(t, err)
+	require.Empty(t, found)
+}
+
+func TestSplitMaintainer(t *testing.T) {
+	for _, tc := range []struct {
+		maintainer string
+		name       string
+		email      string
+	}{
+		{"Natanael Copa <ncopa@alpinelinux.org>", "Natanael Copa", "ncopa@alpinelinux.org"},
+		{" Debian Go Packaging Team <team+pkg-go@tracker.debian.org>", "Debian Go Packaging Team", "team+pkg-go@tracker.debian.org"},
+		{"Wolfi", "Wolfi", ""},
+		{"", "", ""},
+	} {
+		name, email := splitMaintainer(tc.maintainer)
+		require.Equal(t, tc.name, name, tc.maintainer)
+		require.Equal(t, tc.email, email, tc.maintainer)
+	}
+}
+
+func TestParseRpmDB(t *testing.T) {
+	ct := &ContainerScanner{}
+	for _, tc := range []struct {
+		dbPath      string
+		numPackages int
+	}{
+		{"testdata/rpmdb.sqlite", 102},
+		{"testdata/rpmdb-Packages", 2},
+	} {
+		pk, err := ct.parseRpmDB(tc.dbPath)
+		require.NoError(t, err, tc.dbPath)
+		require.Len(t, *pk, tc.numPackages, tc.dbPath)
+
+		byName := map[string]*PackageDBEntry{}
+		for i := range *pk {
+			byName[(*pk)[i].Package] = &(*pk)[i]
+		}
+		require.NotContains(t, byName, rpmGPGKeyPackage)
+
+		bash := byName["bash"]
+		require.NotNil(t, bash, tc.dbPath)
+		require.Equal(t, "5.2.15-3.fc38", bash.Version)
+		require.Equal(t, "x86_64", bash.Architecture)
+		require.Equal(t, "GPL-3.0-or-later", bash.License)
+		require.Equal(t, "Fedora Project", bash.MaintainerName)
+		require.Equal(t, "https://www.gnu.org/software/bash", bash.HomePage)
+		require.Equal(t, purl.TypeRPM, bash.Type)
+
+		require.Equal(t, "1:3.0.9-2.fc38", byName["openssl-libs"].Version)
+	}
+
+	_, err := ct.parseRpmDB("testdata/apkdb")
+	require.Error(t, err)
+}
+
+func TestParseRpmHeader(t *testing.T) {
+	// Headers too short for their index or data are rejected
+	_, err := parseRpmHeader([]byte{0, 0})
+	require.Error(t, err)
+	_, err = parseRpmHeader([]byte{0, 0, 0, 1, 0, 0, 0, 4, 0, 0, 0, 0})
+	require.Error(t, err)
+
+	entry, err := parseRpmHeader([]byte{0, 0, 0, 0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	require.Empty(t, entry.Package)
+	require.Empty(t, entry.Version)
+}
+
+func TestReadRpmPackages(t *testing.T) {
+	sqliteDB, err := os.ReadFile("testdata/rpmdb.sqlite")
+	require.NoError(t, err)
+	base := writeTestLayer(t, map[string]string{
+		"etc/os-release": "NAME=\"Red Hat Enterprise Linux\"\nVERSION=\"9.2 (Plow)\"\nID=\"rhel\"\n",
+	})
+	// Newer releases keep the database in /usr/lib/sysimage/rpm
+	install := writeTestLayer(t, map[string]string{
+		"usr/lib/sysimage/rpm/rpmdb.sqlite": string(sqliteDB),
+	})
+
+	ct := ContainerScanner{}
+	layer, packages, err := ct.ReadOSPackages([]string{base, install})
+	require.NoError(t, err)
+	require.Equal(t, 1, layer)
+	require.Len(t, *packages, 102)
+	for _, p := range *packages {
+		require.Equal(t, 1, p.Layer)
+		if p.Package == "openssl-libs" {
+			require.Equal(t, "pkg:rpm/redhat/openssl-libs@3.0.9-2.fc38?arch=x86_64&epoch=1", p.PackageURL())
+		}
+		if p.Package == "bash" {
+			require.Equal(t, "pkg:rpm/redhat/bash@5.2.15-3.fc38?arch=x86_64", p.PackageURL())
+		}
+	}
+
+	// Images without an rpm database have no packages
+	layer, packages, err = ct.ReadRpmPackages([]string{base})
+	require.NoError(t, err)
+	require.Equal(t, 0, layer)
+	require.Nil(t, packages)
+}
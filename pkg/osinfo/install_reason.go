@@ -0,0 +1,186 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osinfo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Reasons why a package was installed, recorded in PackageDBEntry
+const (
+	InstallReasonExplicit   = "explicit"   // Requested by the user
+	InstallReasonDependency = "dependency" // Pulled in as a dependency of another package
+)
+
+const (
+	// aptExtendedStatesPath lists the packages apt installed automatically
+	aptExtendedStatesPath = "var/lib/apt/extended_states"
+
+	// apkWorldPath lists the packages explicitly installed with apk
+	apkWorldPath = "etc/apk/world"
+)
+
+// readLastLayerFile returns the contents of the file at path in the last
+// layer that has it. found is false when no layer has the file.
+func readLastLayerFile(layers []string, path string) (data []byte, found bool, err error) {
+	loss := LayerScanner{}
+	for _, lp := range layers {
+		tmp, err := os.CreateTemp("", "osinfo-")
+		if err != nil {
+			return nil, false, fmt.Errorf("opening temporary file: %w", err)
+		}
+		tmpPath := tmp.Name()
+		tmp.Close()
+		if err := loss.extractFileFromTar(lp, path, tmpPath); err != nil {
+			os.Remove(tmpPath)
+			if _, ok := err.(ErrFileNotFoundInTar); ok {
+				continue
+			}
+			return nil, false, fmt.Errorf("extracting %s: %w", path, err)
+		}
+		data, err = os.ReadFile(tmpPath)
+		os.Remove(tmpPath)
+		if err != nil {
+			return nil, false, fmt.Errorf("reading %s: %w", path, err)
+		}
+		found = true
+	}
+	return data, found, nil
+}
+
+// parseAptExtendedStates returns the packages marked as automatically
+// installed in the apt extended_states file, keyed by name/arch
+func parseAptExtendedStates(data []byte) map[string]struct{} {
+	auto := map[string]struct{}{}
+	var name, arch string
+	var isAuto bool
+	flush := func() {
+		if name != "" && isAuto {
+			auto[name+"/"+arch] = struct{}{}
+		}
+		name, arch, isAuto = "", "", false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch parts[0] {
+		case "Package":
+			name = value
+		case "Architecture":
+			arch = value
+		case "Auto-Installed":
+			isAuto = value == "1"
+		}
+	}
+	flush()
+	return auto
+}
+
+// parseApkWorld returns the names of the packages listed in the apk world
+// file. Version constraints and repository tags are dropped from the
+// names, packages prefixed with ! are conflicts and are skipped.
+func parseApkWorld(data []byte) map[string]struct{} {
+	world := map[string]struct{}{}
+	for _, dep := range strings.Fields(string(data)) {
+		if strings.HasPrefix(dep, "!") {
+			continue
+		}
+		if i := strings.IndexAny(dep, "=<>~@"); i != -1 {
+			dep = dep[:i]
+		}
+		if dep != "" {
+			world[dep] = struct{}{}
+		}
+	}
+	return world
+}
+
+// setDebianInstallReasons records the install reason of the packages read
+// from the dpkg database using the apt extended_states file in the layers.
+// Packages apt did not mark as automatically installed were installed
+// explicitly. The reason is left unknown when no layer has the file, as
+// in images built without apt.
+func setDebianInstallReasons(layers []string, packages *[]PackageDBEntry) error {
+	if packages == nil {
+		return nil
+	}
+	data, found, err := readLastLayerFile(layers, aptExtendedStatesPath)
+	if err != nil {
+		return fmt.Errorf("reading apt extended states: %w", err)
+	}
+	if found {
+		applyDebianInstallReasons(data, packages)
+	}
+	return nil
+}
+
+// applyDebianInstallReasons sets the install reason of the packages from
+// the contents of the apt extended_states file
+func applyDebianInstallReasons(data []byte, packages *[]PackageDBEntry) {
+	auto := parseAptExtendedStates(data)
+	for i := range *packages {
+		e := &(*packages)[i]
+		e.InstallReason = InstallReasonExplicit
+		if _, ok := auto[e.Package+"/"+e.Architecture]; ok {
+			e.InstallReason = InstallReasonDependency
+		}
+	}
+}
+
+// setApkInstallReasons records the install reason of the packages read
+// from the apk database: those listed in the world file were installed
+// explicitly, the rest as dependencies. The reason is left unknown when
+// no layer has the world file.
+func setApkInstallReasons(layers []string, packages *[]PackageDBEntry) error {
+	if packages == nil {
+		return nil
+	}
+	data, found, err := readLastLayerFile(layers, apkWorldPath)
+	if err != nil {
+		return fmt.Errorf("reading apk world: %w", err)
+	}
+	if found {
+		applyApkInstallReasons(data, packages)
+	}
+	return nil
+}
+
+// applyApkInstallReasons sets the install reason of the packages from the
+// contents of the apk world file
+func applyApkInstallReasons(data []byte, packages *[]PackageDBEntry) {
+	world := parseApkWorld(data)
+	for i := range *packages {
+		e := &(*packages)[i]
+		e.InstallReason = InstallReasonDependency
+		if _, ok := world[e.Package]; ok {
+			e.InstallReason = InstallReasonExplicit
+		}
+	}
+}
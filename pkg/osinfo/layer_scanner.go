@@ -54,43 +54,50 @@ func (loss *LayerScanner) OSType(layerPath string) (ostype string, err error) {
 		return "", fmt.Errorf("reading os release: %w", err)
 	}
 
+	return osTypeFromRelease(osrelease), nil
+}
+
+// osTypeFromRelease returns the OS described by the contents of an
+// os-release file, or an empty string if it is not known
+func osTypeFromRelease(osrelease string) string {
 	if osrelease == "" {
-		return "", nil
+		return ""
 	}
 
 	if strings.Contains(osrelease, "NAME=\"Debian GNU") {
 		logrus.Infof("Scan of container layers found %s base image", OSDebian)
-		return OSDebian, nil
+		return OSDebian
 	}
 
 	if strings.Contains(osrelease, "NAME=\"Ubuntu\"") {
-		return OSUbuntu, nil
+		return OSUbuntu
 	}
 
 	if strings.Contains(osrelease, "NAME=\"Fedora Linux\"") {
-		return OSFedora, nil
+		return OSFedora
 	}
 
-	if strings.Contains(osrelease, "NAME=\"CentOS Linux\"") {
-		return OSCentos, nil
+	if strings.Contains(osrelease, "NAME=\"CentOS Linux\"") ||
+		strings.Contains(osrelease, "NAME=\"CentOS Stream\"") {
+		return OSCentos
 	}
 
 	if strings.Contains(osrelease, "NAME=\"Red Hat Enterprise Linux\"") {
-		return OSRHEL, nil
+		return OSRHEL
 	}
 
 	if strings.Contains(osrelease, "NAME=\"Alpine Linux\"") {
-		return OSAlpine, nil
+		return OSAlpine
 	}
 
 	if strings.Contains(osrelease, "NAME=\"Wolfi\"") {
-		return OSWolfi, nil
+		return OSWolfi
 	}
 
 	if strings.Contains(osrelease, "PRETTY_NAME=\"Distroless") {
-		return OSDistroless, nil
+		return OSDistroless
 	}
-	return "", nil
+	return ""
 }
 
 // CanHandle looks at an image tarball and checks if it
@@ -123,42 +130,47 @@ func (e ErrFileNotFoundInTar) Error() string {
 	return "file not found in tarball"
 }
 
-// extractFileFromTar extracts filePath from tarPath and stores it in destPath
-func (loss *LayerScanner) extractFileFromTar(tarPath, filePath, destPath string) error {
-	// Open the tar file
+// openLayer opens a layer tarball, compressed or not, for reading. The
+// returned file has to be closed by the caller.
+func (loss *LayerScanner) openLayer(tarPath string) (*tar.Reader, *os.File, error) {
 	f, err := os.Open(tarPath)
 	if err != nil {
-		return fmt.Errorf("opening tarball: %w", err)
+		return nil, nil, fmt.Errorf("opening tarball: %w", err)
 	}
-	defer f.Close()
 
 	// Read the first bytes to determine if the file is compressed
 	var sample [3]byte
-	var gzipped bool
 	if _, err := io.ReadFull(f, sample[:]); err != nil {
-		return fmt.Errorf("sampling bytes from file header: %w", err)
+		f.Close()
+		return nil, nil, fmt.Errorf("sampling bytes from file header: %w", err)
 	}
 	if _, err := f.Seek(0, 0); err != nil {
-		return fmt.Errorf("rewinding read pointer: %w", err)
+		f.Close()
+		return nil, nil, fmt.Errorf("rewinding read pointer: %w", err)
 	}
 
 	// From: https://github.com/golang/go/blob/1fadc392ccaefd76ef7be5b685fb3889dbee27c6/src/compress/gzip/gunzip.go#L185
 	if sample[0] == 0x1f && sample[1] == 0x8b && sample[2] == 0x08 {
-		gzipped = true
-	}
-
-	const dotSl = "./"
-	filePath = strings.TrimPrefix(filePath, dotSl)
-
-	var tr *tar.Reader
-	tr = tar.NewReader(f)
-	if gzipped {
 		gzf, err := gzip.NewReader(f)
 		if err != nil {
-			return fmt.Errorf("creating gzip reader: %w", err)
+			f.Close()
+			return nil, nil, fmt.Errorf("creating gzip reader: %w", err)
 		}
-		tr = tar.NewReader(gzf)
+		return tar.NewReader(gzf), f, nil
 	}
+	return tar.NewReader(f), f, nil
+}
+
+// extractFileFromTar extracts filePath from tarPath and stores it in destPath
+func (loss *LayerScanner) extractFileFromTar(tarPath, filePath, destPath string) error {
+	tr, f, err := loss.openLayer(tarPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	const dotSl = "./"
+	filePath = strings.TrimPrefix(filePath, dotSl)
 
 	// Search for the os-file in the tar contents
 	for {
@@ -0,0 +1,138 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osinfo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	purl "github.com/package-url/packageurl-go"
+	"github.com/sirupsen/logrus"
+)
+
+// isPythonMetadata returns true if filePath is the metadata file of a
+// python package installed in a site-packages or dist-packages directory
+func isPythonMetadata(filePath string) bool {
+	dir, file := path.Split(filePath)
+	parent := path.Base(path.Dir(path.Dir(dir)))
+	if parent != "site-packages" && parent != "dist-packages" {
+		return false
+	}
+	pkgDir := path.Base(dir)
+	return (strings.HasSuffix(pkgDir, ".dist-info") && file == "METADATA") ||
+		(strings.HasSuffix(pkgDir, ".egg-info") && file == "PKG-INFO")
+}
+
+// ReadPythonPackages scans the layers looking for python packages installed
+// in site-packages (or dist-packages) directories. When a later layer
+// rewrites the metadata of a package, the package is attributed to it.
+func (ct *ContainerScanner) ReadPythonPackages(layers []string) (*[]PackageDBEntry, error) {
+	loss := LayerScanner{}
+	found := map[string]PackageDBEntry{}
+	for i, lp := range layers {
+		tr, f, err := loss.openLayer(lp)
+		if err != nil {
+			return nil, fmt.Errorf("opening layer %d: %w", i, err)
+		}
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("reading layer %d: %w", i, err)
+			}
+			name := strings.TrimPrefix(hdr.Name, "./")
+			if !hdr.FileInfo().Mode().IsRegular() || !isPythonMetadata(name) {
+				continue
+			}
+			entry, err := parsePythonMetadata(tr)
+			if err != nil {
+				logrus.Warnf("Skipping python metadata in %s: %v", name, err)
+				continue
+			}
+			if entry.Package == "" {
+				continue
+			}
+			entry.Layer = i
+			found[name] = entry
+		}
+		f.Close()
+	}
+	return sortedPythonPackages(found), nil
+}
+
+// sortedPythonPackages returns the python packages found, keyed by the
+// path of their metadata, in path order
+func sortedPythonPackages(found map[string]PackageDBEntry) *[]PackageDBEntry {
+	paths := []string{}
+	for p := range found {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	packages := []PackageDBEntry{}
+	for _, p := range paths {
+		packages = append(packages, found[p])
+	}
+	logrus.Infof("Found %d python packages", len(packages))
+	return &packages
+}
+
+// maxPythonMetadataLine is the length of the longest line read from python
+// package metadata. Some packages put long texts in a single header.
+const maxPythonMetadataLine = 1 << 20
+
+// parsePythonMetadata reads the headers of a python package metadata
+// file (METADATA or PKG-INFO)
+func parsePythonMetadata(r io.Reader) (PackageDBEntry, error) {
+	entry := PackageDBEntry{Type: purl.TypePyPi}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxPythonMetadataLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// The headers end at the first blank line, the description follows
+		if line == "" {
+			break
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch parts[0] {
+		case "Name":
+			entry.Package = value
+		case "Version":
+			entry.Version = value
+		case "Home-page":
+			entry.HomePage = value
+		case "Author":
+			entry.MaintainerName = value
+		case "Author-email":
+			entry.MaintainerEmail = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return entry, fmt.Errorf("scanning metadata: %w", err)
+	}
+	return entry, nil
+}
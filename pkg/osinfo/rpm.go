@@ -0,0 +1,228 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	purl "github.com/package-url/packageurl-go"
+	"github.com/sirupsen/logrus"
+)
+
+// rpmDBPaths are the locations of the rpm database, newer releases keep it
+// in sqlite and older ones in a BerkeleyDB hash file. Fedora and RHEL 9
+// moved it to /usr/lib/sysimage/rpm, leaving /var/lib/rpm as a symlink.
+var rpmDBPaths = []string{
+	"var/lib/rpm/rpmdb.sqlite",
+	"usr/lib/sysimage/rpm/rpmdb.sqlite",
+	"var/lib/rpm/Packages",
+	"usr/lib/sysimage/rpm/Packages",
+}
+
+// Tags of the rpm header read to describe the packages
+const (
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+	rpmTagVendor  = 1011
+	rpmTagLicense = 1014
+	rpmTagURL     = 1020
+	rpmTagArch    = 1022
+)
+
+// Types of the entries of the rpm header
+const (
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeStringArray = 8
+	rpmTypeI18NString  = 9
+)
+
+// rpmGPGKeyPackage is the name of the pseudo packages recording the
+// signing keys imported in the database
+const rpmGPGKeyPackage = "gpg-pubkey"
+
+// ReadRpmPackages reads the last known changed copy of the rpm database,
+// in sqlite or BerkeleyDB format. Each copy is parsed to record in which
+// layer every package was installed.
+func (ct *ContainerScanner) ReadRpmPackages(layers []string) (layer int, pk *[]PackageDBEntry, err error) {
+	for i, lp := range layers {
+		tmpDBPath, dbPath, err := extractFirstFile(lp, rpmDBPaths)
+		if err != nil {
+			return 0, pk, fmt.Errorf("extracting rpm database: %w", err)
+		}
+		if dbPath == "" {
+			continue
+		}
+		logrus.Debugf("Layer %d has a newer version of rpm database", i)
+		layerPackages, err := ct.parseRpmDB(tmpDBPath)
+		os.Remove(tmpDBPath)
+		if err != nil {
+			return 0, nil, fmt.Errorf("parsing rpm database from layer %d: %w", i, err)
+		}
+		attributeLayer(pk, layerPackages, i)
+		pk = layerPackages
+		layer = i
+	}
+
+	if pk == nil {
+		logrus.Info("rpm database data is empty")
+	}
+	return layer, pk, nil
+}
+
+// parseRpmDB reads the package headers from an rpm database and returns
+// the packages described in them
+func (ct *ContainerScanner) parseRpmDB(dbPath string) (*[]PackageDBEntry, error) {
+	data, err := os.ReadFile(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading rpm database: %w", err)
+	}
+	return parseRpmData(data)
+}
+
+// parseRpmData reads the packages of an rpm database, in sqlite or
+// BerkeleyDB format, from its contents
+func parseRpmData(data []byte) (*[]PackageDBEntry, error) {
+	var blobs [][]byte
+	var err error
+	if bytes.HasPrefix(data, []byte(sqliteMagic)) {
+		blobs, err = readSQLiteRpmHeaders(data)
+	} else {
+		blobs, err = readBerkeleyDBRpmHeaders(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	packages := []PackageDBEntry{}
+	for _, blob := range blobs {
+		entry, err := parseRpmHeader(blob)
+		if err != nil {
+			return nil, fmt.Errorf("parsing rpm package header: %w", err)
+		}
+		if entry.Package == "" || entry.Package == rpmGPGKeyPackage {
+			continue
+		}
+		packages = append(packages, *entry)
+	}
+	logrus.Infof("Found %d packages in rpm database", len(packages))
+	return &packages, nil
+}
+
+// parseRpmHeader reads the package data from an rpm header blob as stored
+// in the database: the number of index entries and the size of the data
+// store followed by the index entries and the data they point to.
+func parseRpmHeader(blob []byte) (*PackageDBEntry, error) {
+	if len(blob) < 8 {
+		return nil, errors.New("header is too short")
+	}
+	numEntries := binary.BigEndian.Uint32(blob[0:4])
+	dataLen := binary.BigEndian.Uint32(blob[4:8])
+	indexEnd := 8 + uint64(numEntries)*16
+	if indexEnd+uint64(dataLen) > uint64(len(blob)) {
+		return nil, fmt.Errorf("header with %d entries and %d bytes of data is truncated", numEntries, dataLen)
+	}
+	store := blob[indexEnd : indexEnd+uint64(dataLen)]
+
+	entry := &PackageDBEntry{Type: purl.TypeRPM}
+	var version, release, epoch string
+	for i := uint64(0); i < uint64(numEntries); i++ {
+		e := blob[8+i*16 : 8+(i+1)*16]
+		tag := binary.BigEndian.Uint32(e[0:4])
+		typ := binary.BigEndian.Uint32(e[4:8])
+		offset := binary.BigEndian.Uint32(e[8:12])
+		if uint64(offset) >= uint64(len(store)) {
+			continue
+		}
+		switch tag {
+		case rpmTagEpoch:
+			if typ == rpmTypeInt32 && uint64(offset)+4 <= uint64(len(store)) {
+				epoch = strconv.FormatUint(uint64(binary.BigEndian.Uint32(store[offset:])), 10)
+			}
+			continue
+		case rpmTagName, rpmTagVersion, rpmTagRelease, rpmTagVendor, rpmTagLicense, rpmTagURL, rpmTagArch:
+		default:
+			continue
+		}
+		if typ != rpmTypeString && typ != rpmTypeStringArray && typ != rpmTypeI18NString {
+			continue
+		}
+		value := store[offset:]
+		if end := bytes.IndexByte(value, 0); end != -1 {
+			value = value[:end]
+		}
+		switch tag {
+		case rpmTagName:
+			entry.Package = string(value)
+		case rpmTagVersion:
+			version = string(value)
+		case rpmTagRelease:
+			release = string(value)
+		case rpmTagVendor:
+			entry.MaintainerName = string(value)
+		case rpmTagLicense:
+			entry.License = string(value)
+		case rpmTagURL:
+			entry.HomePage = string(value)
+		case rpmTagArch:
+			entry.Architecture = string(value)
+		}
+	}
+	entry.Version = rpmVersion(epoch, version, release)
+	return entry, nil
+}
+
+// rpmVersion builds the full version of a package as rpm prints it:
+// [epoch:]version-release
+func rpmVersion(epoch, version, release string) string {
+	if version == "" {
+		return ""
+	}
+	if release != "" {
+		version += "-" + release
+	}
+	if epoch != "" {
+		version = epoch + ":" + version
+	}
+	return version
+}
+
+// rpmNamespace returns the purl namespace of the rpm packages of an OS,
+// which is the distribution vendor
+func rpmNamespace(osKind string) string {
+	if osKind == OSRHEL {
+		return "redhat"
+	}
+	return osKind
+}
+
+// splitRpmEpoch splits the epoch from an rpm version, the epoch is
+// empty when the version has none
+func splitRpmEpoch(version string) (epoch, rest string) {
+	if i := strings.Index(version, ":"); i != -1 {
+		return version[:i], version[i+1:]
+	}
+	return "", version
+}
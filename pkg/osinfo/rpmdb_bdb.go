@@ -0,0 +1,177 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Layout of the BerkeleyDB hash databases used by the legacy rpm Packages
+// file. Only the parts needed to read the stored values are described.
+const (
+	bdbHashMagic      = 0x061561
+	bdbPageHeaderSize = 26
+
+	bdbPageHashUnsorted = 2
+	bdbPageOverflow     = 7
+	bdbPageHashMeta     = 8
+	bdbPageHash         = 13
+
+	bdbItemOffPage = 3
+)
+
+// bdbPageHeader is the header common to the pages of the database
+type bdbPageHeader struct {
+	NextPage   uint32 // Next page of an overflow chain
+	NumEntries uint16 // Number of items in a hash page
+	Offset     uint16 // Free area offset in hash pages, data length in overflow pages
+	Type       byte
+}
+
+// readBerkeleyDBRpmHeaders returns the values stored in a BerkeleyDB hash
+// database, which in the rpm Packages file are the package headers. The
+// pages are read in order instead of walking the hash buckets.
+func readBerkeleyDBRpmHeaders(data []byte) ([][]byte, error) {
+	if len(data) < 72 {
+		return nil, errors.New("database is too short for a BerkeleyDB file")
+	}
+	// The database is written in the byte order of the host that created it
+	var order binary.ByteOrder = binary.LittleEndian
+	switch {
+	case binary.LittleEndian.Uint32(data[12:16]) == bdbHashMagic:
+	case binary.BigEndian.Uint32(data[12:16]) == bdbHashMagic:
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("database is not a BerkeleyDB hash file")
+	}
+	if data[25] != bdbPageHashMeta {
+		return nil, fmt.Errorf("unexpected BerkeleyDB metadata page type %d", data[25])
+	}
+	if data[24] != 0 {
+		return nil, errors.New("encrypted BerkeleyDB files are not supported")
+	}
+	pageSize := order.Uint32(data[20:24])
+	if pageSize < 512 || pageSize > 65536 {
+		return nil, fmt.Errorf("invalid BerkeleyDB page size %d", pageSize)
+	}
+	lastPage := order.Uint32(data[32:36])
+
+	db := bdbFile{data: data, order: order, pageSize: pageSize}
+	values := [][]byte{}
+	for pgno := uint32(1); pgno <= lastPage; pgno++ {
+		page, err := db.page(pgno)
+		if err != nil {
+			return nil, err
+		}
+		hdr := db.pageHeader(page)
+		if hdr.Type != bdbPageHash && hdr.Type != bdbPageHashUnsorted {
+			continue
+		}
+		// Items come in key/value pairs, their offsets follow the header
+		if bdbPageHeaderSize+2*int(hdr.NumEntries) > len(page) {
+			return nil, fmt.Errorf("hash page %d has too many entries", pgno)
+		}
+		for i := 1; i < int(hdr.NumEntries); i += 2 {
+			value, err := db.hashValue(page, i)
+			if err != nil {
+				return nil, fmt.Errorf("reading value from hash page %d: %w", pgno, err)
+			}
+			if value != nil {
+				values = append(values, value)
+			}
+		}
+	}
+	return values, nil
+}
+
+// bdbFile is a BerkeleyDB database loaded in memory
+type bdbFile struct {
+	data     []byte
+	order    binary.ByteOrder
+	pageSize uint32
+}
+
+// page returns the contents of a page
+func (db *bdbFile) page(pgno uint32) ([]byte, error) {
+	start := uint64(pgno) * uint64(db.pageSize)
+	if start+uint64(db.pageSize) > uint64(len(db.data)) {
+		return nil, fmt.Errorf("page %d is past the end of the database", pgno)
+	}
+	return db.data[start : start+uint64(db.pageSize)], nil
+}
+
+func (db *bdbFile) pageHeader(page []byte) bdbPageHeader {
+	return bdbPageHeader{
+		NextPage:   db.order.Uint32(page[16:20]),
+		NumEntries: db.order.Uint16(page[20:22]),
+		Offset:     db.order.Uint16(page[22:24]),
+		Type:       page[25],
+	}
+}
+
+// hashValue returns the value of the item i of a hash page when it is
+// stored in a chain of overflow pages, as package headers are. The values
+// stored in the page are small records, as the instance counter rpm keeps
+// under key 0, and return nil.
+func (db *bdbFile) hashValue(page []byte, i int) ([]byte, error) {
+	offset := int(db.order.Uint16(page[bdbPageHeaderSize+2*i:]))
+	if offset >= len(page) {
+		return nil, fmt.Errorf("item %d is out of the page", i)
+	}
+	item := page[offset:]
+	if item[0] != bdbItemOffPage {
+		return nil, nil
+	}
+	if len(item) < 12 {
+		return nil, fmt.Errorf("off page item %d is truncated", i)
+	}
+	return db.overflowValue(db.order.Uint32(item[4:8]), db.order.Uint32(item[8:12]))
+}
+
+// overflowValue reads a value of length size from the overflow pages
+// starting at pgno
+func (db *bdbFile) overflowValue(pgno, size uint32) ([]byte, error) {
+	if uint64(size) > uint64(len(db.data)) {
+		return nil, fmt.Errorf("overflow value of %d bytes is larger than the database", size)
+	}
+	value := make([]byte, 0, size)
+	numPages := uint32(len(db.data)) / db.pageSize
+	for n := uint32(0); pgno != 0 && uint32(len(value)) < size; n++ {
+		if n > numPages {
+			return nil, errors.New("overflow pages form a loop")
+		}
+		page, err := db.page(pgno)
+		if err != nil {
+			return nil, err
+		}
+		hdr := db.pageHeader(page)
+		if hdr.Type != bdbPageOverflow {
+			return nil, fmt.Errorf("page %d is not an overflow page", pgno)
+		}
+		if bdbPageHeaderSize+int(hdr.Offset) > len(page) {
+			return nil, fmt.Errorf("overflow page %d has too much data", pgno)
+		}
+		value = append(value, page[bdbPageHeaderSize:bdbPageHeaderSize+int(hdr.Offset)]...)
+		pgno = hdr.NextPage
+	}
+	if uint32(len(value)) < size {
+		return nil, fmt.Errorf("overflow value is truncated, read %d of %d bytes", len(value), size)
+	}
+	return value[:size], nil
+}
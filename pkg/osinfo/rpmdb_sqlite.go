@@ -0,0 +1,330 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Layout of the sqlite database files, only the parts needed to read the
+// rows of a table are described (https://www.sqlite.org/fileformat.html)
+const (
+	sqliteMagic      = "SQLite format 3\x00"
+	sqliteHeaderSize = 100
+
+	sqlitePageInteriorTable = 0x05
+	sqlitePageLeafTable     = 0x0d
+
+	// sqliteMaxDepth caps the depth of the table b-trees
+	sqliteMaxDepth = 32
+)
+
+// rpmSQLiteTable is the table holding the package headers in rpmdb.sqlite
+const rpmSQLiteTable = "Packages"
+
+// readSQLiteRpmHeaders returns the package headers stored in the blob
+// column of the Packages table of an rpmdb.sqlite database
+func readSQLiteRpmHeaders(data []byte) ([][]byte, error) {
+	db, err := newSQLiteFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The schema table, rooted at page 1, has the root page of the tables
+	rootPage := uint32(0)
+	if err := db.readTable(1, func(row []interface{}) error {
+		if len(row) < 4 {
+			return nil
+		}
+		if kind, ok := row[0].(string); !ok || kind != "table" {
+			return nil
+		}
+		if name, ok := row[1].(string); !ok || name != rpmSQLiteTable {
+			return nil
+		}
+		if page, ok := row[3].(int64); ok {
+			rootPage = uint32(page)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("reading sqlite schema: %w", err)
+	}
+	if rootPage == 0 {
+		return nil, fmt.Errorf("sqlite database has no %s table", rpmSQLiteTable)
+	}
+
+	headers := [][]byte{}
+	if err := db.readTable(rootPage, func(row []interface{}) error {
+		// The first column is the rowid alias, the header is the blob
+		if len(row) < 2 {
+			return errors.New("package row has no blob")
+		}
+		if blob, ok := row[1].([]byte); ok {
+			headers = append(headers, blob)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("reading %s table: %w", rpmSQLiteTable, err)
+	}
+	return headers, nil
+}
+
+// sqliteFile is a sqlite database loaded in memory
+type sqliteFile struct {
+	data       []byte
+	pageSize   int
+	usableSize int // Page size minus the space reserved at the end of each page
+}
+
+func newSQLiteFile(data []byte) (*sqliteFile, error) {
+	if len(data) < sqliteHeaderSize || string(data[:len(sqliteMagic)]) != sqliteMagic {
+		return nil, errors.New("database is not a sqlite file")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid sqlite page size %d", pageSize)
+	}
+	return &sqliteFile{
+		data:       data,
+		pageSize:   pageSize,
+		usableSize: pageSize - int(data[20]),
+	}, nil
+}
+
+// page returns the contents of a page, pages are numbered from 1
+func (db *sqliteFile) page(pgno uint32) ([]byte, error) {
+	start := (uint64(pgno) - 1) * uint64(db.pageSize)
+	if pgno == 0 || start+uint64(db.pageSize) > uint64(len(db.data)) {
+		return nil, fmt.Errorf("page %d is out of the database", pgno)
+	}
+	return db.data[start : start+uint64(db.pageSize)], nil
+}
+
+// readTable walks the b-tree of a table calling fn with the columns of
+// each row. Integers are returned as int64, text as string and blobs as
+// []byte. Other values are returned as nil.
+func (db *sqliteFile) readTable(rootPage uint32, fn func(row []interface{}) error) error {
+	return db.walkTable(rootPage, 0, fn)
+}
+
+func (db *sqliteFile) walkTable(pgno uint32, depth int, fn func(row []interface{}) error) error {
+	if depth > sqliteMaxDepth {
+		return errors.New("table b-tree is too deep")
+	}
+	page, err := db.page(pgno)
+	if err != nil {
+		return err
+	}
+	// The first page starts after the database header
+	hdrStart := 0
+	if pgno == 1 {
+		hdrStart = sqliteHeaderSize
+	}
+	if hdrStart+12 > len(page) {
+		return fmt.Errorf("page %d is truncated", pgno)
+	}
+	pageType := page[hdrStart]
+	numCells := int(binary.BigEndian.Uint16(page[hdrStart+3:]))
+	cellsStart := hdrStart + 8
+	if pageType == sqlitePageInteriorTable {
+		cellsStart = hdrStart + 12
+	}
+	if cellsStart+2*numCells > len(page) {
+		return fmt.Errorf("page %d has too many cells", pgno)
+	}
+
+	for i := 0; i < numCells; i++ {
+		offset := int(binary.BigEndian.Uint16(page[cellsStart+2*i:]))
+		if offset >= len(page) {
+			return fmt.Errorf("cell %d of page %d is out of the page", i, pgno)
+		}
+		cell := page[offset:]
+		switch pageType {
+		case sqlitePageInteriorTable:
+			if len(cell) < 4 {
+				return fmt.Errorf("cell %d of page %d is truncated", i, pgno)
+			}
+			if err := db.walkTable(binary.BigEndian.Uint32(cell), depth+1, fn); err != nil {
+				return err
+			}
+		case sqlitePageLeafTable:
+			payload, err := db.leafPayload(cell)
+			if err != nil {
+				return fmt.Errorf("reading cell %d of page %d: %w", i, pgno, err)
+			}
+			row, err := parseSQLiteRecord(payload)
+			if err != nil {
+				return fmt.Errorf("parsing cell %d of page %d: %w", i, pgno, err)
+			}
+			if err := fn(row); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("page %d is not a table page (type %d)", pgno, pageType)
+		}
+	}
+
+	if pageType == sqlitePageInteriorTable {
+		return db.walkTable(binary.BigEndian.Uint32(page[hdrStart+8:]), depth+1, fn)
+	}
+	return nil
+}
+
+// leafPayload returns the payload of a cell of a table leaf page, reading
+// the overflow pages of the payloads that do not fit in the page
+func (db *sqliteFile) leafPayload(cell []byte) ([]byte, error) {
+	size, n := sqliteVarint(cell)
+	if n == 0 {
+		return nil, errors.New("invalid payload size")
+	}
+	_, m := sqliteVarint(cell[n:]) // rowid
+	if m == 0 {
+		return nil, errors.New("invalid rowid")
+	}
+	cell = cell[n+m:]
+	if size > uint64(len(db.data)) {
+		return nil, fmt.Errorf("payload of %d bytes is larger than the database", size)
+	}
+
+	// The amount of payload stored in the page, as defined by the format
+	u := uint64(db.usableSize)
+	maxLocal := u - 35
+	if size <= maxLocal {
+		if uint64(len(cell)) < size {
+			return nil, errors.New("payload is out of the page")
+		}
+		return cell[:size], nil
+	}
+	minLocal := (u-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(u-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	if uint64(len(cell)) < local+4 {
+		return nil, errors.New("payload is out of the page")
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local:])
+	numPages := len(db.data) / db.pageSize
+	for n := 0; uint64(len(payload)) < size; n++ {
+		if next == 0 || n > numPages {
+			return nil, errors.New("overflow pages are truncated")
+		}
+		page, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		chunk := page[4:db.usableSize]
+		if rest := size - uint64(len(payload)); uint64(len(chunk)) > rest {
+			chunk = chunk[:rest]
+		}
+		payload = append(payload, chunk...)
+		next = binary.BigEndian.Uint32(page)
+	}
+	return payload, nil
+}
+
+// parseSQLiteRecord decodes the columns of a record
+func parseSQLiteRecord(record []byte) ([]interface{}, error) {
+	hdrSize, n := sqliteVarint(record)
+	if n == 0 || hdrSize > uint64(len(record)) {
+		return nil, errors.New("invalid record header")
+	}
+	types := []uint64{}
+	for pos := uint64(n); pos < hdrSize; {
+		t, m := sqliteVarint(record[pos:hdrSize])
+		if m == 0 {
+			return nil, errors.New("invalid record column type")
+		}
+		types = append(types, t)
+		pos += uint64(m)
+	}
+
+	row := []interface{}{}
+	body := record[hdrSize:]
+	for _, t := range types {
+		var size uint64
+		switch {
+		case t <= 4:
+			size = t
+		case t == 5:
+			size = 6
+		case t == 6 || t == 7:
+			size = 8
+		case t >= 12:
+			size = (t - 12) / 2
+		}
+		if size > uint64(len(body)) {
+			return nil, errors.New("record is truncated")
+		}
+		value := body[:size]
+		body = body[size:]
+
+		switch {
+		case t >= 1 && t <= 6:
+			row = append(row, sqliteInt(value))
+		case t == 8:
+			row = append(row, int64(0))
+		case t == 9:
+			row = append(row, int64(1))
+		case t >= 12 && t%2 == 0:
+			row = append(row, value)
+		case t >= 13:
+			row = append(row, string(value))
+		default:
+			row = append(row, nil)
+		}
+	}
+	return row, nil
+}
+
+// sqliteInt decodes a big endian two's complement integer
+func sqliteInt(b []byte) int64 {
+	var v int64
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, c := range b {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+// sqliteVarint decodes a sqlite variable length integer, returning the
+// number of bytes read, which is zero when the buffer is too short
+func sqliteVarint(b []byte) (v uint64, n int) {
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, 9
+}
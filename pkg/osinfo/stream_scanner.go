@@ -0,0 +1,251 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osinfo
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	purl "github.com/package-url/packageurl-go"
+	"github.com/sirupsen/logrus"
+)
+
+// osReleasePaths are the locations of the os-release file, etc/os-release
+// is often a symlink to the one in usr/lib
+var osReleasePaths = []string{"etc/os-release", "usr/lib/os-release"}
+
+// maxStreamLinks is the number of symlinks followed to find a file
+const maxStreamLinks = 10
+
+// StreamScanner reads the packages installed in an image from the entries
+// of its layers as they are streamed, eg straight from a registry, giving
+// the same results as ContainerScanner.ReadOSPackages with the layers on
+// disk. Only the files describing the OS and the package databases are
+// kept, in memory. It is safe for concurrent use.
+type StreamScanner struct {
+	mu          sync.Mutex
+	layers      []map[string]streamedFile // Files kept from each layer by path
+	python      map[string]PackageDBEntry // Python packages by the path of their metadata
+	linkTargets map[string]struct{}       // Paths pointed to by the symlinks kept
+}
+
+// streamedFile is a file kept from a layer, or a symlink
+type streamedFile struct {
+	data []byte
+	link string // Target of the symlink, relative to the root of the layer
+}
+
+// NewStreamScanner returns a scanner with no layers read
+func NewStreamScanner() *StreamScanner {
+	return &StreamScanner{
+		python:      map[string]PackageDBEntry{},
+		linkTargets: map[string]struct{}{},
+	}
+}
+
+// Wants returns true if the scanner reads the entry called name in the
+// layers, callers streaming a layer can skip passing it the others
+func (s *StreamScanner) Wants(name string) bool {
+	name = streamEntryName(name)
+	if isPythonMetadata(name) || name == "var/lib/dpkg/status" ||
+		name == aptExtendedStatesPath || name == apkWorldPath {
+		return true
+	}
+	for _, paths := range [][]string{osReleasePaths, apkDBPaths, rpmDBPaths} {
+		for _, p := range paths {
+			if name == p {
+				return true
+			}
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.linkTargets[name]
+	return ok
+}
+
+// AddEntry reads the entry hdr of the layer with index layer, in the order
+// the layers are applied, whose data is read from r. The entries the
+// scanner does not want are skipped.
+func (s *StreamScanner) AddEntry(layer int, hdr *tar.Header, r io.Reader) error {
+	name := streamEntryName(hdr.Name)
+	if !s.Wants(name) {
+		return nil
+	}
+
+	var f streamedFile
+	switch hdr.Typeflag {
+	case tar.TypeSymlink:
+		target := hdr.Linkname
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(name), target)
+		}
+		f.link = streamEntryName(target)
+	case tar.TypeReg:
+		if isPythonMetadata(name) {
+			entry, err := parsePythonMetadata(r)
+			if err != nil {
+				logrus.Warnf("Skipping python metadata in %s: %v", name, err)
+				return nil
+			}
+			if entry.Package != "" {
+				entry.Layer = layer
+				s.mu.Lock()
+				s.python[name] = entry
+				s.mu.Unlock()
+			}
+			return nil
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("reading %s from layer %d: %w", name, layer, err)
+		}
+		f.data = data
+	default:
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.layers) <= layer {
+		s.layers = append(s.layers, map[string]streamedFile{})
+	}
+	s.layers[layer][name] = f
+	if f.link != "" {
+		s.linkTargets[f.link] = struct{}{}
+	}
+	return nil
+}
+
+// ReadOSPackages returns the packages found in the entries read, and the
+// last layer where the package database is defined, as
+// ContainerScanner.ReadOSPackages does. If the OS is not supported, the
+// packages are nil unless python packages were found.
+func (s *StreamScanner) ReadOSPackages() (layerNum int, packages *[]PackageDBEntry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	osKind := ""
+	for i := range s.layers {
+		if data, ok := s.layerFile(i, osReleasePaths[0]); ok {
+			osKind = osTypeFromRelease(string(data))
+		}
+		if osKind != "" {
+			break
+		}
+	}
+
+	purlType := ""
+	purlNamespace := osKind
+	switch osKind {
+	case OSDebian, OSUbuntu:
+		layerNum, packages, err = s.readDatabases([]string{"var/lib/dpkg/status"}, func(data []byte) (*[]PackageDBEntry, error) {
+			return parseDpkgData(bytes.NewReader(data))
+		})
+		if data, ok := s.lastLayerFile(aptExtendedStatesPath); ok && packages != nil {
+			applyDebianInstallReasons(data, packages)
+		}
+		purlType = purl.TypeDebian
+	case OSAlpine, OSWolfi:
+		layerNum, packages, err = s.readDatabases(apkDBPaths, func(data []byte) (*[]PackageDBEntry, error) {
+			return parseApkData(bytes.NewReader(data))
+		})
+		if data, ok := s.lastLayerFile(apkWorldPath); ok && packages != nil {
+			applyApkInstallReasons(data, packages)
+		}
+		purlType = "apk"
+	case OSFedora, OSCentos, OSRHEL:
+		layerNum, packages, err = s.readDatabases(rpmDBPaths, parseRpmData)
+		purlType = purl.TypeRPM
+		purlNamespace = rpmNamespace(osKind)
+	}
+	if err != nil {
+		return layerNum, packages, err
+	}
+	ct := ContainerScanner{}
+	ct.setPurlData(purlType, purlNamespace, packages)
+
+	if len(s.python) > 0 {
+		if packages == nil {
+			packages = &[]PackageDBEntry{}
+		}
+		*packages = append(*packages, *sortedPythonPackages(s.python)...)
+	}
+	return layerNum, packages, nil
+}
+
+// readDatabases parses the copy of the package database in each layer,
+// found at the first of the paths, recording in which layer each package
+// was installed. It returns the packages of the last copy.
+func (s *StreamScanner) readDatabases(
+	paths []string, parse func([]byte) (*[]PackageDBEntry, error),
+) (layer int, pk *[]PackageDBEntry, err error) {
+	for i := range s.layers {
+		for _, p := range paths {
+			data, ok := s.layerFile(i, p)
+			if !ok {
+				continue
+			}
+			layerPackages, err := parse(data)
+			if err != nil {
+				return 0, nil, fmt.Errorf("parsing %s from layer %d: %w", p, i, err)
+			}
+			attributeLayer(pk, layerPackages, i)
+			pk = layerPackages
+			layer = i
+			break
+		}
+	}
+	return layer, pk, nil
+}
+
+// lastLayerFile returns the contents of the file at name in the last
+// layer that has it
+func (s *StreamScanner) lastLayerFile(name string) (data []byte, found bool) {
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		if data, ok := s.layerFile(i, name); ok {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// layerFile returns the contents of the file at name in a layer, following
+// the symlinks to files in the same layer
+func (s *StreamScanner) layerFile(layer int, name string) ([]byte, bool) {
+	for i := 0; i < maxStreamLinks; i++ {
+		f, ok := s.layers[layer][name]
+		if !ok {
+			return nil, false
+		}
+		if f.link == "" {
+			return f.data, true
+		}
+		name = f.link
+	}
+	return nil, false
+}
+
+// streamEntryName returns the path of a layer entry relative to its root
+func streamEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
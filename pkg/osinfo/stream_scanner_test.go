@@ -0,0 +1,105 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osinfo
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// streamLayers passes the entries of the layers to a stream scanner, as
+// a caller streaming them from a registry does
+func streamLayers(t *testing.T, layers ...string) *StreamScanner {
+	s := NewStreamScanner()
+	loss := LayerScanner{}
+	for i, lp := range layers {
+		tr, f, err := loss.openLayer(lp)
+		require.NoError(t, err)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.NoError(t, s.AddEntry(i, hdr, tr))
+		}
+		f.Close()
+	}
+	return s
+}
+
+func TestStreamScanner(t *testing.T) {
+	pythonLayer := writeTestLayer(t, map[string]string{
+		"usr/local/lib/python3.11/site-packages/PyYAML-6.0.dist-info/METADATA": "Name: PyYAML\nVersion: 6.0\n",
+	})
+	aptLayer := writeTestLayer(t, map[string]string{
+		aptExtendedStatesPath: "Package: openssl\nArchitecture: amd64\nAuto-Installed: 1\n",
+	})
+	rpmData, err := os.ReadFile("testdata/rpmdb.sqlite")
+	require.NoError(t, err)
+	rpmLayer := writeTestLayer(t, map[string]string{
+		"etc/os-release":           "NAME=\"Fedora Linux\"\nID=fedora\n",
+		"var/lib/rpm/rpmdb.sqlite": string(rpmData),
+	})
+
+	// The results match those read from the layers on disk
+	ct := ContainerScanner{}
+	for _, layers := range [][]string{
+		{"testdata/link-with-no-dots.tar.gz", "testdata/dpkg-layer1.tar.gz", "testdata/dpkg-layer2.tar.gz"},
+		{"testdata/link-with-no-dots.tar.gz", "testdata/dpkg-layer1.tar.gz", aptLayer, pythonLayer},
+		{"testdata/link-with-dots.tar.gz", "testdata/dpkg-layer1.tar.gz"},
+		{rpmLayer, pythonLayer},
+		{pythonLayer},
+		{"testdata/dpkg-layer1.tar.gz"},
+	} {
+		expectedLayer, expected, err := ct.ReadOSPackages(layers)
+		require.NoError(t, err)
+		layer, packages, err := streamLayers(t, layers...).ReadOSPackages()
+		require.NoError(t, err)
+		require.Equal(t, expectedLayer, layer, layers)
+		require.Equal(t, expected, packages, layers)
+	}
+
+	// Nothing streamed, nothing found
+	layer, packages, err := NewStreamScanner().ReadOSPackages()
+	require.NoError(t, err)
+	require.Zero(t, layer)
+	require.Nil(t, packages)
+}
+
+func TestStreamScannerWants(t *testing.T) {
+	s := NewStreamScanner()
+	for _, name := range []string{
+		"etc/os-release", "./var/lib/dpkg/status", "/lib/apk/db/installed", "usr/lib/sysimage/rpm/Packages",
+		"usr/lib/python3/dist-packages/six-1.16.0.dist-info/METADATA",
+	} {
+		require.True(t, s.Wants(name), name)
+	}
+	for _, name := range []string{"usr/bin/python3", "etc/passwd", "etc/lsb-release"} {
+		require.False(t, s.Wants(name), name)
+	}
+
+	// The targets of the symlinks kept are read too
+	require.NoError(t, s.AddEntry(0, &tar.Header{
+		Name: "etc/os-release", Typeflag: tar.TypeSymlink, Linkname: "../usr/share/os-release",
+	}, nil))
+	require.True(t, s.Wants("usr/share/os-release"))
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serialize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	gojson "encoding/json"
+
+	"github.com/google/uuid"
+
+	cdx "sigs.k8s.io/bom/pkg/cyclonedx/v1.5"
+	"sigs.k8s.io/bom/pkg/query"
+	"sigs.k8s.io/bom/pkg/spdx"
+	"sigs.k8s.io/release-utils/version"
+)
+
+// cdxHashAlgorithms maps the SPDX checksum algorithms to their CycloneDX
+// names. Checksums in other algorithms are not written.
+var cdxHashAlgorithms = map[string]string{
+	"MD5":         "MD5",
+	"SHA1":        "SHA-1",
+	"SHA256":      "SHA-256",
+	"SHA384":      "SHA-384",
+	"SHA512":      "SHA-512",
+	"SHA3-256":    "SHA3-256",
+	"SHA3-384":    "SHA3-384",
+	"SHA3-512":    "SHA3-512",
+	"BLAKE2B-256": "BLAKE2b-256",
+	"BLAKE2B-384": "BLAKE2b-384",
+	"BLAKE2B-512": "BLAKE2b-512",
+	"BLAKE3":      "BLAKE3",
+}
+
+// cdxComponentTypes maps the SPDX package purposes to CycloneDX component
+// types. Packages without a known purpose are written as libraries.
+var cdxComponentTypes = map[string]string{
+	"APPLICATION":      cdx.ComponentTypeApplication,
+	"FRAMEWORK":        cdx.ComponentTypeFramework,
+	"LIBRARY":          cdx.ComponentTypeLibrary,
+	"CONTAINER":        cdx.ComponentTypeContainer,
+	"OPERATING-SYSTEM": cdx.ComponentTypeOperatingSystem,
+	"DEVICE":           cdx.ComponentTypeDevice,
+	"FIRMWARE":         cdx.ComponentTypeFirmware,
+	"FILE":             cdx.ComponentTypeFile,
+}
+
+// cdxDependencyTypes are the relationships written as dependencies of the
+// element to its peer, cdxReverseDependencyTypes the ones written as
+// dependencies of the peer to the element
+var (
+	cdxDependencyTypes = map[spdx.RelationshipType]struct{}{
+		spdx.CONTAINS: {}, spdx.DEPENDS_ON: {}, spdx.VARIANT_OF: {},
+		spdx.DYNAMIC_LINK: {}, spdx.STATIC_LINK: {}, spdx.HAS_PREREQUISITE: {},
+	}
+	cdxReverseDependencyTypes = map[spdx.RelationshipType]struct{}{
+		spdx.CONTAINED_BY: {}, spdx.DEPENDENCY_OF: {}, spdx.BUILD_DEPENDENCY_OF: {},
+		spdx.DEV_DEPENDENCY_OF: {}, spdx.OPTIONAL_DEPENDENCY_OF: {},
+		spdx.PROVIDED_DEPENDENCY_OF: {}, spdx.TEST_DEPENDENCY_OF: {},
+		spdx.RUNTIME_DEPENDENCY_OF: {}, spdx.PREREQUISITE_FOR: {},
+	}
+)
+
+// CycloneDX serializes the document as a CycloneDX 1.5 JSON BOM. Each
+// package and file becomes a component identified by its SPDX ID, and the
+// relationships among them become the dependencies of the BOM. When the
+// document describes a single element, it is the component of the BOM
+// metadata.
+type CycloneDX struct{}
+
+// Serialize serializes the document into CycloneDX JSON
+func (c *CycloneDX) Serialize(doc *spdx.Document) (string, error) {
+	// Render the document first to finalize it, as the JSON serializer does
+	if _, err := doc.Render(); err != nil {
+		return "", fmt.Errorf("pre-rendering the document: %w", err)
+	}
+
+	// The serial number is derived from the namespace of the document,
+	// which is unique, so the same document gets the same BOM
+	serial := uuid.NewString()
+	if doc.Namespace != "" {
+		serial = uuid.NewSHA1(uuid.NameSpaceURL, []byte(doc.Namespace)).String()
+	}
+	created := doc.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+	bom := cdx.BOM{
+		BOMFormat:    cdx.BOMFormat,
+		SpecVersion:  cdx.SpecVersion,
+		SerialNumber: "urn:uuid:" + serial,
+		Version:      1,
+		Metadata: &cdx.Metadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools: &cdx.Tools{
+				Components: []cdx.Component{{
+					Type:    cdx.ComponentTypeApplication,
+					Name:    "bom",
+					Version: version.GetVersionInfo().GitVersion,
+				}},
+			},
+		},
+		Components:   []cdx.Component{},
+		Dependencies: []cdx.Dependency{},
+	}
+
+	q := query.New()
+	q.Document = doc
+	fp, err := q.Query("all")
+	if err != nil {
+		return "", fmt.Errorf("querying document: %w", err)
+	}
+
+	// Cycle the objects sorted by ID to get a stable output
+	ids := make([]string, 0, len(fp.Objects))
+	for id := range fp.Objects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	described := ""
+	if describedIDs := doc.DescribedIDs(); len(describedIDs) == 1 {
+		described = describedIDs[0]
+	}
+
+	dependencies := map[string]map[string]struct{}{}
+	addDependency := func(ref, dependsOn string) {
+		if _, ok := dependencies[ref]; !ok {
+			dependencies[ref] = map[string]struct{}{}
+		}
+		dependencies[ref][dependsOn] = struct{}{}
+	}
+
+	for _, id := range ids {
+		var component cdx.Component
+		switch o := fp.Objects[id].(type) {
+		case *spdx.Package:
+			component = c.buildComponent(o)
+		case *spdx.File:
+			component = c.buildFileComponent(o)
+		default:
+			continue
+		}
+		if id == described {
+			bom.Metadata.Component = &component
+		} else {
+			bom.Components = append(bom.Components, component)
+		}
+
+		// Only the relationships among elements in the document
+		// are translated, external ones have no component
+		for _, r := range *fp.Objects[id].GetRelationships() {
+			if r.Peer == nil || r.PeerExtReference != "" {
+				continue
+			}
+			if _, ok := fp.Objects[r.Peer.SPDXID()]; !ok {
+				continue
+			}
+			element, peer := id, r.Peer.SPDXID()
+			if r.Inverse {
+				element, peer = peer, element
+			}
+			if _, ok := cdxDependencyTypes[r.Type]; ok {
+				addDependency(element, peer)
+			} else if _, ok := cdxReverseDependencyTypes[r.Type]; ok {
+				addDependency(peer, element)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		if _, ok := fp.Objects[id].(*spdx.Package); !ok {
+			if _, ok := dependencies[id]; !ok {
+				continue
+			}
+		}
+		dep := cdx.Dependency{Ref: id, DependsOn: []string{}}
+		for peer := range dependencies[id] {
+			dep.DependsOn = append(dep.DependsOn, peer)
+		}
+		sort.Strings(dep.DependsOn)
+		bom.Dependencies = append(bom.Dependencies, dep)
+	}
+
+	output, err := gojson.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshaling CycloneDX json: %w", err)
+	}
+	return string(output), nil
+}
+
+// buildComponent converts an SPDX package to a CycloneDX component. Packages
+// with an OCI purl are container images.
+func (c *CycloneDX) buildComponent(p *spdx.Package) cdx.Component {
+	component := cdx.Component{
+		Type:        cdx.ComponentTypeLibrary,
+		BOMRef:      p.SPDXID(),
+		Name:        p.Name,
+		Version:     p.Version,
+		Description: p.Comment,
+		Copyright:   cdxValue(p.CopyrightText),
+		Hashes:      cdxHashes(p.Checksum),
+		Licenses:    cdxLicenses(p.LicenseConcluded, p.LicenseDeclared),
+	}
+	if t, ok := cdxComponentTypes[p.PrimaryPurpose]; ok {
+		component.Type = t
+	}
+	if purl := p.Purl(); purl != nil {
+		component.Purl = purl.ToString()
+		if purl.Type == "oci" {
+			component.Type = cdx.ComponentTypeContainer
+		}
+	}
+	if location := cdxValue(p.DownloadLocation); location != "" {
+		component.ExtRefs = append(component.ExtRefs, cdx.ExternalRef{Type: "distribution", URL: location})
+	}
+	if p.HomePage != "" {
+		component.ExtRefs = append(component.ExtRefs, cdx.ExternalRef{Type: "website", URL: p.HomePage})
+	}
+	for _, advisory := range p.Advisories() {
+		component.ExtRefs = append(component.ExtRefs, cdx.ExternalRef{Type: "advisories", URL: advisory})
+	}
+	return component
+}
+
+// buildFileComponent converts an SPDX file to a CycloneDX file component
+func (c *CycloneDX) buildFileComponent(f *spdx.File) cdx.Component {
+	return cdx.Component{
+		Type:      cdx.ComponentTypeFile,
+		BOMRef:    f.SPDXID(),
+		Name:      f.Name,
+		Copyright: cdxValue(f.CopyrightText),
+		Hashes:    cdxHashes(f.Checksum),
+		Licenses:  cdxLicenses(f.LicenseConcluded, ""),
+	}
+}
+
+// cdxHashes converts SPDX checksums to CycloneDX hashes sorted by algorithm
+func cdxHashes(checksums map[string]string) []cdx.Hash {
+	hashes := []cdx.Hash{}
+	for algo, value := range checksums {
+		if alg, ok := cdxHashAlgorithms[strings.ToUpper(algo)]; ok {
+			hashes = append(hashes, cdx.Hash{Algorithm: alg, Content: strings.ToLower(value)})
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].Algorithm < hashes[j].Algorithm })
+	return hashes
+}
+
+// cdxLicenses returns the license of a component: the concluded license
+// or, if there is none, the declared one
+func cdxLicenses(concluded, declared string) []cdx.LicenseChoice {
+	for _, l := range []string{concluded, declared} {
+		if l = cdxValue(l); l != "" {
+			return []cdx.LicenseChoice{{Expression: l}}
+		}
+	}
+	return nil
+}
+
+// cdxValue returns an SPDX value, blanking NONE and NOASSERTION which
+// CycloneDX expresses by leaving the field out
+func cdxValue(value string) string {
+	if value == spdx.NONE || value == spdx.NOASSERTION {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serialize
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cdx "sigs.k8s.io/bom/pkg/cyclonedx/v1.5"
+	"sigs.k8s.io/bom/pkg/spdx"
+)
+
+func TestCycloneDXFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), os.FileMode(0o755)))
+	for path, content := range map[string]string{
+		"README.md":   "readme",
+		"main.go":     "package main",
+		"sub/data.db": "data",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), os.FileMode(0o644)))
+	}
+
+	// Classify licenses with the embedded list to avoid downloading it
+	sut := spdx.NewSPDX()
+	licenseData := sut.Options().LicenseData
+	sut.Options().LicenseData = ""
+	defer func() { sut.Options().LicenseData = licenseData }()
+	pkg, err := sut.PackageFromDirectory(dir)
+	require.NoError(t, err)
+	require.Len(t, pkg.Files(), 3)
+
+	image := spdx.NewPackage()
+	image.Name = "nginx"
+	image.BuildID("nginx")
+	image.ExternalRefs = append(image.ExternalRefs, spdx.ExternalRef{
+		Category: spdx.CatPackageManager,
+		Type:     "purl",
+		Locator:  "pkg:oci/nginx@sha256%3A0123456789abcdef?repository_url=index.docker.io%2Flibrary",
+	})
+	pkg.AddRelationship(&spdx.Relationship{
+		FullRender: true,
+		Type:       spdx.VARIANT_OF,
+		Peer:       image,
+	})
+
+	doc := spdx.NewDocument()
+	doc.Name = "test-document"
+	require.NoError(t, doc.AddPackage(pkg))
+
+	s := &CycloneDX{}
+	out, err := s.Serialize(doc)
+	require.NoError(t, err)
+
+	bom := cdx.BOM{}
+	require.NoError(t, json.Unmarshal([]byte(out), &bom))
+	require.Equal(t, cdx.BOMFormat, bom.BOMFormat)
+	require.Equal(t, cdx.SpecVersion, bom.SpecVersion)
+
+	// The described package is the metadata component, the files and
+	// the image its components
+	require.NotNil(t, bom.Metadata.Component)
+	require.Equal(t, pkg.SPDXID(), bom.Metadata.Component.BOMRef)
+	require.Len(t, bom.Components, 4)
+
+	types := map[string]int{}
+	refs := []string{}
+	for _, c := range bom.Components {
+		types[c.Type]++
+		refs = append(refs, c.BOMRef)
+		switch c.Type {
+		case cdx.ComponentTypeFile:
+			algorithms := []string{}
+			for _, h := range c.Hashes {
+				algorithms = append(algorithms, h.Algorithm)
+			}
+			require.Equal(t, []string{"SHA-1", "SHA-256", "SHA-512"}, algorithms)
+		case cdx.ComponentTypeContainer:
+			require.Equal(t, image.SPDXID(), c.BOMRef)
+			require.Equal(t, image.Purl().ToString(), c.Purl)
+		}
+	}
+	require.Equal(t, map[string]int{cdx.ComponentTypeFile: 3, cdx.ComponentTypeContainer: 1}, types)
+
+	// The package depends on its files and on the image
+	var deps *cdx.Dependency
+	for i := range bom.Dependencies {
+		if bom.Dependencies[i].Ref == pkg.SPDXID() {
+			deps = &bom.Dependencies[i]
+		}
+	}
+	require.NotNil(t, deps)
+	require.ElementsMatch(t, refs, deps.DependsOn)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serialize
+
+import (
+	"bufio"
+	gojson "encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonWriter writes a JSON object field by field, with the layout of
+// json.MarshalIndent with a two space indent. The values of the array
+// fields are written one at a time. The first error is kept and returned
+// when closing the writer, the later writes do nothing.
+type jsonWriter struct {
+	w      *bufio.Writer
+	fields int
+	err    error
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	jw := &jsonWriter{w: bufio.NewWriter(w)}
+	jw.write("{")
+	return jw
+}
+
+func (jw *jsonWriter) write(s string) {
+	if jw.err == nil {
+		_, jw.err = jw.w.WriteString(s)
+	}
+}
+
+// value writes v indented to be nested at depth levels
+func (jw *jsonWriter) value(v any, depth int) {
+	if jw.err != nil {
+		return
+	}
+	prefix := ""
+	for i := 0; i < depth; i++ {
+		prefix += "  "
+	}
+	data, err := gojson.MarshalIndent(v, prefix, "  ")
+	if err != nil {
+		jw.err = fmt.Errorf("marshaling document json: %w", err)
+		return
+	}
+	_, jw.err = jw.w.Write(data)
+}
+
+// key starts a new field of the object
+func (jw *jsonWriter) key(name string) {
+	if jw.fields > 0 {
+		jw.write(",")
+	}
+	jw.fields++
+	jw.write("\n  ")
+	jw.value(name, 1)
+	jw.write(": ")
+}
+
+// field writes a field of the object
+func (jw *jsonWriter) field(name string, v any) {
+	jw.key(name)
+	jw.value(v, 1)
+}
+
+// array starts an array field, whose values are written with add
+func (jw *jsonWriter) array(name string) *jsonArray {
+	jw.key(name)
+	jw.write("[")
+	return &jsonArray{jw: jw}
+}
+
+// close ends the object and flushes it to the underlying writer
+func (jw *jsonWriter) close() error {
+	if jw.fields > 0 {
+		jw.write("\n")
+	}
+	jw.write("}")
+	if jw.err == nil {
+		jw.err = jw.w.Flush()
+	}
+	return jw.err
+}
+
+// jsonArray is an array field being written
+type jsonArray struct {
+	jw   *jsonWriter
+	size int
+}
+
+// add writes a value of the array
+func (ja *jsonArray) add(v any) {
+	if ja.size > 0 {
+		ja.jw.write(",")
+	}
+	ja.size++
+	ja.jw.write("\n    ")
+	ja.jw.value(v, 2)
+}
+
+// end closes the array
+func (ja *jsonArray) end() {
+	if ja.size > 0 {
+		ja.jw.write("\n  ")
+	}
+	ja.jw.write("]")
+}
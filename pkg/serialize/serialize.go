@@ -18,10 +18,11 @@ package serialize
 
 import (
 	"fmt"
+	"io"
+	"sort"
+	"strings"
 	"time"
 
-	gojson "encoding/json"
-
 	"sigs.k8s.io/bom/pkg/query"
 	"sigs.k8s.io/bom/pkg/spdx"
 	spdxJSON "sigs.k8s.io/bom/pkg/spdx/json/v2.3"
@@ -45,87 +46,165 @@ type JSON struct{}
 
 // Serialize serializes the document into a spdx JSON
 func (json *JSON) Serialize(doc *spdx.Document) (string, error) {
-	// The old Render() method finalizes the sbom before serializing
-	// it. We still need to call it before building the JSON struct.
-	if _, err := doc.Render(); err != nil {
-		return "", fmt.Errorf("pre-rendering the document: %w", err)
-	}
-
-	jsonDoc := spdxJSON.Document{
-		ID:      doc.ID,
-		Name:    doc.Name,
-		Version: spdxJSON.Version,
-		CreationInfo: spdxJSON.CreationInfo{
-			Created: time.Now().UTC().Format("2006-01-02T15:04:05Z07:00"),
-			Creators: []string{
-				fmt.Sprintf("Tool: %s-%s", "bom", version.GetVersionInfo().GitVersion),
-			},
-			LicenseListVersion: doc.LicenseListVersion,
-		},
-		DataLicense:       doc.DataLicense,
-		Namespace:         doc.Namespace,
-		DocumentDescribes: []string{},
-		Packages:          []spdxJSON.Package{},
-		Relationships:     []spdxJSON.Relationship{},
-	}
-
-	// Generate the array for the cycler
-	for _, p := range doc.Packages {
-		jsonDoc.DocumentDescribes = append(jsonDoc.DocumentDescribes, p.SPDXID())
+	var output strings.Builder
+	if err := json.WriteJSON(doc, &output); err != nil {
+		return "", err
 	}
+	return output.String(), nil
+}
 
-	for _, p := range doc.Files {
-		jsonDoc.DocumentDescribes = append(jsonDoc.DocumentDescribes, p.SPDXID())
+// WriteJSON writes the document as spdx JSON to w, as Serialize returns
+// it. The packages, files and relationships are encoded and written one at
+// a time, so the memory needed stays the same whatever the number of files
+// of the document, instead of holding the whole JSON in memory.
+func (json *JSON) WriteJSON(doc *spdx.Document, w io.Writer) error {
+	// The document is finalized the way rendering it does, without
+	// building the rendered document
+	if err := doc.Finalize(); err != nil {
+		return fmt.Errorf("finalizing the document: %w", err)
 	}
 
 	q := query.New()
 	q.Document = doc
 	fp, err := q.Query("all")
 	if err != nil {
-		return "", fmt.Errorf("querying document: %w", err)
+		return fmt.Errorf("querying document: %w", err)
 	}
 
-	for _, o := range fp.Objects {
-		if p, ok := o.(*spdx.Package); ok {
-			jsonPackage, err := json.buildJSONPackage(p)
-			if err != nil {
-				return "", fmt.Errorf("serializing json package: %w", err)
-			}
-			jsonDoc.Packages = append(jsonDoc.Packages, jsonPackage)
-
-			// Add the package's relationships to the doc
-			for _, r := range *p.GetRelationships() {
-				jsonDoc.Relationships = append(jsonDoc.Relationships, spdxJSON.Relationship{
-					Element: p.SPDXID(),
-					Type:    string(r.Type),
-					Related: r.Peer.SPDXID(),
-				})
-			}
+	// Cycle the objects sorted by ID to get a stable output
+	ids := make([]string, 0, len(fp.Objects))
+	hasFiles := false
+	for id, o := range fp.Objects {
+		ids = append(ids, id)
+		if _, ok := o.(*spdx.File); ok {
+			hasFiles = true
 		}
-
-		if f, ok := o.(*spdx.File); ok {
+	}
+	sort.Strings(ids)
+
+	jw := newJSONWriter(w)
+	jw.field("SPDXID", doc.ID)
+	jw.field("name", doc.Name)
+	jw.field("spdxVersion", spdxJSON.Version)
+	created := doc.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+	jw.field("creationInfo", spdxJSON.CreationInfo{
+		Created: created.UTC().Format("2006-01-02T15:04:05Z07:00"),
+		Creators: []string{
+			fmt.Sprintf("Tool: %s-%s", "bom", version.GetVersionInfo().GitVersion),
+		},
+		LicenseListVersion: doc.LicenseListVersion,
+	})
+	jw.field("dataLicense", doc.DataLicense)
+	jw.field("documentNamespace", doc.Namespace)
+
+	// The top level elements are also listed as DESCRIBES relationships
+	// of the document, as documentDescribes is deprecated in SPDX 2.3.
+	describedIDs := doc.DescribedIDs()
+	jw.field("documentDescribes", append([]string{}, describedIDs...))
+
+	if hasFiles {
+		files := jw.array("files")
+		for _, id := range ids {
+			f, ok := fp.Objects[id].(*spdx.File)
+			if !ok {
+				continue
+			}
 			jsonFile, err := json.buildJSONFile(f)
 			if err != nil {
-				return "", fmt.Errorf("serializing json package: %w", err)
+				return fmt.Errorf("serializing json package: %w", err)
 			}
-			jsonDoc.Files = append(jsonDoc.Files, jsonFile)
-
-			// Add the package's relationships to the doc
-			for _, r := range *f.GetRelationships() {
-				jsonDoc.Relationships = append(jsonDoc.Relationships, spdxJSON.Relationship{
-					Element: f.SPDXID(),
-					Type:    string(r.Type),
-					Related: r.Peer.SPDXID(),
-				})
+			files.add(jsonFile)
+		}
+		files.end()
+	}
+
+	packages := jw.array("packages")
+	for _, id := range ids {
+		p, ok := fp.Objects[id].(*spdx.Package)
+		if !ok {
+			continue
+		}
+		jsonPackage, err := json.buildJSONPackage(p)
+		if err != nil {
+			return fmt.Errorf("serializing json package: %w", err)
+		}
+		packages.add(jsonPackage)
+	}
+	packages.end()
+
+	relationships := jw.array("relationships")
+	for _, id := range describedIDs {
+		relationships.add(spdxJSON.Relationship{
+			Element: doc.ID,
+			Type:    string(spdx.DESCRIBES),
+			Related: id,
+		})
+	}
+	for _, id := range ids {
+		switch o := fp.Objects[id].(type) {
+		case *spdx.Package, *spdx.File:
+			for _, r := range *o.GetRelationships() {
+				rel, err := buildJSONRelationship(o, r)
+				if err != nil {
+					return err
+				}
+				relationships.add(rel)
 			}
 		}
 	}
+	relationships.end()
+
+	if len(doc.Annotations) > 0 {
+		annotations := jw.array("annotations")
+		for _, a := range doc.Annotations {
+			annotations.add(spdxJSON.Annotation{
+				Annotator: a.Annotator,
+				Date:      a.Date,
+				Type:      a.Type,
+				Comment:   a.Comment,
+			})
+		}
+		annotations.end()
+	}
+	return jw.close()
+}
 
-	output, err := gojson.MarshalIndent(jsonDoc, "", "  ")
-	if err != nil {
-		return "", fmt.Errorf("marshaling document json: %w", err)
+// buildJSONRelationship converts a relationship of an element to its JSON
+// form. Relationships to peers outside of the document use their
+// reference, inverse relationships are written from the peer.
+func buildJSONRelationship(o spdx.Object, r *spdx.Relationship) (spdxJSON.Relationship, error) {
+	related := r.PeerReference
+	if r.Peer != nil {
+		related = r.Peer.SPDXID()
 	}
-	return string(output), nil
+	if related == "" || r.Type == "" {
+		return spdxJSON.Relationship{}, fmt.Errorf(
+			"serializing relationship of %s: relationships need a type and a peer", o.SPDXID(),
+		)
+	}
+	if r.PeerExtReference != "" {
+		related = "DocumentRef-" + r.PeerExtReference + ":" + related
+	}
+	if r.Inverse {
+		if r.Peer == nil || r.PeerExtReference != "" {
+			return spdxJSON.Relationship{}, fmt.Errorf(
+				"serializing relationship of %s: inverse relationships need a peer in the document", o.SPDXID(),
+			)
+		}
+		return spdxJSON.Relationship{
+			Element: related,
+			Type:    string(r.Type),
+			Related: o.SPDXID(),
+		}, nil
+	}
+	return spdxJSON.Relationship{
+		Element: o.SPDXID(),
+		Type:    string(r.Type),
+		Related: related,
+	}, nil
 }
 
 // buildJSONPackage converts a SPDX package struct to a json package
@@ -157,6 +236,9 @@ func (json *JSON) buildJSONPackage(p *spdx.Package) (jsonPackage spdxJSON.Packag
 		DownloadLocation:     p.DownloadLocation,
 		LicenseInfoFromFiles: p.LicenseInfoFromFiles,
 		PrimaryPurpose:       p.PrimaryPurpose,
+		SourceInfo:           p.SourceInfo,
+		Supplier:             jsonEntity(p.Supplier.Person, p.Supplier.Organization),
+		Originator:           jsonEntity(p.Originator.Person, p.Originator.Organization),
 		CopyrightText:        p.CopyrightText,
 		HasFiles:             []string{},
 		Checksums:            []spdxJSON.Checksum{},
@@ -165,7 +247,8 @@ func (json *JSON) buildJSONPackage(p *spdx.Package) (jsonPackage spdxJSON.Packag
 
 	if p.VerificationCode != "" {
 		jsonPackage.VerificationCode = &spdxJSON.PackageVerificationCode{
-			Value: p.VerificationCode,
+			Value:         p.VerificationCode,
+			ExcludedFiles: p.VerificationCodeExcludedFiles,
 		}
 	}
 
@@ -193,10 +276,14 @@ func (json *JSON) buildJSONPackage(p *spdx.Package) (jsonPackage spdxJSON.Packag
 		jsonPackage.DownloadLocation = spdx.NONE
 	}
 
-	for algo, value := range p.Checksum {
-		jsonPackage.Checksums = append(jsonPackage.Checksums, spdxJSON.Checksum{
-			Algorithm: algo,
-			Value:     value,
+	jsonPackage.Checksums = buildJSONChecksums(p.Checksum)
+
+	for _, a := range p.Annotations {
+		jsonPackage.Annotations = append(jsonPackage.Annotations, spdxJSON.Annotation{
+			Annotator: a.Annotator,
+			Date:      a.Date,
+			Type:      a.Type,
+			Comment:   a.Comment,
 		})
 	}
 
@@ -215,6 +302,18 @@ func (json *JSON) buildJSONPackage(p *spdx.Package) (jsonPackage spdxJSON.Packag
 	return jsonPackage, nil
 }
 
+// jsonEntity returns the supplier or originator of a package in its JSON
+// form. The JSON format has room for one, the organization is preferred.
+func jsonEntity(person, organization string) string {
+	switch {
+	case organization != "":
+		return "Organization: " + organization
+	case person != "":
+		return "Person: " + person
+	}
+	return ""
+}
+
 // buildJSONPackage converts a SPDX package struct to a json package
 // TODO(pueco): Validate file information , eg check checksums are
 // enum : [ "SHA256", "SHA1", "SHA384", "MD2", "MD4", "SHA512", "MD6", "MD5", "SHA224" ]
@@ -249,11 +348,34 @@ func (json *JSON) buildJSONFile(f *spdx.File) (jsonFile spdxJSON.File, err error
 		jsonFile.CopyrightText = spdx.NOASSERTION
 	}
 
-	for algo, value := range f.Checksum {
-		jsonFile.Checksums = append(jsonFile.Checksums, spdxJSON.Checksum{
-			Algorithm: algo,
-			Value:     value,
+	jsonFile.Checksums = buildJSONChecksums(f.Checksum)
+
+	for _, a := range f.Annotations {
+		jsonFile.Annotations = append(jsonFile.Annotations, spdxJSON.Annotation{
+			Annotator: a.Annotator,
+			Date:      a.Date,
+			Type:      a.Type,
+			Comment:   a.Comment,
 		})
 	}
 	return jsonFile, nil
 }
+
+// buildJSONChecksums converts a checksum map to a list of json checksums
+// sorted by algorithm
+func buildJSONChecksums(checksums map[string]string) []spdxJSON.Checksum {
+	algos := make([]string, 0, len(checksums))
+	for algo := range checksums {
+		algos = append(algos, algo)
+	}
+	sort.Strings(algos)
+
+	jsonChecksums := []spdxJSON.Checksum{}
+	for _, algo := range algos {
+		jsonChecksums = append(jsonChecksums, spdxJSON.Checksum{
+			Algorithm: algo,
+			Value:     checksums[algo],
+		})
+	}
+	return jsonChecksums
+}
@@ -0,0 +1,443 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serialize
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/bom/pkg/spdx"
+	spdxJSON "sigs.k8s.io/bom/pkg/spdx/json/v2.3"
+)
+
+func TestJSONDescribes(t *testing.T) {
+	doc := spdx.NewDocument()
+	doc.Name = "test-document"
+	pkg := spdx.NewPackage()
+	pkg.Name = "root"
+	pkg.BuildID("root")
+	require.NoError(t, doc.AddPackage(pkg))
+
+	s := &JSON{}
+	out, err := s.Serialize(doc)
+	require.NoError(t, err)
+
+	jsonDoc := spdxJSON.Document{}
+	require.NoError(t, json.Unmarshal([]byte(out), &jsonDoc))
+	require.Equal(t, []string{pkg.SPDXID()}, jsonDoc.DocumentDescribes)
+	require.Contains(t, jsonDoc.Relationships, spdxJSON.Relationship{
+		Element: doc.ID,
+		Type:    string(spdx.DESCRIBES),
+		Related: pkg.SPDXID(),
+	})
+
+	// The relationships alone are enough to find the top level
+	// package when parsing the document back
+	jsonDoc.DocumentDescribes = []string{}
+	data, err := json.Marshal(&jsonDoc)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "doc.spdx.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	parsed, err := spdx.OpenDoc(path)
+	require.NoError(t, err)
+	require.NoError(t, parsed.ValidateDescribes())
+	require.Contains(t, parsed.Packages, pkg.SPDXID())
+}
+
+func TestJSONInverseRelationship(t *testing.T) {
+	doc := spdx.NewDocument()
+	doc.Name = "test-document"
+	pkg := spdx.NewPackage()
+	pkg.Name = "root"
+	pkg.BuildID("root")
+	dep := spdx.NewPackage()
+	dep.Name = "testonly"
+	dep.BuildID("testonly")
+	pkg.AddRelationship(&spdx.Relationship{
+		FullRender: true,
+		Type:       spdx.TEST_DEPENDENCY_OF,
+		Peer:       dep,
+		Inverse:    true,
+	})
+	require.NoError(t, doc.AddPackage(pkg))
+
+	s := &JSON{}
+	out, err := s.Serialize(doc)
+	require.NoError(t, err)
+
+	// The relationship is written from the dependency to the package
+	jsonDoc := spdxJSON.Document{}
+	require.NoError(t, json.Unmarshal([]byte(out), &jsonDoc))
+	require.Contains(t, jsonDoc.Relationships, spdxJSON.Relationship{
+		Element: dep.SPDXID(),
+		Type:    string(spdx.TEST_DEPENDENCY_OF),
+		Related: pkg.SPDXID(),
+	})
+	require.NotContains(t, jsonDoc.Relationships, spdxJSON.Relationship{
+		Element: pkg.SPDXID(),
+		Type:    string(spdx.TEST_DEPENDENCY_OF),
+		Related: dep.SPDXID(),
+	})
+}
+
+func TestJSONUppercaseChecksums(t *testing.T) {
+	const sha256Hex = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	doc := spdx.NewDocument()
+	doc.Name = "test-document"
+	doc.UppercaseChecksums = true
+	pkg := spdx.NewPackage()
+	pkg.Name = "root"
+	pkg.BuildID("root")
+	pkg.Checksum = map[string]string{"SHA256": sha256Hex}
+	require.NoError(t, doc.AddPackage(pkg))
+
+	s := &JSON{}
+	out, err := s.Serialize(doc)
+	require.NoError(t, err)
+
+	jsonDoc := spdxJSON.Document{}
+	require.NoError(t, json.Unmarshal([]byte(out), &jsonDoc))
+	require.Len(t, jsonDoc.Packages, 1)
+	require.Equal(t, []spdxJSON.Checksum{
+		{Algorithm: "SHA256", Value: strings.ToUpper(sha256Hex)},
+	}, jsonDoc.Packages[0].Checksums)
+
+	// The checksums are read back in lowercase
+	path := filepath.Join(t.TempDir(), "doc.spdx.json")
+	require.NoError(t, os.WriteFile(path, []byte(out), 0o600))
+	parsed, err := spdx.OpenDoc(path)
+	require.NoError(t, err)
+	require.Contains(t, parsed.Packages, pkg.SPDXID())
+	require.Equal(t, map[string]string{"SHA256": sha256Hex}, parsed.Packages[pkg.SPDXID()].Checksum)
+}
+
+func TestJSONSecurityAdvisory(t *testing.T) {
+	const advisory = "https://github.com/advisories/GHSA-xxxx-yyyy-zzzz"
+	doc := spdx.NewDocument()
+	doc.Name = "test-document"
+	pkg := spdx.NewPackage()
+	pkg.Name = "root"
+	pkg.BuildID("root")
+	require.NoError(t, pkg.AddAdvisory(advisory))
+	require.NoError(t, doc.AddPackage(pkg))
+
+	s := &JSON{}
+	out, err := s.Serialize(doc)
+	require.NoError(t, err)
+
+	jsonDoc := spdxJSON.Document{}
+	require.NoError(t, json.Unmarshal([]byte(out), &jsonDoc))
+	require.Len(t, jsonDoc.Packages, 1)
+	require.Equal(t, []spdxJSON.ExternalRef{
+		{Category: spdx.CatSecurity, Type: "advisory", Locator: advisory},
+	}, jsonDoc.Packages[0].ExternalRefs)
+
+	path := filepath.Join(t.TempDir(), "doc.spdx.json")
+	require.NoError(t, os.WriteFile(path, []byte(out), 0o600))
+	parsed, err := spdx.OpenDoc(path)
+	require.NoError(t, err)
+	require.Contains(t, parsed.Packages, pkg.SPDXID())
+	require.Equal(t, []string{advisory}, parsed.Packages[pkg.SPDXID()].Advisories())
+}
+
+func TestTagValueJSONRoundTrip(t *testing.T) {
+	doc := spdx.NewDocument()
+	doc.Name = "test-document"
+
+	pkg := spdx.NewPackage()
+	pkg.Name = "root"
+	pkg.Version = "v1.2.3"
+	pkg.BuildID("root")
+	pkg.DownloadLocation = "https://example.com/root.tar.gz"
+	pkg.LicenseConcluded = "Apache-2.0"
+	pkg.LicenseDeclared = "Apache-2.0"
+	pkg.CopyrightText = "Copyright 2023 The Authors\nCopyright 2022 Other Authors"
+	pkg.Comment = "First line\nsecond line"
+	pkg.Supplier.Organization = "Example Inc."
+	pkg.Originator.Person = "Jane Doe (jane@example.com)"
+	pkg.Checksum = map[string]string{"SHA256": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"}
+	pkg.ExternalRefs = []spdx.ExternalRef{{Category: spdx.CatPackageManager, Type: "purl", Locator: "pkg:golang/example.com/root@v1.2.3"}}
+
+	f := spdx.NewFile()
+	f.Name = "main.go"
+	f.BuildID("root")
+	f.LicenseConcluded = "MIT"
+	f.LicenseInfoInFile = "MIT"
+	f.CopyrightText = "Copyright 2023 The Authors"
+	f.Checksum = map[string]string{"SHA1": "a9993e364706816aba3e25717850c26c9cd0d89d"}
+	require.NoError(t, pkg.AddFile(f))
+
+	sbom := spdx.NewFile()
+	sbom.Name = "root.spdx"
+	sbom.BuildID("root.spdx")
+	sbom.Checksum = map[string]string{"SHA1": "da39a3ee5e6b4b0d3255bfef95601890afd80709"}
+	require.NoError(t, pkg.AddFile(sbom))
+	pkg.FilesAnalyzed = true
+	pkg.VerificationCodeExcludedFiles = []string{"root.spdx"}
+	require.NoError(t, pkg.ComputeVerificationCode())
+
+	dep := spdx.NewPackage()
+	dep.Name = "dependency"
+	dep.Version = "v0.1.0"
+	dep.BuildID("dependency")
+	dep.DownloadLocation = spdx.NOASSERTION
+	dep.LicenseConcluded = "MIT"
+	pkg.AddRelationship(&spdx.Relationship{Peer: dep, Type: spdx.DEPENDS_ON, FullRender: true})
+	require.NoError(t, doc.AddPackage(pkg))
+
+	// The same document is written in both formats and parsed back
+	parsed := map[string]*spdx.Document{}
+	for name, s := range map[string]interface {
+		Serialize(*spdx.Document) (string, error)
+	}{"doc.spdx": &TagValue{}, "doc.spdx.json": &JSON{}} {
+		out, err := s.Serialize(doc)
+		require.NoError(t, err, name)
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(out), 0o600))
+		parsed[name], err = spdx.OpenDoc(path)
+		require.NoError(t, err, name)
+	}
+
+	// The JSON documents have no package comments
+	require.Equal(t, pkg.Comment, parsed["doc.spdx"].Packages[pkg.SPDXID()].Comment)
+
+	for name, parsedDoc := range parsed {
+		require.Equal(t, doc.Name, parsedDoc.Name, name)
+		root, ok := parsedDoc.Packages[pkg.SPDXID()]
+		require.True(t, ok, name)
+		require.Equal(t, pkg.Name, root.Name, name)
+		require.Equal(t, pkg.Version, root.Version, name)
+		require.Equal(t, pkg.DownloadLocation, root.DownloadLocation, name)
+		require.Equal(t, pkg.LicenseConcluded, root.LicenseConcluded, name)
+		require.Equal(t, pkg.LicenseDeclared, root.LicenseDeclared, name)
+		require.Equal(t, pkg.CopyrightText, root.CopyrightText, name)
+		require.Equal(t, pkg.Checksum, root.Checksum, name)
+		require.Equal(t, pkg.Supplier, root.Supplier, name)
+		require.Equal(t, pkg.VerificationCode, root.VerificationCode, name)
+		require.Equal(t, pkg.VerificationCodeExcludedFiles, root.VerificationCodeExcludedFiles, name)
+		require.Equal(t, pkg.Originator, root.Originator, name)
+		require.Equal(t, pkg.ExternalRefs, root.ExternalRefs, name)
+
+		peers := map[string]*spdx.Relationship{}
+		for _, rel := range root.Relationships {
+			peers[rel.Peer.SPDXID()] = rel
+		}
+		require.Contains(t, peers, f.SPDXID(), name)
+		require.Equal(t, spdx.CONTAINS, peers[f.SPDXID()].Type, name)
+		parsedFile, ok := peers[f.SPDXID()].Peer.(*spdx.File)
+		require.True(t, ok, name)
+		require.Equal(t, f.Name, parsedFile.Name, name)
+		require.Equal(t, f.LicenseConcluded, parsedFile.LicenseConcluded, name)
+		require.Equal(t, f.LicenseInfoInFile, parsedFile.LicenseInfoInFile, name)
+		require.Equal(t, f.CopyrightText, parsedFile.CopyrightText, name)
+		require.Equal(t, f.Checksum, parsedFile.Checksum, name)
+
+		require.Contains(t, peers, dep.SPDXID(), name)
+		require.Equal(t, spdx.DEPENDS_ON, peers[dep.SPDXID()].Type, name)
+		parsedDep, ok := peers[dep.SPDXID()].Peer.(*spdx.Package)
+		require.True(t, ok, name)
+		require.Equal(t, dep.Name, parsedDep.Name, name)
+		require.Equal(t, dep.Version, parsedDep.Version, name)
+		require.Equal(t, dep.LicenseConcluded, parsedDep.LicenseConcluded, name)
+	}
+}
+
+func TestAnnotationsRoundTrip(t *testing.T) {
+	docAnnotation := spdx.Annotation{
+		Annotator: "Tool: bom-v0.5.0", Date: "2023-01-01T00:00:00Z",
+		Type: spdx.AnnotationTypeOther, Comment: "SPDX license list version: 3.20",
+	}
+	pkgAnnotation := spdx.Annotation{
+		Annotator: "Person: Jane Doe", Date: "2023-01-02T00:00:00Z",
+		Type: spdx.AnnotationTypeReview, Comment: "Reviewed\nthe package",
+	}
+	fileAnnotation := spdx.Annotation{
+		Annotator: "Tool: bom-v0.5.0", Date: "2023-01-03T00:00:00Z",
+		Type: spdx.AnnotationTypeOther, Comment: "Zero-byte file",
+	}
+
+	doc := spdx.NewDocument()
+	doc.Name = "test-document"
+	doc.AddAnnotation(docAnnotation)
+	pkg := spdx.NewPackage()
+	pkg.Name = "root"
+	pkg.BuildID("root")
+	pkg.AddAnnotation(pkgAnnotation)
+	f := spdx.NewFile()
+	f.Name = "empty.txt"
+	f.BuildID("root")
+	f.Checksum = map[string]string{"SHA1": "da39a3ee5e6b4b0d3255bfef95601890afd80709"}
+	f.AddAnnotation(fileAnnotation)
+	require.NoError(t, pkg.AddFile(f))
+	require.NoError(t, doc.AddPackage(pkg))
+
+	// The annotations of the document, packages and files are read back
+	// from both formats
+	for name, s := range map[string]interface {
+		Serialize(*spdx.Document) (string, error)
+	}{"doc.spdx": &TagValue{}, "doc.spdx.json": &JSON{}} {
+		out, err := s.Serialize(doc)
+		require.NoError(t, err, name)
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(out), 0o600))
+		parsed, err := spdx.OpenDoc(path)
+		require.NoError(t, err, name)
+
+		require.Equal(t, []spdx.Annotation{docAnnotation}, parsed.Annotations, name)
+		root, ok := parsed.Packages[pkg.SPDXID()]
+		require.True(t, ok, name)
+		require.Equal(t, []spdx.Annotation{pkgAnnotation}, root.Annotations, name)
+		require.Len(t, root.Relationships, 1, name)
+		parsedFile, ok := root.Relationships[0].Peer.(*spdx.File)
+		require.True(t, ok, name)
+		require.Equal(t, []spdx.Annotation{fileAnnotation}, parsedFile.Annotations, name)
+	}
+}
+
+func TestJSONWriteJSON(t *testing.T) {
+	doc := spdx.NewDocument()
+	doc.Name = "test-document"
+	doc.AddAnnotation(spdx.Annotation{Annotator: "Tool: test", Date: "2023-01-01T00:00:00Z", Type: "OTHER", Comment: "test"})
+	pkg := spdx.NewPackage()
+	pkg.Name = "root"
+	pkg.BuildID("root")
+	pkg.FilesAnalyzed = true
+	for _, name := range []string{"main.go", "README.md"} {
+		f := spdx.NewFile()
+		f.Name = name
+		f.BuildID(name)
+		f.Checksum = map[string]string{"SHA1": strings.Repeat("a", 40)}
+		require.NoError(t, pkg.AddFile(f))
+	}
+	sub := spdx.NewPackage()
+	sub.Name = "sub"
+	sub.BuildID("sub")
+	require.NoError(t, pkg.AddPackage(sub))
+	pkg.AddRelationship(&spdx.Relationship{
+		Type: spdx.DEPENDS_ON, PeerReference: "SPDXRef-Package-other", PeerExtReference: "other-doc",
+	})
+	require.NoError(t, doc.AddPackage(pkg))
+
+	s := &JSON{}
+	var out strings.Builder
+	require.NoError(t, s.WriteJSON(doc, &out))
+
+	// The output is laid out as the whole document marshaled at once
+	jsonDoc := spdxJSON.Document{}
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &jsonDoc))
+	marshaled, err := json.MarshalIndent(&jsonDoc, "", "  ")
+	require.NoError(t, err)
+	require.Equal(t, string(marshaled), out.String())
+
+	require.Len(t, jsonDoc.Packages, 2)
+	require.Len(t, jsonDoc.Files, 2)
+	require.Len(t, jsonDoc.Annotations, 1)
+	require.Contains(t, jsonDoc.Relationships, spdxJSON.Relationship{
+		Element: pkg.SPDXID(), Type: string(spdx.DEPENDS_ON), Related: "DocumentRef-other-doc:SPDXRef-Package-other",
+	})
+	for _, p := range jsonDoc.Packages {
+		if p.ID == pkg.SPDXID() {
+			require.NotNil(t, p.VerificationCode)
+			require.Len(t, p.HasFiles, 2)
+		}
+	}
+
+	// Documents without files leave out the files field
+	empty := spdx.NewDocument()
+	empty.Name = "empty"
+	out.Reset()
+	require.NoError(t, s.WriteJSON(empty, &out))
+	require.NotContains(t, out.String(), `"files"`)
+	require.Contains(t, out.String(), `"packages": []`)
+
+	// Relationships need a peer
+	pkg.AddRelationship(&spdx.Relationship{Type: spdx.DEPENDS_ON})
+	require.Error(t, s.WriteJSON(doc, io.Discard))
+}
+
+// heapSampler discards the data written to it, recording the largest live
+// heap seen every sampleEvery writes
+type heapSampler struct {
+	writes  int
+	maxHeap uint64
+}
+
+const sampleEvery = 1000
+
+func (hs *heapSampler) Write(p []byte) (int, error) {
+	hs.writes++
+	if hs.writes%sampleEvery == 0 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > hs.maxHeap {
+			hs.maxHeap = stats.HeapAlloc
+		}
+	}
+	return len(p), nil
+}
+
+// BenchmarkJSONWriteJSON writes a package of numFiles files, reporting the
+// live heap grown while writing it. As the files are written one at a time
+// it only grows with the index of their IDs (under 100 bytes per file), not
+// with the size of the JSON written.
+func BenchmarkJSONWriteJSON(b *testing.B) {
+	for _, numFiles := range []int{10000, 100000} {
+		doc := spdx.NewDocument()
+		doc.Name = "bench"
+		pkg := spdx.NewPackage()
+		pkg.Name = "root"
+		pkg.BuildID("root")
+		pkg.FilesAnalyzed = true
+		for i := 0; i < numFiles; i++ {
+			f := spdx.NewFile()
+			f.Name = fmt.Sprintf("dir%d/file%d.go", i%100, i)
+			f.BuildID(f.Name)
+			f.Checksum = map[string]string{"SHA1": fmt.Sprintf("%040x", i), "SHA256": fmt.Sprintf("%064x", i)}
+			f.LicenseInfoInFile = spdx.NONE
+			if err := pkg.AddFile(f); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := doc.AddPackage(pkg); err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("files=%d", numFiles), func(b *testing.B) {
+			s := &JSON{}
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				var stats runtime.MemStats
+				runtime.ReadMemStats(&stats)
+				sampler := &heapSampler{maxHeap: stats.HeapAlloc}
+				if err := s.WriteJSON(doc, sampler); err != nil {
+					b.Fatal(err)
+				}
+				b.ReportMetric(float64(sampler.maxHeap-stats.HeapAlloc)/(1<<20), "heap-growth-MB")
+			}
+		})
+	}
+}
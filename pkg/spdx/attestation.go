@@ -0,0 +1,46 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"encoding/json"
+	"errors"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+)
+
+// NewSPDXStatement wraps a rendered SPDX document in an in-toto statement
+// about the subjects, ready to be signed as an attestation. The predicate
+// type is https://spdx.dev/Document. Documents rendered as JSON are
+// embedded as a JSON object, those in any other format as a string.
+func NewSPDXStatement(document string, subjects []Subject) (*intoto.SPDXStatement, error) {
+	if len(subjects) == 0 {
+		return nil, errors.New("an in-toto statement needs at least one subject")
+	}
+	var predicate interface{} = document
+	if json.Valid([]byte(document)) {
+		predicate = json.RawMessage(document)
+	}
+	return &intoto.SPDXStatement{
+		StatementHeader: intoto.StatementHeader{
+			Type:          intoto.StatementInTotoV01,
+			PredicateType: intoto.PredicateSPDX,
+			Subject:       subjects,
+		},
+		Predicate: predicate,
+	}, nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSPDXStatement(t *testing.T) {
+	_, err := NewSPDXStatement("SPDXVersion: SPDX-2.3\n", nil)
+	require.Error(t, err)
+
+	subjects := []Subject{{Name: "bom", Digest: common.DigestSet{"sha256": "a78c2d6208eff9b672de43f880093100050983047b7b0afe0217d3656e1b0d5f"}}}
+	doc := NewDocument()
+	doc.Name = "attested"
+	tagValue, err := doc.Render()
+	require.NoError(t, err)
+	jsonDoc := `{"spdxVersion":"SPDX-2.3","name":"attested"}`
+
+	for _, tc := range []struct {
+		document  string
+		predicate interface{}
+	}{
+		// JSON documents are embedded as objects
+		{jsonDoc, map[string]interface{}{"spdxVersion": "SPDX-2.3", "name": "attested"}},
+		// Other formats are embedded as strings
+		{tagValue, tagValue},
+	} {
+		statement, err := NewSPDXStatement(tc.document, subjects)
+		require.NoError(t, err)
+		data, err := json.Marshal(statement)
+		require.NoError(t, err)
+
+		parsed := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(data, &parsed))
+		require.Equal(t, "https://in-toto.io/Statement/v0.1", parsed["_type"])
+		require.Equal(t, "https://spdx.dev/Document", parsed["predicateType"])
+		require.Equal(t, tc.predicate, parsed["predicate"])
+		require.Equal(t, []interface{}{map[string]interface{}{
+			"name":   "bom",
+			"digest": map[string]interface{}{"sha256": subjects[0].Digest["sha256"]},
+		}}, parsed["subject"])
+	}
+}
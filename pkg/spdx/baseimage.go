@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	purl "github.com/package-url/packageurl-go"
+)
+
+// OCI annotations naming the base image of an image. Build tools record
+// them in the image manifest, some as labels in the image config.
+const (
+	ociImageBaseNameAnnotation   = "org.opencontainers.image.base.name"
+	ociImageBaseDigestAnnotation = "org.opencontainers.image.base.digest"
+)
+
+// addBaseImage adds a package describing the base image of an image to
+// its package, related to it with a DESCENDANT_OF relationship. The base
+// image is read from the annotations of the image manifest, or else from
+// the labels of its config. The history in the config does not name the
+// base image, images without the annotations are left as they are.
+func addBaseImage(image *Package, annotations map[string]string, config *v1.ConfigFile) {
+	baseName, baseDigest := annotations[ociImageBaseNameAnnotation], annotations[ociImageBaseDigestAnnotation]
+	if baseName == "" && baseDigest == "" && config != nil {
+		labels := config.Config.Labels
+		baseName, baseDigest = labels[ociImageBaseNameAnnotation], labels[ociImageBaseDigestAnnotation]
+	}
+	if baseName == "" && baseDigest == "" {
+		return
+	}
+	image.AddRelationship(&Relationship{
+		Peer:       baseImagePackage(baseName, baseDigest),
+		Type:       DESCENDANT_OF,
+		FullRender: true,
+	})
+}
+
+// baseImagePackage synthesizes the package of a base image from its
+// reference and the digest of its manifest, any of them may be empty.
+// The package gets an oci purl when the reference can be parsed.
+func baseImagePackage(baseName, baseDigest string) *Package {
+	pkg := NewPackage()
+	pkg.Name = baseName
+	if pkg.Name == "" {
+		pkg.Name = baseDigest
+	}
+	pkg.Comment = "Base image"
+	pkg.DownloadLocation = NOASSERTION
+	pkg.PrimaryPurpose = "CONTAINER"
+
+	ref, err := name.ParseReference(baseName)
+	if err != nil {
+		pkg.Version = baseDigest
+		pkg.BuildID("base-image", pkg.Name, baseDigest)
+		return pkg
+	}
+	qualifiers := map[string]string{}
+	switch r := ref.(type) {
+	case name.Digest:
+		if baseDigest == "" {
+			baseDigest = r.DigestStr()
+		}
+	case name.Tag:
+		qualifiers["tag"] = r.TagStr()
+	}
+	pkg.Version = baseDigest
+	pkg.BuildID("base-image", pkg.Name, baseDigest)
+
+	repositoryURL, imageName := ociPurlRepository(ref.Context())
+	qualifiers["repository_url"] = repositoryURL
+	pkg.ExternalRefs = append(pkg.ExternalRefs, ExternalRef{
+		Category: CatPackageManager,
+		Type:     "purl",
+		Locator: purl.NewPackageURL(
+			purl.TypeOCI, "", imageName, baseDigest, purl.QualifiersFromMap(qualifiers), "",
+		).String(),
+	})
+	return pkg
+}
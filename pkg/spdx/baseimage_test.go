@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromImageTarballBaseImage(t *testing.T) {
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	baseDigest := "sha256:" + strings.Repeat("a", 64)
+
+	// baseImage returns the peer of the DESCENDANT_OF relationship
+	baseImage := func(tarPath string) *Package {
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromImageTarball(&Options{}, tarPath)
+		require.NoError(t, err)
+		var base *Package
+		for _, rel := range pkg.Relationships {
+			if rel.Type == DESCENDANT_OF {
+				require.Nil(t, base)
+				base = rel.Peer.(*Package)
+			}
+		}
+		return base
+	}
+
+	// Images without annotations have no base image
+	require.Nil(t, baseImage(writeTestOCILayoutTarball(t, img, "registry.example.com/test/image:v1.0.0")))
+
+	// The annotations of the manifest in OCI layouts
+	annotated := mutate.Annotations(img, map[string]string{
+		ociImageBaseNameAnnotation:   "docker.io/library/alpine:3.18",
+		ociImageBaseDigestAnnotation: baseDigest,
+	}).(v1.Image)
+	base := baseImage(writeTestOCILayoutTarball(t, annotated, "registry.example.com/test/image:v1.0.0"))
+	require.NotNil(t, base)
+	require.Equal(t, "docker.io/library/alpine:3.18", base.Name)
+	require.Equal(t, baseDigest, base.Version)
+	require.Equal(t,
+		"pkg:oci/alpine@"+baseDigest+"?repository_url=index.docker.io%2Flibrary&tag=3.18",
+		base.Purl().String(),
+	)
+
+	// The labels of the config, the digest can be in the reference
+	labeled, err := mutate.Config(img, v1.Config{Labels: map[string]string{
+		ociImageBaseNameAnnotation: "gcr.io/distroless/static@" + baseDigest,
+	}})
+	require.NoError(t, err)
+	tag, err := name.NewTag("registry.example.com/test/image:v1.0.0")
+	require.NoError(t, err)
+	tarPath := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, tarball.WriteToFile(tarPath, tag, labeled))
+	base = baseImage(tarPath)
+	require.NotNil(t, base)
+	require.Equal(t, baseDigest, base.Version)
+	require.Equal(t,
+		"pkg:oci/static@"+baseDigest+"?repository_url=gcr.io%2Fdistroless",
+		base.Purl().String(),
+	)
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// binaryAnnotation prefixes the annotation recording the format and
+// architectures of executable files
+const binaryAnnotation = "Binary format and architecture: "
+
+// Formats of the binaries recognized by ReadBinaryInfo
+const (
+	BinaryFormatELF   = "ELF"
+	BinaryFormatMachO = "Mach-O"
+	BinaryFormatPE    = "PE"
+)
+
+// Linkages of the ELF executables read by ReadBinaryInfo
+const (
+	BinaryLinkageStatic  = "static"
+	BinaryLinkageDynamic = "dynamic"
+)
+
+// BinaryInfo is the format and architecture read from the headers of an
+// executable or library. Universal Mach-O binaries list an architecture
+// for each of the binaries they contain.
+type BinaryInfo struct {
+	Format        string
+	Architectures []string // Architectures named as GOARCH (amd64, arm64...)
+	Universal     bool     // True for fat Mach-O files bundling several binaries
+	Linkage       string   // Static or dynamic for ELF executables, empty for libraries and other formats
+}
+
+func (bi *BinaryInfo) String() string {
+	format := bi.Format
+	if bi.Universal {
+		format += " universal"
+	}
+	return fmt.Sprintf("%s %s", format, strings.Join(bi.Architectures, ","))
+}
+
+var elfArchitectures = map[elf.Machine]string{
+	elf.EM_386:       "386",
+	elf.EM_X86_64:    "amd64",
+	elf.EM_ARM:       "arm",
+	elf.EM_AARCH64:   "arm64",
+	elf.EM_S390:      "s390x",
+	elf.EM_RISCV:     "riscv64",
+	elf.EM_MIPS:      "mips",
+	elf.EM_LOONGARCH: "loong64",
+}
+
+var machoArchitectures = map[macho.Cpu]string{
+	macho.Cpu386:   "386",
+	macho.CpuAmd64: "amd64",
+	macho.CpuArm:   "arm",
+	macho.CpuArm64: "arm64",
+	macho.CpuPpc:   "ppc",
+	macho.CpuPpc64: "ppc64",
+}
+
+var peArchitectures = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:  "386",
+	pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+	pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+}
+
+// ReadBinaryInfo reads the headers of the file at path and returns its
+// binary format and architecture. Files which are not ELF, Mach-O or PE
+// binaries return nil without an error.
+func ReadBinaryInfo(path string) (*BinaryInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		// Files too short to have a magic number are not binaries
+		return nil, nil
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte(elf.ELFMAG)):
+		return readELFInfo(f)
+	case bytes.HasPrefix(magic, []byte("MZ")):
+		return readPEInfo(f)
+	}
+
+	switch binary.BigEndian.Uint32(magic) {
+	case macho.MagicFat:
+		// Java class files share the magic of fat binaries, they are
+		// told apart by failing to parse as one
+		return readFatMachOInfo(f), nil
+	case macho.Magic32, macho.Magic64:
+		return readMachOInfo(f)
+	}
+	switch binary.LittleEndian.Uint32(magic) {
+	case macho.Magic32, macho.Magic64:
+		return readMachOInfo(f)
+	}
+	return nil, nil
+}
+
+func readELFInfo(r io.ReaderAt) (*BinaryInfo, error) {
+	ef, err := elf.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ELF headers: %w", err)
+	}
+	arch, ok := elfArchitectures[ef.Machine]
+	switch {
+	case ef.Machine == elf.EM_PPC64 && ef.ByteOrder == binary.LittleEndian:
+		arch = "ppc64le"
+	case ef.Machine == elf.EM_PPC64:
+		arch = "ppc64"
+	case ef.Machine == elf.EM_MIPS && ef.ByteOrder == binary.LittleEndian:
+		arch = "mipsle"
+	case !ok:
+		arch = strings.ToLower(strings.TrimPrefix(ef.Machine.String(), "EM_"))
+	}
+	return &BinaryInfo{Format: BinaryFormatELF, Architectures: []string{arch}, Linkage: elfLinkage(ef)}, nil
+}
+
+// elfLinkage returns whether an ELF executable is statically or dynamically
+// linked. Dynamic executables name the loader of their libraries in their
+// PT_INTERP header and list the libraries in their dynamic section. Static
+// PIE executables are shared objects too, they are told apart from the
+// libraries by having an entry point and no soname.
+func elfLinkage(ef *elf.File) string {
+	soname, sonameErr := ef.DynString(elf.DT_SONAME)
+	if ef.Type == elf.ET_DYN && sonameErr == nil && len(soname) > 0 {
+		return ""
+	}
+	for _, prog := range ef.Progs {
+		if prog.Type == elf.PT_INTERP {
+			return BinaryLinkageDynamic
+		}
+	}
+	if needed, err := ef.DynString(elf.DT_NEEDED); err == nil && len(needed) > 0 {
+		return BinaryLinkageDynamic
+	}
+	switch {
+	case ef.Type == elf.ET_EXEC:
+		return BinaryLinkageStatic
+	case ef.Type == elf.ET_DYN && sonameErr == nil && ef.Entry != 0:
+		return BinaryLinkageStatic
+	}
+	return ""
+}
+
+func readPEInfo(r io.ReaderAt) (*BinaryInfo, error) {
+	pf, err := pe.NewFile(r)
+	if err != nil {
+		// DOS executables and other MZ files without a PE header
+		return nil, nil //nolint:nilerr
+	}
+	arch, ok := peArchitectures[pf.Machine]
+	if !ok {
+		arch = fmt.Sprintf("0x%04x", pf.Machine)
+	}
+	return &BinaryInfo{Format: BinaryFormatPE, Architectures: []string{arch}}, nil
+}
+
+func machoArchitecture(cpu macho.Cpu) string {
+	if arch, ok := machoArchitectures[cpu]; ok {
+		return arch
+	}
+	return strings.ToLower(cpu.String())
+}
+
+func readMachOInfo(r io.ReaderAt) (*BinaryInfo, error) {
+	mf, err := macho.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Mach-O headers: %w", err)
+	}
+	return &BinaryInfo{
+		Format: BinaryFormatMachO, Architectures: []string{machoArchitecture(mf.Cpu)},
+	}, nil
+}
+
+func readFatMachOInfo(r io.ReaderAt) *BinaryInfo {
+	ff, err := macho.NewFatFile(r)
+	if err != nil {
+		return nil
+	}
+	info := &BinaryInfo{Format: BinaryFormatMachO, Universal: true}
+	for _, arch := range ff.Arches {
+		info.Architectures = append(info.Architectures, machoArchitecture(arch.Cpu))
+	}
+	return info
+}
+
+// annotateBinaryInfo adds an annotation with the binary format and
+// architecture to files which are executables or libraries
+func annotateBinaryInfo(f *File, path string) error {
+	info, err := ReadBinaryInfo(path)
+	if err != nil {
+		return fmt.Errorf("reading binary headers: %w", err)
+	}
+	if info != nil {
+		f.AddAnnotation(newToolAnnotation(binaryAnnotation + info.String()))
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testELFBinary returns the headers of a 64 bit little endian ELF binary
+func testELFBinary(t *testing.T, machine elf.Machine) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, elf.Header64{
+		Ident: [elf.EI_NIDENT]byte{
+			0x7f, 'E', 'L', 'F', byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT),
+		},
+		Type:    uint16(elf.ET_EXEC),
+		Machine: uint16(machine),
+		Version: uint32(elf.EV_CURRENT),
+		Ehsize:  64,
+	}))
+	return buf.Bytes()
+}
+
+// testDynamicELFBinary returns the headers of a 64 bit little endian ELF
+// binary loaded by the dynamic linker named in its PT_INTERP header
+func testDynamicELFBinary(t *testing.T, machine elf.Machine) []byte {
+	interp := []byte("/lib64/ld-linux-x86-64.so.2\x00")
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, elf.Header64{
+		Ident: [elf.EI_NIDENT]byte{
+			0x7f, 'E', 'L', 'F', byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT),
+		},
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Entry:     0x1000,
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: 56,
+		Phnum:     1,
+	}))
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, elf.Prog64{
+		Type: uint32(elf.PT_INTERP), Off: 120, Filesz: uint64(len(interp)), Memsz: uint64(len(interp)),
+	}))
+	buf.Write(interp)
+	return buf.Bytes()
+}
+
+// testPEBinary returns the DOS stub and COFF header of a PE binary
+func testPEBinary(t *testing.T, machine uint16) []byte {
+	dos := make([]byte, 0x80)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x80)
+	buf := bytes.NewBuffer(dos)
+	buf.WriteString("PE\x00\x00")
+	require.NoError(t, binary.Write(buf, binary.LittleEndian, pe.FileHeader{Machine: machine}))
+	return buf.Bytes()
+}
+
+// testMachOBinary returns the header of a 64 bit Mach-O binary
+func testMachOBinary(t *testing.T, cpu macho.Cpu) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, macho.FileHeader{
+		Magic: macho.Magic64, Cpu: cpu, Type: macho.TypeExec,
+	}))
+	buf.Write(make([]byte, 4)) // Reserved field of 64 bit headers
+	return buf.Bytes()
+}
+
+// testFatMachOBinary returns a universal binary bundling a binary for
+// each of the cpus
+func testFatMachOBinary(t *testing.T, cpus ...macho.Cpu) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.BigEndian, []uint32{macho.MagicFat, uint32(len(cpus))}))
+	offset := uint32(8 + 20*len(cpus))
+	binaries := [][]byte{}
+	for _, cpu := range cpus {
+		bin := testMachOBinary(t, cpu)
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, macho.FatArchHeader{
+			Cpu: cpu, Offset: offset, Size: uint32(len(bin)),
+		}))
+		offset += uint32(len(bin))
+		binaries = append(binaries, bin)
+	}
+	for _, bin := range binaries {
+		buf.Write(bin)
+	}
+	return buf.Bytes()
+}
+
+func TestReadBinaryInfo(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name     string
+		data     []byte
+		expected *BinaryInfo
+	}{
+		{
+			"elf-amd64", testELFBinary(t, elf.EM_X86_64),
+			&BinaryInfo{Format: BinaryFormatELF, Architectures: []string{"amd64"}, Linkage: BinaryLinkageStatic},
+		},
+		{
+			"elf-arm64", testELFBinary(t, elf.EM_AARCH64),
+			&BinaryInfo{Format: BinaryFormatELF, Architectures: []string{"arm64"}, Linkage: BinaryLinkageStatic},
+		},
+		{
+			"elf-dynamic", testDynamicELFBinary(t, elf.EM_X86_64),
+			&BinaryInfo{Format: BinaryFormatELF, Architectures: []string{"amd64"}, Linkage: BinaryLinkageDynamic},
+		},
+		{"app.exe", testPEBinary(t, pe.IMAGE_FILE_MACHINE_AMD64), &BinaryInfo{Format: BinaryFormatPE, Architectures: []string{"amd64"}}},
+		{"macho-arm64", testMachOBinary(t, macho.CpuArm64), &BinaryInfo{Format: BinaryFormatMachO, Architectures: []string{"arm64"}}},
+		{
+			"macho-universal",
+			testFatMachOBinary(t, macho.CpuAmd64, macho.CpuArm64),
+			&BinaryInfo{Format: BinaryFormatMachO, Architectures: []string{"amd64", "arm64"}, Universal: true},
+		},
+		// Java classes start with the same magic number as fat binaries
+		{"Main.class", []byte{0xca, 0xfe, 0xba, 0xbe, 0x00, 0x00, 0x00, 0x34, 0x00, 0x10}, nil},
+		{"README.md", []byte("# Not a binary\n"), nil},
+		{"short", []byte("MZ"), nil},
+	} {
+		path := filepath.Join(dir, tc.name)
+		require.NoError(t, os.WriteFile(path, tc.data, os.FileMode(0o644)))
+		info, err := ReadBinaryInfo(path)
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.expected, info, tc.name)
+	}
+
+	// Truncated headers are an error
+	path := filepath.Join(dir, "truncated")
+	require.NoError(t, os.WriteFile(path, testELFBinary(t, elf.EM_X86_64)[:20], os.FileMode(0o644)))
+	_, err := ReadBinaryInfo(path)
+	require.Error(t, err)
+}
+
+func TestPackageFromDirectoryAnalyzeBinaries(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool"), testELFBinary(t, elf.EM_AARCH64), os.FileMode(0o755)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Tool\n"), os.FileMode(0o644)))
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{AnalyzeBinaries: true}, dir)
+	require.NoError(t, err)
+
+	annotations := map[string][]string{}
+	for _, f := range pkg.Files() {
+		for _, a := range f.Annotations {
+			if strings.HasPrefix(a.Comment, binaryAnnotation) {
+				annotations[f.FileName] = append(annotations[f.FileName], a.Comment)
+			}
+		}
+	}
+	require.Equal(t, map[string][]string{"tool": {binaryAnnotation + "ELF arm64"}}, annotations)
+}
+
+func TestAnalyzeImageLayerLinkage(t *testing.T) {
+	layerPath := filepath.Join(t.TempDir(), "layer.tar")
+	f, err := os.Create(layerPath)
+	require.NoError(t, err)
+	tw := tar.NewWriter(f)
+	for _, entry := range []struct {
+		name string
+		mode int64
+		data []byte
+	}{
+		{"bin/static", 0o755, testELFBinary(t, elf.EM_X86_64)},
+		{"./usr/bin/dynamic", 0o755, testDynamicELFBinary(t, elf.EM_X86_64)},
+		{"lib/data.elf", 0o644, testELFBinary(t, elf.EM_X86_64)},
+		{"bin/script", 0o755, []byte("#!/bin/sh\n")},
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: entry.name, Typeflag: tar.TypeReg, Mode: entry.mode, Size: int64(len(entry.data)),
+		}))
+		_, err := tw.Write(entry.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, f.Close())
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromTarball(&Options{SkipLicenseScan: true}, &TarballOptions{AddFiles: true}, layerPath)
+	require.NoError(t, err)
+	require.NoError(t, impl.AnalyzeImageLayer(layerPath, pkg, &Options{}))
+	for _, f := range pkg.Files() {
+		_, known := f.StaticallyLinked()
+		require.False(t, known, "linkage is only read when asked")
+	}
+	require.NoError(t, annotateLayerLinkage(&Options{}, layerPath, pkg))
+
+	type linkage struct{ static, known bool }
+	linkages := map[string]linkage{}
+	for _, f := range pkg.Files() {
+		static, known := f.StaticallyLinked()
+		linkages[f.Name] = linkage{static, known}
+	}
+	require.Equal(t, map[string]linkage{
+		"bin/static":      {true, true},
+		"usr/bin/dynamic": {false, true},
+		"lib/data.elf":    {false, false}, // Not executable
+		"bin/script":      {false, false},
+	}, linkages)
+}
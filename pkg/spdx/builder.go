@@ -17,11 +17,14 @@ limitations under the License.
 package spdx
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"sigs.k8s.io/release-utils/util"
 )
@@ -37,9 +40,11 @@ type YamlBOMConfiguration struct {
 	Namespace string `yaml:"namespace"`
 	License   string `yaml:"license"` // Document wide license
 	Name      string `yaml:"name"`
+	ID        string `yaml:"id"` // SPDX ID of the document
 	Creator   struct {
-		Person string `yaml:"person"`
-		Tool   string `yaml:"tool"`
+		Person       string `yaml:"person"`
+		Organization string `yaml:"organization"`
+		Tool         string `yaml:"tool"`
 	} `yaml:"creator"`
 	ExternalDocRefs []ExternalDocumentRef `yaml:"external-docs"`
 	Artifacts       []*YamlBuildArtifact  `yaml:"artifacts"`
@@ -87,6 +92,14 @@ type DocBuilder struct {
 // Generate creates a new SPDX SBOM. The resulting document will describe the all
 // artifacts specified in the DocGenerateOptions struct passed.
 func (db *DocBuilder) Generate(genopts *DocGenerateOptions) (*Document, error) {
+	return db.GenerateContext(context.Background(), genopts)
+}
+
+// GenerateContext creates a new SPDX SBOM as Generate does. The images are
+// pulled from their registries with the context, when it is done the
+// downloads are aborted and the generation fails.
+func (db *DocBuilder) GenerateContext(ctx context.Context, genopts *DocGenerateOptions) (*Document, error) {
+	started := time.Now()
 	if err := db.impl.ReadYamlConfiguration(genopts.ConfigFile, genopts); err != nil {
 		return nil, fmt.Errorf("parsing configuration file: %w", err)
 	}
@@ -109,7 +122,11 @@ func (db *DocBuilder) Generate(genopts *DocGenerateOptions) (*Document, error) {
 		return nil, fmt.Errorf("scanning directories: %w", err)
 	}
 
-	if err := db.impl.ScanImages(genopts, spdx, doc); err != nil {
+	if err := db.impl.ScanGitRepositories(ctx, genopts, spdx, doc); err != nil {
+		return nil, fmt.Errorf("scanning git repositories: %w", err)
+	}
+
+	if err := db.impl.ScanImages(ctx, genopts, spdx, doc); err != nil {
 		return nil, fmt.Errorf("scanning images: %w", err)
 	}
 
@@ -121,34 +138,90 @@ func (db *DocBuilder) Generate(genopts *DocGenerateOptions) (*Document, error) {
 		return nil, fmt.Errorf("scanning archives: %w", err)
 	}
 
+	if err := db.impl.ScanGoBinaries(genopts, spdx, doc); err != nil {
+		return nil, fmt.Errorf("scanning go binaries: %w", err)
+	}
+
 	if err := db.impl.ScanFiles(genopts, spdx, doc); err != nil {
 		return nil, fmt.Errorf("scanning files: %w", err)
 	}
 
+	if genopts.RecordInputDigests {
+		if err := recordInputDigests(genopts, doc); err != nil {
+			return nil, fmt.Errorf("recording input digests: %w", err)
+		}
+	}
+
+	if genopts.RecordLicenseTools {
+		if err := spdx.AnnotateLicenseVersions(doc); err != nil {
+			return nil, fmt.Errorf("recording license versions: %w", err)
+		}
+	}
+
+	// Reproducible documents get what changes on every run (their
+	// namespace and dates) derived from their contents and options
+	if genopts.Reproducible {
+		if err := doc.makeReproducible(genopts.ReproducibleSeed, genopts.Created, started); err != nil {
+			return nil, fmt.Errorf("making the document reproducible: %w", err)
+		}
+	}
+
+	doc.Warnings = spdx.Warnings()
 	return doc, nil
 }
 
 type DocGenerateOptions struct {
 	AnalyseLayers       bool                  // A flag that controls if deep layer analysis should be performed
+	DetectLinkage       bool                  // Annotate the ELF executables of analyzed layers with their linkage
+	ScanStaticImages    bool                  // Describe the Go binaries of images built FROM scratch instead of their OS packages
 	NoGitignore         bool                  // Do not read exclusions from gitignore file
+	UseDockerignore     bool                  // Also read exclusions from .dockerignore files
+	GlobalGitignore     bool                  // Also read exclusions from the global excludes file of git
+	FollowSymlinks      bool                  // Scan what symbolic links in directories point to
 	ProcessGoModules    bool                  // Analyze go.mod to include data about packages
+	ProcessNPMModules   bool                  // Read npm and pnpm lockfiles to include the dependencies of node projects
+	ProcessPython       bool                  // Read python requirements and lockfiles to include the dependencies of python projects
 	OnlyDirectDeps      bool                  // Only include direct dependencies from go.mod
+	GoTargetOS          string                // Resolve go dependencies for this GOOS (defaults to the host)
+	GoTargetArch        string                // Resolve go dependencies for this GOARCH (defaults to the host)
+	Platforms           []string              // Platforms (os/arch[/variant]) of the images pulled from indexes (default all)
+	ClassifyGoDeps      bool                  // Relate the test-only and tool go dependencies as such
+	LookupGoImports     bool                  // Look up the go-import meta tags of go modules on vanity import paths
 	ScanLicenses        bool                  // Try to look into files to determine their license
 	ScanImages          bool                  // When true, scan images for OS information
+	CollectWarnings     bool                  // Record the generation warnings in the document Warnings
+	RecordLicenseTools  bool                  // Annotate the document with the license classifier and list versions
+	NormalizeVersions   bool                  // Record package versions in a normalized form for matching
+	RecordInputDigests  bool                  // Annotate the document with the digests of the directories and files scanned
+	SplitProjects       bool                  // Generate a package for each project found in the directories
+	UppercaseChecksums  bool                  // Write the checksum digests in uppercase hex
+	Reproducible        bool                  // Generate the same document, byte for byte, from identical inputs
+	ReproducibleSeed    string                // Seed of the name and namespace of reproducible documents (defaults to a digest of their contents)
+	Created             time.Time             // Creation time of reproducible documents (defaults to SOURCE_DATE_EPOCH or the Unix epoch)
 	ConfigFile          string                // Path to SBOM configuration file
 	Format              string                // Output format
 	OutputFile          string                // Output location
 	Name                string                // Name to use in the resulting document
+	DocumentID          string                // SPDX ID of the document (defaults to SPDXRef-DOCUMENT)
 	Namespace           string                // Namespace for the document (a unique URI)
 	CreatorPerson       string                // Document creator information
+	CreatorOrganization string                // Organization creating the document (defaults to Kubernetes Release Engineering)
 	License             string                // Main license of the document
 	LicenseListVersion  string                // Version of the SPDX list to use
+	DataLicense         string                // License of the document data (defaults to CC0-1.0)
 	Tarballs            []string              // A slice of docker archives (tar)
 	Archives            []string              // A list of archive files to add as packages
+	GoBinaries          []string              // Go binaries to describe with the modules compiled into them
 	Files               []string              // A slice of naked files to include in the bom
 	Images              []string              // A slice of docker images
 	Directories         []string              // A slice of directories to convert into packages
+	GitRepositories     []string              // Git repositories (url[@ref]) to clone and convert into packages
 	IgnorePatterns      []string              // A slice of regexp patterns to ignore when scanning dirs
+	ScanExtensions      []string              // Only scan the files of dirs with these extensions
+	ExcludeExtensions   []string              // Do not scan the files of dirs with these extensions
+	MaxFileSize         int64                 // Do not scan the files of dirs larger than this many bytes
+	Supplier            string                // Supplier of the packages of dirs and tarballs without one
+	Originator          string                // Originator of the packages of dirs and tarballs without one
 	ExternalDocumentRef []ExternalDocumentRef // List of external documents related to the bom
 }
 
@@ -157,7 +230,9 @@ func (o *DocGenerateOptions) Validate() error {
 		len(o.Files) == 0 &&
 		len(o.Images) == 0 &&
 		len(o.Directories) == 0 &&
-		len(o.Archives) == 0 {
+		len(o.GitRepositories) == 0 &&
+		len(o.Archives) == 0 &&
+		len(o.GoBinaries) == 0 {
 		return errors.New(
 			"to build a document at least an image, tarball, directory or a file has to be specified",
 		)
@@ -167,6 +242,33 @@ func (o *DocGenerateOptions) Validate() error {
 		return errors.New("the specified configuration file was not found")
 	}
 
+	// A custom document ID has to be a valid SPDX identifier
+	if o.DocumentID != "" {
+		if !strings.HasPrefix(o.DocumentID, "SPDXRef-") ||
+			validIDCharsRe.MatchString(strings.TrimPrefix(o.DocumentID, "SPDXRef-")) {
+			return fmt.Errorf("invalid document ID %q, it must be an SPDXRef- identifier", o.DocumentID)
+		}
+	}
+
+	// The data license has to be a single license identifier, optionally
+	// followed by + ("or later"), it is looked up in the SPDX license list
+	// when creating the document
+	if o.DataLicense != "" && validIDCharsRe.MatchString(strings.TrimSuffix(o.DataLicense, "+")) {
+		return fmt.Errorf("invalid data license %q, it must be an SPDX license identifier", o.DataLicense)
+	}
+
+	// Suppliers and originators are "Organization: name" or "Person: name (email)"
+	if o.Supplier != "" {
+		if _, _, err := parseEntity(o.Supplier); err != nil {
+			return fmt.Errorf("invalid supplier: %w", err)
+		}
+	}
+	if o.Originator != "" {
+		if _, _, err := parseEntity(o.Originator); err != nil {
+			return fmt.Errorf("invalid originator: %w", err)
+		}
+	}
+
 	// Check namespace is a valid URL
 	if _, err := url.Parse(o.Namespace); err != nil {
 		return fmt.Errorf("parsing the namespace URL: %w", err)
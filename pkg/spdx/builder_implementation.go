@@ -17,6 +17,7 @@ limitations under the License.
 package spdx
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -38,9 +39,11 @@ type DocBuilderImplementation interface {
 	// Document generation functions
 	CreateDocument(*DocGenerateOptions, *SPDX) (*Document, error)
 	ScanDirectories(*DocGenerateOptions, *SPDX, *Document) error
-	ScanImages(*DocGenerateOptions, *SPDX, *Document) error
+	ScanGitRepositories(context.Context, *DocGenerateOptions, *SPDX, *Document) error
+	ScanImages(context.Context, *DocGenerateOptions, *SPDX, *Document) error
 	ScanImageArchives(*DocGenerateOptions, *SPDX, *Document) error
 	ScanArchives(*DocGenerateOptions, *SPDX, *Document) error
+	ScanGoBinaries(*DocGenerateOptions, *SPDX, *Document) error
 	ScanFiles(*DocGenerateOptions, *SPDX, *Document) error
 }
 
@@ -54,6 +57,16 @@ func (builder *defaultDocBuilderImpl) CreateDocument(genopts *DocGenerateOptions
 	// Create the new document
 	doc := NewDocument()
 	doc.Name = genopts.Name
+	if genopts.DocumentID != "" {
+		doc.ID = genopts.DocumentID
+	}
+	doc.UppercaseChecksums = genopts.UppercaseChecksums
+	if genopts.DataLicense != "" {
+		if err := validateDataLicense(spdx, genopts.DataLicense); err != nil {
+			return nil, err
+		}
+		doc.DataLicense = genopts.DataLicense
+	}
 	doc.LicenseListVersion = strings.TrimPrefix(license.DefaultCatalogOpts.Version, "v")
 	if genopts.LicenseListVersion != "" {
 		doc.LicenseListVersion = strings.TrimPrefix(genopts.LicenseListVersion, "v")
@@ -62,25 +75,78 @@ func (builder *defaultDocBuilderImpl) CreateDocument(genopts *DocGenerateOptions
 	// If we do not have a namespace, we generate one under the public SPDX
 	// URL as defined in the spec.
 	// (ref https://spdx.github.io/spdx-spec/document-creation-information/#65-spdx-document-namespace-field)
+	// Reproducible documents get one derived from their contents once
+	// they are generated.
 	doc.Namespace = genopts.Namespace
-	if genopts.Namespace == "" {
+	if genopts.Namespace == "" && !genopts.Reproducible {
 		doc.Namespace = "https://spdx.org/spdxdocs/k8s-releng-bom-" + uuid.NewString()
 	}
 
 	doc.Creator.Person = genopts.CreatorPerson
+	if genopts.CreatorOrganization != "" {
+		doc.Creator.Organization = genopts.CreatorOrganization
+	}
 	doc.ExternalDocRefs = genopts.ExternalDocumentRef
 	return doc, nil
 }
 
+// validateDataLicense checks the data license is in the SPDX license list
+// read by the client. Deprecated identifiers such as GPL-2.0 are still valid
+// SPDX identifiers and are accepted, and a trailing + is not part of the
+// identifier looked up. Licenses defined in the document (LicenseRef-) are
+// not looked up.
+func validateDataLicense(spdx *SPDX, id string) error {
+	if spdx == nil || strings.HasPrefix(id, "LicenseRef-") {
+		return nil
+	}
+	reader, err := spdx.impl.LicenseReader(spdx.Options())
+	if err != nil {
+		return fmt.Errorf("creating license reader: %w", err)
+	}
+	if l := reader.LicenseFromLabel(strings.TrimSuffix(id, "+")); l == nil {
+		return fmt.Errorf("data license %s is not in the SPDX license list", id)
+	}
+	return nil
+}
+
 func (builder *defaultDocBuilderImpl) CreateSPDXClient(genopts *DocGenerateOptions, opts *DocBuilderOptions) (*SPDX, error) {
 	spdx := NewSPDX()
 	if len(genopts.IgnorePatterns) > 0 {
 		spdx.Options().IgnorePatterns = genopts.IgnorePatterns
 	}
+	if len(genopts.ScanExtensions) > 0 {
+		spdx.Options().ScanExtensions = genopts.ScanExtensions
+	}
+	if len(genopts.ExcludeExtensions) > 0 {
+		spdx.Options().ExcludeExtensions = genopts.ExcludeExtensions
+	}
+	spdx.Options().MaxFileSize = genopts.MaxFileSize
+	spdx.Options().DefaultSupplier = genopts.Supplier
+	spdx.Options().DefaultOriginator = genopts.Originator
+	// All the directories go in the same document, their shared go
+	// dependencies are described once
+	spdx.Options().DedupeGoDependencies = true
+	spdx.Options().UseDockerignore = genopts.UseDockerignore
+	spdx.Options().GlobalGitignore = genopts.GlobalGitignore
+	// The output file is left out when written to a scanned directory
+	spdx.Options().SelfFileName = genopts.OutputFile
+	spdx.Options().Reproducible = genopts.Reproducible
+	spdx.Options().FollowSymlinks = genopts.FollowSymlinks
 	spdx.Options().AnalyzeLayers = genopts.AnalyseLayers
+	spdx.Options().DetectBinaryLinkage = genopts.DetectLinkage
+	spdx.Options().ScanStaticImages = genopts.ScanStaticImages
 	spdx.Options().ProcessGoModules = genopts.ProcessGoModules
+	spdx.Options().ProcessNPMModules = genopts.ProcessNPMModules
+	spdx.Options().ProcessPython = genopts.ProcessPython
+	spdx.Options().GoTargetOS = genopts.GoTargetOS
+	spdx.Options().Platforms = genopts.Platforms
+	spdx.Options().GoTargetArch = genopts.GoTargetArch
+	spdx.Options().LookupGoImports = genopts.LookupGoImports
+	spdx.Options().ClassifyGoDeps = genopts.ClassifyGoDeps
 	spdx.Options().ScanImages = genopts.ScanImages
+	spdx.Options().NormalizeVersions = genopts.NormalizeVersions
 	spdx.Options().LicenseListVersion = genopts.LicenseListVersion
+	spdx.Options().CollectWarnings = genopts.CollectWarnings
 
 	if !util.Exists(opts.WorkDir) {
 		if err := os.MkdirAll(opts.WorkDir, os.FileMode(0o755)); err != nil {
@@ -106,24 +172,52 @@ func (builder *defaultDocBuilderImpl) ScanDirectories(genopts *DocGenerateOption
 				continue
 			}
 			logrus.Infof("Processing directory %s", dirMatch)
-			pkg, err := spdx.PackageFromDirectory(dirMatch)
+			var packages []*Package
+			if genopts.SplitProjects {
+				packages, err = spdx.PackagesFromProjects(dirMatch)
+			} else {
+				var pkg *Package
+				pkg, err = spdx.PackageFromDirectory(dirMatch)
+				packages = []*Package{pkg}
+			}
 			if err != nil {
 				return fmt.Errorf("generating package from directory: %w", err)
 			}
-			doc.ensureUniqueElementID(pkg)
-			if err := doc.AddPackage(pkg); err != nil {
-				return fmt.Errorf("adding directory package to document: %w", err)
+			for _, pkg := range packages {
+				doc.ensureUniqueElementID(pkg)
+				if err := doc.AddPackage(pkg); err != nil {
+					return fmt.Errorf("adding directory package to document: %w", err)
+				}
 			}
 		}
 	}
 	return nil
 }
 
-func (builder *defaultDocBuilderImpl) ScanImages(genopts *DocGenerateOptions, spdx *SPDX, doc *Document) error {
+func (builder *defaultDocBuilderImpl) ScanGitRepositories(
+	ctx context.Context, genopts *DocGenerateOptions, spdx *SPDX, doc *Document,
+) error {
+	for _, repository := range genopts.GitRepositories {
+		logrus.Infof("Processing git repository %s", repository)
+		pkg, err := spdx.PackageFromGitRepository(ctx, repository)
+		if err != nil {
+			return fmt.Errorf("generating package from git repository: %w", err)
+		}
+		doc.ensureUniqueElementID(pkg)
+		if err := doc.AddPackage(pkg); err != nil {
+			return fmt.Errorf("adding git repository package to document: %w", err)
+		}
+	}
+	return nil
+}
+
+func (builder *defaultDocBuilderImpl) ScanImages(
+	ctx context.Context, genopts *DocGenerateOptions, spdx *SPDX, doc *Document,
+) error {
 	// Process all image references from registries
 	for _, i := range genopts.Images {
 		logrus.Infof("Processing image reference: %s", i)
-		p, err := spdx.ImageRefToPackage(i)
+		p, err := spdx.ImageRefToPackageContext(ctx, i)
 		if err != nil {
 			return fmt.Errorf("generating SPDX package from image ref %s: %w", i, err)
 		}
@@ -170,6 +264,23 @@ func (builder *defaultDocBuilderImpl) ScanArchives(genopts *DocGenerateOptions,
 	return nil
 }
 
+func (builder *defaultDocBuilderImpl) ScanGoBinaries(genopts *DocGenerateOptions, spdx *SPDX, doc *Document) error {
+	// Describe go binaries from their build information
+	for _, bin := range genopts.GoBinaries {
+		logrus.Infof("Adding go binary as package: %s", bin)
+		p, err := spdx.PackageFromGoBinary(bin)
+		if err != nil {
+			return fmt.Errorf("creating spdx package from go binary: %w", err)
+		}
+		doc.ensureUniqueElementID(p)
+		doc.ensureUniquePeerIDs(p.GetRelationships())
+		if err := doc.AddPackage(p); err != nil {
+			return fmt.Errorf("adding package to document: %w", err)
+		}
+	}
+	return nil
+}
+
 func (builder *defaultDocBuilderImpl) ScanFiles(genopts *DocGenerateOptions, spdx *SPDX, doc *Document) error {
 	// Process single files, not part of a package
 	for _, filePattern := range genopts.Files {
@@ -225,6 +336,10 @@ func (builder *defaultDocBuilderImpl) ReadYamlConfiguration(
 		genopts.Name = conf.Name
 	}
 
+	if conf.ID != "" {
+		genopts.DocumentID = conf.ID
+	}
+
 	if conf.Namespace != "" {
 		genopts.Namespace = conf.Namespace
 	}
@@ -233,6 +348,10 @@ func (builder *defaultDocBuilderImpl) ReadYamlConfiguration(
 		genopts.CreatorPerson = conf.Creator.Person
 	}
 
+	if conf.Creator.Organization != "" {
+		genopts.CreatorOrganization = conf.Creator.Organization
+	}
+
 	if conf.License != "" {
 		genopts.License = conf.License
 	}
@@ -253,6 +372,8 @@ func (builder *defaultDocBuilderImpl) ReadYamlConfiguration(
 			genopts.Files = append(genopts.Files, artifact.Source)
 		case "archive":
 			genopts.Archives = append(genopts.Archives, artifact.Source)
+		case "go-binary":
+			genopts.GoBinaries = append(genopts.GoBinaries, artifact.Source)
 		}
 	}
 
@@ -17,8 +17,11 @@ limitations under the License.
 package spdx
 
 import (
+	"bytes"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -27,8 +30,10 @@ var testConfig = `---
 namespace: http://www.example.com/
 license: Apache-2.0
 name: bom-test
+id: SPDXRef-DOCUMENT-bom-test
 creator:
     person: Kubernetes Release Managers (release-managers@kubernetes.io)
+    organization: Example Org
     tool: bom
 artifacts:
     - type: directory
@@ -64,7 +69,179 @@ func TestYAMLParse(t *testing.T) {
 	require.Equal(t, "tmp/sample-images/kube-apiserver.tar", opts.Tarballs[0])
 
 	require.Equal(t, "Kubernetes Release Managers (release-managers@kubernetes.io)", opts.CreatorPerson)
+	require.Equal(t, "Example Org", opts.CreatorOrganization)
 	require.Equal(t, "http://www.example.com/", opts.Namespace)
 	require.Equal(t, "bom-test", opts.Name)
+	require.Equal(t, "SPDXRef-DOCUMENT-bom-test", opts.DocumentID)
 	require.Equal(t, "Apache-2.0", opts.License)
 }
+
+func TestDocumentID(t *testing.T) {
+	impl := defaultDocBuilderImpl{}
+	genopts := &DocGenerateOptions{
+		Files:      []string{"builder.go"},
+		Name:       "custom-id",
+		DocumentID: "SPDXRef-DOCUMENT-custom",
+	}
+	require.NoError(t, genopts.Validate())
+
+	doc, err := impl.CreateDocument(genopts, nil)
+	require.NoError(t, err)
+	require.Equal(t, "SPDXRef-DOCUMENT-custom", doc.ID)
+
+	pkg := NewPackage()
+	pkg.Name = "test"
+	pkg.ID = "SPDXRef-Package-test"
+	require.NoError(t, doc.AddPackage(pkg))
+	out, err := doc.Render()
+	require.NoError(t, err)
+	require.Contains(t, out, "SPDXID: SPDXRef-DOCUMENT-custom\n")
+	require.Contains(t, out, "Relationship: SPDXRef-DOCUMENT-custom DESCRIBES SPDXRef-Package-test\n")
+
+	// The document ID cannot be reused by its elements
+	clash := NewPackage()
+	clash.Name = "clash"
+	clash.ID = "SPDXRef-DOCUMENT-custom"
+	require.NoError(t, doc.AddPackage(clash))
+	_, err = doc.Render()
+	require.Error(t, err)
+
+	// Invalid identifiers are rejected
+	for _, id := range []string{"DOCUMENT", "SPDXRef-DOC:UMENT", "SPDXRef-with space"} {
+		genopts.DocumentID = id
+		require.Error(t, genopts.Validate(), id)
+	}
+}
+
+func TestCreateDocumentCreationInfo(t *testing.T) {
+	impl := defaultDocBuilderImpl{}
+	genopts := &DocGenerateOptions{
+		Files:               []string{"builder.go"},
+		Name:                "creation-info",
+		CreatorPerson:       "Jane Doe (jane@example.com)",
+		CreatorOrganization: "Example Org",
+	}
+	doc, err := impl.CreateDocument(genopts, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Example Org", doc.Creator.Organization)
+	require.NotEmpty(t, doc.Creator.Tool)
+	require.Regexp(t, "^https://spdx.org/spdxdocs/k8s-releng-bom-[0-9a-f-]{36}$", doc.Namespace)
+
+	pkg := NewPackage()
+	pkg.Name = "test"
+	pkg.ID = "SPDXRef-Package-test"
+	require.NoError(t, doc.AddPackage(pkg))
+	var buf bytes.Buffer
+	n, err := doc.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+	out := buf.String()
+	require.Contains(t, out, "DocumentNamespace: "+doc.Namespace+"\n")
+	require.Contains(t, out, "Creator: Person: Jane Doe (jane@example.com)\n")
+	require.Contains(t, out, "Creator: Organization: Example Org\n")
+	require.Contains(t, out, "Created: "+doc.Created.UTC().Format(time.RFC3339)+"\n")
+}
+
+func TestScanDirectoriesSplitProjects(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"README.md":                         "# Monorepo\n",
+		"api/go.mod":                        "module example.com/api\n\ngo 1.20\n",
+		"api/main.go":                       "package main\n",
+		"web/package.json":                  "{\"name\": \"web\"}\n",
+		"web/index.js":                      "console.log('web')\n",
+		"web/node_modules/dep/package.json": "{\"name\": \"dep\"}\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), os.FileMode(0o644)))
+	}
+
+	roots, err := FindProjectRoots(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "api"), filepath.Join(dir, "web")}, roots)
+
+	spdx := &SPDX{impl: &spdxDefaultImplementation{}, options: &Options{}}
+	genopts := &DocGenerateOptions{Directories: []string{dir}, SplitProjects: true}
+	doc := NewDocument()
+	require.NoError(t, (&defaultDocBuilderImpl{}).ScanDirectories(genopts, spdx, doc))
+	require.Len(t, doc.Packages, 2)
+
+	files := map[string][]string{}
+	for _, pkg := range doc.Packages {
+		for _, f := range pkg.Files() {
+			files[pkg.Name] = append(files[pkg.Name], f.FileName)
+		}
+	}
+	// Go modules are named after their module path
+	require.ElementsMatch(t, []string{"go.mod", "main.go"}, files["example.com/api"])
+	require.Contains(t, files["web"], "package.json")
+	require.Contains(t, files["web"], "index.js")
+}
+
+func TestDataLicense(t *testing.T) {
+	impl := defaultDocBuilderImpl{}
+	genopts := &DocGenerateOptions{
+		Files: []string{"builder.go"},
+		Name:  "data-license",
+	}
+	sut := &SPDX{impl: &spdxDefaultImplementation{}, options: &Options{}}
+
+	// Documents use CC0-1.0 unless set
+	doc, err := impl.CreateDocument(genopts, sut)
+	require.NoError(t, err)
+	out, err := doc.Render()
+	require.NoError(t, err)
+	require.Contains(t, out, "DataLicense: CC0-1.0\n")
+
+	genopts.DataLicense = "MIT"
+	require.NoError(t, genopts.Validate())
+	doc, err = impl.CreateDocument(genopts, sut)
+	require.NoError(t, err)
+	out, err = doc.Render()
+	require.NoError(t, err)
+	require.Contains(t, out, "DataLicense: MIT\n")
+	require.NotContains(t, out, "CC0-1.0")
+
+	// Identifiers can be followed by + ("or later")
+	genopts.DataLicense = "GPL-2.0+"
+	require.NoError(t, genopts.Validate())
+	genopts.DataLicense = "MPL-2.0+"
+	require.NoError(t, genopts.Validate())
+	doc, err = impl.CreateDocument(genopts, sut)
+	require.NoError(t, err)
+	out, err = doc.Render()
+	require.NoError(t, err)
+	require.Contains(t, out, "DataLicense: MPL-2.0+\n")
+	genopts.DataLicense = "GPL-2.0++"
+	require.Error(t, genopts.Validate())
+
+	// Identifiers defined in the document are not looked up
+	genopts.DataLicense = "LicenseRef-internal-data"
+	require.NoError(t, genopts.Validate())
+	_, err = impl.CreateDocument(genopts, sut)
+	require.NoError(t, err)
+
+	// Expressions are not license identifiers
+	genopts.DataLicense = "MIT OR Apache-2.0"
+	require.Error(t, genopts.Validate())
+
+	// Identifiers not in the license list are rejected
+	genopts.DataLicense = "Not-A-License"
+	require.NoError(t, genopts.Validate())
+	_, err = impl.CreateDocument(genopts, sut)
+	require.Error(t, err)
+}
+
+func TestValidateEntities(t *testing.T) {
+	genopts := &DocGenerateOptions{Directories: []string{"."}}
+	genopts.Supplier = "Organization: Example Inc."
+	genopts.Originator = "Person: Jane Doe (jane@example.com)"
+	require.NoError(t, genopts.Validate())
+
+	genopts.Supplier = "Example Inc."
+	require.Error(t, genopts.Validate())
+
+	genopts.Supplier = ""
+	genopts.Originator = "Person: Jane Doe (not an email)"
+	require.Error(t, genopts.Validate())
+}
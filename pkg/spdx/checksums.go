@@ -0,0 +1,187 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha1" //nolint:gosec // SHA1 checksums are only computed for interoperability
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// defaultChecksumAlgorithms are the checksums computed for the files when
+// the options do not list the algorithms
+var defaultChecksumAlgorithms = []string{"SHA1", "SHA256", "SHA512"}
+
+// checksumHashes are the hashes of the checksum algorithms supported
+var checksumHashes = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA384": sha512.New384,
+	"SHA512": sha512.New,
+}
+
+// requiredChecksumAlgorithm is the checksum SPDX 2 requires of every file,
+// it is computed even when the options do not list it
+const requiredChecksumAlgorithm = "SHA1"
+
+// normalizeChecksumAlgorithms returns the algorithms in the form used in
+// the checksums (eg sha-512 is SHA512), or the default ones if there are
+// none. SHA1 is added when missing, the files and the package verification
+// codes cannot be rendered without it. It fails if any of the algorithms
+// is not supported.
+func normalizeChecksumAlgorithms(algorithms []string) ([]string, error) {
+	if len(algorithms) == 0 {
+		return defaultChecksumAlgorithms, nil
+	}
+	normalized := []string{}
+	seen := map[string]struct{}{}
+	for _, algo := range algorithms {
+		name := strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(algo)), "-", "")
+		if _, ok := checksumHashes[name]; !ok {
+			return nil, fmt.Errorf("unsupported checksum algorithm %q", algo)
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		normalized = append(normalized, name)
+	}
+	if _, ok := seen[requiredChecksumAlgorithm]; !ok {
+		normalized = append(normalized, requiredChecksumAlgorithm)
+	}
+	return normalized, nil
+}
+
+// fileChecksums hashes a file with the algorithms, reading it only once
+func fileChecksums(path string, algorithms []string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readerChecksums(f, algorithms)
+}
+
+// readerChecksums hashes the data read from r with the algorithms
+func readerChecksums(r io.Reader, algorithms []string) (map[string]string, error) {
+	hashes, w, err := newChecksumHashes(algorithms)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return nil, err
+	}
+	return hexChecksums(hashes), nil
+}
+
+// newChecksumHashes returns the hashes of the algorithms and a writer
+// feeding the data written to all of them
+func newChecksumHashes(algorithms []string) (map[string]hash.Hash, io.Writer, error) {
+	hashes := make(map[string]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, algo := range algorithms {
+		newHash, ok := checksumHashes[algo]
+		if !ok {
+			return nil, nil, fmt.Errorf("unsupported checksum algorithm %q", algo)
+		}
+		hashes[algo] = newHash()
+		writers = append(writers, hashes[algo])
+	}
+	return hashes, io.MultiWriter(writers...), nil
+}
+
+// hexChecksums returns the hex digests of the hashes
+func hexChecksums(hashes map[string]hash.Hash) map[string]string {
+	checksums := make(map[string]string, len(hashes))
+	for algo, h := range hashes {
+		checksums[algo] = hex.EncodeToString(h.Sum(nil))
+	}
+	return checksums
+}
+
+// hasChecksums returns true if there is a checksum for each algorithm
+func hasChecksums(checksums map[string]string, algorithms []string) bool {
+	for _, algo := range algorithms {
+		if _, ok := checksums[algo]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// setChecksumCase rewrites the checksums of the document elements and of
+// the external document references with their hex digests in the case set
+// in UppercaseChecksums. It runs before rendering the document.
+func (d *Document) setChecksumCase() {
+	for i := range d.ExternalDocRefs {
+		setHexCase(d.ExternalDocRefs[i].Checksums, d.UppercaseChecksums)
+	}
+	seen := map[Object]struct{}{}
+	var walk func(o Object)
+	walk = func(o Object) {
+		if _, ok := seen[o]; ok {
+			return
+		}
+		seen[o] = struct{}{}
+		switch e := o.(type) {
+		case *Package:
+			setHexCase(e.Checksum, d.UppercaseChecksums)
+		case *File:
+			setHexCase(e.Checksum, d.UppercaseChecksums)
+		}
+		for _, rel := range *o.GetRelationships() {
+			if rel.Peer != nil {
+				walk(rel.Peer)
+			}
+		}
+	}
+	for _, p := range d.Packages {
+		walk(p)
+	}
+	for _, f := range d.Files {
+		walk(f)
+	}
+}
+
+// setHexCase changes the hex digests in checksums to upper or lower case
+func setHexCase(checksums map[string]string, upper bool) {
+	for algo, value := range checksums {
+		if upper {
+			checksums[algo] = strings.ToUpper(value)
+		} else {
+			checksums[algo] = strings.ToLower(value)
+		}
+	}
+}
+
+// parsedChecksum returns a checksum read from a document in the form
+// used by bom: the algorithm in upper case and the digest in lower case,
+// whatever the case the document was written in
+func parsedChecksum(algo, value string) (string, string) {
+	return strings.ToUpper(strings.TrimSpace(algo)), strings.ToLower(strings.TrimSpace(value))
+}
+
+// checksumValuesEqual compares two hex digests ignoring their case
+func checksumValuesEqual(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
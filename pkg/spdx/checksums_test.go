@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/release-utils/hash"
+)
+
+func TestPackageFromDirectoryChecksumAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(filePath, []byte("contents\n"), os.FileMode(0o644)))
+	sha1sum, err := hash.SHA1ForFile(filePath)
+	require.NoError(t, err)
+	sha256sum, err := hash.SHA256ForFile(filePath)
+	require.NoError(t, err)
+	sha512sum, err := hash.SHA512ForFile(filePath)
+	require.NoError(t, err)
+
+	checksums := func(opts *Options) map[string]string {
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromDirectory(opts, dir)
+		require.NoError(t, err)
+		require.Len(t, pkg.Files(), 1)
+		return pkg.Files()[0].Checksum
+	}
+
+	// By default the files get the SHA1, SHA256 and SHA512 checksums
+	require.Equal(t, map[string]string{
+		"SHA1": sha1sum, "SHA256": sha256sum, "SHA512": sha512sum,
+	}, checksums(&Options{}))
+
+	// The algorithms are matched ignoring the case and dashes, SHA1 is
+	// always computed
+	require.Equal(t, map[string]string{"SHA1": sha1sum, "SHA512": sha512sum}, checksums(&Options{
+		ChecksumAlgorithms: []string{"sha-512"},
+	}))
+	require.Equal(t, map[string]string{"SHA1": sha1sum, "SHA256": sha256sum}, checksums(&Options{
+		ChecksumAlgorithms: []string{"SHA256", "sha1", "SHA-256"},
+	}))
+
+	// Files from a prior scan are only reused if they have all the checksums
+	impl := spdxDefaultImplementation{}
+	first, err := impl.PackageFromDirectory(&Options{ChecksumAlgorithms: []string{"SHA256"}}, dir)
+	require.NoError(t, err)
+	first.Files()[0].Checksum["SHA256"] = "from-prior-scan"
+	prior := NewDocument()
+	require.NoError(t, prior.AddPackage(first))
+	require.Equal(t, map[string]string{"SHA1": sha1sum, "SHA256": "from-prior-scan"}, checksums(&Options{
+		ChecksumAlgorithms: []string{"SHA256"}, PriorDocument: prior,
+	}))
+	sha384sum := sha512.Sum384([]byte("contents\n"))
+	require.Equal(t, map[string]string{
+		"SHA1": sha1sum, "SHA256": sha256sum, "SHA384": hex.EncodeToString(sha384sum[:]),
+	}, checksums(&Options{
+		ChecksumAlgorithms: []string{"SHA256", "SHA384"}, PriorDocument: prior,
+	}))
+
+	_, err = impl.PackageFromDirectory(&Options{ChecksumAlgorithms: []string{"MD5"}}, dir)
+	require.Error(t, err)
+
+	// Documents of files without SHA1 asked for still render their SHA1
+	// checksums and the package verification code
+	pkg, err := impl.PackageFromDirectory(&Options{ChecksumAlgorithms: []string{"SHA512"}}, dir)
+	require.NoError(t, err)
+	doc := NewDocument()
+	require.NoError(t, doc.AddPackage(pkg))
+	rendered, err := doc.Render()
+	require.NoError(t, err)
+	require.Contains(t, rendered, "FileChecksum: SHA1: "+sha1sum)
+	require.Contains(t, rendered, "FileChecksum: SHA512: "+sha512sum)
+	require.Contains(t, rendered, "PackageVerificationCode: ")
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+// Clone returns a deep copy of the package and of the packages and files
+// related to it, which callers can modify without changing the original,
+// eg a package kept in a cache. The relationships of the copies point to
+// the copied peers. Elements reached through more than one relationship,
+// or through a cycle, are copied once, so the copies keep the shape of the
+// graph. The scan plan of dry runs is shared, it is not copied.
+func (p *Package) Clone() *Package {
+	c := &cloner{clones: map[Object]Object{}}
+	return c.clonePackage(p)
+}
+
+// cloner deep copies a graph of SPDX elements, keeping the copy of each
+// element to reuse it when the element is reached again
+type cloner struct {
+	clones map[Object]Object
+}
+
+func (c *cloner) cloneObject(o Object) Object {
+	switch e := o.(type) {
+	case *Package:
+		if e == nil {
+			return o
+		}
+		return c.clonePackage(e)
+	case *File:
+		if e == nil {
+			return o
+		}
+		return c.cloneFile(e)
+	default:
+		// Elements of other types are shared
+		return o
+	}
+}
+
+func (c *cloner) clonePackage(p *Package) *Package {
+	if p == nil {
+		return nil
+	}
+	if clone, ok := c.clones[p]; ok {
+		return clone.(*Package)
+	}
+	clone := copyPackage(p)
+	c.clones[p] = clone
+
+	// The peers are copied once the package is unlocked, they may lead
+	// back to it
+	c.cloneRelationships(clone.Relationships)
+	return clone
+}
+
+// copyPackage copies all the fields of a package. Its relationships are
+// copied but still point to the original peers.
+func copyPackage(p *Package) *Package {
+	clone := &Package{}
+	p.RLock()
+	defer p.RUnlock()
+	clone.Entity = cloneEntity(&p.Entity)
+	clone.FilesAnalyzed = p.FilesAnalyzed
+	clone.VerificationCode = p.VerificationCode
+	clone.LicenseInfoFromFiles = cloneStrings(p.LicenseInfoFromFiles)
+	clone.LicenseDeclared = p.LicenseDeclared
+	clone.Version = p.Version
+	clone.Comment = p.Comment
+	clone.HomePage = p.HomePage
+	clone.SourceInfo = p.SourceInfo
+	clone.PrimaryPurpose = p.PrimaryPurpose
+	clone.Supplier = p.Supplier
+	clone.Originator = p.Originator
+	if p.ExternalRefs != nil {
+		clone.ExternalRefs = append([]ExternalRef{}, p.ExternalRefs...)
+	}
+	clone.VerificationCodeExcludedFiles = cloneStrings(p.VerificationCodeExcludedFiles)
+	clone.Plan = p.Plan
+	clone.dependencyType = p.dependencyType
+	clone.directoryLicenses = cloneStrings(p.directoryLicenses)
+	return clone
+}
+
+func (c *cloner) cloneFile(f *File) *File {
+	if f == nil {
+		return nil
+	}
+	if clone, ok := c.clones[f]; ok {
+		return clone.(*File)
+	}
+	clone := copyFile(f)
+	c.clones[f] = clone
+	c.cloneRelationships(clone.Relationships)
+	return clone
+}
+
+// copyFile copies all the fields of a file. Its relationships are copied
+// but still point to the original peers.
+func copyFile(f *File) *File {
+	clone := &File{}
+	clone.Entity = cloneEntity(&f.Entity)
+	clone.FileType = cloneStrings(f.FileType)
+	clone.LicenseInfoInFile = f.LicenseInfoInFile
+	clone.Size = f.Size
+	clone.ModTime = f.ModTime
+	return clone
+}
+
+// cloneRelationships points the copied relationships to copies of their peers
+func (c *cloner) cloneRelationships(rels []*Relationship) {
+	for _, rel := range rels {
+		if rel != nil && rel.Peer != nil {
+			rel.Peer = c.cloneObject(rel.Peer)
+		}
+	}
+}
+
+// cloneEntity copies the fields of an entity. The relationships are copied
+// but still point to the original peers.
+func cloneEntity(e *Entity) Entity {
+	clone := *e
+	if e.Opts != nil {
+		opts := *e.Opts
+		opts.ChecksumAlgorithms = cloneStrings(e.Opts.ChecksumAlgorithms)
+		clone.Opts = &opts
+	}
+	if e.Relationships != nil {
+		clone.Relationships = make([]*Relationship, 0, len(e.Relationships))
+		for _, rel := range e.Relationships {
+			if rel == nil {
+				clone.Relationships = append(clone.Relationships, nil)
+				continue
+			}
+			relCopy := *rel
+			clone.Relationships = append(clone.Relationships, &relCopy)
+		}
+	}
+	if e.Checksum != nil {
+		clone.Checksum = make(map[string]string, len(e.Checksum))
+		for algo, sum := range e.Checksum {
+			clone.Checksum[algo] = sum
+		}
+	}
+	if e.Annotations != nil {
+		clone.Annotations = append([]Annotation{}, e.Annotations...)
+	}
+	return clone
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
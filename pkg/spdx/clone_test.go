@@ -0,0 +1,83 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageClone(t *testing.T) {
+	root := NewPackage()
+	root.Name = "root"
+	root.BuildID("root")
+	root.LicenseInfoFromFiles = []string{"Apache-2.0"}
+	root.ExternalRefs = []ExternalRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: "pkg:generic/root@v1"}}
+	root.AddAnnotation(newToolAnnotation("Scanned"))
+
+	f := NewFile()
+	f.Name = "main.go"
+	f.BuildID("root", "main.go")
+	f.Checksum = map[string]string{"SHA256": "abc"}
+	require.NoError(t, root.AddFile(f))
+
+	dep := NewPackage()
+	dep.Name = "dep"
+	dep.BuildID("dep")
+	require.NoError(t, root.AddDependency(dep))
+	// The dependency points back to the root and to the same file
+	dep.AddRelationship(&Relationship{Peer: root, Type: DEPENDENCY_OF})
+	dep.AddRelationship(&Relationship{Peer: f, Type: CONTAINS})
+
+	clone := root.Clone()
+	require.NotSame(t, root, clone)
+	require.Equal(t, "root", clone.Name)
+	require.Equal(t, root.SPDXID(), clone.SPDXID())
+	require.Len(t, clone.Relationships, 2)
+
+	cloneFile, ok := clone.Relationships[0].Peer.(*File)
+	require.True(t, ok)
+	require.NotSame(t, f, cloneFile)
+	cloneDep, ok := clone.Relationships[1].Peer.(*Package)
+	require.True(t, ok)
+	require.NotSame(t, dep, cloneDep)
+
+	// The cycle leads to the clone, the shared file is copied once
+	require.Same(t, clone, cloneDep.Relationships[0].Peer)
+	require.Same(t, cloneFile, cloneDep.Relationships[1].Peer)
+
+	// Changing the copies leaves the originals untouched
+	clone.Name = "changed"
+	clone.LicenseInfoFromFiles[0] = "MIT"
+	clone.ExternalRefs[0].Locator = "pkg:generic/changed"
+	clone.Annotations[0].Comment = "changed"
+	cloneFile.Checksum["SHA256"] = "changed"
+	cloneDep.Relationships[0].Type = DESCRIBES
+	clone.AddRelationship(&Relationship{Peer: NewPackage(), Type: CONTAINS})
+	require.Equal(t, "root", root.Name)
+	require.Equal(t, []string{"Apache-2.0"}, root.LicenseInfoFromFiles)
+	require.Equal(t, "pkg:generic/root@v1", root.ExternalRefs[0].Locator)
+	require.Equal(t, "Scanned", root.Annotations[0].Comment)
+	require.Equal(t, "abc", f.Checksum["SHA256"])
+	require.Equal(t, DEPENDENCY_OF, dep.Relationships[0].Type)
+	require.Len(t, root.Relationships, 2)
+	require.Same(t, root, dep.Relationships[0].Peer)
+
+	// A fresh copy has the same package tree
+	require.True(t, Diff(root, root.Clone()).Empty())
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+// CompareOptions control how packages from different documents are
+// matched and compared, eg when merging or diffing SBOMs
+type CompareOptions struct {
+	// IgnoreNoAssertion makes fields set to NOASSERTION (or left empty)
+	// in one of the packages match any value in the other one, instead
+	// of being reported as a difference
+	IgnoreNoAssertion bool
+}
+
+// noAssertion returns true if a field value makes no assertion
+func noAssertion(value string) bool {
+	return value == "" || value == NOASSERTION
+}
+
+// fieldEqual compares the values of a field in two packages
+func (co *CompareOptions) fieldEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	return co != nil && co.IgnoreNoAssertion && (noAssertion(a) || noAssertion(b))
+}
+
+// IdentityKey returns the key used to match the package with the same
+// package in another document: its name and version. Versions without
+// an assertion are left out of the key when the options ignore them.
+func (p *Package) IdentityKey(opts *CompareOptions) string {
+	version := p.Version
+	if opts != nil && opts.IgnoreNoAssertion && noAssertion(version) {
+		version = ""
+	}
+	return p.Name + "@" + version
+}
+
+// Equal returns true if other describes the same package with the same
+// data. Relationships and SPDX IDs are not compared.
+func (p *Package) Equal(other *Package, opts *CompareOptions) bool {
+	if other == nil {
+		return false
+	}
+	for _, f := range [][2]string{
+		{p.Name, other.Name},
+		{p.Version, other.Version},
+		{p.LicenseConcluded, other.LicenseConcluded},
+		{p.LicenseDeclared, other.LicenseDeclared},
+		{p.DownloadLocation, other.DownloadLocation},
+		{p.CopyrightText, other.CopyrightText},
+		{p.Supplier.Person, other.Supplier.Person},
+		{p.Supplier.Organization, other.Supplier.Organization},
+		{p.Originator.Person, other.Originator.Person},
+		{p.Originator.Organization, other.Originator.Organization},
+	} {
+		if !opts.fieldEqual(f[0], f[1]) {
+			return false
+		}
+	}
+	return checksumsEqual(p.Checksum, other.Checksum, opts)
+}
+
+// checksumsEqual compares the checksums computed with the algorithms
+// found in both lists. Lists sharing no algorithm only match if one of
+// them is empty and the options ignore missing values.
+func checksumsEqual(a, b map[string]string, opts *CompareOptions) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b) || (opts != nil && opts.IgnoreNoAssertion)
+	}
+	shared := 0
+	for algo, value := range a {
+		if otherValue, ok := b[algo]; ok {
+			if !checksumValuesEqual(value, otherValue) {
+				return false
+			}
+			shared++
+		}
+	}
+	return shared > 0
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"sigs.k8s.io/bom/pkg/osinfo"
+	"sigs.k8s.io/release-utils/util"
+)
+
+// BlobSource is a store of image content addressed by digest, such as an
+// OCI image layout or the content stores of containerd and buildkit
+type BlobSource interface {
+	// Blob returns a reader for the blob with the specified digest
+	Blob(digest v1.Hash) (io.ReadCloser, error)
+}
+
+// DirectoryBlobSource reads blobs from a content store directory where
+// they are stored as blobs/<algorithm>/<hex>
+type DirectoryBlobSource struct {
+	Path string // Root directory of the content store
+}
+
+// NewDirectoryBlobSource returns a blob source reading from the
+// content store in path
+func NewDirectoryBlobSource(path string) *DirectoryBlobSource {
+	return &DirectoryBlobSource{Path: path}
+}
+
+// BlobPath returns the path of the file storing a blob
+func (ds *DirectoryBlobSource) BlobPath(digest v1.Hash) string {
+	return filepath.Join(ds.Path, "blobs", digest.Algorithm, digest.Hex)
+}
+
+// Blob opens the file of a blob for reading
+func (ds *DirectoryBlobSource) Blob(digest v1.Hash) (io.ReadCloser, error) {
+	f, err := os.Open(ds.BlobPath(digest))
+	if err != nil {
+		return nil, fmt.Errorf("opening blob %s: %w", digest, err)
+	}
+	return f, nil
+}
+
+// readBlob reads the whole contents of a blob. It is meant for small
+// blobs such as manifests and configs.
+func readBlob(src BlobSource, digest v1.Hash) ([]byte, error) {
+	r, err := src.Blob(digest)
+	if err != nil {
+		return nil, fmt.Errorf("getting blob %s: %w", digest, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", digest, err)
+	}
+	return data, nil
+}
+
+// PackageFromContentStore builds a SPDX package describing the image whose
+// manifest is stored in a content store. The layers are streamed from the
+// store, as the layers pulled from a registry with StreamRegistryImages,
+// instead of pulling the image or exporting it to a tarball. Nothing is
+// extracted or copied to disk.
+func (di *spdxDefaultImplementation) PackageFromContentStore(
+	spdxOpts *Options, src BlobSource, manifestDigest string,
+) (*Package, error) {
+	algorithms, err := normalizeChecksumAlgorithms(spdxOpts.ChecksumAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := v1.NewHash(manifestDigest)
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest digest: %w", err)
+	}
+	logger(spdxOpts).Infof("Generating SPDX package from image %s in content store", digest)
+
+	manifestData, err := readBlob(src, digest)
+	if err != nil {
+		return nil, fmt.Errorf("reading image manifest: %w", err)
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(manifestData))
+	if err != nil {
+		return nil, fmt.Errorf("parsing image manifest: %w", err)
+	}
+	if manifest.MediaType.IsIndex() {
+		return nil, fmt.Errorf("%s is an image index, the digest of an image manifest is required", digest)
+	}
+
+	configData, err := readBlob(src, manifest.Config.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("reading image config: %w", err)
+	}
+	config, err := v1.ParseConfigFile(bytes.NewReader(configData))
+	if err != nil {
+		return nil, fmt.Errorf("parsing image config: %w", err)
+	}
+
+	if streamRegistryImagesUnsupported(spdxOpts) {
+		di.warn(spdxOpts, digest.String(),
+			"The layers of %s are streamed from the content store, the options reading them from disk are not applied", digest)
+	}
+
+	var scanner *osinfo.StreamScanner
+	if spdxOpts.ScanImages {
+		scanner = osinfo.NewStreamScanner()
+	}
+	var diffIDs []v1.Hash
+	if !spdxOpts.SkipLayerVerification && len(config.RootFS.DiffIDs) == len(manifest.Layers) {
+		diffIDs = config.RootFS.DiffIDs
+	}
+
+	// The layers are streamed in order, the OS packages found in a layer
+	// replace the ones of the layers before it
+	logger(spdxOpts).Infof("Streaming the %d layers of %s from the content store", len(manifest.Layers), digest)
+	progress := newProgressCounter(progressFn(spdxOpts), ProgressPhaseLayerScan, len(manifest.Layers))
+	layerPackages := make([]*Package, 0, len(manifest.Layers))
+	for i, layer := range manifest.Layers {
+		ls := &layerStream{
+			algorithms:     algorithms,
+			recordMetadata: spdxOpts.RecordFileMetadata,
+			osScanner:      scanner,
+			layer:          i,
+		}
+		if diffIDs != nil {
+			ls.diffID = diffIDs[i].String()
+		}
+		var pkg *Package
+		if err := di.cpuLimiter.run(spdxOpts, func() error {
+			blob, err := src.Blob(layer.Digest)
+			if err != nil {
+				return fmt.Errorf("getting blob %s: %w", layer.Digest, err)
+			}
+			defer blob.Close()
+			pkg, err = streamLayerBlob(blob, fmt.Sprintf("layer %d", i), ls)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("streaming layer %d: %w", i, err)
+		}
+		pkg.Comment = "Container image layer from content store"
+		pkg.BuildID(digest.String(), pkg.Name)
+		layerPackages = append(layerPackages, pkg)
+		progress.done()
+	}
+	if err := di.addStreamedOSPackages(spdxOpts, scanner, layerPackages); err != nil {
+		return nil, err
+	}
+
+	imagePackage := newConfigImagePackage(digest, config, "content store")
+	if err := addLayerPackages(spdxOpts, imagePackage, layerPackages); err != nil {
+		return nil, err
+	}
+	di.recordImageHistory(spdxOpts, config, manifest.Annotations, imagePackage, layerPackages)
+	return imagePackage, nil
+}
+
+// PackageFromImageFiles builds a SPDX package describing an image from its
+// config file and layer blobs, as written separately by some build systems.
+// The layers are listed in the order they are applied, no tarball or
+// manifest is needed.
+func (di *spdxDefaultImplementation) PackageFromImageFiles(
+	spdxOpts *Options, configPath string, layerPaths []string,
+) (*Package, error) {
+	configData, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading image config: %w", err)
+	}
+	config, err := v1.ParseConfigFile(bytes.NewReader(configData))
+	if err != nil {
+		return nil, fmt.Errorf("parsing image config: %w", err)
+	}
+	if len(config.RootFS.DiffIDs) != 0 && len(config.RootFS.DiffIDs) != len(layerPaths) {
+		return nil, fmt.Errorf(
+			"image config lists %d layers but %d layer files were provided",
+			len(config.RootFS.DiffIDs), len(layerPaths),
+		)
+	}
+	for _, path := range layerPaths {
+		if !util.Exists(path) {
+			return nil, fmt.Errorf("layer file %s not found", path)
+		}
+	}
+
+	// Images are identified by the digest of their config
+	digest, _, err := v1.SHA256(bytes.NewReader(configData))
+	if err != nil {
+		return nil, fmt.Errorf("hashing image config: %w", err)
+	}
+	logger(spdxOpts).Infof("Generating SPDX package from image %s with %d layer files", digest, len(layerPaths))
+	return di.imagePackageFromConfig(spdxOpts, digest, config, layerPaths, "image files")
+}
+
+// imagePackageFromConfig builds the package of an image identified by
+// digest from its config and the paths of its layers. source describes
+// where the image was read from in the package comments.
+func (di *spdxDefaultImplementation) imagePackageFromConfig(
+	spdxOpts *Options, digest v1.Hash, config *v1.ConfigFile, layerPaths []string, source string,
+) (*Package, error) {
+	// As with image archives, the files in the layers are only added
+	// when the layer analyzers are not handling them.
+	tarOpts := &TarballOptions{
+		AddFiles:        spdxOpts.AddTarFiles && !spdxOpts.AnalyzeLayers,
+		TempStorageMode: spdxOpts.TempStorageMode,
+	}
+	layerPackages, err := di.imageLayerPackages(
+		spdxOpts, tarOpts, digest.String(), layerPaths, "Container image layer from "+source,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	imagePackage := newConfigImagePackage(digest, config, source)
+	if err := addLayerPackages(spdxOpts, imagePackage, layerPackages); err != nil {
+		return nil, err
+	}
+	di.recordImageHistory(spdxOpts, config, nil, imagePackage, layerPackages)
+	return imagePackage, nil
+}
+
+// newConfigImagePackage returns the package of the image identified by
+// digest, without its layers. source describes where the image was read
+// from in the package comment.
+func newConfigImagePackage(digest v1.Hash, config *v1.ConfigFile, source string) *Package {
+	imagePackage := NewPackage()
+	imagePackage.Name = digest.String()
+	imagePackage.BuildID(digest.String())
+	imagePackage.Comment = "Container image from " + source
+	if digest.Algorithm == "sha256" {
+		imagePackage.Checksum = map[string]string{"SHA256": digest.Hex}
+	}
+	if config.Architecture == "" && config.OS == "" {
+		imagePackage.AddAnnotation(newToolAnnotation(unknownPlatformAnnotation))
+	}
+	return imagePackage
+}
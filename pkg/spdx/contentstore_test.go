@@ -0,0 +1,162 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromContentStore(t *testing.T) {
+	store := memoryBlobSource{}
+	digest := writeTestContentStore(t, store,
+		"../osinfo/testdata/link-with-no-dots.tar.gz",
+		"../osinfo/testdata/dpkg-layer1.tar.gz",
+	)
+
+	// checkImage verifies the package generated from the image
+	checkImage := func(pkg *Package) {
+		require.Equal(t, digest.String(), pkg.Name)
+		require.Equal(t, digest.Hex, pkg.Checksum["SHA256"])
+		layers := []*Package{}
+		for _, rel := range pkg.Relationships {
+			if p, ok := rel.Peer.(*Package); ok {
+				layers = append(layers, p)
+			}
+		}
+		require.Len(t, layers, 2)
+		for i, layer := range layers {
+			require.Equal(t, "Container image layer from content store", layer.Comment)
+			require.Len(t, layer.Annotations, 1)
+			require.Equal(t, fmt.Sprintf("Build step #%d: step %d", i+1, i), layer.Annotations[0].Comment)
+		}
+		require.Len(t, containedPackages(layers[1]), 83)
+		require.Len(t, layers[1].Files(), 1)
+	}
+
+	// The layers are streamed, nothing is written to disk
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromContentStore(&Options{ScanImages: true}, store, digest.String())
+	require.NoError(t, err)
+	checkImage(pkg)
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// Blobs from a directory store are read in place
+	dir := t.TempDir()
+	for d, data := range store {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "blobs", d.Algorithm), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "blobs", d.Algorithm, d.Hex), data, 0o644))
+	}
+	dirStore := NewDirectoryBlobSource(dir)
+	require.Equal(t, filepath.Join(dir, "blobs", "sha256", digest.Hex), dirStore.BlobPath(digest))
+	pkg, err = impl.PackageFromContentStore(&Options{ScanImages: true}, dirStore, digest.String())
+	require.NoError(t, err)
+	checkImage(pkg)
+
+	// Missing blobs and invalid digests fail
+	_, err = impl.PackageFromContentStore(&Options{}, memoryBlobSource{}, digest.String())
+	require.Error(t, err)
+	_, err = impl.PackageFromContentStore(&Options{}, store, "invalid")
+	require.Error(t, err)
+}
+
+func TestPackageFromContentStorePanicCleanup(t *testing.T) {
+	store := memoryBlobSource{}
+	digest := writeTestContentStore(t, store,
+		"../osinfo/testdata/link-with-no-dots.tar.gz",
+		"../osinfo/testdata/dpkg-layer1.tar.gz",
+	)
+	manifest, err := v1.ParseManifest(bytes.NewReader(store[digest]))
+	require.NoError(t, err)
+
+	// The scan panics after the first layer was streamed
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	src := panickingBlobSource{memoryBlobSource: store, panicDigest: manifest.Layers[1].Digest}
+	impl := spdxDefaultImplementation{}
+	require.Panics(t, func() {
+		impl.PackageFromContentStore(&Options{}, src, digest.String()) //nolint:errcheck
+	})
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+	require.Empty(t, impl.tempPaths.paths)
+}
+
+func TestPackageFromImageFiles(t *testing.T) {
+	layerPaths := []string{
+		"../osinfo/testdata/link-with-no-dots.tar.gz",
+		"../osinfo/testdata/dpkg-layer1.tar.gz",
+	}
+	config := v1.ConfigFile{
+		Architecture: "amd64",
+		OS:           "linux",
+		History: []v1.History{
+			{CreatedBy: "step 0"}, {CreatedBy: "step 1"},
+		},
+	}
+	configData, err := json.Marshal(config)
+	require.NoError(t, err)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configPath, configData, 0o644))
+	digest, _, err := v1.SHA256(bytes.NewReader(configData))
+	require.NoError(t, err)
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromImageFiles(&Options{ScanImages: true}, configPath, layerPaths)
+	require.NoError(t, err)
+	require.Equal(t, digest.String(), pkg.Name)
+	require.Equal(t, digest.Hex, pkg.Checksum["SHA256"])
+	require.Equal(t, "Container image from image files", pkg.Comment)
+	layers := []*Package{}
+	for _, rel := range pkg.Relationships {
+		if p, ok := rel.Peer.(*Package); ok {
+			layers = append(layers, p)
+		}
+	}
+	require.Len(t, layers, 2)
+	for i, layer := range layers {
+		require.Equal(t, "Container image layer from image files", layer.Comment)
+		require.Equal(t, fmt.Sprintf("Build step #%d: step %d", i+1, i), layer.Annotations[0].Comment)
+	}
+	require.Len(t, layers[1].Relationships, 83)
+
+	// The layers must match those listed in the config
+	config.RootFS.DiffIDs = []v1.Hash{digest}
+	configData, err = json.Marshal(config)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(configPath, configData, 0o644))
+	_, err = impl.PackageFromImageFiles(&Options{}, configPath, layerPaths)
+	require.Error(t, err)
+
+	// Missing files fail
+	_, err = impl.PackageFromImageFiles(&Options{}, configPath, []string{"missing.tar.gz"})
+	require.Error(t, err)
+	_, err = impl.PackageFromImageFiles(&Options{}, filepath.Join(t.TempDir(), "missing.json"), layerPaths)
+	require.Error(t, err)
+}
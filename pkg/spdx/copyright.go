@@ -0,0 +1,93 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// copyrightHeaderLines is the number of lines read from the top of a
+// file looking for copyright notices
+const copyrightHeaderLines = 100
+
+// copyrightRe matches copyright notices with a year, such as
+// "Copyright (c) 2021 The Authors". Requiring the year leaves out the
+// mentions of copyright in license texts ("the above copyright notice").
+var copyrightRe = regexp.MustCompile(
+	`(?i)\b(copyright\s+(?:\(c\)\s*|©\s*)?\d{4}(?:\s*[-,]\s*\d{4})*\b.*?)\s*(?:\*/)?$`,
+)
+
+// readFileCopyrights returns the distinct copyright notices found in the
+// header of the file at path
+func readFileCopyrights(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer f.Close()
+
+	notices := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < copyrightHeaderLines && scanner.Scan(); i++ {
+		if m := copyrightRe.FindStringSubmatch(scanner.Text()); m != nil {
+			notices[m[1]] = struct{}{}
+		}
+	}
+	// Long lines (eg minified or binary files) end the header
+	if err := scanner.Err(); err != nil && err != bufio.ErrTooLong {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	return sortedKeys(notices), nil
+}
+
+// readCopyrightText sets the copyright text of the file from the notices
+// found in its source file
+func (f *File) readCopyrightText(path string) error {
+	notices, err := readFileCopyrights(path)
+	if err != nil {
+		return err
+	}
+	if len(notices) > 0 {
+		f.CopyrightText = strings.Join(notices, "\n")
+	}
+	return nil
+}
+
+// AggregateFileCopyrights sets the copyright text of the package to the
+// distinct copyright notices of its files, one per line. The copyright
+// text is left untouched when no file has a copyright notice.
+func (p *Package) AggregateFileCopyrights() {
+	notices := map[string]struct{}{}
+	for _, f := range p.Files() {
+		if noAssertion(f.CopyrightText) || f.CopyrightText == NONE {
+			continue
+		}
+		for _, line := range strings.Split(f.CopyrightText, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				notices[line] = struct{}{}
+			}
+		}
+	}
+	if len(notices) == 0 {
+		return
+	}
+	p.CopyrightText = strings.Join(sortedKeys(notices), "\n")
+}
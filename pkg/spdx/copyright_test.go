@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromDirectoryAggregateCopyrights(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":   "/*\nCopyright 2021 The Kubernetes Authors.\n*/\n\npackage main\n",
+		"util.go":   "// Copyright 2021 The Kubernetes Authors.\n\npackage main\n",
+		"script.py": "# Copyright (c) 2019-2020 Jane Doe <jane@example.com>\nprint('hi')\n",
+		"LICENSE":   "The above copyright notice and this permission notice shall be included\n",
+		"README.md": "# No notices here\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+	}
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{}, dir)
+	require.NoError(t, err)
+	require.Empty(t, pkg.CopyrightText)
+
+	pkg, err = impl.PackageFromDirectory(&Options{AggregateCopyrights: true}, dir)
+	require.NoError(t, err)
+	require.Equal(t,
+		"Copyright (c) 2019-2020 Jane Doe <jane@example.com>\nCopyright 2021 The Kubernetes Authors.",
+		pkg.CopyrightText,
+	)
+	for _, f := range pkg.Files() {
+		switch f.FileName {
+		case "main.go", "util.go":
+			require.Equal(t, "Copyright 2021 The Kubernetes Authors.", f.CopyrightText)
+		case "LICENSE", "README.md":
+			require.Empty(t, f.CopyrightText)
+		}
+	}
+}
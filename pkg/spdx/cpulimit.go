@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"runtime"
+	"sync"
+)
+
+// cpuLimiter caps the number of CPU-bound operations (hashing files and
+// classifying licenses) running at the same time across all the phases of
+// a scan. The throttlers of each phase start goroutines freely, the limiter
+// makes them wait for a slot before doing the expensive work. It caps
+// jobs, not the OS threads of the process: those are bounded by GOMAXPROCS
+// and the threads blocked in system calls.
+//
+// Slots are only taken around operations which don't take other slots, so
+// nested phases (eg the files of a layer of an image) cannot deadlock.
+type cpuLimiter struct {
+	sync.Mutex
+	slots chan struct{}
+}
+
+// maxCPUJobs returns the number of CPU-bound operations allowed to run
+// at the same time. It defaults to GOMAXPROCS.
+func maxCPUJobs(opts *Options) int {
+	if opts != nil && opts.MaxCPUJobs > 0 {
+		return opts.MaxCPUJobs
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// acquire blocks until a slot is free and returns the function to release it
+func (cl *cpuLimiter) acquire(opts *Options) (release func()) {
+	return cl.acquireSlots(maxCPUJobs(opts))
+}
+
+// acquireSlots blocks until one of size slots is free. The limiter is also
+// used to cap other operations than those bound by the CPU, with their own
+// number of slots.
+func (cl *cpuLimiter) acquireSlots(size int) (release func()) {
+	cl.Lock()
+	if cl.slots == nil || cap(cl.slots) != size {
+		cl.slots = make(chan struct{}, size)
+	}
+	slots := cl.slots
+	cl.Unlock()
+
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// run calls fn once a slot is free
+func (cl *cpuLimiter) run(opts *Options, fn func() error) error {
+	release := cl.acquire(opts)
+	defer release()
+	return fn()
+}
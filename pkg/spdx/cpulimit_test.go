@@ -0,0 +1,76 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPULimiter(t *testing.T) {
+	limiter := cpuLimiter{}
+	opts := &Options{MaxCPUJobs: 2}
+	var active, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, limiter.run(opts, func() error {
+				n := atomic.AddInt32(&active, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&active, -1)
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(2), peak)
+
+	// Nested phases only take slots for the leaf operations, so scanning
+	// an image in parallel does not deadlock with a single slot
+	names := []string{}
+	layers := [][]byte{}
+	for i := 0; i < 4; i++ {
+		names = append(names, fmt.Sprintf("layer%d/layer.tar", i))
+		layers = append(layers, testLayerData(t, i, 5))
+	}
+	tarPath := writeTestDockerArchive(t, names, layers)
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromImageTarball(
+		&Options{AddTarFiles: true, LayerWorkers: 4, MaxCPUJobs: 1}, tarPath,
+	)
+	require.NoError(t, err)
+	layerCount := 0
+	for _, rel := range pkg.Relationships {
+		if _, ok := rel.Peer.(*Package); ok {
+			layerCount++
+		}
+	}
+	require.Equal(t, 4, layerCount)
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// daemonSchemes prefix the image references read from the local daemon
+var daemonSchemes = []string{"daemon://", "docker-daemon:"}
+
+// daemonImageSave streams an image from the local container daemon as a
+// docker archive
+var daemonImageSave = func(ctx context.Context, ref name.Reference) (io.ReadCloser, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("creating daemon client: %w", err)
+	}
+	rc, err := cli.ImageSave(ctx, []string{ref.Name()})
+	if err != nil {
+		cli.Close()
+		return nil, err
+	}
+	return &daemonSaveReader{ReadCloser: rc, client: cli}, nil
+}
+
+// daemonSaveReader closes the daemon client with the saved image stream
+type daemonSaveReader struct {
+	io.ReadCloser
+	client *client.Client
+}
+
+func (r *daemonSaveReader) Close() error {
+	err := r.ReadCloser.Close()
+	if cerr := r.client.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// saveDaemonImage streams an image from the local container daemon to an
+// archive in dir, without holding it in memory
+func saveDaemonImage(ctx context.Context, reference name.Reference, dir string) (string, error) {
+	rc, err := daemonImageSave(ctx, reference)
+	if err != nil {
+		return "", fmt.Errorf(
+			"reading image %s from the local container daemon (is it running and reachable?): %w",
+			reference.Name(), err,
+		)
+	}
+	defer rc.Close()
+
+	f, err := os.CreateTemp(dir, "daemon-image-*.tar")
+	if err != nil {
+		return "", fmt.Errorf("creating image archive: %w", err)
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return "", fmt.Errorf("writing image %s to disk: %w", reference.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("closing image archive: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// daemonImageReference returns the reference of an image to read from the
+// local daemon, without its scheme. References are read from the daemon
+// when they have one of the daemon schemes or the options set FromDaemon.
+func daemonImageReference(opts *Options, ref string) (string, bool) {
+	for _, scheme := range daemonSchemes {
+		if daemonRef, ok := strings.CutPrefix(ref, scheme); ok {
+			return daemonRef, true
+		}
+	}
+	return ref, opts != nil && opts.FromDaemon
+}
+
+// packageFromDaemonImage builds the package of an image read from the local
+// container daemon, saving it to an archive in dir to describe it as the
+// images pulled from registries.
+func (di *spdxDefaultImplementation) packageFromDaemonImage(
+	ctx context.Context, opts *Options, ref, dir string,
+) (*Package, error) {
+	reference, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image reference %s: %w", ref, err)
+	}
+
+	stopDownload := di.stats.start(opts, phaseDownload)
+	logger(opts).Infof("Reading image %s from the local container daemon", ref)
+	savedPath, err := saveDaemonImage(ctx, reference, dir)
+	stopDownload()
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := tarball.ImageFromPath(savedPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening image %s saved from the daemon: %w", ref, err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("getting digest of image %s: %w", ref, err)
+	}
+	tarPath := filepath.Join(dir, digest.Hex+".tar")
+	if err := os.Rename(savedPath, tarPath); err != nil {
+		return nil, fmt.Errorf("renaming image archive: %w", err)
+	}
+	img, err = tarball.ImageFromPath(tarPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening image %s saved from the daemon: %w", ref, err)
+	}
+
+	config, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("reading config of image %s: %w", ref, err)
+	}
+	info := &ImageReferenceInfo{
+		Digest:    reference.Context().Digest(digest.String()).String(),
+		Reference: ref,
+		Tag:       referenceTag(reference),
+		Archive:   tarPath,
+		Arch:      config.Architecture,
+		OS:        config.OS,
+		Variant:   config.Variant,
+	}
+	if mediaType, err := img.MediaType(); err == nil {
+		info.MediaType = string(mediaType)
+	}
+	if err := checkImagePlatform(opts, info); err != nil {
+		return nil, err
+	}
+
+	pkg, err := di.referenceInfoToPackage(ctx, opts, info)
+	if err != nil {
+		return nil, fmt.Errorf("generating image package: %w", err)
+	}
+	pkg.BuildID(pkg.Name)
+	pkg.Comment = "Container image read from the local daemon"
+	return pkg, nil
+}
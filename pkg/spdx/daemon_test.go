@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRefToPackageFromDaemon(t *testing.T) {
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{Architecture: "amd64", OS: "linux"})
+	require.NoError(t, err)
+	img, err = mutate.AppendLayers(img, layer)
+	require.NoError(t, err)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	// The daemon saves the image as a docker archive stream
+	defer func(f func(context.Context, name.Reference) (io.ReadCloser, error)) {
+		daemonImageSave = f
+	}(daemonImageSave)
+	requested := []string{}
+	daemonImageSave = func(_ context.Context, ref name.Reference) (io.ReadCloser, error) {
+		requested = append(requested, ref.String())
+		r, w := io.Pipe()
+		go func() { w.CloseWithError(tarball.Write(ref, img, w)) }()
+		return r, nil
+	}
+
+	impl := spdxDefaultImplementation{}
+	for _, tc := range []struct {
+		ref  string
+		opts *Options
+	}{
+		{"daemon://registry.example.com/test/image:v1", &Options{}},
+		{"docker-daemon:registry.example.com/test/image:v1", &Options{}},
+		{"registry.example.com/test/image:v1", &Options{FromDaemon: true}},
+	} {
+		pkg, err := impl.ImageRefToPackage(context.Background(), tc.ref, tc.opts)
+		require.NoError(t, err, tc.ref)
+		require.Equal(t, digest.String(), pkg.Name)
+		require.Equal(t, digest.Hex, pkg.Checksum["SHA256"])
+		require.Len(t, containedPackages(pkg), 1)
+		require.NotNil(t, pkg.Purl())
+		require.Equal(t, "amd64", pkg.Purl().Qualifiers.Map()["arch"])
+	}
+	require.Equal(t, []string{
+		"registry.example.com/test/image:v1",
+		"registry.example.com/test/image:v1",
+		"registry.example.com/test/image:v1",
+	}, requested)
+
+	// Dry runs do not read the image from the daemon
+	pkg, err := impl.ImageRefToPackage(context.Background(), "daemon://registry.example.com/test/image:v1", &Options{DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, requested, 3)
+	require.NotNil(t, pkg.Plan)
+	require.Equal(t, "registry.example.com/test/image:v1", pkg.Plan.Images.Reference)
+	require.Equal(t, "v1", pkg.Plan.Images.Tag)
+	require.Empty(t, pkg.Plan.Layers)
+
+	// Failing to reach the daemon is reported as such
+	daemonImageSave = func(context.Context, name.Reference) (io.ReadCloser, error) {
+		return nil, errors.New("cannot connect to the Docker daemon")
+	}
+	_, err = impl.ImageRefToPackage(context.Background(), "daemon://registry.example.com/test/image:v1", &Options{})
+	require.ErrorContains(t, err, "local container daemon")
+	require.ErrorContains(t, err, "cannot connect to the Docker daemon")
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"sort"
+)
+
+// SBOMDiff lists the changes in the packages of an SBOM from another one
+type SBOMDiff struct {
+	Added   []DiffPackage   `json:"added,omitempty"`   // Packages only in the new SBOM
+	Removed []DiffPackage   `json:"removed,omitempty"` // Packages only in the old SBOM
+	Changed []VersionChange `json:"changed,omitempty"` // Packages found in both with a different version
+	Moved   []ParentChange  `json:"moved,omitempty"`   // Packages found in both under different parents
+}
+
+// DiffPackage is a package reported in an SBOMDiff
+type DiffPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Purl    string `json:"purl,omitempty"`
+	License string `json:"license,omitempty"` // Declared license, or the concluded one if not declared
+}
+
+// VersionChange is a package whose version changed
+type VersionChange struct {
+	From DiffPackage `json:"from"`
+	To   DiffPackage `json:"to"`
+}
+
+// ParentChange is a package related to different parent packages. The
+// parents are identified by their purl without the version, or their
+// name when they have no purl.
+type ParentChange struct {
+	Package        DiffPackage `json:"package"`
+	AddedParents   []string    `json:"addedParents,omitempty"`
+	RemovedParents []string    `json:"removedParents,omitempty"`
+}
+
+// Empty returns true if the SBOMs have the same packages
+func (d *SBOMDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Moved) == 0
+}
+
+// Diff compares the package trees of two SBOMs, from a to b. Packages are
+// matched by purl, or by name and version when they have no purl. The
+// packages left unmatched whose purl (without the version) or name is
+// found in the other tree are reported as version changes.
+func Diff(a, b *Package) *SBOMDiff {
+	return DiffWithOptions(a, b, nil)
+}
+
+// DiffWithOptions compares the package trees of two SBOMs as Diff does,
+// matching and comparing the packages with opts. When the options ignore
+// missing values, packages without a version match the same package with
+// a version, and are not reported as version changes. The trees are
+// copied first, the diff is computed on a consistent snapshot of them.
+func DiffWithOptions(a, b *Package, opts *CompareOptions) *SBOMDiff {
+	oldNodes := diffNodes(a.Clone(), opts)
+	newNodes := diffNodes(b.Clone(), opts)
+	diff := &SBOMDiff{}
+
+	// Pairs of the matched packages, to compare their parents
+	matched := [][2]*diffNode{}
+	for _, key := range sortedDiffKeys(oldNodes) {
+		if n, ok := newNodes[key]; ok {
+			matched = append(matched, [2]*diffNode{oldNodes[key], n})
+			delete(oldNodes, key)
+			delete(newNodes, key)
+		}
+	}
+
+	// Pair the packages left by their versionless keys
+	added := map[string][]*diffNode{}
+	for _, key := range sortedDiffKeys(newNodes) {
+		n := newNodes[key]
+		added[n.baseKey] = append(added[n.baseKey], n)
+	}
+	for _, key := range sortedDiffKeys(oldNodes) {
+		o := oldNodes[key]
+		if candidates := added[o.baseKey]; len(candidates) > 0 {
+			n := candidates[0]
+			added[o.baseKey] = candidates[1:]
+			if !opts.fieldEqual(o.pkg.Version, n.pkg.Version) {
+				diff.Changed = append(diff.Changed, VersionChange{From: o.diffPackage(), To: n.diffPackage()})
+			}
+			matched = append(matched, [2]*diffNode{o, n})
+			delete(newNodes, n.key)
+			continue
+		}
+		diff.Removed = append(diff.Removed, o.diffPackage())
+	}
+	for _, key := range sortedDiffKeys(newNodes) {
+		diff.Added = append(diff.Added, newNodes[key].diffPackage())
+	}
+
+	for _, pair := range matched {
+		addedParents := missingKeys(pair[1].parents, pair[0].parents)
+		removedParents := missingKeys(pair[0].parents, pair[1].parents)
+		if len(addedParents) == 0 && len(removedParents) == 0 {
+			continue
+		}
+		diff.Moved = append(diff.Moved, ParentChange{
+			Package:        pair[1].diffPackage(),
+			AddedParents:   addedParents,
+			RemovedParents: removedParents,
+		})
+	}
+	sort.Slice(diff.Moved, func(i, j int) bool {
+		return diff.Moved[i].Package.Name+"@"+diff.Moved[i].Package.Version <
+			diff.Moved[j].Package.Name+"@"+diff.Moved[j].Package.Version
+	})
+	return diff
+}
+
+// diffNode is a package of a tree being compared
+type diffNode struct {
+	key     string              // Purl, or name and version
+	baseKey string              // Purl without the version, or name
+	pkg     *Package            // First package found with the key
+	parents map[string]struct{} // Base keys of the packages related to it
+}
+
+func (n *diffNode) diffPackage() DiffPackage {
+	dp := DiffPackage{
+		Name:    n.pkg.Name,
+		Version: n.pkg.Version,
+		License: n.pkg.LicenseDeclared,
+	}
+	if noAssertion(dp.License) {
+		dp.License = n.pkg.LicenseConcluded
+	}
+	if noAssertion(dp.License) {
+		dp.License = ""
+	}
+	if pu := n.pkg.Purl(); pu != nil {
+		dp.Purl = pu.ToString()
+	}
+	return dp
+}
+
+// diffKeys returns the keys matching a package in the other tree
+func diffKeys(p *Package, opts *CompareOptions) (key, baseKey string) {
+	pu := p.Purl()
+	if pu == nil {
+		return p.IdentityKey(opts), p.Name
+	}
+	key = pu.ToString()
+	versionless := *pu
+	versionless.Version = ""
+	return key, versionless.ToString()
+}
+
+// diffNodes collects the packages of a tree by key
+func diffNodes(root *Package, opts *CompareOptions) map[string]*diffNode {
+	nodes := map[string]*diffNode{}
+	if root == nil {
+		return nodes
+	}
+	seen := map[*Package]struct{}{}
+	var walk func(p *Package)
+	walk = func(p *Package) {
+		if _, ok := seen[p]; ok {
+			return
+		}
+		seen[p] = struct{}{}
+		key, baseKey := diffKeys(p, opts)
+		n, ok := nodes[key]
+		if !ok {
+			n = &diffNode{key: key, baseKey: baseKey, pkg: p, parents: map[string]struct{}{}}
+			nodes[key] = n
+		}
+		for _, rel := range p.Relationships {
+			peer, ok := rel.Peer.(*Package)
+			if !ok || peer == nil {
+				continue
+			}
+			peerKey, _ := diffKeys(peer, opts)
+			walk(peer)
+			nodes[peerKey].parents[baseKey] = struct{}{}
+		}
+	}
+	walk(root)
+	return nodes
+}
+
+func sortedDiffKeys(nodes map[string]*diffNode) []string {
+	keys := make([]string, 0, len(nodes))
+	for key := range nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// missingKeys returns the sorted keys of a not found in b
+func missingKeys(a, b map[string]struct{}) []string {
+	missing := []string{}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	newPkg := func(name, version, purlString string) *Package {
+		p := NewPackage()
+		p.Name = name
+		p.Version = version
+		p.BuildID(name, version)
+		if purlString != "" {
+			p.ExternalRefs = append(p.ExternalRefs, ExternalRef{
+				Category: CatPackageManager, Type: "purl", Locator: purlString,
+			})
+		}
+		return p
+	}
+
+	// The old tree: app -> logrus, yaml -> util, lib
+	oldApp := newPkg("app", "v1.0.0", "pkg:golang/example.com/app@v1.0.0")
+	oldYAML := newPkg("yaml", "v2.4.0", "pkg:golang/gopkg.in/yaml.v2@v2.4.0")
+	require.NoError(t, oldYAML.AddDependency(newPkg("util", "0.1", "")))
+	for _, p := range []*Package{
+		newPkg("logrus", "v1.8.1", "pkg:golang/github.com/sirupsen/logrus@v1.8.1"),
+		oldYAML,
+		newPkg("lib", "1.0", ""),
+	} {
+		require.NoError(t, oldApp.AddDependency(p))
+	}
+
+	// The new tree: app -> logrus, cobra -> yaml, lib
+	newApp := newPkg("app", "v1.1.0", "pkg:golang/example.com/app@v1.1.0")
+	cobra := newPkg("cobra", "v1.7.0", "pkg:golang/github.com/spf13/cobra@v1.7.0")
+	cobra.LicenseDeclared = "GPL-3.0-only"
+	require.NoError(t, cobra.AddDependency(newPkg("yaml", "v2.4.0", "pkg:golang/gopkg.in/yaml.v2@v2.4.0")))
+	for _, p := range []*Package{
+		newPkg("logrus", "v1.9.0", "pkg:golang/github.com/sirupsen/logrus@v1.9.0"),
+		cobra,
+		newPkg("lib", "1.0", ""),
+	} {
+		require.NoError(t, newApp.AddDependency(p))
+	}
+
+	diff := Diff(oldApp, newApp)
+	require.False(t, diff.Empty())
+	require.Equal(t, []DiffPackage{
+		{Name: "cobra", Version: "v1.7.0", Purl: "pkg:golang/github.com/spf13/cobra@v1.7.0", License: "GPL-3.0-only"},
+	}, diff.Added)
+	require.Equal(t, []DiffPackage{{Name: "util", Version: "0.1"}}, diff.Removed)
+	require.Equal(t, []VersionChange{
+		{
+			From: DiffPackage{Name: "app", Version: "v1.0.0", Purl: "pkg:golang/example.com/app@v1.0.0"},
+			To:   DiffPackage{Name: "app", Version: "v1.1.0", Purl: "pkg:golang/example.com/app@v1.1.0"},
+		},
+		{
+			From: DiffPackage{Name: "logrus", Version: "v1.8.1", Purl: "pkg:golang/github.com/sirupsen/logrus@v1.8.1"},
+			To:   DiffPackage{Name: "logrus", Version: "v1.9.0", Purl: "pkg:golang/github.com/sirupsen/logrus@v1.9.0"},
+		},
+	}, diff.Changed)
+	require.Equal(t, []ParentChange{
+		{
+			Package:        DiffPackage{Name: "yaml", Version: "v2.4.0", Purl: "pkg:golang/gopkg.in/yaml.v2@v2.4.0"},
+			AddedParents:   []string{"pkg:golang/github.com/spf13/cobra"},
+			RemovedParents: []string{"pkg:golang/example.com/app"},
+		},
+	}, diff.Moved)
+
+	// The diff serializes to JSON
+	data, err := json.Marshal(diff)
+	require.NoError(t, err)
+	decoded := &SBOMDiff{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+	require.Equal(t, diff, decoded)
+	require.Contains(t, string(data), `"license":"GPL-3.0-only"`)
+
+	// A tree has no differences with itself
+	require.True(t, Diff(newApp, newApp).Empty())
+	require.Len(t, Diff(nil, newApp).Added, 5)
+
+	// Versions without an assertion are changes unless the options
+	// ignore them
+	unversioned := newPkg("app", "v1.1.0", "pkg:golang/example.com/app@v1.1.0")
+	require.NoError(t, unversioned.AddDependency(newPkg("lib", NOASSERTION, "")))
+	versioned := newPkg("app", "v1.1.0", "pkg:golang/example.com/app@v1.1.0")
+	require.NoError(t, versioned.AddDependency(newPkg("lib", "1.0", "")))
+	require.Len(t, Diff(unversioned, versioned).Changed, 1)
+	require.True(t, DiffWithOptions(unversioned, versioned, &CompareOptions{IgnoreNoAssertion: true}).Empty())
+	unversioned.Relationships[0].Peer.(*Package).Version = ""
+	require.True(t, DiffWithOptions(unversioned, versioned, &CompareOptions{IgnoreNoAssertion: true}).Empty())
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// vendorDir is the directory holding the vendored go modules
+const vendorDir = "vendor"
+
+// groupDirectoryFiles moves the files of a directory package to packages
+// describing its top-level directories and the go modules vendored under
+// vendor/ (as listed in vendor/modules.txt). Each directory package is
+// contained by the package of its parent directory, files at the top of
+// the directory stay in pkg.
+func groupDirectoryFiles(pkg *Package, dirPath string) error {
+	modules, err := readVendoredModules(filepath.Join(dirPath, vendorDir, "modules.txt"))
+	if err != nil {
+		return fmt.Errorf("reading vendored modules: %w", err)
+	}
+
+	dirPackages := map[string]*Package{}
+	var dirPackage func(dir string) (*Package, error)
+	dirPackage = func(dir string) (*Package, error) {
+		if p, ok := dirPackages[dir]; ok {
+			return p, nil
+		}
+		parent := pkg
+		p := NewPackage()
+		p.Name = dir
+		p.Comment = "Directory " + dir
+		if modulePath := strings.TrimPrefix(dir, vendorDir+"/"); modulePath != dir {
+			vendorPackage, err := dirPackage(vendorDir)
+			if err != nil {
+				return nil, err
+			}
+			parent = vendorPackage
+			p.Name = modulePath
+			p.Version = modules[modulePath]
+			p.Comment = "Go module vendored in " + dir
+		}
+		p.BuildID(pkg.Name, dir)
+		if err := parent.AddPackage(p); err != nil {
+			return nil, fmt.Errorf("adding package of directory %s: %w", dir, err)
+		}
+		dirPackages[dir] = p
+		return p, nil
+	}
+
+	// Files are grouped in name order so the packages are always created
+	// in the same order
+	files := pkg.Files()
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	moved := map[Object]struct{}{}
+	for _, f := range files {
+		dir := fileDirectoryGroup(f.Name, modules)
+		if dir == "" {
+			continue
+		}
+		p, err := dirPackage(dir)
+		if err != nil {
+			return err
+		}
+		if err := p.AddFile(f); err != nil {
+			return fmt.Errorf("adding file to the package of directory %s: %w", dir, err)
+		}
+		moved[f] = struct{}{}
+	}
+	if len(moved) == 0 {
+		return nil
+	}
+
+	pkg.Lock()
+	rels := []*Relationship{}
+	for _, rel := range pkg.Relationships {
+		if _, ok := moved[rel.Peer]; ok && rel.Type == CONTAINS {
+			continue
+		}
+		rels = append(rels, rel)
+	}
+	pkg.Relationships = rels
+	pkg.Unlock()
+
+	// Only the packages holding files have them analyzed, as the
+	// verification code is computed from the files they contain
+	pkg.FilesAnalyzed = len(pkg.Files()) > 0
+	for _, p := range dirPackages {
+		p.FilesAnalyzed = len(p.Files()) > 0
+	}
+	return nil
+}
+
+// fileDirectoryGroup returns the directory whose package gets the file at
+// path: its top-level directory or, under vendor/, the vendored module
+// holding it. Files at the top of the tree return an empty string.
+func fileDirectoryGroup(path string, modules map[string]string) string {
+	elems := strings.Split(filepath.ToSlash(path), "/")
+	if len(elems) < 2 {
+		return ""
+	}
+	if elems[0] == vendorDir {
+		// The longest module path wins, modules can be nested
+		for i := len(elems) - 1; i > 1; i-- {
+			if _, ok := modules[strings.Join(elems[1:i], "/")]; ok {
+				return vendorDir + "/" + strings.Join(elems[1:i], "/")
+			}
+		}
+	}
+	return elems[0]
+}
+
+// readVendoredModules returns the versions of the go modules listed in
+// a vendor/modules.txt file by their paths. A missing file lists none.
+func readVendoredModules(path string) (map[string]string, error) {
+	modules := map[string]string{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return modules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Modules are listed as "# path version [=> replacement]"
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "#" {
+			continue
+		}
+		version := ""
+		if len(fields) > 2 && fields[2] != "=>" {
+			version = fields[2]
+		}
+		modules[fields[1]] = version
+	}
+	return modules, scanner.Err()
+}
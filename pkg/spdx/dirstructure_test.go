@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromDirectoryStructure(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"main.go":                          "package main\n",
+		"cmd/a.go":                         "package cmd\n",
+		"cmd/sub/b.go":                     "package sub\n",
+		"vendor/modules.txt":               "# github.com/foo/bar v1.0.0\n## explicit\ngithub.com/foo/bar\n",
+		"vendor/github.com/foo/bar/x.go":   "package bar\n",
+		"vendor/github.com/foo/bar/y/z.go": "package y\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), os.FileMode(0o644)))
+	}
+
+	// Files are listed flat by default
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{}, dir)
+	require.NoError(t, err)
+	require.Len(t, pkg.Files(), 6)
+	require.Empty(t, containedPackages(pkg))
+
+	pkg, err = impl.PackageFromDirectory(&Options{IncludeDirectoryStructure: true}, dir)
+	require.NoError(t, err)
+	require.True(t, pkg.FilesAnalyzed)
+	require.Len(t, pkg.Files(), 1)
+	require.Equal(t, "main.go", pkg.Files()[0].Name)
+	require.Len(t, containedPackages(pkg), 2)
+
+	fileNames := func(p *Package) []string {
+		names := []string{}
+		for _, f := range p.Files() {
+			names = append(names, f.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	var cmdPackage, vendorPackage *Package
+	for _, p := range containedPackages(pkg) {
+		switch p.Name {
+		case "cmd":
+			cmdPackage = p
+		case "vendor":
+			vendorPackage = p
+		}
+	}
+	require.NotNil(t, cmdPackage)
+	require.Equal(t, []string{"cmd/a.go", "cmd/sub/b.go"}, fileNames(cmdPackage))
+
+	require.NotNil(t, vendorPackage)
+	require.Equal(t, []string{"vendor/modules.txt"}, fileNames(vendorPackage))
+	require.Len(t, containedPackages(vendorPackage), 1)
+	for _, modulePackage := range containedPackages(vendorPackage) {
+		require.Equal(t, "github.com/foo/bar", modulePackage.Name)
+		require.Equal(t, "v1.0.0", modulePackage.Version)
+		require.True(t, modulePackage.FilesAnalyzed)
+		require.Equal(t, []string{
+			"vendor/github.com/foo/bar/x.go", "vendor/github.com/foo/bar/y/z.go",
+		}, fileNames(modulePackage))
+	}
+}
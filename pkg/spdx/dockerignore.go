@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// dockerIgnoreFile lists the files left out of a docker build context
+const dockerIgnoreFile = ".dockerignore"
+
+// readDockerignorePatterns reads the .dockerignore file at the root of
+// dirPath and returns its rules as gitignore patterns. It returns no
+// patterns when the file does not exist.
+//
+// The rules of both files look alike but docker matches them differently:
+//
+//   - Patterns are always relative to the root of the build context, so
+//     *.log only matches the files at the root (gitignore matches them in
+//     any directory). The patterns are anchored with a leading slash.
+//   - Patterns are cleaned before matching, so a trailing slash does not
+//     restrict them to directories: build/ matches a file called build and
+//     the contents of a build directory.
+//   - ! negates a pattern, re-including the files excluded by the rules
+//     before it, as in gitignore. The .dockerignore rules are read after
+//     IgnorePatterns and before the .gitignore rules, which can exclude
+//     the files again.
+//   - ** matches any number of directories, as in gitignore.
+func readDockerignorePatterns(dirPath string) ([]string, error) {
+	f, err := os.Open(filepath.Join(dirPath, dockerIgnoreFile))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening dockerignore file: %w", err)
+	}
+	defer f.Close()
+
+	rules, err := ReadIgnorePatterns(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading dockerignore file: %w", err)
+	}
+	patterns := []string{}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		negate := ""
+		if strings.HasPrefix(rule, "!") {
+			negate = "!"
+			rule = strings.TrimSpace(rule[1:])
+		}
+		rule = path.Clean(filepath.ToSlash(rule))
+		rule = strings.TrimPrefix(rule, "/")
+		if rule == "." || rule == "" {
+			continue
+		}
+		patterns = append(patterns, negate+"/"+rule)
+	}
+	return patterns, nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromDirectoryDockerignore(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		dockerIgnoreFile: "# Build outputs\n*.log\n!keep.log\nbuild/\n/docs/**/*.md\n",
+		"main.go":        "package main\n",
+		"debug.log":      "log\n",
+		"keep.log":       "log\n",
+		"sub/nested.log": "log\n",
+		"build/main.out": "binary\n",
+		"docs/a/b.md":    "# doc\n",
+		"docs/notes.txt": "notes\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+	}
+
+	patterns, err := readDockerignorePatterns(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/*.log", "!/keep.log", "/build", "/docs/**/*.md"}, patterns)
+
+	scannedFiles := func(opts *Options) []string {
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromDirectory(opts, dir)
+		require.NoError(t, err)
+		files := []string{}
+		for _, f := range pkg.Files() {
+			files = append(files, f.Name)
+		}
+		sort.Strings(files)
+		return files
+	}
+
+	// The .dockerignore file is only read when set in the options
+	require.Len(t, scannedFiles(&Options{}), 8)
+
+	// Patterns are relative to the root of the directory (sub/nested.log
+	// is kept), the negation re-includes keep.log and build/ also
+	// matches the directory contents
+	require.Equal(t, []string{
+		".dockerignore", "docs/notes.txt", "keep.log", "main.go", "sub/nested.log",
+	}, scannedFiles(&Options{UseDockerignore: true}))
+
+	// Without the file, nothing is excluded
+	require.NoError(t, os.Remove(filepath.Join(dir, dockerIgnoreFile)))
+	require.Len(t, scannedFiles(&Options{UseDockerignore: true}), 7)
+}
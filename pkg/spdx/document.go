@@ -29,11 +29,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"html/template"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
@@ -50,7 +52,7 @@ import (
 
 var docTemplate = `{{ if .Version }}SPDXVersion: {{.Version}}
 {{ end -}}
-DataLicense: CC0-1.0
+DataLicense: {{ if .DataLicense }}{{ .DataLicense }}{{ else }}CC0-1.0{{ end }}
 {{ if .ID }}SPDXID: {{ .ID }}
 {{ end -}}
 {{ if .Name }}DocumentName: {{ .Name }}
@@ -74,6 +76,13 @@ ExternalDocumentRef:{{ extDocFormat $value }}
 {{ end -}}
 {{ if .LicenseListVersion }}LicenseListVersion: {{ .LicenseListVersion }}
 {{ end -}}
+{{ range $key, $value := .Annotations -}}
+Annotator: {{ $value.Annotator }}
+AnnotationDate: {{ $value.Date }}
+AnnotationType: {{ $value.Type }}
+SPDXREF: {{ $.ID }}
+AnnotationComment: <text>{{ $value.Comment }}</text>
+{{ end -}}
 {{ if .Created }}Created: {{ dateFormat .Created }}
 {{ end }}
 
@@ -102,6 +111,10 @@ type Document struct {
 	Packages           map[string]*Package
 	Files              map[string]*File      // List of files
 	ExternalDocRefs    []ExternalDocumentRef // List of related external documents
+	Annotations        []Annotation          // Annotations recorded about the document
+	InputDigests       map[string]string     // Digests of the inputs scanned, by path (see AddInputDigest)
+	Warnings           []Warning             // Problems found while generating the document (not serialized)
+	UppercaseChecksums bool                  // Render checksums with uppercase hex digits (SPDX uses lowercase)
 }
 
 // ExternalDocumentRef is a pointer to an external, related document
@@ -202,6 +215,11 @@ func (d *Document) AddPackage(pkg *Package) error {
 	return nil
 }
 
+// AddAnnotation records an annotation about the document
+func (d *Document) AddAnnotation(annotation Annotation) {
+	d.Annotations = append(d.Annotations, annotation)
+}
+
 // Write outputs the SPDX document into a file
 func (d *Document) Write(path string) error {
 	content, err := d.Render()
@@ -215,6 +233,19 @@ func (d *Document) Write(path string) error {
 	return nil
 }
 
+// WriteTo renders the SPDX document to w
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	content, err := d.Render()
+	if err != nil {
+		return 0, fmt.Errorf("rendering SPDX code: %w", err)
+	}
+	n, err := io.WriteString(w, content)
+	if err != nil {
+		return int64(n), fmt.Errorf("writing SPDX code: %w", err)
+	}
+	return int64(n), nil
+}
+
 // Render reders the spdx manifest
 func (d *Document) Render() (doc string, err error) {
 	var buf bytes.Buffer
@@ -224,9 +255,8 @@ func (d *Document) Render() (doc string, err error) {
 		"extDocFormat": func(ed ExternalDocumentRef) string { logrus.Infof("External doc: %s", ed.ID); return ed.String() },
 	}
 
-	if d.Name == "" {
-		d.Name = "SBOM-SPDX-" + uuid.New().String()
-		logrus.Warnf("Document has no name defined, automatically set to " + d.Name)
+	if err := d.prepare(); err != nil {
+		return "", err
 	}
 
 	tmpl, err := template.New("document").Funcs(funcMap).Parse(docTemplate)
@@ -249,7 +279,8 @@ func (d *Document) Render() (doc string, err error) {
 		filesDescribed = "\n"
 	}
 
-	for _, file := range d.Files {
+	for _, id := range d.sortedFileIDs() {
+		file := d.Files[id]
 		fileDoc, err := file.Render()
 		if err != nil {
 			return "", fmt.Errorf("rendering file "+file.Name+" :%w", err)
@@ -260,7 +291,8 @@ func (d *Document) Render() (doc string, err error) {
 	doc += filesDescribed
 
 	// Cycle all packages and get their data
-	for _, pkg := range d.Packages {
+	for _, id := range d.sortedPackageIDs() {
+		pkg := d.Packages[id]
 		pkgDoc, err := pkg.Render()
 		if err != nil {
 			return "", fmt.Errorf("rendering pkg "+pkg.Name+" :%w", err)
@@ -273,6 +305,153 @@ func (d *Document) Render() (doc string, err error) {
 	return doc, err
 }
 
+// prepare sets the document data needed before rendering it
+func (d *Document) prepare() error {
+	if d.Name == "" {
+		d.Name = "SBOM-SPDX-" + uuid.New().String()
+		logrus.Warnf("Document has no name defined, automatically set to " + d.Name)
+	}
+
+	// The document ID cannot be shared with any of its elements
+	if d.ID != "" && d.GetElementByID(d.ID) != nil {
+		return fmt.Errorf("document ID %s is also used by one of its elements", d.ID)
+	}
+
+	// Sort the document elements to get the same output on every run
+	d.Canonicalize()
+	d.setChecksumCase()
+	return nil
+}
+
+// Finalize completes the document as rendering it does, without building
+// the rendered document: the elements missing an ID get one, the files
+// missing checksums are read, the relationships are sorted and the depth
+// of the package trees is checked. Serializers writing the elements one at
+// a time call it first instead of Render.
+func (d *Document) Finalize() error {
+	if err := d.prepare(); err != nil {
+		return err
+	}
+	seen := map[Object]struct{}{}
+	var finalize func(o Object) error
+	finalize = func(o Object) error {
+		if _, ok := seen[o]; ok {
+			return nil
+		}
+		seen[o] = struct{}{}
+		switch e := o.(type) {
+		case *Package:
+			if err := e.CheckRelationships(); err != nil {
+				return fmt.Errorf("checking package relationships: %w", err)
+			}
+		case *File:
+			if len(e.Checksum) == 0 && e.SourceFile != "" {
+				if err := e.ReadSourceFile(e.SourceFile); err != nil {
+					return fmt.Errorf("checksumming file %s: %w", e.Name, err)
+				}
+			}
+		}
+		for _, rel := range *o.GetRelationships() {
+			if rel.Peer == nil {
+				continue
+			}
+			if err := finalize(rel.Peer); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, id := range d.sortedFileIDs() {
+		if err := finalize(d.Files[id]); err != nil {
+			return err
+		}
+	}
+	for _, id := range d.sortedPackageIDs() {
+		if err := checkRelationshipDepth(relationshipDepthLimit(d.Packages[id]), d.Packages[id], 0); err != nil {
+			return err
+		}
+		if err := finalize(d.Packages[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Canonicalize sorts the relationships of all the elements in the
+// document (by type and then by the path of files or the SPDX ID of
+// other peers) and the external document references by ID. As packages
+// and files are rendered sorted by ID, two documents describing the same
+// artifacts render to the same output regardless of the order in which
+// their elements were added.
+func (d *Document) Canonicalize() {
+	sort.SliceStable(d.ExternalDocRefs, func(i, j int) bool {
+		return d.ExternalDocRefs[i].ID < d.ExternalDocRefs[j].ID
+	})
+
+	seen := map[Object]struct{}{}
+	for _, id := range d.sortedPackageIDs() {
+		canonicalizeRelationships(d.Packages[id], seen)
+	}
+	for _, id := range d.sortedFileIDs() {
+		canonicalizeRelationships(d.Files[id], seen)
+	}
+}
+
+// canonicalizeRelationships sorts the relationships of an object and,
+// recursively, those of its peers
+func canonicalizeRelationships(o Object, seen map[Object]struct{}) {
+	if _, ok := seen[o]; ok {
+		return
+	}
+	seen[o] = struct{}{}
+
+	rels := *o.GetRelationships()
+	sort.SliceStable(rels, func(i, j int) bool {
+		if rels[i].Type != rels[j].Type {
+			return rels[i].Type < rels[j].Type
+		}
+		return relationshipSortKey(rels[i]) < relationshipSortKey(rels[j])
+	})
+	for _, rel := range rels {
+		if rel.Peer != nil {
+			canonicalizeRelationships(rel.Peer, seen)
+		}
+	}
+}
+
+// relationshipSortKey returns the string used to order relationships of
+// the same type: the path of file peers or the ID of any other peer
+func relationshipSortKey(rel *Relationship) string {
+	if f, ok := rel.Peer.(*File); ok {
+		return f.FileName + "\x00" + f.SPDXID()
+	}
+	key := rel.PeerExtReference + ":" + rel.PeerReference
+	if rel.Peer != nil {
+		key += "\x00" + rel.Peer.SPDXID()
+	}
+	return key
+}
+
+// sortedPackageIDs returns the IDs of the top level packages, sorted
+func (d *Document) sortedPackageIDs() []string {
+	ids := make([]string, 0, len(d.Packages))
+	for id := range d.Packages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sortedFileIDs returns the IDs of the top level files, sorted
+func (d *Document) sortedFileIDs() []string {
+	ids := make([]string, 0, len(d.Files))
+	for id := range d.Files {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // AddFile adds a file contained in the package
 func (d *Document) AddFile(file *File) error {
 	if d.Files == nil {
@@ -380,6 +559,8 @@ var DefaultProvenanceOptions = &ProvenanceOptions{
 		"exclude": {
 			EXAMPLE_OF,
 			DEPENDS_ON,
+			TEST_DEPENDENCY_OF,
+			BUILD_TOOL_OF,
 		},
 	},
 }
@@ -402,6 +583,28 @@ func (d *Document) ToProvenanceStatement(opts *ProvenanceOptions) *provenance.St
 	return statement
 }
 
+// Subject is an artifact and its digests, as listed in the subject of
+// in-toto statements such as SLSA provenance attestations
+type Subject = intoto.Subject
+
+// Subjects returns the top level packages and files of the document as
+// statement subjects, to attest the artifacts the SBOM describes. Elements
+// without checksums or a location to name them are skipped.
+func (d *Document) Subjects() []Subject {
+	subjects := []Subject{}
+	for _, id := range d.sortedPackageIDs() {
+		if sub := d.Packages[id].ToProvenanceSubject(); sub != nil {
+			subjects = append(subjects, *sub)
+		}
+	}
+	for _, id := range d.sortedFileIDs() {
+		if sub := d.Files[id].ToProvenanceSubject(); sub != nil {
+			subjects = append(subjects, *sub)
+		}
+	}
+	return subjects
+}
+
 // WriteProvenanceStatement writes the sbom as an in-toto provenance statement
 func (d *Document) WriteProvenanceStatement(opts *ProvenanceOptions, path string) error {
 	statement := d.ToProvenanceStatement(opts)
@@ -522,6 +725,36 @@ func (d *Document) GetPackagesByPurl(purlSpec *purl.PackageURL, opts ...PurlSear
 	return foundPackages
 }
 
+// DescribedIDs returns the sorted IDs of the top level packages and
+// files of the document, the elements the document DESCRIBES
+func (d *Document) DescribedIDs() []string {
+	ids := []string{}
+	for _, id := range d.sortedPackageIDs() {
+		ids = append(ids, d.Packages[id].SPDXID())
+	}
+	for _, id := range d.sortedFileIDs() {
+		ids = append(ids, d.Files[id].SPDXID())
+	}
+	return ids
+}
+
+// ValidateDescribes checks that the document declares the elements it
+// describes. Documents parsed from SBOMs lacking both the documentDescribes
+// list and DESCRIBES relationships from the document have no top level
+// elements and fail the check.
+func (d *Document) ValidateDescribes() error {
+	ids := d.DescribedIDs()
+	if len(ids) == 0 {
+		return errors.New("document has no DESCRIBES relationships")
+	}
+	for _, id := range ids {
+		if id == "" {
+			return errors.New("document describes an element without an SPDX ID")
+		}
+	}
+	return nil
+}
+
 type ValidationResults struct {
 	Success          bool
 	Message          string
@@ -613,7 +846,7 @@ func (d *Document) ValidateFiles(filePaths []string) ([]ValidationResults, error
 			checks := 0
 			for algo, documentHashValue := range docFile.Checksum {
 				if artifactHashValue, ok := testFile.Checksum[algo]; ok {
-					if artifactHashValue == documentHashValue {
+					if checksumValuesEqual(artifactHashValue, documentHashValue) {
 						checks++
 						valid = true
 					} else {
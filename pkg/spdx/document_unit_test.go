@@ -17,11 +17,16 @@ limitations under the License.
 package spdx
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
@@ -327,3 +332,160 @@ func TestGetPackagesByPurl(t *testing.T) {
 		require.Equal(t, tc.len, len(packages), tc.purl)
 	}
 }
+
+func TestCanonicalize(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, fmt.Sprintf("file%02d.txt", i)),
+			[]byte(fmt.Sprintf("data %d", i)), os.FileMode(0o644),
+		))
+	}
+
+	created := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	renderDoc := func() string {
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromDirectory(&Options{}, dir)
+		require.NoError(t, err)
+		// Shuffle the files to simulate them being added in a different order
+		rels := pkg.Relationships
+		rand.Shuffle(len(rels), func(i, j int) { rels[i], rels[j] = rels[j], rels[i] })
+
+		doc := NewDocument()
+		doc.Name = "canonical"
+		doc.Namespace = "https://example.com/canonical"
+		doc.Created = created
+		require.NoError(t, doc.AddPackage(pkg))
+		doc.Canonicalize()
+
+		files := pkg.Files()
+		for i := 1; i < len(files); i++ {
+			require.Less(t, files[i-1].FileName, files[i].FileName)
+		}
+
+		out, err := doc.Render()
+		require.NoError(t, err)
+		return out
+	}
+
+	require.Equal(t, renderDoc(), renderDoc())
+}
+
+func TestValidateDescribes(t *testing.T) {
+	doc := NewDocument()
+	require.Error(t, doc.ValidateDescribes())
+
+	pkg := NewPackage()
+	pkg.Name = "root"
+	pkg.BuildID("root")
+	require.NoError(t, doc.AddPackage(pkg))
+	require.NoError(t, doc.ValidateDescribes())
+	require.Equal(t, []string{pkg.SPDXID()}, doc.DescribedIDs())
+
+	// The rendered document declares the package it describes
+	out, err := doc.Render()
+	require.NoError(t, err)
+	require.Contains(t, out, fmt.Sprintf("Relationship: %s DESCRIBES %s\n", doc.ID, pkg.SPDXID()))
+}
+
+func TestSubjects(t *testing.T) {
+	doc := NewDocument()
+
+	image := NewPackage()
+	image.Name = "sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57"
+	image.BuildID(image.Name)
+	image.DownloadLocation = "registry.example.com/image@" + image.Name
+	image.Checksum = map[string]string{"SHA256": "c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57"}
+
+	// Subpackages are not subjects of the document
+	layer := NewPackage()
+	layer.Name = "layer"
+	layer.BuildID(layer.Name)
+	layer.DownloadLocation = "layer.tar.gz"
+	layer.Checksum = map[string]string{"SHA256": "a78c2d6208eff9b672de43f880093100050983047b7b0afe0217d3656e1b0d5f"}
+	require.NoError(t, image.AddPackage(layer))
+	require.NoError(t, doc.AddPackage(image))
+
+	f := NewFile()
+	f.Name = "bin/tool"
+	f.FileName = "bin/tool"
+	f.BuildID(f.Name)
+	f.Checksum = map[string]string{"SHA1": "da39a3ee5e6b4b0d3255bfef95601890afd80709"}
+	require.NoError(t, doc.AddFile(f))
+
+	// Elements without checksums are skipped
+	unhashed := NewPackage()
+	unhashed.Name = "unhashed"
+	unhashed.BuildID(unhashed.Name)
+	unhashed.DownloadLocation = "https://example.com/unhashed.tar.gz"
+	require.NoError(t, doc.AddPackage(unhashed))
+
+	subjects := doc.Subjects()
+	require.Len(t, subjects, 2)
+	require.Equal(t, image.DownloadLocation, subjects[0].Name)
+	require.Equal(t, "c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57", subjects[0].Digest["sha256"])
+	require.Equal(t, "bin/tool", subjects[1].Name)
+	require.Equal(t, "da39a3ee5e6b4b0d3255bfef95601890afd80709", subjects[1].Digest["sha1"])
+}
+
+func TestUppercaseChecksums(t *testing.T) {
+	fileSHA1 := fmt.Sprintf("%x", sha1.Sum([]byte("abc")))
+	fileSHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte("abc")))
+	pkgSHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte("package")))
+
+	newDoc := func(upper bool) *Document {
+		doc := NewDocument()
+		doc.Name = "checksums"
+		doc.Namespace = "https://example.com/checksums"
+		doc.UppercaseChecksums = upper
+		pkg := NewPackage()
+		pkg.Name = "pkg"
+		pkg.BuildID("pkg")
+		pkg.FilesAnalyzed = true
+		pkg.Checksum = map[string]string{"SHA256": pkgSHA256}
+		f := NewFile()
+		f.Name = "file.txt"
+		f.BuildID("file.txt")
+		f.Checksum = map[string]string{"SHA1": fileSHA1, "SHA256": fileSHA256}
+		require.NoError(t, pkg.AddFile(f))
+		require.NoError(t, doc.AddPackage(pkg))
+		return doc
+	}
+
+	lower, err := newDoc(false).Render()
+	require.NoError(t, err)
+	require.Contains(t, lower, "PackageChecksum: SHA256: "+pkgSHA256)
+	require.Contains(t, lower, "FileChecksum: SHA1: "+fileSHA1)
+
+	upperDoc := newDoc(true)
+	upper, err := upperDoc.Render()
+	require.NoError(t, err)
+	require.Contains(t, upper, "PackageChecksum: SHA256: "+strings.ToUpper(pkgSHA256))
+	require.Contains(t, upper, "FileChecksum: SHA1: "+strings.ToUpper(fileSHA1))
+	require.Contains(t, upper, "FileChecksum: SHA256: "+strings.ToUpper(fileSHA256))
+	require.NotContains(t, upper, fileSHA1)
+
+	// The verification code does not depend on the case of the checksums
+	lowerCode := strings.Split(strings.Split(lower, "PackageVerificationCode: ")[1], "\n")[0]
+	upperCode := strings.Split(strings.Split(upper, "PackageVerificationCode: ")[1], "\n")[0]
+	require.Equal(t, lowerCode, upperCode)
+
+	// Parsing the uppercase document reads the checksums in lowercase
+	path := filepath.Join(t.TempDir(), "upper.spdx")
+	require.NoError(t, os.WriteFile(path, []byte(upper), os.FileMode(0o644)))
+	parsed, err := OpenDoc(path)
+	require.NoError(t, err)
+	require.Len(t, parsed.Packages, 1)
+	var pkg *Package
+	for _, p := range parsed.Packages {
+		pkg = p
+	}
+	require.Equal(t, map[string]string{"SHA256": pkgSHA256}, pkg.Checksum)
+	require.Len(t, pkg.Files(), 1)
+	require.Equal(t, map[string]string{"SHA1": fileSHA1, "SHA256": fileSHA256}, pkg.Files()[0].Checksum)
+
+	// Checksums differing only in case compare as equal
+	require.True(t, checksumsEqual(
+		map[string]string{"SHA256": fileSHA256}, map[string]string{"SHA256": strings.ToUpper(fileSHA256)}, nil,
+	))
+}
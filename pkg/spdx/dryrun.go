@@ -0,0 +1,144 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// ScanPlan lists what a scan would read. The packages generated with the
+// DryRun option carry it instead of the results of the scan.
+type ScanPlan struct {
+	// Files of a directory, after applying the ignore patterns and filters
+	Files []string
+
+	// Images resolved from an image reference, only those of the
+	// platforms selected when it points to an index
+	Images *ImageReferenceInfo
+
+	// Layers that would be pulled, by the digest reference of their image
+	Layers map[string][]PlannedLayer
+}
+
+// PlannedLayer is an image layer that a scan would pull
+type PlannedLayer struct {
+	Digest    string // Digest of the compressed layer
+	Size      int64  // Compressed size in bytes
+	MediaType string // Media type of the layer
+}
+
+// imageScanPlan resolves the images of a reference and the layers of each
+// one, reading only their manifests
+func (di *spdxDefaultImplementation) imageScanPlan(
+	ctx context.Context, opts *Options, ref string,
+) (*Package, error) {
+	references, err := di.resolveImageReferences(ctx, opts, ref)
+	if err != nil {
+		return nil, err
+	}
+	remoteOpts, err := remoteOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ScanPlan{Images: references, Layers: map[string][]PlannedLayer{}}
+	images := []string{references.Digest}
+	if len(references.Images) > 0 {
+		images = []string{}
+		for i := range references.Images {
+			images = append(images, references.Images[i].Digest)
+		}
+	}
+	for _, digest := range images {
+		layers, err := imageLayersPlan(ctx, opts, digest, remoteOpts)
+		if err != nil {
+			return nil, err
+		}
+		plan.Layers[digest] = layers
+	}
+
+	pkg := NewPackage()
+	pkg.Name = references.Digest
+	if d, err := name.NewDigest(references.Digest); err == nil {
+		pkg.Name = d.DigestStr()
+	}
+	pkg.BuildID(pkg.Name)
+	pkg.DownloadLocation = references.Digest
+	pkg.Plan = plan
+	return pkg, nil
+}
+
+// daemonScanPlan returns the package planning the scan of an image of the
+// local container daemon. Reading its manifest would export the whole image
+// from the daemon, so the plan names the image without listing its layers.
+func daemonScanPlan(ref string) (*Package, error) {
+	reference, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image reference %s: %w", ref, err)
+	}
+	pkg := NewPackage()
+	pkg.Name = reference.String()
+	pkg.BuildID(pkg.Name)
+	pkg.Plan = &ScanPlan{
+		Images: &ImageReferenceInfo{Reference: ref, Tag: referenceTag(reference)},
+		Layers: map[string][]PlannedLayer{},
+	}
+	return pkg, nil
+}
+
+// imageLayersPlan reads the layers listed in the manifest of an image
+func imageLayersPlan(
+	ctx context.Context, opts *Options, digest string, remoteOpts []remote.Option,
+) ([]PlannedLayer, error) {
+	ref, err := name.NewDigest(digest)
+	if err != nil {
+		return nil, fmt.Errorf("parsing digest %s: %w", digest, err)
+	}
+	var manifest *v1.Manifest
+	if err := withRegistryRetries(ctx, opts, "reading manifest of "+digest, func() error {
+		if err := waitRegistryRequest(ctx, opts); err != nil {
+			return err
+		}
+		desc, err := remote.Get(ref, remoteOpts...)
+		if err != nil {
+			return fmt.Errorf("fetching manifest: %w", err)
+		}
+		manifest, err = v1.ParseManifest(bytes.NewReader(desc.Manifest))
+		if err != nil {
+			return fmt.Errorf("parsing manifest of %s: %w", digest, err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	layers := []PlannedLayer{}
+	for _, layer := range manifest.Layers {
+		layers = append(layers, PlannedLayer{
+			Digest:    layer.Digest.String(),
+			Size:      layer.Size,
+			MediaType: string(layer.MediaType),
+		})
+	}
+	return layers, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromDirectoryDryRun(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "go.mod", "docs/index.md", "pkg/lib.go"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("module example.com/app\n"), os.FileMode(0o644)))
+	}
+
+	sut := NewSPDX()
+	sut.impl = &spdxDefaultImplementation{}
+	sut.Options().DryRun = true
+	sut.Options().CollectStats = true
+	sut.Options().ProcessGoModules = true
+	sut.Options().IgnorePatterns = []string{"docs/"}
+	defer func() {
+		sut.Options().DryRun = false
+		sut.Options().CollectStats = false
+		sut.Options().IgnorePatterns = nil
+	}()
+	pkg, err := sut.PackageFromDirectory(dir)
+	require.NoError(t, err)
+	require.NotNil(t, pkg.Plan)
+	sort.Strings(pkg.Plan.Files)
+	require.Equal(t, []string{"go.mod", "main.go", "pkg/lib.go"}, pkg.Plan.Files)
+
+	// Nothing is read, nor are the go dependencies resolved
+	require.Empty(t, pkg.Files())
+	require.Empty(t, pkg.Relationships)
+	require.Zero(t, sut.Stats().FileScan)
+	require.Zero(t, sut.Stats().LicenseClassification)
+}
+
+func TestImageRefToPackageDryRun(t *testing.T) {
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	layerDigest, err := layer.Digest()
+	require.NoError(t, err)
+	layerSize, err := layer.Size()
+	require.NoError(t, err)
+
+	var blobRequests atomic.Int32
+	regHandler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/blobs/"+layerDigest.String()) {
+			blobRequests.Add(1)
+		}
+		regHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	var index v1.ImageIndex = empty.Index
+	for _, arch := range []string{"amd64", "arm64"} {
+		img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{OS: "linux", Architecture: arch})
+		require.NoError(t, err)
+		img, err = mutate.AppendLayers(img, layer)
+		require.NoError(t, err)
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: arch}},
+		})
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/index:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, index))
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.ImageRefToPackage(
+		context.Background(), ref.String(), &Options{DryRun: true, Platforms: []string{"linux/arm64"}},
+	)
+	require.NoError(t, err)
+	require.NotNil(t, pkg.Plan)
+	require.Len(t, pkg.Plan.Images.Images, 1)
+	require.Equal(t, "arm64", pkg.Plan.Images.Images[0].Arch)
+	require.Equal(t, map[string][]PlannedLayer{
+		pkg.Plan.Images.Images[0].Digest: {{
+			Digest: layerDigest.String(), Size: layerSize, MediaType: string(types.DockerLayer),
+		}},
+	}, pkg.Plan.Layers)
+
+	// The layers are not downloaded
+	require.Zero(t, blobRequests.Load())
+	require.Empty(t, pkg.Relationships)
+}
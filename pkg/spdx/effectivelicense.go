@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"sort"
+	"strings"
+)
+
+// LicenseCount is a license found in a document and the number of
+// elements (packages and files) released under it
+type LicenseCount struct {
+	License string // SPDX license identifier (eg "Apache-2.0" or "GPL-2.0-only WITH Classpath-exception-2.0")
+	Count   int    // Number of elements concluded or declared to use the license
+}
+
+// EffectiveLicenses rolls up the licenses of all the packages and files in
+// the document. Compound expressions are split into the licenses they
+// reference. The list is sorted by count, most used licenses first.
+func (d *Document) EffectiveLicenses() []LicenseCount {
+	counts := map[string]int{}
+	seen := map[Object]struct{}{}
+	var collect func(Object)
+	collect = func(o Object) {
+		if _, ok := seen[o]; ok {
+			return
+		}
+		seen[o] = struct{}{}
+		counted := map[string]struct{}{}
+		for _, l := range licenseIDs(elementLicense(o)) {
+			if _, ok := counted[l]; !ok {
+				counted[l] = struct{}{}
+				counts[l]++
+			}
+		}
+		for _, rel := range *o.GetRelationships() {
+			if rel.Peer != nil {
+				collect(rel.Peer)
+			}
+		}
+	}
+	for _, id := range d.sortedPackageIDs() {
+		collect(d.Packages[id])
+	}
+	for _, id := range d.sortedFileIDs() {
+		collect(d.Files[id])
+	}
+
+	licenses := make([]LicenseCount, 0, len(counts))
+	for l, c := range counts {
+		licenses = append(licenses, LicenseCount{License: l, Count: c})
+	}
+	sort.Slice(licenses, func(i, j int) bool {
+		if licenses[i].Count != licenses[j].Count {
+			return licenses[i].Count > licenses[j].Count
+		}
+		return licenses[i].License < licenses[j].License
+	})
+	return licenses
+}
+
+// EffectiveLicenseExpression returns a license expression combining with
+// AND all the distinct licenses in the document, or NOASSERTION when no
+// element has a license
+func (d *Document) EffectiveLicenseExpression() string {
+	ids := []string{}
+	for _, lc := range d.EffectiveLicenses() {
+		ids = append(ids, lc.License)
+	}
+	if len(ids) == 0 {
+		return NOASSERTION
+	}
+	sort.Strings(ids)
+	for i := range ids {
+		if strings.Contains(ids[i], " WITH ") && len(ids) > 1 {
+			ids[i] = "(" + ids[i] + ")"
+		}
+	}
+	return strings.Join(ids, " AND ")
+}
+
+// elementLicense returns the license of an element: the concluded license
+// if known, else the declared license of packages or the license found in
+// files
+func elementLicense(o Object) string {
+	var concluded, fallback string
+	switch e := o.(type) {
+	case *Package:
+		concluded, fallback = e.LicenseConcluded, e.LicenseDeclared
+	case *File:
+		concluded, fallback = e.LicenseConcluded, e.LicenseInfoInFile
+	default:
+		return ""
+	}
+	if concluded != "" && concluded != NONE && concluded != NOASSERTION {
+		return concluded
+	}
+	return fallback
+}
+
+// licenseIDs splits a license expression into the licenses it references.
+// Exceptions are kept with their license.
+func licenseIDs(expression string) []string {
+	fields := strings.Fields(
+		strings.NewReplacer("(", " ", ")", " ").Replace(expression),
+	)
+	ids := []string{}
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "AND", "OR", NONE, NOASSERTION:
+			continue
+		case "WITH":
+			if len(ids) > 0 && i+1 < len(fields) {
+				ids[len(ids)-1] += " WITH " + fields[i+1]
+				i++
+			}
+			continue
+		}
+		ids = append(ids, fields[i])
+	}
+	return ids
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveLicenses(t *testing.T) {
+	doc := NewDocument()
+	require.Equal(t, NOASSERTION, doc.EffectiveLicenseExpression())
+
+	root := NewPackage()
+	root.Name = "root"
+	root.BuildID("root")
+	root.LicenseConcluded = "Apache-2.0"
+
+	dep := NewPackage()
+	dep.Name = "dep"
+	dep.BuildID("dep")
+	dep.LicenseConcluded = NOASSERTION
+	dep.LicenseDeclared = "(MIT OR BSD-3-Clause)"
+	require.NoError(t, root.AddPackage(dep))
+
+	for i, l := range []string{"Apache-2.0", NONE, "MIT AND MIT", "GPL-2.0-only WITH Classpath-exception-2.0"} {
+		f := NewFile()
+		f.Name = fmt.Sprintf("file%d", i)
+		f.BuildID(f.Name)
+		f.LicenseInfoInFile = l
+		require.NoError(t, root.AddFile(f))
+	}
+	require.NoError(t, doc.AddPackage(root))
+
+	require.Equal(t, []LicenseCount{
+		{License: "Apache-2.0", Count: 2},
+		{License: "MIT", Count: 2},
+		{License: "BSD-3-Clause", Count: 1},
+		{License: "GPL-2.0-only WITH Classpath-exception-2.0", Count: 1},
+	}, doc.EffectiveLicenses())
+	require.Equal(t,
+		"Apache-2.0 AND BSD-3-Clause AND (GPL-2.0-only WITH Classpath-exception-2.0) AND MIT",
+		doc.EffectiveLicenseExpression(),
+	)
+}
@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -48,6 +49,13 @@ LicenseConcluded: {{ if .LicenseConcluded }}{{ .LicenseConcluded }}{{ else }}NOA
 {{ if .LicenseComments }}LicenseComments: <text>{{ .LicenseComments }}
 </text>
 {{ end -}}
+{{ range $key, $value := .Annotations -}}
+Annotator: {{ $value.Annotator }}
+AnnotationDate: {{ $value.Date }}
+AnnotationType: {{ $value.Type }}
+SPDXREF: {{ $.ID }}
+AnnotationComment: <text>{{ $value.Comment }}</text>
+{{ end -}}
 LicenseInfoInFile: {{ if .LicenseInfoInFile }}{{ .LicenseInfoInFile }}{{ else }}NOASSERTION{{ end }}
 FileCopyrightText: {{ if .CopyrightText }}<text>{{ .CopyrightText }}
 </text>{{ else }}NOASSERTION{{ end }}
@@ -58,7 +66,9 @@ FileCopyrightText: {{ if .CopyrightText }}<text>{{ .CopyrightText }}
 type File struct {
 	Entity
 	FileType          []string
-	LicenseInfoInFile string // GPL-3.0-or-later
+	LicenseInfoInFile string    // GPL-3.0-or-later
+	Size              int64     // Size of the source file when scanned, not rendered
+	ModTime           time.Time // Modification time of the source file when scanned, not rendered
 }
 
 func NewFile() (f *File) {
@@ -143,14 +153,23 @@ func getFileTypes(path string) []string {
 		if err != nil {
 			return []string{"OTHER"}
 		}
-		splited := strings.Split(mineType, "/")
+		fileExtension = contentTypeExtension(mineType)
+	}
+	return extensionFileTypes(fileExtension)
+}
 
-		fileExtension = splited[0]
-		if splited[0] == "application" {
-			fileExtension = splited[1]
-		}
+// contentTypeExtension returns the name used in place of the extension of
+// files without one from their content type
+func contentTypeExtension(mimeType string) string {
+	splited := strings.Split(mimeType, "/")
+	if splited[0] == "application" && len(splited) > 1 {
+		return splited[1]
 	}
+	return splited[0]
+}
 
+// extensionFileTypes returns the SPDX file types of a file extension
+func extensionFileTypes(fileExtension string) []string {
 	switch fileExtension {
 	case "go", "java", "rs", "rb", "c", "cgi", "class", "cpp", "cs", "h",
 		"php", "py", "sh", "swift", "vb", "css":
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileMetadataAnnotation prefixes the annotation recording the mode and
+// modification time of a file, eg "File metadata: mode=0755 mtime=..."
+const fileMetadataAnnotation = "File metadata: "
+
+// FileMetadata is the file system metadata of a scanned file, recorded
+// when the options ask for it
+type FileMetadata struct {
+	Mode    os.FileMode // Permission bits, with the setuid, setgid and sticky bits
+	ModTime time.Time   // Modification time
+}
+
+// newFileMetadata returns the metadata of a file from its mode and mtime.
+// Only the permission and special bits of the mode are kept.
+func newFileMetadata(mode os.FileMode, modTime time.Time) FileMetadata {
+	return FileMetadata{
+		Mode:    mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky),
+		ModTime: modTime.UTC(),
+	}
+}
+
+// diskFileMetadata returns the metadata of the file at path in the directory
+// being scanned: that of its tarball entry when it was extracted to a
+// temporary directory, the one on disk otherwise
+func (di *spdxDefaultImplementation) diskFileMetadata(dirPath, path string, info os.FileInfo) FileMetadata {
+	if m, ok := di.tempPaths.fileMetadata(dirPath, path); ok {
+		return m
+	}
+	return newFileMetadata(info.Mode(), info.ModTime())
+}
+
+// String returns the metadata as annotated, with the mode in the octal
+// notation of chmod and the time in RFC 3339 format
+func (m FileMetadata) String() string {
+	mode := uint32(m.Mode.Perm())
+	if m.Mode&os.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if m.Mode&os.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if m.Mode&os.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+	return fmt.Sprintf("mode=%04o mtime=%s", mode, m.ModTime.Format(time.RFC3339Nano))
+}
+
+// parseFileMetadata parses the metadata as written by String
+func parseFileMetadata(s string) (*FileMetadata, error) {
+	var modeStr, mtimeStr string
+	for _, field := range strings.Fields(s) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "mode":
+			modeStr = value
+		case "mtime":
+			mtimeStr = value
+		}
+	}
+	bits, err := strconv.ParseUint(modeStr, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("parsing file mode %q: %w", modeStr, err)
+	}
+	mtime, err := time.Parse(time.RFC3339Nano, mtimeStr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing modification time: %w", err)
+	}
+	mode := os.FileMode(bits) & os.ModePerm
+	if bits&0o4000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&0o2000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&0o1000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return &FileMetadata{Mode: mode, ModTime: mtime}, nil
+}
+
+// Metadata returns the file system metadata annotated on the file, or nil
+// if it has none
+func (f *File) Metadata() *FileMetadata {
+	for _, a := range f.Annotations {
+		if strings.HasPrefix(a.Comment, fileMetadataAnnotation) {
+			m, err := parseFileMetadata(strings.TrimPrefix(a.Comment, fileMetadataAnnotation))
+			if err != nil {
+				return nil
+			}
+			return m
+		}
+	}
+	return nil
+}
+
+// setMetadata annotates the file with its metadata, replacing the one it had
+func (f *File) setMetadata(m FileMetadata) {
+	for i := range f.Annotations {
+		if strings.HasPrefix(f.Annotations[i].Comment, fileMetadataAnnotation) {
+			f.Annotations[i] = newToolAnnotation(fileMetadataAnnotation + m.String())
+			return
+		}
+	}
+	f.AddAnnotation(newToolAnnotation(fileMetadataAnnotation + m.String()))
+}
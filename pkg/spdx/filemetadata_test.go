@@ -0,0 +1,111 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromDirectoryFileMetadata(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	path := filepath.Join(dir, "run.sh")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), os.FileMode(0o644)))
+	require.NoError(t, os.Chmod(path, 0o750))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{SkipLicenseScan: true, RecordFileMetadata: true}, dir)
+	require.NoError(t, err)
+	require.Len(t, pkg.Files(), 1)
+	f := pkg.Files()[0]
+	require.Equal(t, &FileMetadata{Mode: 0o750, ModTime: mtime}, f.Metadata())
+	require.Len(t, f.Annotations, 1)
+	require.Equal(t, fileMetadataAnnotation+"mode=0750 mtime=2021-03-04T05:06:07Z", f.Annotations[0].Comment)
+
+	// The metadata has no effect on the checksums and licenses
+	plain, err := impl.PackageFromDirectory(&Options{SkipLicenseScan: true}, dir)
+	require.NoError(t, err)
+	require.Nil(t, plain.Files()[0].Metadata())
+	require.Equal(t, plain.Files()[0].Checksum, f.Checksum)
+	require.Equal(t, plain.Files()[0].LicenseConcluded, f.LicenseConcluded)
+}
+
+func TestPackageFromTarballFileMetadata(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tarPath := filepath.Join(t.TempDir(), "files.tar")
+	f, err := os.Create(tarPath)
+	require.NoError(t, err)
+	tw := tar.NewWriter(f)
+	for name, mode := range map[string]int64{"bin/tool": 0o4755, "secret": 0o200} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: name, Typeflag: tar.TypeReg, Mode: mode, Size: 5, ModTime: mtime,
+		}))
+		_, err := tw.Write([]byte("data\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, f.Close())
+
+	// The files get the mode and time of their entries, not of extraction
+	expected := map[string]*FileMetadata{
+		"bin/tool": {Mode: 0o755 | os.ModeSetuid, ModTime: mtime},
+		"secret":   {Mode: 0o200, ModTime: mtime},
+	}
+	impl := spdxDefaultImplementation{}
+	for _, mode := range []TempStorageMode{TempStoragePlain, TempStorageCompressed} {
+		for _, workers := range []int{0, 4} {
+			opts := &Options{SkipLicenseScan: true, RecordFileMetadata: true, ExtractWorkers: workers}
+			pkg, err := impl.PackageFromTarball(opts, &TarballOptions{AddFiles: true, TempStorageMode: mode}, tarPath)
+			require.NoError(t, err)
+			require.Len(t, pkg.Files(), 2)
+			for _, f := range pkg.Files() {
+				m := f.Metadata()
+				require.NotNil(t, m, f.Name)
+				require.Equal(t, expected[f.Name].Mode, m.Mode, "%s %s", mode, f.Name)
+				require.Equal(t, expected[f.Name].ModTime, m.ModTime, "%s %s", mode, f.Name)
+			}
+		}
+	}
+
+	// The modes are recorded, never set on the extracted files
+	dir, err := impl.ExtractTarballTmp(&Options{RecordFileMetadata: true}, tarPath)
+	require.NoError(t, err)
+	defer impl.tempPaths.remove(dir)
+	for _, name := range []string{"bin/tool", "secret"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Zero(t, info.Mode()&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky|0o022), name)
+		require.NotZero(t, info.Mode()&0o400, name)
+		m, ok := impl.tempPaths.fileMetadata(dir, filepath.Join(dir, name))
+		require.True(t, ok, name)
+		require.Equal(t, *expected[name], m, name)
+	}
+
+	// Modes are written and parsed in the octal notation of chmod
+	hdr := &tar.Header{Name: "secret", Typeflag: tar.TypeReg, Mode: 0o200, ModTime: mtime}
+	require.Equal(t, "mode=0200 mtime=2020-01-02T03:04:05Z", newFileMetadata(hdr.FileInfo().Mode(), hdr.ModTime).String())
+	parsed, err := parseFileMetadata("mode=7777 mtime=2020-01-02T03:04:05Z")
+	require.NoError(t, err)
+	require.Equal(t, os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky, parsed.Mode)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PackageFromFile builds a package describing a single file, eg a release
+// binary. The package is named after the file and contains it, both carry
+// the checksums of the file and the license found in it.
+func (di *spdxDefaultImplementation) PackageFromFile(opts *Options, filePath string) (*Package, error) {
+	checksumAlgorithms, err := normalizeChecksumAlgorithms(opts.ChecksumAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+	unknownInFile, unknownConcluded, err := unknownLicenseTags(opts.UnknownLicensePolicy)
+	if err != nil {
+		return nil, err
+	}
+	filePath, err = filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("getting absolute file path: %w", err)
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", filePath)
+	}
+
+	pkg := NewPackage()
+	pkg.Options().WorkDir = filepath.Dir(filePath)
+	pkg.setSourceFile(filePath)
+	pkg.BuildID(pkg.Name)
+	pkg.FilesAnalyzed = true
+
+	f := NewFile()
+	f.Options().WorkDir = filepath.Dir(filePath)
+	f.Options().Prefix = pkg.Name
+	f.Options().ChecksumAlgorithms = checksumAlgorithms
+	f.Options().ComputeSWHID = opts.ComputeSWHID
+	f.Size = info.Size()
+	f.ModTime = info.ModTime()
+	stopFileScan := di.stats.start(opts, phaseFileScan)
+	err = di.cpuLimiter.run(opts, func() error { return f.ReadSourceFile(filePath) })
+	stopFileScan()
+	if err != nil {
+		return nil, fmt.Errorf("checksumming file: %w", err)
+	}
+
+	f.LicenseInfoInFile = NOASSERTION
+	f.LicenseConcluded = NOASSERTION
+	if !opts.SkipLicenseScan {
+		reader, err := di.LicenseReader(opts)
+		if err != nil {
+			return nil, fmt.Errorf("creating license reader: %w", err)
+		}
+		stopClassification := di.stats.start(opts, phaseLicenseClassification)
+		lic, err := reader.LicenseFromFile(filePath)
+		stopClassification()
+		if err != nil {
+			return nil, fmt.Errorf("scanning file for license: %w", err)
+		}
+		f.LicenseInfoInFile = unknownInFile
+		f.LicenseConcluded = unknownConcluded
+		if lic != nil {
+			f.LicenseInfoInFile = lic.LicenseID
+			f.LicenseConcluded = lic.LicenseID
+		}
+	}
+
+	if opts.AnalyzeBinaries {
+		if err := annotateBinaryInfo(f, filePath); err != nil {
+			di.warn(opts, filePath, "Could not read the architecture of %s: %v", filePath, err)
+		}
+	}
+	if opts.RecordFileMetadata {
+		f.setMetadata(newFileMetadata(info.Mode(), info.ModTime()))
+	}
+	if opts.AnnotateEmptyFiles && info.Size() == 0 {
+		f.AddAnnotation(newScanAnnotation(opts, emptyFileAnnotation))
+	}
+
+	// The package is the file, it has the same checksums and license
+	pkg.Checksum = map[string]string{}
+	for algo, sum := range f.Checksum {
+		pkg.Checksum[algo] = sum
+	}
+	pkg.LicenseConcluded = f.LicenseConcluded
+	if err := pkg.AddFile(f); err != nil {
+		return nil, fmt.Errorf("adding file to the spdx package: %w", err)
+	}
+	computeVerificationCodes(opts, pkg)
+	return pkg, nil
+}
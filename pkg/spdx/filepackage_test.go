@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/bom/pkg/license"
+)
+
+func TestPackageFromFile(t *testing.T) {
+	list, err := license.EmbeddedLicenseList()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "LICENSE.txt")
+	require.NoError(t, os.WriteFile(path, []byte(list.Licenses["Apache-2.0"].LicenseText), os.FileMode(0o644)))
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromFile(&Options{ChecksumAlgorithms: []string{"SHA256", "SHA512"}}, path)
+	require.NoError(t, err)
+	require.Equal(t, "LICENSE.txt", pkg.Name)
+	require.Equal(t, "LICENSE.txt", pkg.FileName)
+	require.True(t, pkg.FilesAnalyzed)
+	require.Equal(t, "Apache-2.0", pkg.LicenseConcluded)
+
+	require.Len(t, pkg.Files(), 1)
+	f := pkg.Files()[0]
+	require.Equal(t, "LICENSE.txt", f.Name)
+	require.Equal(t, "Apache-2.0", f.LicenseInfoInFile)
+	require.Equal(t, "Apache-2.0", f.LicenseConcluded)
+	require.Len(t, f.Checksum, 3)
+	require.NotEmpty(t, f.Checksum["SHA1"])
+	require.NotEmpty(t, f.Checksum["SHA256"])
+	require.NotEmpty(t, f.Checksum["SHA512"])
+	require.Equal(t, f.Checksum, pkg.Checksum)
+	require.NotEmpty(t, pkg.VerificationCode)
+
+	pkg, err = impl.PackageFromFile(&Options{SkipLicenseScan: true}, path)
+	require.NoError(t, err)
+	require.NotEmpty(t, pkg.VerificationCode)
+	require.Equal(t, NOASSERTION, pkg.Files()[0].LicenseConcluded)
+
+	// Only regular files can be described
+	_, err = impl.PackageFromFile(&Options{}, dir)
+	require.Error(t, err)
+	_, err = impl.PackageFromFile(&Options{}, filepath.Join(dir, "missing"))
+	require.Error(t, err)
+	_, err = impl.PackageFromFile(&Options{ChecksumAlgorithms: []string{"MD4"}}, path)
+	require.Error(t, err)
+}
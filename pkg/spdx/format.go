@@ -24,3 +24,6 @@ const FormatTagValue = "tag-value"
 
 // FormatJSON is the JSON format for an SPDX document.
 const FormatJSON = "json"
+
+// FormatCycloneDXJSON writes the document as a CycloneDX JSON BOM.
+const FormatCycloneDXJSON = "cyclonedx-json"
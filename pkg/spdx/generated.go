@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+)
+
+// generatedFileAnnotation prefixes the annotation flagging generated files,
+// followed by how they were recognized
+const generatedFileAnnotation = "Generated file: "
+
+// defaultGeneratedPatterns match the names of the files generated by the
+// common code generators, used when the options set no patterns
+var defaultGeneratedPatterns = []string{"*.pb.go", "*.pb.gw.go", "zz_generated.*", "*_gen.go", "*_generated.go"}
+
+// generatedHeaderLines is the number of lines read looking for the header
+// of generated files
+const generatedHeaderLines = 30
+
+// generatedHeader matches the comment marking generated code, as defined
+// by the go command (https://go.dev/s/generatedcode) in the comment syntax
+// of other languages too, and the @generated marker of other tools
+var generatedHeader = regexp.MustCompile(
+	`^\s*(//|#|/?\*|--|;)\s*(Code generated .* DO NOT EDIT\.?\s*$|.*@generated\b)`,
+)
+
+// generatedFileReason returns why the file at filePath, named name in its
+// package, is taken as generated: the pattern its name matches or its
+// header. Returns an empty string for the files that are not generated.
+func generatedFileReason(filePath, name string, patterns []string) (string, error) {
+	if len(patterns) == 0 {
+		patterns = defaultGeneratedPatterns
+	}
+	for _, pattern := range patterns {
+		// Patterns with a slash match the whole path, the rest the name
+		subject := path.Base(name)
+		if path.Base(pattern) != pattern {
+			subject = name
+		}
+		match, err := path.Match(pattern, subject)
+		if err != nil {
+			return "", fmt.Errorf("invalid generated file pattern %q: %w", pattern, err)
+		}
+		if match {
+			return "name matches " + pattern, nil
+		}
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < generatedHeaderLines && scanner.Scan(); i++ {
+		if generatedHeader.MatchString(scanner.Text()) {
+			return "code generated header", nil
+		}
+	}
+	// Lines too long for the scanner mean the file is not source code
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return "", err
+	}
+	return "", nil
+}
+
+// annotateGeneratedFile flags the file if it is generated
+func annotateGeneratedFile(f *File, filePath string, patterns []string) error {
+	reason, err := generatedFileReason(filePath, f.Name, patterns)
+	if err != nil || reason == "" {
+		return err
+	}
+	f.AddAnnotation(newToolAnnotation(generatedFileAnnotation + reason))
+	return nil
+}
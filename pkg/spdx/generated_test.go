@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratedFileReason(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name     string
+		content  string
+		patterns []string
+		reason   string
+	}{
+		{"api/service.pb.go", "package api\n", nil, "name matches *.pb.go"},
+		{"pkg/zz_generated.deepcopy.go", "package pkg\n", nil, "name matches zz_generated.*"},
+		{"main.go", "package main\n", nil, ""},
+		{"mock.go", "// Copyright 2023\n\n// Code generated by counterfeiter. DO NOT EDIT.\npackage fake\n", nil, "code generated header"},
+		{"gen.py", "# Code generated by protoc-gen. DO NOT EDIT.\n", nil, "code generated header"},
+		{"schema.js", "/**\n * @generated\n */\n", nil, "code generated header"},
+		{"notes.go", "package notes\n\n// Code generated files should not be edited\n", nil, ""},
+		{"api/types.go", "package api\n", []string{"api/*.go"}, "name matches api/*.go"},
+		{"service.pb.go", "package api\n", []string{"*.txt"}, ""},
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(tc.name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(path, []byte(tc.content), os.FileMode(0o644)))
+		reason, err := generatedFileReason(path, tc.name, tc.patterns)
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.reason, reason, tc.name)
+	}
+
+	_, err := generatedFileReason(filepath.Join(dir, "main.go"), "main.go", []string{"["})
+	require.Error(t, err)
+}
+
+func TestPackageFromDirectoryDetectGeneratedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":            "package main\n",
+		"api/service.pb.go":  "package api\n",
+		"fakes/fake_impl.go": "// Code generated by counterfeiter. DO NOT EDIT.\npackage fakes\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+	}
+
+	generatedFiles := func(opts *Options) map[string]string {
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromDirectory(opts, dir)
+		require.NoError(t, err)
+		generated := map[string]string{}
+		for _, f := range pkg.Files() {
+			for _, a := range f.Annotations {
+				if strings.HasPrefix(a.Comment, generatedFileAnnotation) {
+					generated[f.Name] = strings.TrimPrefix(a.Comment, generatedFileAnnotation)
+				}
+			}
+		}
+		return generated
+	}
+
+	require.Equal(t, map[string]string{
+		"api/service.pb.go":  "name matches *.pb.go",
+		"fakes/fake_impl.go": "code generated header",
+	}, generatedFiles(&Options{DetectGeneratedFiles: true}))
+
+	// Files are only checked when the options ask for it
+	require.Empty(t, generatedFiles(&Options{}))
+}
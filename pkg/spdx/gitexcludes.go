@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gitconfig "github.com/go-git/go-git/v5/plumbing/format/config"
+)
+
+// globalGitExcludesFile returns the path of the file with the ignore rules
+// git applies to all the repositories of the user: the core.excludesFile
+// of the global git config or, when it is not set, the XDG default
+// $XDG_CONFIG_HOME/git/ignore. It returns an empty string when the home
+// directory of the user is not known.
+func globalGitExcludesFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = ""
+	}
+	xdgConfig := os.Getenv("XDG_CONFIG_HOME")
+	if xdgConfig == "" && home != "" {
+		xdgConfig = filepath.Join(home, ".config")
+	}
+
+	// As in git, GIT_CONFIG_GLOBAL replaces the global config files, and
+	// ~/.gitconfig is read after the XDG one so its settings win
+	configFiles := []string{}
+	switch {
+	case os.Getenv("GIT_CONFIG_GLOBAL") != "":
+		configFiles = append(configFiles, os.Getenv("GIT_CONFIG_GLOBAL"))
+	default:
+		if xdgConfig != "" {
+			configFiles = append(configFiles, filepath.Join(xdgConfig, "git", "config"))
+		}
+		if home != "" {
+			configFiles = append(configFiles, filepath.Join(home, ".gitconfig"))
+		}
+	}
+	excludesFile := ""
+	for _, path := range configFiles {
+		if value := readGitConfigValue(path, "core", "excludesFile"); value != "" {
+			excludesFile = value
+		}
+	}
+
+	switch {
+	case excludesFile == "" && xdgConfig != "":
+		return filepath.Join(xdgConfig, "git", "ignore")
+	case strings.HasPrefix(excludesFile, "~/") && home != "":
+		return filepath.Join(home, excludesFile[2:])
+	case strings.HasPrefix(excludesFile, "~/"):
+		return ""
+	}
+	return excludesFile
+}
+
+// readGitConfigValue returns the value of a key in a section of the git
+// config file at path, or an empty string if the file cannot be read or
+// does not set it
+func readGitConfigValue(path, section, key string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	cfg := gitconfig.New()
+	if err := gitconfig.NewDecoder(f).Decode(cfg); err != nil {
+		return ""
+	}
+	if !cfg.HasSection(section) {
+		return ""
+	}
+	return cfg.Section(section).Options.Get(key)
+}
+
+// readGlobalGitExcludes returns the rules of the global excludes file of
+// git. A missing file has no rules.
+func readGlobalGitExcludes() (path string, patterns []string, err error) {
+	path = globalGitExcludesFile()
+	if path == "" {
+		return "", nil, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return path, nil, nil
+	}
+	if err != nil {
+		return path, nil, fmt.Errorf("opening global git excludes file: %w", err)
+	}
+	defer f.Close()
+	patterns, err = ReadIgnorePatterns(f)
+	if err != nil {
+		return path, nil, fmt.Errorf("reading global git excludes file %s: %w", path, err)
+	}
+	return path, patterns, nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobalGitExcludesFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_GLOBAL", "")
+
+	// Without a setting, git reads the XDG default
+	require.Equal(t, filepath.Join(home, ".config", "git", "ignore"), globalGitExcludesFile())
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	require.Equal(t, filepath.Join(xdg, "git", "ignore"), globalGitExcludesFile())
+
+	// core.excludesFile is read from the global config files, ~/.gitconfig
+	// winning over the XDG one
+	require.NoError(t, os.MkdirAll(filepath.Join(xdg, "git"), os.FileMode(0o755)))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(xdg, "git", "config"), []byte("[core]\n\texcludesFile = /etc/xdg-ignore\n"), os.FileMode(0o644),
+	))
+	require.Equal(t, "/etc/xdg-ignore", globalGitExcludesFile())
+	require.NoError(t, os.WriteFile(
+		filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = someone\n[core]\n\texcludesfile = ~/.gitignore_global\n"),
+		os.FileMode(0o644),
+	))
+	require.Equal(t, filepath.Join(home, ".gitignore_global"), globalGitExcludesFile())
+
+	// GIT_CONFIG_GLOBAL replaces the global config files
+	custom := filepath.Join(t.TempDir(), "gitconfig")
+	require.NoError(t, os.WriteFile(custom, []byte("[core]\n\texcludesFile = /etc/custom-ignore\n"), os.FileMode(0o644)))
+	t.Setenv("GIT_CONFIG_GLOBAL", custom)
+	require.Equal(t, "/etc/custom-ignore", globalGitExcludesFile())
+}
+
+func TestPackageFromDirectoryGlobalGitignore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_GLOBAL", "")
+	require.NoError(t, os.WriteFile(
+		filepath.Join(home, ".gitconfig"), []byte("[core]\n\texcludesFile = ~/.gitignore_global\n"), os.FileMode(0o644),
+	))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(home, ".gitignore_global"), []byte(".idea/\n*.swp\n"), os.FileMode(0o644),
+	))
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":         "*.log\n!keep.swp\n",
+		"main.go":            "package main\n",
+		"main.go.swp":        "",
+		"keep.swp":           "",
+		"debug.log":          "",
+		".idea/workspace.go": "",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+	}
+	scannedFiles := func(opts *Options) []string {
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromDirectory(opts, dir)
+		require.NoError(t, err)
+		names := []string{}
+		for _, f := range pkg.Files() {
+			names = append(names, f.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// The rules of the repository take precedence over the global ones
+	require.Equal(
+		t, []string{".gitignore", "keep.swp", "main.go"},
+		scannedFiles(&Options{SkipLicenseScan: true, GlobalGitignore: true}),
+	)
+	// The global excludes file is only read when asked
+	require.Equal(
+		t, []string{".gitignore", ".idea/workspace.go", "keep.swp", "main.go", "main.go.swp"},
+		scannedFiles(&Options{SkipLicenseScan: true}),
+	)
+	// Without gitignore files, no rules apply
+	require.Len(t, scannedFiles(&Options{SkipLicenseScan: true, NoGitignore: true}), 6)
+}
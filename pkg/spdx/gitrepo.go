@@ -0,0 +1,248 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/sirupsen/logrus"
+)
+
+// PackageFromGitRepository clones a git repository and returns the package
+// of its directory. The repository is given by its URL, optionally followed
+// by @ and the tag, branch or commit to check out (eg
+// https://github.com/foo/bar@v1.2.3). The package download location points
+// to the commit scanned. The clone is removed once scanned.
+func (spdx *SPDX) PackageFromGitRepository(ctx context.Context, repository string) (*Package, error) {
+	repoURL, ref := splitGitRepository(repository)
+	tmp, err := os.MkdirTemp(tempRoot(spdx.Options()), "spdx-git-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory for the clone: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	dir := filepath.Join(tmp, gitRepositoryName(repoURL))
+	commit, err := cloneGitRepository(ctx, logger(spdx.Options()), repoURL, ref, dir)
+	if err != nil {
+		return nil, fmt.Errorf("cloning %s: %w", sanitizeGitURL(repoURL), err)
+	}
+	// Only the files checked out are described
+	if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+		return nil, fmt.Errorf("removing the git metadata of the clone: %w", err)
+	}
+
+	pkg, err := spdx.packageFromDirectory(spdx.Options(), dir)
+	if err != nil {
+		return nil, err
+	}
+	pkg.DownloadLocation = gitDownloadLocation(repoURL, commit)
+	pkg.SourceInfo = fmt.Sprintf("Cloned from %s at commit %s", sanitizeGitURL(repoURL), commit)
+	if ref != "" {
+		pkg.SourceInfo += fmt.Sprintf(" (%s)", ref)
+	}
+	return pkg, nil
+}
+
+// gitSourceInfo describes the git checkout at dirPath for the source info
+// of its package: the commit checked out, the branch or tags pointing to
+// it and the URL of its remote, origin if there are several. Returns an
+// empty string if the directory is not the root of a git checkout.
+func gitSourceInfo(log logrus.FieldLogger, dirPath string) string {
+	repo, err := git.PlainOpen(dirPath)
+	if err != nil {
+		return ""
+	}
+	head, err := repo.Head()
+	if err != nil {
+		log.Debugf("Not describing the git checkout at %s: %v", dirPath, err)
+		return ""
+	}
+
+	info := "Git checkout"
+	if remoteURL := gitRemoteURL(repo); remoteURL != "" {
+		info += " of " + remoteURL
+	}
+	info += " at commit " + head.Hash().String()
+
+	refs := []string{}
+	if head.Name().IsBranch() {
+		refs = append(refs, "branch "+head.Name().Short())
+	}
+	if tags, err := repo.Tags(); err == nil {
+		//nolint:errcheck // The iterator callback never fails
+		tags.ForEach(func(ref *plumbing.Reference) error {
+			hash := ref.Hash()
+			// Annotated tags point to the tag object, not to the commit
+			if tag, err := repo.TagObject(hash); err == nil {
+				hash = tag.Target
+			}
+			if hash == head.Hash() {
+				refs = append(refs, "tag "+ref.Name().Short())
+			}
+			return nil
+		})
+	}
+	if len(refs) > 0 {
+		info += " (" + strings.Join(refs, ", ") + ")"
+	}
+	return info
+}
+
+// gitRemoteURL returns the URL of the origin remote of a repository or,
+// without one, of its first remote. The credentials in the URL are removed.
+func gitRemoteURL(repo *git.Repository) string {
+	remotes, err := repo.Remotes()
+	if err != nil || len(remotes) == 0 {
+		return ""
+	}
+	remote := remotes[0]
+	for _, r := range remotes {
+		if r.Config().Name == git.DefaultRemoteName {
+			remote = r
+		}
+	}
+	if len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	return sanitizeGitURL(remote.Config().URLs[0])
+}
+
+// sanitizeGitURL returns a git URL fit to be published in a document: the
+// credentials in it are removed and SCP-like addresses of SSH remotes (eg
+// git@github.com:foo/bar.git) are written as ssh:// URLs without the user.
+// Local paths are returned as they are.
+func sanitizeGitURL(repoURL string) string {
+	if !strings.Contains(repoURL, "://") {
+		userHost, repoPath, ok := strings.Cut(repoURL, ":")
+		// A colon after a slash is part of a path, and a single letter
+		// before it a Windows drive
+		if !ok || strings.Contains(userHost, "/") || len(userHost) < 2 {
+			return repoURL
+		}
+		if _, host, ok := strings.Cut(userHost, "@"); ok {
+			userHost = host
+		}
+		return "ssh://" + userHost + "/" + strings.TrimPrefix(repoPath, "/")
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		// Do not risk publishing the credentials of a URL not parsed
+		if i := strings.Index(repoURL, "://"); i >= 0 {
+			if j := strings.LastIndex(repoURL, "@"); j > i {
+				return repoURL[:i+3] + repoURL[j+1:]
+			}
+		}
+		return repoURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// gitDownloadLocation returns the SPDX download location of the commit of
+// the repository at repoURL, eg git+https://github.com/foo/bar@<commit>
+func gitDownloadLocation(repoURL, commit string) string {
+	return "git+" + sanitizeGitURL(repoURL) + "@" + commit
+}
+
+// splitGitRepository splits a repository in its URL and the ref after its
+// last @, if any. The @ of the user in URLs (eg git@github.com:foo/bar) is
+// not taken as a ref, nor the git+ prefix of SPDX download locations.
+func splitGitRepository(repository string) (repoURL, ref string) {
+	repository = strings.TrimPrefix(repository, "git+")
+	i := strings.LastIndex(repository, "@")
+	if i <= 0 || i < strings.LastIndexAny(repository, "/:") {
+		return repository, ""
+	}
+	return repository[:i], repository[i+1:]
+}
+
+// gitRepositoryName returns the name of the repository at a URL, the last
+// element of its path without the .git suffix
+func gitRepositoryName(repoURL string) string {
+	name := path.Base(strings.TrimSuffix(strings.TrimSuffix(repoURL, "/"), ".git"))
+	if _, after, ok := strings.Cut(name, ":"); ok {
+		name = after
+	}
+	if name == "" || name == "." || name == "/" {
+		return "repository"
+	}
+	return name
+}
+
+// cloneGitRepository clones the repository at repoURL to dir and returns
+// the commit checked out. Tags and branches are cloned shallow, commits
+// are checked out of a full clone as they cannot be fetched by hash.
+func cloneGitRepository(ctx context.Context, log logrus.FieldLogger, repoURL, ref, dir string) (string, error) {
+	refNames := []plumbing.ReferenceName{""}
+	if ref != "" {
+		refNames = []plumbing.ReferenceName{
+			plumbing.NewTagReferenceName(ref), plumbing.NewBranchReferenceName(ref),
+		}
+	}
+	for _, refName := range refNames {
+		if err := os.RemoveAll(dir); err != nil {
+			return "", fmt.Errorf("removing previous clone: %w", err)
+		}
+		log.Infof("Cloning %s %s", sanitizeGitURL(repoURL), refName)
+		repo, err := git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{
+			URL:           repoURL,
+			ReferenceName: refName,
+			SingleBranch:  true,
+			Depth:         1,
+		})
+		if errors.As(err, &git.NoMatchingRefSpecError{}) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		head, err := repo.Head()
+		if err != nil {
+			return "", fmt.Errorf("reading the commit cloned: %w", err)
+		}
+		return head.Hash().String(), nil
+	}
+
+	if !plumbing.IsHash(ref) {
+		return "", fmt.Errorf("no tag, branch or commit %s found", ref)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("removing previous clone: %w", err)
+	}
+	log.Infof("Cloning %s to check out commit %s", sanitizeGitURL(repoURL), ref)
+	repo, err := git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{URL: repoURL, NoCheckout: true})
+	if err != nil {
+		return "", err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("opening worktree: %w", err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(ref)}); err != nil {
+		return "", fmt.Errorf("checking out commit %s: %w", ref, err)
+	}
+	return plumbing.NewHash(ref).String(), nil
+}
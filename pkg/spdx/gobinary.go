@@ -0,0 +1,52 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PackageFromGoBinary builds a package describing a Go binary from the
+// build information embedded in it. The package of the main module holds
+// the binary and depends on every module compiled into it, with their
+// exact versions and go.sum hashes. No network access is needed unless
+// opts.LookupGoImports is set.
+func (di *spdxDefaultImplementation) PackageFromGoBinary(opts *Options, binaryPath string) (*Package, error) {
+	f, err := os.Open(binaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening binary: %w", err)
+	}
+	defer f.Close()
+	finfo, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("checking binary: %w", err)
+	}
+	bin, err := newStaticBinary(filepath.Base(binaryPath), f, finfo.Size())
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", binaryPath, err)
+	}
+	var pkg *Package
+	if err := di.cpuLimiter.run(opts, func() (err error) {
+		pkg, err = bin.spdxPackage(newScanGoRepositoryResolver(opts))
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("describing Go binary %s: %w", binaryPath, err)
+	}
+	return pkg, nil
+}
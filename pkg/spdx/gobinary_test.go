@@ -0,0 +1,148 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromImageTarballGoBinaries(t *testing.T) {
+	binPath, err := os.Executable()
+	require.NoError(t, err)
+	binData, err := os.ReadFile(binPath)
+	require.NoError(t, err)
+
+	// The package database makes the image not static
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	for name, data := range map[string][]byte{
+		"usr/bin/app":         binData,
+		"var/lib/dpkg/status": []byte(""),
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0o755, Size: int64(len(data)), Typeflag: tar.TypeReg,
+		}))
+		_, err = tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	tarPath := writeTestDockerArchive(t, []string{"layer1/layer.tar"}, [][]byte{layer.Bytes()})
+
+	// goModules returns the packages of Go modules held by the layer
+	goModules := func(opts *Options) []*Package {
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromImageTarball(opts, tarPath)
+		require.NoError(t, err)
+		layers := containedPackages(pkg)
+		require.Len(t, layers, 1)
+		return containedPackages(layers[0])
+	}
+	require.Empty(t, goModules(&Options{}))
+
+	modules := goModules(&Options{ScanGoBinaries: true})
+	require.Len(t, modules, 1)
+	require.Equal(t, "sigs.k8s.io/bom", modules[0].Name)
+	require.Equal(t, "APPLICATION", modules[0].PrimaryPurpose)
+	files := modules[0].Files()
+	require.Len(t, files, 1)
+	require.Equal(t, "usr/bin/app", files[0].Name)
+	require.Equal(t, fmt.Sprintf("%x", sha256.Sum256(binData)), files[0].Checksum["SHA256"])
+	deps := 0
+	for _, rel := range modules[0].Relationships {
+		if dep, ok := rel.Peer.(*Package); ok && rel.Type == DEPENDS_ON {
+			if pu := dep.Purl(); pu != nil {
+				require.Equal(t, "golang", pu.Type, dep.Name)
+				deps++
+			}
+		}
+	}
+	require.NotZero(t, deps)
+}
+
+func TestPackageFromGoBinary(t *testing.T) {
+	// The test binary itself is the fixture, its build information
+	// lists the modules of the repository
+	binPath, err := os.Executable()
+	require.NoError(t, err)
+	info, err := buildinfo.ReadFile(binPath)
+	require.NoError(t, err)
+	require.NotEmpty(t, info.Deps)
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromGoBinary(&Options{}, binPath)
+	require.NoError(t, err)
+	require.Equal(t, "sigs.k8s.io/bom", pkg.Name)
+	require.Equal(t, "APPLICATION", pkg.PrimaryPurpose)
+
+	type module struct {
+		version, sum, purl string
+	}
+	deps := map[string]module{}
+	for _, rel := range pkg.Relationships {
+		switch peer := rel.Peer.(type) {
+		case *File:
+			require.Equal(t, filepath.Base(binPath), peer.Name)
+		case *Package:
+			require.Equal(t, DEPENDS_ON, rel.Type)
+			require.Equal(t, "LIBRARY", peer.PrimaryPurpose)
+			require.Empty(t, peer.Checksum)
+			m := module{version: peer.Version}
+			for _, a := range peer.Annotations {
+				if sum, ok := strings.CutPrefix(a.Comment, goModuleSumAnnotation); ok {
+					m.sum = sum
+				}
+			}
+			if pu := peer.Purl(); pu != nil {
+				m.purl = pu.ToString()
+			}
+			deps[peer.Name] = m
+		}
+	}
+
+	// Every module compiled in is listed with its version, the h1: sum
+	// in an annotation and a golang purl
+	require.Len(t, deps, len(info.Deps))
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		require.Contains(t, deps, dep.Path)
+		m := deps[dep.Path]
+		if dep.Version != "(devel)" {
+			require.Equal(t, dep.Version, m.version, dep.Path)
+			// golang purls are lowercased (eg github.com/protonmail/go-crypto)
+			require.True(t, strings.HasPrefix(m.purl, "pkg:golang/"+strings.ToLower(dep.Path)+"@"), m.purl)
+		}
+		require.Equal(t, dep.Sum, m.sum, dep.Path)
+	}
+
+	// Files without build information are rejected
+	notGo := filepath.Join(t.TempDir(), "script.sh")
+	require.NoError(t, os.WriteFile(notGo, []byte("#!/bin/sh\n"), os.FileMode(0o755)))
+	_, err = impl.PackageFromGoBinary(&Options{}, notGo)
+	require.Error(t, err)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"sync"
+)
+
+// goDependencySet tracks the go dependencies added to the packages of the
+// directories scanned, to describe each module version only once
+type goDependencySet struct {
+	sync.Mutex
+	packages map[string]*Package
+}
+
+// add registers the package of a go dependency. If the same module version
+// was added before, it returns the package added first and true.
+func (s *goDependencySet) add(pkg *Package) (first *Package, seen bool) {
+	key := goDependencyKey(pkg)
+	s.Lock()
+	defer s.Unlock()
+	if first, ok := s.packages[key]; ok {
+		return first, true
+	}
+	if s.packages == nil {
+		s.packages = map[string]*Package{}
+	}
+	s.packages[key] = pkg
+	return pkg, false
+}
+
+// goDependencyKey identifies the module version described by the package
+// of a go dependency: its purl or, without one, its name and version
+func goDependencyKey(pkg *Package) string {
+	if purl := pkg.Purl(); purl != nil {
+		return purl.ToString()
+	}
+	return pkg.Name + "@" + pkg.Version
+}
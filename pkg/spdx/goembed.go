@@ -0,0 +1,193 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	goEmbedDirective = "//go:embed"
+	goEmbedAllPrefix = "all:"
+)
+
+// goEmbedPattern is a pattern read from a go:embed directive
+type goEmbedPattern struct {
+	Pattern string // Pattern, relative to the directory of the go file
+	All     bool   // When true, the pattern was prefixed with all:
+}
+
+// readGoEmbedPatterns parses a go source file and returns the patterns
+// found in all its go:embed directives
+func readGoEmbedPatterns(goFile string) ([]goEmbedPattern, error) {
+	f, err := os.Open(goFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening go source file: %w", err)
+	}
+	defer f.Close()
+
+	// Lines are read whole, generated sources often have lines longer
+	// than the limit of a bufio.Scanner
+	patterns := []goEmbedPattern{}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("reading go source file: %w", err)
+		}
+		if line == "" && err != nil {
+			break
+		}
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, goEmbedDirective+" ") && !strings.HasPrefix(line, goEmbedDirective+"\t") {
+			continue
+		}
+		fields, err := splitGoEmbedArgs(strings.TrimPrefix(line, goEmbedDirective))
+		if err != nil {
+			return nil, fmt.Errorf("parsing go:embed directive in %s: %w", goFile, err)
+		}
+		for _, field := range fields {
+			p := goEmbedPattern{Pattern: field}
+			if strings.HasPrefix(field, goEmbedAllPrefix) {
+				p.All = true
+				p.Pattern = strings.TrimPrefix(field, goEmbedAllPrefix)
+			}
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns, nil
+}
+
+// splitGoEmbedArgs splits the arguments of a go:embed directive. Patterns
+// are separated by spaces and may be quoted using go string syntax.
+func splitGoEmbedArgs(args string) ([]string, error) {
+	fields := []string{}
+	args = strings.TrimSpace(args)
+	for args != "" {
+		switch args[0] {
+		case '"', '`':
+			end := strings.IndexByte(args[1:], args[0])
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated quoted pattern: %s", args)
+			}
+			s, err := strconv.Unquote(args[:end+2])
+			if err != nil {
+				return nil, fmt.Errorf("unquoting pattern: %w", err)
+			}
+			fields = append(fields, s)
+			args = args[end+2:]
+		default:
+			end := strings.IndexAny(args, " \t")
+			if end == -1 {
+				end = len(args)
+			}
+			fields = append(fields, args[:end])
+			args = args[end:]
+		}
+		args = strings.TrimSpace(args)
+	}
+	return fields, nil
+}
+
+// matchesGoEmbedPattern checks if a file path (relative to the directory
+// of the go file declaring the pattern) is embedded by it. Following the
+// embed package rules, when the pattern names a directory, all files under
+// it are embedded except those beginning with '.' or '_' unless the
+// pattern has the all: prefix.
+func matchesGoEmbedPattern(pattern goEmbedPattern, relPath string) bool {
+	parts := strings.Split(relPath, "/")
+	for i := 1; i <= len(parts); i++ {
+		match, err := path.Match(pattern.Pattern, strings.Join(parts[:i], "/"))
+		if err != nil || !match {
+			continue
+		}
+		// The pattern names the file itself
+		if i == len(parts) {
+			return true
+		}
+		// The pattern names a directory containing the file
+		if pattern.All {
+			return true
+		}
+		hidden := false
+		for _, p := range parts[i:] {
+			if strings.HasPrefix(p, ".") || strings.HasPrefix(p, "_") {
+				hidden = true
+				break
+			}
+		}
+		if !hidden {
+			return true
+		}
+	}
+	return false
+}
+
+// linkGoEmbeddedFiles looks for go:embed directives in the go source files
+// of a package and links the files they embed to the source file declaring
+// them with a DATA_FILE_OF relationship. Source files that cannot be read
+// or parsed are skipped with a warning.
+func (di *spdxDefaultImplementation) linkGoEmbeddedFiles(opts *Options, pkg *Package) {
+	files := pkg.Files()
+	for _, goFile := range files {
+		if filepath.Ext(goFile.FileName) != ".go" || goFile.SourceFile == "" {
+			continue
+		}
+		patterns, err := readGoEmbedPatterns(goFile.SourceFile)
+		if err != nil {
+			di.warn(opts, goFile.FileName, "Skipping go:embed directives of %s: %v", goFile.FileName, err)
+			continue
+		}
+		if len(patterns) == 0 {
+			continue
+		}
+
+		goDir := path.Dir(filepath.ToSlash(goFile.FileName))
+		for _, f := range files {
+			if f == goFile {
+				continue
+			}
+			relPath := filepath.ToSlash(f.FileName)
+			if goDir != "." {
+				if !strings.HasPrefix(relPath, goDir+"/") {
+					continue
+				}
+				relPath = strings.TrimPrefix(relPath, goDir+"/")
+			}
+			for _, pattern := range patterns {
+				if !matchesGoEmbedPattern(pattern, relPath) {
+					continue
+				}
+				logger(opts).Debugf("File %s is embedded in %s", f.FileName, goFile.FileName)
+				f.AddRelationship(&Relationship{
+					Peer:    goFile,
+					Type:    DATA_FILE_OF,
+					Comment: "Embedded with go:embed",
+				})
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitGoEmbedArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args        string
+		expected    []string
+		shouldError bool
+	}{
+		{"file.txt", []string{"file.txt"}, false},
+		{" a.txt  b/*.json ", []string{"a.txt", "b/*.json"}, false},
+		{`"with space.txt" plain`, []string{"with space.txt", "plain"}, false},
+		{"`raw.txt` all:static", []string{"raw.txt", "all:static"}, false},
+		{`"unterminated`, nil, true},
+	} {
+		res, err := splitGoEmbedArgs(tc.args)
+		if tc.shouldError {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, res)
+	}
+}
+
+func TestMatchesGoEmbedPattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern  goEmbedPattern
+		path     string
+		expected bool
+	}{
+		{goEmbedPattern{Pattern: "hello.txt"}, "hello.txt", true},
+		{goEmbedPattern{Pattern: "*.txt"}, "hello.txt", true},
+		{goEmbedPattern{Pattern: "*.txt"}, "data/hello.txt", false},
+		{goEmbedPattern{Pattern: "data"}, "data/hello.txt", true},
+		{goEmbedPattern{Pattern: "data"}, "data/.hidden", false},
+		{goEmbedPattern{Pattern: "data"}, "data/_tmp/file", false},
+		{goEmbedPattern{Pattern: "data", All: true}, "data/.hidden", true},
+		{goEmbedPattern{Pattern: "data/.hidden"}, "data/.hidden", true},
+		{goEmbedPattern{Pattern: "other"}, "data/hello.txt", false},
+	} {
+		require.Equal(t, tc.expected, matchesGoEmbedPattern(tc.pattern, tc.path), tc.path)
+	}
+}
+
+func TestLinkGoEmbeddedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":                "package main\n\nimport _ \"embed\"\n\n//go:embed static\nvar s string\n",
+		"static/index.html":      "<html></html>",
+		"cmd/tool/tool.go":       "package tool\n\n//go:embed \"banner.txt\"\nvar banner string\n",
+		"cmd/tool/banner.txt":    "hello",
+		"cmd/tool/not-used.txt":  "not embedded",
+		"static/.hidden-file.md": "hidden",
+	}
+	pkg := NewPackage()
+	pkg.Name = "test"
+	for path, data := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(data), os.FileMode(0o644)))
+		f := NewFile()
+		f.Options().WorkDir = dir
+		require.NoError(t, f.ReadSourceFile(filepath.Join(dir, path)))
+		require.NoError(t, pkg.AddFile(f))
+	}
+
+	impl := spdxDefaultImplementation{}
+	impl.linkGoEmbeddedFiles(&Options{}, pkg)
+
+	linked := map[string]string{}
+	for _, f := range pkg.Files() {
+		for _, rel := range f.Relationships {
+			require.Equal(t, DATA_FILE_OF, rel.Type)
+			linked[f.FileName] = rel.Peer.(*File).FileName
+		}
+	}
+	require.Equal(t, map[string]string{
+		"static/index.html":   "main.go",
+		"cmd/tool/banner.txt": "cmd/tool/tool.go",
+	}, linked)
+}
+
+func TestReadGoEmbedPatternsLongLines(t *testing.T) {
+	// Generated sources have lines longer than the 64 KiB of a bufio.Scanner
+	goFile := filepath.Join(t.TempDir(), "bindata.go")
+	require.NoError(t, os.WriteFile(goFile, []byte(
+		"package data\n\nvar blob = \""+strings.Repeat("x", 70*1024)+"\"\n\n//go:embed assets\nvar assets string",
+	), os.FileMode(0o644)))
+
+	patterns, err := readGoEmbedPatterns(goFile)
+	require.NoError(t, err)
+	require.Equal(t, []goEmbedPattern{{Pattern: "assets"}}, patterns)
+}
+
+func TestLinkGoEmbeddedFilesWarnings(t *testing.T) {
+	dir := t.TempDir()
+	pkg := NewPackage()
+	pkg.Name = "test"
+	for path, data := range map[string]string{
+		"broken.go": "package main\n\n//go:embed \"unterminated\nvar s string\n",
+		"main.go":   "package main\n\n//go:embed data.txt\nvar s string\n",
+		"data.txt":  "hello",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(data), os.FileMode(0o644)))
+		f := NewFile()
+		f.Options().WorkDir = dir
+		require.NoError(t, f.ReadSourceFile(filepath.Join(dir, path)))
+		require.NoError(t, pkg.AddFile(f))
+	}
+
+	// The file that cannot be parsed is skipped, the others are linked
+	impl := spdxDefaultImplementation{}
+	impl.linkGoEmbeddedFiles(&Options{CollectWarnings: true}, pkg)
+	warnings := impl.Warnings()
+	require.Len(t, warnings, 1)
+	require.Equal(t, "broken.go", warnings[0].Element)
+	for _, f := range pkg.Files() {
+		if f.FileName == "data.txt" {
+			require.Len(t, f.Relationships, 1)
+			require.Equal(t, "main.go", f.Relationships[0].Peer.(*File).FileName)
+		}
+	}
+}
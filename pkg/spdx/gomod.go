@@ -20,31 +20,33 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/nozzle/throttler"
 	purl "github.com/package-url/packageurl-go"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/mod/modfile"
-	"golang.org/x/tools/go/vcs"
+	"golang.org/x/mod/module"
 
 	"sigs.k8s.io/bom/pkg/license"
 	"sigs.k8s.io/release-utils/command"
+	"sigs.k8s.io/release-utils/http"
 	"sigs.k8s.io/release-utils/util"
 )
 
 const (
-	downloadDir   = spdxTempDir + "/gomod-scanner"
 	GoModFileName = "go.mod"
 	GoSumFileName = "go.sum"
-	goModRevPtn   = `v\d+\.\d+\.\d+-[0-9.]+-([a-f0-9]+)` // Match revisions in go modules
-)
 
-var goModRevRe *regexp.Regexp
+	// defaultLicenseAPIURL is the deps.dev endpoint queried for the
+	// licenses of go modules. The module proxy protocol does not
+	// publish license data.
+	defaultLicenseAPIURL = "https://api.deps.dev/v3/systems/go/packages"
+)
 
 // NewGoModule returns a new go module from the specified path
 func NewGoModuleFromPath(path string) (*GoModule, error) {
@@ -72,6 +74,84 @@ type GoModuleOptions struct {
 	Path           string // Path to the dir where go.mod resides
 	OnlyDirectDeps bool   // Only include direct dependencies from go.mod
 	ScanLicenses   bool   // Scan licenses from everypossible place unless false
+	LicenseAPIURL  string // Base URL of the deps.dev compatible API used by LookupLicenses
+	GOOS           string // Target operating system the dependencies are resolved for (defaults to the host)
+	GOARCH         string // Target architecture the dependencies are resolved for (defaults to the host)
+	Workers        int    // Packages downloaded and scanned, or looked up, at the same time (default 10)
+	ClassifyDeps   bool   // Classify the test-only and tool dependencies, listing them with two more go list runs
+
+	// Logger receives the messages logged while resolving the module,
+	// defaults to the logrus standard logger
+	Logger logrus.FieldLogger
+
+	// ProgressFn is called as the licenses of each package are scanned
+	ProgressFn func(ProgressEvent)
+
+	// Env is added to the environment of the process for the go commands
+	// resolving and downloading the dependencies (eg
+	// GOPROXY=https://proxy.example.com).
+	// The go tool honors GOPROXY, GOPRIVATE, GONOPROXY, GONOSUMDB, GOSUMDB,
+	// GOINSECURE and GOFLAGS, there is no GONOSUMCHECK variable. The
+	// modules matched by GOPRIVATE or GONOPROXY are never sent to public
+	// services: their licenses are not looked up online and their download
+	// location is their repository instead of the public module proxy.
+	Env []string
+}
+
+// defaultGoModuleWorkers is the number of packages whose licenses are
+// resolved at the same time when the options don't set it
+const defaultGoModuleWorkers = 10
+
+// workers returns the number of packages processed at the same time
+func (opts *GoModuleOptions) workers() int {
+	if opts.Workers > 0 {
+		return opts.Workers
+	}
+	return defaultGoModuleWorkers
+}
+
+// log returns the logger of the options
+func (opts *GoModuleOptions) log() logrus.FieldLogger {
+	if opts.Logger == nil {
+		return logrus.StandardLogger()
+	}
+	return opts.Logger
+}
+
+// goEnv returns the environment added to the go commands
+func (opts *GoModuleOptions) goEnv() []string {
+	return append(append([]string{}, opts.Env...), opts.targetEnv()...)
+}
+
+// getenv returns the value of a variable of the go environment, set in
+// the options or else in the process
+func (opts *GoModuleOptions) getenv(key string) string {
+	for i := len(opts.Env) - 1; i >= 0; i-- {
+		if value, ok := strings.CutPrefix(opts.Env[i], key+"="); ok {
+			return value
+		}
+	}
+	return os.Getenv(key)
+}
+
+// privateModule returns true if the module path matches the GOPRIVATE or
+// GONOPROXY patterns, as the go tool does
+func (opts *GoModuleOptions) privateModule(path string) bool {
+	return module.MatchPrefixPatterns(opts.getenv("GOPRIVATE"), path) ||
+		module.MatchPrefixPatterns(opts.getenv("GONOPROXY"), path)
+}
+
+// targetEnv returns the environment to have the go tool resolve the
+// dependencies for the target platform set in the options
+func (opts *GoModuleOptions) targetEnv() []string {
+	env := []string{}
+	if opts.GOOS != "" {
+		env = append(env, "GOOS="+opts.GOOS)
+	}
+	if opts.GOARCH != "" {
+		env = append(env, "GOARCH="+opts.GOARCH)
+	}
+	return env
 }
 
 // Options returns a pointer to the module options set
@@ -88,12 +168,32 @@ type GoPackage struct {
 	LocalInstall  string
 	LicenseID     string
 	CopyrightText string
+	Private       bool // Matched by GOPRIVATE or GONOPROXY, not looked up in public services
+	TestOnly      bool // Only imported by the tests of the module
+	Tool          bool // Only imported by the build tools of the module (tool directives or tools build tag)
 }
 
-// SPDXPackage builds a spdx package from the go package data
+// dependencyType returns the relationship of the package to the module
+// depending on it, empty for the dependencies of the module itself
+func (pkg *GoPackage) dependencyType() RelationshipType {
+	switch {
+	case pkg.Tool:
+		return BUILD_TOOL_OF
+	case pkg.TestOnly:
+		return TEST_DEPENDENCY_OF
+	}
+	return ""
+}
+
+// SPDXPackage builds a spdx package from the go package data. The
+// repositories of modules are resolved offline, from their import paths.
 func (pkg *GoPackage) ToSPDXPackage() (*Package, error) {
-	repo, err := vcs.RepoRootForImportPath(pkg.ImportPath, true)
-	if err != nil {
+	return pkg.toSPDXPackage(newGoRepositoryResolver(nil))
+}
+
+// toSPDXPackage builds the spdx package resolving repositories with resolver
+func (pkg *GoPackage) toSPDXPackage(resolver *goRepositoryResolver) (*Package, error) {
+	if err := validGoImportPath(pkg.ImportPath); err != nil {
 		return nil, fmt.Errorf("building repository from package import path: %w", err)
 	}
 	spdxPackage := NewPackage()
@@ -101,17 +201,15 @@ func (pkg *GoPackage) ToSPDXPackage() (*Package, error) {
 	spdxPackage.Name = pkg.ImportPath
 
 	spdxPackage.BuildID(pkg.ImportPath, pkg.Revision)
-	if strings.Contains(pkg.Revision, "+incompatible") {
-		spdxPackage.DownloadLocation = repo.VCS.Scheme[0] + "+" + repo.Repo
+	if pkg.Private {
+		spdxPackage.DownloadLocation = goRepositoryDownloadLocation(resolver, pkg.ImportPath, pkg.Revision)
 	} else {
-		spdxPackage.DownloadLocation = fmt.Sprintf(
-			"https://proxy.golang.org/%s/@v/%s.zip", pkg.ImportPath,
-			strings.TrimSuffix(pkg.Revision, "+incompatible"),
-		)
+		spdxPackage.DownloadLocation = goDownloadLocation(resolver, pkg.ImportPath, pkg.Revision)
 	}
 	spdxPackage.LicenseConcluded = pkg.LicenseID
 	spdxPackage.Version = strings.TrimSuffix(pkg.Revision, "+incompatible")
 	spdxPackage.CopyrightText = pkg.CopyrightText
+	spdxPackage.dependencyType = pkg.dependencyType()
 	if packageurl := pkg.PackageURL(); packageurl != "" {
 		spdxPackage.ExternalRefs = append(spdxPackage.ExternalRefs, ExternalRef{
 			Category: CatPackageManager,
@@ -122,6 +220,44 @@ func (pkg *GoPackage) ToSPDXPackage() (*Package, error) {
 	return spdxPackage, nil
 }
 
+// goDownloadLocation returns where the source of a go module can be
+// downloaded from. Released versions are downloaded from the module proxy,
+// incompatible and unversioned modules from their repository. Modules
+// whose repository cannot be resolved get NOASSERTION.
+func goDownloadLocation(resolver *goRepositoryResolver, importPath, revision string) string {
+	version := strings.TrimSuffix(revision, "+incompatible")
+	if version != "" && version == revision {
+		return fmt.Sprintf("https://proxy.golang.org/%s/@v/%s.zip", importPath, version)
+	}
+	return goRepositoryDownloadLocation(resolver, importPath, revision)
+}
+
+// goRepositoryDownloadLocation returns the repository of a go module at
+// the revision as its download location, or NOASSERTION if the repository
+// cannot be resolved
+func goRepositoryDownloadLocation(resolver *goRepositoryResolver, importPath, revision string) string {
+	version := strings.TrimSuffix(revision, "+incompatible")
+	repo, err := resolver.resolve(importPath)
+	if err != nil {
+		resolver.log.Debugf("Unable to find the repository of %s: %v", importPath, err)
+		return NOASSERTION
+	}
+	if version != "" {
+		return repo.downloadLocation() + "@" + version
+	}
+	return repo.downloadLocation()
+}
+
+// goModulePath returns the module path declared in the go.mod file at the
+// root of dirPath, or an empty string if there is none or it cannot be read
+func goModulePath(dirPath string) string {
+	data, err := os.ReadFile(filepath.Join(dirPath, GoModFileName))
+	if err != nil {
+		return ""
+	}
+	return modfile.ModulePath(data)
+}
+
 func nsAndNameFromImportPath(importPath string) (namespace, packageName string) {
 	lastSlashIndex := strings.LastIndex(importPath, "/")
 	if lastSlashIndex == -1 {
@@ -169,12 +305,18 @@ func (mod *GoModule) Open() error {
 	var pkgs []*GoPackage
 	if mod.Options().OnlyDirectDeps {
 		pkgs, err = mod.impl.BuildPackageList(mod.GoMod)
+		if err == nil && (mod.opts.ClassifyDeps || len(mod.opts.targetEnv()) > 0) {
+			pkgs, err = mod.resolveDirectDeps(pkgs)
+		}
 	} else {
 		pkgs, err = mod.BuildFullPackageList(mod.GoMod)
 	}
 	if err != nil {
 		return fmt.Errorf("building module package list: %w", err)
 	}
+	for _, pkg := range pkgs {
+		pkg.Private = mod.opts.privateModule(pkg.ImportPath)
+	}
 	mod.Packages = pkgs
 	return nil
 }
@@ -186,7 +328,7 @@ func (mod *GoModule) RemoveDownloads() error {
 
 // DownloadPackages downloads all the module's packages to the local disk
 func (mod *GoModule) DownloadPackages() error {
-	logrus.Infof("Downloading source code for %d packages", len(mod.Packages))
+	mod.opts.log().Infof("Downloading source code for %d packages", len(mod.Packages))
 	if mod.Packages == nil {
 		return errors.New("unable to download packages, package list is nil")
 	}
@@ -210,38 +352,39 @@ func (mod *GoModule) ScanLicenses() error {
 		return fmt.Errorf("creating license scanner: %w", err)
 	}
 
-	logrus.Infof("Scanning licenses for %d go packages", len(mod.Packages))
+	mod.opts.log().Infof("Scanning licenses for %d go packages", len(mod.Packages))
 
-	// Create a new Throttler that will get parallelDownloads urls at a time
-	t := throttler.New(10, len(mod.Packages))
-	// Do a quick re-check for missing downloads
-	// todo: paralelize this. urgently.
+	// The packages are downloaded and scanned in parallel, each one
+	// records its license itself so the order of the list is kept
+	t := throttler.New(mod.opts.workers(), len(mod.Packages))
+	progress := newProgressCounter(mod.opts.ProgressFn, ProgressPhaseLicenseScan, len(mod.Packages))
 	for _, pkg := range mod.Packages {
 		// Launch a goroutine to fetch the package contents
 		go func(curPkg *GoPackage) {
-			logrus.WithField(
+			mod.opts.log().WithField(
 				"package", curPkg.ImportPath).Debugf(
 				"Downloading package (%d total)", len(mod.Packages),
 			)
-			defer t.Done(err)
+			defer t.Done(nil)
+			defer progress.done()
 			if curPkg.LocalInstall == "" {
 				// Call download with no force in case local data is missing
 				if err2 := mod.impl.DownloadPackage(curPkg, mod.opts, false); err2 != nil {
 					// If we're unable to download the module we dont treat it as
 					// fatal, package will remain without license info but we go
 					// on scanning the rest of the packages.
-					logrus.WithField("package", curPkg.ImportPath).Error(err2)
+					mod.opts.log().WithField("package", curPkg.ImportPath).Error(err2)
 					return
 				}
 			} else {
-				logrus.WithField("package", curPkg.ImportPath).Debugf(
+				mod.opts.log().WithField("package", curPkg.ImportPath).Debugf(
 					"There is a local copy of %s@%s", curPkg.ImportPath, curPkg.Revision,
 				)
 			}
 
-			if err = mod.impl.ScanPackageLicense(curPkg, reader, mod.opts); err != nil {
-				logrus.WithField("package", curPkg.ImportPath).Errorf(
-					"scanning package %s for licensing info", curPkg.ImportPath,
+			if err := mod.impl.ScanPackageLicense(curPkg, reader, mod.opts); err != nil {
+				mod.opts.log().WithField("package", curPkg.ImportPath).Errorf(
+					"scanning package %s for licensing info: %v", curPkg.ImportPath, err,
 				)
 			}
 		}(pkg)
@@ -255,48 +398,188 @@ func (mod *GoModule) ScanLicenses() error {
 	return nil
 }
 
-// BuildFullPackageList return the complete of packages imported into
-// the module, instead of reading go.mod, this functions calls
-// go list and works from there
-func (mod *GoModule) BuildFullPackageList(g *modfile.File) (packageList []*GoPackage, err error) {
-	packageList = []*GoPackage{}
+// LookupLicenses queries the licenses of the packages without one from
+// the deps.dev API instead of downloading and scanning their source code.
+// Lookup failures are not fatal, the packages are left without a license.
+func (mod *GoModule) LookupLicenses() error {
+	if mod.Packages == nil {
+		return errors.New("unable to look up licenses, package list is nil")
+	}
+	apiURL := mod.opts.LicenseAPIURL
+	if apiURL == "" {
+		apiURL = defaultLicenseAPIURL
+	}
 
-	// If no go.sum is found, then there are no deps
-	if !util.Exists(filepath.Join(mod.opts.Path, GoSumFileName)) {
-		return packageList, nil
+	mod.opts.log().Infof("Looking up licenses for %d go packages", len(mod.Packages))
+	t := throttler.New(mod.opts.workers(), len(mod.Packages))
+	for _, pkg := range mod.Packages {
+		go func(curPkg *GoPackage) {
+			defer t.Done(nil)
+			if curPkg.LicenseID != "" {
+				return
+			}
+			if curPkg.Private {
+				mod.opts.log().WithField("package", curPkg.ImportPath).Debug("Not looking up the license of a private module")
+				return
+			}
+			licenseID, err := lookupGoLicense(apiURL, curPkg)
+			if err != nil {
+				mod.opts.log().WithField("package", curPkg.ImportPath).Warnf("looking up license: %v", err)
+				return
+			}
+			curPkg.LicenseID = licenseID
+		}(pkg)
+		t.Throttle()
 	}
+	return nil
+}
 
-	gobin, err := exec.LookPath("go")
+// goVersionInfo is the part of the deps.dev version data read by bom
+type goVersionInfo struct {
+	Licenses []string `json:"licenses"`
+}
+
+// lookupGoLicense fetches the licenses of a go package version from
+// the API and returns them as a license expression
+func lookupGoLicense(apiURL string, pkg *GoPackage) (string, error) {
+	if pkg.ImportPath == "" || pkg.Revision == "" {
+		return "", errors.New("package has no import path or version")
+	}
+	data, err := http.NewAgent().Get(fmt.Sprintf(
+		"%s/%s/versions/%s", strings.TrimSuffix(apiURL, "/"),
+		url.PathEscape(pkg.ImportPath), url.PathEscape(pkg.Revision),
+	))
 	if err != nil {
-		return nil, errors.New("unable to get full list of packages, go executbale not found ")
+		return "", fmt.Errorf("fetching version data: %w", err)
+	}
+	info := goVersionInfo{}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return "", fmt.Errorf("parsing version data: %w", err)
 	}
 
-	gorun := command.NewWithWorkDir(mod.opts.Path, gobin, "list", "-deps", "-e", "-json", "./...")
-	output, err := gorun.RunSilentSuccessOutput()
+	licenses := []string{}
+	for _, l := range info.Licenses {
+		// deps.dev reports licenses it cannot identify as non-standard
+		if l != "" && l != "non-standard" {
+			licenses = append(licenses, l)
+		}
+	}
+	if len(licenses) == 0 {
+		return "", nil
+	}
+	if len(licenses) == 1 {
+		return licenses[0], nil
+	}
+	for i := range licenses {
+		if strings.Contains(licenses[i], " ") {
+			licenses[i] = "(" + licenses[i] + ")"
+		}
+	}
+	return strings.Join(licenses, " AND "), nil
+}
+
+// resolveDirectDeps classifies the direct dependencies of the module as
+// test-only or tools as the full package list does, when the options ask
+// for it. When a target platform
+// is set, the dependencies not imported when building the module for it
+// are dropped, eg those only used on other systems.
+func (mod *GoModule) resolveDirectDeps(pkgs []*GoPackage) ([]*GoPackage, error) {
+	// Without go.sum the package list cannot be resolved
+	if !util.Exists(filepath.Join(mod.opts.Path, GoSumFileName)) {
+		return pkgs, nil
+	}
+	targeted := len(mod.opts.targetEnv()) > 0
+	fullList, err := mod.BuildFullPackageList(mod.GoMod)
 	if err != nil {
-		return nil, fmt.Errorf("while calling go to get full list of deps: %w", err)
+		if targeted {
+			return nil, fmt.Errorf("resolving dependencies for the target platform: %w", err)
+		}
+		// Without the full list the dependencies are just not classified
+		mod.opts.log().Warnf("Could not classify the test and tool dependencies: %v", err)
+		return pkgs, nil
+	}
+	resolved := map[string]*GoPackage{}
+	for _, pkg := range fullList {
+		resolved[pkg.ImportPath] = pkg
+	}
+	filtered := []*GoPackage{}
+	for _, pkg := range pkgs {
+		dep, ok := resolved[pkg.ImportPath]
+		if !ok && targeted {
+			mod.opts.log().Infof("Dropping %s, not imported for the target platform", pkg.ImportPath)
+			continue
+		}
+		if ok {
+			pkg.TestOnly = dep.TestOnly
+			pkg.Tool = dep.Tool
+		}
+		filtered = append(filtered, pkg)
+	}
+	return filtered, nil
+}
+
+// goToolPackages returns the packages listed in the tool directives of
+// go.mod. The go.mod parser predates them, they are read from the syntax.
+func goToolPackages(g *modfile.File) []string {
+	tools := []string{}
+	if g == nil || g.Syntax == nil {
+		return tools
+	}
+	for _, stmt := range g.Syntax.Stmt {
+		switch s := stmt.(type) {
+		case *modfile.Line:
+			if len(s.Token) == 2 && s.Token[0] == "tool" {
+				tools = append(tools, s.Token[1])
+			}
+		case *modfile.LineBlock:
+			if len(s.Token) != 1 || s.Token[0] != "tool" {
+				continue
+			}
+			for _, line := range s.Line {
+				if len(line.Token) == 1 {
+					tools = append(tools, line.Token[0])
+				}
+			}
+		}
 	}
+	return tools
+}
 
-	type ModEntry struct {
-		DepOnly bool `json:"DepOnly,omitempty"`
-		Main    bool `json:"Main,omitempty"`
-		Module  struct {
-			Path     string `json:"Path,omitempty"`    // Path is theImportPath
-			Main     bool   `json:"Main,omitempty"`    // true if its the main module (eg k/release)
-			Dir      string `json:"Dir,omitempty"`     // The source can be found here
-			GoMod    string `json:"GoMod,omitempty"`   // Or cached here
-			Version  string `json:"Version,omitempty"` // PAckage version
-			Indirect bool   `json:"Indirect,omitempty"`
-			Replace  *struct {
-				Dir string `json:"Dir,omitempty"`
-			} `json:"Replace,omitempty"`
-		} `json:"Module,omitempty"`
+// goListEntry is a package listed by go list, with its module
+type goListEntry struct {
+	DepOnly bool `json:"DepOnly,omitempty"`
+	Main    bool `json:"Main,omitempty"`
+	Module  struct {
+		Path     string `json:"Path,omitempty"`    // Path is theImportPath
+		Main     bool   `json:"Main,omitempty"`    // true if its the main module (eg k/release)
+		Dir      string `json:"Dir,omitempty"`     // The source can be found here
+		GoMod    string `json:"GoMod,omitempty"`   // Or cached here
+		Version  string `json:"Version,omitempty"` // PAckage version
+		Indirect bool   `json:"Indirect,omitempty"`
+		Replace  *struct {
+			Dir string `json:"Dir,omitempty"`
+		} `json:"Replace,omitempty"`
+	} `json:"Module,omitempty"`
+}
+
+// goListModules runs go list -deps with args in the module, returning the
+// modules of the packages listed, other than the main one, by path and
+// version
+func (mod *GoModule) goListModules(gobin string, args ...string) (map[string]map[string]*goListEntry, error) {
+	// Packages are only listed when imported under the build constraints
+	// of the platform, so setting a target leaves out the dependencies
+	// used only on other systems or architectures.
+	gorun := command.NewWithWorkDir(mod.opts.Path, gobin, append([]string{"list", "-deps", "-e", "-json"}, args...)...).
+		Env(mod.opts.goEnv()...)
+	output, err := gorun.RunSilentSuccessOutput()
+	if err != nil {
+		return nil, fmt.Errorf("while calling go to get full list of deps: %w", err)
 	}
 
 	dec := json.NewDecoder(strings.NewReader(output.Output()))
-	list := map[string]map[string]*ModEntry{}
+	list := map[string]map[string]*goListEntry{}
 	for dec.More() {
-		m := &ModEntry{}
+		m := &goListEntry{}
 		// Decode the json stream as we get "Module" blocks from go:
 		if err := dec.Decode(m); err != nil {
 			return nil, fmt.Errorf("decoding module list: %w", err)
@@ -308,7 +591,7 @@ func (mod *GoModule) BuildFullPackageList(g *modfile.File) (packageList []*GoPac
 			}
 
 			if _, ok := list[m.Module.Path]; !ok {
-				list[m.Module.Path] = map[string]*ModEntry{}
+				list[m.Module.Path] = map[string]*goListEntry{}
 			}
 
 			// Go list will return modules with a specific version
@@ -329,7 +612,62 @@ func (mod *GoModule) BuildFullPackageList(g *modfile.File) (packageList []*GoPac
 			}
 		}
 	}
-	logrus.Info("Adding full list of dependencies:")
+	return list, nil
+}
+
+// BuildFullPackageList return the complete of packages imported into
+// the module, instead of reading go.mod, this functions calls
+// go list and works from there
+func (mod *GoModule) BuildFullPackageList(g *modfile.File) (packageList []*GoPackage, err error) {
+	packageList = []*GoPackage{}
+
+	// If no go.sum is found, then there are no deps
+	if !util.Exists(filepath.Join(mod.opts.Path, GoSumFileName)) {
+		return packageList, nil
+	}
+
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		return nil, errors.New("unable to get full list of packages, go executbale not found ")
+	}
+
+	list, err := mod.goListModules(gobin, "./...")
+	if err != nil {
+		return nil, err
+	}
+
+	// When classifying the dependencies, the modules only imported by the
+	// tests or the tools of the module are listed too, classified. They are
+	// not needed to build it, so failing to list them leaves them out with
+	// a warning.
+	testOnly := map[string]struct{}{}
+	tools := map[string]struct{}{}
+	extras := []struct {
+		args  []string
+		found map[string]struct{}
+	}{
+		{append([]string{"-tags", "tools", "./..."}, goToolPackages(g)...), tools},
+		{[]string{"-test", "./..."}, testOnly},
+	}
+	if !mod.opts.ClassifyDeps {
+		extras = nil
+	}
+	for _, extra := range extras {
+		extraList, err := mod.goListModules(gobin, extra.args...)
+		if err != nil {
+			mod.opts.log().Warnf("Could not list the test and tool dependencies: %v", err)
+			continue
+		}
+		for path, versions := range extraList {
+			if _, ok := list[path]; ok {
+				continue
+			}
+			list[path] = versions
+			extra.found[path] = struct{}{}
+		}
+	}
+
+	mod.opts.log().Info("Adding full list of dependencies:")
 	for _, versions := range list {
 		for _, fmod := range versions {
 			dep := &GoPackage{
@@ -338,6 +676,8 @@ func (mod *GoModule) BuildFullPackageList(g *modfile.File) (packageList []*GoPac
 				LocalDir:     "",
 				LocalInstall: "",
 			}
+			_, dep.Tool = tools[dep.ImportPath]
+			_, dep.TestOnly = testOnly[dep.ImportPath]
 			status := ""
 			if fmod.Module.Dir != "" && util.Exists(fmod.Module.Dir) {
 				dep.LocalInstall = fmod.Module.Dir
@@ -349,7 +689,7 @@ func (mod *GoModule) BuildFullPackageList(g *modfile.File) (packageList []*GoPac
 				fmod.Module.Replace.Dir != "" &&
 				// If the local directory exists:
 				util.Exists(fmod.Module.Replace.Dir) {
-				logrus.Infof(
+				mod.opts.log().Infof(
 					"Package %s has local replacement in %s",
 					dep.ImportPath, fmod.Module.Replace.Dir,
 				)
@@ -357,11 +697,16 @@ func (mod *GoModule) BuildFullPackageList(g *modfile.File) (packageList []*GoPac
 				status = "(has a local replacement)"
 			}
 
-			logrus.Infof(" > %s@%s %s", dep.ImportPath, dep.Revision, status)
+			if dep.Tool {
+				status += " (build tool)"
+			} else if dep.TestOnly {
+				status += " (test only)"
+			}
+			mod.opts.log().Infof(" > %s@%s %s", dep.ImportPath, dep.Revision, status)
 			packageList = append(packageList, dep)
 		}
 	}
-	logrus.Infof("Found %d packages from full dependency tree", len(packageList))
+	mod.opts.log().Infof("Found %d packages from full dependency tree", len(packageList))
 	return packageList, nil
 }
 
@@ -379,7 +724,7 @@ func (di *GoModDefaultImpl) OpenModule(opts *GoModuleOptions) (*modfile.File, er
 	if err != nil {
 		return nil, fmt.Errorf("reading go.mod: %w", err)
 	}
-	logrus.Infof(
+	opts.log().Infof(
 		"Parsed go.mod file for %s, found %d direct dependencies",
 		gomod.Module.Mod.Path, len(gomod.Require),
 	)
@@ -403,61 +748,62 @@ func (di *GoModDefaultImpl) BuildPackageList(gomod *modfile.File) ([]*GoPackage,
 //	the download dir in the LocalDir field
 func (di *GoModDefaultImpl) DownloadPackage(pkg *GoPackage, opts *GoModuleOptions, force bool) error {
 	if pkg.LocalDir != "" && util.Exists(pkg.LocalDir) && !force {
-		logrus.WithField("package", pkg.ImportPath).Infof("Not downloading %s as it already has local data", pkg.ImportPath)
+		opts.log().WithField("package", pkg.ImportPath).Infof("Not downloading %s as it already has local data", pkg.ImportPath)
 		return nil
 	}
 
-	logrus.WithField("package", pkg.ImportPath).Debugf("Downloading package %s@%s", pkg.ImportPath, pkg.Revision)
-	repo, err := vcs.RepoRootForImportPath(pkg.ImportPath, true)
+	gobin, err := exec.LookPath("go")
 	if err != nil {
-		repoName := "[unknown repo]"
-		if repo != nil {
-			repoName = repo.Repo
-		}
-		return fmt.Errorf("fetching package %s from %s: %w", pkg.ImportPath, repoName, err)
+		return errors.New("unable to download package, go executable not found")
 	}
 
-	if !util.Exists(filepath.Join(os.TempDir(), downloadDir)) {
-		if err := os.MkdirAll(
-			filepath.Join(os.TempDir(), downloadDir), os.FileMode(0o755),
-		); err != nil {
-			return fmt.Errorf("creating parent tmpdir: %w", err)
-		}
+	// The module is downloaded by the go tool, with the environment of the
+	// options, so it honors GOPROXY, GOPRIVATE, GOFLAGS and the like as
+	// the go list runs resolving the dependencies do
+	version := pkg.Revision
+	if version == "" {
+		version = "latest"
 	}
-
-	// Create tempdir
-	tmpDir, err := os.MkdirTemp(filepath.Join(os.TempDir(), downloadDir), "package-download-")
+	opts.log().WithField("package", pkg.ImportPath).Debugf("Downloading package %s@%s", pkg.ImportPath, version)
+	output, err := command.NewWithWorkDir(
+		opts.Path, gobin, "mod", "download", "-json", pkg.ImportPath+"@"+version,
+	).Env(opts.goEnv()...).RunSilent()
 	if err != nil {
-		return fmt.Errorf("creating temporary dir: %w", err)
+		return fmt.Errorf("downloading package %s: %w", pkg.ImportPath, err)
 	}
-	// Create a clone of the module repo at the revision
-	rev := strings.TrimSuffix(pkg.Revision, "+incompatible")
 
-	// Strip the revision from the whole string part
-	if goModRevRe == nil {
-		goModRevRe = regexp.MustCompile(goModRevPtn)
+	// go mod download prints the JSON with the error when it fails
+	download := &goModDownload{}
+	if err := json.Unmarshal([]byte(output.Output()), download); err != nil {
+		if !output.Success() {
+			return fmt.Errorf("downloading package %s: %s", pkg.ImportPath, strings.TrimSpace(output.Error()))
+		}
+		return fmt.Errorf("decoding the download of package %s: %w", pkg.ImportPath, err)
 	}
-	m := goModRevRe.FindStringSubmatch(pkg.Revision)
-	if len(m) > 1 {
-		rev = m[1]
-		logrus.WithField("package", pkg.ImportPath).Infof("Using commit %s as revision for download", rev)
+	if download.Error != "" {
+		return fmt.Errorf("downloading package %s: %s", pkg.ImportPath, download.Error)
 	}
-	if rev == "" {
-		if err := repo.VCS.Create(tmpDir, repo.Repo); err != nil {
-			return fmt.Errorf("creating local clone of %s: %w", repo.Repo, err)
-		}
-	} else {
-		if err := repo.VCS.CreateAtRev(tmpDir, repo.Repo, rev); err != nil {
-			return fmt.Errorf("creating local clone of %s: %w", repo.Repo, err)
-		}
+	if !output.Success() || download.Dir == "" {
+		return fmt.Errorf("downloading package %s: %s", pkg.ImportPath, strings.TrimSpace(output.Error()))
 	}
 
-	logrus.WithField("package", pkg.ImportPath).Infof("Go Package %s (rev %s) downloaded to %s", pkg.ImportPath, pkg.Revision, tmpDir)
-	pkg.LocalDir = tmpDir
-	pkg.TmpDir = true
+	opts.log().WithField("package", pkg.ImportPath).Infof(
+		"Go Package %s (version %s) downloaded to %s", pkg.ImportPath, download.Version, download.Dir,
+	)
+	// The module cache is shared, the download is not removed
+	pkg.LocalDir = download.Dir
+	pkg.TmpDir = false
 	return nil
 }
 
+// goModDownload is the output of go mod download -json
+type goModDownload struct {
+	Path    string `json:"Path,omitempty"`
+	Version string `json:"Version,omitempty"`
+	Dir     string `json:"Dir,omitempty"`   // Directory of the module in the module cache
+	Error   string `json:"Error,omitempty"` // Why the module could not be downloaded
+}
+
 // RemoveDownloads takes a list of packages and remove its downloads
 func (di *GoModDefaultImpl) RemoveDownloads(packageList []*GoPackage) error {
 	for _, pkg := range packageList {
@@ -473,7 +819,8 @@ func (di *GoModDefaultImpl) RemoveDownloads(packageList []*GoPackage) error {
 // LicenseReader returns a license reader
 func (di *GoModDefaultImpl) LicenseReader() (*license.Reader, error) {
 	if di.licenseReader == nil {
-		opts := license.DefaultReaderOptions
+		// Copy the defaults, the reader sets its working directory in them
+		opts := *license.DefaultReaderOptions
 		opts.CacheDir = filepath.Join(os.TempDir(), spdxLicenseDlCache)
 		opts.LicenseDir = filepath.Join(os.TempDir(), spdxLicenseData)
 		if !util.Exists(opts.CacheDir) {
@@ -481,7 +828,7 @@ func (di *GoModDefaultImpl) LicenseReader() (*license.Reader, error) {
 				return nil, fmt.Errorf("creating dir: %w", err)
 			}
 		}
-		reader, err := license.NewReaderWithOptions(opts)
+		reader, err := license.NewReaderWithOptions(&opts)
 		if err != nil {
 			return nil, fmt.Errorf("creating reader: %w", err)
 		}
@@ -505,14 +852,14 @@ func (di *GoModDefaultImpl) ScanPackageLicense(
 	}
 
 	if licenseResult != nil {
-		logrus.Debugf(
+		opts.log().Debugf(
 			"Package %s license is %s", pkg.ImportPath,
 			licenseResult.License.LicenseID,
 		)
 		pkg.LicenseID = licenseResult.License.LicenseID
 		pkg.CopyrightText = licenseResult.Text
 	} else {
-		logrus.Warnf("Could not find licensing information for package %s", pkg.ImportPath)
+		opts.log().Warnf("Could not find licensing information for package %s", pkg.ImportPath)
 	}
 	return nil
 }
@@ -17,10 +17,25 @@ limitations under the License.
 package spdx
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	modzip "golang.org/x/mod/zip"
+
+	"sigs.k8s.io/bom/pkg/license"
 )
 
 func TestToSPDXPackage(t *testing.T) {
@@ -54,6 +69,74 @@ func TestToSPDXPackage(t *testing.T) {
 	}
 }
 
+func TestGoDownloadLocation(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("go-get") != "1" || !strings.HasPrefix(r.URL.Path, "/vanity/") {
+			http.NotFound(w, r)
+			return
+		}
+		host := r.Host
+		fmt.Fprintf(w, `<html><head>
+<meta name="go-import" content="%s/vanity mod https://proxy.example.com">
+<meta name="go-import" content="%s/vanity/mod git https://git.example.com/mod">
+</head><body>Not here</body></html>`, host, host)
+	}))
+	defer server.Close()
+	// The certificate of the test server is valid for example.com, which
+	// is dialed at the server address
+	host := "example.com"
+	client := server.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	client.Transport = transport
+	resolver := newGoRepositoryResolver(client)
+
+	for _, tc := range []struct {
+		importPath, revision, expected string
+	}{
+		// Released versions come from the module proxy
+		{"github.com/foo/bar", "v1.2.3", "https://proxy.golang.org/github.com/foo/bar/@v/v1.2.3.zip"},
+		// Incompatible versions come from the repository
+		{"github.com/docker/cli/cli", "v20.10.12+incompatible", "git+https://github.com/docker/cli@v20.10.12"},
+		// Well known hosts and vanity domains
+		{"golang.org/x/term", "", "git+https://go.googlesource.com/term"},
+		{"k8s.io/api/core/v1", "", "git+https://github.com/kubernetes/api"},
+		{"sigs.k8s.io/yaml", "", "git+https://github.com/kubernetes-sigs/yaml"},
+		{"gopkg.in/yaml.v3", "", "git+https://github.com/go-yaml/yaml"},
+		{"gopkg.in/src-d/go-git.v4", "", "git+https://github.com/src-d/go-git"},
+		{"example.com/repo.git/sub", "", "git+https://example.com/repo.git"},
+		// go-import meta tags
+		{host + "/vanity/mod/pkg", "v0.1.0+incompatible", "git+https://git.example.com/mod@v0.1.0"},
+		// Unresolved repositories
+		{host + "/missing", "", NOASSERTION},
+		{"command-line-arguments", "", NOASSERTION},
+	} {
+		require.Equal(t, tc.expected, goDownloadLocation(resolver, tc.importPath, tc.revision), tc.importPath)
+	}
+
+	// Hosts known to go/vcs, IP addresses and ports are never looked up
+	var requests int32
+	counting := newGoRepositoryResolver(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&requests, 1)
+		return transport.RoundTrip(r)
+	})})
+	for _, importPath := range []string{"github.com/foo", "127.0.0.1/vanity/mod", "example.com:8443/vanity/mod"} {
+		require.Equal(t, NOASSERTION, goDownloadLocation(counting, importPath, ""), importPath)
+	}
+	require.Zero(t, atomic.LoadInt32(&requests))
+
+	// Without a client the resolver does not make requests
+	offline := newScanGoRepositoryResolver(&Options{})
+	require.Equal(t, NOASSERTION, goDownloadLocation(offline, host+"/vanity/mod/pkg", ""))
+	require.Equal(t, "git+https://go.googlesource.com/term", goDownloadLocation(offline, "golang.org/x/term", ""))
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
 func TestPackageURL(t *testing.T) {
 	for _, tc := range []struct {
 		pkg      GoPackage
@@ -73,3 +156,300 @@ func TestPackageURL(t *testing.T) {
 		require.Equal(t, tc.expected, tc.pkg.PackageURL())
 	}
 }
+
+func TestLookupLicenses(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.EscapedPath() {
+		case "/github.com%2Fexample%2Fmit/versions/v1.2.0":
+			fmt.Fprint(w, `{"versionKey":{"system":"GO"},"licenses":["MIT"]}`)
+		case "/github.com%2Fexample%2Fdual/versions/v0.1.0":
+			fmt.Fprint(w, `{"licenses":["Apache-2.0","BSD-3-Clause","non-standard"]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	mod := NewGoModule()
+	mod.Options().LicenseAPIURL = server.URL
+	mod.Packages = []*GoPackage{
+		{ImportPath: "github.com/example/mit", Revision: "v1.2.0"},
+		{ImportPath: "github.com/example/dual", Revision: "v0.1.0"},
+		{ImportPath: "github.com/example/missing", Revision: "v1.0.0"},
+		{ImportPath: "github.com/example/scanned", Revision: "v1.0.0", LicenseID: "ISC"},
+	}
+	require.NoError(t, mod.LookupLicenses())
+	require.Equal(t, "MIT", mod.Packages[0].LicenseID)
+	require.Equal(t, "Apache-2.0 AND BSD-3-Clause", mod.Packages[1].LicenseID)
+
+	// Failed lookups leave the package without a license
+	require.Equal(t, "", mod.Packages[2].LicenseID)
+
+	// Packages with a license are not looked up
+	require.Equal(t, "ISC", mod.Packages[3].LicenseID)
+	require.EqualValues(t, 3, atomic.LoadInt32(&requests))
+
+	// The license ends up in the SPDX package
+	spdxPackage, err := mod.Packages[0].ToSPDXPackage()
+	require.NoError(t, err)
+	require.Equal(t, "MIT", spdxPackage.LicenseConcluded)
+}
+
+func TestDownloadPackage(t *testing.T) {
+	// A module proxy in a directory, only reachable with the environment
+	// set in the options
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/lib\n"), os.FileMode(0o644)))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "LICENSE"), []byte("license text\n"), os.FileMode(0o644)))
+	proxy := t.TempDir()
+	versionDir := filepath.Join(proxy, "example.com", "lib", "@v")
+	require.NoError(t, os.MkdirAll(versionDir, os.FileMode(0o755)))
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, "list"), []byte("v1.0.0\n"), os.FileMode(0o644)))
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, "v1.0.0.info"), []byte(`{"Version":"v1.0.0"}`), os.FileMode(0o644)))
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, "v1.0.0.mod"), []byte("module example.com/lib\n"), os.FileMode(0o644)))
+	zipFile, err := os.Create(filepath.Join(versionDir, "v1.0.0.zip"))
+	require.NoError(t, err)
+	require.NoError(t, modzip.CreateFromDir(zipFile, module.Version{Path: "example.com/lib", Version: "v1.0.0"}, src))
+	require.NoError(t, zipFile.Close())
+
+	cache := t.TempDir()
+	opts := &GoModuleOptions{
+		Path: t.TempDir(),
+		Env: []string{
+			"GOPROXY=file://" + filepath.ToSlash(proxy), "GOSUMDB=off", "GOPRIVATE=", "GONOPROXY=",
+			"GOMODCACHE=" + cache, "GOFLAGS=-modcacherw", "GOWORK=off",
+		},
+	}
+	pkg := &GoPackage{ImportPath: "example.com/lib", Revision: "v1.0.0"}
+	impl := &GoModDefaultImpl{}
+	require.NoError(t, impl.DownloadPackage(pkg, opts, true))
+	require.Equal(t, filepath.Join(cache, "example.com", "lib@v1.0.0"), pkg.LocalDir)
+	require.False(t, pkg.TmpDir)
+	require.FileExists(t, filepath.Join(pkg.LocalDir, "LICENSE"))
+
+	// Versions missing from the proxy fail
+	pkg = &GoPackage{ImportPath: "example.com/lib", Revision: "v2.0.0"}
+	require.Error(t, impl.DownloadPackage(pkg, opts, true))
+	require.Empty(t, pkg.LocalDir)
+}
+
+func TestGoModulePrivateModules(t *testing.T) {
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GONOPROXY", "git.example.com")
+
+	opts := &GoModuleOptions{
+		GOOS: "linux",
+		Env:  []string{"GOPROXY=https://proxy.example.com", "GOPRIVATE=github.com/corp,*.internal"},
+	}
+	require.Equal(t, []string{
+		"GOPROXY=https://proxy.example.com", "GOPRIVATE=github.com/corp,*.internal", "GOOS=linux",
+	}, opts.goEnv())
+	require.Equal(t, "https://proxy.example.com", opts.getenv("GOPROXY"))
+	require.Equal(t, "git.example.com", opts.getenv("GONOPROXY"))
+
+	for path, private := range map[string]bool{
+		"github.com/corp/lib":         true,
+		"github.com/corp/lib/v2/pkg":  true,
+		"github.com/corporate/lib":    false,
+		"go.internal/tools":           true,
+		"git.example.com/team/module": true,
+		"github.com/foo/bar":          false,
+	} {
+		require.Equal(t, private, opts.privateModule(path), path)
+	}
+
+	// The licenses of private modules are not looked up
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"licenses":["MIT"]}`)
+	}))
+	defer server.Close()
+	mod := NewGoModule()
+	mod.Options().LicenseAPIURL = server.URL
+	mod.Packages = []*GoPackage{
+		{ImportPath: "github.com/corp/lib", Revision: "v1.0.0", Private: true},
+		{ImportPath: "github.com/foo/bar", Revision: "v1.0.0"},
+	}
+	require.NoError(t, mod.LookupLicenses())
+	require.Equal(t, "", mod.Packages[0].LicenseID)
+	require.Equal(t, "MIT", mod.Packages[1].LicenseID)
+	require.EqualValues(t, 1, atomic.LoadInt32(&requests))
+
+	// Nor are they downloaded from the public module proxy
+	spdxPackage, err := mod.Packages[0].ToSPDXPackage()
+	require.NoError(t, err)
+	require.Equal(t, "git+https://github.com/corp/lib@v1.0.0", spdxPackage.DownloadLocation)
+	spdxPackage, err = mod.Packages[1].ToSPDXPackage()
+	require.NoError(t, err)
+	require.Equal(t, "https://proxy.golang.org/github.com/foo/bar/@v/v1.0.0.zip", spdxPackage.DownloadLocation)
+}
+
+func TestOpenGoModuleTarget(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go executable not found")
+	}
+	// Resolve the dependencies offline from the local replacements only
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.20\n\n" +
+			"require (\n\texample.com/common v0.0.0\n\texample.com/winonly v0.0.0\n)\n\n" +
+			"replace example.com/common => ./common\n\nreplace example.com/winonly => ./winonly\n",
+		"go.sum":             "",
+		"main.go":            "package main\n\nimport _ \"example.com/common\"\n\nfunc main() {}\n",
+		"main_windows.go":    "package main\n\nimport _ \"example.com/winonly\"\n",
+		"common/go.mod":      "module example.com/common\n\ngo 1.20\n",
+		"common/common.go":   "package common\n",
+		"winonly/go.mod":     "module example.com/winonly\n\ngo 1.20\n",
+		"winonly/winonly.go": "package winonly\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), os.FileMode(0o644)))
+	}
+
+	for _, tc := range []struct {
+		goos           string
+		onlyDirectDeps bool
+		expected       []string
+	}{
+		{"linux", false, []string{"example.com/common"}},
+		{"linux", true, []string{"example.com/common"}},
+		{"windows", false, []string{"example.com/common", "example.com/winonly"}},
+		{"windows", true, []string{"example.com/common", "example.com/winonly"}},
+	} {
+		mod, err := NewGoModuleFromPath(dir)
+		require.NoError(t, err)
+		mod.Options().GOOS = tc.goos
+		mod.Options().GOARCH = "amd64"
+		mod.Options().OnlyDirectDeps = tc.onlyDirectDeps
+		require.NoError(t, mod.Open())
+
+		deps := []string{}
+		for _, pkg := range mod.Packages {
+			deps = append(deps, pkg.ImportPath)
+		}
+		sort.Strings(deps)
+		require.Equal(t, tc.expected, deps, "%s (direct deps only: %v)", tc.goos, tc.onlyDirectDeps)
+	}
+}
+
+func TestOpenGoModuleScopes(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go executable not found")
+	}
+	// Resolve the dependencies offline from the local replacements only
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+
+	dir := t.TempDir()
+	gomod := "module example.com/app\n\ngo 1.20\n\nrequire (\n"
+	for _, dep := range []string{"common", "testonly", "tool"} {
+		gomod += "\texample.com/" + dep + " v0.0.0\n"
+	}
+	gomod += ")\n\n"
+	for _, dep := range []string{"common", "testonly", "tool"} {
+		gomod += "replace example.com/" + dep + " => ./" + dep + "\n\n"
+	}
+	for path, content := range map[string]string{
+		"go.mod":               gomod,
+		"go.sum":               "",
+		"main.go":              "package main\n\nimport _ \"example.com/common\"\n\nfunc main() {}\n",
+		"main_test.go":         "package main\n\nimport _ \"example.com/testonly\"\n",
+		"tools.go":             "//go:build tools\n\npackage main\n\nimport _ \"example.com/tool\"\n",
+		"common/go.mod":        "module example.com/common\n\ngo 1.20\n",
+		"common/common.go":     "package common\n",
+		"testonly/go.mod":      "module example.com/testonly\n\ngo 1.20\n",
+		"testonly/testonly.go": "package testonly\n",
+		"tool/go.mod":          "module example.com/tool\n\ngo 1.20\n",
+		"tool/tool.go":         "package tool\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), os.FileMode(0o644)))
+	}
+
+	expected := map[string]RelationshipType{
+		"example.com/common":   "",
+		"example.com/testonly": TEST_DEPENDENCY_OF,
+		"example.com/tool":     BUILD_TOOL_OF,
+	}
+	for _, onlyDirectDeps := range []bool{false, true} {
+		mod, err := NewGoModuleFromPath(dir)
+		require.NoError(t, err)
+		mod.Options().OnlyDirectDeps = onlyDirectDeps
+		mod.Options().ClassifyDeps = true
+		require.NoError(t, mod.Open())
+
+		scopes := map[string]RelationshipType{}
+		for _, pkg := range mod.Packages {
+			scopes[pkg.ImportPath] = pkg.dependencyType()
+		}
+		require.Equal(t, expected, scopes, "direct deps only: %v", onlyDirectDeps)
+	}
+
+	// Unless asked, the dependencies are not classified: the full list
+	// leaves out those of the tests and tools, go.mod lists them all
+	for onlyDirectDeps, expected := range map[bool]map[string]RelationshipType{
+		false: {"example.com/common": ""},
+		true:  {"example.com/common": "", "example.com/testonly": "", "example.com/tool": ""},
+	} {
+		mod, err := NewGoModuleFromPath(dir)
+		require.NoError(t, err)
+		mod.Options().OnlyDirectDeps = onlyDirectDeps
+		require.NoError(t, mod.Open())
+
+		scopes := map[string]RelationshipType{}
+		for _, pkg := range mod.Packages {
+			scopes[pkg.ImportPath] = pkg.dependencyType()
+		}
+		require.Equal(t, expected, scopes, "direct deps only: %v", onlyDirectDeps)
+	}
+
+	// Test-only dependencies are related to the module as such
+	module := NewPackage()
+	module.BuildID("example.com/app")
+	dep, err := (&GoPackage{ImportPath: "example.com/testonly", Revision: "v0.0.0", TestOnly: true}).ToSPDXPackage()
+	require.NoError(t, err)
+	require.NoError(t, module.addScopedDependency(dep, dep.dependencyType, true))
+	rendered, err := module.Render()
+	require.NoError(t, err)
+	require.Contains(t, rendered, fmt.Sprintf("Relationship: %s TEST_DEPENDENCY_OF %s\n", dep.SPDXID(), module.SPDXID()))
+	require.NotContains(t, rendered, "DEPENDS_ON")
+}
+
+func TestGoToolPackages(t *testing.T) {
+	gomod, err := modfile.ParseLax("go.mod", []byte(
+		"module example.com/app\n\ngo 1.24\n\ntool example.com/gen\n\ntool (\n\texample.com/lint/cmd/lint\n\texample.com/vet\n)\n",
+	), nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"example.com/gen", "example.com/lint/cmd/lint", "example.com/vet"}, goToolPackages(gomod))
+	require.Empty(t, goToolPackages(nil))
+}
+
+func TestGoModLicenseReaderEmbeddedFallback(t *testing.T) {
+	// The reader of go modules keeps its data in the temporary directory
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	cacheDir := filepath.Join(tmpDir, spdxLicenseDlCache)
+	require.NoError(t, os.MkdirAll(cacheDir, os.FileMode(0o755)))
+	breakLicenseDownload(t, cacheDir)
+
+	impl := GoModDefaultImpl{}
+	reader, err := impl.LicenseReader()
+	require.NoError(t, err)
+
+	licPath := filepath.Join(t.TempDir(), "LICENSE")
+	list, err := license.EmbeddedLicenseList()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(licPath, []byte(list.Licenses["Apache-2.0"].LicenseText), os.FileMode(0o644)))
+	lic, err := reader.LicenseFromFile(licPath)
+	require.NoError(t, err)
+	require.NotNil(t, lic)
+	require.Equal(t, "Apache-2.0", lic.LicenseID)
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// goRepository is the source code repository of a go module
+type goRepository struct {
+	VCS string // Version control system, eg git
+	URL string // Repository URL, including the scheme
+}
+
+// downloadLocation returns the repository in the form of the SPDX
+// download locations of version control systems, eg git+https://host/repo
+func (r *goRepository) downloadLocation() string {
+	return r.VCS + "+" + r.URL
+}
+
+// goRepositoryResolver finds the repositories of go modules from their
+// module paths. The repositories of well known hosts are derived from the
+// path. When the resolver has an HTTP client, the others are read from the
+// go-import meta tags served at the path, as the go command does. The
+// results are cached by path, a resolver is meant to live for one scan.
+type goRepositoryResolver struct {
+	client *http.Client
+	log    logrus.FieldLogger
+	mu     sync.Mutex
+	cache  map[string]goRepositoryResult
+}
+
+type goRepositoryResult struct {
+	repo *goRepository
+	err  error
+}
+
+// goImportLookupTimeout bounds each request for go-import meta tags
+const goImportLookupTimeout = 10 * time.Second
+
+// vcsKnownHosts are the hosts whose repositories the go/vcs package
+// derives from the import path. They do not serve go-import meta tags, so
+// they are never looked up.
+var vcsKnownHosts = map[string]bool{
+	"github.com":        true,
+	"bitbucket.org":     true,
+	"hub.jazz.net":      true,
+	"git.apache.org":    true,
+	"git.openstack.org": true,
+	"chiselapp.com":     true,
+	"launchpad.net":     true,
+}
+
+// newGoRepositoryResolver returns a resolver looking up go-import meta
+// tags with client. A nil client only resolves repositories offline.
+func newGoRepositoryResolver(client *http.Client) *goRepositoryResolver {
+	return &goRepositoryResolver{client: client, log: logrus.StandardLogger(), cache: map[string]goRepositoryResult{}}
+}
+
+// newScanGoRepositoryResolver returns the resolver of a scan, which looks
+// up go-import meta tags over the network only when the options set
+// LookupGoImports
+func newScanGoRepositoryResolver(opts *Options) *goRepositoryResolver {
+	var client *http.Client
+	if opts.LookupGoImports {
+		client = &http.Client{Timeout: goImportLookupTimeout}
+	}
+	resolver := newGoRepositoryResolver(client)
+	resolver.log = logger(opts)
+	return resolver
+}
+
+// resolve returns the repository of the go module or package at importPath
+func (r *goRepositoryResolver) resolve(importPath string) (*goRepository, error) {
+	r.mu.Lock()
+	res, ok := r.cache[importPath]
+	r.mu.Unlock()
+	if ok {
+		return res.repo, res.err
+	}
+
+	res.err = validGoImportPath(importPath)
+	if res.err == nil {
+		res.repo = staticGoRepository(importPath)
+	}
+	if res.err == nil && res.repo == nil {
+		res.repo, res.err = r.lookupGoImport(importPath)
+	}
+	r.mu.Lock()
+	r.cache[importPath] = res
+	r.mu.Unlock()
+	return res.repo, res.err
+}
+
+// validGoImportPath checks that the first element of the path is a domain
+func validGoImportPath(importPath string) error {
+	host, _, _ := strings.Cut(importPath, "/")
+	if !strings.Contains(host, ".") || strings.Contains(importPath, "://") {
+		return fmt.Errorf("invalid import path %q", importPath)
+	}
+	return nil
+}
+
+// staticGoRepository returns the repository of the import paths of well
+// known hosts and vanity domains, or nil if it needs to be looked up
+func staticGoRepository(importPath string) *goRepository {
+	elems := strings.Split(importPath, "/")
+	switch {
+	case (elems[0] == "github.com" || elems[0] == "bitbucket.org") && len(elems) >= 3:
+		return &goRepository{VCS: "git", URL: "https://" + strings.Join(elems[:3], "/")}
+	case elems[0] == "golang.org" && len(elems) >= 3 && elems[1] == "x":
+		return &goRepository{VCS: "git", URL: "https://go.googlesource.com/" + elems[2]}
+	case elems[0] == "k8s.io" && len(elems) >= 2:
+		return &goRepository{VCS: "git", URL: "https://github.com/kubernetes/" + elems[1]}
+	case elems[0] == "sigs.k8s.io" && len(elems) >= 2:
+		return &goRepository{VCS: "git", URL: "https://github.com/kubernetes-sigs/" + elems[1]}
+	case elems[0] == "gopkg.in" && len(elems) >= 2:
+		return gopkgInRepository(elems[1:])
+	}
+
+	// Paths naming the repository with its VCS suffix, eg host/repo.git
+	for i := 1; i < len(elems); i++ {
+		for _, vcsName := range []string{"git", "hg", "svn", "bzr"} {
+			if strings.HasSuffix(elems[i], "."+vcsName) {
+				return &goRepository{VCS: vcsName, URL: "https://" + strings.Join(elems[:i+1], "/")}
+			}
+		}
+	}
+	return nil
+}
+
+// gopkgInRepository returns the GitHub repository of a gopkg.in path:
+// gopkg.in/pkg.v1 is github.com/go-pkg/pkg and gopkg.in/user/pkg.v1 is
+// github.com/user/pkg
+func gopkgInRepository(elems []string) *goRepository {
+	if pkg, _, ok := strings.Cut(elems[0], ".v"); ok {
+		return &goRepository{VCS: "git", URL: "https://github.com/go-" + pkg + "/" + pkg}
+	}
+	if len(elems) < 2 {
+		return nil
+	}
+	pkg, _, ok := strings.Cut(elems[1], ".v")
+	if !ok {
+		return nil
+	}
+	return &goRepository{VCS: "git", URL: "https://github.com/" + elems[0] + "/" + pkg}
+}
+
+// lookupGoImport reads the repository from the go-import meta tags served
+// at https://importPath?go-get=1. It does not send requests when the
+// resolver has no client, nor to the hosts known to go/vcs and to hosts
+// given as IP addresses or with a port.
+func (r *goRepositoryResolver) lookupGoImport(importPath string) (*goRepository, error) {
+	if r.client == nil {
+		return nil, fmt.Errorf("repository of %s is not known and go-import lookups are disabled", importPath)
+	}
+	host, _, _ := strings.Cut(importPath, "/")
+	if vcsKnownHosts[host] {
+		return nil, fmt.Errorf("repository of %s cannot be derived from its path", importPath)
+	}
+	if strings.Contains(host, ":") || net.ParseIP(host) != nil {
+		return nil, fmt.Errorf("not looking up go-import meta tags at host %s", host)
+	}
+	resp, err := r.client.Get("https://" + importPath + "?go-get=1")
+	if err != nil {
+		return nil, fmt.Errorf("looking up go-import meta tags of %s: %w", importPath, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("looking up go-import meta tags of %s: HTTP status %s", importPath, resp.Status)
+	}
+
+	imports, err := parseGoImports(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing go-import meta tags of %s: %w", importPath, err)
+	}
+	// The longest prefix of the path wins
+	var repo *goRepository
+	prefixLen := -1
+	for _, imp := range imports {
+		if imp[1] == "mod" || len(imp[0]) <= prefixLen {
+			continue
+		}
+		if importPath != imp[0] && !strings.HasPrefix(importPath, imp[0]+"/") {
+			continue
+		}
+		if u, err := url.Parse(imp[2]); err != nil || u.Scheme == "" {
+			continue
+		}
+		repo = &goRepository{VCS: imp[1], URL: imp[2]}
+		prefixLen = len(imp[0])
+	}
+	if repo == nil {
+		return nil, fmt.Errorf("no go-import meta tag matches %s", importPath)
+	}
+	return repo, nil
+}
+
+// parseGoImports returns the prefix, VCS and repository root of the
+// go-import meta tags in the head of an HTML document
+func parseGoImports(r io.Reader) ([][3]string, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	imports := [][3]string{}
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			if errors.Is(err, io.EOF) || len(imports) > 0 {
+				return imports, nil
+			}
+			return nil, err
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return imports, nil
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if strings.EqualFold(e.Name.Local, "body") {
+			return imports, nil
+		}
+		if !strings.EqualFold(e.Name.Local, "meta") || xmlAttr(e.Attr, "name") != "go-import" {
+			continue
+		}
+		if f := strings.Fields(xmlAttr(e.Attr, "content")); len(f) == 3 {
+			imports = append(imports, [3]string{f[0], f[1], f[2]})
+		}
+	}
+}
+
+func xmlAttr(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -31,16 +31,25 @@ import (
 // common base images with more data to have the most common images covered.
 type ImageAnalyzer struct {
 	Analyzers map[string]ContainerLayerAnalyzer
+	log       logrus.FieldLogger
 }
 
 func NewImageAnalyzer() *ImageAnalyzer {
+	return newImageAnalyzer(nil)
+}
+
+// newImageAnalyzer returns an image analyzer logging to the logger of the
+// options
+func newImageAnalyzer(spdxOpts *Options) *ImageAnalyzer {
 	// Default options for all analyzers
 	opts := &ContainerLayerAnalyzerOptions{
 		LicenseCacheDir: filepath.Join(os.TempDir(), spdxLicenseData),
+		Logger:          logger(spdxOpts),
 	}
 
 	// Create the instance with all the drivers we have so far
 	return &ImageAnalyzer{
+		log: opts.Logger,
 		Analyzers: map[string]ContainerLayerAnalyzer{
 			"distroless": &distrolessHandler{
 				Options: opts,
@@ -61,8 +70,12 @@ func (ia *ImageAnalyzer) AnalyzeLayer(layerPath string, pkg *Package) error {
 	if pkg == nil {
 		return errors.New("unable to analyze layer, package is null")
 	}
+	log := ia.log
+	if log == nil {
+		log = logrus.StandardLogger()
+	}
 	for label, handler := range ia.Analyzers {
-		logrus.Infof("Scanning layer with %s", label)
+		log.Infof("Scanning layer with %s", label)
 		can, err := handler.CanHandle(layerPath)
 		if err != nil {
 			return fmt.Errorf("checking if layer can be handled with %s: %w", label, err)
@@ -84,4 +97,38 @@ type ContainerLayerAnalyzer interface {
 
 type ContainerLayerAnalyzerOptions struct {
 	LicenseCacheDir string
+	Logger          logrus.FieldLogger // Logger of the analyzer messages, the logrus standard logger when nil
+}
+
+// log returns the logger of the options
+func (o *ContainerLayerAnalyzerOptions) log() logrus.FieldLogger {
+	if o == nil || o.Logger == nil {
+		return logrus.StandardLogger()
+	}
+	return o.Logger
+}
+
+// LayerAnalyzerFunc enriches the package of an image layer with the data
+// read from the layer tarball at layerPath
+type LayerAnalyzerFunc func(layerPath string, pkg *Package) error
+
+// LayerAnalyzer is an analyzer supplied by the caller, run on the layers of
+// images after the built-in ones (see Options.LayerAnalyzers)
+type LayerAnalyzer struct {
+	Name    string // Name of the analyzer, prefixed to its errors
+	Analyze LayerAnalyzerFunc
+}
+
+// runLayerAnalyzers runs the analyzers on the layer in order, stopping at
+// the first one failing
+func runLayerAnalyzers(analyzers []LayerAnalyzer, layerPath string, pkg *Package) error {
+	for _, analyzer := range analyzers {
+		if analyzer.Analyze == nil {
+			return fmt.Errorf("layer analyzer %s has no function", analyzer.Name)
+		}
+		if err := analyzer.Analyze(layerPath, pkg); err != nil {
+			return fmt.Errorf("running layer analyzer %s: %w", analyzer.Name, err)
+		}
+	}
+	return nil
 }
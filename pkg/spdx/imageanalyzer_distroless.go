@@ -17,9 +17,7 @@ limitations under the License.
 package spdx
 
 import (
-	"archive/tar"
 	"bytes"
-	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -28,8 +26,6 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/sirupsen/logrus"
-
 	"sigs.k8s.io/bom/pkg/license"
 	"sigs.k8s.io/release-utils/http"
 	"sigs.k8s.io/release-utils/util"
@@ -42,7 +38,6 @@ const (
 	distrolessLicenseName      = "/copyright"
 	distrolessCommonLicenseDir = "/usr/share/common-licenses/"
 	commonLicensesRe           = `(?i)/usr/share/common-licenses/[-A-Z0-9\.]+`
-	gzExt                      = ".gz"
 )
 
 type distrolessHandler struct {
@@ -83,15 +78,9 @@ func (h *distrolessHandler) ReadPackageData(layerPath string, pkg *Package) erro
 		return fmt.Errorf("creating temporary directory: %w", err)
 	}
 	defer os.RemoveAll(dir)
-	var tr *tar.Reader
-	if filepath.Ext(layerPath) == gzExt {
-		gzf, err := gzip.NewReader(tarfile)
-		if err != nil {
-			return fmt.Errorf("creating gzip reader: %w", err)
-		}
-		tr = tar.NewReader(gzf)
-	} else {
-		tr = tar.NewReader(tarfile)
+	tr, err := newTarReader(tarfile)
+	if err != nil {
+		return fmt.Errorf("opening distroless layer tarball: %w", err)
 	}
 	for {
 		hdr, err := tr.Next()
@@ -106,14 +95,14 @@ func (h *distrolessHandler) ReadPackageData(layerPath string, pkg *Package) erro
 		if strings.HasPrefix(hdr.Name, distrolessLicensePath) && strings.HasSuffix(hdr.Name, distrolessLicenseName) {
 			// We infer the name of the package from the license directory
 			packageName := strings.TrimSuffix(strings.TrimPrefix(hdr.Name, distrolessLicensePath), distrolessLicenseName)
-			logrus.Infof("Creating SPDX subpackage " + packageName)
+			h.Options.log().Infof("Creating SPDX subpackage " + packageName)
 			subpkg := NewPackage()
 			subpkg.Name = packageName
 			if _, ok := packageList[subpkg.Name]; ok {
-				logrus.Infof(" distroless uses version %s of %s", packageList[subpkg.Name], subpkg.Name)
+				h.Options.log().Infof(" distroless uses version %s of %s", packageList[subpkg.Name], subpkg.Name)
 				subpkg.Version = packageList[subpkg.Name]
 			} else {
-				logrus.Warnf("could not determine version for package %s", subpkg.Name)
+				h.Options.log().Warnf("could not determine version for package %s", subpkg.Name)
 			}
 
 			// Extract the package license to a file
@@ -150,7 +139,7 @@ func (h *distrolessHandler) ReadPackageData(layerPath string, pkg *Package) erro
 				// We will try to look for the license in two ways:
 				if strings.Contains(string(fileData), "is in the public domain") {
 					// Option 1: File is in the public domain
-					logrus.Info("File is the public domain")
+					h.Options.log().Info("File is the public domain")
 
 					// In this case we include the full license text in the manifest
 					subpkg.CopyrightText = string(fileData)
@@ -166,7 +155,7 @@ func (h *distrolessHandler) ReadPackageData(layerPath string, pkg *Package) erro
 					label = license.DebianLicenseLabels[label]
 					if label != "" {
 						spdxlicense = licenseReader.LicenseFromLabel(label)
-						logrus.Infof("Found license %s for package %s by reading copyright file", spdxlicense.LicenseID, subpkg.Name)
+						h.Options.log().Infof("Found license %s for package %s by reading copyright file", spdxlicense.LicenseID, subpkg.Name)
 						subpkg.LicenseDeclared = spdxlicense.LicenseID
 					}
 				}
@@ -187,7 +176,7 @@ func (h *distrolessHandler) ReadPackageData(layerPath string, pkg *Package) erro
 //
 //	distroless repository keyed by package name and version
 func (h *distrolessHandler) fetchDistrolessPackages() (pkgInfo map[string]string, err error) {
-	logrus.Info("Fetching distroless image package list")
+	h.Options.log().Info("Fetching distroless image package list")
 	body, err := http.NewAgent().Get(distrolessBundleURL + distrolessBundle)
 	if err != nil {
 		return nil, fmt.Errorf("fetching distroless image package manifest: %w", err)
@@ -197,7 +186,7 @@ func (h *distrolessHandler) fetchDistrolessPackages() (pkgInfo map[string]string
 	if err := json.Unmarshal(body, &pkgInfo); err != nil {
 		return nil, fmt.Errorf("unmarshalling the distroless package list: %w", err)
 	}
-	logrus.Infof(
+	h.Options.log().Infof(
 		"Distroless bundle for %s lists %d packages",
 		distrolessBundle, len(pkgInfo),
 	)
@@ -207,7 +196,7 @@ func (h *distrolessHandler) fetchDistrolessPackages() (pkgInfo map[string]string
 // licenseReader returns a reusable license reader
 func (h *distrolessHandler) licenseReader(o *ContainerLayerAnalyzerOptions) (*license.Reader, error) {
 	if h.reader == nil {
-		logrus.Info("Initializing licence reader with default options")
+		o.log().Info("Initializing licence reader with default options")
 		// We use a default license cache
 		opts := license.DefaultReaderOptions
 		ldir := filepath.Join(os.TempDir(), "spdx-license-reader-licenses")
@@ -242,15 +231,11 @@ func (h *distrolessHandler) CanHandle(layerPath string) (can bool, err error) {
 		return can, fmt.Errorf("opening tarball: %w", err)
 	}
 
-	var tr *tar.Reader
-	if filepath.Ext(layerPath) == gzExt {
-		gzf, err := gzip.NewReader(f)
-		if err != nil {
-			return can, fmt.Errorf("creating gzip reader: %w", err)
-		}
-		tr = tar.NewReader(gzf)
-	} else {
-		tr = tar.NewReader(f)
+	defer f.Close()
+
+	tr, err := newTarReader(f)
+	if err != nil {
+		return can, fmt.Errorf("opening layer tarball: %w", err)
 	}
 	b := bytes.NewBuffer(make([]byte, 0))
 	// Search for the os-file in the tar contents
@@ -280,7 +265,7 @@ func (h *distrolessHandler) CanHandle(layerPath string) (can bool, err error) {
 	}
 	// If the image has the Distroless tag in the OS file, we can handle it
 	if strings.Contains(b.String(), `PRETTY_NAME="Distroless"`) {
-		logrus.Infof("👍 Tarball %s identified as distroless layer", layerPath)
+		h.Options.log().Infof("👍 Tarball %s identified as distroless layer", layerPath)
 		return true, nil
 	}
 	return can, nil
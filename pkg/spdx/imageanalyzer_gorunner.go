@@ -17,15 +17,11 @@ limitations under the License.
 package spdx
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 
-	"github.com/sirupsen/logrus"
-
 	"sigs.k8s.io/bom/pkg/license"
 	"sigs.k8s.io/release-utils/http"
 	"sigs.k8s.io/release-utils/util"
@@ -51,7 +47,7 @@ func (h *goRunnerHandler) ReadPackageData(layerPath string, pkg *Package) error
 	if err != nil {
 		return fmt.Errorf("fetching go-runner VERSION file: %w", err)
 	}
-	logrus.Infof("go-runner image is at version %s", string(versionb))
+	h.Options.log().Infof("go-runner image is at version %s", string(versionb))
 	pkg.Version = string(versionb)
 
 	// Read the docker file to scan for license
@@ -93,14 +89,14 @@ func (h *goRunnerHandler) ReadPackageData(layerPath string, pkg *Package) error
 		}
 	}
 	pkg.LicenseDeclared = grlic.LicenseID
-	logrus.Infof("Found license %s in go-runner image", grlic.LicenseID)
+	h.Options.log().Infof("Found license %s in go-runner image", grlic.LicenseID)
 	return nil
 }
 
 // licenseReader returns a reusable license reader
 func (h *goRunnerHandler) licenseReader(o *ContainerLayerAnalyzerOptions) (*license.Reader, error) {
 	if h.reader == nil {
-		logrus.Info("Initializing licence reader with default options")
+		o.log().Info("Initializing licence reader with default options")
 		// We use a default license cache
 		opts := license.DefaultReaderOptions
 		ldir := filepath.Join(os.TempDir(), spdxLicenseDlCache)
@@ -136,15 +132,9 @@ func (h *goRunnerHandler) CanHandle(layerPath string) (can bool, err error) {
 		return can, fmt.Errorf("opening tarball: %w", err)
 	}
 	defer f.Close()
-	var tr *tar.Reader
-	if filepath.Ext(layerPath) == ".gz" {
-		gzf, err := gzip.NewReader(f)
-		if err != nil {
-			return can, fmt.Errorf("creating gzip reader: %w", err)
-		}
-		tr = tar.NewReader(gzf)
-	} else {
-		tr = tar.NewReader(f)
+	tr, err := newTarReader(f)
+	if err != nil {
+		return can, fmt.Errorf("opening layer tarball: %w", err)
 	}
 
 	binaryFound := false
@@ -165,7 +155,7 @@ func (h *goRunnerHandler) CanHandle(layerPath string) (can bool, err error) {
 		// Scan for the os-release file in the tarball
 		if hdr.Name == "go-runner" {
 			binaryFound = true
-			logrus.Infof("👍 Tarball %s identified as a go-runner layer", layerPath)
+			h.Options.log().Infof("👍 Tarball %s identified as a go-runner layer", layerPath)
 			break
 		}
 	}
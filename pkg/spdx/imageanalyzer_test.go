@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromImageTarballLayerAnalyzers(t *testing.T) {
+	tarPath := writeTestImageTarball(t, "../osinfo/testdata/link-with-no-dots.tar.gz")
+
+	// The analyzers run in order on each extracted layer
+	calls := []string{}
+	analyzer := func(name string) LayerAnalyzer {
+		return LayerAnalyzer{Name: name, Analyze: func(layerPath string, pkg *Package) error {
+			require.FileExists(t, layerPath)
+			calls = append(calls, name)
+			pkg.AddAnnotation(newToolAnnotation("Analyzed by " + name))
+			return nil
+		}}
+	}
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromImageTarball(&Options{
+		AnalyzeLayers: true, LayerAnalyzers: []LayerAnalyzer{analyzer("first"), analyzer("second")},
+	}, tarPath)
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "second"}, calls)
+	layers := containedPackages(pkg)
+	require.Len(t, layers, 1)
+	require.GreaterOrEqual(t, len(layers[0].Annotations), 2)
+	require.Equal(t, "Analyzed by first", layers[0].Annotations[0].Comment)
+	require.Equal(t, "Analyzed by second", layers[0].Annotations[1].Comment)
+
+	// Without AnalyzeLayers they do not run
+	calls = []string{}
+	_, err = impl.PackageFromImageTarball(&Options{LayerAnalyzers: []LayerAnalyzer{analyzer("first")}}, tarPath)
+	require.NoError(t, err)
+	require.Empty(t, calls)
+
+	// Their errors are prefixed by their names
+	_, err = impl.PackageFromImageTarball(&Options{
+		AnalyzeLayers: true,
+		LayerAnalyzers: []LayerAnalyzer{{Name: "failing", Analyze: func(string, *Package) error {
+			return errors.New("unknown package format")
+		}}},
+	}, tarPath)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "running layer analyzer failing: unknown package format")
+
+	_, err = impl.PackageFromImageTarball(&Options{
+		AnalyzeLayers: true, LayerAnalyzers: []LayerAnalyzer{{Name: "empty"}},
+	}, tarPath)
+	require.Error(t, err)
+}
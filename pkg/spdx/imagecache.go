@@ -0,0 +1,191 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/sirupsen/logrus"
+)
+
+// The image cache stores the archive of each image pulled next to its raw
+// manifest, both named after the manifest digest. The manifest is kept to
+// check the archive still holds the image before reusing it.
+const (
+	imageCacheArchiveExt  = ".tar"
+	imageCacheManifestExt = ".manifest.json"
+)
+
+// imageCachePath returns the path of the cache files of an image, without
+// the extension
+func imageCachePath(cacheDir string, digest v1.Hash) string {
+	return filepath.Join(cacheDir, digest.Algorithm+"-"+digest.Hex)
+}
+
+// cachedImageArchive puts the cached archive of the image with the manifest
+// digest at tarPath. It returns false when the cache has no archive of the
+// image or it does not match its manifest, so the image has to be pulled.
+func cachedImageArchive(log logrus.FieldLogger, cacheDir string, digest v1.Hash, tarPath string) bool {
+	base := imageCachePath(cacheDir, digest)
+	rawManifest, err := os.ReadFile(base + imageCacheManifestExt)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("Reading cached manifest of %s: %v", digest, err)
+		}
+		return false
+	}
+	if err := validateImageArchive(base+imageCacheArchiveExt, digest, rawManifest); err != nil {
+		log.Warnf("Pulling %s again, cached archive is not valid: %v", digest, err)
+		return false
+	}
+	if err := linkOrCopyFile(base+imageCacheArchiveExt, tarPath); err != nil {
+		log.Warnf("Copying cached archive of %s: %v", digest, err)
+		return false
+	}
+	return true
+}
+
+// storeImageArchive adds the archive of an image and its manifest to the cache
+func storeImageArchive(cacheDir string, digest v1.Hash, rawManifest []byte, tarPath string) error {
+	if err := os.MkdirAll(cacheDir, os.FileMode(0o755)); err != nil {
+		return fmt.Errorf("creating image cache directory: %w", err)
+	}
+	// Both files are written to temporary files first, so readers never
+	// find them half written
+	base := imageCachePath(cacheDir, digest)
+	if err := writeFileAtomic(base+imageCacheArchiveExt, func(w io.Writer) error {
+		f, err := os.Open(tarPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	}); err != nil {
+		return fmt.Errorf("caching image archive: %w", err)
+	}
+	if err := writeFileAtomic(base+imageCacheManifestExt, func(w io.Writer) error {
+		_, err := w.Write(rawManifest)
+		return err
+	}); err != nil {
+		return fmt.Errorf("caching image manifest: %w", err)
+	}
+	return nil
+}
+
+// validateImageArchive checks the manifest hashes to the digest, and that
+// the config and layers in the archive hash to the digests in the manifest
+func validateImageArchive(tarPath string, digest v1.Hash, rawManifest []byte) error {
+	manifestDigest, _, err := v1.SHA256(bytes.NewReader(rawManifest))
+	if err != nil {
+		return fmt.Errorf("hashing manifest: %w", err)
+	}
+	if manifestDigest != digest {
+		return fmt.Errorf("manifest digest is %s", manifestDigest)
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	img, err := tarball.ImageFromPath(tarPath, nil)
+	if err != nil {
+		return fmt.Errorf("opening archive: %w", err)
+	}
+	rawConfig, err := img.RawConfigFile()
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	configDigest, _, err := v1.SHA256(bytes.NewReader(rawConfig))
+	if err != nil {
+		return fmt.Errorf("hashing config: %w", err)
+	}
+	if configDigest != manifest.Config.Digest {
+		return fmt.Errorf("config digest is %s, expected %s", configDigest, manifest.Config.Digest)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("reading layers: %w", err)
+	}
+	if len(layers) != len(manifest.Layers) {
+		return fmt.Errorf("archive has %d layers, expected %d", len(layers), len(manifest.Layers))
+	}
+	for i, layer := range layers {
+		layerDigest, err := hashLayer(layer)
+		if err != nil {
+			return fmt.Errorf("hashing layer %d: %w", i, err)
+		}
+		if layerDigest != manifest.Layers[i].Digest {
+			return fmt.Errorf("layer %d digest is %s, expected %s", i, layerDigest, manifest.Layers[i].Digest)
+		}
+	}
+	return nil
+}
+
+// hashLayer computes the digest of the compressed contents of a layer
+func hashLayer(layer v1.Layer) (v1.Hash, error) {
+	rc, err := layer.Compressed()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	defer rc.Close()
+	h, _, err := v1.SHA256(rc)
+	return h, err
+}
+
+// writeFileAtomic writes a file through a temporary file in the same
+// directory, renamed to the path once complete
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// linkOrCopyFile hard links src to dst, copying it when they are in
+// different filesystems
+func linkOrCopyFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return writeFileAtomic(dst, func(w io.Writer) error {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullImagesToArchiveCache(t *testing.T) {
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	layerDigest, err := layer.Digest()
+	require.NoError(t, err)
+
+	// Resolving the reference reads the config, only the layer
+	// is counted to know if the image was downloaded
+	var blobRequests atomic.Int32
+	regHandler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/blobs/"+layerDigest.String()) {
+			blobRequests.Add(1)
+		}
+		regHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/image:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	opts := &Options{ImageCacheDir: filepath.Join(t.TempDir(), "cache")}
+	impl := spdxDefaultImplementation{}
+	pull := func() string {
+		blobRequests.Store(0)
+		refs, err := impl.PullImagesToArchive(context.Background(), opts, ref.String(), t.TempDir())
+		require.NoError(t, err)
+		require.FileExists(t, refs.Archive)
+		pulled, err := tarball.ImageFromPath(refs.Archive, nil)
+		require.NoError(t, err)
+		pulledDigest, err := pulled.Digest()
+		require.NoError(t, err)
+		require.Equal(t, digest, pulledDigest)
+		return refs.Archive
+	}
+
+	// The first pull downloads the blobs and stores the archive
+	pull()
+	require.NotZero(t, blobRequests.Load())
+	cached := imageCachePath(opts.ImageCacheDir, digest)
+	require.FileExists(t, cached+imageCacheArchiveExt)
+	require.FileExists(t, cached+imageCacheManifestExt)
+
+	// The next ones reuse it without downloading the blobs
+	pull()
+	require.Zero(t, blobRequests.Load())
+
+	// A corrupt archive is pulled again and replaced in the cache
+	require.NoError(t, os.WriteFile(cached+imageCacheArchiveExt, []byte("corrupt"), os.FileMode(0o644)))
+	pull()
+	require.NotZero(t, blobRequests.Load())
+	pull()
+	require.Zero(t, blobRequests.Load())
+
+	// So is an archive whose manifest does not match the digest
+	require.NoError(t, os.WriteFile(cached+imageCacheManifestExt, []byte("{}"), os.FileMode(0o644)))
+	pull()
+	require.NotZero(t, blobRequests.Load())
+}
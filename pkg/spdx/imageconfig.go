@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"sigs.k8s.io/bom/pkg/license"
+)
+
+const (
+	// Prefixes of the annotations recording the metadata in image configs
+	imageCreatedAnnotation    = "Image created: "
+	imageLabelAnnotation      = "Image label: "
+	imageEnvAnnotation        = "Image environment variable: "
+	imageEntrypointAnnotation = "Image entrypoint: "
+	imageCmdAnnotation        = "Image command: "
+
+	// OCI annotations read from the image labels
+	// https://github.com/opencontainers/image-spec/blob/main/annotations.md
+	ociImageSourceLabel   = "org.opencontainers.image.source"
+	ociImageURLLabel      = "org.opencontainers.image.url"
+	ociImageVersionLabel  = "org.opencontainers.image.version"
+	ociImageLicensesLabel = "org.opencontainers.image.licenses"
+)
+
+// annotateImageConfig records the metadata in an image config in the image
+// package: the creation time, labels, environment and entrypoint are kept as
+// annotations, and the OCI labels describing the image fill the package
+// fields not set yet, except the license, see imageConfigLicense. The values of environment variables that look like
+// credentials are redacted.
+func annotateImageConfig(config *v1.ConfigFile, image *Package) {
+	if !config.Created.IsZero() {
+		created := config.Created.UTC().Format(time.RFC3339)
+		annotation := newToolAnnotation(imageCreatedAnnotation + created)
+		annotation.Date = created
+		image.AddAnnotation(annotation)
+	}
+
+	labels := config.Config.Labels
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		image.AddAnnotation(newToolAnnotation(imageLabelAnnotation + k + "=" + labels[k]))
+	}
+	if isUnset(image.DownloadLocation) && isURL(labels[ociImageSourceLabel]) {
+		image.DownloadLocation = labels[ociImageSourceLabel]
+	}
+	if image.HomePage == "" && isURL(labels[ociImageURLLabel]) {
+		image.HomePage = labels[ociImageURLLabel]
+	}
+	if image.Version == "" {
+		image.Version = labels[ociImageVersionLabel]
+	}
+
+	for _, env := range config.Config.Env {
+		name, value, _ := strings.Cut(env, "=")
+		if value != "" && secretEnvRe.MatchString(name) {
+			env = name + "=" + redactedValue
+		}
+		image.AddAnnotation(newToolAnnotation(imageEnvAnnotation + env))
+	}
+
+	for _, command := range []struct {
+		prefix string
+		args   []string
+	}{
+		{imageEntrypointAnnotation, config.Config.Entrypoint},
+		{imageCmdAnnotation, config.Config.Cmd},
+	} {
+		if len(command.args) == 0 {
+			continue
+		}
+		data, err := json.Marshal(command.args)
+		if err != nil {
+			continue
+		}
+		image.AddAnnotation(newToolAnnotation(command.prefix + redactSecrets(string(data))))
+	}
+}
+
+// imageConfigLicense sets the declared license of an image package without
+// one from the OCI licenses label of its config. The label is only copied
+// when it is a valid SPDX license expression, otherwise the package keeps
+// NOASSERTION and a warning is emitted.
+func (di *spdxDefaultImplementation) imageConfigLicense(opts *Options, config *v1.ConfigFile, image *Package) {
+	if !isUnset(image.LicenseDeclared) {
+		return
+	}
+	if image.LicenseDeclared == "" {
+		image.LicenseDeclared = NOASSERTION
+	}
+	label := strings.TrimSpace(config.Config.Labels[ociImageLicensesLabel])
+	if label == "" {
+		di.warn(opts, image.Name, "Image %s has no %s label, its declared license is %s",
+			image.Name, ociImageLicensesLabel, image.LicenseDeclared)
+		return
+	}
+	if _, err := license.ParseLicenseExpression(label); err != nil {
+		di.warn(opts, image.Name, "Ignoring the %s label of image %s: %v",
+			ociImageLicensesLabel, image.Name, err)
+		return
+	}
+	image.LicenseDeclared = label
+}
+
+// isUnset returns true if an SPDX field has no value
+func isUnset(value string) bool {
+	return value == "" || value == NOASSERTION || value == NONE
+}
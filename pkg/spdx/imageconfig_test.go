@@ -0,0 +1,112 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromImageTarballConfigMetadata(t *testing.T) {
+	created := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{
+		Architecture: "amd64",
+		OS:           "linux",
+		Created:      v1.Time{Time: created},
+		Config: v1.Config{
+			Labels: map[string]string{
+				ociImageSourceLabel:   "https://github.com/example/app",
+				ociImageVersionLabel:  "1.2.3",
+				ociImageLicensesLabel: "Apache-2.0",
+				"maintainer":          "team@example.com",
+			},
+			Env:        []string{"PATH=/usr/bin:/bin", "API_TOKEN=hunter2"},
+			Entrypoint: []string{"/app", "--serve"},
+			Cmd:        []string{"--port", "8080"},
+		},
+	})
+	require.NoError(t, err)
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	img, err = mutate.AppendLayers(img, layer)
+	require.NoError(t, err)
+	tag, err := name.NewTag("registry.example.com/test/image:v1.0.0")
+	require.NoError(t, err)
+	tarPath := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, tarball.WriteToFile(tarPath, tag, img))
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromImageTarball(&Options{}, tarPath)
+	require.NoError(t, err)
+
+	// The OCI labels fill the package fields
+	require.Equal(t, "https://github.com/example/app", pkg.DownloadLocation)
+	require.Equal(t, "1.2.3", pkg.Version)
+	require.Equal(t, "Apache-2.0", pkg.LicenseDeclared)
+
+	comments := map[string]Annotation{}
+	for _, a := range pkg.Annotations {
+		comments[a.Comment] = a
+	}
+	require.Contains(t, comments, imageCreatedAnnotation+"2023-05-01T12:00:00Z")
+	require.Equal(t, "2023-05-01T12:00:00Z", comments[imageCreatedAnnotation+"2023-05-01T12:00:00Z"].Date)
+	require.Contains(t, comments, imageLabelAnnotation+"maintainer=team@example.com")
+	require.Contains(t, comments, imageLabelAnnotation+ociImageSourceLabel+"=https://github.com/example/app")
+	require.Contains(t, comments, imageEnvAnnotation+"PATH=/usr/bin:/bin")
+	require.Contains(t, comments, imageEntrypointAnnotation+`["/app","--serve"]`)
+	require.Contains(t, comments, imageCmdAnnotation+`["--port","8080"]`)
+
+	// Credentials in the environment are not recorded
+	require.Contains(t, comments, imageEnvAnnotation+"API_TOKEN="+redactedValue)
+	for comment := range comments {
+		require.NotContains(t, comment, "hunter2")
+	}
+}
+
+func TestImageConfigLicense(t *testing.T) {
+	for _, tc := range []struct {
+		label    string
+		declared string
+		expected string
+		warns    bool
+	}{
+		{label: "Apache-2.0 OR MIT", expected: "Apache-2.0 OR MIT"},
+		{label: "GPL-2.0+", expected: "GPL-2.0+"},
+		{label: "", expected: NOASSERTION, warns: true},
+		{label: "Apache-2.0 AND", expected: NOASSERTION, warns: true},
+		{label: "see LICENSE file", declared: NOASSERTION, expected: NOASSERTION, warns: true},
+		{label: "MIT", declared: "Apache-2.0", expected: "Apache-2.0"},
+	} {
+		impl := spdxDefaultImplementation{}
+		config := &v1.ConfigFile{Config: v1.Config{Labels: map[string]string{}}}
+		if tc.label != "" {
+			config.Config.Labels[ociImageLicensesLabel] = tc.label
+		}
+		image := &Package{Entity: Entity{Name: "image"}, LicenseDeclared: tc.declared}
+		impl.imageConfigLicense(&Options{CollectWarnings: true}, config, image)
+		require.Equal(t, tc.expected, image.LicenseDeclared, tc.label)
+		require.Equal(t, tc.warns, len(impl.Warnings()) == 1, tc.label)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// readImageConfig parses the image config file at configPath
+func readImageConfig(configPath string) (*v1.ConfigFile, error) {
+	f, err := os.Open(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening image config: %w", err)
+	}
+	defer f.Close()
+
+	config, err := v1.ParseConfigFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image config: %w", err)
+	}
+	return config, nil
+}
+
+// recordImageHistory records the build steps and the metadata of an image
+// in its package and layers, and its base image when the annotations of its
+// manifest or its config name it. When detecting secrets, the credentials
+// found in the config are reported and redacted from the recorded steps.
+func (di *spdxDefaultImplementation) recordImageHistory(
+	opts *Options, config *v1.ConfigFile, annotations map[string]string, image *Package, layers []*Package,
+) {
+	if opts.DetectSecrets {
+		di.scanConfigSecrets(opts, image.Name, config)
+	}
+	annotateImageConfig(config, image)
+	di.imageConfigLicense(opts, config, image)
+	annotateConfigHistory(logger(opts), config, image, layers)
+	addBaseImage(image, annotations, config)
+}
+
+var (
+	// historyEnvRe matches the ENV steps of image histories, as recorded
+	// by docker build (/bin/sh -c #(nop)  ENV) and buildkit (ENV)
+	historyEnvRe = regexp.MustCompile(`^((?:/bin/sh -c #\(nop\)\s+)?ENV\s+)(.*)$`)
+
+	// historyEnvAssignmentRe matches the variables set by an ENV step
+	historyEnvAssignmentRe = regexp.MustCompile(`([A-Za-z_][\w.-]*)=("(?:[^"\\]|\\.)*"|'[^']*'|(?:[^\s\\]|\\.)*)`)
+)
+
+// redactHistoryEnv replaces the values set by an ENV build step with
+// [REDACTED], keeping the variable names. Other steps are returned as is.
+func redactHistoryEnv(createdBy string) string {
+	m := historyEnvRe.FindStringSubmatch(createdBy)
+	if m == nil {
+		return createdBy
+	}
+	// The legacy form sets a single variable: ENV KEY value
+	if key, _, ok := strings.Cut(m[2], " "); ok && !strings.Contains(key, "=") {
+		return m[1] + key + " " + redactedValue
+	}
+	return m[1] + historyEnvAssignmentRe.ReplaceAllString(m[2], "$1="+redactedValue)
+}
+
+// annotateConfigHistory records the build steps from a parsed image
+// config as annotations of the image and layer packages. The values of
+// the environment variables set by the steps are redacted. Steps without
+// a creation time are dated when the image was created, or when they are
+// recorded if the config has no date either, which reproducible documents
+// replace with their creation date.
+func annotateConfigHistory(log logrus.FieldLogger, config *v1.ConfigFile, image *Package, layers []*Package) {
+	annotator := toolAnnotator()
+	layerNum := 0
+	for i, step := range config.History {
+		date := step.Created.UTC()
+		if step.Created.IsZero() {
+			date = config.Created.UTC()
+		}
+		if date.IsZero() {
+			date = time.Now().UTC()
+		}
+		comment := fmt.Sprintf("Build step #%d: %s", i+1, redactHistoryEnv(step.CreatedBy))
+		if step.Comment != "" {
+			comment += fmt.Sprintf(" (%s)", step.Comment)
+		}
+		if step.EmptyLayer {
+			comment += " [empty layer]"
+		}
+		annotation := Annotation{
+			Annotator: annotator,
+			Date:      date.Format(time.RFC3339),
+			Type:      AnnotationTypeOther,
+			Comment:   comment,
+		}
+		image.AddAnnotation(annotation)
+
+		if step.EmptyLayer {
+			continue
+		}
+		if layerNum >= len(layers) {
+			log.Warnf("Image history lists more layers than the %d found in the image", len(layers))
+			continue
+		}
+		layers[layerNum].AddAnnotation(annotation)
+		layerNum++
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotateImageHistory(t *testing.T) {
+	config := `{"architecture":"amd64","os":"linux","history":[` +
+		`{"created":"2023-01-01T10:00:00Z","created_by":"/bin/sh -c #(nop) ADD file:abc in / "},` +
+		`{"created":"2023-01-01T10:00:01Z","created_by":"/bin/sh -c #(nop)  CMD [\"sh\"]","empty_layer":true},` +
+		`{"created":"2023-01-02T10:00:00Z","created_by":"RUN apt-get install -y curl","comment":"buildkit.dockerfile.v0"}` +
+		`]}`
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(config), os.FileMode(0o644)))
+
+	image := NewPackage()
+	layers := []*Package{NewPackage(), NewPackage()}
+	imageConfig, err := readImageConfig(configPath)
+	require.NoError(t, err)
+	annotateConfigHistory(logger(nil), imageConfig, image, layers)
+
+	// All steps are recorded in the image
+	require.Len(t, image.Annotations, 3)
+	require.Equal(t, "2023-01-01T10:00:00Z", image.Annotations[0].Date)
+	require.Equal(t, AnnotationTypeOther, image.Annotations[0].Type)
+	require.Contains(t, image.Annotations[0].Comment, "ADD file:abc")
+	require.Contains(t, image.Annotations[1].Comment, "[empty layer]")
+	require.Contains(t, image.Annotations[2].Comment, "buildkit.dockerfile.v0")
+
+	// Steps that created layers are correlated to them
+	require.Len(t, layers[0].Annotations, 1)
+	require.Equal(t, image.Annotations[0], layers[0].Annotations[0])
+	require.Len(t, layers[1].Annotations, 1)
+	require.Equal(t, image.Annotations[2], layers[1].Annotations[0])
+
+	// Steps without a date get the one of the image
+	imageConfig.Created = v1.Time{Time: time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)}
+	imageConfig.History[1].Created = v1.Time{}
+	undated := NewPackage()
+	annotateConfigHistory(logger(nil), imageConfig, undated, []*Package{NewPackage(), NewPackage()})
+	require.Equal(t, "2023-01-01T10:00:00Z", undated.Annotations[0].Date)
+	require.Equal(t, "2023-01-03T00:00:00Z", undated.Annotations[1].Date)
+
+	// Annotations are rendered in the tag-value output
+	image.Name = "image"
+	image.BuildID("image")
+	out, err := image.Render()
+	require.NoError(t, err)
+	require.Contains(t, out, "AnnotationComment: <text>Build step #3: RUN apt-get install -y curl")
+	require.Contains(t, out, "SPDXREF: "+image.ID)
+}
+
+func TestRedactHistoryEnv(t *testing.T) {
+	for createdBy, expected := range map[string]string{
+		"/bin/sh -c #(nop)  ENV PATH=/usr/local/bin:/usr/bin": "/bin/sh -c #(nop)  ENV PATH=[REDACTED]",
+		`ENV DB_URL="postgres://u:p@db/x" MODE=prod`:          "ENV DB_URL=[REDACTED] MODE=[REDACTED]",
+		`ENV GREETING='hello world' ESCAPED=a\ b`:             "ENV GREETING=[REDACTED] ESCAPED=[REDACTED]",
+		"ENV LEGACY some value with spaces":                   "ENV LEGACY [REDACTED]",
+		"RUN ENV=1 make":                                      "RUN ENV=1 make",
+		"/bin/sh -c #(nop)  CMD [\"sh\"]":                     "/bin/sh -c #(nop)  CMD [\"sh\"]",
+	} {
+		require.Equal(t, expected, redactHistoryEnv(createdBy), createdBy)
+	}
+
+	config := &v1.ConfigFile{History: []v1.History{
+		{CreatedBy: "ENV API_ENDPOINT=https://internal.example.com", EmptyLayer: true},
+	}}
+	image := NewPackage()
+	annotateConfigHistory(logger(nil), config, image, nil)
+	require.Len(t, image.Annotations, 1)
+	require.Equal(t, "Build step #1: ENV API_ENDPOINT=[REDACTED] [empty layer]", image.Annotations[0].Comment)
+	require.NotContains(t, image.Annotations[0].Comment, "internal.example.com")
+}
@@ -20,30 +20,36 @@ package spdx
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bufio"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	gitignore "github.com/go-git/go-git/v5/plumbing/format/gitignore"
-	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
 	"github.com/nozzle/throttler"
 	purl "github.com/package-url/packageurl-go"
 	"github.com/sirupsen/logrus"
 
+	"sigs.k8s.io/bom/pkg/fsimage"
 	"sigs.k8s.io/bom/pkg/license"
 	"sigs.k8s.io/bom/pkg/osinfo"
 	"sigs.k8s.io/release-utils/util"
@@ -52,27 +58,63 @@ import (
 //counterfeiter:generate . spdxImplementation
 
 type spdxImplementation interface {
-	ExtractTarballTmp(string) (string, error)
+	ExtractTarballTmp(*Options, string) (string, error)
+	ExtractLayersTmp(*Options, []string) (string, error)
+	ExtractZipTmp(string) (string, error)
 	ReadArchiveManifest(string) (*ArchiveManifest, error)
-	PullImagesToArchive(string, string) (*ImageReferenceInfo, error)
+	ReadArchiveManifests(string) ([]ArchiveManifest, error)
+	PullImagesToArchive(context.Context, *Options, string, string) (*ImageReferenceInfo, error)
 	PackageFromImageTarball(*Options, string) (*Package, error)
 	PackageFromTarball(*Options, *TarballOptions, string) (*Package, error)
+	PackageFromZip(*Options, string) (*Package, error)
+	PackageFromFilesystemImage(*Options, string) (*Package, error)
+	PackageFromGoBinary(*Options, string) (*Package, error)
+	PackageFromContentStore(*Options, BlobSource, string) (*Package, error)
+	PackageFromImageFiles(*Options, string, []string) (*Package, error)
 	PackageFromDirectory(*Options, string) (*Package, error)
-	GetDirectoryTree(string) ([]string, error)
-	IgnorePatterns(string, []string, bool) ([]gitignore.Pattern, error)
-	ApplyIgnorePatterns([]string, []gitignore.Pattern) []string
+	PackageFromFile(*Options, string) (*Package, error)
+	GetDirectoryTree(string, bool, bool) ([]string, error)
+	IgnorePatterns(string, []string, bool, bool) ([]gitignore.Pattern, error)
+	ApplyIgnorePatterns([]string, []gitignore.Pattern, bool) []string
 	GetGoDependencies(string, *Options) ([]*Package, error)
+	GetGoDependenciesWithErrors(string, *Options) ([]*Package, []error, error)
+	GetNPMDependencies(string, *Options) ([]*Package, error)
+	GetPythonDependencies(string, *Options) ([]*Package, error)
 	GetDirectoryLicense(*license.Reader, string, *Options) (*license.License, error)
 	LicenseReader(*Options) (*license.Reader, error)
-	ImageRefToPackage(string, *Options) (*Package, error)
-	AnalyzeImageLayer(string, *Package) error
+	ImageRefToPackage(context.Context, string, *Options) (*Package, error)
+	ImageOSPackages(context.Context, string, *Options) ([]osinfo.PackageDBEntry, error)
+	AnalyzeImageLayer(string, *Package, *Options) error
+	Warnings() []Warning
+	Stats() Stats
 }
 
-type spdxDefaultImplementation struct{}
+type spdxDefaultImplementation struct {
+	referenceCache referenceCache       // Cache of image references resolved from registries
+	digests        digestCache          // Digests of the tags looked up to build purls
+	warnings       warningList          // Warnings collected while generating packages
+	cpuLimiter     cpuLimiter           // Caps the concurrent hashing and license classification
+	stats          statsRecorder        // Time spent in each phase of the scans
+	tempPaths      tempRegistry         // Temporary directories to remove if a scan panics
+	nestedArchives nestedArchiveScanner // Caps the archives inside archives scanned at once
+	workerPool     workerPool           // Caps the goroutines of all the throttled phases together
+}
+
+// ExtractTarballTmp extracts a tarball to a temporary directory. When the
+// options set more than one ExtractWorkers, small files are written to disk
+// in parallel while the archive is read.
+func (di *spdxDefaultImplementation) ExtractTarballTmp(opts *Options, tarPath string) (tmpDir string, err error) {
+	return di.extractTarballTmp(opts, tarPath, TempStoragePlain, nil)
+}
 
-// ExtractTarballTmp extracts a tarball to a temporary directory
-func (di *spdxDefaultImplementation) ExtractTarballTmp(tarPath string) (tmpDir string, err error) {
-	tmpDir, err = os.MkdirTemp(os.TempDir(), "spdx-tar-extract-")
+// extractTarballTmp extracts a tarball to a temporary directory, keeping
+// the files as mode selects. Only the entries under the include paths are
+// written, all of them when it is empty.
+func (di *spdxDefaultImplementation) extractTarballTmp(
+	opts *Options, tarPath string, mode TempStorageMode, include []string,
+) (tmpDir string, err error) {
+	defer di.tempPaths.cleanupOnPanic(opts)
+	tmpDir, err = di.tempPaths.mkdirTemp(opts, "spdx-tar-extract-")
 	if err != nil {
 		return tmpDir, fmt.Errorf("creating temporary directory for tar extraction: %w", err)
 	}
@@ -84,139 +126,454 @@ func (di *spdxDefaultImplementation) ExtractTarballTmp(tarPath string) (tmpDir s
 	}
 	defer f.Close()
 
-	// Read the first bytes to determine if the file is compressed
-	var sample [3]byte
-	var gzipped bool
-	if _, err := io.ReadFull(f, sample[:]); err != nil {
-		return "", fmt.Errorf("sampling bytes from file header: %w", err)
+	defer di.stats.start(opts, phaseExtract)()
+	tr, err := newTarReader(f)
+	if err != nil {
+		return "", err
 	}
-	if _, err := f.Seek(0, 0); err != nil {
-		return "", fmt.Errorf("rewinding read pointer: %w", err)
+	ex := newTarExtractor(opts, di)
+	ex.compress = mode == TempStorageCompressed
+	ex.include = normalizeIncludePaths(include)
+	numFiles, err := ex.extractAll(tr, tmpDir)
+	if err != nil {
+		// Do not leave behind what was written, the archive may have
+		// been cut off for filling the disk
+		di.tempPaths.remove(tmpDir)
+		return "", fmt.Errorf("extracting %s: %w", tarPath, err)
 	}
 
-	if sample[0] == 0x1f && sample[1] == 0x8b && sample[2] == 0x08 {
-		gzipped = true
+	logger(opts).Infof("Successfully extracted %d files from image tarball %s", numFiles, tarPath)
+	return tmpDir, nil
+}
+
+// ExtractLayersTmp extracts the layers of an image, in the order they are
+// applied, to the same temporary directory. The whiteouts in each layer
+// delete the files of the layers below, so the directory ends up with the
+// filesystem of a container running the image.
+func (di *spdxDefaultImplementation) ExtractLayersTmp(opts *Options, layerPaths []string) (tmpDir string, err error) {
+	defer di.tempPaths.cleanupOnPanic(opts)
+	tmpDir, err = di.tempPaths.mkdirTemp(opts, "spdx-layers-extract-")
+	if err != nil {
+		return tmpDir, fmt.Errorf("creating temporary directory for layer extraction: %w", err)
 	}
 
-	var tr *tar.Reader
-	if gzipped {
-		gzipReader, err := gzip.NewReader(f)
-		if err != nil {
+	defer di.stats.start(opts, phaseExtract)()
+	for _, layerPath := range layerPaths {
+		if err := di.extractLayer(opts, layerPath, tmpDir); err != nil {
+			di.tempPaths.remove(tmpDir)
 			return "", err
 		}
-		tr = tar.NewReader(gzipReader)
-	} else {
-		tr = tar.NewReader(f)
 	}
-	numFiles := 0
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return tmpDir, fmt.Errorf("reading tarfile %s: %w", tarPath, err)
-		}
+	logger(opts).Infof("Successfully extracted %d image layers", len(layerPaths))
+	return tmpDir, nil
+}
 
-		if hdr.FileInfo().IsDir() {
-			continue
-		}
+// extractLayer extracts a layer tarball on top of the contents of dir
+func (di *spdxDefaultImplementation) extractLayer(opts *Options, layerPath, dir string) error {
+	f, err := os.Open(layerPath)
+	if err != nil {
+		return fmt.Errorf("opening layer: %w", err)
+	}
+	defer f.Close()
 
-		if strings.HasPrefix(filepath.Base(hdr.FileInfo().Name()), ".wh") {
-			logrus.Info("Skipping extraction of whithout file")
-			continue
-		}
+	tr, err := newTarReader(f)
+	if err != nil {
+		return err
+	}
+	ex := newTarExtractor(opts, di)
+	ex.include = normalizeIncludePaths(opts.IncludePaths)
+	if _, err := ex.extractAll(tr, dir); err != nil {
+		return fmt.Errorf("extracting %s: %w", layerPath, err)
+	}
+	return nil
+}
 
-		if err := os.MkdirAll(
-			filepath.Join(tmpDir, filepath.Dir(hdr.Name)), os.FileMode(0o755),
-		); err != nil {
-			return tmpDir, fmt.Errorf("creating image directory structure: %w", err)
-		}
+// newTarReader returns a tar reader for the archive in f. Compression is
+// detected by sniffing the first bytes of the file, not from its name, so
+// each layer of an image is read correctly regardless of how it was
+// compressed or named. Both gzip and zstd compressed archives are read.
+func newTarReader(f *os.File) (*tar.Reader, error) {
+	return newTarStreamReader(f)
+}
+
+// newTarStreamReader returns a tar reader for the archive read from r,
+// detecting its compression as newTarReader does. The stream is buffered
+// to sample its first bytes, so r does not need to be seekable.
+func newTarStreamReader(r io.Reader) (*tar.Reader, error) {
+	dr, err := decompressedStream(r)
+	if err != nil {
+		return nil, err
+	}
+	return tar.NewReader(dr), nil
+}
+
+// decompressedStream returns the data read from r decompressed if it is
+// gzip or zstd compressed, detecting the compression from its first bytes
+func decompressedStream(r io.Reader) (io.Reader, error) {
+	// Read the first bytes to determine if the stream is compressed
+	br := bufio.NewReader(r)
+	sample, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("sampling bytes from file header: %w", err)
+	}
 
-		targetFile, err := sanitizeExtractPath(tmpDir, hdr.Name)
+	if isGzipHeader(sample) {
+		gzipReader, err := newGzipMembersReader(br)
 		if err != nil {
-			return tmpDir, err
+			return nil, fmt.Errorf("creating gzip reader: %w", err)
 		}
-		f, err := os.Create(targetFile)
+		return gzipReader, nil
+	}
+
+	// zstd frames start with the magic number 0xFD2FB528 (little endian)
+	if sample[0] == 0x28 && sample[1] == 0xb5 && sample[2] == 0x2f && sample[3] == 0xfd {
+		// A single threaded decoder does not start goroutines, so it
+		// needs no closing once the archive has been read
+		zstdReader, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
 		if err != nil {
-			return tmpDir, fmt.Errorf("creating image layer file: %w", err)
+			return nil, fmt.Errorf("creating zstd reader: %w", err)
 		}
+		return zstdReader, nil
+	}
+	return br, nil
+}
 
-		if _, err := io.CopyN(f, tr, hdr.Size); err != nil {
-			f.Close()
-			if err == io.EOF {
-				break
-			}
+// isGzipHeader returns true if the sample starts with the magic number
+// and deflate method of gzip members
+func isGzipHeader(sample []byte) bool {
+	return len(sample) >= 3 && sample[0] == 0x1f && sample[1] == 0x8b && sample[2] == 0x08
+}
 
-			return tmpDir, fmt.Errorf("extracting image data: %w", err)
-		}
-		f.Close()
+// gzipMembersReader decompresses all the gzip members concatenated in a
+// stream, as some tools write layers in several members. Unlike the
+// multistream mode of gzip.Reader, the bytes following the last member
+// end the stream instead of failing it, as found in padded layer blobs.
+type gzipMembersReader struct {
+	r    *bufio.Reader
+	zr   *gzip.Reader
+	done bool
+}
 
-		numFiles++
+func newGzipMembersReader(r *bufio.Reader) (*gzipMembersReader, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	// Members are read one at a time to check what follows each of them.
+	// As bufio.Reader is a io.ByteReader, gzip reads no further than the
+	// end of each member.
+	zr.Multistream(false)
+	return &gzipMembersReader{r: r, zr: zr}, nil
+}
+
+func (gr *gzipMembersReader) Read(p []byte) (int, error) {
+	for !gr.done {
+		n, err := gr.zr.Read(p)
+		if !errors.Is(err, io.EOF) {
+			return n, err
+		}
+		if err := gr.nextMember(); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
 	}
+	return 0, io.EOF
+}
 
-	logrus.Infof("Successfully extracted %d files from image tarball %s", numFiles, tarPath)
-	return tmpDir, err
+// nextMember starts reading the member following the current one, or
+// marks the stream as done if the next bytes are not a gzip member
+func (gr *gzipMembersReader) nextMember() error {
+	sample, err := gr.r.Peek(3)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading gzip stream: %w", err)
+	}
+	if !isGzipHeader(sample) {
+		gr.done = true
+		return nil
+	}
+	if err := gr.zr.Reset(gr.r); err != nil {
+		return fmt.Errorf("reading gzip member: %w", err)
+	}
+	gr.zr.Multistream(false)
+	return nil
 }
 
 // fix gosec G305: File traversal when extracting zip/tar archive
 // more context: https://snyk.io/research/zip-slip-vulnerability
+//
+// The symlinks already extracted to tmpDir are followed in the parent
+// directories of the path, resolved as if tmpDir was the root of the
+// filesystem, so a chain of links cannot lead the path out of it. The last
+// element is not followed, entries replace the links they are written to.
 func sanitizeExtractPath(tmpDir, filePath string) (string, error) {
 	destpath := filepath.Join(tmpDir, filePath)
 	if !strings.HasPrefix(destpath, filepath.Clean(tmpDir)+string(os.PathSeparator)) {
 		return "", fmt.Errorf("%s: illegal file path", filePath)
 	}
 
-	return destpath, nil
+	rel, err := filepath.Rel(tmpDir, filepath.Dir(destpath))
+	if err != nil {
+		return "", fmt.Errorf("%s: illegal file path: %w", filePath, err)
+	}
+	parent, err := resolveInRoot(tmpDir, filepath.ToSlash(rel))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", filePath, err)
+	}
+	return filepath.Join(parent, filepath.Base(destpath)), nil
+}
+
+// maxSymlinkHops is the number of symlinks followed resolving a path before
+// giving up, as the kernel does with ELOOP
+const maxSymlinkHops = 255
+
+// resolveInRoot returns the path in root of name, a slash separated path
+// relative to it, following the symlinks found on disk. Links are resolved
+// as in a container with root as its filesystem: absolute targets start
+// back at root, and the parent of root is root itself.
+func resolveInRoot(root, name string) (string, error) {
+	resolved := "/"
+	rest := strings.Split(name, "/")
+	hops := 0
+	for len(rest) > 0 {
+		elem := rest[0]
+		rest = rest[1:]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+		next := path.Join(resolved, elem)
+		full := filepath.Join(root, filepath.FromSlash(next))
+		info, err := os.Lstat(full)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		hops++
+		if hops > maxSymlinkHops {
+			return "", fmt.Errorf("resolving %s: too many levels of symbolic links", name)
+		}
+		target, err := os.Readlink(full)
+		if err != nil {
+			return "", fmt.Errorf("resolving %s: %w", name, err)
+		}
+		target = filepath.ToSlash(target)
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+	return filepath.Join(root, filepath.FromSlash(resolved)), nil
+}
+
+// checkInRoot returns an error if the directory dir, once its symlinks are
+// followed by the OS, is not root or a directory under it
+func checkInRoot(root, dir string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("resolving extraction directory: %w", err)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", dir, err)
+	}
+	if realDir != realRoot && !strings.HasPrefix(realDir, realRoot+string(os.PathSeparator)) {
+		return fmt.Errorf("%s: illegal file path, it resolves out of the extraction directory", dir)
+	}
+	return nil
+}
+
+// ExtractZipTmp extracts a zip archive (jar, wheel, nupkg, etc) to a
+// temporary directory. Entries pointing outside of the extraction
+// directory are rejected and not written to disk.
+func (di *spdxDefaultImplementation) ExtractZipTmp(zipPath string) (tmpDir string, err error) {
+	return di.extractZipTmp(nil, zipPath)
+}
+
+// extractZipTmp extracts a zip archive to a temporary directory under the
+// temporary root of the options
+func (di *spdxDefaultImplementation) extractZipTmp(opts *Options, zipPath string) (tmpDir string, err error) {
+	defer di.tempPaths.cleanupOnPanic(opts)
+	tmpDir, err = di.tempPaths.mkdirTemp(opts, "spdx-zip-extract-")
+	if err != nil {
+		return tmpDir, fmt.Errorf("creating temporary directory for zip extraction: %w", err)
+	}
+
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return tmpDir, fmt.Errorf("opening zip archive: %w", err)
+	}
+	defer zr.Close()
+
+	numFiles := 0
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+
+		targetFile, err := sanitizeExtractPath(tmpDir, zf.Name)
+		if err != nil {
+			logger(opts).Warnf("Skipping zip entry: %v", err)
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(targetFile), os.FileMode(0o755)); err != nil {
+			return tmpDir, fmt.Errorf("creating zip directory structure: %w", err)
+		}
+
+		if err := extractZipFile(zf, targetFile); err != nil {
+			return tmpDir, fmt.Errorf("extracting %s from zip archive: %w", zf.Name, err)
+		}
+		numFiles++
+	}
+
+	logger(opts).Infof("Successfully extracted %d files from zip archive %s", numFiles, zipPath)
+	return tmpDir, nil
+}
+
+// extractZipFile writes a file from a zip archive to targetFile
+func extractZipFile(zf *zip.File, targetFile string) error {
+	rc, err := zf.Open()
+	if err != nil {
+		return fmt.Errorf("opening zip entry: %w", err)
+	}
+	defer rc.Close()
+
+	f, err := os.Create(targetFile)
+	if err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+	defer f.Close()
+
+	//nolint:gosec // The zip reader fails if data exceeds the size in the entry header
+	if _, err := io.Copy(f, rc); err != nil {
+		return fmt.Errorf("writing file data: %w", err)
+	}
+	return nil
 }
 
 // readArchiveManifest extracts the manifest json from an image tar
-// archive and returns the data as a struct
+// archive and returns the data as a struct. Only the first image is
+// returned from archives holding several, see ReadArchiveManifests.
 func (di *spdxDefaultImplementation) ReadArchiveManifest(manifestPath string) (manifest *ArchiveManifest, err error) {
+	manifests, err := di.ReadArchiveManifests(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return &manifests[0], nil
+}
+
+// ReadArchiveManifests returns the entries of the manifest json of an image
+// tar archive, one per image saved in it. Archives without images fail.
+func (di *spdxDefaultImplementation) ReadArchiveManifests(manifestPath string) ([]ArchiveManifest, error) {
+	// Images exported as OCI layouts (eg by skopeo or oras) have no
+	// manifest.json, the image manifest is found through index.json
+	if !util.Exists(manifestPath) && isOCILayout(filepath.Dir(manifestPath)) {
+		manifest, err := readOCILayoutManifest(readDirFile(filepath.Dir(manifestPath)))
+		if err != nil {
+			return nil, err
+		}
+		return []ArchiveManifest{*manifest}, nil
+	}
+
 	// Check that we have the archive manifest.json file
 	if !util.Exists(manifestPath) {
-		return manifest, errors.New("unable to find manifest file " + manifestPath)
+		return nil, errors.New("unable to find manifest file " + manifestPath)
 	}
 
 	// Parse the json file
 	manifestData := []ArchiveManifest{}
 	manifestJSON, err := os.ReadFile(manifestPath)
 	if err != nil {
-		return manifest, fmt.Errorf("unable to read from tarfile: %w", err)
+		return nil, fmt.Errorf("unable to read from tarfile: %w", err)
 	}
 	if err := json.Unmarshal(manifestJSON, &manifestData); err != nil {
 		fmt.Println(string(manifestJSON))
-		return manifest, fmt.Errorf("unmarshalling image manifest: %w", err)
+		return nil, fmt.Errorf("unmarshalling image manifest: %w", err)
+	}
+	if len(manifestData) == 0 {
+		return nil, fmt.Errorf("archive manifest %s lists no images", manifestPath)
 	}
-	return &manifestData[0], nil
+	return manifestData, nil
 }
 
 // getImageReferences gets a reference string and returns all image
 // references from it
-func getImageReferences(referenceString string) (*ImageReferenceInfo, error) {
+func getImageReferences(ctx context.Context, opts *Options, referenceString string) (*ImageReferenceInfo, error) {
 	ref, err := name.ParseReference(referenceString)
 	if err != nil {
 		return nil, fmt.Errorf("parsing image reference %s: %w", referenceString, err)
 	}
 
-	descr, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	remoteOpts, err := remoteOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := waitRegistryRequest(ctx, opts); err != nil {
+		return nil, err
+	}
+	descr, err := remote.Get(ref, remoteOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("fetching remote descriptor: %w", err)
 	}
 
 	// If the reference points to an image, return it
-	if descr.MediaType.IsImage() {
-		return refInfoFromImage(descr)
-	} else if descr.MediaType.IsIndex() {
-		return refInfoFromIndex(descr)
+	var refinfo *ImageReferenceInfo
+	switch {
+	case descr.MediaType.IsImage():
+		refinfo, err = refInfoFromImage(opts, descr)
+	case descr.MediaType.IsIndex():
+		refinfo, err = refInfoFromIndex(opts, descr)
+	default:
+		return nil, fmt.Errorf("unable to recognize reference mediatype (%s)", string(descr.MediaType))
+	}
+	if err != nil {
+		return nil, err
 	}
+	refinfo.Tag = referenceTag(ref)
+	if _, ok := ref.(name.Tag); ok && !referenceNamesTag(referenceString) {
+		logger(opts).Infof("Reference %s has no tag, resolved %s as %s", referenceString, ref.String(), refinfo.Digest)
+		refinfo.ImplicitTag = true
+	}
+	return refinfo, nil
+}
+
+// referenceNamesTag returns true if the reference string spells out a tag,
+// name.ParseReference adds the default one to references without it
+func referenceNamesTag(referenceString string) bool {
+	repo, _, _ := strings.Cut(referenceString, "@")
+	return strings.LastIndex(repo, ":") > strings.LastIndex(repo, "/")
+}
 
-	return nil, fmt.Errorf("unable to recognize reference mediatype (%s)", string(descr.MediaType))
+// referenceTag returns the tag named in an image reference. References
+// with both a tag and a digest (repo:tag@sha256:...) are parsed as digests
+// and the tag is dropped from the parsed reference, so it is read from the
+// original string. Digest references without a tag return an empty string.
+func referenceTag(ref name.Reference) string {
+	switch r := ref.(type) {
+	case name.Tag:
+		return r.TagStr()
+	case name.Digest:
+		repo, _, found := strings.Cut(r.String(), "@")
+		if !found || strings.LastIndex(repo, ":") <= strings.LastIndex(repo, "/") {
+			return ""
+		}
+		tag, err := name.NewTag(repo)
+		if err != nil {
+			return ""
+		}
+		return tag.TagStr()
+	}
+	return ""
 }
 
-func refInfoFromIndex(descr *remote.Descriptor) (refinfo *ImageReferenceInfo, err error) {
+func refInfoFromIndex(opts *Options, descr *remote.Descriptor) (refinfo *ImageReferenceInfo, err error) {
 	refinfo = &ImageReferenceInfo{Images: []ImageReferenceInfo{}}
-	logrus.Infof("Reference %s points to an index", descr.Ref.String())
+	logger(opts).Infof("Reference %s points to an index", descr.Ref.String())
 
 	tag := descr.Ref.Context().Tag(descr.Ref.String())
 	if tag.String() == "" {
@@ -245,7 +602,7 @@ func refInfoFromIndex(descr *remote.Descriptor) (refinfo *ImageReferenceInfo, er
 	if err != nil {
 		return nil, fmt.Errorf("getting index manifest from %s: %w", descr.Ref.String(), err)
 	}
-	logrus.Infof("Reference image index points to %d manifests", len(indexManifest.Manifests))
+	logger(opts).Infof("Reference image index points to %d manifests", len(indexManifest.Manifests))
 	refinfo.MediaType = string(indexManifest.MediaType)
 
 	// Add all the child images described in the index
@@ -256,29 +613,96 @@ func refInfoFromIndex(descr *remote.Descriptor) (refinfo *ImageReferenceInfo, er
 			return nil, fmt.Errorf("generating digest for image: %w", err)
 		}
 
-		arch, osid := "", ""
+		arch, osid, variant := "", "", ""
 		if manifest.Platform != nil {
 			arch = manifest.Platform.Architecture
 			osid = manifest.Platform.OS
+			variant = manifest.Platform.Variant
+		}
+
+		// Signatures and attestations are listed apart, they are not images
+		if isAttestationManifest(&manifest) {
+			logger(opts).Infof("Skipping attestation manifest %s", archImgDigest)
+			mediaType := string(manifest.MediaType)
+			if manifest.ArtifactType != "" {
+				mediaType = manifest.ArtifactType
+			}
+			refinfo.Attestations = append(refinfo.Attestations, ImageReferenceInfo{
+				Digest:    archImgDigest.String(),
+				MediaType: mediaType,
+			})
+			continue
 		}
 
-		logrus.Infof("Adding image %s (%s/%s)", archImgDigest, arch, osid)
+		logger(opts).Infof("Adding image %s (%s/%s)", archImgDigest, arch, osid)
 
 		refinfo.Images = append(refinfo.Images,
 			ImageReferenceInfo{
-				Digest:    archImgDigest.String(),
-				MediaType: string(manifest.MediaType),
-				Arch:      arch,
-				OS:        osid,
+				Digest:       archImgDigest.String(),
+				MediaType:    string(manifest.MediaType),
+				ArtifactType: manifest.ArtifactType,
+				Arch:         arch,
+				OS:           osid,
+				Variant:      variant,
 			})
 	}
 
+	// An index without images cannot be read as one
+	if len(refinfo.Images) == 0 && len(refinfo.Attestations) > 0 {
+		return nil, fmt.Errorf(
+			"index %s lists only %d signatures and attestations, no images to describe",
+			descr.Ref.String(), len(refinfo.Attestations),
+		)
+	}
+
 	return refinfo, nil
 }
 
-func refInfoFromImage(descr *remote.Descriptor) (refinfo *ImageReferenceInfo, err error) {
+// manifestArtifactType returns the type of the artifact stored in an OCI
+// manifest, the media type of its config (eg the Helm chart config).
+// Images return an empty string.
+func manifestArtifactType(manifest *v1.Manifest) string {
+	switch manifest.Config.MediaType {
+	case "", types.OCIConfigJSON, types.DockerConfigJSON:
+		return ""
+	}
+	return string(manifest.Config.MediaType)
+}
+
+// attestationMediaTypes are the media and artifact types of the signatures
+// and attestations published in image indexes
+var attestationMediaTypes = map[string]struct{}{
+	"application/vnd.in-toto+json":                         {},
+	"application/vnd.dsse.envelope.v1+json":                {},
+	"application/vnd.dev.cosign.simplesigning.v1+json":     {},
+	"application/vnd.dev.cosign.artifact.sig.v1+json":      {},
+	"application/vnd.dev.cosign.artifact.sbom.v1+json":     {},
+	"application/vnd.dev.sigstore.bundle+json;version=0.1": {},
+	"application/vnd.dev.sigstore.bundle.v0.3+json":        {},
+}
+
+// isAttestationManifest returns true if a manifest listed in an index is a
+// signature or attestation instead of an image: buildkit publishes them for
+// the unknown/unknown platform, cosign and the OCI referrers by their
+// artifact type.
+func isAttestationManifest(manifest *v1.Descriptor) bool {
+	if manifest.Platform != nil && manifest.Platform.OS == "unknown" && manifest.Platform.Architecture == "unknown" {
+		return true
+	}
+	if manifest.Annotations["vnd.docker.reference.type"] == "attestation-manifest" {
+		return true
+	}
+	for _, mediaType := range []string{manifest.ArtifactType, string(manifest.MediaType)} {
+		if _, ok := attestationMediaTypes[mediaType]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func refInfoFromImage(opts *Options, descr *remote.Descriptor) (refinfo *ImageReferenceInfo, err error) {
 	refinfo = &ImageReferenceInfo{}
-	logrus.Infof("Reference %s points to a single image", descr.Ref.String())
+	logger(opts).Infof("Reference %s points to a single image", descr.Ref.String())
 
 	tag := descr.Ref.Context().Tag(descr.Ref.String())
 	if tag.String() == "" {
@@ -307,14 +731,31 @@ func refInfoFromImage(descr *remote.Descriptor) (refinfo *ImageReferenceInfo, er
 	if err == nil {
 		refinfo.MediaType = string(mt)
 	}
+	if manifest, err := im.Manifest(); err == nil {
+		refinfo.ArtifactType = manifestArtifactType(manifest)
+	}
 
 	// Get the platform data
+	refinfo.Arch, refinfo.OS = imagePlatform(opts, descr.Ref.String(), im)
+	return refinfo, nil
+}
+
+// imagePlatform reads the architecture and OS from an image config. When
+// the config cannot be fetched or does not define the platform, the reason
+// is logged at debug level and empty strings are returned as the image can
+// still be scanned without the platform data. The warning is emitted once,
+// when the package of the image is described.
+func imagePlatform(opts *Options, ref string, im v1.Image) (arch, osid string) {
 	conf, err := im.ConfigFile()
-	if err == nil {
-		refinfo.Arch = conf.Architecture
-		refinfo.OS = conf.OS
+	if err != nil {
+		logger(opts).Debugf("Unable to fetch the config of image %s, its platform is unknown: %v", ref, err)
+		return "", ""
 	}
-	return refinfo, nil
+	if conf == nil || (conf.Architecture == "" && conf.OS == "") {
+		logger(opts).Debugf("The config of image %s does not define its platform", ref)
+		return "", ""
+	}
+	return conf.Architecture, conf.OS
 }
 
 // fullDigest builds a name.Digest with the registry info from tag
@@ -333,14 +774,44 @@ func fullDigest(tag name.Tag, hash v1.Hash) (name.Digest, error) {
 	return dig, nil
 }
 
+// PullImageToArchive downloads the image referenced to a tarball in path
 func PullImageToArchive(referenceString, path string) error {
+	return PullImageToArchiveContext(context.Background(), referenceString, path)
+}
+
+// PullImageToArchiveContext downloads the image referenced to a tarball in
+// path. The download is aborted when the context is done.
+func PullImageToArchiveContext(ctx context.Context, referenceString, path string) error {
+	return PullImageToArchiveWithOptions(ctx, nil, referenceString, path)
+}
+
+// PullImageToArchiveWithOptions downloads the image referenced to a tarball
+// in path, authenticating with the keychain in the options. When the
+// reference points to an index, the image of the platform selected in the
+// options is pulled.
+func PullImageToArchiveWithOptions(ctx context.Context, opts *Options, referenceString, path string) error {
 	ref, err := name.ParseReference(referenceString)
 	if err != nil {
 		return fmt.Errorf("parsing reference %s: %w", referenceString, err)
 	}
 
-	// Get the image from the reference:
-	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	// Get the image from the reference. The layers are fetched with
+	// the same context when written to the tarball.
+	remoteOpts, err := remoteOptions(ctx, opts)
+	if err != nil {
+		return err
+	}
+	platform, err := pullPlatform(opts)
+	if err != nil {
+		return err
+	}
+	if platform != nil {
+		remoteOpts = append(remoteOpts, remote.WithPlatform(*platform))
+	}
+	if err := waitRegistryRequest(ctx, opts); err != nil {
+		return err
+	}
+	img, err := remote.Image(ref, remoteOpts...)
 	if err != nil {
 		return fmt.Errorf("getting image: %w", err)
 	}
@@ -351,13 +822,25 @@ func PullImageToArchive(referenceString, path string) error {
 	return nil
 }
 
+// defaultDownloadConcurrency is the number of image variants downloaded
+// at once when the options do not set it
+const defaultDownloadConcurrency = 4
+
+// downloadConcurrency returns the number of images pulled in parallel
+func downloadConcurrency(opts *Options) int {
+	if opts != nil && opts.DownloadConcurrency > 0 {
+		return opts.DownloadConcurrency
+	}
+	return defaultDownloadConcurrency
+}
+
 // PullImagesToArchive takes an image reference (a tag or a digest)
-// and writes it into a docker tar archive in path
+// and writes it into a docker tar archive in path. Once the context is
+// done, the downloads in flight are aborted and no new ones are started.
 func (di *spdxDefaultImplementation) PullImagesToArchive(
-	referenceString, path string,
+	ctx context.Context, opts *Options, referenceString, path string,
 ) (references *ImageReferenceInfo, err error) {
-	// Get the image references from the index
-	references, err = getImageReferences(referenceString)
+	references, err = di.resolveImageReferences(ctx, opts, referenceString)
 	if err != nil {
 		return nil, err
 	}
@@ -368,13 +851,18 @@ func (di *spdxDefaultImplementation) PullImagesToArchive(
 		}
 	}
 
+	// Archives completely written by a previous pull are not downloaded again
+	state := readPullState(logger(opts), path)
+
 	// If we do not have any child images we download the main reference
 	// as it is not an index
 	if len(references.Images) == 0 {
-		tarPath, err := createReferenceArchive(references.Digest, path)
+		progress := newProgressCounter(progressFn(opts), ProgressPhaseDownload, 1)
+		tarPath, err := createReferenceArchive(ctx, opts, state, references.Digest, path)
 		if err != nil {
 			return nil, fmt.Errorf("downloading archive of image: %w", err)
 		}
+		progress.done()
 		references.Archive = tarPath
 	}
 
@@ -382,19 +870,26 @@ func (di *spdxDefaultImplementation) PullImagesToArchive(
 	newrefs := *references
 	newrefs.Images = []ImageReferenceInfo{}
 
-	// Download 4 arches at once
-	t := throttler.New(4, len(references.Images))
+	// Download several arches at once
+	t := throttler.New(downloadConcurrency(opts), len(references.Images))
 	mtx := sync.Mutex{}
+	progress := newProgressCounter(progressFn(opts), ProgressPhaseDownload, len(references.Images))
 
 	for _, refData := range references.Images {
-		go func(r ImageReferenceInfo) {
-			tarPath, err := createReferenceArchive(r.Digest, path)
+		r := refData
+		di.workerPool.spawn(opts, func() {
+			if err := ctx.Err(); err != nil {
+				t.Done(fmt.Errorf("downloading %s: %w", r.Digest, err))
+				return
+			}
+			tarPath, err := createReferenceArchive(ctx, opts, state, r.Digest, path)
 			mtx.Lock()
 			r.Archive = tarPath
 			newrefs.Images = append(newrefs.Images, r)
 			mtx.Unlock()
+			progress.done()
 			t.Done(err)
-		}(refData)
+		})
 		t.Throttle()
 	}
 	if err := t.Err(); err != nil {
@@ -403,7 +898,48 @@ func (di *spdxDefaultImplementation) PullImagesToArchive(
 	return &newrefs, nil
 }
 
-func createReferenceArchive(digest, path string) (tarPath string, err error) {
+// resolveImageReferences resolves an image reference to the images it
+// points to, keeping only those of the platforms selected in the options
+func (di *spdxDefaultImplementation) resolveImageReferences(
+	ctx context.Context, opts *Options, referenceString string,
+) (references *ImageReferenceInfo, err error) {
+	if opts != nil {
+		if _, err := parseImagePlatforms(opts.Platforms); err != nil {
+			return nil, err
+		}
+	}
+
+	// Get the image references from the index
+	if err := withRegistryRetries(ctx, opts, "resolving "+referenceString, func() (err error) {
+		references, err = di.referenceCache.Get(ctx, opts, referenceString)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	// Drop the images of the platforms not selected in the options
+	numImages := len(references.Images)
+	references, err = filterImagePlatforms(opts, references)
+	if err != nil {
+		return nil, err
+	}
+	if numImages > 0 && len(references.Images) == 0 {
+		return nil, fmt.Errorf(
+			"none of the %d images in %s is for platforms %s",
+			numImages, referenceString, strings.Join(opts.Platforms, ", "),
+		)
+	}
+	if err := checkImagePlatform(opts, references); err != nil {
+		return nil, err
+	}
+	return references, nil
+}
+
+// createReferenceArchive writes the archive of the image with the digest
+// reference to path, unless the pull state lists it as already written
+func createReferenceArchive(
+	ctx context.Context, opts *Options, state *pullState, digest, path string,
+) (tarPath string, err error) {
 	ref, err := name.ParseReference(digest)
 	if err != nil {
 		return "", fmt.Errorf("parsing reference %s: %w", digest, err)
@@ -419,19 +955,67 @@ func createReferenceArchive(digest, path string) (tarPath string, err error) {
 		return "", fmt.Errorf("unable to parse digest string %s", d.DigestStr())
 	}
 	tarPath = filepath.Join(path, p[1]+".tar")
-	logrus.Debugf("Downloading %s from remote registry to %s", digest, tarPath)
+	if state.completed(d.DigestStr(), tarPath) {
+		logger(opts).Infof("Reusing the archive of %s written by a previous pull", digest)
+		return tarPath, nil
+	}
+	defer func() {
+		if err == nil {
+			if stateErr := state.record(d.DigestStr(), tarPath); stateErr != nil {
+				logger(opts).Warnf("Could not record the pull of %s: %v", digest, stateErr)
+			}
+		}
+	}()
 
-	// Download image from remote
-	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	// Reuse the archive from the cache if a valid one was stored before
+	var manifestDigest v1.Hash
+	if opts != nil && opts.ImageCacheDir != "" {
+		manifestDigest, err = v1.NewHash(d.DigestStr())
+		if err != nil {
+			return "", fmt.Errorf("parsing digest %s: %w", digest, err)
+		}
+		if cachedImageArchive(logger(opts), opts.ImageCacheDir, manifestDigest, tarPath) {
+			logger(opts).Debugf("Using the cached archive of %s", digest)
+			return tarPath, nil
+		}
+	}
+	logger(opts).Debugf("Downloading %s from remote registry to %s", digest, tarPath)
+	remoteOpts, err := remoteOptions(ctx, opts)
 	if err != nil {
-		return "", fmt.Errorf("getting image from remote: %w", err)
+		return "", err
 	}
 
-	// Write image to tar archive
-	if err := tarball.MultiWriteToFile(
-		tarPath, map[name.Tag]v1.Image{d.Repository.Tag(p[1]): img},
-	); err != nil {
-		return "", fmt.Errorf("writing image to disk: %w", err)
+	// Download image from remote. The layers are pulled while writing the
+	// archive, so a transient error in any of them retries the whole image.
+	if err := withRegistryRetries(ctx, opts, "download of "+digest, func() error {
+		if err := waitRegistryRequest(ctx, opts); err != nil {
+			return err
+		}
+		img, err := remote.Image(ref, remoteOpts...)
+		if err != nil {
+			return fmt.Errorf("getting image from remote: %w", err)
+		}
+
+		// Write image to tar archive
+		if err := tarball.MultiWriteToFile(
+			tarPath, map[name.Tag]v1.Image{d.Repository.Tag(p[1]): img},
+		); err != nil {
+			return fmt.Errorf("writing image to disk: %w", err)
+		}
+
+		// A failure to cache the archive only costs a later download
+		if opts != nil && opts.ImageCacheDir != "" {
+			rawManifest, err := img.RawManifest()
+			if err == nil {
+				err = storeImageArchive(opts.ImageCacheDir, manifestDigest, rawManifest, tarPath)
+			}
+			if err != nil {
+				logger(opts).Warnf("Could not cache the archive of %s: %v", digest, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return "", err
 	}
 
 	return tarPath, nil
@@ -441,102 +1025,521 @@ func createReferenceArchive(digest, path string) (tarPath string, err error) {
 func (di *spdxDefaultImplementation) PackageFromTarball(
 	opts *Options, tarOpts *TarballOptions, tarFile string,
 ) (pkg *Package, err error) {
-	logrus.Infof("Generating SPDX package from tarball %s", tarFile)
+	logger(opts).Infof("Generating SPDX package from tarball %s", tarFile)
 
-	if tarOpts.AddFiles {
+	// Image archives are extracted whole, the include paths filter the
+	// files of their layers
+	var include []string
+	if tarOpts.layer && opts != nil {
+		include = opts.IncludePaths
+	}
+	if tarOpts.AddFiles && tarOpts.TempStorageMode == TempStorageCompressed {
+		pkg, err = di.packageFromCompressedTarball(opts, tarFile, include)
+		if err != nil {
+			return nil, err
+		}
+	} else if tarOpts.AddFiles {
 		// Estract the tarball
-		tmp, err := di.ExtractTarballTmp(tarFile)
+		tmp, err := di.extractTarballTmp(opts, tarFile, TempStoragePlain, include)
 		if err != nil {
 			return nil, fmt.Errorf("extracting tarball to temporary archive: %w", err)
 		}
-		defer os.RemoveAll(tmp)
+		defer di.tempPaths.remove(tmp)
 		pkg, err = di.PackageFromDirectory(opts, tmp)
 		if err != nil {
 			return nil, fmt.Errorf("generating package from tar contents: %w", err)
 		}
+		if err := di.addNestedArchives(opts, pkg, tmp, 1); err != nil {
+			return nil, fmt.Errorf("scanning archives in tarball: %w", err)
+		}
 	} else {
 		pkg = NewPackage()
 	}
 	// Set the extract dir option. This makes the package to remove
 	// the tempdir prefix from the document paths:
 	pkg.Options().WorkDir = tarOpts.ExtractDir
-	if err := pkg.ReadSourceFile(tarFile); err != nil {
+	if err := di.cpuLimiter.run(opts, func() error { return pkg.ReadSourceFile(tarFile) }); err != nil {
 		return nil, fmt.Errorf("reading source file %s: %w", tarFile, err)
 	}
 	// Build the ID and the filename from the tarball name
 	return pkg, nil
 }
 
-// GetDirectoryTree traverses a directory and return a slice of strings with all files
-func (di *spdxDefaultImplementation) GetDirectoryTree(dirPath string) ([]string, error) {
-	fileList := []string{}
+// plainTarballPackage builds the package of a tarball passed as an image
+// which turned out to hold plain files, from the directory where it was
+// already extracted to look for the image manifest
+func (di *spdxDefaultImplementation) plainTarballPackage(opts *Options, tarPath, extractDir string) (*Package, error) {
+	pkg, err := di.PackageFromDirectory(opts, extractDir)
+	if err != nil {
+		return nil, fmt.Errorf("generating package from plain tarball: %w", err)
+	}
+	if err := di.addNestedArchives(opts, pkg, extractDir, 1); err != nil {
+		return nil, fmt.Errorf("scanning archives in tarball: %w", err)
+	}
+	pkg.Options().WorkDir = filepath.Dir(tarPath)
+	if err := di.cpuLimiter.run(opts, func() error { return pkg.ReadSourceFile(tarPath) }); err != nil {
+		return nil, fmt.Errorf("reading source file %s: %w", tarPath, err)
+	}
+	pkg.Name = filepath.Base(tarPath)
+	pkg.BuildID(pkg.Name)
+	pkg.Comment = "Tarball without an image manifest, read as a plain archive"
+	return pkg, nil
+}
 
-	if err := fs.WalkDir(os.DirFS(dirPath), ".", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
+// PackageFromZip builds a SPDX package from the contents of a zip archive
+func (di *spdxDefaultImplementation) PackageFromZip(
+	opts *Options, zipFile string,
+) (pkg *Package, err error) {
+	logger(opts).Infof("Generating SPDX package from zip archive %s", zipFile)
 
-		if d.Type() == os.ModeSymlink {
-			return nil
+	tmp, err := di.extractZipTmp(opts, zipFile)
+	if tmp != "" {
+		defer di.tempPaths.remove(tmp)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("extracting zip archive to temporary directory: %w", err)
+	}
+
+	pkg, err = di.PackageFromDirectory(opts, tmp)
+	if err != nil {
+		return nil, fmt.Errorf("generating package from zip contents: %w", err)
+	}
+
+	// Name the package after the archive, not the temporary directory
+	pkg.Name = filepath.Base(zipFile)
+	if err := di.addNestedArchives(opts, pkg, tmp, 1); err != nil {
+		return nil, fmt.Errorf("scanning archives in zip archive: %w", err)
+	}
+	pkg.Options().WorkDir = filepath.Dir(zipFile)
+	if err := di.cpuLimiter.run(opts, func() error { return pkg.ReadSourceFile(zipFile) }); err != nil {
+		return nil, fmt.Errorf("reading source file %s: %w", zipFile, err)
+	}
+	return pkg, nil
+}
+
+// PackageFromFilesystemImage builds a SPDX package from the files and the
+// operating system packages found in a filesystem image (squashfs or ext4)
+func (di *spdxDefaultImplementation) PackageFromFilesystemImage(
+	opts *Options, imagePath string,
+) (*Package, error) {
+	defer di.tempPaths.cleanupOnPanic(opts)
+	logger(opts).Infof("Generating SPDX package from filesystem image %s", imagePath)
+
+	// Dump the image contents to a tarball to reuse the layer scanners
+	tmp, err := di.tempPaths.mkdirTemp(opts, "fsimage-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer di.tempPaths.remove(tmp)
+	tarPath := filepath.Join(tmp, "rootfs.tar")
+	if err := fsimage.WriteTar(imagePath, tarPath); err != nil {
+		return nil, fmt.Errorf("reading filesystem image: %w", err)
+	}
+
+	pkg, err := di.PackageFromTarball(opts, &TarballOptions{AddFiles: true}, tarPath)
+	if err != nil {
+		return nil, fmt.Errorf("generating package from filesystem contents: %w", err)
+	}
+
+	// Name the package after the image, not the temporary tarball
+	pkg.Name = filepath.Base(imagePath)
+	pkg.Options().WorkDir = filepath.Dir(imagePath)
+	if err := di.cpuLimiter.run(opts, func() error { return pkg.ReadSourceFile(imagePath) }); err != nil {
+		return nil, fmt.Errorf("reading source file %s: %w", imagePath, err)
+	}
+	pkg.BuildID(pkg.Name)
+
+	if !opts.ScanImages {
+		return pkg, nil
+	}
+
+	ct := osinfo.ContainerScanner{}
+	stopOSScan := di.stats.start(opts, phaseOSScan)
+	_, osPackageData, err := ct.ReadOSPackages([]string{tarPath})
+	stopOSScan()
+	if err != nil {
+		return nil, fmt.Errorf("getting os data from filesystem image: %w", err)
+	}
+	if osPackageData == nil {
+		return pkg, nil
+	}
+	logger(opts).Infof("Scan of filesystem image returned %d OS packages", len(*osPackageData))
+	for i := range *osPackageData {
+		ospk := osPackageFromDBEntry(&(*osPackageData)[i])
+		ospk.BuildID(pkg.ID)
+		if err := pkg.addTemplatedPackage(ospk, opts.LayerPackageRelationship); err != nil {
+			return nil, fmt.Errorf("adding OS package to filesystem image: %w", err)
 		}
+	}
+	return pkg, nil
+}
 
-		fileList = append(fileList, path)
-		return nil
-	}); err != nil {
+// GetDirectoryTree traverses a directory and return a slice of strings with all files.
+// When caseInsensitive is true, paths differing only in case are listed once.
+// Symbolic links are skipped unless followSymlinks is true, then the files
+// they point to are listed under the path of the link, and the directories
+// they point to are walked. Each real directory is walked once, so links
+// pointing back to a directory already walked (eg to a parent) are skipped.
+func (di *spdxDefaultImplementation) GetDirectoryTree(
+	dirPath string, caseInsensitive, followSymlinks bool,
+) ([]string, error) {
+	return di.getDirectoryTree(logger(nil), dirPath, caseInsensitive, followSymlinks)
+}
+
+// getDirectoryTree is GetDirectoryTree logging to log
+func (di *spdxDefaultImplementation) getDirectoryTree(
+	log logrus.FieldLogger, dirPath string, caseInsensitive, followSymlinks bool,
+) ([]string, error) {
+	fileList := []string{}
+	visited := map[string]struct{}{}
+
+	var walkTree func(root, prefix string) error
+	walkTree = func(root, prefix string) error {
+		return fs.WalkDir(os.DirFS(root), ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if followSymlinks {
+					visited[filepath.Join(root, filepath.FromSlash(p))] = struct{}{}
+				}
+				return nil
+			}
+
+			if d.Type() != os.ModeSymlink {
+				fileList = append(fileList, path.Join(prefix, p))
+				return nil
+			}
+			if !followSymlinks {
+				return nil
+			}
+
+			target, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(p)))
+			if err != nil {
+				log.Debugf("Skipping symlink %s: %v", path.Join(prefix, p), err)
+				return nil
+			}
+			info, err := os.Stat(target)
+			if err != nil {
+				return fmt.Errorf("reading symlink target: %w", err)
+			}
+			switch {
+			case info.Mode().IsRegular():
+				fileList = append(fileList, path.Join(prefix, p))
+			case info.IsDir():
+				if _, ok := visited[target]; ok {
+					log.Debugf("Skipping symlink %s to a directory already scanned", path.Join(prefix, p))
+					return nil
+				}
+				return walkTree(target, path.Join(prefix, p))
+			}
+			return nil
+		})
+	}
+
+	root := dirPath
+	if followSymlinks {
+		// Track the directories by their real paths
+		var err error
+		if root, err = filepath.EvalSymlinks(dirPath); err != nil {
+			return nil, fmt.Errorf("resolving directory path: %w", err)
+		}
+		if root, err = filepath.Abs(root); err != nil {
+			return nil, fmt.Errorf("getting absolute directory path: %w", err)
+		}
+	}
+	if err := walkTree(root, ""); err != nil {
 		return nil, fmt.Errorf("buiding directory tree: %w", err)
 	}
+	if caseInsensitive {
+		fileList = dedupeFoldedPaths(log, fileList)
+	}
 	return fileList, nil
 }
 
-// IgnorePatterns return a list of gitignore patterns
+// filterFileSizes returns the list of files without those of zero bytes,
+// when skipEmpty is set, and those larger than maxSize bytes. A zero
+// maxSize does not limit the size of the files.
+func filterFileSizes(
+	log logrus.FieldLogger, dirPath string, fileList []string, skipEmpty bool, maxSize int64,
+) ([]string, error) {
+	filtered := []string{}
+	for _, path := range fileList {
+		info, err := os.Stat(filepath.Join(dirPath, path))
+		if err != nil {
+			return nil, fmt.Errorf("checking size of %s: %w", path, err)
+		}
+		if skipEmpty && info.Size() == 0 {
+			log.Debugf("Skipping empty file %s", path)
+			continue
+		}
+		if maxSize > 0 && info.Size() > maxSize {
+			log.Debugf("Skipping file %s, its %d bytes exceed the %d bytes limit", path, info.Size(), maxSize)
+			continue
+		}
+		filtered = append(filtered, path)
+	}
+	return filtered, nil
+}
+
+// emptyDirectories returns the slash separated paths of the directories
+// under dirPath that have no entries, leaving out those matched by the
+// ignore patterns
+func emptyDirectories(dirPath string, patterns []gitignore.Pattern, caseInsensitive bool) ([]string, error) {
+	matcher := gitignore.NewMatcher(patterns)
+	emptyDirs := []string{}
+	err := fs.WalkDir(os.DirFS(dirPath), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || p == "." {
+			return nil
+		}
+		matchPath := p
+		if caseInsensitive {
+			matchPath = strings.ToLower(p)
+		}
+		if matcher.Match(strings.Split(matchPath, "/"), true) {
+			return fs.SkipDir
+		}
+		entries, err := os.ReadDir(filepath.Join(dirPath, filepath.FromSlash(p)))
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			emptyDirs = append(emptyDirs, p)
+		}
+		return nil
+	})
+	return emptyDirs, err
+}
+
+// extensionSuffixes normalizes a list of file extensions to the lowercase
+// suffixes matched against the file names. Extensions can be written with
+// or without the leading dot, so .tar.gz or yaml are valid.
+func extensionSuffixes(extensions []string) []string {
+	suffixes := []string{}
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		suffixes = append(suffixes, ext)
+	}
+	return suffixes
+}
+
+// hasExtension returns true if the name of the file at path ends with one
+// of the suffixes, ignoring case
+func hasExtension(path string, suffixes []string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	for _, suffix := range suffixes {
+		if len(name) > len(suffix) && strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterExtensions returns the files of the list whose names end with one
+// of the extensions, matched ignoring case
+func filterExtensions(log logrus.FieldLogger, fileList, extensions []string) []string {
+	suffixes := extensionSuffixes(extensions)
+	filtered := []string{}
+	for _, path := range fileList {
+		if hasExtension(path, suffixes) {
+			filtered = append(filtered, path)
+		}
+	}
+	log.Debugf("Scanning %d of %d files by extension", len(filtered), len(fileList))
+	return filtered
+}
+
+// excludeExtensions returns the files of the list whose names do not end
+// with any of the extensions, matched ignoring case
+func excludeExtensions(log logrus.FieldLogger, fileList, extensions []string) []string {
+	suffixes := extensionSuffixes(extensions)
+	filtered := []string{}
+	for _, path := range fileList {
+		if hasExtension(path, suffixes) {
+			log.Debugf("Skipping file %s by its extension", path)
+			continue
+		}
+		filtered = append(filtered, path)
+	}
+	return filtered
+}
+
+// excludeSelfFile drops from the files of dirPath the SBOM being written
+// to selfPath, which is absolute or relative to the working directory
+func excludeSelfFile(
+	log logrus.FieldLogger, dirPath string, fileList []string, selfPath string, caseInsensitive bool,
+) ([]string, error) {
+	selfPath, err := filepath.Abs(selfPath)
+	if err != nil {
+		return nil, fmt.Errorf("getting absolute path of the SBOM file: %w", err)
+	}
+	rel, err := filepath.Rel(dirPath, selfPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fileList, nil
+	}
+	rel = filepath.ToSlash(rel)
+	filtered := []string{}
+	for _, path := range fileList {
+		if path == rel || (caseInsensitive && strings.EqualFold(path, rel)) {
+			log.Infof("Skipping %s, it is the SBOM being written", path)
+			continue
+		}
+		filtered = append(filtered, path)
+	}
+	return filtered, nil
+}
+
+// IgnorePatterns return a list of gitignore patterns. Patterns to match
+// paths ignoring case are parsed in lower case, to be applied with
+// ApplyIgnorePatterns to lowered paths.
+//
+// The .gitignore files found in the subdirectories are read too, their
+// patterns only apply to the files under the directory where they are.
+// As in git, the patterns of deeper files take precedence and the files
+// in directories already ignored are not read. Only the rules of the
+// directory apply, the global excludes file of git is not read.
 func (di *spdxDefaultImplementation) IgnorePatterns(
-	dirPath string, extraPatterns []string, skipGitIgnore bool,
+	dirPath string, extraPatterns []string, skipGitIgnore, caseInsensitive bool,
+) ([]gitignore.Pattern, error) {
+	return di.ignorePatterns(logger(nil), dirPath, extraPatterns, skipGitIgnore, caseInsensitive, false)
+}
+
+// ignorePatterns is IgnorePatterns logging to log. Unless skipGitIgnore is
+// set, globalExcludes adds the rules of the global excludes file of git
+// before those of the gitignore files, which take precedence as in git.
+func (di *spdxDefaultImplementation) ignorePatterns(
+	log logrus.FieldLogger, dirPath string, extraPatterns []string, skipGitIgnore, caseInsensitive, globalExcludes bool,
 ) ([]gitignore.Pattern, error) {
+	parsePattern := func(s string, domain []string) gitignore.Pattern {
+		if caseInsensitive {
+			s = strings.ToLower(s)
+		}
+		return gitignore.ParsePattern(s, domain)
+	}
+
 	patterns := []gitignore.Pattern{}
 	for _, s := range extraPatterns {
-		patterns = append(patterns, gitignore.ParsePattern(s, nil))
+		patterns = append(patterns, parsePattern(s, nil))
 	}
 
 	if skipGitIgnore {
-		logrus.Debug("Not using patterns in .gitignore")
+		log.Debug("Not using patterns in .gitignore")
 		return patterns, nil
 	}
 
-	if util.Exists(filepath.Join(dirPath, gitIgnoreFile)) {
-		f, err := os.Open(filepath.Join(dirPath, gitIgnoreFile))
+	if globalExcludes {
+		excludesPath, excludes, err := readGlobalGitExcludes()
 		if err != nil {
-			return nil, fmt.Errorf("opening gitignore file: %w", err)
+			return nil, err
+		}
+		if len(excludes) > 0 {
+			log.Debugf("Loaded %d patterns from the global git excludes file %s", len(excludes), excludesPath)
+		}
+		for _, s := range excludes {
+			patterns = append(patterns, parsePattern(s, nil))
+		}
+	}
+
+	numGitignores := 0
+	if err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return fmt.Errorf("getting relative path: %w", err)
+		}
+		domain := []string{}
+		if rel != "." {
+			matchPath := rel
+			if caseInsensitive {
+				matchPath = strings.ToLower(rel)
+			}
+			domain = strings.Split(matchPath, string(filepath.Separator))
+			if d.Name() == ".git" || gitignore.NewMatcher(patterns).Match(domain, true) {
+				return filepath.SkipDir
+			}
+		}
+
+		gitignorePath := filepath.Join(path, gitIgnoreFile)
+		if !util.Exists(gitignorePath) {
+			return nil
+		}
+		f, err := os.Open(gitignorePath)
+		if err != nil {
+			return fmt.Errorf("opening gitignore file: %w", err)
 		}
 		defer f.Close()
 
 		// When using .gitignore files, we alwas add the .git directory
 		// to match git's behavior
-		patterns = append(patterns, gitignore.ParsePattern(".git/", nil))
-
-		scanner := bufio.NewScanner(f)
-		for scanner.Scan() {
-			s := scanner.Text()
-			if !strings.HasPrefix(s, "#") && len(strings.TrimSpace(s)) > 0 {
-				logrus.Debugf("Loaded .gitignore pattern: >>%s<<", s)
-				patterns = append(patterns, gitignore.ParsePattern(s, nil))
-			}
+		if numGitignores == 0 {
+			patterns = append(patterns, parsePattern(".git/", nil))
+		}
+		numGitignores++
+
+		gitignorePatterns, err := ReadIgnorePatterns(f)
+		if err != nil {
+			return fmt.Errorf("reading gitignore file: %w", err)
 		}
+		for _, s := range gitignorePatterns {
+			log.Debugf("Loaded .gitignore pattern from %s: >>%s<<", rel, s)
+			patterns = append(patterns, parsePattern(s, domain))
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("looking for .gitignore files: %w", err)
 	}
 
-	logrus.Debugf(
-		"Loaded %d patterns from .gitignore (+ %d extra) at root of directory", len(patterns), len(extraPatterns),
+	log.Debugf(
+		"Loaded %d patterns from %d .gitignore files (+ %d extra)", len(patterns), numGitignores, len(extraPatterns),
 	)
 	return patterns, nil
 }
 
-// ApplyIgnorePatterns applies the gitignore patterns to a list of files, removing matched
+// ReadIgnorePatterns reads gitignore rules from r, one per line, skipping
+// comments and blank lines. The result can be set as the GitignorePatterns
+// option to supply the rules of the directories scanned from memory.
+func ReadIgnorePatterns(r io.Reader) ([]string, error) {
+	patterns := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s := scanner.Text()
+		if !strings.HasPrefix(s, "#") && len(strings.TrimSpace(s)) > 0 {
+			patterns = append(patterns, s)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanning ignore patterns: %w", err)
+	}
+	return patterns, nil
+}
+
+// ApplyIgnorePatterns applies the gitignore patterns to a list of files, removing matched.
+// When caseInsensitive is true, the paths are lowered before matching them.
 func (di *spdxDefaultImplementation) ApplyIgnorePatterns(
-	fileList []string, patterns []gitignore.Pattern,
+	fileList []string, patterns []gitignore.Pattern, caseInsensitive bool,
+) (filteredList []string) {
+	return di.applyIgnorePatterns(logger(nil), fileList, patterns, caseInsensitive)
+}
+
+// applyIgnorePatterns is ApplyIgnorePatterns logging to log
+func (di *spdxDefaultImplementation) applyIgnorePatterns(
+	log logrus.FieldLogger, fileList []string, patterns []gitignore.Pattern, caseInsensitive bool,
 ) (filteredList []string) {
-	logrus.Infof(
+	log.Infof(
 		"Applying %d ignore patterns to list of %d filenames",
 		len(patterns), len(fileList),
 	)
@@ -548,8 +1551,12 @@ func (di *spdxDefaultImplementation) ApplyIgnorePatterns(
 
 	// Cycle all files, removing those matched:
 	for _, file := range fileList {
-		if matcher.Match(strings.Split(file, string(filepath.Separator)), false) {
-			logrus.Debugf("File ignored by .gitignore: %s", file)
+		matchPath := file
+		if caseInsensitive {
+			matchPath = strings.ToLower(file)
+		}
+		if matcher.Match(strings.Split(matchPath, string(filepath.Separator)), false) {
+			log.Debugf("File ignored by .gitignore: %s", file)
 		} else {
 			filteredList = append(filteredList, file)
 		}
@@ -562,53 +1569,105 @@ func (di *spdxDefaultImplementation) ApplyIgnorePatterns(
 func (di *spdxDefaultImplementation) GetGoDependencies(
 	path string, opts *Options,
 ) (spdxPackages []*Package, err error) {
+	spdxPackages, _, err = di.GetGoDependenciesWithErrors(path, opts)
+	return spdxPackages, err
+}
+
+// GetGoDependenciesWithErrors returns the dependencies of a go module as
+// GetGoDependencies does, along with a *DependencyError for each one that
+// could not be converted to an SPDX package and was left out.
+func (di *spdxDefaultImplementation) GetGoDependenciesWithErrors(
+	path string, opts *Options,
+) (spdxPackages []*Package, dropped []error, err error) {
 	// Open the directory as a go module:
 	mod, err := NewGoModuleFromPath(path)
 	if err != nil {
-		return nil, fmt.Errorf("creating a mod from the specified path: %w", err)
+		return nil, nil, fmt.Errorf("creating a mod from the specified path: %w", err)
 	}
 	mod.Options().OnlyDirectDeps = opts.OnlyDirectDeps
 	mod.Options().ScanLicenses = opts.ScanLicenses
+	mod.Options().GOOS = opts.GoTargetOS
+	mod.Options().GOARCH = opts.GoTargetArch
+	mod.Options().ProgressFn = opts.ProgressFn
+	mod.Options().Env = opts.GoEnv
+	mod.Options().ClassifyDeps = opts.ClassifyGoDeps
+	mod.Options().Logger = logger(opts)
+	if opts.MaxConcurrency > 0 {
+		mod.Options().Workers = opts.MaxConcurrency
+	}
+
+	// The packages downloaded are removed even if the resolution fails
+	// after downloading some of them
+	defer func() {
+		if rmErr := mod.RemoveDownloads(); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}()
 
 	// Open the module
 	if err := mod.Open(); err != nil {
-		return nil, fmt.Errorf("opening new module path: %w", err)
+		return nil, nil, fmt.Errorf("opening new module path: %w", err)
 	}
 
-	defer func() {
-		preErr := err
-		err = mod.RemoveDownloads()
-		if preErr != nil {
-			err = preErr
-		}
-	}()
 	if opts.ScanLicenses {
-		if errScan := mod.ScanLicenses(); err != nil {
-			return nil, errScan
+		if errScan := mod.ScanLicenses(); errScan != nil {
+			return nil, nil, errScan
+		}
+	} else if opts.LookupGoLicenses {
+		if errLookup := mod.LookupLicenses(); errLookup != nil {
+			return nil, nil, errLookup
 		}
 	}
 
+	spdxPackages, dropped = di.goPackagesToSPDX(opts, mod.Packages)
+	return spdxPackages, dropped, err
+}
+
+// goPackagesToSPDX converts the go packages of a module to SPDX packages.
+// The packages that cannot be converted are returned as errors. Resolving
+// the repositories of the packages may take network round-trips when
+// opts.LookupGoImports is set, so they are converted in parallel, but both
+// lists keep the order of goPackages.
+func (di *spdxDefaultImplementation) goPackagesToSPDX(
+	opts *Options, goPackages []*GoPackage,
+) (spdxPackages []*Package, dropped []error) {
+	converted := make([]*Package, len(goPackages))
+	errs := make([]error, len(goPackages))
+	resolver := newScanGoRepositoryResolver(opts)
+	t := throttler.New(defaultGoModuleWorkers, len(goPackages))
+	for i, goPkg := range goPackages {
+		i, goPkg := i, goPkg
+		di.workerPool.spawn(opts, func() {
+			converted[i], errs[i] = goPkg.toSPDXPackage(resolver)
+			t.Done(nil)
+		})
+		t.Throttle()
+	}
+
 	spdxPackages = []*Package{}
-	for _, goPkg := range mod.Packages {
-		spdxPkg, err := goPkg.ToSPDXPackage()
-		if err != nil {
+	for i, goPkg := range goPackages {
+		if errs[i] != nil {
 			// If a dependency cannot be converted, warn but do not die
-			logrus.Error(fmt.Errorf("converting go dependency to spdx package: %w", err))
+			di.warn(opts, goPkg.ImportPath, "converting go dependency to spdx package: %v", errs[i])
+			dropped = append(dropped, &DependencyError{Dependency: goPkg.ImportPath, Err: errs[i]})
 			continue
 		}
-		spdxPackages = append(spdxPackages, spdxPkg)
+		spdxPackages = append(spdxPackages, converted[i])
 	}
-
-	return spdxPackages, err
+	return spdxPackages, dropped
 }
 
 func (di *spdxDefaultImplementation) LicenseReader(spdxOpts *Options) (*license.Reader, error) {
-	opts := license.DefaultReaderOptions
+	opts := *license.DefaultReaderOptions
 	opts.CacheDir = spdxOpts.LicenseCacheDir
 	opts.LicenseDir = spdxOpts.LicenseData
 	opts.LicenseListVersion = spdxOpts.LicenseListVersion
+
+	// Without a license data directory, classify using the embedded list
+	opts.EmbeddedLicenses = spdxOpts.LicenseData == ""
+
 	// Create the new reader
-	reader, err := license.NewReaderWithOptions(opts)
+	reader, err := license.NewReaderWithOptions(&opts)
 	if err != nil {
 		return nil, fmt.Errorf("creating reusable license reader: %w", err)
 	}
@@ -620,24 +1679,105 @@ func (di *spdxDefaultImplementation) LicenseReader(spdxOpts *Options) (*license.
 func (di *spdxDefaultImplementation) GetDirectoryLicense(
 	reader *license.Reader, path string, spdxOpts *Options,
 ) (*license.License, error) {
-	licenseResult, err := reader.ReadTopLicense(path)
+	lic, _, err := di.directoryLicenses(reader, path, spdxOpts)
+	return lic, err
+}
+
+// directoryLicenses returns the license concluded for a directory and the
+// licenses detected in its top license files. Projects with several
+// license notices (eg LICENSE and LICENSE.third-party) are concluded to be
+// under all of them, combined with AND. License exceptions found in their
+// own files are applied to the license with WITH.
+func (di *spdxDefaultImplementation) directoryLicenses(
+	reader *license.Reader, path string, spdxOpts *Options,
+) (lic *license.License, detected []string, err error) {
+	release := di.cpuLimiter.acquire(spdxOpts)
+	stopClassification := di.stats.start(spdxOpts, phaseLicenseClassification)
+	licenseResults, err := reader.ReadTopLicenses(path)
+	stopClassification()
+	release()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting directory license: %w", err)
+	}
+
+	var licenses, exceptions []*license.License
+	for _, res := range licenseResults {
+		if res == nil || res.License == nil {
+			continue
+		}
+		if isLicenseException(res.License.LicenseID) {
+			exceptions = append(exceptions, res.License)
+			continue
+		}
+		detected = append(detected, res.License.LicenseID)
+		licenses = append(licenses, res.License)
+	}
+	if len(licenses) == 0 {
+		di.warn(spdxOpts, path, "License classifier could not find a license for directory %s", path)
+		return nil, nil, nil
+	}
+	lic = licenses[0]
+	if len(exceptions) > 0 {
+		if len(licenses) > 1 || strings.Contains(lic.LicenseID, " ") {
+			ids := []string{}
+			for _, e := range exceptions {
+				ids = append(ids, e.LicenseID)
+			}
+			di.warn(
+				spdxOpts, path, "Not concluding license exceptions %s of directory %s, the license they apply to is not known",
+				strings.Join(ids, ", "), path,
+			)
+		} else {
+			terms := []string{}
+			for _, e := range exceptions {
+				terms = append(terms, lic.LicenseID+" WITH "+e.LicenseID)
+			}
+			lic = &license.License{LicenseID: strings.Join(terms, " AND ")}
+		}
+	}
+	if len(licenses) > 1 {
+		terms := []string{}
+		for _, l := range licenses {
+			if strings.Contains(l.LicenseID, " ") {
+				terms = append(terms, "("+l.LicenseID+")")
+				continue
+			}
+			terms = append(terms, l.LicenseID)
+		}
+		lic = &license.License{LicenseID: strings.Join(terms, " AND ")}
+		logger(spdxOpts).Infof("Directory %s has several licenses, concluded %s", path, lic.LicenseID)
+	}
+
+	// The license is concluded for the directory package, make sure it is
+	// a valid expression. Licenses missing from the SPDX list are kept.
+	exp, err := license.ParseLicenseExpression(lic.LicenseID)
 	if err != nil {
-		return nil, fmt.Errorf("getting directory license: %w", err)
+		di.warn(spdxOpts, path, "Discarding the license found in directory %s: %v", path, err)
+		return nil, nil, nil
 	}
-	if licenseResult == nil {
-		logrus.Warnf("License classifier could not find a license for directory: %v", err)
-		return nil, nil
+	if unknown := reader.UnknownLicenses(exp); len(unknown) > 0 {
+		di.warn(
+			spdxOpts, path, "License of directory %s is not in the SPDX license list: %s",
+			path, strings.Join(unknown, ", "),
+		)
 	}
-	return licenseResult.License, nil
+	return lic, detected, nil
+}
+
+// isLicenseException returns true if the SPDX identifier is the one of a
+// license exception, eg Classpath-exception-2.0 or Linux-syscall-note
+func isLicenseException(id string) bool {
+	lower := strings.ToLower(id)
+	return !strings.Contains(id, " ") && (strings.Contains(lower, "exception") || strings.HasSuffix(lower, "-note"))
 }
 
 // purlFromImage builds a purl from an image reference
-func (*spdxDefaultImplementation) purlFromImage(img *ImageReferenceInfo) string {
+func (di *spdxDefaultImplementation) purlFromImage(ctx context.Context, opts *Options, img *ImageReferenceInfo) string {
 	// OCI type urls don't have a namespace ref:
 	// https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#oci
 	imageReference, err := name.ParseReference(img.Digest)
 	if err != nil {
-		logrus.Error(err)
+		logger(opts).Error(err)
 		return ""
 	}
 
@@ -650,20 +1790,14 @@ func (*spdxDefaultImplementation) purlFromImage(img *ImageReferenceInfo) string
 		}
 		digest = p[1]
 	} else {
-		digest, err = crane.Digest(img.Reference)
+		digest, err = di.digests.get(ctx, opts, img.Reference)
 		if err != nil {
-			logrus.Error(err)
+			logger(opts).Error(err)
 			return ""
 		}
 	}
 
-	url := imageReference.Context().Name()
-	parts := strings.Split(url, "/")
-	if len(parts) < 2 {
-		return ""
-	}
-	imageName := parts[len(parts)-1]
-	url = strings.TrimSuffix(url, "/"+imageName)
+	url, imageName := ociPurlRepository(imageReference.Context())
 
 	// Add the purl qualifgiers:
 	mm := map[string]string{
@@ -675,44 +1809,120 @@ func (*spdxDefaultImplementation) purlFromImage(img *ImageReferenceInfo) string
 	if img.OS != "" {
 		mm["os"] = img.OS
 	}
-	if tag, ok := imageReference.(name.Tag); ok {
-		mm["tag"] = tag.String()
+	if img.Tag != "" {
+		mm["tag"] = img.Tag
+	} else if tag, ok := imageReference.(name.Tag); ok {
+		mm["tag"] = tag.TagStr()
 	}
-	if img.MediaType != "" {
-		mm["mediaType"] = img.MediaType
+	if mediaType := purlMediaType(img); mediaType != "" {
+		mm["mediaType"] = mediaType
 	}
 
 	packageurl := purl.NewPackageURL(
-		purl.TypeOCI, "", imageName, digest,
+		purlType(opts, img), "", imageName, digest,
 		purl.QualifiersFromMap(mm), "",
 	)
 	return packageurl.String()
 }
 
+// dockerHubRegistries are the hostnames Docker Hub images are referenced
+// with, the purls use index.docker.io for all of them
+var dockerHubRegistries = map[string]struct{}{
+	name.DefaultRegistry:   {},
+	"docker.io":            {},
+	"registry-1.docker.io": {},
+}
+
+// ociPurlRepository splits an image repository in the repository_url
+// qualifier and the name of its oci purl. The registry is lowercased and
+// its default port dropped, and Docker Hub images get their hostname and
+// implicit library/ namespace spelled out, so all the references to the
+// same repository produce the same purl.
+func ociPurlRepository(repo name.Repository) (repositoryURL, imageName string) {
+	registry := strings.ToLower(repo.RegistryStr())
+	for _, port := range []string{":443", ":80"} {
+		registry = strings.TrimSuffix(registry, port)
+	}
+	repository := strings.ToLower(repo.RepositoryStr())
+	if _, ok := dockerHubRegistries[registry]; ok {
+		registry = name.DefaultRegistry
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+	}
+
+	repositoryURL = registry
+	namespace, imageName := path.Split(repository)
+	if namespace != "" {
+		repositoryURL += "/" + strings.TrimSuffix(namespace, "/")
+	}
+	return repositoryURL, imageName
+}
+
 // ImageRefToPackage Returns a spdx package from an OCI image reference
-func (di *spdxDefaultImplementation) ImageRefToPackage(ref string, opts *Options) (*Package, error) {
-	tmpdir, err := os.MkdirTemp("", "doc-build-")
+func (di *spdxDefaultImplementation) ImageRefToPackage(ctx context.Context, ref string, opts *Options) (*Package, error) {
+	defer di.tempPaths.cleanupOnPanic(opts)
+	if opts != nil {
+		di.referenceCache.configure(opts.ImageReferenceCacheTTL, opts.ImageReferenceCacheSize)
+	}
+
+	// Images streamed from the registry need no temporary workdir
+	if opts != nil && opts.StreamRegistryImages && !opts.DryRun {
+		if _, ok := daemonImageReference(opts, ref); !ok {
+			if !streamRegistryImagesUnsupported(opts) {
+				return di.packageFromRegistryStream(ctx, opts, ref)
+			}
+			di.warn(opts, ref, "Not streaming the layers of %s, the options set need them on disk", ref)
+		}
+	}
+
+	// Dry runs only resolve the images and their layers, nothing is read
+	// from the local daemon
+	if opts != nil && opts.DryRun {
+		if daemonRef, ok := daemonImageReference(opts, ref); ok {
+			return daemonScanPlan(daemonRef)
+		}
+		return di.imageScanPlan(ctx, opts, ref)
+	}
+
+	tmpdir, err := di.tempPaths.mkdirTemp(opts, "doc-build-")
 	if err != nil {
 		return nil, fmt.Errorf("creating temporary workdir in: %w", err)
 	}
-	defer os.RemoveAll(tmpdir)
+	defer di.tempPaths.remove(tmpdir)
+
+	if daemonRef, ok := daemonImageReference(opts, ref); ok {
+		return di.packageFromDaemonImage(ctx, opts, daemonRef, tmpdir)
+	}
 
-	references, err := di.PullImagesToArchive(ref, tmpdir)
+	stopDownload := di.stats.start(opts, phaseDownload)
+	references, err := di.PullImagesToArchive(ctx, opts, ref, tmpdir)
+	stopDownload()
 	if err != nil {
 		return nil, fmt.Errorf("while downloading images to archive: %w", err)
 	}
 
+	return di.imageReferencesPackage(ctx, opts, ref, references, di.referenceInfoToPackage)
+}
+
+// imageReferencesPackage builds the package of the images resolved from
+// ref, calling imagePackage to describe each one. A single image is
+// returned as is, an index gets a package with one for each image.
+func (di *spdxDefaultImplementation) imageReferencesPackage(
+	ctx context.Context, opts *Options, ref string, references *ImageReferenceInfo,
+	imagePackage func(ctx context.Context, opts *Options, img *ImageReferenceInfo) (*Package, error),
+) (*Package, error) {
 	topDigest, err := name.NewDigest(references.Digest)
 	if err != nil {
 		return nil, fmt.Errorf("parsing digest %s: %w", references.Digest, err)
 	}
-	logrus.Debugf("Reference %s produced %+v", ref, references)
+	logger(opts).Debugf("Reference %s produced %+v", ref, references)
 
 	// If we just got one image and that image is exactly the same
 	// reference, return a single package:
 	if len(references.Images) == 0 {
-		logrus.Infof("Generating single image package for %s", ref)
-		p, err := di.referenceInfoToPackage(opts, references)
+		logger(opts).Infof("Generating single image package for %s", ref)
+		p, err := imagePackage(ctx, opts, references)
 		if err != nil {
 			return nil, fmt.Errorf("generating image package: %w", err)
 		}
@@ -725,7 +1935,7 @@ func (di *spdxDefaultImplementation) ImageRefToPackage(ref string, opts *Options
 	}
 
 	// Create the package representing the image tag:
-	logrus.Infof("Generating SBOM for multiarch image %s", references.Digest)
+	logger(opts).Infof("Generating SBOM for multiarch image %s", references.Digest)
 	pkg := &Package{}
 
 	pkg.Name = topDigest.DigestStr()
@@ -734,10 +1944,16 @@ func (di *spdxDefaultImplementation) ImageRefToPackage(ref string, opts *Options
 	if references.Digest != "" {
 		pkg.DownloadLocation = references.Digest
 	}
+	if references.Tag != "" {
+		pkg.AddAnnotation(newToolAnnotation(imageTagAnnotation + references.Tag))
+	}
+	if references.ImplicitTag {
+		pkg.AddAnnotation(newToolAnnotation(implicitTagAnnotation + references.Tag))
+	}
 
 	// Now, cycle each image in the index and generate a package from it
 	for i := range references.Images {
-		subpkg, err := di.referenceInfoToPackage(opts, &references.Images[i])
+		subpkg, err := imagePackage(ctx, opts, &references.Images[i])
 		if err != nil {
 			return nil, fmt.Errorf("generating image package")
 		}
@@ -745,23 +1961,11 @@ func (di *spdxDefaultImplementation) ImageRefToPackage(ref string, opts *Options
 		// Rebuild the ID to compose it with the parent element
 		subpkg.BuildID(pkg.Name, subpkg.Name)
 
-		// Add the package to the image
-		pkg.AddRelationship(&Relationship{
-			Peer:       subpkg,
-			Type:       CONTAINS,
-			FullRender: true,
-			Comment:    "Container image lager",
-		})
-		// And add an inverse relationship to the index
-		subpkg.AddRelationship(&Relationship{
-			Peer:    pkg,
-			Type:    VARIANT_OF,
-			Comment: "Image index",
-		})
+		linkImageVariant(opts, pkg, subpkg)
 	}
 
 	// Add a the topmost package purl
-	packageurl := di.purlFromImage(references)
+	packageurl := di.purlFromImage(ctx, opts, references)
 	if packageurl != "" {
 		pkg.ExternalRefs = append(pkg.ExternalRefs, ExternalRef{
 			Category: CatPackageManager,
@@ -772,12 +1976,118 @@ func (di *spdxDefaultImplementation) ImageRefToPackage(ref string, opts *Options
 	return pkg, nil
 }
 
-func (di *spdxDefaultImplementation) referenceInfoToPackage(opts *Options, img *ImageReferenceInfo) (*Package, error) {
+// linkImageVariant adds the relationships between the package of an
+// image index and the package of one of its variants
+func linkImageVariant(opts *Options, index, variant *Package) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	// Add the package to the image
+	index.AddRelationship(opts.ImageVariantRelationship.Apply(&Relationship{
+		Peer:       variant,
+		Type:       CONTAINS,
+		FullRender: true,
+		Comment:    "Container image lager",
+	}))
+	// And add an inverse relationship to the index
+	variant.AddRelationship(opts.ImageIndexRelationship.Apply(&Relationship{
+		Peer:    index,
+		Type:    VARIANT_OF,
+		Comment: "Image index",
+	}))
+}
+
+// ImageOSPackages pulls the images referenced by ref and returns the
+// operating system packages found in them, without building an SBOM.
+// When the reference points to an index, the packages of all variants
+// are returned.
+func (di *spdxDefaultImplementation) ImageOSPackages(
+	ctx context.Context, ref string, opts *Options,
+) ([]osinfo.PackageDBEntry, error) {
+	defer di.tempPaths.cleanupOnPanic(opts)
+	if opts != nil {
+		di.referenceCache.configure(opts.ImageReferenceCacheTTL, opts.ImageReferenceCacheSize)
+	}
+	tmpdir, err := di.tempPaths.mkdirTemp(opts, "os-packages-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary workdir: %w", err)
+	}
+	defer di.tempPaths.remove(tmpdir)
+
+	stopDownload := di.stats.start(opts, phaseDownload)
+	references, err := di.PullImagesToArchive(ctx, opts, ref, tmpdir)
+	stopDownload()
+	if err != nil {
+		return nil, fmt.Errorf("while downloading images to archive: %w", err)
+	}
+
+	archives := []string{}
+	if references.Archive != "" {
+		archives = append(archives, references.Archive)
+	}
+	for i := range references.Images {
+		archives = append(archives, references.Images[i].Archive)
+	}
+
+	packages := []osinfo.PackageDBEntry{}
+	for _, archive := range archives {
+		pkgs, err := di.imageTarballOSPackages(opts, archive)
+		if err != nil {
+			return nil, fmt.Errorf("reading os packages from %s: %w", archive, err)
+		}
+		packages = append(packages, pkgs...)
+	}
+	return packages, nil
+}
+
+// imageTarballOSPackages reads the OS packages from an image archive
+func (di *spdxDefaultImplementation) imageTarballOSPackages(
+	opts *Options, tarPath string,
+) ([]osinfo.PackageDBEntry, error) {
+	extractDir, err := di.ExtractTarballTmp(opts, tarPath)
+	if err != nil {
+		return nil, fmt.Errorf("extracting tarball to temp dir: %w", err)
+	}
+	defer di.tempPaths.remove(extractDir)
+
+	manifest, err := di.ReadArchiveManifest(filepath.Join(extractDir, archiveManifestFilename))
+	if err != nil {
+		return nil, fmt.Errorf("while reading docker archive manifest: %w", err)
+	}
+
+	layerPaths := []string{}
+	for _, layerFile := range manifest.LayerFiles {
+		layerPaths = append(layerPaths, filepath.Join(extractDir, layerFile))
+	}
+
+	ct := osinfo.ContainerScanner{}
+	stopOSScan := di.stats.start(opts, phaseOSScan)
+	_, osPackageData, err := ct.ReadOSPackages(layerPaths)
+	stopOSScan()
+	if err != nil {
+		return nil, fmt.Errorf("getting os data from container: %w", err)
+	}
+	if osPackageData == nil {
+		return []osinfo.PackageDBEntry{}, nil
+	}
+	return *osPackageData, nil
+}
+
+func (di *spdxDefaultImplementation) referenceInfoToPackage(
+	ctx context.Context, opts *Options, img *ImageReferenceInfo,
+) (*Package, error) {
 	subpkg, err := di.PackageFromImageTarball(opts, img.Archive)
 	if err != nil {
 		return nil, fmt.Errorf("adding image variant package: %w", err)
 	}
+	return di.describeImageReference(ctx, opts, img, subpkg)
+}
 
+// describeImageReference names the package of an image after its digest,
+// recording its tag and purl
+func (di *spdxDefaultImplementation) describeImageReference(
+	ctx context.Context, opts *Options, img *ImageReferenceInfo, subpkg *Package,
+) (*Package, error) {
 	imageDigest, err := name.NewDigest(img.Digest)
 	if err != nil {
 		return nil, fmt.Errorf("parsing digest %s: %w", img.Digest, err)
@@ -788,7 +2098,20 @@ func (di *spdxDefaultImplementation) referenceInfoToPackage(opts *Options, img *
 	}
 	subpkg.FileName = ""
 
-	packageurl := di.purlFromImage(img)
+	if img.Arch == "" && img.OS == "" {
+		subpkg.AddAnnotation(newToolAnnotation(unknownPlatformAnnotation))
+		di.warn(opts, img.Digest, "%s for %s", unknownPlatformAnnotation, img.Digest)
+	}
+
+	// The digest identifies the image, the tag is only recorded
+	if img.Tag != "" {
+		subpkg.AddAnnotation(newToolAnnotation(imageTagAnnotation + img.Tag))
+	}
+	if img.ImplicitTag {
+		subpkg.AddAnnotation(newToolAnnotation(implicitTagAnnotation + img.Tag))
+	}
+
+	packageurl := di.purlFromImage(ctx, opts, img)
 	if packageurl != "" {
 		subpkg.ExternalRefs = append(subpkg.ExternalRefs, ExternalRef{
 			Category: CatPackageManager,
@@ -805,11 +2128,28 @@ func (di *spdxDefaultImplementation) referenceInfoToPackage(opts *Options, img *
 func (di *spdxDefaultImplementation) PackageFromImageTarball(
 	spdxOpts *Options, tarPath string,
 ) (imagePackage *Package, err error) {
-	logrus.Infof("Generating SPDX package from image tarball %s", tarPath)
+	logger(spdxOpts).Infof("Generating SPDX package from image tarball %s", tarPath)
 	if tarPath == "" {
 		return nil, errors.New("tar path empty")
 	}
 
+	if spdxOpts.StreamLayers {
+		if !spdxOpts.AnalyzeLayers && !spdxOpts.AddTarFiles && !spdxOpts.ScanImages &&
+			!spdxOpts.DetectSecrets && !spdxOpts.ScanImageFilesystem {
+			return di.packageFromImageTarballStream(spdxOpts, tarPath)
+		}
+		di.warn(spdxOpts, tarPath, "Not streaming the layers of %s, the options set need them extracted", tarPath)
+	}
+
+	// The OS package databases and the container filesystem are read from
+	// all the layers flattened, so they cannot be scanned one at a time
+	if spdxOpts.LazyLayers {
+		if !spdxOpts.ScanImages && !spdxOpts.ScanImageFilesystem {
+			return di.packageFromImageTarballLazy(spdxOpts, tarPath)
+		}
+		di.warn(spdxOpts, tarPath, "Not reading layers lazily, scanning OS packages or the filesystem needs all the layers of %s", tarPath)
+	}
+
 	// Extract all files from tarfile
 	tarOpts := &TarballOptions{}
 
@@ -819,32 +2159,33 @@ func (di *spdxDefaultImplementation) PackageFromImageTarball(
 	if spdxOpts.AddTarFiles && !spdxOpts.AnalyzeLayers {
 		tarOpts.AddFiles = true
 	}
-	tarOpts.ExtractDir, err = di.ExtractTarballTmp(tarPath)
+	tarOpts.TempStorageMode = spdxOpts.TempStorageMode
+	tarOpts.ExtractDir, err = di.ExtractTarballTmp(spdxOpts, tarPath)
 	if err != nil {
 		return nil, fmt.Errorf("extracting tarball to temp dir: %w", err)
 	}
-	defer os.RemoveAll(tarOpts.ExtractDir)
+	defer di.tempPaths.remove(tarOpts.ExtractDir)
+
+	// Tarballs wrapping a plain file or directory have no image manifest
+	if spdxOpts.PlainTarballFallback && !hasImageManifest(tarOpts.ExtractDir) {
+		logger(spdxOpts).Infof("%s has no image manifest, reading it as a plain tarball", tarPath)
+		return di.plainTarballPackage(spdxOpts, tarPath, tarOpts.ExtractDir)
+	}
 
 	// Read the archive manifest json:
-	manifest, err := di.ReadArchiveManifest(
+	manifests, err := di.ReadArchiveManifests(
 		filepath.Join(tarOpts.ExtractDir, archiveManifestFilename),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("while reading docker archive manifest: %w", err)
 	}
-
-	if len(manifest.RepoTags) == 0 {
-		return nil, errors.New("no RepoTags found in manifest")
-	}
-
-	if manifest.RepoTags[0] == "" {
-		return nil, errors.New(
-			"unable to add tar archive, manifest does not have a RepoTags entry",
-		)
+	// Check the tags before scanning anything
+	for i := range manifests {
+		if _, err := manifestRepoTag(&manifests[i]); err != nil {
+			return nil, err
+		}
 	}
 
-	logrus.Infof("Package describes image %s", manifest.RepoTags[0])
-
 	// Create the new SPDX package
 	imagePackage, err = di.PackageFromTarball(spdxOpts, tarOpts, tarPath)
 	if err != nil {
@@ -852,108 +2193,337 @@ func (di *spdxDefaultImplementation) PackageFromImageTarball(
 	}
 	imagePackage.Options().WorkDir = tarOpts.ExtractDir
 	imagePackage.Name = filepath.Base(tarPath)
-	imagePackage.BuildID(manifest.RepoTags[0])
 	imagePackage.Comment = "Container image archive"
-	logrus.Infof("Image manifest lists %d layers", len(manifest.LayerFiles))
 
+	if err := describeArchiveImages(spdxOpts, imagePackage, manifests, func(
+		manifest *ArchiveManifest, imagePackage *Package, repoTag string,
+	) error {
+		logger(spdxOpts).Infof("Package describes image %s", repoTag)
+		logger(spdxOpts).Infof("Image manifest lists %d layers", len(manifest.LayerFiles))
+
+		layerPaths := []string{}
+		for _, layerFile := range manifest.LayerFiles {
+			layerPaths = append(layerPaths, filepath.Join(tarOpts.ExtractDir, layerFile))
+		}
+		if !spdxOpts.SkipLayerVerification {
+			if err := di.verifyArchiveLayers(spdxOpts, tarOpts.ExtractDir, manifest); err != nil {
+				return fmt.Errorf("verifying the layers of %s: %w", repoTag, err)
+			}
+		}
+		layerPackages, err := di.imageLayerPackages(
+			spdxOpts, tarOpts, repoTag, layerPaths, "Container image layer from archive",
+		)
+		if err != nil {
+			return err
+		}
+
+		// Add the layer packages to the image package
+		if err := addLayerPackages(spdxOpts, imagePackage, layerPackages); err != nil {
+			return err
+		}
+
+		if spdxOpts.ScanImageFilesystem {
+			fsPackage, err := di.imageFilesystemPackage(spdxOpts, repoTag, layerPaths)
+			if err != nil {
+				return fmt.Errorf("scanning the filesystem of %s: %w", repoTag, err)
+			}
+			if err := imagePackage.AddPackage(fsPackage); err != nil {
+				return fmt.Errorf("adding filesystem to image package: %w", err)
+			}
+		}
+
+		// Record the build steps from the image history
+		if manifest.ConfigFilename != "" {
+			config, err := readImageConfig(filepath.Join(tarOpts.ExtractDir, manifest.ConfigFilename))
+			if err != nil {
+				return fmt.Errorf("recording image history: %w", err)
+			}
+			di.recordImageHistory(spdxOpts, config, manifest.Annotations, imagePackage, layerPackages)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// return the finished package
+	return imagePackage, nil
+}
+
+// imageFilesystemPackage describes the filesystem of a container running
+// the image made of the layers at layerPaths, extracting them on top of
+// each other. The dependencies of the projects found in it are not read.
+func (di *spdxDefaultImplementation) imageFilesystemPackage(
+	opts *Options, repoTag string, layerPaths []string,
+) (*Package, error) {
+	rootfs, err := di.ExtractLayersTmp(opts, layerPaths)
+	if err != nil {
+		return nil, err
+	}
+	defer di.tempPaths.remove(rootfs)
+
+	fsOpts := *opts
+	fsOpts.ProcessGoModules = false
+	fsOpts.ProcessNPMModules = false
+	fsOpts.ProcessPython = false
+	fsPackage, err := di.PackageFromDirectory(&fsOpts, rootfs)
+	if err != nil {
+		return nil, err
+	}
+	fsPackage.Name = "filesystem"
+	fsPackage.Comment = "Filesystem of a container running the image, with the whiteouts of its layers applied"
+	fsPackage.BuildID(repoTag, fsPackage.Name)
+	return fsPackage, nil
+}
+
+// describeArchiveImages calls describe to add the layers of each image
+// listed in the manifests of an image archive to the package of the image.
+// An archive holding a single image is that image, so archivePackage is
+// passed to describe. Archives holding several images (eg saved by docker
+// with several tags) get a package for each image, added to archivePackage.
+func describeArchiveImages(
+	opts *Options, archivePackage *Package, manifests []ArchiveManifest,
+	describe func(manifest *ArchiveManifest, imagePackage *Package, repoTag string) error,
+) error {
+	if len(manifests) == 1 {
+		repoTag, err := manifestRepoTag(&manifests[0])
+		if err != nil {
+			return err
+		}
+		archivePackage.BuildID(repoTag)
+		return describe(&manifests[0], archivePackage, repoTag)
+	}
+
+	logger(opts).Infof("Image archive %s holds %d images", archivePackage.Name, len(manifests))
+	archivePackage.BuildID(archivePackage.Name)
+	for i := range manifests {
+		repoTag, err := manifestRepoTag(&manifests[i])
+		if err != nil {
+			return err
+		}
+		imagePackage := NewPackage()
+		imagePackage.Name = repoTag
+		imagePackage.BuildID(repoTag)
+		imagePackage.Comment = "Container image saved in archive " + archivePackage.Name
+		if err := describe(&manifests[i], imagePackage, repoTag); err != nil {
+			return fmt.Errorf("describing image %s: %w", repoTag, err)
+		}
+		if err := archivePackage.AddPackage(imagePackage); err != nil {
+			return fmt.Errorf("adding image %s to archive package: %w", repoTag, err)
+		}
+	}
+	return nil
+}
+
+// manifestRepoTag returns the first tag of the image in an archive manifest.
+// Images in OCI layouts without a reference are identified by the digest
+// of their manifest.
+func manifestRepoTag(manifest *ArchiveManifest) (string, error) {
+	if len(manifest.RepoTags) == 0 && manifest.ManifestDigest != "" {
+		return manifest.ManifestDigest, nil
+	}
+	if len(manifest.RepoTags) == 0 {
+		return "", errors.New("no RepoTags found in manifest")
+	}
+
+	if manifest.RepoTags[0] == "" {
+		return "", errors.New(
+			"unable to add tar archive, manifest does not have a RepoTags entry",
+		)
+	}
+	return manifest.RepoTags[0], nil
+}
+
+// imageLayerPackages generates the packages describing the layers of an
+// image, in order. When ScanImages is set, the OS packages are added to the
+// layer where they were installed. imageID is used to build the layer IDs.
+func (di *spdxDefaultImplementation) imageLayerPackages(
+	spdxOpts *Options, tarOpts *TarballOptions, imageID string, layerPaths []string, layerComment string,
+) ([]*Package, error) {
 	// Scan the container layers for OS information:
 	ct := osinfo.ContainerScanner{}
 	var osPackageData *[]osinfo.PackageDBEntry
 	var layerNum int
-	layerPaths := []string{}
-	for _, layerFile := range manifest.LayerFiles {
-		layerPaths = append(layerPaths, filepath.Join(tarOpts.ExtractDir, layerFile))
+	var err error
+
+	// Images holding static binaries FROM scratch have no package
+	// database to probe, the modules compiled into the binaries are
+	// described instead when the options ask for it
+	goResolver := newScanGoRepositoryResolver(spdxOpts)
+	var staticBinaries []staticBinary
+	if spdxOpts.ScanStaticImages {
+		staticBinaries, err = findStaticBinaries(spdxOpts, layerPaths)
+		if err != nil {
+			return nil, fmt.Errorf("looking for static binaries in image: %w", err)
+		}
+	}
+	if staticBinaries != nil {
+		logger(spdxOpts).Infof("Image has %d static Go binaries and no package database, not scanning OS packages", len(staticBinaries))
+	}
+
+	// The modules of the Go binaries of other images are described when
+	// the options ask for them
+	goBinaries := staticBinaries
+	if goBinaries == nil && spdxOpts.ScanGoBinaries {
+		goBinaries, err = findLayerGoBinaries(spdxOpts, layerPaths)
+		if err != nil {
+			return nil, fmt.Errorf("looking for Go binaries in image: %w", err)
+		}
+		logger(spdxOpts).Infof("Image has %d Go binaries", len(goBinaries))
 	}
 
 	// Scan for package data if option is set
-	if spdxOpts.ScanImages {
+	if spdxOpts.ScanImages && staticBinaries == nil {
+		stopOSScan := di.stats.start(spdxOpts, phaseOSScan)
 		layerNum, osPackageData, err = ct.ReadOSPackages(layerPaths)
+		stopOSScan()
 		if err != nil {
 			return nil, fmt.Errorf("getting os data from container: %w", err)
 		}
 	}
 
 	if osPackageData != nil {
-		logrus.Infof(
-			"Scan of container image returned %d OS packages in layer #%d",
+		logger(spdxOpts).Infof(
+			"Scan of container image returned %d OS packages, database last updated in layer #%d",
 			len(*osPackageData), layerNum,
 		)
 	}
 
-	// Cycle all the layers from the manifest and add them as packages
-	for i, layerFile := range manifest.LayerFiles {
+	// layerPackage generates the package describing the layer at index i
+	layerOpts := *tarOpts
+	layerOpts.layer = true
+	layerPackage := func(i int, layerPath string) (*Package, error) {
 		// Generate a package from a layer
-		pkg, err := di.PackageFromTarball(spdxOpts, tarOpts, filepath.Join(tarOpts.ExtractDir, layerFile))
+		pkg, err := di.PackageFromTarball(spdxOpts, &layerOpts, layerPath)
 		if err != nil {
 			return nil, fmt.Errorf("building package from layer: %w", err)
 		}
 
 		pkg.Name = "sha256:" + pkg.Checksum["SHA256"]
-		pkg.Comment = "Container image layer from archive"
+		pkg.Comment = layerComment
 
 		// Regenerate the BuildID to avoid clashes when handling multiple
 		// images at the same time.
-		pkg.BuildID(manifest.RepoTags[0], pkg.Name)
+		pkg.BuildID(imageID, pkg.Name)
+
+		if spdxOpts.DetectSecrets {
+			if err := di.scanLayerSecrets(spdxOpts, pkg.Name, layerPath); err != nil {
+				return nil, fmt.Errorf("scanning layer for secrets: %w", err)
+			}
+		}
 
 		// If the option is enabled, scan the container layers
 		if spdxOpts.AnalyzeLayers {
-			if err := di.AnalyzeImageLayer(filepath.Join(tarOpts.ExtractDir, layerFile), pkg); err != nil {
+			if err := di.AnalyzeImageLayer(layerPath, pkg, spdxOpts); err != nil {
 				return nil, fmt.Errorf("scanning layer "+pkg.ID+" :%w", err)
 			}
-		} else {
-			logrus.Info("Not performing deep image analysis (opts.AnalyzeLayers = false)")
-		}
-
-		// If we got the OS data from the scanner, add the packages:
-		if i == layerNum && osPackageData != nil {
-			for i := range *osPackageData {
-				ospk := NewPackage()
-				ospk.Name = (*osPackageData)[i].Package
-				ospk.Version = (*osPackageData)[i].Version
-				ospk.HomePage = (*osPackageData)[i].HomePage
-				ospk.Originator = struct {
-					Person       string
-					Organization string
-				}{
-					Person: (*osPackageData)[i].MaintainerName,
-				}
-				if (*osPackageData)[i].License != "" {
-					ospk.LicenseDeclared = (*osPackageData)[i].License
+			if spdxOpts.DetectBinaryLinkage {
+				if err := annotateLayerLinkage(spdxOpts, layerPath, pkg); err != nil {
+					return nil, fmt.Errorf("reading the linkage of layer binaries: %w", err)
 				}
-				ospk.Checksum = (*osPackageData)[i].Checksums
+			}
+			if err := runLayerAnalyzers(spdxOpts.LayerAnalyzers, layerPath, pkg); err != nil {
+				return nil, fmt.Errorf("scanning layer %s: %w", pkg.ID, err)
+			}
+		} else {
+			logger(spdxOpts).Info("Not performing deep image analysis (opts.AnalyzeLayers = false)")
+		}
 
-				if (*osPackageData)[i].MaintainerName != "" {
-					ospk.Supplier.Person = (*osPackageData)[i].MaintainerName
-					if (*osPackageData)[i].MaintainerEmail != "" {
-						ospk.Supplier.Person += fmt.Sprintf(" (%s)", (*osPackageData)[i].MaintainerEmail)
-					}
-				}
-				if (*osPackageData)[i].PackageURL() != "" {
-					ospk.ExternalRefs = append(ospk.ExternalRefs, ExternalRef{
-						Category: CatPackageManager,
-						Type:     "purl",
-						Locator:  (*osPackageData)[i].PackageURL(),
-					})
+		for j := range goBinaries {
+			if goBinaries[j].Layer != i {
+				continue
+			}
+			binPkg, err := goBinaries[j].spdxPackage(goResolver)
+			if err != nil {
+				return nil, fmt.Errorf("describing Go binary %s: %w", goBinaries[j].Path, err)
+			}
+			if err := pkg.AddPackage(binPkg); err != nil {
+				return nil, fmt.Errorf("adding Go binary to container layer: %w", err)
+			}
+		}
+
+		// If we got the OS data from the scanner, add the packages
+		// installed in this layer:
+		if osPackageData != nil {
+			for j := range *osPackageData {
+				if (*osPackageData)[j].Layer != i {
+					continue
 				}
+				ospk := osPackageFromDBEntry(&(*osPackageData)[j])
 				ospk.BuildID(pkg.ID)
-				if err := pkg.AddPackage(ospk); err != nil {
+				if err := pkg.addTemplatedPackage(ospk, spdxOpts.LayerPackageRelationship); err != nil {
 					return nil, fmt.Errorf("adding OS package to container layer: %w", err)
 				}
 			}
 		}
 
-		// Add the layer package to the image package
-		if err := imagePackage.AddPackage(pkg); err != nil {
-			return nil, fmt.Errorf("adding layer to image package: %w", err)
-		}
+		return pkg, nil
+	}
+
+	// Cycle all the layers and generate their packages. The layers are
+	// scanned in parallel but stored by index to keep their order.
+	workers := spdxOpts.LayerWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	layerPackages := make([]*Package, len(layerPaths))
+	t := throttler.New(workers, len(layerPaths))
+	progress := newProgressCounter(progressFn(spdxOpts), ProgressPhaseLayerScan, len(layerPaths))
+	for i, layerPath := range layerPaths {
+		i, layerPath := i, layerPath
+		di.workerPool.spawnPhase(func() {
+			defer di.tempPaths.cleanupOnPanic(spdxOpts)
+			pkg, err := layerPackage(i, layerPath)
+			layerPackages[i] = pkg
+			progress.done()
+			t.Done(err)
+		})
+		t.Throttle()
+	}
+	if err := t.Err(); err != nil {
+		return nil, err
 	}
+	return layerPackages, nil
+}
 
-	// return the finished package
-	return imagePackage, nil
+// osPackageFromDBEntry builds a SPDX package from an entry read from
+// an operating system package database
+func osPackageFromDBEntry(entry *osinfo.PackageDBEntry) *Package {
+	ospk := NewPackage()
+	ospk.Name = entry.Package
+	ospk.Version = entry.Version
+	ospk.HomePage = entry.HomePage
+	ospk.Originator = struct {
+		Person       string
+		Organization string
+	}{
+		Person: entry.MaintainerName,
+	}
+	if entry.License != "" {
+		ospk.LicenseDeclared = entry.License
+	}
+	ospk.Checksum = entry.Checksums
+
+	if entry.MaintainerName != "" {
+		ospk.Supplier.Person = entry.MaintainerName
+		if entry.MaintainerEmail != "" {
+			ospk.Supplier.Person += fmt.Sprintf(" (%s)", entry.MaintainerEmail)
+		}
+	}
+	if entry.PackageURL() != "" {
+		ospk.ExternalRefs = append(ospk.ExternalRefs, ExternalRef{
+			Category: CatPackageManager,
+			Type:     "purl",
+			Locator:  entry.PackageURL(),
+		})
+	}
+	if entry.InstallReason != "" {
+		ospk.AddAnnotation(newToolAnnotation(installReasonAnnotation + entry.InstallReason))
+	}
+	return ospk
 }
 
-func (di *spdxDefaultImplementation) AnalyzeImageLayer(layerPath string, pkg *Package) error {
-	return NewImageAnalyzer().AnalyzeLayer(layerPath, pkg)
+func (di *spdxDefaultImplementation) AnalyzeImageLayer(layerPath string, pkg *Package, opts *Options) error {
+	return newImageAnalyzer(opts).AnalyzeLayer(layerPath, pkg)
 }
 
 // PackageFromDirectory scans a directory and returns its contents as a
@@ -963,96 +2533,328 @@ func (di *spdxDefaultImplementation) PackageFromDirectory(opts *Options, dirPath
 	if err != nil {
 		return nil, fmt.Errorf("getting absolute directory path: %w", err)
 	}
-	fileList, err := di.GetDirectoryTree(dirPath)
+	checksumAlgorithms, err := normalizeChecksumAlgorithms(opts.ChecksumAlgorithms)
 	if err != nil {
-		return nil, fmt.Errorf("building directory tree: %w", err)
+		return nil, err
 	}
-	reader, err := di.LicenseReader(opts)
+	unknownInFile, unknownConcluded, err := unknownLicenseTags(opts.UnknownLicensePolicy)
 	if err != nil {
-		return nil, fmt.Errorf("creating license reader: %w", err)
+		return nil, err
 	}
-	licenseTag := ""
-	lic, err := di.GetDirectoryLicense(reader, dirPath, opts)
+	// On case-insensitive filesystems, README and readme are the same file
+	caseInsensitive := caseInsensitivePaths(opts, dirPath)
+	fileList, err := di.getDirectoryTree(logger(opts), dirPath, caseInsensitive, opts.FollowSymlinks)
 	if err != nil {
-		return nil, fmt.Errorf("scanning directory for licenses: %w", err)
+		return nil, fmt.Errorf("building directory tree: %w", err)
 	}
-	if lic != nil {
-		licenseTag = lic.LicenseID
+	var reader *license.Reader
+	var detectedLicenses []string
+	licenseTag := NOASSERTION
+	if !opts.SkipLicenseScan && !opts.DryRun {
+		reader, err = di.LicenseReader(opts)
+		if err != nil {
+			return nil, fmt.Errorf("creating license reader: %w", err)
+		}
+		var lic *license.License
+		lic, detectedLicenses, err = di.directoryLicenses(reader, dirPath, opts)
+		if err != nil {
+			return nil, fmt.Errorf("scanning directory for licenses: %w", err)
+		}
+		licenseTag = unknownConcluded
+		if lic != nil {
+			licenseTag = lic.LicenseID
+		}
 	}
 
 	// Build a list of patterns from those found in the .gitignore file and
-	// posssibly others passed in the options:
-	patterns, err := di.IgnorePatterns(
-		dirPath, opts.IgnorePatterns, opts.NoGitignore,
+	// posssibly others passed in the options. Gitignore rules supplied in
+	// the options are used instead of reading the file.
+	extraPatterns, skipGitIgnore := opts.IgnorePatterns, opts.NoGitignore
+	if opts.GitignorePatterns != nil && !opts.NoGitignore {
+		extraPatterns = append(append(append([]string{}, extraPatterns...), ".git/"), opts.GitignorePatterns...)
+		skipGitIgnore = true
+	}
+	if opts.UseDockerignore {
+		dockerPatterns, err := readDockerignorePatterns(dirPath)
+		if err != nil {
+			return nil, err
+		}
+		extraPatterns = append(append([]string{}, extraPatterns...), dockerPatterns...)
+	}
+	patterns, err := di.ignorePatterns(
+		logger(opts), dirPath, extraPatterns, skipGitIgnore, caseInsensitive, opts.GlobalGitignore,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("building ignore patterns list: %w", err)
 	}
 
 	// Apply the ignore patterns to the list of files
-	fileList = di.ApplyIgnorePatterns(fileList, patterns)
+	fileList = di.applyIgnorePatterns(logger(opts), fileList, patterns, caseInsensitive)
+
+	// Keep only the files with the extensions in the options
+	if len(opts.ScanExtensions) > 0 {
+		fileList = filterExtensions(logger(opts), fileList, opts.ScanExtensions)
+	}
+
+	// Drop the files with the extensions excluded in the options
+	if len(opts.ExcludeExtensions) > 0 {
+		fileList = excludeExtensions(logger(opts), fileList, opts.ExcludeExtensions)
+	}
+
+	// The SBOM written to the directory does not describe itself
+	if opts.SelfFileName != "" {
+		fileList, err = excludeSelfFile(logger(opts), dirPath, fileList, opts.SelfFileName, caseInsensitive)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Drop the zero-byte files and those over the size limit
+	if opts.SkipEmptyFiles || opts.MaxFileSize > 0 {
+		fileList, err = filterFileSizes(logger(opts), dirPath, fileList, opts.SkipEmptyFiles, opts.MaxFileSize)
+		if err != nil {
+			return nil, fmt.Errorf("filtering files by size: %w", err)
+		}
+	}
 	if len(fileList) == 0 {
 		return nil, fmt.Errorf("directory %s has no files to scan", dirPath)
 	}
-	logrus.Infof("Scanning %d files and adding them to the SPDX package", len(fileList))
-
 	pkg = NewPackage()
 	pkg.FilesAnalyzed = true
 	pkg.Name = filepath.Base(dirPath)
 	if pkg.Name == "" {
-		pkg.Name = uuid.NewString()
+		if opts.Reproducible {
+			pkg.Name = reproducibleUUID(strings.Join(fileList, "\n"))
+		} else {
+			pkg.Name = uuid.NewString()
+		}
 	}
+
+	// Dry runs return the files that would be scanned
+	if opts.DryRun {
+		pkg.FilesAnalyzed = false
+		pkg.Plan = &ScanPlan{Files: fileList}
+		return pkg, nil
+	}
+
+	// Empty directories have no file elements, they are recorded in the
+	// annotations of the package
+	if opts.AnnotateEmptyFiles {
+		emptyDirs, err := emptyDirectories(dirPath, patterns, caseInsensitive)
+		if err != nil {
+			return nil, fmt.Errorf("looking for empty directories: %w", err)
+		}
+		for _, dir := range emptyDirs {
+			pkg.AddAnnotation(newScanAnnotation(opts, emptyDirectoryAnnotation+dir))
+		}
+	}
+	logger(opts).Infof("Scanning %d files and adding them to the SPDX package", len(fileList))
 	pkg.LicenseConcluded = licenseTag
+	// The licenses of the license files are listed with those of the files
+	pkg.directoryLicenses = detectedLicenses
 
 	// Set the working directory of the package:
 	pkg.Options().WorkDir = filepath.Dir(dirPath)
 
+	// Git checkouts record the revision scanned
+	pkg.SourceInfo = gitSourceInfo(logger(opts), dirPath)
+
+	// Files scanned before can be reused if they did not change
+	priorFiles := newPriorFiles(opts.PriorDocument)
+
 	t := throttler.New(5, len(fileList))
 
-	processDirectoryFile := func(path string, pkg *Package) {
-		defer t.Done(err)
+	// Each file records its own error by its index in the list. Unless
+	// the options ask to continue, files not started when one fails are
+	// skipped.
+	fileErrors := make([]*FileError, len(fileList))
+	var failed atomic.Bool
+	progress := newProgressCounter(progressFn(opts), ProgressPhaseFileScan, len(fileList))
+
+	processDirectoryFile := func(i int, path string, pkg *Package) {
+		defer di.tempPaths.cleanupOnPanic(opts)
+		var err error
+		defer func() {
+			if err != nil {
+				fileErrors[i] = &FileError{Path: path, Err: err}
+				failed.Store(true)
+			}
+			progress.done()
+			t.Done(nil)
+		}()
+		if failed.Load() && !opts.ContinueOnFileError {
+			return
+		}
+		release := di.cpuLimiter.acquire(opts)
+		defer release()
 		f := NewFile()
 		f.Options().WorkDir = dirPath
 		f.Options().Prefix = pkg.Name
-
-		lic, err = reader.LicenseFromFile(filepath.Join(dirPath, path))
-		if err != nil {
-			err = fmt.Errorf("scanning file for license: %w", err)
-			return
+		f.Options().ChecksumAlgorithms = checksumAlgorithms
+		f.Options().ComputeSWHID = opts.ComputeSWHID
+
+		info, statErr := os.Stat(filepath.Join(dirPath, path))
+		if statErr == nil {
+			f.Size = info.Size()
+			f.ModTime = info.ModTime()
+			if opts.RecordFileMetadata {
+				f.setMetadata(di.diskFileMetadata(dirPath, filepath.Join(dirPath, path), info))
+			}
 		}
 
-		// If a file does not contain a license then we assume
-		// the whole repository license applies. If it has one,
-		// the we conclude that files is released under those licenses.
-		f.LicenseInfoInFile = NONE
-		if lic == nil {
+		prior := priorFiles.unchanged(filepath.Join(dirPath, path), info, checksumAlgorithms)
+		if prior != nil && opts.ComputeSWHID && prior.SWHID() == "" {
+			prior = nil
+		}
+		if prior != nil {
+			// Files not modified since the prior scan keep its results
+			logger(opts).Debugf("Reusing checksums and license of unchanged file %s", path)
+			f.reuse(prior, filepath.Join(dirPath, path), checksumAlgorithms)
+			if opts.ComputeSWHID {
+				f.setSWHID(prior.SWHID())
+			}
 			f.LicenseConcluded = licenseTag
+			if f.LicenseInfoInFile == NONE {
+				f.LicenseInfoInFile = unknownInFile
+			} else {
+				f.LicenseConcluded = f.LicenseInfoInFile
+			}
 		} else {
-			f.LicenseInfoInFile = lic.LicenseID
-			f.LicenseConcluded = lic.LicenseID
+			// Without a license scan the licenses are unknown
+			f.LicenseInfoInFile = NOASSERTION
+			f.LicenseConcluded = NOASSERTION
+			if reader != nil {
+				stopClassification := di.stats.start(opts, phaseLicenseClassification)
+				var lic *license.License
+				lic, err = reader.LicenseFromFile(filepath.Join(dirPath, path))
+				stopClassification()
+				if err != nil {
+					err = fmt.Errorf("scanning file for license: %w", err)
+					return
+				}
+
+				// If a file does not contain a license then we assume
+				// the whole repository license applies. If it has one,
+				// the we conclude that files is released under those licenses.
+				f.LicenseInfoInFile = unknownInFile
+				if lic == nil {
+					f.LicenseConcluded = licenseTag
+				} else {
+					f.LicenseInfoInFile = lic.LicenseID
+					f.LicenseConcluded = lic.LicenseID
+				}
+			}
+
+			stopFileScan := di.stats.start(opts, phaseFileScan)
+			err = f.ReadSourceFile(filepath.Join(dirPath, path))
+			stopFileScan()
+			if err != nil {
+				err = fmt.Errorf("checksumming file: %w", err)
+				return
+			}
 		}
 
-		if err = f.ReadSourceFile(filepath.Join(dirPath, path)); err != nil {
-			err = fmt.Errorf("checksumming file: %w", err)
-			return
+		if opts.AggregateCopyrights {
+			if cErr := f.readCopyrightText(filepath.Join(dirPath, path)); cErr != nil {
+				di.warn(opts, path, "Could not read the copyright notices of %s: %v", path, cErr)
+			}
+		}
+
+		if opts.AnalyzeBinaries {
+			if binErr := annotateBinaryInfo(f, filepath.Join(dirPath, path)); binErr != nil {
+				di.warn(opts, path, "Could not read the architecture of %s: %v", path, binErr)
+			}
+		}
+
+		if opts.DetectGeneratedFiles {
+			if genErr := annotateGeneratedFile(f, filepath.Join(dirPath, path), opts.GeneratedPatterns); genErr != nil {
+				di.warn(opts, path, "Could not check if %s is generated: %v", path, genErr)
+			}
+		}
+
+		// Zero-byte files are flagged so they can be told apart
+		if opts.AnnotateEmptyFiles && statErr == nil && info.Size() == 0 {
+			f.AddAnnotation(newScanAnnotation(opts, emptyFileAnnotation))
 		}
 		if err = pkg.AddFile(f); err != nil {
-			err = fmt.Errorf("adding %s as file to the spdx package: %w", path, err)
+			err = fmt.Errorf("adding file to the spdx package: %w", err)
 			return
 		}
 	}
 
 	// Read the files in parallel
-	for _, path := range fileList {
-		go processDirectoryFile(path, pkg)
+	for i, path := range fileList {
+		i, path := i, path
+		di.workerPool.spawn(opts, func() { processDirectoryFile(i, path, pkg) })
 		t.Throttle()
 	}
 
-	// If the throttler picked an error, fail here
-	if err := t.Err(); err != nil {
-		return nil, err
+	// Files that could not be scanned fail the whole directory, or are
+	// left out of the package when the options ask to continue
+	for _, fe := range fileErrors {
+		if fe == nil {
+			continue
+		}
+		if !opts.ContinueOnFileError {
+			return nil, fmt.Errorf("scanning directory %s: %w", dirPath, fe)
+		}
+		di.warn(opts, fe.Path, "Skipping file %s: %v", fe.Path, fe.Err)
+	}
+
+	if opts.AggregateCopyrights {
+		pkg.AggregateFileCopyrights()
+	}
+
+	// Link files embedded in go sources to the files that embed them
+	if opts.ProcessGoModules {
+		di.linkGoEmbeddedFiles(opts, pkg)
 	}
 
+	if opts.IncludeDirectoryStructure {
+		if err := groupDirectoryFiles(pkg, dirPath); err != nil {
+			return nil, fmt.Errorf("grouping files by directory: %w", err)
+		}
+	}
+
+	computeVerificationCodes(opts, pkg)
+
 	// Add files into the package
 	return pkg, nil
 }
+
+// computeVerificationCodes sets the verification codes of a package of a
+// scanned directory, archive or image and of the packages it contains (eg
+// those grouping its subdirectories or the image layers), leaving out the
+// files excluded in the options
+func computeVerificationCodes(opts *Options, pkg *Package) {
+	excluded := map[string]struct{}{}
+	for _, path := range opts.VerificationExcludedFiles {
+		excluded[filepath.ToSlash(filepath.Clean(path))] = struct{}{}
+	}
+	seen := map[*Package]struct{}{}
+	var compute func(p *Package)
+	compute = func(p *Package) {
+		if _, ok := seen[p]; ok {
+			return
+		}
+		seen[p] = struct{}{}
+		p.VerificationCodeExcludedFiles = nil
+		for _, f := range p.Files() {
+			if _, ok := excluded[f.Name]; ok {
+				p.VerificationCodeExcludedFiles = append(p.VerificationCodeExcludedFiles, f.Name)
+			}
+		}
+		sort.Strings(p.VerificationCodeExcludedFiles)
+		// The code cannot be computed when files have no checksums at
+		// all, eg in packages parsed from documents or built by hand
+		if err := p.ComputeVerificationCode(); err != nil {
+			logger(opts).Warnf("Not computing the verification code of %s: %v", p.Name, err)
+		}
+		for _, rel := range p.Relationships {
+			if sub, ok := rel.Peer.(*Package); ok && sub != nil && rel.Type == CONTAINS {
+				compute(sub)
+			}
+		}
+	}
+	compute(pkg)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"io/fs"
+)
+
+// priorFiles indexes the files of a document from a previous scan by
+// the path of their source file
+type priorFiles map[string]*File
+
+// newPriorFiles collects the files of doc which recorded the size and
+// modification time of their source file. Documents parsed from SBOMs
+// don't record them, so none of their files can be reused.
+func newPriorFiles(doc *Document) priorFiles {
+	pf := priorFiles{}
+	if doc == nil {
+		return pf
+	}
+	seen := map[Object]struct{}{}
+	var collect func(Object)
+	collect = func(o Object) {
+		if _, ok := seen[o]; ok {
+			return
+		}
+		seen[o] = struct{}{}
+		if f, ok := o.(*File); ok && f.SourceFile != "" && !f.ModTime.IsZero() && len(f.Checksum) > 0 {
+			pf[f.SourceFile] = f
+		}
+		for _, rel := range *o.GetRelationships() {
+			if rel.Peer != nil {
+				collect(rel.Peer)
+			}
+		}
+	}
+	for _, p := range doc.Packages {
+		collect(p)
+	}
+	for _, f := range doc.Files {
+		collect(f)
+	}
+	return pf
+}
+
+// unchanged returns the prior file scanned from path if the size and
+// modification time of the file still match those recorded in it and
+// it has the checksums of all the algorithms
+func (pf priorFiles) unchanged(path string, info fs.FileInfo, algorithms []string) *File {
+	prior, ok := pf[path]
+	if !ok || info == nil || !hasChecksums(prior.Checksum, algorithms) {
+		return nil
+	}
+	if prior.Size != info.Size() || !prior.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+	return prior
+}
+
+// reuse copies into f the data of a prior scan of the file at path,
+// leaving the checksums of the algorithms and license found then in
+// place of a rescan
+func (f *File) reuse(prior *File, path string, algorithms []string) {
+	f.Checksum = make(map[string]string, len(algorithms))
+	for _, algo := range algorithms {
+		f.Checksum[algo] = prior.Checksum[algo]
+	}
+	f.LicenseInfoInFile = prior.LicenseInfoInFile
+	f.FileType = append([]string{}, prior.FileType...)
+	f.setSourceFile(path)
+	if f.SPDXID() == "" {
+		f.BuildID()
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/release-utils/hash"
+)
+
+func TestPackageFromDirectoryPriorDocument(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"unchanged.txt", "changed.txt"} {
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, name), []byte("contents of "+name), os.FileMode(0o644),
+		))
+	}
+
+	impl := spdxDefaultImplementation{}
+	opts := &Options{}
+	first, err := impl.PackageFromDirectory(opts, dir)
+	require.NoError(t, err)
+
+	// Tamper the checksums recorded in the first scan. If the file
+	// was hashed again, the checksums would be the real ones.
+	for _, f := range first.Files() {
+		for algo := range f.Checksum {
+			f.Checksum[algo] = "from-prior-scan"
+		}
+	}
+	prior := NewDocument()
+	require.NoError(t, prior.AddPackage(first))
+
+	changedPath := filepath.Join(dir, "changed.txt")
+	require.NoError(t, os.WriteFile(changedPath, []byte("new contents"), os.FileMode(0o644)))
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(changedPath, later, later))
+
+	opts.PriorDocument = prior
+	second, err := impl.PackageFromDirectory(opts, dir)
+	require.NoError(t, err)
+	require.Len(t, second.Files(), 2)
+	for _, f := range second.Files() {
+		switch filepath.Base(f.SourceFile) {
+		case "unchanged.txt":
+			require.Equal(t, "from-prior-scan", f.Checksum["SHA256"])
+			require.Equal(t, NONE, f.LicenseInfoInFile)
+		case "changed.txt":
+			csum, err := hash.SHA256ForFile(changedPath)
+			require.NoError(t, err)
+			require.Equal(t, csum, f.Checksum["SHA256"])
+			require.Equal(t, later.Unix(), f.ModTime.Unix())
+		default:
+			t.Fatalf("unexpected file %s", f.SourceFile)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inputDigestAnnotation prefixes the annotations recording the digests
+// of the inputs scanned to generate a document
+const inputDigestAnnotation = "Input digest: "
+
+// AddInputDigest records the digest of an input scanned to generate the
+// document. It is kept in InputDigests and written to the document as an
+// annotation so consumers can check the SBOM was generated from an artifact.
+func (d *Document) AddInputDigest(input, digest string) {
+	if d.InputDigests == nil {
+		d.InputDigests = map[string]string{}
+	}
+	d.InputDigests[input] = digest
+	d.AddAnnotation(newToolAnnotation(inputDigestAnnotation + input + " " + digest))
+}
+
+// FileDigest returns the SHA256 digest of a file as sha256:<hex>
+func FileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening file: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+// DirectoryDigest returns a deterministic digest of the contents of a
+// directory tree as sha256:<hex>. It is the TreeHash of a package holding
+// a file for each path relative to dir, with its SHA256. Symbolic links are
+// hashed by their target and .git directories are left out. Modes and
+// modification times do not change the digest.
+func DirectoryDigest(dir string) (string, error) {
+	tree := NewPackage()
+	tree.Name = "directory"
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("getting relative path: %w", err)
+		}
+		rel = filepath.ToSlash(rel)
+
+		var sum string
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("reading symlink: %w", err)
+			}
+			sum = fmt.Sprintf("%x", sha256.Sum256([]byte(filepath.ToSlash(target))))
+		case d.Type().IsRegular():
+			digest, err := FileDigest(path)
+			if err != nil {
+				return err
+			}
+			sum = strings.TrimPrefix(digest, "sha256:")
+		default:
+			return nil
+		}
+		f := NewFile()
+		f.Name = rel
+		f.FileName = rel
+		f.Checksum = map[string]string{"SHA256": sum}
+		return tree.AddFile(f)
+	}); err != nil {
+		return "", fmt.Errorf("walking directory %s: %w", dir, err)
+	}
+	return "sha256:" + tree.TreeHash(), nil
+}
+
+// recordInputDigests adds to the document the digests of the directories,
+// tarballs, archives and files in the options. Images pulled from a
+// registry are already identified by their digest in their packages.
+func recordInputDigests(genopts *DocGenerateOptions, doc *Document) error {
+	for _, dir := range genopts.Directories {
+		digest, err := DirectoryDigest(dir)
+		if err != nil {
+			return fmt.Errorf("computing digest of directory: %w", err)
+		}
+		doc.AddInputDigest(dir, digest)
+	}
+	for _, list := range [][]string{genopts.Tarballs, genopts.Archives, genopts.GoBinaries, genopts.Files} {
+		for _, path := range list {
+			digest, err := FileDigest(path)
+			if err != nil {
+				return fmt.Errorf("computing digest of input: %w", err)
+			}
+			doc.AddInputDigest(path, digest)
+		}
+	}
+	return nil
+}
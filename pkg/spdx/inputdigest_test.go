@@ -0,0 +1,80 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordInputDigests(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "src", ".git"), os.FileMode(0o755)))
+	for path, content := range map[string]string{
+		"README.md":       "readme",
+		"src/main.go":     "package main",
+		"src/.git/HEAD":   "ref: refs/heads/main",
+		"src/sub/data.db": "data",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), os.FileMode(0o644)))
+	}
+	archive := filepath.Join(t.TempDir(), "archive.tar")
+	require.NoError(t, os.WriteFile(archive, []byte("not really a tarball"), os.FileMode(0o644)))
+
+	doc := NewDocument()
+	require.NoError(t, recordInputDigests(&DocGenerateOptions{
+		Directories: []string{dir},
+		Archives:    []string{archive},
+	}, doc))
+	require.Len(t, doc.InputDigests, 2)
+	require.Len(t, doc.Annotations, 2)
+
+	// The recorded digests match the ones computed again from the inputs
+	dirDigest, err := DirectoryDigest(dir)
+	require.NoError(t, err)
+	require.Equal(t, dirDigest, doc.InputDigests[dir])
+	require.Equal(t, inputDigestAnnotation+dir+" "+dirDigest, doc.Annotations[0].Comment)
+	archiveDigest, err := FileDigest(archive)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("not really a tarball"))), archiveDigest)
+	require.Equal(t, archiveDigest, doc.InputDigests[archive])
+
+	// Git metadata and modification times do not change the tree digest
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src/.git/HEAD"), []byte("ref: refs/heads/other"), os.FileMode(0o644)))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "README.md"), time.Now(), time.Unix(0, 0)))
+	newDigest, err := DirectoryDigest(dir)
+	require.NoError(t, err)
+	require.Equal(t, dirDigest, newDigest)
+
+	// Changing the contents or renaming a file does
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src/main.go"), []byte("package other"), os.FileMode(0o644)))
+	newDigest, err = DirectoryDigest(dir)
+	require.NoError(t, err)
+	require.NotEqual(t, dirDigest, newDigest)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src/main.go"), []byte("package main"), os.FileMode(0o644)))
+	require.NoError(t, os.Rename(filepath.Join(dir, "src/sub/data.db"), filepath.Join(dir, "src/sub/other.db")))
+	newDigest, err = DirectoryDigest(dir)
+	require.NoError(t, err)
+	require.NotEqual(t, dirDigest, newDigest)
+}
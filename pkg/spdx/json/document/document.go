@@ -28,6 +28,7 @@ type Document interface {
 	GetRelationships() []Relationship
 	GetDocumentDescribes() []string
 	GetExternalDocumentRefs() []ExternalDocumentRef
+	GetAnnotations() []Annotation
 }
 
 type CreationInfo interface {
@@ -43,6 +44,7 @@ type File interface {
 	GetLicenseConcluded() string
 	GetLicenseInfoInFile() []string
 	GetChecksums() []Checksum
+	GetAnnotations() []Annotation
 }
 
 type Relationship interface {
@@ -66,14 +68,25 @@ type Package interface {
 	GetFilesAnalyzed() bool
 	GetLicenseDeclared() string
 	GetVersion() string
+	GetSupplier() string
+	GetOriginator() string
 	GetVerificationCode() PackageVerificationCode
 	GetPrimaryPurpose() string
 	GetChecksums() []Checksum
 	GetExternalRefs() []ExternalRef
+	GetAnnotations() []Annotation
 }
 
 type PackageVerificationCode interface {
 	GetValue() string
+	GetExcludedFiles() []string
+}
+
+type Annotation interface {
+	GetAnnotator() string
+	GetDate() string
+	GetType() string
+	GetComment() string
 }
 
 type Checksum interface {
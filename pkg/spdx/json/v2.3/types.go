@@ -35,6 +35,7 @@ type Document struct {
 	Packages             []Package             `json:"packages"`
 	Relationships        []Relationship        `json:"relationships"`
 	ExternalDocumentRefs []ExternalDocumentRef `json:"externalDocumentRefs,omitempty"`
+	Annotations          []Annotation          `json:"annotations,omitempty"`
 }
 
 func (d *Document) GetVersion() string                     { return d.Version }
@@ -77,6 +78,14 @@ func (d *Document) GetExternalDocumentRefs() []document.ExternalDocumentRef {
 	return externalDocumentRefs
 }
 
+func (d *Document) GetAnnotations() []document.Annotation {
+	annotations := make([]document.Annotation, len(d.Annotations))
+	for i := range d.Annotations {
+		annotations[i] = &d.Annotations[i]
+	}
+	return annotations
+}
+
 type CreationInfo struct {
 	Created            string   `json:"created"` // Date
 	Creators           []string `json:"creators"`
@@ -96,6 +105,7 @@ type Package struct {
 	LicenseConcluded     string                   `json:"licenseConcluded,omitempty"`
 	Description          string                   `json:"description,omitempty"`
 	DownloadLocation     string                   `json:"downloadLocation"`
+	Supplier             string                   `json:"supplier,omitempty"`
 	Originator           string                   `json:"originator,omitempty"`
 	SourceInfo           string                   `json:"sourceInfo,omitempty"`
 	CopyrightText        string                   `json:"copyrightText"`
@@ -105,6 +115,7 @@ type Package struct {
 	Checksums            []Checksum               `json:"checksums"`
 	ExternalRefs         []ExternalRef            `json:"externalRefs,omitempty"`
 	VerificationCode     *PackageVerificationCode `json:"packageVerificationCode,omitempty"`
+	Annotations          []Annotation             `json:"annotations,omitempty"`
 }
 
 func (p *Package) GetID() string               { return p.ID }
@@ -115,6 +126,8 @@ func (p *Package) GetLicenseConcluded() string { return p.LicenseConcluded }
 func (p *Package) GetFilesAnalyzed() bool      { return p.FilesAnalyzed }
 func (p *Package) GetLicenseDeclared() string  { return p.LicenseDeclared }
 func (p *Package) GetVersion() string          { return p.Version }
+func (p *Package) GetSupplier() string         { return p.Supplier }
+func (p *Package) GetOriginator() string       { return p.Originator }
 func (p *Package) GetPrimaryPurpose() string   { return p.PrimaryPurpose }
 
 func (p *Package) GetVerificationCode() document.PackageVerificationCode {
@@ -140,23 +153,33 @@ func (p *Package) GetExternalRefs() []document.ExternalRef {
 	return externalRefs
 }
 
+func (p *Package) GetAnnotations() []document.Annotation {
+	annotations := make([]document.Annotation, len(p.Annotations))
+	for i := range p.Annotations {
+		annotations[i] = &p.Annotations[i]
+	}
+	return annotations
+}
+
 type PackageVerificationCode struct {
 	Value         string   `json:"packageVerificationCodeValue,omitempty"`
 	ExcludedFiles []string `json:"packageVerificationCodeExcludedFiles,omitempty"`
 }
 
-func (p *PackageVerificationCode) GetValue() string { return p.Value }
+func (p *PackageVerificationCode) GetValue() string           { return p.Value }
+func (p *PackageVerificationCode) GetExcludedFiles() []string { return p.ExcludedFiles }
 
 type File struct {
-	ID                string     `json:"SPDXID"`
-	Name              string     `json:"fileName"`
-	CopyrightText     string     `json:"copyrightText"`
-	NoticeText        string     `json:"noticeText,omitempty"`
-	LicenseConcluded  string     `json:"licenseConcluded,omitempty"`
-	Description       string     `json:"description,omitempty"`
-	FileTypes         []string   `json:"fileTypes,omitempty"`
-	LicenseInfoInFile []string   `json:"licenseInfoInFiles,omitempty"` // List of licenses
-	Checksums         []Checksum `json:"checksums"`
+	ID                string       `json:"SPDXID"`
+	Name              string       `json:"fileName"`
+	CopyrightText     string       `json:"copyrightText"`
+	NoticeText        string       `json:"noticeText,omitempty"`
+	LicenseConcluded  string       `json:"licenseConcluded,omitempty"`
+	Description       string       `json:"description,omitempty"`
+	FileTypes         []string     `json:"fileTypes,omitempty"`
+	LicenseInfoInFile []string     `json:"licenseInfoInFiles,omitempty"` // List of licenses
+	Checksums         []Checksum   `json:"checksums"`
+	Annotations       []Annotation `json:"annotations,omitempty"`
 }
 
 func (f *File) GetID() string                  { return f.ID }
@@ -173,6 +196,26 @@ func (f *File) GetChecksums() []document.Checksum {
 	return checksums
 }
 
+func (f *File) GetAnnotations() []document.Annotation {
+	annotations := make([]document.Annotation, len(f.Annotations))
+	for i := range f.Annotations {
+		annotations[i] = &f.Annotations[i]
+	}
+	return annotations
+}
+
+type Annotation struct {
+	Annotator string `json:"annotator"`
+	Date      string `json:"annotationDate"`
+	Type      string `json:"annotationType"`
+	Comment   string `json:"comment"`
+}
+
+func (a *Annotation) GetAnnotator() string { return a.Annotator }
+func (a *Annotation) GetDate() string      { return a.Date }
+func (a *Annotation) GetType() string      { return a.Type }
+func (a *Annotation) GetComment() string   { return a.Comment }
+
 type Checksum struct {
 	Algorithm string `json:"algorithm"`
 	Value     string `json:"checksumValue"`
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// archiveLayerDiffIDs returns the hex encoded digests of the uncompressed
+// contents of each layer in the manifest (the diff IDs) as listed in the
+// image config. Layers whose digest is not sha256 get an empty one. It
+// returns nil when the config does not list a diff ID for each layer, and
+// the layers cannot be verified.
+func archiveLayerDiffIDs(opts *Options, config *v1.ConfigFile, manifest *ArchiveManifest) []string {
+	diffIDs := config.RootFS.DiffIDs
+	if len(diffIDs) != len(manifest.LayerFiles) {
+		logger(opts).Debugf(
+			"Not verifying the layers, the image config lists %d digests for %d layers",
+			len(diffIDs), len(manifest.LayerFiles),
+		)
+		return nil
+	}
+	digests := make([]string, len(diffIDs))
+	for i, layerFile := range manifest.LayerFiles {
+		if diffIDs[i].Algorithm != "sha256" {
+			logger(opts).Debugf("Not verifying layer %s, its digest is %s", layerFile, diffIDs[i])
+			continue
+		}
+		digests[i] = diffIDs[i].Hex
+	}
+	return digests
+}
+
+// verifyArchiveLayers checks that the layers of an image extracted to dir
+// match the digests of their uncompressed contents (the diff IDs) listed
+// in the image config. Images without a config listing a diff ID for each
+// layer cannot be verified and are skipped.
+func (di *spdxDefaultImplementation) verifyArchiveLayers(
+	opts *Options, dir string, manifest *ArchiveManifest,
+) error {
+	if manifest.ConfigFilename == "" {
+		return nil
+	}
+	config, err := readImageConfig(filepath.Join(dir, manifest.ConfigFilename))
+	if err != nil {
+		return err
+	}
+	for i, diffID := range archiveLayerDiffIDs(opts, config, manifest) {
+		layerFile := manifest.LayerFiles[i]
+		if err := di.cpuLimiter.run(opts, func() error {
+			return verifyLayerFile(filepath.Join(dir, layerFile), layerFile, diffID)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyLayerFile checks that the uncompressed contents of the layer blob
+// at path match diffID. Layers with an empty diffID are not checked.
+func verifyLayerFile(path, name, diffID string) error {
+	if diffID == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("verifying layer %s: %w", name, err)
+	}
+	defer f.Close()
+	r, err := decompressedStream(f)
+	if err != nil {
+		return fmt.Errorf("verifying layer %s: %w", name, err)
+	}
+	if _, err := io.Copy(io.Discard, newDiffIDReader(r, diffID)); err != nil {
+		return fmt.Errorf("verifying layer %s: %w", name, err)
+	}
+	return nil
+}
+
+// diffIDReader hashes the uncompressed contents of a layer as they are
+// read, failing the read of the end of the layer if they do not match its
+// diff ID. Readers of the layer, extracting or streaming it, get the check
+// without reading it again.
+type diffIDReader struct {
+	r      io.Reader
+	hash   hash.Hash
+	diffID string // Expected hex encoded sha256 digest
+}
+
+// newDiffIDReader returns a reader of the uncompressed layer contents in r
+// checking them against diffID. An empty diffID returns r unchanged.
+func newDiffIDReader(r io.Reader, diffID string) io.Reader {
+	if diffID == "" {
+		return r
+	}
+	return &diffIDReader{r: r, hash: sha256.New(), diffID: diffID}
+}
+
+func (dr *diffIDReader) Read(p []byte) (int, error) {
+	n, err := dr.r.Read(p)
+	dr.hash.Write(p[:n])
+	if err == io.EOF {
+		if digest := hex.EncodeToString(dr.hash.Sum(nil)); digest != dr.diffID {
+			return n, fmt.Errorf(
+				"layer does not match its digest, expected sha256:%s but got sha256:%s (the archive may be truncated or corrupt)",
+				dr.diffID, digest,
+			)
+		}
+	}
+	return n, err
+}
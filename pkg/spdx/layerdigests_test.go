@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromImageTarballVerifyLayers(t *testing.T) {
+	tarPath := writeTestImageTarball(t, "../osinfo/testdata/link-with-no-dots.tar.gz")
+	otherLayer, err := os.ReadFile("../osinfo/testdata/link-with-dots.tar.gz")
+	require.NoError(t, err)
+
+	// rewriteLayer copies the archive replacing its layer contents
+	rewriteLayer := func(replace func([]byte) []byte) string {
+		in, err := os.Open(tarPath)
+		require.NoError(t, err)
+		defer in.Close()
+		outPath := filepath.Join(t.TempDir(), "image.tar")
+		out, err := os.Create(outPath)
+		require.NoError(t, err)
+		defer out.Close()
+		tr := tar.NewReader(in)
+		tw := tar.NewWriter(out)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			if strings.HasSuffix(hdr.Name, ".tar.gz") {
+				data = replace(data)
+				hdr.Size = int64(len(data))
+			}
+			require.NoError(t, tw.WriteHeader(hdr))
+			_, err = tw.Write(data)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		return outPath
+	}
+
+	swapped := rewriteLayer(func([]byte) []byte { return otherLayer })
+	truncated := rewriteLayer(func(data []byte) []byte { return data[:len(data)/2] })
+
+	impl := spdxDefaultImplementation{}
+	for name, opts := range map[string]Options{
+		"extract": {},
+		"lazy":    {LazyLayers: true},
+		"stream":  {StreamLayers: true},
+	} {
+		opts := opts
+		t.Run(name, func(t *testing.T) {
+			_, err := impl.PackageFromImageTarball(&opts, tarPath)
+			require.NoError(t, err)
+
+			// A layer with other contents fails the digest check
+			_, err = impl.PackageFromImageTarball(&opts, swapped)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "does not match its digest")
+
+			// Unless the check is skipped
+			skipOpts := opts
+			skipOpts.SkipLayerVerification = true
+			_, err = impl.PackageFromImageTarball(&skipOpts, swapped)
+			require.NoError(t, err)
+
+			// Truncated layers cannot be read to the end
+			_, err = impl.PackageFromImageTarball(&opts, truncated)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+)
+
+// addLayerPackages adds the packages of the layers of an image to the image
+// package, in order. If the options ask for it, the files repeated in
+// several layers are described only once.
+func addLayerPackages(spdxOpts *Options, imagePackage *Package, layerPackages []*Package) error {
+	if spdxOpts.DedupeLayerFiles {
+		if n := dedupeLayerFiles(layerPackages); n > 0 {
+			logger(spdxOpts).Infof("Described %d files repeated in the layers of %s only once", n, imagePackage.Name)
+		}
+	}
+	for _, pkg := range layerPackages {
+		if err := imagePackage.addTemplatedPackage(pkg, spdxOpts.ImageLayerRelationship); err != nil {
+			return fmt.Errorf("adding layer to image package: %w", err)
+		}
+	}
+	return nil
+}
+
+// dedupeLayerFiles replaces the files of each layer found with the same
+// path and SHA256 checksum in a lower layer by the file of the lowest one.
+// The layers keep their CONTAINS relationships to the files, but only the
+// layer where a file first appears renders it. Returns the number of files
+// replaced.
+func dedupeLayerFiles(layerPackages []*Package) int {
+	seen := map[string]*File{}
+	replaced := 0
+	for _, pkg := range layerPackages {
+		pkg.Lock()
+		for _, rel := range pkg.Relationships {
+			f, ok := rel.Peer.(*File)
+			if !ok || f == nil || rel.Type != CONTAINS || f.Checksum["SHA256"] == "" {
+				continue
+			}
+			key := f.Name + "@" + f.Checksum["SHA256"]
+			first, ok := seen[key]
+			if !ok {
+				seen[key] = f
+				continue
+			}
+			rel.Peer = first
+			rel.PeerReference = ""
+			rel.FullRender = false
+			replaced++
+		}
+		pkg.Unlock()
+	}
+	return replaced
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromImageTarballDedupeLayerFiles(t *testing.T) {
+	// layerData returns a layer with the files
+	layerData := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for name, data := range files {
+			require.NoError(t, tw.WriteHeader(&tar.Header{
+				Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+			}))
+			_, err := tw.Write([]byte(data))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		return buf.Bytes()
+	}
+	// The unchanged file is repeated in the upper layers, the changed
+	// one has other contents in each layer
+	tarPath := writeTestDockerArchive(t,
+		[]string{"base/layer.tar", "app/layer.tar", "top/layer.tar"},
+		[][]byte{
+			layerData(map[string]string{"etc/unchanged": "same\n", "etc/changed": "base\n"}),
+			layerData(map[string]string{"etc/unchanged": "same\n", "etc/changed": "app\n"}),
+			layerData(map[string]string{"etc/unchanged": "same\n", "etc/changed": "top\n"}),
+		},
+	)
+
+	impl := spdxDefaultImplementation{}
+	for _, tc := range []struct {
+		opts              Options
+		renderedUnchanged int
+	}{
+		{Options{AddTarFiles: true}, 3},
+		{Options{AddTarFiles: true, DedupeLayerFiles: true}, 1},
+		{Options{StreamLayers: true, DedupeLayerFiles: true}, 1},
+	} {
+		tc := tc
+		pkg, err := impl.PackageFromImageTarball(&tc.opts, tarPath)
+		require.NoError(t, err)
+		layers := containedPackages(pkg)
+		require.Len(t, layers, 3)
+
+		// Every layer still contains both files
+		var unchanged *File
+		for _, layer := range layers {
+			require.Len(t, layer.Files(), 2)
+			for _, f := range layer.Files() {
+				if f.Name != "etc/unchanged" {
+					continue
+				}
+				if tc.opts.DedupeLayerFiles && unchanged != nil {
+					require.Same(t, unchanged, f)
+				}
+				unchanged = f
+			}
+		}
+
+		out, err := pkg.Render()
+		require.NoError(t, err)
+		require.Equal(t, tc.renderedUnchanged, strings.Count(out, "FileName: etc/unchanged\n"))
+		require.Equal(t, 3, strings.Count(out, "FileName: etc/changed\n"))
+		if tc.opts.DedupeLayerFiles {
+			require.Equal(t, 3, strings.Count(out, " CONTAINS "+unchanged.SPDXID()+"\n"))
+		}
+	}
+}
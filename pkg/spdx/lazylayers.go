@@ -0,0 +1,204 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// extractTarEntry copies the entry called name in the tarball at tarPath
+// to dest, without extracting the rest of the archive
+func extractTarEntry(tarPath, name, dest string) error {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return fmt.Errorf("opening tarball: %w", err)
+	}
+	defer f.Close()
+
+	tr, err := newTarReader(f)
+	if err != nil {
+		return err
+	}
+	name = strings.TrimPrefix(name, "./")
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%s not found in %s", name, tarPath)
+		}
+		if err != nil {
+			return fmt.Errorf("reading tarfile %s: %w", tarPath, err)
+		}
+		if strings.TrimPrefix(hdr.Name, "./") != name {
+			continue
+		}
+
+		out, err := os.Create(dest)
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", dest, err)
+		}
+		defer out.Close()
+		if _, err := io.CopyN(out, tr, hdr.Size); err != nil {
+			return fmt.Errorf("extracting %s: %w", name, err)
+		}
+		return nil
+	}
+}
+
+// packageFromImageTarballLazy builds the package of an image archive taking
+// its layers out of the tarball one at a time. Each layer is deleted once
+// scanned, so at most one layer is on disk instead of the whole image.
+//
+// The files of the archive itself (manifest, config and layer blobs) are
+// not listed in the image package, the layers are described by their own
+// packages as when reading the whole archive.
+func (di *spdxDefaultImplementation) packageFromImageTarballLazy(
+	spdxOpts *Options, tarPath string,
+) (*Package, error) {
+	defer di.tempPaths.cleanupOnPanic(spdxOpts)
+	tmpDir, err := di.tempPaths.mkdirTemp(spdxOpts, "spdx-lazy-layers-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer di.tempPaths.remove(tmpDir)
+
+	manifests, err := di.readLazyArchiveManifests(tarPath, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	imagePackage := NewPackage()
+	if err := di.cpuLimiter.run(spdxOpts, func() error { return imagePackage.ReadSourceFile(tarPath) }); err != nil {
+		return nil, fmt.Errorf("reading source file %s: %w", tarPath, err)
+	}
+	imagePackage.Name = filepath.Base(tarPath)
+	imagePackage.Comment = "Container image archive"
+
+	tarOpts := &TarballOptions{
+		AddFiles:        spdxOpts.AddTarFiles && !spdxOpts.AnalyzeLayers,
+		TempStorageMode: spdxOpts.TempStorageMode,
+	}
+	if err := describeArchiveImages(spdxOpts, imagePackage, manifests, func(
+		manifest *ArchiveManifest, imagePackage *Package, repoTag string,
+	) error {
+		logger(spdxOpts).Infof("Package describes image %s, scanning its %d layers one at a time", repoTag, len(manifest.LayerFiles))
+		config, err := readArchiveConfig(tarPath, tmpDir, manifest)
+		if err != nil {
+			return err
+		}
+		diffIDs := layerVerificationDiffIDs(spdxOpts, config, manifest)
+		layerPackages := []*Package{}
+		for i, layerFile := range manifest.LayerFiles {
+			layerPath := filepath.Join(tmpDir, fmt.Sprintf("layer-%d.tar", i))
+			if err := extractTarEntry(tarPath, layerFile, layerPath); err != nil {
+				return fmt.Errorf("extracting layer %d: %w", i, err)
+			}
+			if diffIDs != nil {
+				if err := di.cpuLimiter.run(spdxOpts, func() error {
+					return verifyLayerFile(layerPath, layerFile, diffIDs[i])
+				}); err != nil {
+					os.Remove(layerPath)
+					return fmt.Errorf("verifying the layers of %s: %w", repoTag, err)
+				}
+			}
+			pkgs, err := di.imageLayerPackages(
+				spdxOpts, tarOpts, repoTag, []string{layerPath}, "Container image layer from archive",
+			)
+			if rmErr := os.Remove(layerPath); rmErr != nil {
+				logger(spdxOpts).Warnf("Removing scanned layer %d: %v", i, rmErr)
+			}
+			if err != nil {
+				return err
+			}
+			layerPackages = append(layerPackages, pkgs...)
+		}
+
+		if err := addLayerPackages(spdxOpts, imagePackage, layerPackages); err != nil {
+			return err
+		}
+		if config != nil {
+			di.recordImageHistory(spdxOpts, config, manifest.Annotations, imagePackage, layerPackages)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return imagePackage, nil
+}
+
+// readArchiveConfig reads the config of an image in the archive at
+// tarPath, extracting only the config to tmpDir. It returns nil if the
+// manifest does not name a config.
+func readArchiveConfig(tarPath, tmpDir string, manifest *ArchiveManifest) (*v1.ConfigFile, error) {
+	if manifest.ConfigFilename == "" {
+		return nil, nil
+	}
+	configPath := filepath.Join(tmpDir, "config.json")
+	if err := extractTarEntry(tarPath, manifest.ConfigFilename, configPath); err != nil {
+		return nil, fmt.Errorf("reading image config: %w", err)
+	}
+	config, err := readImageConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading image config: %w", err)
+	}
+	return config, nil
+}
+
+// layerVerificationDiffIDs returns the diff IDs the layers of an image
+// are checked against, nil if they are not verified
+func layerVerificationDiffIDs(spdxOpts *Options, config *v1.ConfigFile, manifest *ArchiveManifest) []string {
+	if spdxOpts.SkipLayerVerification || config == nil {
+		return nil
+	}
+	return archiveLayerDiffIDs(spdxOpts, config, manifest)
+}
+
+// readLazyArchiveManifests reads the manifest of the image archive at
+// tarPath, extracting only the files needed to tmpDir. The archive may be
+// a docker archive or an OCI layout.
+func (di *spdxDefaultImplementation) readLazyArchiveManifests(tarPath, tmpDir string) ([]ArchiveManifest, error) {
+	manifestPath := filepath.Join(tmpDir, archiveManifestFilename)
+	err := extractTarEntry(tarPath, archiveManifestFilename, manifestPath)
+	if err == nil {
+		manifests, err := di.ReadArchiveManifests(manifestPath)
+		if err != nil {
+			return nil, fmt.Errorf("while reading docker archive manifest: %w", err)
+		}
+		return manifests, nil
+	}
+	if extractTarEntry(tarPath, ociLayoutFilename, filepath.Join(tmpDir, ociLayoutFilename)) != nil {
+		return nil, fmt.Errorf("reading docker archive manifest: %w", err)
+	}
+
+	manifest, err := readOCILayoutManifest(func(name string) ([]byte, error) {
+		dest := filepath.Join(tmpDir, "oci-entry")
+		defer os.Remove(dest)
+		if err := extractTarEntry(tarPath, name, dest); err != nil {
+			return nil, err
+		}
+		return os.ReadFile(dest)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while reading OCI layout manifest: %w", err)
+	}
+	return []ArchiveManifest{*manifest}, nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromImageTarballLazyLayers(t *testing.T) {
+	const numLayers, filesPerLayer, fileSize = 3, 4, 256 * 1024
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+	names := []string{}
+	layers := [][]byte{}
+	for i := 0; i < numLayers; i++ {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for j := 0; j < filesPerLayer; j++ {
+			data := make([]byte, fileSize)
+			rnd.Read(data)
+			require.NoError(t, tw.WriteHeader(&tar.Header{
+				Name: fmt.Sprintf("layer%d/file%d.bin", i, j), Mode: 0o644, Size: fileSize, Typeflag: tar.TypeReg,
+			}))
+			_, err := tw.Write(data)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		names = append(names, fmt.Sprintf("layer%d/layer.tar", i))
+		layers = append(layers, buf.Bytes())
+	}
+	tarPath := writeTestDockerArchive(t, names, layers)
+	layerSize := int64(len(layers[0]))
+
+	// peakTempUsage scans the archive measuring the disk used in the
+	// temporary directory while it runs
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	peakTempUsage := func(opts *Options) (*Package, int64) {
+		var peak int64
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if size := extractionDiskUsage(tmpDir); size > peak {
+					peak = size
+				}
+				select {
+				case <-done:
+					return
+				case <-time.After(time.Millisecond):
+				}
+			}
+		}()
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromImageTarball(opts, tarPath)
+		close(done)
+		wg.Wait()
+		require.NoError(t, err)
+		return pkg, peak
+	}
+
+	eagerPkg, eagerPeak := peakTempUsage(&Options{AddTarFiles: true})
+	lazyPkg, lazyPeak := peakTempUsage(&Options{AddTarFiles: true, LazyLayers: true})
+
+	// Reading the whole archive keeps all the layers on disk, lazily
+	// there is at most a layer and its extracted files
+	require.GreaterOrEqual(t, eagerPeak, numLayers*layerSize)
+	require.LessOrEqual(t, lazyPeak, 2*layerSize+64*1024)
+	require.Zero(t, extractionDiskUsage(tmpDir))
+
+	// Both modes describe the same layers
+	layerFiles := func(pkg *Package) map[string]int {
+		files := map[string]int{}
+		for _, rel := range pkg.Relationships {
+			if layer, ok := rel.Peer.(*Package); ok {
+				files[layer.Name] = len(layer.Files())
+			}
+		}
+		return files
+	}
+	require.Len(t, layerFiles(lazyPkg), numLayers)
+	require.Equal(t, layerFiles(eagerPkg), layerFiles(lazyPkg))
+	require.Equal(t, eagerPkg.Checksum, lazyPkg.Checksum)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// staticLinkAnnotation prefixes the annotation recording if an ELF
+// executable found in an image layer is statically linked
+const staticLinkAnnotation = "Statically linked: "
+
+// StaticallyLinked returns whether the file was found to be a statically
+// linked executable when its image layer was analyzed. The second value is
+// false when the linkage of the file is not known.
+func (f *File) StaticallyLinked() (static, known bool) {
+	for _, a := range f.Annotations {
+		if strings.HasPrefix(a.Comment, staticLinkAnnotation) {
+			static, err := strconv.ParseBool(strings.TrimPrefix(a.Comment, staticLinkAnnotation))
+			return static, err == nil
+		}
+	}
+	return false, false
+}
+
+// maxLinkageBinarySize is the size of the largest executable whose ELF
+// headers are read to find its linkage
+const maxLinkageBinarySize = 1 << 30
+
+// annotateLayerLinkage annotates the files of the layer package which are
+// ELF executables with whether they are statically linked. Static binaries
+// bundle their libraries, which the package databases of the image do not
+// list, so they are logged as worth a deeper analysis.
+func annotateLayerLinkage(opts *Options, layerPath string, pkg *Package) error {
+	linkages, err := readLayerLinkages(opts, layerPath)
+	if err != nil {
+		return err
+	}
+	if len(linkages) == 0 {
+		return nil
+	}
+	for _, f := range pkg.Files() {
+		linkage, ok := linkages[f.Name]
+		if !ok {
+			continue
+		}
+		static := linkage == BinaryLinkageStatic
+		f.AddAnnotation(newToolAnnotation(staticLinkAnnotation + strconv.FormatBool(static)))
+		if static {
+			logger(opts).Infof("Layer file %s is a statically linked binary, its libraries are not listed", f.Name)
+		}
+	}
+	return nil
+}
+
+// readLayerLinkages returns the linkage of the ELF executables in a layer
+// tarball by their path
+func readLayerLinkages(opts *Options, layerPath string) (map[string]string, error) {
+	linkages := map[string]string{}
+	err := readLayerExecutables(opts, layerPath, maxLinkageBinarySize, func(name string, r io.ReaderAt, size int64) error {
+		magic := make([]byte, len(elf.ELFMAG))
+		if _, err := r.ReadAt(magic, 0); err != nil || !bytes.Equal(magic, []byte(elf.ELFMAG)) {
+			return nil
+		}
+		info, err := readELFInfo(r)
+		if err != nil {
+			logger(opts).Debugf("Unable to parse ELF headers of %s: %v", name, err)
+			return nil
+		}
+		if info.Linkage != "" {
+			linkages[name] = info.Linkage
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return linkages, nil
+}
+
+// readLayerExecutables calls fn with the path and the contents of each
+// executable file in the layer tarball at layerPath. The files are copied
+// one at a time to a temporary file read by fn, those larger than maxSize
+// bytes are skipped.
+func readLayerExecutables(
+	opts *Options, layerPath string, maxSize int64, fn func(name string, r io.ReaderAt, size int64) error,
+) error {
+	f, err := os.Open(layerPath)
+	if err != nil {
+		return fmt.Errorf("opening layer: %w", err)
+	}
+	defer f.Close()
+	tr, err := newTarReader(f)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(tempRoot(opts), "spdx-layer-executable-")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading layer %s: %w", layerPath, err)
+		}
+		if !hdr.FileInfo().Mode().IsRegular() || hdr.Mode&0o111 == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "/")
+		if hdr.Size > maxSize {
+			logger(opts).Debugf("Not reading %s, its %d bytes exceed the %d bytes limit", name, hdr.Size, maxSize)
+			continue
+		}
+		if err := tmp.Truncate(0); err != nil {
+			return fmt.Errorf("truncating temporary file: %w", err)
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("rewinding temporary file: %w", err)
+		}
+		size, err := io.Copy(tmp, tr)
+		if err != nil {
+			return fmt.Errorf("reading %s from layer: %w", hdr.Name, err)
+		}
+		if err := fn(name, io.NewSectionReader(tmp, 0, size), size); err != nil {
+			return err
+		}
+	}
+}
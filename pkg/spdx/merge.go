@@ -0,0 +1,241 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// mergedPackageName is the name of the package created by Merge
+const mergedPackageName = "merged-sbom"
+
+// Merge combines the package trees of several SBOMs under a new package
+// that CONTAINS each of them. Packages found more than once (sharing a
+// purl or, without one, the SPDX ID and data) are kept once: the one with
+// more data is kept, adding the relationships of the others, and the
+// relationships pointing to the dropped copies are repointed to it.
+// Elements with the same SPDX ID that are not duplicates get a new ID.
+// The input packages are not modified, the merged package holds copies of
+// their trees.
+func Merge(pkgs ...*Package) (*Package, error) {
+	return MergeWithOptions(nil, pkgs...)
+}
+
+// MergeWithOptions combines the package trees of several SBOMs as Merge
+// does, comparing the packages without a purl with opts to find the
+// duplicates.
+func MergeWithOptions(opts *CompareOptions, pkgs ...*Package) (*Package, error) {
+	if len(pkgs) == 0 {
+		return nil, errors.New("no packages to merge")
+	}
+	for i, p := range pkgs {
+		if p == nil {
+			return nil, fmt.Errorf("package #%d to merge is nil", i)
+		}
+	}
+
+	// Copy the trees with a single cloner, so the elements shared by
+	// several of them stay shared in the copies
+	c := &cloner{clones: map[Object]Object{}}
+	clones := make([]*Package, len(pkgs))
+	for i, p := range pkgs {
+		clones[i] = c.clonePackage(p)
+	}
+	pkgs = clones
+
+	// Collect all the packages in the trees, in the order they are found
+	all := []*Package{}
+	seen := map[*Package]struct{}{}
+	var collect func(*Package)
+	collect = func(p *Package) {
+		if _, ok := seen[p]; ok {
+			return
+		}
+		seen[p] = struct{}{}
+		all = append(all, p)
+		for _, rel := range p.Relationships {
+			if peer, ok := rel.Peer.(*Package); ok && peer != nil {
+				collect(peer)
+			}
+		}
+	}
+	for _, p := range pkgs {
+		collect(p)
+	}
+
+	// Group the duplicates, keeping the package with the most data
+	replacements := map[*Package]*Package{}
+	kept := map[string]*Package{}
+	for _, p := range all {
+		key := mergeKey(p)
+		prev, ok := kept[key]
+		// Packages without a purl are only duplicates if their data match
+		if !ok || (p.Purl() == nil && !p.Equal(prev, opts)) {
+			if !ok {
+				kept[key] = p
+			}
+			continue
+		}
+		if packageRichness(p) > packageRichness(prev) {
+			replacements[prev] = p
+			kept[key] = p
+			continue
+		}
+		replacements[p] = prev
+	}
+	resolve := func(p *Package) *Package {
+		for {
+			r, ok := replacements[p]
+			if !ok {
+				return p
+			}
+			p = r
+		}
+	}
+
+	// Move the relationships of the dropped copies to the kept ones
+	for _, p := range all {
+		if target := resolve(p); target != p {
+			logrus.Debugf("Merging duplicate package %s into %s", p.SPDXID(), target.SPDXID())
+			target.Relationships = append(target.Relationships, p.Relationships...)
+			p.Relationships = nil
+		}
+	}
+
+	merged := NewPackage()
+	merged.Name = mergedPackageName
+	merged.BuildID(merged.Name)
+
+	// Repoint the relationships and make the IDs unique among the elements.
+	// The tree is walked in the order it is rendered, so elements reached
+	// again through another relationship are not rendered twice.
+	ids := map[string]Object{merged.SPDXID(): merged}
+	visited := map[Object]struct{}{}
+	var walk func(Object)
+	walk = func(o Object) {
+		if _, ok := visited[o]; ok {
+			return
+		}
+		visited[o] = struct{}{}
+		ensureMergedID(ids, o)
+		rels := o.GetRelationships()
+		*rels = dedupeMergedRelationships(o, *rels, resolve)
+		for _, rel := range *rels {
+			if rel.Peer == nil || !rel.FullRender {
+				continue
+			}
+			if _, ok := visited[rel.Peer]; ok {
+				rel.FullRender = false
+				continue
+			}
+			walk(rel.Peer)
+		}
+	}
+
+	attached := map[*Package]struct{}{}
+	for _, p := range pkgs {
+		p = resolve(p)
+		if _, ok := attached[p]; ok {
+			continue
+		}
+		attached[p] = struct{}{}
+		_, rendered := visited[p]
+		walk(p)
+		if err := merged.AddPackage(p); err != nil {
+			return nil, fmt.Errorf("adding %s to the merged package: %w", p.SPDXID(), err)
+		}
+		if rendered {
+			merged.Relationships[len(merged.Relationships)-1].FullRender = false
+		}
+	}
+	return merged, nil
+}
+
+// mergeKey returns the key identifying the duplicates of a package
+func mergeKey(p *Package) string {
+	if pu := p.Purl(); pu != nil {
+		return "purl:" + pu.ToString()
+	}
+	return "id:" + p.SPDXID()
+}
+
+// packageRichness counts the data recorded about a package, to keep the
+// most complete copy of a package when merging
+func packageRichness(p *Package) int {
+	n := 0
+	for _, v := range []string{
+		p.Version, p.LicenseConcluded, p.LicenseDeclared, p.DownloadLocation,
+		p.CopyrightText, p.HomePage, p.Supplier.Person, p.Supplier.Organization,
+		p.Originator.Person, p.Originator.Organization, p.PrimaryPurpose,
+	} {
+		if !noAssertion(v) {
+			n++
+		}
+	}
+	return n + len(p.Checksum) + len(p.ExternalRefs) + len(p.Relationships)
+}
+
+// ensureMergedID gives the object a new ID if another element of the
+// merged tree already has its ID
+func ensureMergedID(ids map[string]Object, o Object) {
+	id := o.SPDXID()
+	if id == "" {
+		return
+	}
+	newID := id
+	for i := 1; ; i++ {
+		if prev, ok := ids[newID]; !ok || prev == o {
+			break
+		}
+		newID = fmt.Sprintf("%s-%04d", id, i)
+	}
+	if newID != id {
+		logrus.Infof("Element ID changed from %s to %s to keep it unique in the merged SBOM", id, newID)
+		o.SetSPDXID(newID)
+	}
+	ids[newID] = o
+}
+
+// dedupeMergedRelationships repoints the relationships of an object to
+// the packages kept, dropping the repeated ones and those to itself
+func dedupeMergedRelationships(o Object, rels []*Relationship, resolve func(*Package) *Package) []*Relationship {
+	type relKey struct {
+		Type RelationshipType
+		Peer Object
+		Ref  string
+	}
+	seen := map[relKey]struct{}{}
+	deduped := []*Relationship{}
+	for _, rel := range rels {
+		if peer, ok := rel.Peer.(*Package); ok && peer != nil {
+			rel.Peer = resolve(peer)
+		}
+		if rel.Peer == o {
+			continue
+		}
+		key := relKey{Type: rel.Type, Peer: rel.Peer, Ref: rel.PeerExtReference + ":" + rel.PeerReference}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		deduped = append(deduped, rel)
+	}
+	return deduped
+}
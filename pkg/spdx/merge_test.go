@@ -0,0 +1,137 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	newPkg := func(name, version, purlString string) *Package {
+		p := NewPackage()
+		p.Name = name
+		p.Version = version
+		p.BuildID(name)
+		if purlString != "" {
+			p.ExternalRefs = append(p.ExternalRefs, ExternalRef{
+				Category: CatPackageManager, Type: "purl", Locator: purlString,
+			})
+		}
+		return p
+	}
+	const logrusPurl = "pkg:golang/github.com/sirupsen/logrus@v1.9.0"
+
+	// The backend records logrus without a license
+	backend := newPkg("backend", "v1.0.0", "pkg:golang/example.com/backend@v1.0.0")
+	backendLogrus := newPkg("logrus", "v1.9.0", logrusPurl)
+	require.NoError(t, backend.AddDependency(backendLogrus))
+
+	// The frontend has a package with the same ID as one in the image
+	frontend := newPkg("frontend", "2.0.0", "pkg:npm/frontend@2.0.0")
+	frontendCommon := newPkg("common", "1.0", "")
+	require.NoError(t, frontend.AddPackage(frontendCommon))
+
+	// The image has a richer copy of logrus, and the same common package
+	// as the frontend but with a different version
+	image := newPkg("image", "", "pkg:oci/image@sha256%3Aabcd")
+	imageLogrus := newPkg("logrus", "v1.9.0", logrusPurl)
+	imageLogrus.LicenseDeclared = "MIT"
+	imageLogrus.Checksum = map[string]string{"SHA256": "1234"}
+	binary := newPkg("binary", "", "")
+	require.NoError(t, binary.AddDependency(backendLogrus))
+	require.NoError(t, image.AddPackage(imageLogrus))
+	require.NoError(t, image.AddPackage(binary))
+	imageCommon := newPkg("common", "2.0", "")
+	require.NoError(t, image.AddPackage(imageCommon))
+
+	before := []*Package{backend.Clone(), frontend.Clone(), image.Clone()}
+	merged, err := Merge(backend, frontend, image)
+	require.NoError(t, err)
+	require.Len(t, merged.Relationships, 3)
+	mergedPkgs := make([]*Package, 3)
+	for i, p := range []*Package{backend, frontend, image} {
+		require.Equal(t, CONTAINS, merged.Relationships[i].Type)
+		mergedPkgs[i] = merged.Relationships[i].Peer.(*Package)
+		require.NotSame(t, p, mergedPkgs[i])
+		require.Equal(t, p.Name, mergedPkgs[i].Name)
+	}
+	mergedBackend, mergedFrontend, mergedImage := mergedPkgs[0], mergedPkgs[1], mergedPkgs[2]
+
+	// The relationships to the poorer copy of logrus point to the richer one
+	mergedLogrus := mergedImage.Relationships[0].Peer.(*Package)
+	require.Equal(t, "MIT", mergedLogrus.LicenseDeclared)
+	require.Same(t, mergedLogrus, mergedBackend.Relationships[0].Peer)
+	mergedBinary := mergedImage.Relationships[1].Peer.(*Package)
+	require.Same(t, mergedLogrus, mergedBinary.Relationships[0].Peer)
+
+	// The packages sharing an ID get unique ones
+	require.Equal(t, "SPDXRef-Package-common", mergedFrontend.Relationships[0].Peer.SPDXID())
+	require.Equal(t, "SPDXRef-Package-common-0001", mergedImage.Relationships[2].Peer.SPDXID())
+
+	// The input packages are left unchanged
+	for i, p := range []*Package{backend, frontend, image} {
+		require.True(t, Diff(before[i], p).Empty())
+		require.Equal(t, len(before[i].Relationships), len(p.Relationships))
+	}
+	require.Same(t, backendLogrus, backend.Relationships[0].Peer)
+	require.Same(t, backendLogrus, binary.Relationships[0].Peer)
+	require.Equal(t, "SPDXRef-Package-common", imageCommon.SPDXID())
+	require.Equal(t, "SPDXRef-Package-common", frontendCommon.SPDXID())
+	require.Empty(t, backendLogrus.LicenseDeclared)
+
+	// The merged package renders in a document with each package once
+	doc := NewDocument()
+	require.NoError(t, doc.AddPackage(merged))
+	rendered, err := doc.Render()
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(rendered, "PackageName: logrus\n"))
+	require.Equal(t, 2, strings.Count(rendered, "PackageName: common\n"))
+
+	// Packages without a purl are merged if their data match
+	a := newPkg("data", "1.0", "")
+	b := newPkg("data", "1.0", "")
+	merged, err = Merge(a, b)
+	require.NoError(t, err)
+	require.Len(t, merged.Relationships, 1)
+
+	// Missing data only tell packages apart when the options compare it
+	c := newPkg("data", "1.0", "")
+	c.LicenseDeclared = "MIT"
+	d := newPkg("data", "1.0", "")
+	d.LicenseDeclared = NOASSERTION
+	merged, err = Merge(c, d)
+	require.NoError(t, err)
+	require.Len(t, merged.Relationships, 2)
+	require.Equal(t, "SPDXRef-Package-data-0001", merged.Relationships[1].Peer.SPDXID())
+
+	c = newPkg("data", "1.0", "")
+	c.LicenseDeclared = "MIT"
+	d = newPkg("data", "1.0", "")
+	d.LicenseDeclared = NOASSERTION
+	merged, err = MergeWithOptions(&CompareOptions{IgnoreNoAssertion: true}, c, d)
+	require.NoError(t, err)
+	require.Len(t, merged.Relationships, 1)
+	require.Equal(t, "MIT", merged.Relationships[0].Peer.(*Package).LicenseDeclared)
+
+	_, err = Merge()
+	require.Error(t, err)
+	_, err = Merge(a, nil)
+	require.Error(t, err)
+}
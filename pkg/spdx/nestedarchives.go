@@ -0,0 +1,195 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/nozzle/throttler"
+)
+
+const (
+	// maxNestedArchiveDepth is the deepest level of archives inside
+	// archives that gets scanned
+	maxNestedArchiveDepth = 5
+
+	// defaultNestedArchiveWorkers is the number of nested archives
+	// extracted and scanned at once when the options do not set it
+	defaultNestedArchiveWorkers = 2
+)
+
+// nestedArchiveScanner scans the archives found inside other archives.
+// The number of them being extracted and scanned at the same time is
+// capped across all the nesting levels. The files in them are still
+// hashed and classified under the CPU limit of the scan.
+type nestedArchiveScanner struct {
+	limiter cpuLimiter
+
+	// scanning is called with the path of each nested archive when it
+	// takes a slot, the returned function is called before releasing it.
+	// It is set in the unit tests.
+	scanning func(path string) (done func())
+}
+
+// nestedArchiveWorkers returns the number of nested archives scanned at once
+func nestedArchiveWorkers(opts *Options) int {
+	if opts != nil && opts.NestedArchiveWorkers > 0 {
+		return opts.NestedArchiveWorkers
+	}
+	return defaultNestedArchiveWorkers
+}
+
+// isNestedArchive returns true if the file at path is an archive read
+// when scanning nested archives
+func isNestedArchive(path string) bool {
+	lower := strings.ToLower(path)
+	if isTarArchive(lower) {
+		return true
+	}
+	for _, ext := range zipArchiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTarArchive returns true if the lowercased path names a tarball
+func isTarArchive(lower string) bool {
+	return strings.HasSuffix(lower, ".tar") || strings.HasSuffix(lower, ".tar.gz") ||
+		strings.HasSuffix(lower, ".tgz")
+}
+
+// addNestedArchives adds to pkg a package for each archive found in dir,
+// where the archive of pkg was extracted. depth is the nesting level of
+// the archives in dir. The packages are added in the order of the paths
+// of the archives once all of them are scanned.
+func (di *spdxDefaultImplementation) addNestedArchives(
+	opts *Options, pkg *Package, dir string, depth int,
+) error {
+	if !opts.ScanNestedArchives {
+		return nil
+	}
+	if depth > maxNestedArchiveDepth {
+		di.warn(opts, pkg.Name, "Not scanning archives nested more than %d levels deep", maxNestedArchiveDepth)
+		return nil
+	}
+
+	archives := []string{}
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && isNestedArchive(path) {
+			archives = append(archives, path)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("looking for nested archives: %w", err)
+	}
+	if len(archives) == 0 {
+		return nil
+	}
+
+	nested := make([]*Package, len(archives))
+	t := throttler.New(len(archives), len(archives))
+	for i, path := range archives {
+		i, path := i, path
+		di.workerPool.spawnPhase(func() {
+			defer di.tempPaths.cleanupOnPanic(opts)
+			rel, err := filepath.Rel(dir, path)
+			if err == nil {
+				nested[i], err = di.nestedArchivePackage(opts, path, filepath.ToSlash(rel), depth)
+			}
+			t.Done(err)
+		})
+		t.Throttle()
+	}
+	if err := t.Err(); err != nil {
+		return err
+	}
+
+	for _, sub := range nested {
+		if sub == nil {
+			continue
+		}
+		if err := pkg.AddPackage(sub); err != nil {
+			return fmt.Errorf("adding nested archive package: %w", err)
+		}
+	}
+	return nil
+}
+
+// nestedArchivePackage builds the package of an archive found at path
+// rel inside another archive. The archive holds a slot while it is
+// extracted and scanned, the archives nested in it are scanned after the
+// slot is released so the levels never wait on each other.
+func (di *spdxDefaultImplementation) nestedArchivePackage(
+	opts *Options, path, rel string, depth int,
+) (*Package, error) {
+	release := di.nestedArchives.limiter.acquireSlots(nestedArchiveWorkers(opts))
+	if di.nestedArchives.scanning != nil {
+		done, releaseSlot := di.nestedArchives.scanning(path), release
+		release = func() {
+			done()
+			releaseSlot()
+		}
+	}
+	logger(opts).Infof("Scanning nested archive %s", rel)
+
+	var tmp string
+	var err error
+	if isTarArchive(strings.ToLower(path)) {
+		tmp, err = di.ExtractTarballTmp(opts, path)
+	} else {
+		tmp, err = di.extractZipTmp(opts, path)
+	}
+	if tmp != "" {
+		defer di.tempPaths.remove(tmp)
+	}
+	if err != nil {
+		release()
+		di.warn(opts, rel, "Could not extract nested archive %s: %v", rel, err)
+		return nil, nil
+	}
+
+	pkg, err := di.PackageFromDirectory(opts, tmp)
+	if err != nil {
+		release()
+		di.warn(opts, rel, "Could not scan nested archive %s: %v", rel, err)
+		return nil, nil
+	}
+	pkg.Name = filepath.Base(path)
+	pkg.Options().WorkDir = filepath.Dir(path)
+	pkg.Comment = "Archive found inside another archive"
+	err = di.cpuLimiter.run(opts, func() error { return pkg.ReadSourceFile(path) })
+	release()
+	if err != nil {
+		return nil, fmt.Errorf("reading source file %s: %w", rel, err)
+	}
+	// The same archive may be nested in several places, seed the ID
+	// with its path and its contents
+	pkg.BuildID(rel, pkg.Checksum["SHA256"])
+
+	if err := di.addNestedArchives(opts, pkg, tmp, depth+1); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
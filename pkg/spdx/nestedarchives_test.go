@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromZipNestedArchives(t *testing.T) {
+	readZip := func(entries map[string]string) string {
+		data, err := os.ReadFile(writeTestZip(t, entries))
+		require.NoError(t, err)
+		return string(data)
+	}
+	var tarData bytes.Buffer
+	tw := tar.NewWriter(&tarData)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "README", Mode: 0o644, Size: 5}))
+	_, err := tw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	entries := map[string]string{
+		"app.txt":      "app",
+		"lib/docs.tar": tarData.String(),
+		"lib/outer.jar": readZip(map[string]string{
+			"inner.zip": readZip(map[string]string{"deep.txt": "deep"}),
+		}),
+	}
+	for i := 0; i < 6; i++ {
+		entries[fmt.Sprintf("lib/dep%d.jar", i)] = readZip(map[string]string{
+			fmt.Sprintf("dep%d.class", i): fmt.Sprintf("class %d", i),
+		})
+	}
+	zipPath := writeTestZip(t, entries)
+
+	// Without the option the archives are read as plain files
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromZip(&Options{}, zipPath)
+	require.NoError(t, err)
+	require.Len(t, pkg.Files(), 9)
+	require.Empty(t, containedPackages(pkg))
+
+	for _, workers := range []int{1, 3} {
+		var mtx sync.Mutex
+		inFlight, maxInFlight := 0, 0
+		scanned := []string{}
+		impl := spdxDefaultImplementation{}
+		impl.nestedArchives.scanning = func(path string) func() {
+			mtx.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			scanned = append(scanned, filepath.Base(path))
+			mtx.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			return func() {
+				mtx.Lock()
+				inFlight--
+				mtx.Unlock()
+			}
+		}
+
+		pkg, err := impl.PackageFromZip(&Options{
+			ScanNestedArchives: true, NestedArchiveWorkers: workers,
+		}, zipPath)
+		require.NoError(t, err)
+		require.LessOrEqual(t, maxInFlight, workers)
+		require.Len(t, scanned, 9)
+		require.Zero(t, inFlight)
+
+		// The packages are added in the order of the archive paths
+		subs := containedPackages(pkg)
+		require.Len(t, subs, 8)
+		for i := 0; i < 6; i++ {
+			require.Equal(t, fmt.Sprintf("dep%d.jar", i), subs[i].Name)
+			require.Len(t, subs[i].Files(), 1)
+			require.NotEmpty(t, subs[i].Checksum["SHA256"])
+		}
+		require.Equal(t, "docs.tar", subs[6].Name)
+		require.Len(t, subs[6].Files(), 1)
+		require.Equal(t, "outer.jar", subs[7].Name)
+		inner := containedPackages(subs[7])
+		require.Len(t, inner, 1)
+		require.Equal(t, "inner.zip", inner[0].Name)
+		require.Len(t, inner[0].Files(), 1)
+
+		ids := map[string]struct{}{pkg.SPDXID(): {}}
+		for _, sub := range append(subs, inner...) {
+			ids[sub.SPDXID()] = struct{}{}
+		}
+		require.Len(t, ids, 10)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/bom/pkg/license"
+)
+
+const (
+	noticeSeparator    = "================================================================================"
+	noticeSubSeparator = "--------------------------------------------------------------------------------"
+)
+
+// packageNotice is the attribution data collected from a package
+type packageNotice struct {
+	name       string
+	licenses   []string
+	copyrights []string
+}
+
+// GenerateNoticeFile writes an attribution document (a NOTICE file) listing
+// the licenses and copyright notices of the packages in the document,
+// followed by the full text of every license. The texts are read from the
+// license list embedded in bom.
+func (d *Document) GenerateNoticeFile(w io.Writer) error {
+	opts := *license.DefaultReaderOptions
+	opts.EmbeddedLicenses = true
+	reader, err := license.NewReaderWithOptions(&opts)
+	if err != nil {
+		return fmt.Errorf("creating license reader: %w", err)
+	}
+	return d.GenerateNoticeFileWithReader(w, reader)
+}
+
+// GenerateNoticeFileWithReader writes the attribution document of the
+// SBOM reading the license texts from reader
+func (d *Document) GenerateNoticeFileWithReader(w io.Writer, reader *license.Reader) error {
+	notices := d.packageNotices()
+	licenseIDs := map[string]struct{}{}
+	for _, n := range notices {
+		for _, l := range n.licenses {
+			// Exceptions are not in the license list, print the license text
+			id, _, _ := strings.Cut(l, " WITH ")
+			licenseIDs[id] = struct{}{}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "NOTICES AND INFORMATION")
+	fmt.Fprintln(bw)
+	fmt.Fprintf(bw, "This file lists the licenses and copyright notices of the %d packages\n", len(notices))
+	fmt.Fprintf(bw, "described in %s.\n", d.Name)
+
+	for _, n := range notices {
+		fmt.Fprintln(bw)
+		fmt.Fprintln(bw, noticeSeparator)
+		fmt.Fprintln(bw, n.name)
+		fmt.Fprintln(bw, noticeSeparator)
+		if len(n.licenses) > 0 {
+			fmt.Fprintf(bw, "License: %s\n", strings.Join(n.licenses, ", "))
+		}
+		for _, c := range n.copyrights {
+			fmt.Fprintln(bw, c)
+		}
+	}
+
+	ids := make([]string, 0, len(licenseIDs))
+	for id := range licenseIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if len(ids) > 0 {
+		fmt.Fprintln(bw)
+		fmt.Fprintln(bw, noticeSeparator)
+		fmt.Fprintln(bw, "LICENSE TEXTS")
+		fmt.Fprintln(bw, noticeSeparator)
+	}
+	for _, id := range ids {
+		fmt.Fprintln(bw)
+		fmt.Fprintln(bw, noticeSubSeparator)
+		lic := reader.LicenseFromLabel(id)
+		if lic == nil || lic.LicenseText == "" {
+			fmt.Fprintln(bw, id)
+			fmt.Fprintln(bw, noticeSubSeparator)
+			fmt.Fprintln(bw, "The text of this license is not available.")
+			continue
+		}
+		fmt.Fprintf(bw, "%s (%s)\n", lic.Name, id)
+		fmt.Fprintln(bw, noticeSubSeparator)
+		fmt.Fprintln(bw, strings.TrimRight(lic.LicenseText, "\n"))
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("writing notice file: %w", err)
+	}
+	return nil
+}
+
+// packageNotices collects the licenses and copyright notices of every
+// package in the document and the files they contain. Packages without
+// either are left out. The list is sorted by package name.
+func (d *Document) packageNotices() []packageNotice {
+	notices := []packageNotice{}
+	seen := map[*Package]struct{}{}
+	var collect func(*Package)
+	collect = func(p *Package) {
+		if _, ok := seen[p]; ok {
+			return
+		}
+		seen[p] = struct{}{}
+
+		licenses := map[string]struct{}{}
+		copyrights := map[string]struct{}{}
+		addElement := func(o Object, copyright string) {
+			for _, l := range licenseIDs(elementLicense(o)) {
+				licenses[l] = struct{}{}
+			}
+			if !noAssertion(copyright) && copyright != NONE {
+				copyrights[strings.TrimSpace(copyright)] = struct{}{}
+			}
+		}
+		addElement(p, p.CopyrightText)
+		for _, rel := range p.Relationships {
+			switch peer := rel.Peer.(type) {
+			case *File:
+				addElement(peer, peer.CopyrightText)
+			case *Package:
+				collect(peer)
+			}
+		}
+		if len(licenses) == 0 && len(copyrights) == 0 {
+			return
+		}
+
+		name := p.Name
+		if p.Version != "" {
+			name += " " + p.Version
+		}
+		notices = append(notices, packageNotice{
+			name:       name,
+			licenses:   sortedKeys(licenses),
+			copyrights: sortedKeys(copyrights),
+		})
+	}
+	for _, id := range d.sortedPackageIDs() {
+		collect(d.Packages[id])
+	}
+	sort.SliceStable(notices, func(i, j int) bool {
+		return notices[i].name < notices[j].name
+	})
+	return notices
+}
+
+// sortedKeys returns the keys of a set of strings, sorted
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateNoticeFile(t *testing.T) {
+	doc := NewDocument()
+	doc.Name = "notice-test"
+
+	app := NewPackage()
+	app.Name = "app"
+	app.Version = "v1.0.0"
+	app.BuildID(app.Name)
+	app.LicenseConcluded = "MIT"
+	app.CopyrightText = "Copyright 2023 The App Authors"
+
+	f := NewFile()
+	f.Name = "vendored.c"
+	f.BuildID(f.Name)
+	f.LicenseInfoInFile = "ISC"
+	f.CopyrightText = "Copyright (c) 2020 Vendored Library Author"
+	require.NoError(t, app.AddFile(f))
+
+	dep := NewPackage()
+	dep.Name = "dep"
+	dep.BuildID(dep.Name)
+	dep.LicenseDeclared = "LicenseRef-custom OR MIT"
+	require.NoError(t, app.AddDependency(dep))
+
+	// Packages without licenses or copyrights are not listed
+	empty := NewPackage()
+	empty.Name = "empty"
+	empty.BuildID(empty.Name)
+	require.NoError(t, app.AddPackage(empty))
+	require.NoError(t, doc.AddPackage(app))
+
+	var out bytes.Buffer
+	require.NoError(t, doc.GenerateNoticeFile(&out))
+
+	expected, err := os.ReadFile(filepath.Join("testdata", "notice.txt"))
+	require.NoError(t, err)
+	require.Equal(t, string(expected), out.String())
+}
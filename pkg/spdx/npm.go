@@ -0,0 +1,495 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	purl "github.com/package-url/packageurl-go"
+	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/release-utils/util"
+
+	"sigs.k8s.io/bom/pkg/license"
+)
+
+const (
+	NPMLockFileName       = "package-lock.json"
+	NPMShrinkwrapFileName = "npm-shrinkwrap.json"
+	PNPMLockFileName      = "pnpm-lock.yaml"
+
+	// npmManifestFileName is the manifest of node projects and packages
+	npmManifestFileName = "package.json"
+
+	// npmModulesDir is the directory where the dependencies are installed
+	npmModulesDir = "node_modules"
+)
+
+// npmLockFiles are the lockfiles looked up in node projects, in order of
+// preference. npm publishes the shrinkwrap file instead of the lock.
+var npmLockFiles = []string{NPMShrinkwrapFileName, NPMLockFileName, PNPMLockFileName}
+
+// NPMPackage is a dependency resolved in the lockfile of a node project
+type NPMPackage struct {
+	Name      string // Name of the package, including its scope (eg @babel/core)
+	Version   string // Version resolved in the lockfile
+	Resolved  string // URL of the package tarball
+	Integrity string // Subresource integrity of the tarball (eg sha512-...)
+	License   string // License field of the package, when read
+	Direct    bool   // The project depends on the package, not only other packages
+
+	// installPath is where the package is installed relative to the
+	// project, used to read its manifest
+	installPath string
+}
+
+// PackageURL returns a purl representing the npm package
+func (pkg *NPMPackage) PackageURL() string {
+	if pkg.Name == "" || pkg.Version == "" {
+		return ""
+	}
+	namespace, name := "", pkg.Name
+	if i := strings.Index(pkg.Name, "/"); i != -1 && strings.HasPrefix(pkg.Name, "@") {
+		namespace, name = pkg.Name[:i], pkg.Name[i+1:]
+	}
+	return purl.NewPackageURL(purl.TypeNPM, namespace, name, pkg.Version, nil, "").ToString()
+}
+
+// ToSPDXPackage builds a SPDX package from the npm dependency
+func (pkg *NPMPackage) ToSPDXPackage() (*Package, error) {
+	if pkg.Name == "" || pkg.Version == "" {
+		return nil, fmt.Errorf("npm package %q has no name or version", pkg.Name+"@"+pkg.Version)
+	}
+	spdxPackage := NewPackage()
+	spdxPackage.Options().Prefix = "npm"
+	spdxPackage.Name = pkg.Name
+	spdxPackage.Version = pkg.Version
+	spdxPackage.BuildID(pkg.Name, pkg.Version)
+	spdxPackage.DownloadLocation = NOASSERTION
+	if isURL(pkg.Resolved) {
+		spdxPackage.DownloadLocation = pkg.Resolved
+	}
+	if algo, sum := npmIntegrityChecksum(pkg.Integrity); algo != "" {
+		spdxPackage.Checksum = map[string]string{algo: sum}
+	}
+	if pkg.License != "" {
+		spdxPackage.LicenseDeclared = pkg.License
+	}
+	if packageurl := pkg.PackageURL(); packageurl != "" {
+		spdxPackage.ExternalRefs = append(spdxPackage.ExternalRefs, ExternalRef{
+			Category: CatPackageManager,
+			Type:     "purl",
+			Locator:  packageurl,
+		})
+	}
+	return spdxPackage, nil
+}
+
+// npmIntegrityChecksum converts the first hash of a subresource integrity
+// string to a SPDX checksum algorithm and its hex value
+func npmIntegrityChecksum(integrity string) (algo, sum string) {
+	for _, hash := range strings.Fields(integrity) {
+		name, value, ok := strings.Cut(hash, "-")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "sha512", "sha384", "sha256", "sha1":
+		default:
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		return strings.ToUpper(name), hex.EncodeToString(data)
+	}
+	return "", ""
+}
+
+// npmLockFile returns the path of the lockfile of the node project in
+// dirPath, or an empty string if it has none
+func npmLockFile(dirPath string) string {
+	for _, name := range npmLockFiles {
+		if util.Exists(filepath.Join(dirPath, name)) {
+			return filepath.Join(dirPath, name)
+		}
+	}
+	return ""
+}
+
+// readNPMLockFile returns the packages resolved in an npm or pnpm lockfile
+// sorted by name and version. Packages installed several times with the
+// same version are listed once.
+func readNPMLockFile(path string) ([]*NPMPackage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading lockfile: %w", err)
+	}
+	var packages []*NPMPackage
+	if filepath.Base(path) == PNPMLockFileName {
+		packages, err = parsePNPMLockFile(data)
+	} else {
+		packages, err = parseNPMLockFile(data, filepath.Dir(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+
+	unique := map[string]*NPMPackage{}
+	for _, p := range packages {
+		key := p.Name + "@" + p.Version
+		if prev, ok := unique[key]; ok {
+			prev.Direct = prev.Direct || p.Direct
+			continue
+		}
+		unique[key] = p
+	}
+	ret := []*NPMPackage{}
+	for _, p := range unique {
+		ret = append(ret, p)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Name != ret[j].Name {
+			return ret[i].Name < ret[j].Name
+		}
+		return ret[i].Version < ret[j].Version
+	})
+	return ret, nil
+}
+
+// npmLockFileData is the data read from package-lock.json files. Lockfile
+// version 1 lists the dependencies as a tree, versions 2 and 3 list them
+// in packages by their install path.
+type npmLockFileData struct {
+	LockfileVersion int                          `json:"lockfileVersion"`
+	Packages        map[string]npmLockPackage    `json:"packages"`
+	Dependencies    map[string]npmLockDependency `json:"dependencies"`
+}
+
+type npmLockPackage struct {
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	Resolved             string            `json:"resolved"`
+	Integrity            string            `json:"integrity"`
+	License              interface{}       `json:"license"`
+	Link                 bool              `json:"link"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+}
+
+type npmLockDependency struct {
+	Version      string                       `json:"version"`
+	Resolved     string                       `json:"resolved"`
+	Integrity    string                       `json:"integrity"`
+	Dependencies map[string]npmLockDependency `json:"dependencies"`
+}
+
+// parseNPMLockFile reads the packages of a package-lock.json file. The
+// direct dependencies are those of the root package, lockfiles version 1
+// do not list them and they are read from the package.json in projectDir.
+func parseNPMLockFile(data []byte, projectDir string) ([]*NPMPackage, error) {
+	lock := npmLockFileData{}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("decoding json: %w", err)
+	}
+
+	packages := []*NPMPackage{}
+	if len(lock.Packages) > 0 {
+		root := lock.Packages[""]
+		direct := npmDependencyNames(&root)
+		for key, p := range lock.Packages {
+			// Skip the root package, workspaces and their links
+			i := strings.LastIndex(key, npmModulesDir+"/")
+			if i == -1 || p.Link {
+				continue
+			}
+			name := key[i+len(npmModulesDir)+1:]
+			if p.Name != "" {
+				name = p.Name
+			}
+			_, isDirect := direct[name]
+			packages = append(packages, &NPMPackage{
+				Name:        name,
+				Version:     p.Version,
+				Resolved:    p.Resolved,
+				Integrity:   p.Integrity,
+				License:     npmLicense(p.License),
+				Direct:      isDirect && i == 0,
+				installPath: key,
+			})
+		}
+		return packages, nil
+	}
+
+	// Lockfiles version 1 hoist the dependencies to the top of the tree
+	// so the manifest is needed to tell the direct ones
+	var direct map[string]struct{}
+	if manifest, err := readNPMManifest(filepath.Join(projectDir, npmManifestFileName)); err == nil {
+		direct = npmDependencyNames(manifest)
+	}
+	var walk func(deps map[string]npmLockDependency, parent string)
+	walk = func(deps map[string]npmLockDependency, parent string) {
+		for name, d := range deps {
+			installPath := filepath.ToSlash(filepath.Join(parent, npmModulesDir, name))
+			isDirect := parent == ""
+			if direct != nil {
+				_, isDirect = direct[name]
+				isDirect = isDirect && parent == ""
+			}
+			packages = append(packages, &NPMPackage{
+				Name:        name,
+				Version:     d.Version,
+				Resolved:    d.Resolved,
+				Integrity:   d.Integrity,
+				Direct:      isDirect,
+				installPath: installPath,
+			})
+			walk(d.Dependencies, installPath)
+		}
+	}
+	walk(lock.Dependencies, "")
+	return packages, nil
+}
+
+// npmDependencyNames returns the names of all the dependencies of a package
+func npmDependencyNames(p *npmLockPackage) map[string]struct{} {
+	names := map[string]struct{}{}
+	for _, deps := range []map[string]string{
+		p.Dependencies, p.DevDependencies, p.OptionalDependencies, p.PeerDependencies,
+	} {
+		for name := range deps {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// readNPMManifest reads a package.json file
+func readNPMManifest(path string) (*npmLockPackage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading package manifest: %w", err)
+	}
+	manifest := &npmLockPackage{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("decoding package manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// npmLicense returns the license of a package manifest. It is usually an
+// SPDX expression but old packages use an object with the license type.
+func npmLicense(l interface{}) string {
+	switch v := l.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if t, ok := v["type"].(string); ok {
+			return t
+		}
+	}
+	return ""
+}
+
+// pnpmLockFileData is the data read from pnpm-lock.yaml files. Single
+// project lockfiles before version 9 list the dependencies at the top
+// level, workspaces and newer versions list them by importer.
+type pnpmLockFileData struct {
+	Importers    map[string]pnpmImporter `yaml:"importers"`
+	pnpmImporter `yaml:",inline"`
+	Packages     map[string]pnpmPackage `yaml:"packages"`
+}
+
+type pnpmImporter struct {
+	Dependencies         map[string]interface{} `yaml:"dependencies"`
+	DevDependencies      map[string]interface{} `yaml:"devDependencies"`
+	OptionalDependencies map[string]interface{} `yaml:"optionalDependencies"`
+}
+
+type pnpmPackage struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	Resolution struct {
+		Integrity string `yaml:"integrity"`
+		Tarball   string `yaml:"tarball"`
+	} `yaml:"resolution"`
+}
+
+// parsePNPMLockFile reads the packages of a pnpm-lock.yaml file. The
+// direct dependencies are those of the importers (the projects of the
+// workspace).
+func parsePNPMLockFile(data []byte) ([]*NPMPackage, error) {
+	lock := pnpmLockFileData{}
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("decoding yaml: %w", err)
+	}
+
+	importers := []pnpmImporter{lock.pnpmImporter}
+	for _, imp := range lock.Importers {
+		importers = append(importers, imp)
+	}
+	direct := map[string]struct{}{}
+	for _, imp := range importers {
+		for _, deps := range []map[string]interface{}{
+			imp.Dependencies, imp.DevDependencies, imp.OptionalDependencies,
+		} {
+			for name, spec := range deps {
+				direct[name+"@"+pnpmVersion(pnpmSpecVersion(spec))] = struct{}{}
+			}
+		}
+	}
+
+	packages := []*NPMPackage{}
+	for key, p := range lock.Packages {
+		name, version := parsePNPMPackageKey(key)
+		if p.Name != "" {
+			name = p.Name
+		}
+		if p.Version != "" {
+			version = p.Version
+		}
+		if name == "" || version == "" {
+			continue
+		}
+		_, isDirect := direct[name+"@"+version]
+		packages = append(packages, &NPMPackage{
+			Name:      name,
+			Version:   version,
+			Resolved:  p.Resolution.Tarball,
+			Integrity: p.Resolution.Integrity,
+			Direct:    isDirect,
+			installPath: filepath.ToSlash(filepath.Join(
+				npmModulesDir, ".pnpm", strings.ReplaceAll(name, "/", "+")+"@"+version, npmModulesDir, name,
+			)),
+		})
+	}
+	return packages, nil
+}
+
+// pnpmSpecVersion returns the version of an importer dependency, written
+// as the version string before lockfile version 6 and in a map after it
+func pnpmSpecVersion(spec interface{}) string {
+	switch v := spec.(type) {
+	case string:
+		return v
+	case map[interface{}]interface{}:
+		if version, ok := v["version"].(string); ok {
+			return version
+		}
+	}
+	return ""
+}
+
+// pnpmVersion strips the peer dependencies suffix from a pnpm version,
+// written in parentheses since lockfile version 6 and after an underscore
+// before it
+func pnpmVersion(version string) string {
+	if i := strings.Index(version, "("); i != -1 {
+		version = version[:i]
+	}
+	if i := strings.Index(version, "_"); i != -1 {
+		version = version[:i]
+	}
+	return version
+}
+
+// parsePNPMPackageKey returns the name and version of a package from its
+// key in the lockfile: /name/version before lockfile version 6,
+// /name@version after it and name@version since version 9
+func parsePNPMPackageKey(key string) (name, version string) {
+	key = strings.TrimPrefix(key, "/")
+	if i := strings.Index(key, "("); i != -1 {
+		key = key[:i]
+	}
+	scope, rest := "", key
+	if strings.HasPrefix(key, "@") {
+		i := strings.Index(key, "/")
+		if i == -1 {
+			return "", ""
+		}
+		scope, rest = key[:i+1], key[i+1:]
+	}
+	if i := strings.Index(rest, "/"); i > 0 {
+		return scope + rest[:i], pnpmVersion(rest[i+1:])
+	}
+	if i := strings.Index(rest, "@"); i > 0 {
+		return scope + rest[:i], rest[i+1:]
+	}
+	return "", ""
+}
+
+// GetNPMDependencies reads the lockfile of the node project in path and
+// returns its dependencies as SPDX packages
+func (di *spdxDefaultImplementation) GetNPMDependencies(path string, opts *Options) ([]*Package, error) {
+	lockPath := npmLockFile(path)
+	if lockPath == "" {
+		return nil, fmt.Errorf("no npm or pnpm lockfile found in %s", path)
+	}
+	npmPackages, err := readNPMLockFile(lockPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading npm lockfile: %w", err)
+	}
+
+	spdxPackages := []*Package{}
+	for _, p := range npmPackages {
+		if opts.OnlyDirectDeps && !p.Direct {
+			continue
+		}
+		if opts.ScanLicenses {
+			di.npmPackageLicense(opts, path, p)
+		} else {
+			p.License = ""
+		}
+		spdxPkg, err := p.ToSPDXPackage()
+		if err != nil {
+			// If a dependency cannot be converted, warn but do not die
+			di.warn(opts, p.Name, "converting npm dependency to spdx package: %v", err)
+			continue
+		}
+		spdxPackages = append(spdxPackages, spdxPkg)
+	}
+	return spdxPackages, nil
+}
+
+// npmPackageLicense sets the license of an npm package. Lockfiles version
+// 2 and 3 record it, otherwise it is read from the manifest of the package
+// when it is installed in the project. Licenses which are not a valid
+// license expression are dropped with a warning.
+func (di *spdxDefaultImplementation) npmPackageLicense(opts *Options, projectDir string, p *NPMPackage) {
+	if p.License == "" && p.installPath != "" {
+		manifest, err := readNPMManifest(filepath.Join(projectDir, filepath.FromSlash(p.installPath), npmManifestFileName))
+		if err == nil {
+			p.License = npmLicense(manifest.License)
+		}
+	}
+	if p.License == "" {
+		return
+	}
+	if _, err := license.ParseLicenseExpression(p.License); err != nil {
+		di.warn(opts, p.Name, "ignoring license of npm package %s: %v", p.Name, err)
+		p.License = ""
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetNPMDependencies(t *testing.T) {
+	const lockV3 = `{
+  "name": "app", "version": "1.0.0", "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app", "version": "1.0.0", "dependencies": {"@babel/core": "^7.22.0"}, "devDependencies": {"ms": "^2.1.3"}},
+    "node_modules/@babel/core": {"version": "7.22.5", "resolved": "https://registry.npmjs.org/@babel/core/-/core-7.22.5.tgz",
+      "integrity": "sha512-SBuTAjg91A3eKOvD+bPEz3LlhHZRNu1nFOVts9lzDJTXshHTjII0BAtDS3Y2DAkdZdDKWVZGVwkDfc4Clxn1dg==", "license": "MIT",
+      "dependencies": {"debug": "^4.1.0"}},
+    "node_modules/debug": {"version": "4.3.4", "license": "MIT", "dependencies": {"ms": "2.1.2"}},
+    "node_modules/debug/node_modules/ms": {"version": "2.1.2", "license": "MIT"},
+    "node_modules/ms": {"version": "2.1.3", "dev": true, "license": "SEE LICENSE IN LICENSE.md"},
+    "packages/lib": {"name": "lib", "version": "0.1.0"},
+    "node_modules/lib": {"resolved": "packages/lib", "link": true}
+  }
+}`
+	const lockV1 = `{
+  "name": "app", "version": "1.0.0", "lockfileVersion": 1, "requires": true,
+  "dependencies": {
+    "debug": {"version": "4.3.4", "resolved": "https://registry.npmjs.org/debug/-/debug-4.3.4.tgz", "requires": {"ms": "2.1.2"},
+      "dependencies": {"ms": {"version": "2.1.2"}}},
+    "ms": {"version": "2.1.3"}
+  }
+}`
+	const pnpmV6 = `lockfileVersion: '6.0'
+dependencies:
+  '@babel/core':
+    specifier: ^7.22.0
+    version: 7.22.5
+devDependencies:
+  react-dom:
+    specifier: ^18.2.0
+    version: 18.2.0(react@18.2.0)
+packages:
+  /@babel/core@7.22.5:
+    resolution: {integrity: sha512-SBuTAjg91A3eKOvD+bPEz3LlhHZRNu1nFOVts9lzDJTXshHTjII0BAtDS3Y2DAkdZdDKWVZGVwkDfc4Clxn1dg==}
+  /react-dom@18.2.0(react@18.2.0):
+    resolution: {integrity: sha512-6IMTriUmvsjHUjNtEDudZfuDQUoWXVxKHhlEGSk81n4YFS+r/Kl99wXiwlVXtPBtJenozv2P+hxDsw9eA7Xo6g==}
+  /react@18.2.0:
+    resolution: {integrity: sha512-/3IjMdb2L9QbBdWiW5e3P2/npwMBaU9mHCSCUzNln0ZCYbcfTsGbTJrU/kGemdH2IWmB2ioZ+zkxtmq6g09fGQ==}
+`
+	for _, tc := range []struct {
+		name           string
+		files          map[string]string
+		onlyDirectDeps bool
+		scanLicenses   bool
+		expected       []string // Packages as name@version
+		licenses       map[string]string
+		shouldErr      bool
+	}{
+		{
+			name:     "lockfile v3",
+			files:    map[string]string{NPMLockFileName: lockV3},
+			expected: []string{"@babel/core@7.22.5", "debug@4.3.4", "ms@2.1.2", "ms@2.1.3"},
+		},
+		{
+			name:           "lockfile v3 direct deps",
+			files:          map[string]string{NPMLockFileName: lockV3},
+			onlyDirectDeps: true,
+			scanLicenses:   true,
+			expected:       []string{"@babel/core@7.22.5", "ms@2.1.3"},
+			// Licenses which are not an expression are dropped
+			licenses: map[string]string{"@babel/core": "MIT", "ms": ""},
+		},
+		{
+			name: "lockfile v1 with manifest",
+			files: map[string]string{
+				NPMLockFileName:                        lockV1,
+				npmManifestFileName:                    `{"name": "app", "dependencies": {"debug": "^4.3.0"}}`,
+				"node_modules/debug/package.json":      `{"name": "debug", "license": "MIT"}`,
+				"node_modules/debug/node_modules/x.js": "",
+			},
+			onlyDirectDeps: true,
+			scanLicenses:   true,
+			expected:       []string{"debug@4.3.4"},
+			licenses:       map[string]string{"debug": "MIT"},
+		},
+		{
+			name:           "pnpm lockfile",
+			files:          map[string]string{PNPMLockFileName: pnpmV6},
+			onlyDirectDeps: true,
+			expected:       []string{"@babel/core@7.22.5", "react-dom@18.2.0"},
+		},
+		{
+			name:      "no lockfile",
+			files:     map[string]string{npmManifestFileName: `{"name": "app"}`},
+			shouldErr: true,
+		},
+		{
+			name:      "invalid lockfile",
+			files:     map[string]string{NPMLockFileName: "{"},
+			shouldErr: true,
+		},
+	} {
+		dir := t.TempDir()
+		for name, data := range tc.files {
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), os.FileMode(0o755)))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), os.FileMode(0o644)))
+		}
+		di := spdxDefaultImplementation{}
+		opts := &Options{OnlyDirectDeps: tc.onlyDirectDeps, ScanLicenses: tc.scanLicenses}
+		packages, err := di.GetNPMDependencies(dir, opts)
+		if tc.shouldErr {
+			require.Error(t, err, tc.name)
+			continue
+		}
+		require.NoError(t, err, tc.name)
+
+		versions := []string{}
+		for _, p := range packages {
+			versions = append(versions, p.Name+"@"+p.Version)
+			if l, ok := tc.licenses[p.Name]; ok {
+				require.Equal(t, l, p.LicenseDeclared, tc.name)
+			}
+			if !tc.scanLicenses {
+				require.Empty(t, p.LicenseDeclared, tc.name)
+			}
+		}
+		require.Equal(t, tc.expected, versions, tc.name)
+	}
+}
+
+func TestNPMPackageToSPDX(t *testing.T) {
+	p := &NPMPackage{
+		Name:      "@babel/core",
+		Version:   "7.22.5",
+		Resolved:  "https://registry.npmjs.org/@babel/core/-/core-7.22.5.tgz",
+		Integrity: "sha1-KOvD+bPEz3LlhHZRNu1nFOVts9k=",
+		License:   "MIT",
+	}
+	require.Equal(t, "pkg:npm/%40babel/core@7.22.5", p.PackageURL())
+
+	spdxPackage, err := p.ToSPDXPackage()
+	require.NoError(t, err)
+	require.Equal(t, "@babel/core", spdxPackage.Name)
+	require.Equal(t, "7.22.5", spdxPackage.Version)
+	require.Equal(t, p.Resolved, spdxPackage.DownloadLocation)
+	require.Equal(t, "28ebc3f9b3c4cf72e584765136ed6714e56db3d9", spdxPackage.Checksum["SHA1"])
+	require.Equal(t, "MIT", spdxPackage.LicenseDeclared)
+	require.Equal(t, "pkg:npm/%40babel/core@7.22.5", spdxPackage.ExternalRefs[0].Locator)
+
+	// Packages resolved from the filesystem have no download location
+	p = &NPMPackage{Name: "ms", Version: "2.1.3", Resolved: "file:../ms"}
+	spdxPackage, err = p.ToSPDXPackage()
+	require.NoError(t, err)
+	require.Equal(t, NOASSERTION, spdxPackage.DownloadLocation)
+	require.Empty(t, spdxPackage.Checksum)
+
+	_, err = (&NPMPackage{Name: "ms"}).ToSPDXPackage()
+	require.Error(t, err)
+}
+
+func TestParsePNPMPackageKey(t *testing.T) {
+	for key, expected := range map[string][2]string{
+		"/ms/2.1.3":                       {"ms", "2.1.3"},
+		"/string_decoder/1.3.0":           {"string_decoder", "1.3.0"},
+		"/@babel/core/7.22.5":             {"@babel/core", "7.22.5"},
+		"/react-dom/18.2.0_react@18.2.0":  {"react-dom", "18.2.0"},
+		"/ms@2.1.3":                       {"ms", "2.1.3"},
+		"/@babel/core@7.22.5":             {"@babel/core", "7.22.5"},
+		"/react-dom@18.2.0(react@18.2.0)": {"react-dom", "18.2.0"},
+		"@types/node@20.4.2":              {"@types/node", "20.4.2"},
+		"invalid":                         {"", ""},
+	} {
+		name, version := parsePNPMPackageKey(key)
+		require.Equal(t, expected[0], name, key)
+		require.Equal(t, expected[1], version, key)
+	}
+}
@@ -26,13 +26,14 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	intoto "github.com/in-toto/in-toto-golang/in_toto"
 	purl "github.com/package-url/packageurl-go"
 	"github.com/sirupsen/logrus"
 
-	"sigs.k8s.io/release-utils/hash"
 	"sigs.k8s.io/release-utils/util"
+	"sigs.k8s.io/release-utils/version"
 )
 
 // Object is an interface that dictates the common methods of spdx
@@ -63,11 +64,36 @@ type Entity struct {
 	Opts             *ObjectOptions    // Entity options
 	Relationships    []*Relationship   // List of objects that have a relationship woth this package
 	Checksum         map[string]string // Colection of source file checksums
+	Annotations      []Annotation      // Annotations recorded about the entity
+	randomID         bool              // The ID ends with a random UUID, see BuildID
+}
+
+// Annotation types defined in the SPDX spec
+const (
+	AnnotationTypeReview = "REVIEW"
+	AnnotationTypeOther  = "OTHER"
+)
+
+// Annotation records a comment about an SPDX element
+type Annotation struct {
+	Annotator string // Person, Organization or Tool making the annotation (eg "Tool: bom-v0.5.0")
+	Date      string // Annotation date in ISO 8601 format
+	Type      string // REVIEW or OTHER
+	Comment   string // Text of the annotation
 }
 
 type ObjectOptions struct {
-	Prefix  string
-	WorkDir string
+	Prefix             string
+	WorkDir            string
+	ChecksumAlgorithms []string // Checksums computed when reading the source file (default SHA1, SHA256 and SHA512)
+	ComputeSWHID       bool     // Also annotate the entity with the SWHID of the source file when reading it
+
+	// MaxRelationshipDepth is the maximum number of levels packages can
+	// be nested under a package through relationships. Rendering trees
+	// deeper than the limit, or adding subpackages that would exceed it,
+	// fails instead of recursing without bounds. Zero uses the default of
+	// 1000 levels, a negative limit disables the check.
+	MaxRelationshipDepth int
 }
 
 func (e *Entity) Options() *ObjectOptions {
@@ -82,14 +108,17 @@ func (e *Entity) SPDXID() string {
 // SPDXID returns the SPDX reference string for the object
 func (e *Entity) SetSPDXID(id string) {
 	e.ID = id
+	e.randomID = false
 }
 
-// BuildID sets the file ID, optionally from a series of strings
+// BuildID sets the file ID, optionally from a series of strings. When no
+// seed is usable, the ID ends with a random UUID, replaced by one derived
+// from the document contents when the document is made reproducible.
 func (e *Entity) BuildID(seeds ...string) {
 	if len(seeds) <= 1 {
 		seeds = append(seeds, e.Name)
 	}
-	e.ID = buildIDString(seeds...)
+	e.ID, e.randomID = buildIDSeeds(seeds...)
 }
 
 // AddRelated this adds a related object to the file to be rendered
@@ -98,22 +127,68 @@ func (e *Entity) AddRelationship(rel *Relationship) {
 	e.Relationships = append(e.Relationships, rel)
 }
 
+// newToolAnnotation returns an annotation made by bom at the current time
+func newToolAnnotation(comment string) Annotation {
+	return Annotation{
+		Annotator: toolAnnotator(),
+		Date:      time.Now().UTC().Format(time.RFC3339),
+		Type:      AnnotationTypeOther,
+		Comment:   comment,
+	}
+}
+
+// newScanAnnotation returns an annotation made by bom while scanning with
+// opts. Reproducible scans date it at the reproducible creation time.
+func newScanAnnotation(opts *Options, comment string) Annotation {
+	annotation := newToolAnnotation(comment)
+	if opts.Reproducible {
+		if date, err := reproducibleTime(time.Time{}); err == nil {
+			annotation.Date = date.Format(time.RFC3339)
+		}
+	}
+	return annotation
+}
+
+// toolAnnotator returns the annotator string identifying bom
+func toolAnnotator() string {
+	return fmt.Sprintf("Tool: %s-%s", "bom", version.GetVersionInfo().GitVersion)
+}
+
+// AddAnnotation records an annotation about the entity
+func (e *Entity) AddAnnotation(annotation Annotation) {
+	e.Annotations = append(e.Annotations, annotation)
+}
+
 // ReadChecksums receives a path to a file and calculates its checksums
+// with the algorithms in the object options (SHA1, SHA256 and SHA512 by
+// default)
 func (e *Entity) ReadChecksums(filePath string) error {
 	if e.Checksum == nil {
 		e.Checksum = map[string]string{}
 	}
 
+	algorithms := defaultChecksumAlgorithms
+	if e.Opts != nil && len(e.Opts.ChecksumAlgorithms) > 0 {
+		algorithms = e.Opts.ChecksumAlgorithms
+	}
+
 	// Hash the file contents
-	for algo, fn := range map[string]func(string) (string, error){
-		"SHA1":   hash.SHA1ForFile,
-		"SHA256": hash.SHA256ForFile,
-		"SHA512": hash.SHA512ForFile,
-	} {
-		csum, err := fn(filePath)
+	if e.Opts != nil && e.Opts.ComputeSWHID {
+		checksums, swhid, err := fileChecksumsWithSWHID(filePath, algorithms)
 		if err != nil {
-			return fmt.Errorf("hashing %s file %s: %w", algo, filePath, err)
+			return fmt.Errorf("hashing file %s: %w", filePath, err)
 		}
+		for algo, csum := range checksums {
+			e.Checksum[algo] = csum
+		}
+		e.setSWHID(swhid)
+		return nil
+	}
+	checksums, err := fileChecksums(filePath, algorithms)
+	if err != nil {
+		return fmt.Errorf("hashing file %s: %w", filePath, err)
+	}
+	for algo, csum := range checksums {
 		e.Checksum[algo] = csum
 	}
 
@@ -132,6 +207,13 @@ func (e *Entity) ReadSourceFile(path string) error {
 		return fmt.Errorf("reading file checksums: %w", err)
 	}
 
+	e.setSourceFile(path)
+	return nil
+}
+
+// setSourceFile records path as the source file of the entity and
+// derives its file name from it
+func (e *Entity) setSourceFile(path string) {
 	e.SourceFile = path
 
 	// If the entity name is blank, we set it to the file path
@@ -142,8 +224,6 @@ func (e *Entity) ReadSourceFile(path string) error {
 	if e.Name == "" {
 		e.Name = e.FileName
 	}
-
-	return nil
 }
 
 // Render is overridden by Package and File with their own variants
@@ -0,0 +1,154 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"sigs.k8s.io/release-utils/util"
+)
+
+const (
+	// ociLayoutFilename marks the root of an OCI image layout
+	ociLayoutFilename = "oci-layout"
+
+	// ociIndexFilename is the index listing the manifests of an OCI layout
+	ociIndexFilename = "index.json"
+
+	// ociRefNameAnnotation holds the reference of a manifest in the index
+	// of an OCI layout, skopeo and oras record the image tag in it
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+// isOCILayout returns true if dir holds an OCI image layout
+func isOCILayout(dir string) bool {
+	return util.Exists(filepath.Join(dir, ociLayoutFilename)) &&
+		util.Exists(filepath.Join(dir, ociIndexFilename))
+}
+
+// hasImageManifest returns true if the archive extracted to dir is an
+// image, either a docker archive or an OCI layout
+func hasImageManifest(dir string) bool {
+	return util.Exists(filepath.Join(dir, archiveManifestFilename)) || isOCILayout(dir)
+}
+
+// ociBlobPath returns the path of a blob relative to the layout root
+func ociBlobPath(digest v1.Hash) string {
+	return path.Join("blobs", digest.Algorithm, digest.Hex)
+}
+
+// readOCILayoutManifest reads the manifest of the image in an OCI layout
+// and returns it as the manifest of a docker archive: the config and layer
+// files are the paths of their blobs. The files of the layout are read with
+// readFile, which gets their slash separated path from the layout root.
+//
+// When the index lists several images (or an image index, as in multi
+// arch layouts) the first image found is read.
+func readOCILayoutManifest(readFile func(name string) ([]byte, error)) (*ArchiveManifest, error) {
+	data, err := readFile(ociIndexFilename)
+	if err != nil {
+		return nil, fmt.Errorf("reading OCI layout index: %w", err)
+	}
+	index, err := v1.ParseIndexManifest(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing OCI layout index: %w", err)
+	}
+
+	desc, err := firstOCIImage(readFile, index, 0)
+	if err != nil {
+		return nil, err
+	}
+	data, err = readFile(ociBlobPath(desc.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("reading image manifest: %w", err)
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing image manifest: %w", err)
+	}
+
+	archiveManifest := &ArchiveManifest{
+		ConfigFilename: ociBlobPath(manifest.Config.Digest),
+		RepoTags:       []string{},
+		LayerFiles:     []string{},
+		ManifestDigest: desc.Digest.String(),
+		Annotations:    manifest.Annotations,
+	}
+	if ref := desc.Annotations[ociRefNameAnnotation]; ref != "" {
+		archiveManifest.RepoTags = append(archiveManifest.RepoTags, ref)
+	}
+	for _, layer := range manifest.Layers {
+		archiveManifest.LayerFiles = append(archiveManifest.LayerFiles, ociBlobPath(layer.Digest))
+	}
+	return archiveManifest, nil
+}
+
+// firstOCIImage returns the descriptor of the first image listed in an
+// index, looking into the nested indexes. The reference annotation of the
+// outer descriptor is kept when the image comes from a nested index.
+func firstOCIImage(
+	readFile func(name string) ([]byte, error), index *v1.IndexManifest, depth int,
+) (*v1.Descriptor, error) {
+	if depth > 2 {
+		return nil, errors.New("OCI layout indexes are nested too deep")
+	}
+	for i := range index.Manifests {
+		desc := index.Manifests[i]
+		switch {
+		case isAttestationManifest(&desc):
+			continue
+		case desc.MediaType.IsImage():
+			return &desc, nil
+		case desc.MediaType.IsIndex():
+			data, err := readFile(ociBlobPath(desc.Digest))
+			if err != nil {
+				return nil, fmt.Errorf("reading nested image index: %w", err)
+			}
+			nested, err := v1.ParseIndexManifest(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("parsing nested image index: %w", err)
+			}
+			image, err := firstOCIImage(readFile, nested, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if ref := desc.Annotations[ociRefNameAnnotation]; ref != "" && image.Annotations[ociRefNameAnnotation] == "" {
+				annotations := map[string]string{ociRefNameAnnotation: ref}
+				for k, v := range image.Annotations {
+					annotations[k] = v
+				}
+				image.Annotations = annotations
+			}
+			return image, nil
+		}
+	}
+	return nil, errors.New("no image manifest found in OCI layout index")
+}
+
+// readDirFile returns a function reading the files under dir by their
+// slash separated paths
+func readDirFile(dir string) func(name string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	}
+}
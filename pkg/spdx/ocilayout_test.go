@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromImageTarballOCILayout(t *testing.T) {
+	layers := []v1.Layer{}
+	for _, lf := range []string{
+		"../osinfo/testdata/link-with-no-dots.tar.gz",
+		"../osinfo/testdata/link-with-dots.tar.gz",
+	} {
+		layer, err := tarball.LayerFromFile(lf)
+		require.NoError(t, err)
+		layers = append(layers, layer)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	require.NoError(t, err)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	tag, err := name.NewTag("registry.example.com/test/image:v1.0.0")
+	require.NoError(t, err)
+	dockerPath := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, tarball.WriteToFile(dockerPath, tag, img))
+	ociPath := writeTestOCILayoutTarball(t, img, tag.String())
+
+	// layerNames returns the names of the layer packages, in order
+	layerNames := func(pkg *Package) []string {
+		names := []string{}
+		for _, layer := range containedPackages(pkg) {
+			names = append(names, layer.Name)
+		}
+		return names
+	}
+
+	opts := &Options{AnalyzeLayers: true}
+	impl := spdxDefaultImplementation{}
+	dockerPkg, err := impl.PackageFromImageTarball(opts, dockerPath)
+	require.NoError(t, err)
+	ociPkg, err := impl.PackageFromImageTarball(opts, ociPath)
+	require.NoError(t, err)
+	require.Len(t, layerNames(ociPkg), 2)
+	require.Equal(t, layerNames(dockerPkg), layerNames(ociPkg))
+	require.Equal(t, dockerPkg.SPDXID(), ociPkg.SPDXID())
+
+	// The manifest is resolved the same way when reading layers lazily
+	lazyPkg, err := impl.PackageFromImageTarball(&Options{AnalyzeLayers: true, LazyLayers: true}, ociPath)
+	require.NoError(t, err)
+	require.Equal(t, layerNames(ociPkg), layerNames(lazyPkg))
+	require.Equal(t, ociPkg.SPDXID(), lazyPkg.SPDXID())
+
+	// Without a reference the image is identified by its manifest digest
+	untagged := writeTestOCILayoutTarball(t, img, "")
+	dir, err := impl.ExtractTarballTmp(opts, untagged)
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	manifest, err := impl.ReadArchiveManifest(filepath.Join(dir, archiveManifestFilename))
+	require.NoError(t, err)
+	require.Empty(t, manifest.RepoTags)
+	require.Equal(t, digest.String(), manifest.ManifestDigest)
+	require.Len(t, manifest.LayerFiles, 2)
+	repoTag, err := manifestRepoTag(manifest)
+	require.NoError(t, err)
+	require.Equal(t, digest.String(), repoTag)
+
+	untaggedPkg, err := impl.PackageFromImageTarball(opts, untagged)
+	require.NoError(t, err)
+	require.Equal(t, layerNames(ociPkg), layerNames(untaggedPkg))
+}
@@ -59,7 +59,14 @@ FilesAnalyzed: {{ .FilesAnalyzed }}
 {{- if .Supplier.Organization }}PackageSupplier: Organization: {{ .Supplier.Organization }}
 {{ end -}}
 {{ end -}}
+{{ if .Originator -}}
+{{- if .Originator.Person }}PackageOriginator: Person: {{ .Originator.Person }}
+{{ end -}}
+{{- if .Originator.Organization }}PackageOriginator: Organization: {{ .Originator.Organization }}
+{{ end -}}
+{{ end -}}
 {{ if .VerificationCode }}PackageVerificationCode: {{ .VerificationCode }}
+{{- if .VerificationCodeExcludedFiles }} (excludes: {{ range $i, $f := .VerificationCodeExcludedFiles }}{{ if $i }}, {{ end }}{{ $f }}{{ end }}){{ end }}
 {{ end -}}
 PackageLicenseConcluded: {{ if .LicenseConcluded }}{{ .LicenseConcluded }}{{ else }}NOASSERTION{{ end }}
 {{ if .FileName }}PackageFileName: {{ .FileName }}
@@ -71,6 +78,8 @@ PackageLicenseConcluded: {{ if .LicenseConcluded }}{{ .LicenseConcluded }}{{ els
 {{ end -}}
 {{ if .HomePage }}PackageHomePage: {{ .HomePage }}
 {{ end -}}
+{{ if .SourceInfo }}PackageSourceInfo: <text>{{ .SourceInfo }}</text>
+{{ end -}}
 {{ if .PrimaryPurpose }}PrimaryPackagePurpose: {{ .PrimaryPurpose }}
 {{ end -}}
 {{ if .ExternalRefs }}{{- range $key, $value := .ExternalRefs -}}ExternalRef: {{ $value.Category }} {{ $value.Type }} {{ $value.Locator }}
@@ -79,6 +88,15 @@ PackageLicenseConcluded: {{ if .LicenseConcluded }}{{ .LicenseConcluded }}{{ els
 {{ if .LicenseComments }}PackageLicenseComments: <text>{{ .LicenseComments }}
 </text>
 {{ end -}}
+{{ if .Comment }}PackageComment: <text>{{ .Comment }}</text>
+{{ end -}}
+{{ range $key, $value := .Annotations -}}
+Annotator: {{ $value.Annotator }}
+AnnotationDate: {{ $value.Date }}
+AnnotationType: {{ $value.Type }}
+SPDXREF: {{ $.ID }}
+AnnotationComment: <text>{{ $value.Comment }}</text>
+{{ end -}}
 PackageLicenseDeclared: {{ if .LicenseDeclared }}{{ .LicenseDeclared }}{{ else }}NOASSERTION{{ end }}
 PackageCopyrightText: {{ if .CopyrightText }}<text>{{ .CopyrightText }}
 </text>{{ else }}NOASSERTION{{ end }}
@@ -96,6 +114,7 @@ type Package struct {
 	Version              string   // Package version
 	Comment              string   // a place for the SPDX document creator to record any general comments
 	HomePage             string   // A web site that serves as the package home page
+	SourceInfo           string   // Background information about the origin of the package (eg the commit it was built from)
 	PrimaryPurpose       string   // Estimate of the most likely package usage
 
 	// Supplier: the actual distribution source for the package/directory
@@ -111,6 +130,24 @@ type Package struct {
 	}
 
 	ExternalRefs []ExternalRef // List of external references
+
+	// VerificationCodeExcludedFiles lists the names of the files left out
+	// of the verification code, eg the SBOM written to the directory scanned
+	VerificationCodeExcludedFiles []string
+
+	// Plan lists what the scan of the package would read when it is
+	// generated with the DryRun option
+	Plan *ScanPlan
+
+	// dependencyType is the relationship of a dependency to the package
+	// depending on it when it is not needed at runtime, eg
+	// TEST_DEPENDENCY_OF. Empty for plain dependencies.
+	dependencyType RelationshipType
+
+	// directoryLicenses are the licenses found in the license files at the
+	// top of the directory of the package. ComputeLicenseList lists them
+	// along with the licenses of its files.
+	directoryLicenses []string
 }
 
 // PackagePurposes lists the valid package purposes
@@ -168,16 +205,28 @@ func (p *Package) AddFile(file *File) error {
 
 // AddPackage adds a new subpackage to a package
 func (p *Package) AddPackage(pkg *Package) error {
-	p.AddRelationship(&Relationship{
+	return p.addTemplatedPackage(pkg, nil)
+}
+
+// addTemplatedPackage adds a subpackage as AddPackage does, with the type
+// and comment of the relationship overridden by rt when set
+func (p *Package) addTemplatedPackage(pkg *Package, rt *RelationshipTemplate) error {
+	if err := checkRelationshipDepth(relationshipDepthLimit(p), pkg, 1); err != nil {
+		return err
+	}
+	p.AddRelationship(rt.Apply(&Relationship{
 		Peer:       pkg,
 		Type:       CONTAINS,
 		FullRender: true,
-	})
+	}))
 	return nil
 }
 
 // AddDependency adds a new subpackage as a dependency
 func (p *Package) AddDependency(pkg *Package) error {
+	if err := checkRelationshipDepth(relationshipDepthLimit(p), pkg, 1); err != nil {
+		return err
+	}
 	p.AddRelationship(&Relationship{
 		Peer:       pkg,
 		Type:       DEPENDS_ON,
@@ -186,6 +235,25 @@ func (p *Package) AddDependency(pkg *Package) error {
 	return nil
 }
 
+// addScopedDependency adds pkg as a dependency as AddDependency does, but
+// dependencies not needed at runtime are related with their dependencyType
+// (eg TEST_DEPENDENCY_OF) from pkg to p instead. Dependencies described
+// elsewhere in the document are added without rendering them.
+func (p *Package) addScopedDependency(pkg *Package, dependencyType RelationshipType, fullRender bool) error {
+	if fullRender {
+		if err := checkRelationshipDepth(relationshipDepthLimit(p), pkg, 1); err != nil {
+			return err
+		}
+	}
+	rel := &Relationship{Peer: pkg, Type: DEPENDS_ON, FullRender: fullRender}
+	if dependencyType != "" {
+		rel.Type = dependencyType
+		rel.Inverse = true
+	}
+	p.AddRelationship(rel)
+	return nil
+}
+
 // Files returns all contained files in the package
 func (p *Package) Files() []*File {
 	ret := []*File{}
@@ -200,7 +268,8 @@ func (p *Package) Files() []*File {
 }
 
 // ComputeVerificationCode calculates the package verification
-// code according to the SPDX spec
+// code according to the SPDX spec. The files listed in
+// VerificationCodeExcludedFiles are left out of it.
 func (p *Package) ComputeVerificationCode() error {
 	files := p.Files()
 	p.VerificationCode = ""
@@ -214,8 +283,15 @@ func (p *Package) ComputeVerificationCode() error {
 	if len(files) == 0 {
 		return nil
 	}
+	excluded := map[string]struct{}{}
+	for _, name := range p.VerificationCodeExcludedFiles {
+		excluded[name] = struct{}{}
+	}
 	shaList := []string{}
 	for _, f := range files {
+		if _, ok := excluded[f.Name]; ok {
+			continue
+		}
 		if f.Checksum == nil {
 			return fmt.Errorf("unable to render package, file has no checksums")
 		}
@@ -224,7 +300,9 @@ func (p *Package) ComputeVerificationCode() error {
 				"unable to render package, files were analyzed but some do not have sha1 checksums",
 			)
 		}
-		shaList = append(shaList, f.Checksum["SHA1"])
+		// The code is computed over the lowercase digests, whatever
+		// the case the checksums are rendered in
+		shaList = append(shaList, strings.ToLower(f.Checksum["SHA1"]))
 	}
 
 	// Sort the strings:
@@ -250,7 +328,7 @@ func (p *Package) ComputeLicenseList() error {
 		return fmt.Errorf("unable to compute license list, package has no files")
 	}
 
-	filesTagList := []string{}
+	filesTagList := append([]string{}, p.directoryLicenses...)
 	for _, f := range files {
 		// Collect the license tags
 		if f.LicenseInfoInFile != "" {
@@ -274,9 +352,14 @@ func (p *Package) ComputeLicenseList() error {
 	}
 
 	// If no license tags where collected from files, then the SBOM has
-	// to express "NONE" in the LicenseInfoFromFiles section to be compliant:
-	if len(filesTagList) == 0 {
-		p.LicenseInfoFromFiles = append(p.LicenseInfoFromFiles, NONE)
+	// to express "NONE" in the LicenseInfoFromFiles section to be compliant,
+	// or NOASSERTION when that is what all the files state:
+	if len(p.LicenseInfoFromFiles) == 0 {
+		tag := NONE
+		if len(filesTagList) == 1 && filesTagList[0] == NOASSERTION {
+			tag = NOASSERTION
+		}
+		p.LicenseInfoFromFiles = append(p.LicenseInfoFromFiles, tag)
 	}
 
 	return nil
@@ -284,6 +367,18 @@ func (p *Package) ComputeLicenseList() error {
 
 // Render renders the document fragment of the package
 func (p *Package) Render() (docFragment string, err error) {
+	return p.render(0)
+}
+
+// render renders the package found depth levels down the tree being
+// rendered. The depth of the whole tree is checked once, from its root.
+func (p *Package) render(depth int) (docFragment string, err error) {
+	if depth == 0 {
+		if err := checkRelationshipDepth(relationshipDepthLimit(p), p, 0); err != nil {
+			return "", err
+		}
+	}
+
 	// First thing, check all relationships
 	if len(p.Relationships) > 0 {
 		logrus.Infof("Package %s has %d relationships defined", p.SPDXID(), len(p.Relationships))
@@ -307,8 +402,8 @@ func (p *Package) Render() (docFragment string, err error) {
 
 		// Extract the ltest license tags from the contained files
 		// these MUST be listed in the LicenseInfoFromFiles tag
-		if err := p.ComputeVerificationCode(); err != nil {
-			return "", fmt.Errorf("computing verification code: %w", err)
+		if err := p.ComputeLicenseList(); err != nil {
+			return "", fmt.Errorf("computing license list: %w", err)
 		}
 	}
 
@@ -321,7 +416,7 @@ func (p *Package) Render() (docFragment string, err error) {
 
 	// Add the output from all related files
 	for _, rel := range p.Relationships {
-		fragment, err := rel.Render(p)
+		fragment, err := rel.render(p, depth)
 		if err != nil {
 			return "", fmt.Errorf("rendering relationship: %w", err)
 		}
@@ -528,6 +623,37 @@ func (p *Package) Purl() *purl.PackageURL {
 	return &purlObject
 }
 
+// AddAdvisory adds a SECURITY external reference to the package pointing
+// to an advisory about it. The locator must be the URL of the advisory.
+// Adding an advisory already referenced by the package does nothing.
+func (p *Package) AddAdvisory(advisoryURL string) error {
+	if !isURL(advisoryURL) || strings.ContainsAny(advisoryURL, " \t\n") {
+		return fmt.Errorf("invalid advisory URL: %q", advisoryURL)
+	}
+	for _, er := range p.ExternalRefs {
+		if er.Category == CatSecurity && er.Type == advisoryRefType && er.Locator == advisoryURL {
+			return nil
+		}
+	}
+	p.ExternalRefs = append(p.ExternalRefs, ExternalRef{
+		Category: CatSecurity,
+		Type:     advisoryRefType,
+		Locator:  advisoryURL,
+	})
+	return nil
+}
+
+// Advisories returns the URLs of the advisories referenced by the package
+func (p *Package) Advisories() []string {
+	advisories := []string{}
+	for _, er := range p.ExternalRefs {
+		if er.Category == CatSecurity && er.Type == advisoryRefType {
+			advisories = append(advisories, er.Locator)
+		}
+	}
+	return advisories
+}
+
 type PurlSearchOption string
 
 // PurlMatches gets a spec url and returns true if its defined parts
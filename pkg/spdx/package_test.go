@@ -18,6 +18,8 @@ package spdx
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	purl "github.com/package-url/packageurl-go"
@@ -292,3 +294,114 @@ func TestIsURL(t *testing.T) {
 		require.Equal(t, tc.isURL, res)
 	}
 }
+
+func TestPackageEqualNoAssertion(t *testing.T) {
+	newPkg := func(version, license string) *Package {
+		p := NewPackage()
+		p.Name = "pkg"
+		p.BuildID(p.Name, version)
+		p.Version = version
+		p.LicenseConcluded = license
+		p.Checksum = map[string]string{"SHA256": "c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57"}
+		return p
+	}
+	ignore := &CompareOptions{IgnoreNoAssertion: true}
+
+	for _, tc := range []struct {
+		a, b         *Package
+		strict, lax  bool
+		sameIdentity bool
+	}{
+		{newPkg("1.0", "MIT"), newPkg("1.0", "MIT"), true, true, true},
+		{newPkg("1.0", "MIT"), newPkg("1.0", NOASSERTION), false, true, true},
+		{newPkg("1.0", ""), newPkg("1.0", "MIT"), false, true, true},
+		{newPkg(NOASSERTION, "MIT"), newPkg("1.0", "MIT"), false, true, false},
+		{newPkg("1.0", "MIT"), newPkg("1.0", "Apache-2.0"), false, false, true},
+	} {
+		require.Equal(t, tc.strict, tc.a.Equal(tc.b, nil))
+		require.Equal(t, tc.lax, tc.a.Equal(tc.b, ignore))
+		require.Equal(t, tc.lax, tc.b.Equal(tc.a, ignore))
+		require.Equal(t, tc.sameIdentity, tc.a.IdentityKey(nil) == tc.b.IdentityKey(nil))
+	}
+
+	// A NOASSERTION version drops out of the identity key
+	require.Equal(t, "pkg@", newPkg(NOASSERTION, "MIT").IdentityKey(ignore))
+
+	// Differing checksums are never ignored
+	other := newPkg("1.0", NOASSERTION)
+	other.Checksum = map[string]string{"SHA256": "0000"}
+	require.False(t, newPkg("1.0", "MIT").Equal(other, ignore))
+}
+
+func TestMaxRelationshipDepth(t *testing.T) {
+	newPkg := func(i int) *Package {
+		p := NewPackage()
+		p.Name = fmt.Sprintf("pkg%d", i)
+		p.BuildID(p.Name)
+		p.Options().MaxRelationshipDepth = 10
+		return p
+	}
+
+	// Building the chain bottom up fails once it gets too deep
+	top := newPkg(0)
+	var err error
+	for i := 1; i <= 20 && err == nil; i++ {
+		parent := newPkg(i)
+		if err = parent.AddPackage(top); err == nil {
+			top = parent
+		}
+	}
+	require.ErrorIs(t, err, ErrMaxRelationshipDepth)
+	_, err = top.Render()
+	require.NoError(t, err)
+
+	// Relationships added directly are checked when rendering
+	deeper := newPkg(100)
+	deeper.AddRelationship(&Relationship{Peer: top, Type: CONTAINS, FullRender: true})
+	_, err = deeper.Render()
+	require.ErrorIs(t, err, ErrMaxRelationshipDepth)
+
+	doc := NewDocument()
+	doc.Packages = map[string]*Package{deeper.SPDXID(): deeper}
+	_, err = doc.Render()
+	require.ErrorIs(t, err, ErrMaxRelationshipDepth)
+
+	// Without a limit any depth is allowed
+	unlimited := newPkg(101)
+	unlimited.Options().MaxRelationshipDepth = -1
+	require.NoError(t, unlimited.AddPackage(deeper))
+	_, err = unlimited.Render()
+	require.NoError(t, err)
+
+	// The default limit allows deeper trees
+	require.NoError(t, NewPackage().AddPackage(deeper))
+}
+
+func TestAddAdvisory(t *testing.T) {
+	const advisory = "https://github.com/advisories/GHSA-xxxx-yyyy-zzzz"
+	pkg := NewPackage()
+	pkg.Name = "pkg"
+	pkg.BuildID("pkg")
+	require.Error(t, pkg.AddAdvisory("GHSA-xxxx-yyyy-zzzz"))
+	require.Error(t, pkg.AddAdvisory("https://example.com/an advisory"))
+	require.NoError(t, pkg.AddAdvisory(advisory))
+	require.NoError(t, pkg.AddAdvisory(advisory))
+	require.Equal(t, []ExternalRef{{Category: CatSecurity, Type: "advisory", Locator: advisory}}, pkg.ExternalRefs)
+	require.Equal(t, []string{advisory}, pkg.Advisories())
+
+	// The reference survives rendering and parsing the document
+	doc := NewDocument()
+	doc.Name = "advisories"
+	doc.Namespace = "https://example.com/advisories"
+	require.NoError(t, doc.AddPackage(pkg))
+	rendered, err := doc.Render()
+	require.NoError(t, err)
+	require.Contains(t, rendered, "ExternalRef: SECURITY advisory "+advisory+"\n")
+
+	path := filepath.Join(t.TempDir(), "doc.spdx")
+	require.NoError(t, os.WriteFile(path, []byte(rendered), os.FileMode(0o644)))
+	parsed, err := OpenDoc(path)
+	require.NoError(t, err)
+	require.Contains(t, parsed.Packages, pkg.SPDXID())
+	require.Equal(t, []string{advisory}, parsed.Packages[pkg.SPDXID()].Advisories())
+}
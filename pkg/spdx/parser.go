@@ -46,6 +46,12 @@ var (
 // spdx.Document object. This functions has the cyclomatic chec disabled as
 // it spans specific cases for each of the tags it recognizes.
 func OpenDoc(path string) (doc *Document, err error) {
+	return OpenDocWithOptions(path, nil)
+}
+
+// OpenDocWithOptions is OpenDoc logging the problems found in the document
+// to the logger of the options
+func OpenDocWithOptions(path string, opts *Options) (doc *Document, err error) {
 	// support reading SBOMs from STDIN
 	var file *os.File
 	var isTemp bool
@@ -81,14 +87,41 @@ func OpenDoc(path string) (doc *Document, err error) {
 
 	switch format {
 	case "spdx":
-		return parseTagValue(file)
+		return parseTagValue(logger(opts), file)
 	case "spdx+json":
-		return parseJSON(file)
+		return parseJSON(logger(opts), file)
 	}
 
 	return nil, errors.New("unknown SBOM encoding")
 }
 
+// ReadDoc parses an SPDX document in JSON or tag-value format from a
+// reader. The relationships between its elements are rebuilt from their
+// SPDX IDs, as when opening documents with OpenDoc.
+func ReadDoc(r io.Reader) (*Document, error) {
+	return ReadDocWithOptions(r, nil)
+}
+
+// ReadDocWithOptions is ReadDoc logging the problems found in the document
+// to the logger of the options
+func ReadDocWithOptions(r io.Reader, opts *Options) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading SBOM: %w", err)
+	}
+	prefix := data
+	if len(prefix) > 512 {
+		prefix = prefix[:512]
+	}
+	switch sbomEncoding(prefix) {
+	case "spdx":
+		return parseTagValue(logger(opts), bytes.NewReader(data))
+	case "spdx+json":
+		return parseJSON(logger(opts), bytes.NewReader(data))
+	}
+	return nil, errors.New("unknown SBOM encoding")
+}
+
 func tempFileFromURL(query string) (*os.File, error) {
 	response, err := http.GetURLResponse(query, false)
 	if err != nil {
@@ -115,12 +148,12 @@ func isURL(str string) bool {
 // parseJSON parses an SPDX document encoded in json
 //
 //nolint:gocyclo
-func parseJSON(file *os.File) (doc *Document, err error) {
+func parseJSON(log logrus.FieldLogger, r io.Reader) (doc *Document, err error) {
 	var jsonDoc document.Document
 
 	// Read the SPDX doc into the json struct
 	var data []byte
-	data, err = os.ReadFile(file.Name())
+	data, err = io.ReadAll(r)
 	if err != nil {
 		return nil, fmt.Errorf("reading SBOM file: %w", err)
 	}
@@ -139,7 +172,7 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 			ExternalDocumentRefs: []spdx23JSON.ExternalDocumentRef{},
 		}
 		if err := json.Unmarshal(data, &doc); err != nil {
-			return nil, fmt.Errorf("parsing SBOM json: %w", err)
+			return nil, jsonParseError(data, err)
 		}
 		spdxVersion = "2.3"
 		jsonDoc = &doc
@@ -155,7 +188,7 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 			ExternalDocumentRefs: []spdx22JSON.ExternalDocumentRef{},
 		}
 		if err := json.Unmarshal(data, &doc); err != nil {
-			return nil, fmt.Errorf("parsing SBOM json: %w", err)
+			return nil, jsonParseError(data, err)
 		}
 		spdxVersion = "2.2"
 		jsonDoc = &doc
@@ -177,6 +210,7 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 		Packages:        map[string]*Package{},
 		Files:           map[string]*File{},
 		ExternalDocRefs: []ExternalDocumentRef{},
+		Annotations:     parsedAnnotations(jsonDoc.GetAnnotations()),
 	}
 
 	creationInfo := jsonDoc.GetCreationInfo()
@@ -184,7 +218,7 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 		// Technical limitation in bom: We only have one person and one org
 		ps := strings.SplitN(c, ":", 2)
 		if len(ps) != 2 {
-			logrus.Errorf("unable to parse creator data: %s", c)
+			log.Errorf("unable to parse creator data: %s", c)
 			continue
 		}
 		ps[1] = strings.TrimSpace(ps[1])
@@ -194,18 +228,18 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 			if doc.Creator.Person == "" {
 				doc.Creator.Person = ps[1]
 			} else {
-				logrus.Warnf("Ignoring additional SBOM Creator Person")
+				log.Warnf("Ignoring additional SBOM Creator Person")
 			}
 		case entOrganization:
 			if doc.Creator.Organization == "" {
 				doc.Creator.Organization = ps[1]
 			} else {
-				logrus.Warnf("Ignoring additional SBOM Creator Organization")
+				log.Warnf("Ignoring additional SBOM Creator Organization")
 			}
 		case entTool:
 			doc.Creator.Tool = append(doc.Creator.Tool, ps[1])
 		default:
-			logrus.Errorf("Unknown creator record: %s", ps[0])
+			log.Errorf("Unknown creator record: %s", ps[0])
 		}
 	}
 
@@ -214,25 +248,32 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 	if createdDate != "" {
 		t, err := time.Parse("2006-01-02T15:04:05Z", createdDate)
 		if err != nil {
-			logrus.Errorf("unable to parse creation time: %s: %s", createdDate, err)
+			log.Errorf("unable to parse creation time: %s: %s", createdDate, err)
 		} else {
 			doc.Created = t
 		}
 	}
 
 	allPackages := map[string]*Package{}
-	for _, pData := range jsonDoc.GetPackages() {
+	for i, pData := range jsonDoc.GetPackages() {
 		packageID := pData.GetID()
+		if packageID == "" {
+			return nil, fmt.Errorf("package #%d (%q) has no SPDXID", i+1, pData.GetName())
+		}
+		if _, ok := allPackages[packageID]; ok {
+			return nil, fmt.Errorf("package #%d (%q) has duplicate SPDXID %s", i+1, pData.GetName(), packageID)
+		}
 		allPackages[packageID] = &Package{
 			Entity: Entity{
 				ID:               pData.GetID(),
 				Name:             pData.GetName(),
 				DownloadLocation: pData.GetDownloadLocation(),
 				CopyrightText:    pData.GetCopyrightText(),
-				LicenseConcluded: pData.GetLicenseDeclared(),
+				LicenseConcluded: pData.GetLicenseConcluded(),
 				// LicenseComments:  pData.LicenseComments,
 				Relationships: []*Relationship{},
 				Checksum:      map[string]string{},
+				Annotations:   parsedAnnotations(pData.GetAnnotations()),
 			},
 			FilesAnalyzed:        pData.GetFilesAnalyzed(),
 			LicenseInfoFromFiles: []string{},
@@ -249,15 +290,42 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 				Person       string
 				Organization string
 			}{},
-			ExternalRefs: []ExternalRef{},
+			ExternalRefs:                  []ExternalRef{},
+			VerificationCodeExcludedFiles: pData.GetVerificationCode().GetExcludedFiles(),
 		}
 
 		if spdxVersion == "2.3" {
 			allPackages[packageID].PrimaryPurpose = pData.GetPrimaryPurpose()
 		}
 
+		// Suppliers and originators are "Person: name" or "Organization: name",
+		// other forms found in documents written by other tools are skipped
+		pkg := allPackages[packageID]
+		for _, ent := range []struct {
+			what, value          string
+			person, organization *string
+		}{
+			{"supplier", pData.GetSupplier(), &pkg.Supplier.Person, &pkg.Supplier.Organization},
+			{"originator", pData.GetOriginator(), &pkg.Originator.Person, &pkg.Originator.Organization},
+		} {
+			if ent.value == "" || ent.value == NOASSERTION {
+				continue
+			}
+			match := tagRegExp.FindStringSubmatch(ent.value)
+			if len(match) != 3 || (match[1] != entPerson && match[1] != entOrganization) {
+				log.Debugf("Ignoring invalid %s %q of package %s", ent.what, ent.value, pData.GetID())
+				continue
+			}
+			if match[1] == entPerson {
+				*ent.person = match[2]
+			} else {
+				*ent.organization = match[2]
+			}
+		}
+
 		for _, cs := range pData.GetChecksums() {
-			allPackages[packageID].Checksum[cs.GetAlgorithm()] = cs.GetValue()
+			algo, value := parsedChecksum(cs.GetAlgorithm(), cs.GetValue())
+			allPackages[packageID].Checksum[algo] = value
 		}
 
 		for _, eref := range pData.GetExternalRefs() {
@@ -272,8 +340,17 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 	}
 
 	allFiles := map[string]*File{}
-	for _, fData := range jsonDoc.GetFiles() {
+	for i, fData := range jsonDoc.GetFiles() {
 		fileID := fData.GetID()
+		if fileID == "" {
+			return nil, fmt.Errorf("file #%d (%q) has no SPDXID", i+1, fData.GetName())
+		}
+		if _, ok := allPackages[fileID]; ok {
+			return nil, fmt.Errorf("file #%d (%q) has duplicate SPDXID %s", i+1, fData.GetName(), fileID)
+		}
+		if _, ok := allFiles[fileID]; ok {
+			return nil, fmt.Errorf("file #%d (%q) has duplicate SPDXID %s", i+1, fData.GetName(), fileID)
+		}
 		allFiles[fileID] = &File{
 			Entity: Entity{
 				ID:               fileID,
@@ -283,6 +360,7 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 				// LicenseComments:  pData.LicenseComments,
 				Relationships: []*Relationship{},
 				Checksum:      map[string]string{},
+				Annotations:   parsedAnnotations(fData.GetAnnotations()),
 			},
 			FileType: []string{},
 			LicenseInfoInFile: strings.Join(
@@ -291,11 +369,13 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 		}
 
 		for _, cs := range fData.GetChecksums() {
-			allFiles[fileID].Checksum[cs.GetAlgorithm()] = cs.GetValue()
+			algo, value := parsedChecksum(cs.GetAlgorithm(), cs.GetValue())
+			allFiles[fileID].Checksum[algo] = value
 		}
 	}
 
 	seenObjects := map[string]string{}
+	describedIDs := jsonDoc.GetDocumentDescribes()
 
 	// Populate the package and file relationships before adding
 	// the root level elements
@@ -309,6 +389,13 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 		relatedID = r.GetRelated()
 		typeID := r.GetType()
 
+		// DESCRIBES relationships from the document declare the top
+		// level elements, same as the documentDescribes list
+		if elementID == jsonDoc.GetID() && typeID == string(DESCRIBES) {
+			describedIDs = append(describedIDs, relatedID)
+			continue
+		}
+
 		// Look for the source element
 		if _, ok := allPackages[elementID]; ok {
 			source = allPackages[elementID]
@@ -316,7 +403,7 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 			source = allFiles[elementID]
 		}
 		if source == nil {
-			logrus.Warnf("unable to find SPDX source element %s", elementID)
+			log.Warnf("unable to find SPDX source element %s", elementID)
 			continue
 		}
 
@@ -326,7 +413,7 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 			externalID = relatedID
 			parts := strings.SplitN(relatedID, ":", 2)
 			if len(parts) != 2 {
-				logrus.Errorf("Unable to parse external reference %s", relatedID)
+				log.Errorf("Unable to parse external reference %s", relatedID)
 				continue
 			}
 			relatedID = parts[1]
@@ -337,7 +424,7 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 				peer = allFiles[relatedID]
 			}
 			if peer == nil {
-				logrus.Warnf("unable to find SPDX related element %s", relatedID)
+				log.Warnf("unable to find SPDX related element %s", relatedID)
 				continue
 			}
 			relatedID = peer.SPDXID()
@@ -359,7 +446,7 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 	}
 
 	// Add the top level packages
-	for _, el := range jsonDoc.GetDocumentDescribes() {
+	for _, el := range describedIDs {
 		var p *Package
 		var f *File
 		var ok bool
@@ -375,7 +462,7 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 			seenObjects[el] = el
 			continue
 		}
-		logrus.Errorf("unable to find package %s described by sbom", el)
+		log.Errorf("unable to find package %s described by sbom", el)
 	}
 
 	// Delete everything from the all maps to see if we missed anything
@@ -385,34 +472,67 @@ func parseJSON(file *os.File) (doc *Document, err error) {
 	}
 
 	if l := len(allPackages); l > 0 {
-		logrus.Warnf("%d packages could not be assigned to the SBOM", l)
+		log.Warnf("%d packages could not be assigned to the SBOM", l)
 	}
 
 	if l := len(allFiles); l > 0 {
-		logrus.Warnf("%d files could not be assigned to the SBOM", l)
+		log.Warnf("%d files could not be assigned to the SBOM", l)
 	}
 
 	// Assign external references
 	for _, ref := range jsonDoc.GetExternalDocumentRefs() {
 		cs := ref.GetChecksum()
+		algo, value := parsedChecksum(cs.GetAlgorithm(), cs.GetValue())
 		extRef := ExternalDocumentRef{
-			ID:  ref.GetExternalDocumentID(),
-			URI: ref.GetSPDXDocument(),
-			Checksums: map[string]string{
-				cs.GetAlgorithm(): cs.GetValue(),
-			},
+			ID:        ref.GetExternalDocumentID(),
+			URI:       ref.GetSPDXDocument(),
+			Checksums: map[string]string{algo: value},
 		}
 		doc.ExternalDocRefs = append(doc.ExternalDocRefs, extRef)
 	}
-	fmt.Printf("%+v\n", doc)
-	fmt.Printf("PACKAGE:  %+v\n", doc.Packages["SPDXRef-Package-sha256-a78c2d6208eff9b672de43f880093100050983047b7b0afe0217d3656e1b0d5f"])
 	return doc, nil
 }
 
+// parsedAnnotations converts the annotations read from a JSON document
+func parsedAnnotations(annotations []document.Annotation) []Annotation {
+	if len(annotations) == 0 {
+		return nil
+	}
+	parsed := make([]Annotation, 0, len(annotations))
+	for _, a := range annotations {
+		parsed = append(parsed, Annotation{
+			Annotator: a.GetAnnotator(),
+			Date:      a.GetDate(),
+			Type:      a.GetType(),
+			Comment:   a.GetComment(),
+		})
+	}
+	return parsed
+}
+
+// jsonParseError returns the error of parsing an SPDX JSON document,
+// pointing to the line where it failed
+func jsonParseError(data []byte, err error) error {
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 || offset > int64(len(data)) {
+		return fmt.Errorf("parsing SBOM json: %w", err)
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	return fmt.Errorf("parsing SBOM json at line %d: %w", line, err)
+}
+
 // parseTagValue parses an SPDX SBOM in tag-value format
 //
 //nolint:gocyclo
-func parseTagValue(file *os.File) (doc *Document, err error) {
+func parseTagValue(log logrus.FieldLogger, file io.Reader) (doc *Document, err error) {
 	// Create a blank document
 	doc = &Document{
 		Packages:        map[string]*Package{},
@@ -433,18 +553,27 @@ func parseTagValue(file *os.File) (doc *Document, err error) {
 		Peer         string
 		ExtDoc       string
 	}{}
+
+	// Annotations are assigned to the elements in their SPDXREF tag once
+	// all of them are read
+	annotations := []struct {
+		Element    string
+		Annotation Annotation
+	}{}
 	for scanner.Scan() {
 		// If we are capturing text for a multiline value, read and add
 		// the line to the buffer
 		if captureMultiline {
-			textValue += scanner.Text() + "\n"
 			// If we closing tag is not here, continue to the next line
-			if !strings.Contains(scanner.Text(), "</text>") {
+			line, _, closed := strings.Cut(scanner.Text(), "</text>")
+			if !closed {
+				textValue += line + "\n"
 				continue
 			}
 
-			// If closing tag found, remove it from value
-			value = strings.ReplaceAll(textValue, "</text>", "")
+			// If closing tag found, remove it from value. The writer
+			// breaks the line before the closing tag of some values.
+			value = strings.TrimSuffix(textValue+line, "\n")
 			textValue = ""
 		}
 
@@ -459,15 +588,16 @@ func parseTagValue(file *os.File) (doc *Document, err error) {
 
 			// If it is a multiline value, start buffering it
 			if strings.HasPrefix(value, "<text>") {
-				textValue = strings.ReplaceAll(value, "<text>", "") + "\n"
+				textValue = strings.TrimPrefix(value, "<text>")
 				captureMultiline = true
 
 				// It may be that the closing tag is right in the same
 				// line. If so, capture and finish buffering
-				if strings.Contains(scanner.Text(), "</text>") {
-					value = strings.ReplaceAll(textValue, "</text>", "")
+				if line, _, closed := strings.Cut(textValue, "</text>"); closed {
+					value = line
 					textValue = ""
 				} else {
+					textValue += "\n"
 					continue
 				}
 			}
@@ -504,7 +634,7 @@ func parseTagValue(file *os.File) (doc *Document, err error) {
 			currentEntity.Name = value
 
 		case "SPDXID":
-			logrus.Debugf("Entity ID %s", value)
+			log.Debugf("Entity ID %s", value)
 			if currentEntity == nil {
 				doc.ID = value
 			} else {
@@ -526,13 +656,26 @@ func parseTagValue(file *os.File) (doc *Document, err error) {
 		case "PackageLicenseDeclared":
 			currentObject.(*Package).LicenseDeclared = value
 		case "PackageVerificationCode":
-			currentObject.(*Package).VerificationCode = value
+			// The code may list the files it leaves out: "code (excludes: a, b)"
+			code, excludes, ok := strings.Cut(value, "(excludes:")
+			currentObject.(*Package).VerificationCode = strings.TrimSpace(code)
+			if ok {
+				for _, name := range strings.Split(strings.TrimSuffix(strings.TrimSpace(excludes), ")"), ",") {
+					if name = strings.TrimSpace(name); name != "" {
+						currentObject.(*Package).VerificationCodeExcludedFiles = append(
+							currentObject.(*Package).VerificationCodeExcludedFiles, name,
+						)
+					}
+				}
+			}
 		case "PackageComment":
 			currentObject.(*Package).Comment = value
 		case "PackageFileName":
 			currentObject.(*Package).FileName = value
 		case "PackageHomePage":
 			currentObject.(*Package).HomePage = value
+		case "PackageSourceInfo":
+			currentObject.(*Package).SourceInfo = value
 		case "PrimaryPackagePurpose":
 			purpose := ""
 			for _, pp := range PackagePurposes {
@@ -578,6 +721,25 @@ func parseTagValue(file *os.File) (doc *Document, err error) {
 					match[1], i,
 				)
 			}
+		case "PackageOriginator":
+			if value == NOASSERTION {
+				continue
+			}
+			match := tagRegExp.FindStringSubmatch(value)
+			if len(match) != 3 {
+				return nil, fmt.Errorf("invalid originator tag syntax at line %d: %s", i, value)
+			}
+			switch match[1] {
+			case entPerson:
+				currentObject.(*Package).Originator.Person = match[2]
+			case entOrganization:
+				currentObject.(*Package).Originator.Organization = match[2]
+			default:
+				return nil, fmt.Errorf(
+					"invalid originator tag '%s' syntax at line %d, valid values are 'Organization' or 'Person'",
+					match[1], i,
+				)
+			}
 		case "LicenseInfoInFile":
 			if value != NONE {
 				currentObject.(*File).LicenseInfoInFile = value
@@ -591,7 +753,8 @@ func parseTagValue(file *os.File) (doc *Document, err error) {
 			if currentEntity.Checksum == nil {
 				currentEntity.Checksum = map[string]string{}
 			}
-			currentEntity.Checksum[match[1]] = match[2]
+			algo, hash := parsedChecksum(match[1], match[2])
+			currentEntity.Checksum[algo] = hash
 		case "Relationship":
 			matches := relationshioRegExp.FindStringSubmatch(value)
 			if len(matches) != 4 {
@@ -694,14 +857,40 @@ func parseTagValue(file *os.File) (doc *Document, err error) {
 			}
 		case "LicenseListVersion":
 			doc.LicenseListVersion = value
+			// Annotations start with their annotator and apply to the
+			// element being read unless they name another one
+		case "Annotator":
+			element := doc.ID
+			if currentEntity != nil {
+				element = currentEntity.ID
+			}
+			annotations = append(annotations, struct {
+				Element    string
+				Annotation Annotation
+			}{element, Annotation{Annotator: value}})
+		case "AnnotationDate", "AnnotationType", "SPDXREF", "AnnotationComment":
+			if len(annotations) == 0 {
+				return nil, fmt.Errorf("%s found outside of an annotation at line %d", tag, i)
+			}
+			last := &annotations[len(annotations)-1]
+			switch tag {
+			case "AnnotationDate":
+				last.Annotation.Date = value
+			case "AnnotationType":
+				last.Annotation.Type = value
+			case "SPDXREF":
+				last.Element = value
+			case "AnnotationComment":
+				last.Annotation.Comment = value
+			}
 		default:
-			logrus.Debugf("Unknown tag: %s", tag)
+			log.Debugf("Unknown tag: %s", tag)
 		}
 		i++
 	}
 
 	if currentEntity == nil {
-		return nil, fmt.Errorf("invalid file %s", file.Name())
+		return nil, errors.New("invalid document, it has no packages nor files")
 	}
 	// Add the last object from the doc
 	currentObject.SetEntity(currentEntity)
@@ -718,16 +907,16 @@ func parseTagValue(file *os.File) (doc *Document, err error) {
 	// Now assign the relationships to the proper objects
 	owned := map[string]struct{}{}
 	for _, rdata := range rels {
-		logrus.Debugf("Procesing %s %s %s", rdata.Source, rdata.Relationship, rdata.Peer)
+		log.Debugf("Procesing %s %s %s", rdata.Source, rdata.Relationship, rdata.Peer)
 		// If the source is the doc. Add them
 		if rdata.Source == doc.ID {
 			if p, ok := objects[rdata.Peer].(*Package); ok {
-				logrus.Debugf("doc %s describes package %s", doc.ID, rdata.Peer)
+				log.Debugf("doc %s describes package %s", doc.ID, rdata.Peer)
 				doc.Packages[rdata.Peer] = p
 			}
 
 			if f, ok := objects[rdata.Peer].(*File); ok {
-				logrus.Debugf("doc %s describes file %s", doc.ID, rdata.Peer)
+				log.Debugf("doc %s describes file %s", doc.ID, rdata.Peer)
 				doc.Files[(objects[rdata.Peer]).(*File).SPDXID()] = f
 			}
 			continue
@@ -747,7 +936,7 @@ func parseTagValue(file *os.File) (doc *Document, err error) {
 		}
 
 		if (objects[rdata.Source]).SPDXID() == "" {
-			logrus.Fatalf("No ID in object %s:\n%+v", rdata.Source, objects[rdata.Source])
+			log.Fatalf("No ID in object %s:\n%+v", rdata.Source, objects[rdata.Source])
 		}
 		(objects[rdata.Source]).AddRelationship(&Relationship{
 			FullRender:       false,
@@ -760,6 +949,21 @@ func parseTagValue(file *os.File) (doc *Document, err error) {
 		owned[rdata.Peer] = struct{}{}
 	}
 
+	for _, a := range annotations {
+		switch obj := objects[a.Element].(type) {
+		case *Package:
+			obj.AddAnnotation(a.Annotation)
+		case *File:
+			obj.AddAnnotation(a.Annotation)
+		default:
+			if a.Element != doc.ID {
+				log.Warnf("unable to find SPDX element %s of annotation", a.Element)
+				continue
+			}
+			doc.AddAnnotation(a.Annotation)
+		}
+	}
+
 	// Now, finally any objects not referenced should be made
 	// leafs of the document
 	for id, obj := range objects {
@@ -787,15 +991,18 @@ func DetectSBOMEncoding(f *os.File) (format string, err error) {
 	if _, err := f.Seek(0, 0); err != nil {
 		return "", fmt.Errorf("rewinding sbom pointer: %w", err)
 	}
+	return sbomEncoding(bs), nil
+}
 
+// sbomEncoding returns the encoding of an SBOM from its first bytes
+func sbomEncoding(bs []byte) string {
 	// In JSON, the spdx version fiel would be quoted
 	if strings.Contains(string(bs), "\"spdxVersion\"") {
-		return "spdx+json", nil
+		return "spdx+json"
 	} else if strings.Contains(string(bs), "SPDXVersion:") {
-		return "spdx", nil
+		return "spdx"
 	}
-	logrus.Warn("Unable to detect SBOM encoding")
-	return "", nil
+	return ""
 }
 
 // buyfferSTDIN buffers all of STDIN to a temp file
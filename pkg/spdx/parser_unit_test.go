@@ -18,6 +18,7 @@ package spdx
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -97,7 +98,7 @@ func TestParseJsonInternal(t *testing.T) {
 	file, err := os.Open("testdata/images.spdx.json")
 	require.NoError(t, err)
 
-	doc, err := parseJSON(file)
+	doc, err := parseJSON(logger(nil), file)
 	require.NoError(t, err)
 
 	require.Len(t, doc.Packages, 1)
@@ -125,7 +126,7 @@ func TestParseJsonExternal(t *testing.T) {
 	file, err := os.Open("testdata/external-references.spdx.json")
 	require.NoError(t, err)
 
-	doc, err := parseJSON(file)
+	doc, err := parseJSON(logger(nil), file)
 	require.NoError(t, err)
 
 	rootPackage := "sha256-af1c5f9673f78aa7a575d627cd8a210bf6a895b0065f719a098dc035eee55a58"
@@ -154,3 +155,53 @@ func TestParseJsonExternal(t *testing.T) {
 		}
 	}
 }
+
+func TestReadDoc(t *testing.T) {
+	// Both encodings are read from readers
+	for _, path := range []string{"testdata/images.spdx.json", "testdata/nginx.spdx"} {
+		opened, err := OpenDoc(path)
+		require.NoError(t, err, path)
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		doc, err := ReadDoc(f)
+		f.Close()
+		require.NoError(t, err, path)
+		require.Equal(t, opened.Name, doc.Name, path)
+		require.Len(t, doc.Packages, len(opened.Packages), path)
+		for id, pkg := range opened.Packages {
+			require.Contains(t, doc.Packages, id, path)
+			require.Len(t, doc.Packages[id].Relationships, len(pkg.Relationships), path)
+		}
+	}
+
+	// Malformed documents point to the element failing
+	for _, tc := range []struct {
+		data string
+		err  string
+	}{
+		{
+			data: "{\n  \"spdxVersion\": \"SPDX-2.3\",\n  \"packages\": [\n    {\"name\": \"foo\",}\n  ]\n}\n",
+			err:  "at line 4",
+		},
+		{
+			data: "{\n  \"spdxVersion\": \"SPDX-2.3\",\n  \"packages\": [\n    {\"name\": 1}\n  ]\n}\n",
+			err:  "at line 4",
+		},
+		{
+			data: `{"spdxVersion": "SPDX-2.3", "packages": [{"SPDXID": "SPDXRef-Package-a", "name": "a"}, {"name": "b"}]}`,
+			err:  `package #2 ("b") has no SPDXID`,
+		},
+		{
+			data: `{"spdxVersion": "SPDX-2.3", "packages": [{"SPDXID": "SPDXRef-a", "name": "a"}], "files": [{"SPDXID": "SPDXRef-a", "fileName": "a.txt"}]}`,
+			err:  `file #1 ("a.txt") has duplicate SPDXID SPDXRef-a`,
+		},
+		{
+			data: "not an SBOM",
+			err:  "unknown SBOM encoding",
+		},
+	} {
+		_, err := ReadDoc(strings.NewReader(tc.data))
+		require.Error(t, err, tc.data)
+		require.Contains(t, err.Error(), tc.err, tc.data)
+	}
+}
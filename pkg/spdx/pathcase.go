@@ -0,0 +1,93 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+)
+
+// caseInsensitivePaths returns true if the paths in dirPath have to be
+// compared ignoring case, either because the options ask for it or
+// because the directory is on a case-insensitive filesystem
+func caseInsensitivePaths(opts *Options, dirPath string) bool {
+	if opts != nil && opts.CaseInsensitivePaths {
+		return true
+	}
+	return isCaseInsensitiveFS(dirPath)
+}
+
+// isCaseInsensitiveFS detects if dirPath is on a case-insensitive
+// filesystem (as usual on macOS and Windows) by looking up one of its
+// entries with the case of its name swapped. Nothing is written to the
+// directory, so directories without entries with letters in their names
+// are reported as case sensitive.
+func isCaseInsensitiveFS(dirPath string) bool {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		swapped := swapCase(e.Name())
+		if swapped == e.Name() {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(dirPath, e.Name()))
+		if err != nil {
+			return false
+		}
+		swappedInfo, err := os.Lstat(filepath.Join(dirPath, swapped))
+		if err != nil {
+			return false
+		}
+		// Both names exist, they are the same file unless the
+		// directory has entries differing only in case
+		return os.SameFile(info, swappedInfo)
+	}
+	return false
+}
+
+// swapCase returns s with the case of its letters swapped
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// dedupeFoldedPaths removes from a list of paths those equal to a
+// previous path when compared ignoring case, logging the skipped ones to log
+func dedupeFoldedPaths(log logrus.FieldLogger, paths []string) []string {
+	seen := map[string]struct{}{}
+	deduped := []string{}
+	for _, p := range paths {
+		key := strings.ToLower(p)
+		if _, ok := seen[key]; ok {
+			log.Debugf("Skipping %s, path already listed with a different case", p)
+			continue
+		}
+		seen[key] = struct{}{}
+		deduped = append(deduped, p)
+	}
+	return deduped
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Docs"), os.FileMode(0o755)))
+	for _, f := range []string{"README", "readme", "main.go", "Docs/Notes.TXT"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, f), []byte(f), os.FileMode(0o644)))
+	}
+	insensitiveFS := isCaseInsensitiveFS(dir)
+
+	impl := spdxDefaultImplementation{}
+	tree, err := impl.GetDirectoryTree(dir, false, false)
+	require.NoError(t, err)
+	if insensitiveFS {
+		// README and readme were written to the same file
+		require.Len(t, tree, 3)
+	} else {
+		require.Len(t, tree, 4)
+	}
+
+	// Case variants are listed once when ignoring case
+	tree, err = impl.GetDirectoryTree(dir, true, false)
+	require.NoError(t, err)
+	require.Len(t, tree, 3)
+
+	// Ignore patterns match any case variant
+	patterns, err := impl.IgnorePatterns(dir, []string{"docs/*.txt", "ReadMe"}, true, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"main.go"}, impl.ApplyIgnorePatterns(tree, patterns, true))
+
+	patterns, err = impl.IgnorePatterns(dir, []string{"docs/*.txt", "ReadMe"}, true, false)
+	require.NoError(t, err)
+	require.Len(t, impl.ApplyIgnorePatterns(tree, patterns, false), 3)
+
+	// The option forces case-insensitive paths on any filesystem
+	require.True(t, caseInsensitivePaths(&Options{CaseInsensitivePaths: true}, dir))
+	require.Equal(t, insensitiveFS, caseInsensitivePaths(&Options{}, dir))
+}
+
+func TestIsCaseInsensitiveFS(t *testing.T) {
+	dir := t.TempDir()
+	require.False(t, isCaseInsensitiveFS(dir), "empty directories are reported as case sensitive")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "File.txt"), []byte("test"), os.FileMode(0o644)))
+	_, err := os.Stat(filepath.Join(dir, "fILE.TXT"))
+	if err != nil {
+		require.False(t, isCaseInsensitiveFS(dir))
+
+		// Entries differing only in case are different files
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "fILE.TXT"), []byte("test"), os.FileMode(0o644)))
+		require.False(t, isCaseInsensitiveFS(dir))
+		return
+	}
+	require.True(t, isCaseInsensitiveFS(dir))
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// knownImageOS and knownImageArch are the operating systems and
+// architectures accepted in the platforms of the options. They are the
+// GOOS and GOARCH values, which the OCI image spec uses.
+var (
+	knownImageOS = map[string]struct{}{
+		"aix": {}, "android": {}, "darwin": {}, "dragonfly": {}, "freebsd": {},
+		"illumos": {}, "ios": {}, "js": {}, "linux": {}, "netbsd": {},
+		"openbsd": {}, "plan9": {}, "solaris": {}, "wasip1": {}, "windows": {},
+	}
+	knownImageArch = map[string]struct{}{
+		"386": {}, "amd64": {}, "arm": {}, "arm64": {}, "loong64": {},
+		"mips": {}, "mips64": {}, "mips64le": {}, "mipsle": {}, "ppc64": {},
+		"ppc64le": {}, "riscv64": {}, "s390x": {}, "wasm": {},
+	}
+)
+
+// platformSpec is a platform selected in the options
+type platformSpec struct {
+	OS      string
+	Arch    string
+	Variant string
+}
+
+// parseImagePlatforms parses platforms written as os/arch or
+// os/arch/variant (eg linux/arm64 or linux/arm/v7)
+func parseImagePlatforms(platforms []string) ([]platformSpec, error) {
+	ret := []platformSpec{}
+	for _, s := range platforms {
+		parts := strings.Split(s, "/")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid platform %q, expected os/arch or os/arch/variant", s)
+		}
+		p := platformSpec{OS: parts[0], Arch: parts[1]}
+		if len(parts) == 3 {
+			if parts[2] == "" {
+				return nil, fmt.Errorf("invalid platform %q, the variant is empty", s)
+			}
+			p.Variant = parts[2]
+		}
+		if _, ok := knownImageOS[p.OS]; !ok {
+			return nil, fmt.Errorf("invalid platform %q, unknown operating system %q", s, p.OS)
+		}
+		if _, ok := knownImageArch[p.Arch]; !ok {
+			return nil, fmt.Errorf("invalid platform %q, unknown architecture %q", s, p.Arch)
+		}
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+// matches returns true if an image of an index is for the platform. The
+// variant is only compared when the platform names one.
+func (p platformSpec) matches(img *ImageReferenceInfo) bool {
+	if p.OS != img.OS || p.Arch != img.Arch {
+		return false
+	}
+	return p.Variant == "" || p.Variant == img.Variant
+}
+
+// filterImagePlatforms returns the references of an index keeping only the
+// images of the platforms in the options. The references are cached with
+// all the images of the index, so they are filtered after reading them.
+func filterImagePlatforms(opts *Options, references *ImageReferenceInfo) (*ImageReferenceInfo, error) {
+	if opts == nil || len(opts.Platforms) == 0 || len(references.Images) == 0 {
+		return references, nil
+	}
+	platforms, err := parseImagePlatforms(opts.Platforms)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := *references
+	filtered.Images = []ImageReferenceInfo{}
+	for i := range references.Images {
+		for _, p := range platforms {
+			if p.matches(&references.Images[i]) {
+				filtered.Images = append(filtered.Images, references.Images[i])
+				break
+			}
+		}
+	}
+	logger(opts).Infof(
+		"Pulling %d of the %d images in the index for platforms %s",
+		len(filtered.Images), len(references.Images), strings.Join(opts.Platforms, ", "),
+	)
+	return &filtered, nil
+}
+
+// checkImagePlatform returns an error when the options select platforms
+// and the single image img is for none of them. Images whose platform is
+// unknown are not checked.
+func checkImagePlatform(opts *Options, img *ImageReferenceInfo) error {
+	if opts == nil || len(opts.Platforms) == 0 || len(img.Images) != 0 || (img.OS == "" && img.Arch == "") {
+		return nil
+	}
+	platforms, err := parseImagePlatforms(opts.Platforms)
+	if err != nil {
+		return err
+	}
+	for _, p := range platforms {
+		if p.matches(img) {
+			return nil
+		}
+	}
+	platform := img.OS + "/" + img.Arch
+	if img.Variant != "" {
+		platform += "/" + img.Variant
+	}
+	return fmt.Errorf(
+		"image %s is for platform %s, not for platforms %s",
+		img.Digest, platform, strings.Join(opts.Platforms, ", "),
+	)
+}
+
+// pullPlatform returns the platform selected in the options to pull a
+// single image from an index, or nil when none is selected
+func pullPlatform(opts *Options) (*v1.Platform, error) {
+	if opts == nil || len(opts.Platforms) == 0 {
+		return nil, nil
+	}
+	platforms, err := parseImagePlatforms(opts.Platforms)
+	if err != nil {
+		return nil, err
+	}
+	if len(platforms) > 1 {
+		return nil, fmt.Errorf("a single image is pulled, select one platform instead of %s", strings.Join(opts.Platforms, ", "))
+	}
+	return &v1.Platform{OS: platforms[0].OS, Architecture: platforms[0].Arch, Variant: platforms[0].Variant}, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullImagesToArchivePlatforms(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	var index v1.ImageIndex = empty.Index
+	for _, p := range []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "linux", Architecture: "arm", Variant: "v7"},
+		{OS: "linux", Architecture: "s390x"},
+	} {
+		img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{OS: p.OS, Architecture: p.Architecture, Variant: p.Variant})
+		require.NoError(t, err)
+		p := p
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add: img, Descriptor: v1.Descriptor{Platform: &p},
+		})
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/index:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, index))
+
+	// platformsPulled returns the os/arch/variant of the images pulled
+	platformsPulled := func(platforms ...string) ([]string, error) {
+		impl := spdxDefaultImplementation{}
+		references, err := impl.PullImagesToArchive(
+			context.Background(), &Options{Platforms: platforms}, ref.String(), t.TempDir(),
+		)
+		if err != nil {
+			return nil, err
+		}
+		pulled := []string{}
+		for _, img := range references.Images {
+			require.FileExists(t, img.Archive)
+			pulled = append(pulled, strings.TrimSuffix(img.OS+"/"+img.Arch+"/"+img.Variant, "/"))
+		}
+		sort.Strings(pulled)
+		return pulled, nil
+	}
+
+	pulled, err := platformsPulled()
+	require.NoError(t, err)
+	require.Equal(t, []string{"linux/amd64", "linux/arm/v7", "linux/arm64", "linux/s390x"}, pulled)
+
+	pulled, err = platformsPulled("linux/amd64", "linux/arm64")
+	require.NoError(t, err)
+	require.Equal(t, []string{"linux/amd64", "linux/arm64"}, pulled)
+
+	pulled, err = platformsPulled("linux/arm")
+	require.NoError(t, err)
+	require.Equal(t, []string{"linux/arm/v7"}, pulled)
+
+	_, err = platformsPulled("linux/arm/v6")
+	require.Error(t, err)
+
+	for _, invalid := range []string{"amd64", "linux/x86_64", "plan10/amd64", "linux/arm/", "linux/arm/v7/x"} {
+		_, err = platformsPulled(invalid)
+		require.Error(t, err, invalid)
+		require.Contains(t, err.Error(), "invalid platform", invalid)
+	}
+
+	// Single images of other platforms are rejected
+	manifest, err := index.IndexManifest()
+	require.NoError(t, err)
+	arm64Ref := ref.Context().Digest(manifest.Manifests[1].Digest.String()).String()
+	impl := spdxDefaultImplementation{}
+	_, err = impl.PullImagesToArchive(context.Background(), &Options{Platforms: []string{"linux/arm64"}}, arm64Ref, t.TempDir())
+	require.NoError(t, err)
+	_, err = impl.PullImagesToArchive(context.Background(), &Options{Platforms: []string{"linux/amd64"}}, arm64Ref, t.TempDir())
+	require.ErrorContains(t, err, "is for platform linux/arm64")
+
+	// Pulling a single image from the index takes the selected platform
+	tarPath := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, PullImageToArchiveWithOptions(
+		context.Background(), &Options{Platforms: []string{"linux/s390x"}}, ref.String(), tarPath,
+	))
+	img, err := tarball.ImageFromPath(tarPath, nil)
+	require.NoError(t, err)
+	config, err := img.ConfigFile()
+	require.NoError(t, err)
+	require.Equal(t, "s390x", config.Architecture)
+	require.Error(t, PullImageToArchiveWithOptions(
+		context.Background(), &Options{Platforms: []string{"linux/s390x", "linux/arm64"}}, ref.String(), tarPath,
+	))
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import "sync"
+
+// Labels of the phases reported in the progress events
+const (
+	ProgressPhaseDownload    = "download"     // Image archives pulled from registries
+	ProgressPhaseLayerScan   = "layer-scan"   // Image layers scanned
+	ProgressPhaseFileScan    = "file-scan"    // Files of a directory checksummed and classified
+	ProgressPhaseLicenseScan = "license-scan" // Go packages scanned for licenses
+)
+
+// ProgressEvent reports that an item of a long-running phase completed
+type ProgressEvent struct {
+	Phase   string // Phase of the scan, one of the ProgressPhase labels
+	Current int    // Number of items completed so far, including this one
+	Total   int    // Number of items the phase will process
+}
+
+// progressCounter counts the items completed in a phase, reporting each
+// one to the callback. Events are delivered one at a time and in order,
+// so the callback does not need to be safe for concurrent use.
+type progressCounter struct {
+	fn      func(ProgressEvent)
+	phase   string
+	total   int
+	current int
+	mtx     sync.Mutex
+}
+
+// newProgressCounter returns the counter of a phase. It is nil, which
+// reports nothing, when the callback is not set.
+func newProgressCounter(fn func(ProgressEvent), phase string, total int) *progressCounter {
+	if fn == nil {
+		return nil
+	}
+	return &progressCounter{fn: fn, phase: phase, total: total}
+}
+
+// progressFn returns the progress callback of the options, which may
+// be nil
+func progressFn(opts *Options) func(ProgressEvent) {
+	if opts == nil {
+		return nil
+	}
+	return opts.ProgressFn
+}
+
+// done records an item as completed
+func (pc *progressCounter) done() {
+	if pc == nil {
+		return
+	}
+	pc.mtx.Lock()
+	defer pc.mtx.Unlock()
+	pc.current++
+	pc.fn(ProgressEvent{Phase: pc.phase, Current: pc.current, Total: pc.total})
+}
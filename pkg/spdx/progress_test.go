@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromDirectoryProgress(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 12; i++ {
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, fmt.Sprintf("file-%02d.txt", i)), []byte("contents\n"), os.FileMode(0o644),
+		))
+	}
+
+	// Events are delivered one at a time, so no lock is needed here
+	events := []ProgressEvent{}
+	impl := spdxDefaultImplementation{}
+	_, err := impl.PackageFromDirectory(&Options{
+		ProgressFn: func(e ProgressEvent) { events = append(events, e) },
+	}, dir)
+	require.NoError(t, err)
+	require.Len(t, events, 12)
+	for i, e := range events {
+		require.Equal(t, ProgressEvent{Phase: ProgressPhaseFileScan, Current: i + 1, Total: 12}, e)
+	}
+
+	// Without a callback nothing is reported
+	pc := newProgressCounter(nil, ProgressPhaseFileScan, 12)
+	require.Nil(t, pc)
+	pc.done()
+}
+
+func TestImagePullProgress(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{OS: "linux", Architecture: "amd64"})
+	require.NoError(t, err)
+	index := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+	})
+	repo := strings.TrimPrefix(server.URL, "http://") + "/test/"
+	imageRef, err := name.ParseReference(repo + "image:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(imageRef, img))
+	indexRef, err := name.ParseReference(repo + "index:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(indexRef, index))
+
+	// Pulls without options report nothing
+	impl := spdxDefaultImplementation{}
+	for _, ref := range []name.Reference{imageRef, indexRef} {
+		_, err := impl.PullImagesToArchive(context.Background(), nil, ref.String(), t.TempDir())
+		require.NoError(t, err)
+	}
+
+	// Single images are reported as one download, as indexes with one image
+	for _, ref := range []name.Reference{imageRef, indexRef} {
+		events := []ProgressEvent{}
+		opts := &Options{ProgressFn: func(e ProgressEvent) { events = append(events, e) }}
+		_, err := impl.PullImagesToArchive(context.Background(), opts, ref.String(), t.TempDir())
+		require.NoError(t, err)
+		require.Equal(t, []ProgressEvent{{Phase: ProgressPhaseDownload, Current: 1, Total: 1}}, events, ref.String())
+	}
+}
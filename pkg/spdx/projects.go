@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/release-utils/util"
+)
+
+// projectManifests are the files marking the root of a project
+var projectManifests = []string{
+	GoModFileName, "package.json", "Cargo.toml", "pyproject.toml", "setup.py", "pom.xml", "build.gradle",
+}
+
+// projectSkipDirs are directories holding the manifests of dependencies
+// or tool data, never the root of a project
+var projectSkipDirs = map[string]struct{}{
+	".git": {}, "node_modules": {}, "vendor": {},
+}
+
+// FindProjectRoots walks dirPath and returns the directories containing
+// a project manifest (go.mod, package.json, Cargo.toml...). The paths are
+// returned sorted, dirPath itself is included when it is a project.
+func FindProjectRoots(dirPath string) ([]string, error) {
+	roots := []string{}
+	if err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if _, ok := projectSkipDirs[d.Name()]; ok && path != dirPath {
+			return filepath.SkipDir
+		}
+		for _, manifest := range projectManifests {
+			if util.Exists(filepath.Join(path, manifest)) {
+				roots = append(roots, path)
+				break
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("searching for project roots: %w", err)
+	}
+	sort.Strings(roots)
+	return roots, nil
+}
+
+// nestedProjectPatterns returns the ignore patterns excluding the projects
+// nested in root from its package, so each file is only listed once
+func nestedProjectPatterns(root string, roots []string) []string {
+	patterns := []string{}
+	for _, r := range roots {
+		rel, err := filepath.Rel(root, r)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		patterns = append(patterns, "/"+filepath.ToSlash(rel)+"/")
+	}
+	return patterns
+}
+
+// PackagesFromProjects returns a package for each project found in dirPath
+// instead of a single package for the whole tree. Projects nested in others
+// get their own package and are left out of the enclosing one. Files not
+// belonging to any project are not listed. When no project is found, the
+// directory is returned as a single package.
+func (spdx *SPDX) PackagesFromProjects(dirPath string) ([]*Package, error) {
+	roots, err := FindProjectRoots(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
+		pkg, err := spdx.PackageFromDirectory(dirPath)
+		if err != nil {
+			return nil, err
+		}
+		return []*Package{pkg}, nil
+	}
+
+	packages := []*Package{}
+	for _, root := range roots {
+		opts := *spdx.Options()
+		opts.IgnorePatterns = append(
+			append([]string{}, opts.IgnorePatterns...), nestedProjectPatterns(root, roots)...,
+		)
+		pkg, err := spdx.packageFromDirectory(&opts, root)
+		if err != nil {
+			return nil, fmt.Errorf("generating package for project %s: %w", root, err)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
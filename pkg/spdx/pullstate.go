@@ -0,0 +1,154 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pullStateDir is the directory, under the user cache directory, of the
+// files recording the archives completely written to the directories
+// images are pulled to. A pull interrupted (eg by a network failure or the
+// process being killed) only downloads the images missing when it is run
+// again. The state is kept out of the pull directories, which belong to
+// the user.
+const pullStateDir = "bom/pull-state"
+
+// pullStatePath returns the path of the file with the state of the pulls
+// to dir, named by the digest of its absolute path. It is under the user
+// cache directory or, when there is none, the temporary directory.
+func pullStatePath(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(dir))
+	return filepath.Join(base, filepath.FromSlash(pullStateDir), hex.EncodeToString(sum[:])+".json")
+}
+
+// pullState lists the image archives pulled to a directory by the digest
+// of their images. It is safe for concurrent use.
+type pullState struct {
+	mu       sync.Mutex
+	path     string
+	log      logrus.FieldLogger
+	Archives map[string]pulledArchive `json:"archives"`
+}
+
+// pulledArchive records an archive written, to check it is still complete
+// and unmodified before reusing it
+type pulledArchive struct {
+	File   string `json:"file"`   // Name of the archive in the directory
+	Size   int64  `json:"size"`   // Size of the archive
+	SHA256 string `json:"sha256"` // Digest of the archive file
+}
+
+// readPullState reads the state of the pulls to dir. A missing or
+// unreadable state file starts an empty state.
+func readPullState(log logrus.FieldLogger, dir string) *pullState {
+	ps := &pullState{
+		path:     pullStatePath(dir),
+		log:      log,
+		Archives: map[string]pulledArchive{},
+	}
+	data, err := os.ReadFile(ps.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("Reading the state of previous pulls: %v", err)
+		}
+		return ps
+	}
+	if err := json.Unmarshal(data, ps); err != nil || ps.Archives == nil {
+		log.Warnf("Ignoring the state of previous pulls in %s, it is not valid", ps.path)
+		ps.Archives = map[string]pulledArchive{}
+	}
+	return ps
+}
+
+// completed returns true when the archive of the image with the digest was
+// completely written to tarPath by a previous pull and did not change since
+func (ps *pullState) completed(digest, tarPath string) bool {
+	if ps == nil {
+		return false
+	}
+	ps.mu.Lock()
+	archive, ok := ps.Archives[digest]
+	ps.mu.Unlock()
+	if !ok || archive.File != filepath.Base(tarPath) {
+		return false
+	}
+	size, sum, err := hashArchive(tarPath)
+	if err != nil || size != archive.Size || sum != archive.SHA256 {
+		ps.log.Warnf("Pulling %s again, its archive changed since it was pulled", digest)
+		return false
+	}
+	return true
+}
+
+// record adds the archive of the image with the digest to the state,
+// writing the state file
+func (ps *pullState) record(digest, tarPath string) error {
+	if ps == nil {
+		return nil
+	}
+	size, sum, err := hashArchive(tarPath)
+	if err != nil {
+		return fmt.Errorf("hashing archive: %w", err)
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.Archives[digest] = pulledArchive{File: filepath.Base(tarPath), Size: size, SHA256: sum}
+	if err := os.MkdirAll(filepath.Dir(ps.path), os.FileMode(0o755)); err != nil {
+		return fmt.Errorf("creating pull state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling pull state: %w", err)
+	}
+	return writeFileAtomic(ps.path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// hashArchive returns the size and the hex SHA256 digest of a file
+func hashArchive(path string) (size int64, sum string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err = io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullImagesToArchiveResume(t *testing.T) {
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	layerDigest, err := layer.Digest()
+	require.NoError(t, err)
+
+	// Only the layer is counted to know how many images were downloaded
+	var blobRequests atomic.Int32
+	regHandler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/blobs/"+layerDigest.String()) {
+			blobRequests.Add(1)
+		}
+		regHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	var index v1.ImageIndex = empty.Index
+	for _, arch := range []string{"amd64", "arm64"} {
+		img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{OS: "linux", Architecture: arch})
+		require.NoError(t, err)
+		img, err = mutate.AppendLayers(img, layer)
+		require.NoError(t, err)
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: arch}},
+		})
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/index:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, index))
+
+	// The state is kept in the cache directory, not with the archives
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := t.TempDir()
+	pull := func() *ImageReferenceInfo {
+		blobRequests.Store(0)
+		impl := spdxDefaultImplementation{}
+		refs, err := impl.PullImagesToArchive(context.Background(), &Options{}, ref.String(), dir)
+		require.NoError(t, err)
+		require.Len(t, refs.Images, 2)
+		for _, img := range refs.Images {
+			_, err := tarball.ImageFromPath(img.Archive, nil)
+			require.NoError(t, err)
+		}
+		return refs
+	}
+
+	// The first pull downloads both images and records them
+	refs := pull()
+	require.EqualValues(t, 2, blobRequests.Load())
+	state := readPullState(logger(nil), dir)
+	require.Len(t, state.Archives, 2)
+	require.FileExists(t, pullStatePath(dir))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// Running it again reuses the archives
+	pull()
+	require.Zero(t, blobRequests.Load())
+
+	// Only the archives that changed since are pulled again
+	f, err := os.OpenFile(refs.Images[0].Archive, os.O_WRONLY|os.O_TRUNC, os.FileMode(0o644))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	pull()
+	require.EqualValues(t, 1, blobRequests.Load())
+	pull()
+	require.Zero(t, blobRequests.Load())
+
+	// Without a valid state everything is downloaded
+	require.NoError(t, os.WriteFile(pullStatePath(dir), []byte("not json"), os.FileMode(0o644)))
+	pull()
+	require.EqualValues(t, 2, blobRequests.Load())
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+
+	purl "github.com/package-url/packageurl-go"
+)
+
+// PurlBuilder customizes the package URL of the packages generated by bom.
+// It is called with each package and the purl bom built for it (nil when
+// none could be built) and returns the purl to record in the package.
+// Returning nil leaves the package without a purl.
+type PurlBuilder func(pkg *Package, defaultPurl *purl.PackageURL) *purl.PackageURL
+
+// applyPurlBuilder runs the purl builder from the options on pkg and on
+// all the packages related to it, replacing their purl external refs
+func applyPurlBuilder(opts *Options, pkg *Package) {
+	if opts == nil || opts.PurlBuilder == nil || pkg == nil {
+		return
+	}
+	seen := map[*Package]struct{}{}
+	var apply func(*Package)
+	apply = func(p *Package) {
+		if _, ok := seen[p]; ok {
+			return
+		}
+		seen[p] = struct{}{}
+
+		customPurl := opts.PurlBuilder(p, p.Purl())
+		refs := []ExternalRef{}
+		for _, er := range p.ExternalRefs {
+			if (er.Category == CatPackageManager || er.Category == "PACKAGE_MANAGER") && er.Type == "purl" {
+				continue
+			}
+			refs = append(refs, er)
+		}
+		if customPurl != nil {
+			refs = append(refs, ExternalRef{
+				Category: CatPackageManager,
+				Type:     "purl",
+				Locator:  customPurl.ToString(),
+			})
+		}
+		p.ExternalRefs = refs
+
+		for _, rel := range p.Relationships {
+			if peer, ok := rel.Peer.(*Package); ok && peer != nil {
+				apply(peer)
+			}
+		}
+	}
+	apply(pkg)
+}
+
+// parsePackagePurl parses the purl set in the options for the package of
+// a scanned directory. It returns nil when the options set none.
+func parsePackagePurl(packagePurl string) (*purl.PackageURL, error) {
+	if packagePurl == "" {
+		return nil, nil
+	}
+	p, err := purl.FromString(packagePurl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing package purl %q: %w", packagePurl, err)
+	}
+	if p.Type == "" || p.Name == "" {
+		return nil, fmt.Errorf("invalid package purl %q, it has no type or name", packagePurl)
+	}
+	return &p, nil
+}
+
+// setPackagePurl replaces the purl external refs of pkg with packagePurl,
+// if it is not nil
+func setPackagePurl(pkg *Package, packagePurl *purl.PackageURL) {
+	if packagePurl == nil {
+		return
+	}
+	refs := []ExternalRef{}
+	for _, er := range pkg.ExternalRefs {
+		if (er.Category == CatPackageManager || er.Category == "PACKAGE_MANAGER") && er.Type == "purl" {
+			continue
+		}
+		refs = append(refs, er)
+	}
+	pkg.ExternalRefs = append(refs, ExternalRef{
+		Category: CatPackageManager,
+		Type:     "purl",
+		Locator:  packagePurl.ToString(),
+	})
+	if pkg.Version == "" {
+		pkg.Version = packagePurl.Version
+	}
+}
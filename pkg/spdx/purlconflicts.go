@@ -0,0 +1,96 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidatePurls looks for packages in the document sharing a purl while
+// recording different names or versions, either because of a bug when
+// generating the SBOM or a genuine conflict between the packages. It
+// returns a warning for each conflicting purl, sorted by purl. Packages
+// without a version do not conflict on it.
+func (d *Document) ValidatePurls() []Warning {
+	byPurl := map[string][]*Package{}
+	seen := map[*Package]struct{}{}
+	var collect func(*Package)
+	collect = func(p *Package) {
+		if _, ok := seen[p]; ok {
+			return
+		}
+		seen[p] = struct{}{}
+		if pu := p.Purl(); pu != nil {
+			key := pu.ToString()
+			byPurl[key] = append(byPurl[key], p)
+		}
+		for _, rel := range p.Relationships {
+			if peer, ok := rel.Peer.(*Package); ok && peer != nil {
+				collect(peer)
+			}
+		}
+	}
+	for _, id := range d.sortedPackageIDs() {
+		collect(d.Packages[id])
+	}
+
+	keys := make([]string, 0, len(byPurl))
+	for key := range byPurl {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	warnings := []Warning{}
+	for _, key := range keys {
+		packages := byPurl[key]
+		if len(packages) < 2 {
+			continue
+		}
+		ids := []string{}
+		names := map[string]struct{}{}
+		versions := map[string]struct{}{}
+		for _, p := range packages {
+			ids = append(ids, p.SPDXID())
+			names[p.Name] = struct{}{}
+			if p.Version != "" && !noAssertion(p.Version) {
+				versions[p.Version] = struct{}{}
+			}
+		}
+		sort.Strings(ids)
+
+		conflicts := []string{}
+		if len(names) > 1 {
+			conflicts = append(conflicts, "names "+strings.Join(sortedKeys(names), ", "))
+		}
+		if len(versions) > 1 {
+			conflicts = append(conflicts, "versions "+strings.Join(sortedKeys(versions), ", "))
+		}
+		if len(conflicts) == 0 {
+			continue
+		}
+		warnings = append(warnings, Warning{
+			Element: key,
+			Message: fmt.Sprintf(
+				"purl is shared by packages %s with conflicting %s",
+				strings.Join(ids, ", "), strings.Join(conflicts, " and "),
+			),
+		})
+	}
+	return warnings
+}
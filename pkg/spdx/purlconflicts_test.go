@@ -0,0 +1,63 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePurls(t *testing.T) {
+	newPkg := func(id, name, version, purlString string) *Package {
+		p := NewPackage()
+		p.Name = name
+		p.Version = version
+		p.BuildID(id)
+		p.ExternalRefs = []ExternalRef{{Category: CatPackageManager, Type: "purl", Locator: purlString}}
+		return p
+	}
+
+	doc := NewDocument()
+	root := newPkg("root", "root", "1.0.0", "pkg:generic/root@1.0.0")
+	require.NoError(t, doc.AddPackage(root))
+
+	// Same purl, different versions
+	require.NoError(t, root.AddPackage(newPkg("zlib-a", "zlib", "1.2.13", "pkg:deb/debian/zlib@1.2.13")))
+	require.NoError(t, root.AddPackage(newPkg("zlib-b", "zlib", "1.3", "pkg:deb/debian/zlib@1.2.13")))
+
+	// Genuine duplicates do not conflict
+	require.NoError(t, root.AddPackage(newPkg("curl-a", "curl", "8.0.1", "pkg:deb/debian/curl@8.0.1")))
+	require.NoError(t, root.AddPackage(newPkg("curl-b", "curl", "8.0.1", "pkg:deb/debian/curl@8.0.1")))
+
+	// A missing version is not a conflict, a different name is
+	require.NoError(t, root.AddPackage(newPkg("ssl-a", "openssl", "", "pkg:deb/debian/openssl@3.0.9")))
+	require.NoError(t, root.AddPackage(newPkg("ssl-b", "libssl3", "3.0.9", "pkg:deb/debian/openssl@3.0.9")))
+
+	warnings := doc.ValidatePurls()
+	require.Len(t, warnings, 2)
+	require.Equal(t, "pkg:deb/debian/openssl@3.0.9", warnings[0].Element)
+	require.Equal(t,
+		"purl is shared by packages SPDXRef-Package-ssl-a, SPDXRef-Package-ssl-b with conflicting names libssl3, openssl",
+		warnings[0].Message,
+	)
+	require.Equal(t, "pkg:deb/debian/zlib@1.2.13", warnings[1].Element)
+	require.Equal(t,
+		"purl is shared by packages SPDXRef-Package-zlib-a, SPDXRef-Package-zlib-b with conflicting versions 1.2.13, 1.3",
+		warnings[1].Message,
+	)
+}
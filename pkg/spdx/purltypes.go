@@ -0,0 +1,48 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	purl "github.com/package-url/packageurl-go"
+)
+
+// purlType returns the purl type of an image or OCI artifact, looked up by
+// its artifact type and then by its media type in the PurlTypes of the
+// options. The purl spec has no types for the artifacts stored in
+// registries other than oci, so by default all of them get the oci type
+// and are told apart by their mediaType qualifier.
+func purlType(opts *Options, img *ImageReferenceInfo) string {
+	if opts == nil {
+		return purl.TypeOCI
+	}
+	for _, mediaType := range []string{img.ArtifactType, img.MediaType} {
+		if t, ok := opts.PurlTypes[mediaType]; ok && mediaType != "" && t != "" {
+			return t
+		}
+	}
+	return purl.TypeOCI
+}
+
+// purlMediaType returns the value of the mediaType qualifier of the purl
+// of an image or artifact: its artifact type, as artifacts share the
+// manifest media type of images, or its media type.
+func purlMediaType(img *ImageReferenceInfo) string {
+	if img.ArtifactType != "" {
+		return img.ArtifactType
+	}
+	return img.MediaType
+}
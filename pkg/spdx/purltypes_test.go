@@ -0,0 +1,60 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurlFromImagePurlTypes(t *testing.T) {
+	hash := "sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57"
+	helmChart := ImageReferenceInfo{
+		Digest:       "registry.example.com/charts/web@" + hash,
+		MediaType:    "application/vnd.oci.image.manifest.v1+json",
+		ArtifactType: "application/vnd.cncf.helm.config.v1+json",
+	}
+	image := ImageReferenceInfo{
+		Digest:    "registry.example.com/app/web@" + hash,
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+	}
+	impl := spdxDefaultImplementation{}
+
+	// Images keep the oci type, artifacts get their type as mediaType
+	require.Equal(
+		t, "pkg:oci/web@"+hash+"?mediaType=application%2Fvnd.oci.image.manifest.v1+json&repository_url=registry.example.com%2Fapp",
+		impl.purlFromImage(context.Background(), nil, &image),
+	)
+	require.Equal(
+		t, "pkg:oci/web@"+hash+"?mediaType=application%2Fvnd.cncf.helm.config.v1+json&repository_url=registry.example.com%2Fcharts",
+		impl.purlFromImage(context.Background(), nil, &helmChart),
+	)
+
+	// The options override the defaults, the artifact type first
+	opts := &Options{PurlTypes: map[string]string{
+		"application/vnd.cncf.helm.config.v1+json":   "helm",
+		"application/vnd.oci.image.manifest.v1+json": "generic",
+	}}
+	require.True(t, strings.HasPrefix(impl.purlFromImage(context.Background(), opts, &helmChart), "pkg:helm/web@"))
+	require.True(t, strings.HasPrefix(impl.purlFromImage(context.Background(), opts, &image), "pkg:generic/web@"))
+
+	// Unknown types are oci
+	require.Equal(t, "oci", purlType(opts, &ImageReferenceInfo{MediaType: "application/x-unknown"}))
+}
@@ -0,0 +1,520 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	purl "github.com/package-url/packageurl-go"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/release-utils/util"
+
+	"sigs.k8s.io/bom/pkg/license"
+)
+
+const (
+	PoetryLockFileName         = "poetry.lock"
+	PipfileLockFileName        = "Pipfile.lock"
+	PythonRequirementsFileName = "requirements.txt"
+
+	// pyprojectFileName and pipfileFileName declare the direct dependencies
+	// of the projects locked in poetry.lock and Pipfile.lock
+	pyprojectFileName = "pyproject.toml"
+	pipfileFileName   = "Pipfile"
+)
+
+// pythonDependencyFiles are the files listing the dependencies of python
+// projects, in order of preference
+var pythonDependencyFiles = []string{PoetryLockFileName, PipfileLockFileName, PythonRequirementsFileName}
+
+var (
+	// pythonRequirementRe matches the name of a PEP 508 requirement and
+	// splits it from the extras and the version specifiers
+	pythonRequirementRe = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?\s*([<>=!~@(].*)?$`)
+
+	// pythonNameSeparatorsRe matches the separators normalized in names
+	pythonNameSeparatorsRe = regexp.MustCompile(`[-_.]+`)
+)
+
+// PythonPackage is a dependency of a python project
+type PythonPackage struct {
+	Name    string // Name of the package as written in the dependency file
+	Version string // Pinned version, empty when the requirement is not pinned
+	License string // License read from the installed package metadata
+	Direct  bool   // The project declares the dependency
+}
+
+// normalizePythonName normalizes a package name as PEP 503 does, which is
+// also how pypi purls write them
+func normalizePythonName(name string) string {
+	return pythonNameSeparatorsRe.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// PackageURL returns a purl representing the python package
+func (pkg *PythonPackage) PackageURL() string {
+	if pkg.Name == "" {
+		return ""
+	}
+	return purl.NewPackageURL(
+		purl.TypePyPi, "", normalizePythonName(pkg.Name), pkg.Version, nil, "",
+	).ToString()
+}
+
+// ToSPDXPackage builds a SPDX package from the python dependency
+func (pkg *PythonPackage) ToSPDXPackage() (*Package, error) {
+	if pkg.Name == "" {
+		return nil, fmt.Errorf("python package has no name")
+	}
+	spdxPackage := NewPackage()
+	spdxPackage.Options().Prefix = "pypi"
+	spdxPackage.Name = pkg.Name
+	spdxPackage.Version = pkg.Version
+	spdxPackage.BuildID(pkg.Name, pkg.Version)
+	spdxPackage.DownloadLocation = NOASSERTION
+	if pkg.License != "" {
+		spdxPackage.LicenseDeclared = pkg.License
+	}
+	if packageurl := pkg.PackageURL(); packageurl != "" {
+		spdxPackage.ExternalRefs = append(spdxPackage.ExternalRefs, ExternalRef{
+			Category: CatPackageManager,
+			Type:     "purl",
+			Locator:  packageurl,
+		})
+	}
+	return spdxPackage, nil
+}
+
+// pythonDependencyFile returns the path of the file listing the
+// dependencies of the python project in dirPath, or an empty string if
+// it has none
+func pythonDependencyFile(dirPath string) string {
+	for _, name := range pythonDependencyFiles {
+		if util.Exists(filepath.Join(dirPath, name)) {
+			return filepath.Join(dirPath, name)
+		}
+	}
+	return ""
+}
+
+// readPythonDependencies returns the packages listed in a poetry.lock,
+// Pipfile.lock or requirements file sorted by name. Packages listed more
+// than once with the same version are returned once.
+func readPythonDependencies(path string) ([]*PythonPackage, error) {
+	var packages []*PythonPackage
+	var err error
+	switch filepath.Base(path) {
+	case PoetryLockFileName:
+		packages, err = parsePoetryLock(path)
+	case PipfileLockFileName:
+		packages, err = parsePipfileLock(path)
+	default:
+		packages, err = parsePythonRequirements(path, map[string]struct{}{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+	unique := []*PythonPackage{}
+	seen := map[string]struct{}{}
+	for _, p := range packages {
+		key := normalizePythonName(p.Name) + "@" + p.Version
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, p)
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		return normalizePythonName(unique[i].Name) < normalizePythonName(unique[j].Name)
+	})
+	return unique, nil
+}
+
+// parsePythonRequirements reads the requirements of a requirements file
+// and the files it includes with -r. All of them are direct dependencies,
+// only those pinned with == have a version.
+func parsePythonRequirements(path string, seen map[string]struct{}) ([]*PythonPackage, error) {
+	if _, ok := seen[path]; ok {
+		return []*PythonPackage{}, nil
+	}
+	seen[path] = struct{}{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading requirements: %w", err)
+	}
+
+	packages := []*PythonPackage{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := ""
+	for scanner.Scan() {
+		// Lines ending with a backslash continue in the next one
+		line += scanner.Text()
+		if strings.HasSuffix(line, `\`) {
+			line = strings.TrimSuffix(line, `\`)
+			continue
+		}
+		req := line
+		line = ""
+		if i := strings.Index(req, " #"); i != -1 {
+			req = req[:i]
+		}
+		req = strings.TrimSpace(req)
+		if req == "" || strings.HasPrefix(req, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(req, "-") {
+			// Read the included requirement files, other options are
+			// not requirements
+			opt, arg, _ := strings.Cut(strings.Replace(req, "=", " ", 1), " ")
+			if opt != "-r" && opt != "--requirement" {
+				continue
+			}
+			included, err := parsePythonRequirements(
+				filepath.Join(filepath.Dir(path), strings.TrimSpace(arg)), seen,
+			)
+			if err != nil {
+				return nil, err
+			}
+			packages = append(packages, included...)
+			continue
+		}
+
+		if p := parsePythonRequirement(req); p != nil {
+			p.Direct = true
+			packages = append(packages, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanning requirements: %w", err)
+	}
+	return packages, nil
+}
+
+// parsePythonRequirement parses a PEP 508 requirement. The version is set
+// when it is pinned to a single version with == or ===.
+func parsePythonRequirement(req string) *PythonPackage {
+	// Drop the environment markers and the pip options (eg --hash)
+	if i := strings.Index(req, ";"); i != -1 {
+		req = req[:i]
+	}
+	if i := strings.Index(req, " --"); i != -1 {
+		req = req[:i]
+	}
+	m := pythonRequirementRe.FindStringSubmatch(strings.TrimSpace(req))
+	if m == nil {
+		return nil
+	}
+	p := &PythonPackage{Name: m[1]}
+	spec := strings.TrimSpace(m[3])
+	for _, op := range []string{"===", "=="} {
+		if !strings.HasPrefix(spec, op) {
+			continue
+		}
+		version := strings.TrimSpace(strings.TrimPrefix(spec, op))
+		if !strings.ContainsAny(version, ",*") {
+			p.Version = version
+		}
+		break
+	}
+	return p
+}
+
+// poetryLockData is the data read from poetry.lock files
+type poetryLockData struct {
+	Package []struct {
+		Name    string `toml:"name"`
+		Version string `toml:"version"`
+	} `toml:"package"`
+}
+
+// parsePoetryLock reads the packages of a poetry.lock file. The direct
+// dependencies are read from the pyproject.toml next to it, without it
+// all the packages are considered direct.
+func parsePoetryLock(path string) ([]*PythonPackage, error) {
+	lock := poetryLockData{}
+	if _, err := toml.DecodeFile(path, &lock); err != nil {
+		return nil, fmt.Errorf("decoding poetry lock: %w", err)
+	}
+	direct, err := pyprojectDependencies(filepath.Join(filepath.Dir(path), pyprojectFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	packages := []*PythonPackage{}
+	for _, entry := range lock.Package {
+		if entry.Name == "" {
+			continue
+		}
+		isDirect := direct == nil
+		if !isDirect {
+			_, isDirect = direct[normalizePythonName(entry.Name)]
+		}
+		packages = append(packages, &PythonPackage{Name: entry.Name, Version: entry.Version, Direct: isDirect})
+	}
+	return packages, nil
+}
+
+// pyprojectData is the data read from pyproject.toml files. The values
+// of the poetry dependency tables are either version strings or tables,
+// only their keys are used.
+type pyprojectData struct {
+	Project struct {
+		Dependencies         []string            `toml:"dependencies"`
+		OptionalDependencies map[string][]string `toml:"optional-dependencies"`
+	} `toml:"project"`
+	Tool struct {
+		Poetry struct {
+			Dependencies    map[string]interface{} `toml:"dependencies"`
+			DevDependencies map[string]interface{} `toml:"dev-dependencies"`
+			Group           map[string]struct {
+				Dependencies map[string]interface{} `toml:"dependencies"`
+			} `toml:"group"`
+		} `toml:"poetry"`
+	} `toml:"tool"`
+}
+
+// pyprojectDependencies returns the normalized names of the dependencies
+// declared in a pyproject.toml file, both in the standard project table
+// and in the poetry tables. It returns nil if the file does not exist.
+func pyprojectDependencies(path string) (map[string]struct{}, error) {
+	pyproject := pyprojectData{}
+	if _, err := toml.DecodeFile(path, &pyproject); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("decoding %s: %w", pyprojectFileName, err)
+	}
+
+	names := map[string]struct{}{}
+	addRequirements := func(reqs []string) {
+		for _, r := range reqs {
+			if p := parsePythonRequirement(r); p != nil {
+				names[normalizePythonName(p.Name)] = struct{}{}
+			}
+		}
+	}
+	addKeys := func(deps map[string]interface{}) {
+		for name := range deps {
+			// Poetry lists the python version among the dependencies
+			if name != "python" {
+				names[normalizePythonName(name)] = struct{}{}
+			}
+		}
+	}
+
+	addRequirements(pyproject.Project.Dependencies)
+	for _, reqs := range pyproject.Project.OptionalDependencies {
+		addRequirements(reqs)
+	}
+	poetry := pyproject.Tool.Poetry
+	addKeys(poetry.Dependencies)
+	addKeys(poetry.DevDependencies)
+	for _, group := range poetry.Group {
+		addKeys(group.Dependencies)
+	}
+	return names, nil
+}
+
+// pipfileLockData is the data read from Pipfile.lock files
+type pipfileLockData struct {
+	Default map[string]struct {
+		Version string `json:"version"`
+	} `json:"default"`
+	Develop map[string]struct {
+		Version string `json:"version"`
+	} `json:"develop"`
+}
+
+// pipfileData is the data read from Pipfiles, only the keys of the
+// package tables are used
+type pipfileData struct {
+	Packages    map[string]interface{} `toml:"packages"`
+	DevPackages map[string]interface{} `toml:"dev-packages"`
+}
+
+// parsePipfileLock reads the packages of a Pipfile.lock file. The direct
+// dependencies are read from the Pipfile next to it, without it all the
+// packages are considered direct.
+func parsePipfileLock(path string) ([]*PythonPackage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading Pipfile lock: %w", err)
+	}
+	lock := pipfileLockData{}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("decoding Pipfile lock: %w", err)
+	}
+
+	var direct map[string]struct{}
+	pipfile := pipfileData{}
+	if _, err := toml.DecodeFile(filepath.Join(filepath.Dir(path), pipfileFileName), &pipfile); err == nil {
+		direct = map[string]struct{}{}
+		for _, deps := range []map[string]interface{}{pipfile.Packages, pipfile.DevPackages} {
+			for name := range deps {
+				direct[normalizePythonName(name)] = struct{}{}
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("decoding %s: %w", pipfileFileName, err)
+	}
+
+	packages := []*PythonPackage{}
+	for _, section := range []map[string]struct {
+		Version string `json:"version"`
+	}{lock.Default, lock.Develop} {
+		for name, p := range section {
+			isDirect := direct == nil
+			if !isDirect {
+				_, isDirect = direct[normalizePythonName(name)]
+			}
+			packages = append(packages, &PythonPackage{
+				Name:    name,
+				Version: strings.TrimPrefix(p.Version, "=="),
+				Direct:  isDirect,
+			})
+		}
+	}
+	return packages, nil
+}
+
+// installedPythonLicenses looks for the metadata of the python packages
+// installed under dirPath (eg in a virtualenv) and returns their license
+// by normalized package name
+func installedPythonLicenses(log logrus.FieldLogger, dirPath string) (map[string]string, error) {
+	licenses := map[string]string{}
+	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (d.Name() == ".git" || d.Name() == npmModulesDir) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || d.Name() != "METADATA" || !strings.HasSuffix(filepath.Dir(path), ".dist-info") {
+			return nil
+		}
+		name, licenseID, err := readPythonMetadataLicense(path)
+		if err != nil {
+			log.Warnf("Reading python package metadata in %s: %v", path, err)
+			return nil
+		}
+		if name != "" && licenseID != "" {
+			licenses[normalizePythonName(name)] = licenseID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("looking for installed python packages: %w", err)
+	}
+	return licenses, nil
+}
+
+// readPythonMetadataLicense reads the name and license of a python package
+// from its METADATA file. The License-Expression header of newer packages
+// is preferred over the free form License header.
+func readPythonMetadataLicense(path string) (name, licenseID string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("reading metadata: %w", err)
+	}
+	var expression string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		// The headers end at the first blank line, the description follows
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Name":
+			name = value
+		case "License":
+			licenseID = value
+		case "License-Expression":
+			expression = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", fmt.Errorf("scanning metadata: %w", err)
+	}
+	if expression != "" {
+		licenseID = expression
+	}
+	return name, licenseID, nil
+}
+
+// GetPythonDependencies reads the poetry.lock, Pipfile.lock or
+// requirements.txt file of the python project in path and returns its
+// dependencies as SPDX packages
+func (di *spdxDefaultImplementation) GetPythonDependencies(path string, opts *Options) ([]*Package, error) {
+	depsPath := pythonDependencyFile(path)
+	if depsPath == "" {
+		return nil, fmt.Errorf("no python dependency file found in %s", path)
+	}
+	pythonPackages, err := readPythonDependencies(depsPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading python dependencies: %w", err)
+	}
+
+	var licenses map[string]string
+	if opts.ScanLicenses {
+		licenses, err = installedPythonLicenses(logger(opts), path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	spdxPackages := []*Package{}
+	for _, p := range pythonPackages {
+		if opts.OnlyDirectDeps && !p.Direct {
+			continue
+		}
+		if p.Version == "" {
+			di.warn(opts, p.Name, "python requirement %s is not pinned to a version", p.Name)
+		}
+		if l := licenses[normalizePythonName(p.Name)]; l != "" {
+			if _, err := license.ParseLicenseExpression(l); err != nil {
+				di.warn(opts, p.Name, "ignoring license of python package %s: %v", p.Name, err)
+			} else {
+				p.License = l
+			}
+		}
+		spdxPkg, err := p.ToSPDXPackage()
+		if err != nil {
+			// If a dependency cannot be converted, warn but do not die
+			di.warn(opts, p.Name, "converting python dependency to spdx package: %v", err)
+			continue
+		}
+		spdxPackages = append(spdxPackages, spdxPkg)
+	}
+	return spdxPackages, nil
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPythonDependencies(t *testing.T) {
+	const poetryLock = `# This file is automatically @generated by Poetry and should not be changed by hand.
+
+[[package]]
+name = "certifi"
+version = "2023.7.22"
+description = "Python package for providing Mozilla's CA Bundle."
+optional = false
+python-versions = ">=3.6"
+files = [
+    {file = "certifi-2023.7.22-py3-none-any.whl", hash = "sha256:92d6037539857d8206b8f6ae472e8b77db8058fec5937a1ef3f54304089edbb9"},
+]
+
+[[package]]
+name = "requests"
+version = "2.31.0"
+description = "Python HTTP for Humans."
+optional = false
+python-versions = ">=3.7"
+files = []
+
+[package.dependencies]
+certifi = ">=2017.4.17"
+
+[package.extras]
+socks = ["PySocks (>=1.5.6,!=1.5.7)"]
+
+[[package]]
+name = "pytest"
+version = "7.4.0"
+description = "pytest: simple powerful testing with Python"
+optional = false
+python-versions = ">=3.7"
+files = []
+
+[metadata]
+lock-version = "2.0"
+python-versions = "^3.11"
+content-hash = "a5b4"
+`
+	const pyproject = `[tool.poetry]
+name = "app"
+version = "0.1.0"
+description = """
+A multiline
+description"""
+authors = [
+  "Someone <someone@example.com>", # The author
+]
+
+[tool.poetry.dependencies]
+python = "^3.11"
+requests = {version = "^2.31.0", extras = ["socks"]}
+
+[tool.poetry.group.test.dependencies]
+pytest = "^7.4.0"
+`
+	const pipfileLock = `{
+  "_meta": {"hash": {"sha256": "abc"}, "pipfile-spec": 6},
+  "default": {
+    "flask": {"hashes": ["sha256:abc"], "version": "==2.3.2"},
+    "werkzeug": {"hashes": ["sha256:def"], "version": "==2.3.6"}
+  },
+  "develop": {
+    "black": {"version": "==23.7.0"}
+  }
+}`
+	const pipfile = `[[source]]
+url = "https://pypi.org/simple"
+verify_ssl = true
+name = "pypi"
+
+[packages]
+Flask = "*"
+
+[dev-packages]
+black = "*"
+`
+	metadata := func(name, license string) string {
+		return "Metadata-Version: 2.1\nName: " + name + "\nVersion: 1.0\n" + license + "\n\nLicense: not a header\n"
+	}
+	for _, tc := range []struct {
+		name           string
+		files          map[string]string
+		onlyDirectDeps bool
+		scanLicenses   bool
+		expected       []string // Packages as name@version
+		licenses       map[string]string
+		shouldErr      bool
+	}{
+		{
+			name: "requirements",
+			files: map[string]string{
+				PythonRequirementsFileName: "# Runtime\n-r base.txt\nrequests[socks]==2.31.0 ; python_version >= \"3.7\" \\\n    --hash=sha256:58cd\n" +
+					"urllib3>=1.21.1,<3\n-e ./local\ngit+https://github.com/psf/black\n--index-url https://pypi.org/simple\n",
+				"base.txt": "certifi===2023.7.22  # via requests\n-r requirements.txt\nrequests==2.31.0\n",
+				".venv/lib/python3.11/site-packages/requests-2.31.0.dist-info/METADATA":   metadata("requests", "License: Apache 2.0"),
+				".venv/lib/python3.11/site-packages/certifi-2023.7.22.dist-info/METADATA": metadata("certifi", "License: MPL-2.0"),
+				".venv/lib/python3.11/site-packages/urllib3-2.0.4.dist-info/METADATA": metadata(
+					"urllib3", "License: MIT License\nLicense-Expression: MIT",
+				),
+			},
+			scanLicenses: true,
+			expected:     []string{"certifi@2023.7.22", "requests@2.31.0", "urllib3@"},
+			// Licenses which are not an expression are dropped
+			licenses: map[string]string{"certifi": "MPL-2.0", "requests": "", "urllib3": "MIT"},
+		},
+		{
+			name:     "poetry",
+			files:    map[string]string{PoetryLockFileName: poetryLock, pyprojectFileName: pyproject},
+			expected: []string{"certifi@2023.7.22", "pytest@7.4.0", "requests@2.31.0"},
+		},
+		{
+			name:           "poetry direct deps",
+			files:          map[string]string{PoetryLockFileName: poetryLock, pyprojectFileName: pyproject},
+			onlyDirectDeps: true,
+			expected:       []string{"pytest@7.4.0", "requests@2.31.0"},
+		},
+		{
+			name:           "pipenv direct deps",
+			files:          map[string]string{PipfileLockFileName: pipfileLock, pipfileFileName: pipfile},
+			onlyDirectDeps: true,
+			expected:       []string{"black@23.7.0", "flask@2.3.2"},
+		},
+		{
+			name:           "pipenv without Pipfile",
+			files:          map[string]string{PipfileLockFileName: pipfileLock},
+			onlyDirectDeps: true,
+			expected:       []string{"black@23.7.0", "flask@2.3.2", "werkzeug@2.3.6"},
+		},
+		{
+			name:      "no dependency files",
+			files:     map[string]string{pyprojectFileName: pyproject},
+			shouldErr: true,
+		},
+		{
+			name:      "invalid lockfile",
+			files:     map[string]string{PoetryLockFileName: "[[package]\nname = 1"},
+			shouldErr: true,
+		},
+	} {
+		dir := t.TempDir()
+		for name, data := range tc.files {
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), os.FileMode(0o755)))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), os.FileMode(0o644)))
+		}
+		di := spdxDefaultImplementation{}
+		opts := &Options{OnlyDirectDeps: tc.onlyDirectDeps, ScanLicenses: tc.scanLicenses}
+		packages, err := di.GetPythonDependencies(dir, opts)
+		if tc.shouldErr {
+			require.Error(t, err, tc.name)
+			continue
+		}
+		require.NoError(t, err, tc.name)
+
+		versions := []string{}
+		for _, p := range packages {
+			versions = append(versions, p.Name+"@"+p.Version)
+			if l, ok := tc.licenses[p.Name]; ok {
+				require.Equal(t, l, p.LicenseDeclared, tc.name)
+			}
+			require.Equal(t, (&PythonPackage{Name: p.Name, Version: p.Version}).PackageURL(), p.ExternalRefs[0].Locator)
+		}
+		require.Equal(t, tc.expected, versions, tc.name)
+	}
+}
+
+func TestPythonPackageURL(t *testing.T) {
+	require.Equal(t, "pkg:pypi/pyyaml@6.0", (&PythonPackage{Name: "PyYAML", Version: "6.0"}).PackageURL())
+	require.Equal(t, "pkg:pypi/zope-interface@6.0", (&PythonPackage{Name: "zope.interface", Version: "6.0"}).PackageURL())
+	require.Equal(t, "pkg:pypi/urllib3", (&PythonPackage{Name: "urllib3"}).PackageURL())
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// registryLimiter spaces the requests to registries of all the scans run
+// in the process, so batch jobs stay within the pull limits of registries
+var registryLimiter = &rateLimiter{}
+
+// rateLimiter is a token bucket holding a single token: each request
+// waits for the interval of the rate to pass since the previous one.
+type rateLimiter struct {
+	mu   sync.Mutex
+	next time.Time // Time when the next request can be sent
+}
+
+// wait blocks until a request can be sent at perSecond requests per
+// second, or ctx is done. Rates of zero or less do not limit requests.
+func (rl *rateLimiter) wait(ctx context.Context, perSecond float64) error {
+	if perSecond <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / perSecond)
+	rl.mu.Lock()
+	now := time.Now()
+	slot := rl.next
+	if slot.Before(now) {
+		slot = now
+	}
+	rl.next = slot.Add(interval)
+	rl.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// waitRegistryRequest blocks until a request to a registry can be sent
+// within the RegistryRequestsPerSecond of the options
+func waitRegistryRequest(ctx context.Context, opts *Options) error {
+	if opts == nil || opts.RegistryRequestsPerSecond <= 0 {
+		return nil
+	}
+	if err := registryLimiter.wait(ctx, opts.RegistryRequestsPerSecond); err != nil {
+		return fmt.Errorf("waiting for the registry rate limit: %w", err)
+	}
+	return nil
+}
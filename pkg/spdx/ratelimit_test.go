@@ -0,0 +1,54 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	// Without a rate requests are not limited
+	rl := &rateLimiter{}
+	for i := 0; i < 100; i++ {
+		require.NoError(t, rl.wait(ctx, 0))
+	}
+	require.True(t, rl.next.IsZero())
+
+	// The first request is sent right away, the next ones are spaced
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, rl.wait(ctx, 50))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+
+	// Waits are cut short when the context is done
+	rl = &rateLimiter{}
+	require.NoError(t, rl.wait(ctx, 0.01))
+	cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, rl.wait(cancelled, 0.01), context.DeadlineExceeded)
+
+	// Options without a rate do not wait on the shared limiter
+	require.NoError(t, waitRegistryRequest(cancelled, nil))
+	require.NoError(t, waitRegistryRequest(cancelled, &Options{}))
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// defaultReferenceCacheSize is the number of references kept in the
+// cache when the options don't specify a size
+const defaultReferenceCacheSize = 100
+
+// referenceCache keeps the image references resolved from the registry
+// to avoid repeating the network round-trips when the same tag or digest
+// is looked up more than once. The zero value is a disabled cache.
+type referenceCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	entries map[string]referenceCacheEntry
+
+	// resolve is the function that looks up the references in the
+	// registry and now returns the current time. Both are replaced
+	// in the unit tests.
+	resolve func(context.Context, string) (*ImageReferenceInfo, error)
+	now     func() time.Time
+}
+
+type referenceCacheEntry struct {
+	info    *ImageReferenceInfo
+	expires time.Time
+}
+
+// configure sets the time to live and size of the cache. A zero TTL
+// disables the cache and drops its entries.
+func (rc *referenceCache) configure(ttl time.Duration, maxSize int) {
+	rc.Lock()
+	defer rc.Unlock()
+	if maxSize <= 0 {
+		maxSize = defaultReferenceCacheSize
+	}
+	if ttl <= 0 {
+		rc.entries = nil
+	}
+	rc.ttl = ttl
+	rc.maxSize = maxSize
+}
+
+// Get returns the resolved references of an image reference string,
+// reading them from the cache when they have not expired
+func (rc *referenceCache) Get(ctx context.Context, opts *Options, referenceString string) (*ImageReferenceInfo, error) {
+	resolve := rc.resolve
+	if resolve == nil {
+		resolve = func(ctx context.Context, referenceString string) (*ImageReferenceInfo, error) {
+			return getImageReferences(ctx, opts, referenceString)
+		}
+	}
+
+	rc.Lock()
+	ttl := rc.ttl
+	if entry, ok := rc.entries[referenceString]; ok && rc.timeNow().Before(entry.expires) {
+		rc.Unlock()
+		logger(opts).Debugf("Image reference %s read from cache", referenceString)
+		return entry.info.copy(), nil
+	}
+	rc.Unlock()
+
+	info, err := resolve(ctx, referenceString)
+	if err != nil || ttl <= 0 {
+		return info, err
+	}
+
+	rc.Lock()
+	defer rc.Unlock()
+	rc.add(referenceString, info.copy())
+	return info, nil
+}
+
+// add stores a new entry in the cache, evicting expired entries or, if
+// none have expired, the one closest to expiring when the cache is full
+func (rc *referenceCache) add(referenceString string, info *ImageReferenceInfo) {
+	if rc.entries == nil {
+		rc.entries = map[string]referenceCacheEntry{}
+	}
+	now := rc.timeNow()
+	if _, ok := rc.entries[referenceString]; !ok && len(rc.entries) >= rc.maxSize {
+		oldest := ""
+		for ref, entry := range rc.entries {
+			if !now.Before(entry.expires) {
+				delete(rc.entries, ref)
+				continue
+			}
+			if oldest == "" || entry.expires.Before(rc.entries[oldest].expires) {
+				oldest = ref
+			}
+		}
+		if len(rc.entries) >= rc.maxSize {
+			delete(rc.entries, oldest)
+		}
+	}
+	rc.entries[referenceString] = referenceCacheEntry{
+		info:    info,
+		expires: now.Add(rc.ttl),
+	}
+}
+
+// defaultDigestCacheTTL is how long the looked up digests are kept when
+// the options don't set ImageReferenceCacheTTL
+const defaultDigestCacheTTL = time.Minute
+
+// digestCache keeps the digests of the image references looked up in the
+// registry, so each reference is resolved only once even when it is found
+// in the purls of several packages. The entries belong to the options
+// they were looked up with, as those choose the registries, credentials
+// and transport, and expire after ImageReferenceCacheTTL. Failed lookups
+// are not kept.
+type digestCache struct {
+	sync.Mutex
+	opts    *Options
+	entries map[string]digestCacheEntry
+
+	// lookup resolves the digest of a reference and now returns the
+	// current time. Both are replaced in the unit tests.
+	lookup func(context.Context, string) (string, error)
+	now    func() time.Time
+}
+
+type digestCacheEntry struct {
+	digest  string
+	expires time.Time
+}
+
+// get returns the digest of an image reference, resolving it from the
+// registry the first time it is seen with the options
+func (dc *digestCache) get(ctx context.Context, opts *Options, referenceString string) (string, error) {
+	dc.Lock()
+	if dc.opts != opts {
+		dc.opts, dc.entries = opts, nil
+	}
+	entry, ok := dc.entries[referenceString]
+	lookup := dc.lookup
+	now := dc.timeNow()
+	dc.Unlock()
+	if ok && now.Before(entry.expires) {
+		logger(opts).Debugf("Digest of %s read from cache", referenceString)
+		return entry.digest, nil
+	}
+
+	if lookup == nil {
+		lookup = func(ctx context.Context, referenceString string) (string, error) {
+			if err := waitRegistryRequest(ctx, opts); err != nil {
+				return "", err
+			}
+			return remoteDigest(ctx, opts, referenceString)
+		}
+	}
+	digest, err := lookup(ctx, referenceString)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := defaultDigestCacheTTL
+	if opts != nil && opts.ImageReferenceCacheTTL > 0 {
+		ttl = opts.ImageReferenceCacheTTL
+	}
+	dc.Lock()
+	defer dc.Unlock()
+	if dc.opts != opts {
+		return digest, nil
+	}
+	if dc.entries == nil {
+		dc.entries = map[string]digestCacheEntry{}
+	}
+	dc.entries[referenceString] = digestCacheEntry{digest: digest, expires: dc.timeNow().Add(ttl)}
+	return digest, nil
+}
+
+func (dc *digestCache) timeNow() time.Time {
+	if dc.now != nil {
+		return dc.now()
+	}
+	return time.Now()
+}
+
+// remoteDigest looks up the digest of an image reference in its registry
+// with a HEAD request, falling back to fetching the manifest when the
+// registry does not answer it, as crane.Digest does
+func remoteDigest(ctx context.Context, opts *Options, referenceString string) (string, error) {
+	ref, err := name.ParseReference(referenceString)
+	if err != nil {
+		return "", fmt.Errorf("parsing reference %s: %w", referenceString, err)
+	}
+	remoteOpts, err := remoteOptions(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	desc, err := remote.Head(ref, remoteOpts...)
+	if err != nil {
+		logger(opts).Debugf("HEAD request for %s failed, fetching the manifest: %v", referenceString, err)
+		getDesc, err := remote.Get(ref, remoteOpts...)
+		if err != nil {
+			return "", fmt.Errorf("fetching manifest of %s: %w", referenceString, err)
+		}
+		return getDesc.Digest.String(), nil
+	}
+	return desc.Digest.String(), nil
+}
+
+func (rc *referenceCache) timeNow() time.Time {
+	if rc.now != nil {
+		return rc.now()
+	}
+	return time.Now()
+}
+
+// copy returns a deep copy of the reference info. Cached entries are
+// copied as callers modify the data they get (eg to add the archives).
+func (ri *ImageReferenceInfo) copy() *ImageReferenceInfo {
+	if ri == nil {
+		return nil
+	}
+	c := *ri
+	if ri.Images != nil {
+		c.Images = make([]ImageReferenceInfo, len(ri.Images))
+		copy(c.Images, ri.Images)
+	}
+	if ri.Attestations != nil {
+		c.Attestations = make([]ImageReferenceInfo, len(ri.Attestations))
+		copy(c.Attestations, ri.Attestations)
+	}
+	return &c
+}
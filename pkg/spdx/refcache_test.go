@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurlFromImageDigestCache(t *testing.T) {
+	hash := "sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57"
+	lookups := map[string]int{}
+	now := time.Now()
+	impl := spdxDefaultImplementation{digests: digestCache{
+		now: func() time.Time { return now },
+		lookup: func(_ context.Context, ref string) (string, error) {
+			lookups[ref]++
+			if strings.HasSuffix(ref, ":missing") {
+				return "", errors.New("tag not found")
+			}
+			return hash, nil
+		},
+	}}
+
+	for i := 0; i < 3; i++ {
+		for _, ref := range []string{"registry.example.com/app/web:v1", "registry.example.com/app/api:v1"} {
+			p := impl.purlFromImage(context.Background(), nil, &ImageReferenceInfo{Digest: ref, Reference: ref})
+			require.Contains(t, p, "@"+hash)
+		}
+		// References carrying their digest are never looked up
+		p := impl.purlFromImage(context.Background(), nil, &ImageReferenceInfo{Digest: "registry.example.com/app/db@" + hash})
+		require.Contains(t, p, "@"+hash)
+
+		// Failed lookups are retried
+		require.Empty(t, impl.purlFromImage(context.Background(), nil, &ImageReferenceInfo{
+			Digest: "registry.example.com/app/web:missing", Reference: "registry.example.com/app/web:missing",
+		}))
+	}
+	require.Equal(t, map[string]int{
+		"registry.example.com/app/web:v1":      1,
+		"registry.example.com/app/api:v1":      1,
+		"registry.example.com/app/web:missing": 3,
+	}, lookups)
+
+	// Other options look the references up again
+	ref := "registry.example.com/app/web:v1"
+	opts := &Options{ImageReferenceCacheTTL: time.Hour}
+	require.Contains(t, impl.purlFromImage(context.Background(), opts, &ImageReferenceInfo{Digest: ref, Reference: ref}), "@"+hash)
+	require.Equal(t, 2, lookups[ref])
+	require.Contains(t, impl.purlFromImage(context.Background(), opts, &ImageReferenceInfo{Digest: ref, Reference: ref}), "@"+hash)
+	require.Equal(t, 2, lookups[ref])
+
+	// And the digests expire after the TTL of the options
+	now = now.Add(2 * time.Hour)
+	require.Contains(t, impl.purlFromImage(context.Background(), opts, &ImageReferenceInfo{Digest: ref, Reference: ref}), "@"+hash)
+	require.Equal(t, 3, lookups[ref])
+}
+
+func TestReferenceCache(t *testing.T) {
+	calls := 0
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	rc := referenceCache{
+		resolve: func(_ context.Context, ref string) (*ImageReferenceInfo, error) {
+			calls++
+			return &ImageReferenceInfo{
+				Digest: ref + "@sha256:0000",
+				Images: []ImageReferenceInfo{{Digest: ref + "@sha256:1111"}},
+			}, nil
+		},
+		now: func() time.Time { return now },
+	}
+
+	// Disabled by default
+	_, err := rc.Get(context.Background(), nil, "image:v1")
+	require.NoError(t, err)
+	_, err = rc.Get(context.Background(), nil, "image:v1")
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+
+	// Repeated resolutions within the TTL don't hit the registry
+	calls = 0
+	rc.configure(time.Minute, 2)
+	info, err := rc.Get(context.Background(), nil, "image:v1")
+	require.NoError(t, err)
+	info.Archive = "/tmp/modified.tar"
+	info.Images[0].Archive = "/tmp/modified.tar"
+	info, err = rc.Get(context.Background(), nil, "image:v1")
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+	require.Equal(t, "image:v1@sha256:0000", info.Digest)
+	require.Empty(t, info.Archive)
+	require.Empty(t, info.Images[0].Archive)
+
+	// Expired entries are resolved again
+	now = now.Add(2 * time.Minute)
+	_, err = rc.Get(context.Background(), nil, "image:v1")
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+
+	// The cache is bounded, the entry closest to expire is evicted
+	now = now.Add(time.Second)
+	_, err = rc.Get(context.Background(), nil, "image:v2")
+	require.NoError(t, err)
+	_, err = rc.Get(context.Background(), nil, "image:v3")
+	require.NoError(t, err)
+	require.Len(t, rc.entries, 2)
+	require.Equal(t, 4, calls)
+	_, err = rc.Get(context.Background(), nil, "image:v1")
+	require.NoError(t, err)
+	require.Equal(t, 5, calls)
+}
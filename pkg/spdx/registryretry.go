@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// defaultRegistryRetryBackoff is the wait before the first retry of a
+// registry request when the options do not set it
+const defaultRegistryRetryBackoff = time.Second
+
+// retryableRegistryError returns true if the error is a response from the
+// registry signaling a transient problem: rate limiting or a server error.
+// Other errors like failed authentication or missing manifests are final.
+func retryableRegistryError(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	switch terr.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// withRegistryRetries runs fn, retrying it up to the RegistryRetries of the
+// options while it fails with a transient registry error. The wait between
+// attempts starts at RegistryRetryBackoff and doubles after each retry.
+func withRegistryRetries(ctx context.Context, opts *Options, what string, fn func() error) error {
+	retries := 0
+	backoff := defaultRegistryRetryBackoff
+	if opts != nil {
+		retries = opts.RegistryRetries
+		if opts.RegistryRetryBackoff > 0 {
+			backoff = opts.RegistryRetryBackoff
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries || !retryableRegistryError(err) {
+			return err
+		}
+		logger(opts).Warnf("Retrying %s in %s after registry error (%d/%d): %v", what, backoff, attempt+1, retries, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
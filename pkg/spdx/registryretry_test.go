@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryRetries(t *testing.T) {
+	// The registry rate limits the first manifest requests
+	var limited, manifestRequests atomic.Int32
+	regHandler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/manifests/") {
+			manifestRequests.Add(1)
+			if limited.Load() > 0 {
+				limited.Add(-1)
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		}
+		regHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/image:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	opts := &Options{RegistryRetries: 2, RegistryRetryBackoff: time.Millisecond}
+	impl := spdxDefaultImplementation{}
+
+	// Transient errors are retried up to the limit
+	limited.Store(2)
+	refs, err := impl.PullImagesToArchive(context.Background(), opts, ref.String(), t.TempDir())
+	require.NoError(t, err)
+	require.FileExists(t, refs.Archive)
+
+	limited.Store(3)
+	_, err = impl.PullImagesToArchive(context.Background(), opts, ref.String(), t.TempDir())
+	require.Error(t, err)
+
+	// Without retries the first error is final
+	limited.Store(1)
+	_, err = impl.PullImagesToArchive(context.Background(), &Options{}, ref.String(), t.TempDir())
+	require.Error(t, err)
+
+	// Missing images are not retried
+	limited.Store(0)
+	manifestRequests.Store(0)
+	missing := strings.TrimPrefix(server.URL, "http://") + "/test/image:missing"
+	_, err = impl.PullImagesToArchive(context.Background(), opts, missing, t.TempDir())
+	require.Error(t, err)
+	require.EqualValues(t, 1, manifestRequests.Load())
+}
+
+func TestRetryableRegistryError(t *testing.T) {
+	for code, retryable := range map[int]bool{
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusGatewayTimeout:      true,
+		http.StatusUnauthorized:        false,
+		http.StatusForbidden:           false,
+		http.StatusNotFound:            false,
+	} {
+		err := fmt.Errorf("fetching: %w", &transport.Error{StatusCode: code})
+		require.Equal(t, retryable, retryableRegistryError(err), code)
+	}
+	require.False(t, retryableRegistryError(errors.New("some error")))
+}
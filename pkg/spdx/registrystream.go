@@ -0,0 +1,193 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"sigs.k8s.io/bom/pkg/osinfo"
+)
+
+// streamRegistryImagesUnsupported returns true if the options need the
+// layers of images on disk, so they cannot be streamed from the registry
+func streamRegistryImagesUnsupported(opts *Options) bool {
+	return opts.AnalyzeLayers || opts.AddTarFiles || opts.DetectSecrets
+}
+
+// packageFromRegistryStream builds the package of the images in the
+// registry reference ref reading their layers as they are downloaded.
+// Nothing is written to disk, the files of each layer are hashed and the
+// package databases read while streaming it.
+func (di *spdxDefaultImplementation) packageFromRegistryStream(
+	ctx context.Context, opts *Options, ref string,
+) (*Package, error) {
+	references, err := di.resolveImageReferences(ctx, opts, ref)
+	if err != nil {
+		return nil, fmt.Errorf("resolving image references: %w", err)
+	}
+	return di.imageReferencesPackage(ctx, opts, ref, references, func(
+		ctx context.Context, opts *Options, img *ImageReferenceInfo,
+	) (*Package, error) {
+		pkg, err := di.registryImagePackage(ctx, opts, img)
+		if err != nil {
+			return nil, fmt.Errorf("streaming image %s: %w", img.Digest, err)
+		}
+		return di.describeImageReference(ctx, opts, img, pkg)
+	})
+}
+
+// registryImagePackage streams the layers of the image img from its
+// registry, returning a package with one for each layer
+func (di *spdxDefaultImplementation) registryImagePackage(
+	ctx context.Context, opts *Options, img *ImageReferenceInfo,
+) (*Package, error) {
+	algorithms, err := normalizeChecksumAlgorithms(opts.ChecksumAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := name.ParseReference(img.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %s: %w", img.Digest, err)
+	}
+	remoteOpts, err := remoteOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		image    v1.Image
+		config   *v1.ConfigFile
+		manifest *v1.Manifest
+		layers   []v1.Layer
+	)
+	if err := withRegistryRetries(ctx, opts, "fetch of "+img.Digest, func() (err error) {
+		if err := waitRegistryRequest(ctx, opts); err != nil {
+			return err
+		}
+		image, err = remote.Image(ref, remoteOpts...)
+		if err != nil {
+			return fmt.Errorf("getting image from remote: %w", err)
+		}
+		if config, err = image.ConfigFile(); err != nil {
+			return fmt.Errorf("reading image config: %w", err)
+		}
+		if manifest, err = image.Manifest(); err != nil {
+			return fmt.Errorf("reading image manifest: %w", err)
+		}
+		if layers, err = image.Layers(); err != nil {
+			return fmt.Errorf("listing image layers: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var scanner *osinfo.StreamScanner
+	if opts.ScanImages {
+		scanner = osinfo.NewStreamScanner()
+	}
+
+	// The layers are streamed in order, the OS packages found in a layer
+	// replace the ones of the layers before it
+	logger(opts).Infof("Streaming the %d layers of %s from the registry", len(layers), img.Digest)
+	progress := newProgressCounter(progressFn(opts), ProgressPhaseLayerScan, len(layers))
+	layerPackages := make([]*Package, 0, len(layers))
+	for i, layer := range layers {
+		var pkg *Package
+		stopDownload := di.stats.start(opts, phaseDownload)
+		err := withRegistryRetries(ctx, opts, fmt.Sprintf("stream of layer %d of %s", i, img.Digest), func() error {
+			if err := waitRegistryRequest(ctx, opts); err != nil {
+				return err
+			}
+			return di.cpuLimiter.run(opts, func() error {
+				blob, err := layer.Compressed()
+				if err != nil {
+					return fmt.Errorf("opening layer: %w", err)
+				}
+				defer blob.Close()
+				pkg, err = streamLayerBlob(blob, fmt.Sprintf("layer %d", i), &layerStream{
+					algorithms:     algorithms,
+					recordMetadata: opts.RecordFileMetadata,
+					osScanner:      scanner,
+					layer:          i,
+				})
+				return err
+			})
+		})
+		stopDownload()
+		if err != nil {
+			return nil, fmt.Errorf("streaming layer %d: %w", i, err)
+		}
+		pkg.Comment = "Container image layer from registry"
+		pkg.BuildID(img.Digest, pkg.Name)
+		layerPackages = append(layerPackages, pkg)
+		progress.done()
+	}
+
+	if err := di.addStreamedOSPackages(opts, scanner, layerPackages); err != nil {
+		return nil, err
+	}
+
+	imagePackage := NewPackage()
+	imagePackage.Comment = "Container image streamed from registry"
+	if err := addLayerPackages(opts, imagePackage, layerPackages); err != nil {
+		return nil, err
+	}
+	di.recordImageHistory(opts, config, manifest.Annotations, imagePackage, layerPackages)
+	return imagePackage, nil
+}
+
+// addStreamedOSPackages adds the OS packages found by scanner in the
+// layers streamed to the package of the layer where they were installed.
+// Nothing is done when scanner is nil.
+func (di *spdxDefaultImplementation) addStreamedOSPackages(
+	opts *Options, scanner *osinfo.StreamScanner, layerPackages []*Package,
+) error {
+	if scanner == nil {
+		return nil
+	}
+	stopOSScan := di.stats.start(opts, phaseOSScan)
+	layerNum, osPackageData, err := scanner.ReadOSPackages()
+	stopOSScan()
+	if err != nil {
+		return fmt.Errorf("getting os data from container: %w", err)
+	}
+	if osPackageData == nil {
+		return nil
+	}
+	logger(opts).Infof(
+		"Scan of container image returned %d OS packages, database last updated in layer #%d",
+		len(*osPackageData), layerNum,
+	)
+	for j := range *osPackageData {
+		entry := &(*osPackageData)[j]
+		if entry.Layer < 0 || entry.Layer >= len(layerPackages) {
+			continue
+		}
+		ospk := osPackageFromDBEntry(entry)
+		ospk.BuildID(layerPackages[entry.Layer].ID)
+		if err := layerPackages[entry.Layer].addTemplatedPackage(ospk, opts.LayerPackageRelationship); err != nil {
+			return fmt.Errorf("adding OS package to container layer: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRefToPackageStreamRegistryImages(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	// The OS is described in the first layer, the packages in the others
+	var osLayer bytes.Buffer
+	tw := tar.NewWriter(&osLayer)
+	osRelease := []byte("PRETTY_NAME=\"Debian GNU/Linux 11 (bullseye)\"\nNAME=\"Debian GNU/Linux\"\nID=debian\n")
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name: "etc/os-release", Mode: 0o644, Size: int64(len(osRelease)), Typeflag: tar.TypeReg,
+	}))
+	_, err := tw.Write(osRelease)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	layers := []v1.Layer{}
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(osLayer.Bytes())), nil
+	})
+	require.NoError(t, err)
+	layers = append(layers, layer)
+	for _, f := range []string{"dpkg-layer1.tar.gz", "dpkg-layer2.tar.gz"} {
+		layer, err := tarball.LayerFromFile(filepath.Join("../osinfo/testdata", f))
+		require.NoError(t, err)
+		layers = append(layers, layer)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/image:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	// layerContents returns the files and OS packages of each layer
+	layerContents := func(pkg *Package) (files map[string]map[string]string, packages map[string][]string) {
+		files = map[string]map[string]string{}
+		packages = map[string][]string{}
+		for _, rel := range pkg.Relationships {
+			layer, ok := rel.Peer.(*Package)
+			if !ok {
+				continue
+			}
+			for _, f := range layer.Files() {
+				files[layer.Name+"/"+f.Name] = f.Checksum
+			}
+			packages[layer.Name] = []string{}
+			for _, lrel := range layer.Relationships {
+				if ospk, ok := lrel.Peer.(*Package); ok {
+					packages[layer.Name] = append(packages[layer.Name], ospk.Name+"@"+ospk.Version)
+				}
+			}
+			sort.Strings(packages[layer.Name])
+		}
+		return files, packages
+	}
+
+	impl := spdxDefaultImplementation{}
+	tempDir := t.TempDir()
+	streamPkg, err := impl.ImageRefToPackage(context.Background(), ref.String(), &Options{
+		StreamRegistryImages: true, ScanImages: true, TempDir: tempDir,
+	})
+	require.NoError(t, err)
+	pullPkg, err := impl.ImageRefToPackage(context.Background(), ref.String(), &Options{
+		AddTarFiles: true, ScanImages: true,
+	})
+	require.NoError(t, err)
+
+	// Nothing was written to disk
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// Streaming describes the same image, files and packages as pulling it
+	require.Equal(t, pullPkg.Name, streamPkg.Name)
+	require.Equal(t, pullPkg.Checksum, streamPkg.Checksum)
+	streamFiles, streamPackages := layerContents(streamPkg)
+	pullFiles, pullPackages := layerContents(pullPkg)
+	require.Len(t, streamFiles, 3)
+	require.Equal(t, pullFiles, streamFiles)
+	require.Len(t, streamPackages, 3)
+	require.Equal(t, pullPackages, streamPackages)
+	total := 0
+	for _, pkgs := range streamPackages {
+		total += len(pkgs)
+	}
+	require.NotZero(t, total)
+
+	// Options needing the layers on disk pull the image
+	tempDir = t.TempDir()
+	opts := &Options{StreamRegistryImages: true, AddTarFiles: true, TempDir: tempDir, CollectWarnings: true}
+	fallbackPkg, err := impl.ImageRefToPackage(context.Background(), ref.String(), opts)
+	require.NoError(t, err)
+	fallbackFiles, _ := layerContents(fallbackPkg)
+	require.Equal(t, pullFiles, fallbackFiles)
+	require.NotEmpty(t, impl.Warnings())
+	require.Contains(t, impl.Warnings()[0].Message, "Not streaming the layers")
+}
@@ -72,6 +72,27 @@ const (
 	OTHER                       RelationshipType = "OTHER"
 )
 
+// RelationshipTemplate overrides the type and comment of a relationship
+// generated by the library. Empty fields keep the default values.
+type RelationshipTemplate struct {
+	Type    RelationshipType // Relationship type to use instead of the default
+	Comment string           // Comment to use instead of the default
+}
+
+// Apply sets the template values in a relationship
+func (rt *RelationshipTemplate) Apply(rel *Relationship) *Relationship {
+	if rt == nil {
+		return rel
+	}
+	if rt.Type != "" {
+		rel.Type = rt.Type
+	}
+	if rt.Comment != "" {
+		rel.Comment = rt.Comment
+	}
+	return rel
+}
+
 type Relationship struct {
 	FullRender       bool             // Flag, then true the package will be rendered in the doc
 	PeerReference    string           // SPDX Ref of the peer object. Will override the ID of provided package if set
@@ -79,9 +100,16 @@ type Relationship struct {
 	Comment          string           // Relationship ship commnet
 	Type             RelationshipType // Relationship of the specified package
 	Peer             Object           // SPDX object that acts as peer
+	Inverse          bool             // Render the relationship from the peer to the host object (eg peer TEST_DEPENDENCY_OF host)
 }
 
 func (ro *Relationship) Render(hostObject Object) (string, error) {
+	return ro.render(hostObject, 0)
+}
+
+// render renders the relationship of a host object found depth levels
+// down the tree being rendered
+func (ro *Relationship) render(hostObject Object, depth int) (string, error) {
 	// We can render the relationship from an object or from a
 	// predefined entity reference. But we have to have on of them
 	if ro.Peer == nil && ro.PeerReference == "" {
@@ -108,7 +136,13 @@ func (ro *Relationship) Render(hostObject Object) (string, error) {
 
 	docFragment := ""
 	if ro.FullRender {
-		objDoc, err := ro.Peer.Render()
+		var objDoc string
+		var err error
+		if pkg, ok := ro.Peer.(*Package); ok {
+			objDoc, err = pkg.render(depth + 1)
+		} else {
+			objDoc, err = ro.Peer.Render()
+		}
 		if err != nil {
 			return "", fmt.Errorf("rendering related object %s: %w", hostObject.SPDXID(), err)
 		}
@@ -118,7 +152,14 @@ func (ro *Relationship) Render(hostObject Object) (string, error) {
 	if ro.PeerExtReference != "" {
 		peerExtRef = fmt.Sprintf("DocumentRef-%s:", ro.PeerExtReference)
 	}
-	if ro.Peer != nil {
+	if ro.Inverse {
+		if ro.Peer == nil || peerExtRef != "" {
+			return "", errors.New("unable to render inverse relationship, peer object has to be set in the document")
+		}
+		docFragment += fmt.Sprintf(
+			"Relationship: %s %s %s\n", ro.Peer.SPDXID(), ro.Type, hostObject.SPDXID(),
+		)
+	} else if ro.Peer != nil {
 		docFragment += fmt.Sprintf(
 			"Relationship: %s %s %s%s\n", hostObject.SPDXID(), ro.Type, peerExtRef, ro.Peer.SPDXID(),
 		)
@@ -129,3 +170,65 @@ func (ro *Relationship) Render(hostObject Object) (string, error) {
 	}
 	return docFragment, nil
 }
+
+// defaultMaxRelationshipDepth is the maximum number of levels packages can
+// be nested through relationships when their options don't set one
+const defaultMaxRelationshipDepth = 1000
+
+// ErrMaxRelationshipDepth is returned when a package tree is nested
+// deeper than the MaxRelationshipDepth of its object options
+var ErrMaxRelationshipDepth = errors.New("maximum relationship depth exceeded")
+
+// relationshipDepthLimit returns the maximum depth of the tree under a
+// package, read from its object options. Zero or less means no limit.
+func relationshipDepthLimit(pkg *Package) int {
+	if pkg.Opts == nil || pkg.Opts.MaxRelationshipDepth == 0 {
+		return defaultMaxRelationshipDepth
+	}
+	return pkg.Opts.MaxRelationshipDepth
+}
+
+// checkRelationshipDepth returns an error if the tree of packages under
+// pkg, placed levels down a tree, is deeper than limit
+func checkRelationshipDepth(limit int, pkg *Package, levels int) error {
+	if limit <= 0 {
+		return nil
+	}
+	if levels+packageTreeHeight(pkg, limit-levels, map[*Package]int{}) > limit {
+		return fmt.Errorf(
+			"%w: package %s nests more than %d levels",
+			ErrMaxRelationshipDepth, pkg.SPDXID(), limit,
+		)
+	}
+	return nil
+}
+
+// packageTreeHeight returns the number of levels of packages related to
+// pkg. The search stops once the height is past limit so it never recurses
+// deeper than the limit. Packages already being measured (cycles) are not
+// followed again.
+func packageTreeHeight(pkg *Package, limit int, heights map[*Package]int) int {
+	if h, ok := heights[pkg]; ok {
+		return h
+	}
+	heights[pkg] = 0
+	height := 0
+	for _, rel := range pkg.Relationships {
+		peer, ok := rel.Peer.(*Package)
+		if !ok || peer == nil {
+			continue
+		}
+		if limit <= 0 {
+			height = 1
+			break
+		}
+		if h := 1 + packageTreeHeight(peer, limit-1, heights); h > height {
+			height = h
+		}
+		if height > limit {
+			break
+		}
+	}
+	heights[pkg] = height
+	return height
+}
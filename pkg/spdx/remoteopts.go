@@ -0,0 +1,171 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// keychain returns the keychain to authenticate to registries, the one
+// in the options or the default one reading the docker config
+func keychain(opts *Options) authn.Keychain {
+	if opts != nil && opts.Keychain != nil {
+		return opts.Keychain
+	}
+	return authn.DefaultKeychain
+}
+
+// maxRegistryTransports is the number of options whose registry transport
+// is kept for reuse
+const maxRegistryTransports = 16
+
+// registryTransports keeps the transports built for the options, so the CA
+// bundle is read once per options and their requests share connections
+var registryTransports transportCache
+
+type transportCache struct {
+	sync.Mutex
+	entries map[*Options]transportCacheEntry
+	order   []*Options // Options in the order they were added, to evict the oldest
+}
+
+// transportCacheEntry is a transport with the settings it was built from,
+// to build it again if they change in the options
+type transportCacheEntry struct {
+	caFile    string
+	insecure  bool
+	transport http.RoundTripper
+}
+
+// get returns the transport built for the options, building it if it is not
+// in the cache or the options changed since
+func (tc *transportCache) get(opts *Options) (http.RoundTripper, error) {
+	tc.Lock()
+	defer tc.Unlock()
+	if entry, ok := tc.entries[opts]; ok {
+		if entry.caFile == opts.RegistryCAFile && entry.insecure == opts.InsecureRegistries {
+			return entry.transport, nil
+		}
+	} else {
+		if len(tc.order) >= maxRegistryTransports {
+			delete(tc.entries, tc.order[0])
+			tc.order = tc.order[1:]
+		}
+		tc.order = append(tc.order, opts)
+	}
+
+	t, err := newRegistryTransport(opts)
+	if err != nil {
+		delete(tc.entries, opts)
+		for i := range tc.order {
+			if tc.order[i] == opts {
+				tc.order = append(tc.order[:i], tc.order[i+1:]...)
+				break
+			}
+		}
+		return nil, err
+	}
+	if tc.entries == nil {
+		tc.entries = map[*Options]transportCacheEntry{}
+	}
+	tc.entries[opts] = transportCacheEntry{
+		caFile:    opts.RegistryCAFile,
+		insecure:  opts.InsecureRegistries,
+		transport: t,
+	}
+	return t, nil
+}
+
+// registryTransport returns the transport for the requests to registries.
+// It is nil when the default one of go-containerregistry is to be used.
+func registryTransport(opts *Options) (http.RoundTripper, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	if opts.Transport != nil {
+		return opts.Transport, nil
+	}
+	if opts.RegistryCAFile == "" && !opts.InsecureRegistries {
+		return nil, nil
+	}
+	return registryTransports.get(opts)
+}
+
+// newRegistryTransport builds a transport trusting the CA bundle of the
+// options or, if they allow insecure registries, skipping the verification
+func newRegistryTransport(opts *Options) (*http.Transport, error) {
+	base, ok := remote.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("default registry transport is not an http transport")
+	}
+	t := base.Clone()
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.TLSClientConfig != nil {
+		tlsConfig = t.TLSClientConfig.Clone()
+	}
+	if opts.RegistryCAFile != "" {
+		pem, err := os.ReadFile(opts.RegistryCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading registry CA bundle: %w", err)
+		}
+		// The bundle is trusted in addition to the system roots
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.RegistryCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.InsecureRegistries {
+		tlsConfig.InsecureSkipVerify = true //nolint:gosec // Requested in the options
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// remoteOptions returns the options for the requests to registries. The
+// RemoteOptions of the options go after the ones built by bom, so they
+// override them.
+func remoteOptions(ctx context.Context, opts *Options) ([]remote.Option, error) {
+	remoteOpts := []remote.Option{
+		remote.WithAuthFromKeychain(keychain(opts)),
+		remote.WithContext(ctx),
+	}
+	t, err := registryTransport(opts)
+	if err != nil {
+		return nil, fmt.Errorf("building registry transport: %w", err)
+	}
+	if t != nil {
+		remoteOpts = append(remoteOpts, remote.WithTransport(t))
+	}
+	if opts != nil {
+		remoteOpts = append(remoteOpts, opts.RemoteOptions...)
+	}
+	return remoteOpts, nil
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullImagesToArchiveKeychain(t *testing.T) {
+	// The registry only serves requests with the right credentials
+	regHandler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "bom" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		regHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	auth := authn.FromConfig(authn.AuthConfig{Username: "bom", Password: "secret"})
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/image:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithAuth(auth)))
+
+	// Without the keychain the requests are anonymous
+	impl := spdxDefaultImplementation{}
+	_, err = impl.PullImagesToArchive(context.Background(), &Options{}, ref.String(), t.TempDir())
+	require.Error(t, err)
+	require.Error(t, PullImageToArchiveWithOptions(
+		context.Background(), &Options{}, ref.String(), filepath.Join(t.TempDir(), "image.tar"),
+	))
+
+	opts := &Options{Keychain: staticKeychain{auth: auth}}
+	refs, err := impl.PullImagesToArchive(context.Background(), opts, ref.String(), t.TempDir())
+	require.NoError(t, err)
+	require.FileExists(t, refs.Archive)
+	tarPath := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, PullImageToArchiveWithOptions(context.Background(), opts, ref.String(), tarPath))
+	require.FileExists(t, tarPath)
+}
+
+func TestPullImagesToArchiveTLS(t *testing.T) {
+	server := httptest.NewTLSServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "https://") + "/test/image:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithTransport(server.Client().Transport)))
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: server.Certificate().Raw,
+	}), os.FileMode(0o644)))
+
+	pull := func(opts *Options) error {
+		impl := spdxDefaultImplementation{}
+		_, err := impl.PullImagesToArchive(context.Background(), opts, ref.String(), t.TempDir())
+		return err
+	}
+
+	// The certificate of the registry is not trusted by default
+	require.Error(t, pull(&Options{}))
+
+	for _, opts := range []*Options{
+		{RegistryCAFile: caFile},
+		{InsecureRegistries: true},
+		{Transport: server.Client().Transport},
+	} {
+		require.NoError(t, pull(opts))
+	}
+
+	// CA bundles must hold certificates
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), os.FileMode(0o644)))
+	require.Error(t, pull(&Options{RegistryCAFile: caFile}))
+	require.Error(t, pull(&Options{RegistryCAFile: filepath.Join(t.TempDir(), "missing.pem")}))
+}
+
+func TestRegistryTransportReuse(t *testing.T) {
+	opts := &Options{InsecureRegistries: true}
+	first, err := registryTransport(opts)
+	require.NoError(t, err)
+	second, err := registryTransport(opts)
+	require.NoError(t, err)
+	require.Same(t, first, second)
+
+	// Other options get their own transport
+	other, err := registryTransport(&Options{InsecureRegistries: true})
+	require.NoError(t, err)
+	require.NotSame(t, first, other)
+
+	// Changing the TLS settings of the options builds it again
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), os.FileMode(0o644)))
+	opts.RegistryCAFile = caFile
+	_, err = registryTransport(opts)
+	require.Error(t, err)
+	opts.RegistryCAFile = ""
+	third, err := registryTransport(opts)
+	require.NoError(t, err)
+	require.NotSame(t, first, third)
+
+	// The cache does not grow past its size
+	for i := 0; i < 2*maxRegistryTransports; i++ {
+		_, err := registryTransport(&Options{InsecureRegistries: true})
+		require.NoError(t, err)
+	}
+	registryTransports.Lock()
+	defer registryTransports.Unlock()
+	require.Len(t, registryTransports.entries, maxRegistryTransports)
+	require.Len(t, registryTransports.order, maxRegistryTransports)
+}
+
+func TestRemoteOptions(t *testing.T) {
+	regHandler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	var mtx sync.Mutex
+	userAgents := map[string]int{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		userAgents[strings.Fields(r.UserAgent())[0]]++
+		mtx.Unlock()
+		regHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "https://") + "/test/image:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img, remote.WithTransport(server.Client().Transport), remote.WithUserAgent("writer")))
+	expectedDigest, err := img.Digest()
+	require.NoError(t, err)
+
+	// The remote options go after the ones of bom: the transport trusting
+	// the test server replaces the default one
+	opts := &Options{RemoteOptions: []remote.Option{
+		remote.WithTransport(server.Client().Transport),
+		remote.WithUserAgent("bom-test"),
+	}}
+	mtx.Lock()
+	userAgents = map[string]int{}
+	mtx.Unlock()
+	impl := spdxDefaultImplementation{}
+	refs, err := impl.PullImagesToArchive(context.Background(), opts, ref.String(), t.TempDir())
+	require.NoError(t, err)
+	require.FileExists(t, refs.Archive)
+
+	digest, err := remoteDigest(context.Background(), opts, ref.String())
+	require.NoError(t, err)
+	require.Equal(t, expectedDigest.String(), digest)
+
+	// All the requests were sent with the options
+	mtx.Lock()
+	require.Len(t, userAgents, 1)
+	require.NotZero(t, userAgents["bom-test"])
+	mtx.Unlock()
+
+	// Without them the certificate of the server is not trusted
+	_, err = remoteDigest(context.Background(), &Options{}, ref.String())
+	require.Error(t, err)
+}
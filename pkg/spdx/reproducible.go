@@ -0,0 +1,272 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sourceDateEpochEnv is the variable setting the time recorded by
+// reproducible builds (https://reproducible-builds.org/specs/source-date-epoch/)
+const sourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// reproducibleTime returns the creation time recorded in reproducible
+// documents: created if set, else the time in SOURCE_DATE_EPOCH, else the
+// Unix epoch
+func reproducibleTime(created time.Time) (time.Time, error) {
+	if !created.IsZero() {
+		return created.UTC().Truncate(time.Second), nil
+	}
+	epoch := os.Getenv(sourceDateEpochEnv)
+	if epoch == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing %s: %w", sourceDateEpochEnv, err)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// reproducibleUUID returns a UUID derived from seed, the same one for the
+// same seed
+func reproducibleUUID(seed string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(seed)).String()
+}
+
+// contentSeed returns a digest of the elements the document describes:
+// the IDs, versions, verification codes and checksums of its top level
+// packages and files. The IDs ending with a random UUID are left out.
+func (d *Document) contentSeed() (string, error) {
+	entries := []string{}
+	for _, id := range d.sortedPackageIDs() {
+		p := d.Packages[id]
+		if err := p.ComputeVerificationCode(); err != nil {
+			return "", fmt.Errorf("computing the verification code of %s: %w", id, err)
+		}
+		entries = append(entries, stableID(&p.Entity)+" "+elementDescription(p))
+	}
+	for _, id := range d.sortedFileIDs() {
+		entries = append(entries, stableID(&d.Files[id].Entity)+" "+elementDescription(d.Files[id]))
+	}
+	sort.Strings(entries)
+	h := sha256.New()
+	for _, entry := range entries {
+		fmt.Fprintln(h, entry)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// stableID returns the ID of an entity, or nothing if it ends with a
+// random UUID
+func stableID(e *Entity) string {
+	if e.randomID {
+		return ""
+	}
+	return e.ID
+}
+
+// elementDescription describes the contents of a package or a file: its
+// name, version, verification code and checksums
+func elementDescription(o Object) string {
+	var b strings.Builder
+	var checksums map[string]string
+	switch e := o.(type) {
+	case *Package:
+		fmt.Fprintf(&b, "package %s %s %s", e.Name, e.Version, e.VerificationCode)
+		checksums = e.Checksum
+	case *File:
+		fmt.Fprintf(&b, "file %s", e.Name)
+		checksums = e.Checksum
+	}
+	algorithms := make([]string, 0, len(checksums))
+	for algorithm := range checksums {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	for _, algorithm := range algorithms {
+		fmt.Fprintf(&b, " %s:%s", algorithm, strings.ToLower(checksums[algorithm]))
+	}
+	return b.String()
+}
+
+// replaceRandomIDs replaces the random UUID ending the IDs of the elements
+// built without a usable seed (see BuildID) with a UUID derived from seed
+// and the contents of each element, and rewrites the references to them
+func (d *Document) replaceRandomIDs(seed string) {
+	type randomElement struct {
+		entity      *Entity
+		description string
+	}
+	elements := []randomElement{}
+	objects := []Object{}
+	seen := map[Object]struct{}{}
+	var walk func(o Object)
+	walk = func(o Object) {
+		if _, ok := seen[o]; ok {
+			return
+		}
+		seen[o] = struct{}{}
+		objects = append(objects, o)
+		var entity *Entity
+		switch e := o.(type) {
+		case *Package:
+			entity = &e.Entity
+		case *File:
+			entity = &e.Entity
+		}
+		if entity != nil && entity.randomID {
+			elements = append(elements, randomElement{entity, elementDescription(o)})
+		}
+		for _, rel := range *o.GetRelationships() {
+			if rel.Peer != nil {
+				walk(rel.Peer)
+			}
+		}
+	}
+	for _, p := range d.Packages {
+		walk(p)
+	}
+	for _, f := range d.Files {
+		walk(f)
+	}
+	if len(elements) == 0 {
+		return
+	}
+
+	// Elements with the same contents are told apart by their order
+	sort.SliceStable(elements, func(i, j int) bool {
+		return elements[i].description < elements[j].description
+	})
+	renamed := map[string]string{}
+	occurrences := map[string]int{}
+	for _, e := range elements {
+		n := occurrences[e.description]
+		occurrences[e.description]++
+		old := e.entity.ID
+		prefix := old[:len(old)-len(uuid.Nil.String())]
+		e.entity.SetSPDXID(prefix + reproducibleUUID(fmt.Sprintf("%s\n%s\n%d", seed, e.description, n)))
+		renamed[old] = e.entity.ID
+	}
+
+	for _, o := range objects {
+		for _, rel := range *o.GetRelationships() {
+			if id, ok := renamed[rel.PeerReference]; ok && rel.PeerExtReference == "" {
+				rel.PeerReference = id
+			}
+		}
+	}
+	packages := make(map[string]*Package, len(d.Packages))
+	for id, p := range d.Packages {
+		if newID, ok := renamed[id]; ok {
+			id = newID
+		}
+		packages[id] = p
+	}
+	d.Packages = packages
+	files := make(map[string]*File, len(d.Files))
+	for id, f := range d.Files {
+		if newID, ok := renamed[id]; ok {
+			id = newID
+		}
+		files[id] = f
+	}
+	d.Files = files
+}
+
+// makeReproducible rewrites what changes on every generation of the
+// document so that identical inputs produce the same output: the IDs of
+// its elements ending with a random UUID get one derived from its contents,
+// its relationships are sorted, its name and namespace (when not set) are
+// derived from seed, or from its contents when seed is empty, and it is
+// created at created. The annotations made by bom since the generation
+// started get the same date, older ones (eg image build steps) are kept.
+func (d *Document) makeReproducible(seed string, created, started time.Time) (err error) {
+	created, err = reproducibleTime(created)
+	if err != nil {
+		return err
+	}
+	content, err := d.contentSeed()
+	if err != nil {
+		return fmt.Errorf("deriving the document seed: %w", err)
+	}
+	d.replaceRandomIDs(content)
+	d.Canonicalize()
+
+	if seed == "" {
+		seed = content
+	}
+	if d.Name == "" {
+		d.Name = "SBOM-SPDX-" + reproducibleUUID(seed)
+	}
+	if d.Namespace == "" {
+		d.Namespace = "https://spdx.org/spdxdocs/k8s-releng-bom-" + reproducibleUUID(seed)
+	}
+	d.Created = created
+
+	annotator := toolAnnotator()
+	started = started.Truncate(time.Second)
+	date := created.Format(time.RFC3339)
+	setDates := func(annotations []Annotation) {
+		for i := range annotations {
+			if annotations[i].Annotator != annotator {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, annotations[i].Date)
+			if err != nil || t.Before(started) {
+				continue
+			}
+			annotations[i].Date = date
+		}
+	}
+	setDates(d.Annotations)
+
+	seen := map[Object]struct{}{}
+	var walk func(o Object)
+	walk = func(o Object) {
+		if _, ok := seen[o]; ok {
+			return
+		}
+		seen[o] = struct{}{}
+		switch e := o.(type) {
+		case *Package:
+			setDates(e.Annotations)
+		case *File:
+			setDates(e.Annotations)
+		}
+		for _, rel := range *o.GetRelationships() {
+			if rel.Peer != nil {
+				walk(rel.Peer)
+			}
+		}
+	}
+	for _, id := range d.sortedPackageIDs() {
+		walk(d.Packages[id])
+	}
+	for _, id := range d.sortedFileIDs() {
+		walk(d.Files[id])
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReproducibleDocument(t *testing.T) {
+	t.Setenv(sourceDateEpochEnv, "1700000000")
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"README.md":   "# Reproducible\n",
+		"main.go":     "package main\n",
+		"sub/data.db": "data",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), os.FileMode(0o644)))
+	}
+
+	// generate scans the directory into a reproducible document
+	generate := func(genopts *DocGenerateOptions) *Document {
+		started := time.Now()
+		impl := &defaultDocBuilderImpl{}
+		spdx := &SPDX{impl: &spdxDefaultImplementation{}, options: &Options{SkipLicenseScan: true, Reproducible: true}}
+		doc, err := impl.CreateDocument(genopts, nil)
+		require.NoError(t, err)
+		require.Equal(t, genopts.Namespace, doc.Namespace)
+		require.NoError(t, impl.ScanDirectories(genopts, spdx, doc))
+
+		// Annotations made while generating are dated at the creation
+		// time, older ones are kept
+		for _, pkg := range doc.Packages {
+			pkg.AddAnnotation(newToolAnnotation("generated"))
+			pkg.AddAnnotation(Annotation{
+				Annotator: toolAnnotator(), Date: "2020-01-02T03:04:05Z", Type: AnnotationTypeOther, Comment: "build step",
+			})
+		}
+		require.NoError(t, doc.makeReproducible(genopts.ReproducibleSeed, genopts.Created, started))
+		return doc
+	}
+	render := func(doc *Document) string {
+		out, err := doc.Render()
+		require.NoError(t, err)
+		return out
+	}
+
+	genopts := &DocGenerateOptions{Directories: []string{dir}, Reproducible: true}
+	doc := generate(genopts)
+	first := render(doc)
+	require.Regexp(t, "^SBOM-SPDX-[0-9a-f-]{36}$", doc.Name)
+	require.Regexp(t, "^https://spdx.org/spdxdocs/k8s-releng-bom-[0-9a-f-]{36}$", doc.Namespace)
+	require.Contains(t, first, "Created: 2023-11-14T22:13:20Z\n")
+	require.Contains(t, first, "AnnotationDate: 2023-11-14T22:13:20Z\n")
+	require.Contains(t, first, "AnnotationDate: 2020-01-02T03:04:05Z\n")
+
+	// Identical inputs give the same document, byte for byte
+	time.Sleep(time.Second)
+	require.Equal(t, first, render(generate(genopts)))
+
+	// The namespace changes with the contents, or follows the seed set
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package other\n"), os.FileMode(0o644)))
+	changed := generate(genopts)
+	require.NotEqual(t, doc.Namespace, changed.Namespace)
+	seeded := generate(&DocGenerateOptions{
+		Directories: []string{dir}, Reproducible: true, ReproducibleSeed: "release-1.0",
+		Created: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+	})
+	require.Equal(t, "https://spdx.org/spdxdocs/k8s-releng-bom-"+reproducibleUUID("release-1.0"), seeded.Namespace)
+	require.Contains(t, render(seeded), "Created: 2024-05-06T07:08:09Z\n")
+
+	// A namespace set is kept
+	named := generate(&DocGenerateOptions{
+		Directories: []string{dir}, Reproducible: true, Namespace: "https://example.com/sbom", Name: "named",
+	})
+	require.Equal(t, "https://example.com/sbom", named.Namespace)
+	require.Equal(t, "named", named.Name)
+}
+
+func TestReproducibleRandomIDs(t *testing.T) {
+	// build returns a document whose elements have no name to seed their
+	// IDs, the file being referenced by its ID only
+	build := func() *Document {
+		doc := NewDocument()
+		pkg := NewPackage()
+		pkg.BuildID()
+		pkg.FilesAnalyzed = true
+		f := NewFile()
+		f.BuildID()
+		f.Checksum = map[string]string{"SHA1": strings.Repeat("a", 40)}
+		require.NoError(t, pkg.AddFile(f))
+		require.NoError(t, doc.AddPackage(pkg))
+		other := NewPackage()
+		other.Name = "other"
+		other.BuildID("other")
+		other.AddRelationship(&Relationship{PeerReference: f.SPDXID(), Type: DEPENDS_ON})
+		require.NoError(t, doc.AddPackage(other))
+		return doc
+	}
+	ids := func(doc *Document) []string {
+		ids := []string{}
+		for id, p := range doc.Packages {
+			ids = append(ids, id)
+			for _, f := range p.Files() {
+				ids = append(ids, f.SPDXID())
+			}
+			for _, rel := range p.Relationships {
+				if rel.PeerReference != "" {
+					ids = append(ids, rel.PeerReference)
+				}
+			}
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	first, second := build(), build()
+	require.NotEqual(t, ids(first), ids(second))
+	require.NoError(t, first.makeReproducible("", time.Time{}, time.Now()))
+	require.NoError(t, second.makeReproducible("", time.Time{}, time.Now()))
+	require.Equal(t, ids(first), ids(second))
+	require.Equal(t, first.Namespace, second.Namespace)
+
+	// The references by ID follow the new IDs, and the document is keyed
+	// by them
+	var named, unnamed *Package
+	for id, p := range first.Packages {
+		require.Equal(t, id, p.SPDXID())
+		if p.Name == "other" {
+			named = p
+		} else {
+			unnamed = p
+		}
+	}
+	require.Equal(t, unnamed.Files()[0].SPDXID(), named.Relationships[0].PeerReference)
+
+	// Split documents without a namespace get one derived from the
+	// contents, not a random one
+	first, second = build(), build()
+	first.Namespace, second.Namespace = "", ""
+	require.Equal(t, path.Dir(first.Split()[0].Namespace), path.Dir(second.Split()[0].Namespace))
+}
+
+func TestReproducibleTime(t *testing.T) {
+	t.Setenv(sourceDateEpochEnv, "")
+	created, err := reproducibleTime(time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(0, 0).UTC(), created)
+
+	t.Setenv(sourceDateEpochEnv, "1700000000")
+	created, err = reproducibleTime(time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1700000000, 0).UTC(), created)
+
+	// The time set wins over the environment
+	set := time.Date(2024, 5, 6, 7, 8, 9, 500, time.UTC)
+	created, err = reproducibleTime(set)
+	require.NoError(t, err)
+	require.Equal(t, set.Truncate(time.Second), created)
+
+	t.Setenv(sourceDateEpochEnv, "yesterday")
+	_, err = reproducibleTime(time.Time{})
+	require.Error(t, err)
+}
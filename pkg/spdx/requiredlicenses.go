@@ -0,0 +1,80 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MissingLicenseError is returned by the package builders when the options
+// require licenses and some of the packages or files described have none
+type MissingLicenseError struct {
+	Package  string   // Name of the package built
+	Elements []string // Packages and files without a license, eg "file cmd/main.go"
+}
+
+func (e *MissingLicenseError) Error() string {
+	return fmt.Sprintf(
+		"%d elements of %s have no license: %s",
+		len(e.Elements), e.Package, strings.Join(e.Elements, ", "),
+	)
+}
+
+// checkRequiredLicenses returns a *MissingLicenseError listing the
+// packages and files related to pkg, and pkg itself, whose concluded
+// license is empty or NOASSERTION, when the options require licenses.
+// Packages with no concluded license pass if they declare one.
+func checkRequiredLicenses(opts *Options, pkg *Package) error {
+	if !opts.RequireLicenses {
+		return nil
+	}
+	missing := []string{}
+	seen := map[Object]struct{}{}
+	var walk func(o Object)
+	walk = func(o Object) {
+		if _, ok := seen[o]; ok {
+			return
+		}
+		seen[o] = struct{}{}
+		switch e := o.(type) {
+		case *Package:
+			if !licenseDetermined(e.LicenseConcluded) && !licenseDetermined(e.LicenseDeclared) {
+				missing = append(missing, "package "+e.Name)
+			}
+		case *File:
+			if !licenseDetermined(e.LicenseConcluded) {
+				missing = append(missing, "file "+e.Name)
+			}
+		}
+		for _, rel := range *o.GetRelationships() {
+			if rel.Peer != nil {
+				walk(rel.Peer)
+			}
+		}
+	}
+	walk(pkg)
+	if len(missing) == 0 {
+		return nil
+	}
+	return &MissingLicenseError{Package: pkg.Name, Elements: missing}
+}
+
+// licenseDetermined returns true if a license field holds a license
+func licenseDetermined(license string) bool {
+	return license != "" && license != NOASSERTION
+}
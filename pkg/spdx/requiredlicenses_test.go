@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRequiredLicenses(t *testing.T) {
+	pkg := NewPackage()
+	pkg.Name = "project"
+	pkg.BuildID("project")
+	pkg.LicenseConcluded = NOASSERTION
+	pkg.LicenseDeclared = "Apache-2.0"
+	for name, lic := range map[string]string{
+		"main.go": "Apache-2.0", "README.md": NOASSERTION, "data.json": "",
+	} {
+		f := NewFile()
+		f.Name = name
+		f.BuildID("project", name)
+		f.LicenseConcluded = lic
+		require.NoError(t, pkg.AddFile(f))
+	}
+	dep := NewPackage()
+	dep.Name = "dependency"
+	dep.BuildID("dependency")
+	dep.LicenseConcluded = NOASSERTION
+	require.NoError(t, pkg.AddDependency(dep))
+
+	// Nothing is checked unless the options require licenses
+	require.NoError(t, checkRequiredLicenses(&Options{}, pkg))
+
+	err := checkRequiredLicenses(&Options{RequireLicenses: true}, pkg)
+	missingErr := &MissingLicenseError{}
+	require.ErrorAs(t, err, &missingErr)
+	require.Equal(t, "project", missingErr.Package)
+	require.ElementsMatch(t, []string{"file README.md", "file data.json", "package dependency"}, missingErr.Elements)
+	require.Contains(t, err.Error(), "3 elements of project have no license")
+
+	dep.LicenseConcluded = "MIT"
+	for _, f := range pkg.Files() {
+		f.LicenseConcluded = NONE
+	}
+	require.NoError(t, checkRequiredLicenses(&Options{RequireLicenses: true}, pkg))
+}
+
+func TestPackageFromFileRequireLicenses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(path, []byte("no license here\n"), os.FileMode(0o644)))
+
+	// Files not classified have no license
+	opts := defaultSPDXOptions
+	sut := &SPDX{impl: &spdxDefaultImplementation{}, options: &opts}
+	sut.Options().SkipLicenseScan = true
+	_, err := sut.PackageFromFile(path)
+	require.NoError(t, err)
+
+	sut.Options().RequireLicenses = true
+	_, err = sut.PackageFromFile(path)
+	missingErr := &MissingLicenseError{}
+	require.ErrorAs(t, err, &missingErr)
+	require.ElementsMatch(t, []string{"package notes.txt", "file notes.txt"}, missingErr.Elements)
+}
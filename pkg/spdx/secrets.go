@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// redactedValue replaces the values of secrets in the SBOM data
+const redactedValue = "[REDACTED]"
+
+// maxSecretFileSize is the size of the largest file whose contents are
+// read to confirm it holds a private key
+const maxSecretFileSize = 64 * 1024
+
+// secretFiles are the files which usually hold credentials, matched
+// against the end of their path in the layer
+var secretFiles = map[string]string{
+	".aws/credentials":                     "AWS credentials file",
+	".docker/config.json":                  "Docker registry credentials",
+	".git-credentials":                     "Git credentials",
+	".netrc":                               "netrc credentials",
+	".npmrc":                               "npm registry credentials",
+	".pypirc":                              "PyPI registry credentials",
+	".kube/config":                         "Kubernetes client credentials",
+	"application_default_credentials.json": "Google Cloud credentials",
+	"id_rsa":                               "SSH private key",
+	"id_dsa":                               "SSH private key",
+	"id_ecdsa":                             "SSH private key",
+	"id_ed25519":                           "SSH private key",
+}
+
+// secretEnvRe matches the names of environment variables holding secrets
+var secretEnvRe = regexp.MustCompile(
+	`(?i)(SECRET|TOKEN|PASSWORD|PASSWD|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIAL)`,
+)
+
+// secretAssignmentRe matches assignments of secret looking variables in
+// commands, as recorded in the image history (ENV KEY=value)
+var secretAssignmentRe = regexp.MustCompile(
+	`(?i)\b(\w*(?:SECRET|TOKEN|PASSWORD|PASSWD|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIAL)\w*)=("[^"]*"|'[^']*'|\S+)`,
+)
+
+// secretFileKind returns the kind of credentials a file with the
+// specified path in a layer is likely to hold, or "" if none
+func secretFileKind(filePath string) string {
+	filePath = "/" + strings.TrimPrefix(filePath, "./")
+	for suffix, kind := range secretFiles {
+		if strings.HasSuffix(filePath, "/"+suffix) {
+			return kind
+		}
+	}
+	base := path.Base(filePath)
+	if base == ".env" || strings.HasPrefix(base, ".env.") {
+		return "dotenv file"
+	}
+	return ""
+}
+
+// isPrivateKeyFile checks if a key or certificate file holds a private key
+func isPrivateKeyFile(filePath string, r io.Reader, size int64) bool {
+	ext := path.Ext(filePath)
+	if (ext != ".pem" && ext != ".key") || size > maxSecretFileSize {
+		return false
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxSecretFileSize))
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(data, []byte("PRIVATE KEY-----"))
+}
+
+// reportSecret records a security finding. Findings name the element
+// where the secret is, never its value.
+func (di *spdxDefaultImplementation) reportSecret(opts *Options, element, message string) {
+	logger(opts).Warnf("Security finding: %s: %s", element, message)
+	di.warnings.add(opts, Warning{Element: element, Message: message, Security: true})
+}
+
+// scanLayerSecrets reports the files in a layer that look like they
+// contain credentials. element identifies the layer in the findings.
+func (di *spdxDefaultImplementation) scanLayerSecrets(opts *Options, element, layerPath string) error {
+	f, err := os.Open(layerPath)
+	if err != nil {
+		return fmt.Errorf("opening layer: %w", err)
+	}
+	defer f.Close()
+
+	tr, err := newTarReader(f)
+	if err != nil {
+		return err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading layer: %w", err)
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		kind := secretFileKind(name)
+		if kind == "" && isPrivateKeyFile(name, tr, hdr.Size) {
+			kind = "private key"
+		}
+		if kind != "" {
+			di.reportSecret(opts, element+":/"+name, fmt.Sprintf("file looks like a %s", kind))
+		}
+	}
+}
+
+// scanConfigSecrets reports the environment variables of an image config
+// that look like credentials and redacts the secrets from the commands of
+// its history, which are recorded in the SBOM.
+func (di *spdxDefaultImplementation) scanConfigSecrets(opts *Options, element string, config *v1.ConfigFile) {
+	for _, env := range config.Config.Env {
+		name, value, _ := strings.Cut(env, "=")
+		if value != "" && secretEnvRe.MatchString(name) {
+			di.reportSecret(opts, element, fmt.Sprintf("environment variable %s looks like a credential", name))
+		}
+	}
+	for i := range config.History {
+		config.History[i].CreatedBy = redactSecrets(config.History[i].CreatedBy)
+	}
+}
+
+// redactSecrets replaces the values assigned to variables that look like
+// credentials in a command
+func redactSecrets(command string) string {
+	return secretAssignmentRe.ReplaceAllString(command, "$1="+redactedValue)
+}
@@ -17,19 +17,27 @@ limitations under the License.
 package spdx
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/uuid"
 	purl "github.com/package-url/packageurl-go"
 	"github.com/sirupsen/logrus"
 
+	"sigs.k8s.io/bom/pkg/fsimage"
+	"sigs.k8s.io/bom/pkg/license"
+	"sigs.k8s.io/bom/pkg/osinfo"
 	"sigs.k8s.io/release-utils/util"
 )
 
@@ -49,6 +57,47 @@ const (
 	entOrganization = "Organization"
 
 	CatPackageManager = "PACKAGE-MANAGER"
+	CatSecurity       = "SECURITY"
+
+	// advisoryRefType is the type of the security external references
+	// pointing to an advisory about the package
+	advisoryRefType = "advisory"
+
+	// emptyFileAnnotation is the comment annotating zero-byte files
+	emptyFileAnnotation = "Zero-byte file"
+
+	// goModuleSumAnnotation prefixes the go.sum hash (h1:) of the modules
+	// compiled into Go binaries in their annotations
+	goModuleSumAnnotation = "Go module sum: "
+
+	// emptyDirectoryAnnotation prefixes the path of each empty directory
+	// in the annotations of the packages of directories
+	emptyDirectoryAnnotation = "Empty directory: "
+
+	// unknownPlatformAnnotation is the comment annotating images whose
+	// platform could not be read from their config
+	unknownPlatformAnnotation = "Image platform could not be determined"
+
+	// installReasonAnnotation prefixes the annotation recording why an
+	// OS package was installed (see osinfo.InstallReasonExplicit)
+	installReasonAnnotation = "Install reason: "
+
+	// imageTagAnnotation prefixes the annotation recording the tag of
+	// references that name both a tag and a digest
+	imageTagAnnotation = "Image reference tag: "
+
+	// implicitTagAnnotation notes the tag of an image was not in the
+	// reference, it was defaulted when resolving it
+	implicitTagAnnotation = "Image reference had no tag, defaulted to: "
+
+	// directoryNameAnnotation prefixes the annotation recording the name
+	// of a directory whose package is named after its go module
+	directoryNameAnnotation = "Directory name: "
+
+	// licenseClassifierAnnotation and licenseListAnnotation prefix the
+	// document annotations recording the versions used to detect licenses
+	licenseClassifierAnnotation = "License classifier version: "
+	licenseListAnnotation       = "SPDX license list version: "
 
 	termBanner = `ICAgICAgICAgICAgICAgXyAgICAgIAogX19fIF8gX18gICBfX3wgfF8gIF9fCi8gX198ICdfIFwg
 LyBfYCBcIFwvIC8KXF9fIFwgfF8pIHwgKF98IHw+ICA8IAp8X19fLyAuX18vIFxfXyxfL18vXF9c
@@ -56,25 +105,43 @@ CiAgICB8X3wgICAgICAgICAgICAgICAK`
 )
 
 var (
+	// zipArchiveExtensions are the file extensions of archives read as zip files
+	zipArchiveExtensions = []string{".zip", ".jar", ".war", ".ear", ".aar", ".whl", ".nupkg"}
+
 	// https://spdx.github.io/spdx-spec/3-package-information/#32-package-spdx-identifier
 	validIDCharsRe          = regexp.MustCompile(`[^a-zA-Z0-9-.]+`)
 	SupportedHashAlgorithms = []string{"SHA1", "SHA256", "SHA25"}
 )
 
 type SPDX struct {
-	impl    spdxImplementation
-	options *Options
+	impl           spdxImplementation
+	options        *Options
+	goDependencies goDependencySet // Go dependencies described, when deduplicating them
 }
 
 // ImageReferenceInfo is a type to move information about a container image reference
 type ImageReferenceInfo struct {
 	Digest    string
 	Reference string
+	Tag       string
 	Archive   string
 	Arch      string
 	OS        string
+	Variant   string
 	MediaType string
 	Images    []ImageReferenceInfo
+
+	// ArtifactType is the type of the OCI artifact the manifest stores
+	// when it is not an image, eg a Helm chart
+	ArtifactType string
+
+	// ImplicitTag is set when the reference named no tag nor digest and
+	// the default tag (latest) was resolved
+	ImplicitTag bool
+
+	// Attestations lists the manifests of an index that are not images
+	// but signatures or attestations of them. They are not pulled.
+	Attestations []ImageReferenceInfo
 }
 
 func NewSPDX() *SPDX {
@@ -91,15 +158,285 @@ func (spdx *SPDX) SetImplementation(impl spdxImplementation) {
 type Options struct {
 	AnalyzeLayers      bool
 	NoGitignore        bool     // Do not read exclusions from gitignore file
+	GlobalGitignore    bool     // Also read the exclusions of the global excludes file of git (core.excludesFile) along with the gitignore files
+	GitignorePatterns  []string // Gitignore rules used instead of reading the .gitignore file (see ReadIgnorePatterns)
+	UseDockerignore    bool     // Also exclude the files matched by the .dockerignore file of scanned directories
 	ProcessGoModules   bool     // If true, spdx will check if dirs are go modules and analize the packages
-	OnlyDirectDeps     bool     // Only include direct dependencies from go.mod
+	ProcessNPMModules  bool     // If true, spdx will check if dirs have an npm or pnpm lockfile and add their dependencies
+	ProcessPython      bool     // If true, spdx will check if dirs have python requirements or lockfiles and add their dependencies
+	OnlyDirectDeps     bool     // Only include direct dependencies from go.mod, npm and python dependency files
 	ScanLicenses       bool     // Scan licenses from everypossible place unless false
+	LookupGoLicenses   bool     // Query the licenses of go dependencies online when not scanning them
+	LookupGoImports    bool     // Read the repositories of go modules on vanity import paths from their go-import meta tags, over the network
+	GoTargetOS         string   // GOOS to resolve go dependencies for, leaving out those of other systems
+	GoTargetArch       string   // GOARCH to resolve go dependencies for, leaving out those of other architectures
+	ClassifyGoDeps     bool     // Relate the test-only and tool go dependencies as such, listing them with two more go list runs
+	GoEnv              []string // Environment of the go commands resolving go dependencies (eg GOPROXY, GOPRIVATE), see GoModuleOptions.Env
 	AddTarFiles        bool     // Scan and add files inside of tarfiles
 	ScanImages         bool     // When true, scan container images for OS information
 	LicenseCacheDir    string   // Directory to cache SPDX license downloads
 	LicenseData        string   // Directory to store the SPDX licenses
 	LicenseListVersion string   // Version of the SPDX license list to use
 	IgnorePatterns     []string // Patterns to ignore when scanning file
+	ScanExtensions     []string // Only scan the files of directories with these extensions (eg .go), after the ignore patterns
+	ExcludeExtensions  []string // Do not scan the files of directories with these extensions (eg .png), after the ignore patterns
+	SkipEmptyFiles     bool     // Do not add zero-byte files to packages
+	AnnotateEmptyFiles bool     // Annotate the zero-byte files, and the packages of directories with their empty directories
+	MaxFileSize        int64    // Do not scan the files of directories larger than this many bytes (default unlimited)
+	ChecksumAlgorithms []string // Checksums computed for the files of directories, of SHA1, SHA256, SHA384 and SHA512 (default all but SHA384). SHA1 is always computed, SPDX requires it.
+	FollowSymlinks     bool     // Scan the files and directories symbolic links point to instead of skipping them
+	AnalyzeBinaries    bool     // Annotate executable files with their binary format and architecture
+	ComputeSWHID       bool     // Annotate the files of directories with their Software Heritage content identifier (swh:1:cnt:)
+	RecordFileMetadata bool     // Annotate the files with their mode and modification time, those of their entries when read from tarballs
+	LayerWorkers       int      // Number of image layers scanned in parallel (default 1)
+	ExtractWorkers     int      // Number of files written in parallel when extracting tarballs (default 1)
+	ExtractBufferSize  int      // Largest tarball entry buffered for the extraction workers (default 1 MiB)
+	MaxExtractedBytes  int64    // Largest total size of the files extracted from a tarball (default unlimited)
+	MaxEntryBytes      int64    // Largest file extracted from a tarball (default unlimited)
+	IncludePaths       []string // Only extract the image layer entries under these paths or globs, eg /usr/bin or /opt/*/lib (default all)
+	CollectWarnings    bool     // Record warnings to be read with Warnings(), not only log them
+	CollectStats       bool     // Record the time spent in each scan phase, to be read with Stats()
+	MaxCPUJobs         int      // Maximum hashing and license classification jobs running at once in all phases, the OS threads are not capped (default GOMAXPROCS)
+	MaxConcurrency     int      // Maximum goroutines downloading images, converting go packages and reading files at once (default unlimited)
+
+	// The copyright notices found in the headers of the files of scanned
+	// directories are aggregated into the package CopyrightText
+	AggregateCopyrights bool
+
+	// DetectGeneratedFiles annotates the files of directories generated by
+	// tools: those with a "Code generated ... DO NOT EDIT." header and
+	// those whose names match GeneratedPatterns. The patterns are globs
+	// matched against the file names, or the paths when they have a slash
+	// (default *.pb.go, *.pb.gw.go, zz_generated.*, *_gen.go, *_generated.go).
+	DetectGeneratedFiles bool
+	GeneratedPatterns    []string
+
+	// TempDir is the directory where the archives and image layers are
+	// extracted while they are scanned, it defaults to the system temporary
+	// directory. Scanning large images needs it on a volume with room for
+	// all their layers.
+	TempDir string
+
+	// Logger receives the messages logged while generating SBOM data,
+	// letting callers add fields to them or send them to their own
+	// output. Defaults to the logrus standard logger.
+	Logger logrus.FieldLogger
+
+	// DefaultSupplier and DefaultOriginator are set on the packages of
+	// scanned directories and image tarballs when they have no supplier or
+	// originator of their own. Their form is "Organization: name" or
+	// "Person: name (email)", the email being optional.
+	DefaultSupplier   string
+	DefaultOriginator string
+
+	// VerificationExcludedFiles lists the paths, relative to the directory,
+	// archive or image layer scanned, of the files left out of the package
+	// verification codes.
+	// The files are still described, eg the SBOM written to the directory.
+	VerificationExcludedFiles []string
+
+	// SelfFileName is the path, absolute or relative to the working
+	// directory, where the SBOM being generated is written. When it is in
+	// a scanned directory the file is left out of its package, so the SBOM
+	// does not describe itself with the checksum of a previous version.
+	SelfFileName string
+
+	// Reproducible names the packages of directories without a name after
+	// the files they contain instead of a random UUID, so scanning the same
+	// inputs gives the same packages. Documents are made reproducible with
+	// the Reproducible option of DocGenerateOptions.
+	Reproducible bool
+
+	// SkipLicenseScan does not classify the files of scanned directories
+	// to find their licenses, the slowest part of scanning large trees.
+	// Their license fields, and those of the directory package, are set
+	// to NOASSERTION.
+	SkipLicenseScan bool
+
+	// UnknownLicensePolicy selects if NONE or NOASSERTION is written in the
+	// license fields of the files and packages of scanned directories when
+	// the classifier finds no license (see UnknownLicensePolicy).
+	UnknownLicensePolicy UnknownLicensePolicy
+
+	// RequireLicenses fails building the packages of directories, files,
+	// archives and go binaries when any of the packages or files they
+	// describe has no license, or NOASSERTION, in its concluded license.
+	// The error is a *MissingLicenseError listing all of them. Container
+	// images are not checked, their packages carry no license of their own.
+	RequireLicenses bool
+
+	// IncludeDirectoryStructure adds a package for each top-level directory
+	// of scanned directories, and for each go module vendored under vendor/,
+	// containing their files instead of listing them all in the directory
+	// package
+	IncludeDirectoryStructure bool
+
+	// Files of scanned directories that cannot be read are left out of
+	// the package with a warning instead of failing the whole scan
+	ContinueOnFileError bool
+
+	// Paths differing only in case are matched as the same file when scanning
+	// directories in case-insensitive filesystems. This forces it on any filesystem.
+	CaseInsensitivePaths bool
+
+	ImageReferenceCacheTTL  time.Duration // When set, image references resolved from registries are cached this long
+	ImageReferenceCacheSize int           // Maximum number of cached image references (default 100)
+	DownloadConcurrency     int           // Number of image variants downloaded at once from registries (default 4)
+	ImageCacheDir           string        // Directory where pulled image archives are kept by digest to reuse them (optional)
+	RegistryRetries         int           // Times a registry request failing with a transient error is retried (default 0)
+	RegistryRetryBackoff    time.Duration // Wait before the first retry, doubled on each one (default 1s)
+	Platforms               []string      // Platforms (os/arch[/variant]) of the images pulled from an index (default all)
+	FromDaemon              bool          // Read the image references from the local container daemon instead of a registry
+
+	// Keychain authenticates the requests to registries. When not set, the
+	// credentials are read from the docker config of the environment.
+	Keychain authn.Keychain
+
+	// Transport sends the requests to registries (optional). When it is not
+	// set, the certificates of registries are verified against the system
+	// roots plus those in RegistryCAFile, unless InsecureRegistries is set.
+	Transport          http.RoundTripper
+	RegistryCAFile     string // PEM bundle of the certificate authorities trusted for registries
+	InsecureRegistries bool   // Do not verify the TLS certificates of registries
+
+	// RegistryRequestsPerSecond limits the image lookups and pulls sent to
+	// registries by all the scans of the process, to stay within the pull
+	// limits of registries like Docker Hub. Zero means no limit.
+	RegistryRequestsPerSecond float64
+
+	// RemoteOptions are passed to all the requests bom sends to registries,
+	// eg to set the user agent, the retries or the puller concurrency. They
+	// are applied after the ones bom builds from the other options, so as
+	// in go-containerregistry, a later option overrides an earlier one.
+	RemoteOptions []remote.Option
+
+	// PurlTypes overrides the purl types of images and OCI artifacts by
+	// their artifact or media type, eg to describe Helm charts with a
+	// type other than oci. Types not listed use the oci type.
+	PurlTypes map[string]string
+
+	// Overrides for the relationships generated between the packages of images
+	ImageVariantRelationship *RelationshipTemplate // Relationship from the index to each image (default CONTAINS)
+	ImageIndexRelationship   *RelationshipTemplate // Relationship from each image to its index (default VARIANT_OF)
+	ImageLayerRelationship   *RelationshipTemplate // Relationship from each image to its layers (default CONTAINS)
+	LayerPackageRelationship *RelationshipTemplate // Relationship from each layer or filesystem image to its OS packages (default CONTAINS)
+
+	// Tarballs passed as images but holding no image manifest (eg a single
+	// binary) are read as plain tarballs instead of failing
+	PlainTarballFallback bool
+
+	// LazyLayers takes the layers out of image archives one at a time, deleting
+	// each one once scanned to keep a single layer on disk. It does not apply
+	// when ScanImages or ScanImageFilesystem is set, as those read all the
+	// layers at once.
+	LazyLayers bool
+
+	// ScanImageFilesystem describes the files a container running each image
+	// of an archive sees: its layers are extracted on top of each other,
+	// the whiteouts of each layer deleting the files of those below, and
+	// the result is scanned as a directory into a package the image
+	// CONTAINS.
+	ScanImageFilesystem bool
+
+	// StreamLayers lists the files of the layers in image archives reading
+	// them straight from the tarball, hashing each file as it is read
+	// without extracting anything. Static binaries and licenses are not
+	// looked for. It is ignored when AnalyzeLayers, AddTarFiles, ScanImages,
+	// ScanImageFilesystem or DetectSecrets is set, as those need the layers
+	// on disk.
+	StreamLayers bool
+
+	// StreamRegistryImages reads the layers of images pulled from registries
+	// as they are downloaded, hashing their files and reading the OS
+	// packages when ScanImages is set, without writing anything to disk.
+	// Static and Go binaries are not looked for. Images are pulled to disk
+	// as usual when AnalyzeLayers, AddTarFiles or DetectSecrets is set.
+	StreamRegistryImages bool
+
+	// LayerAnalyzers enrich the packages of image layers after the built-in
+	// analyzers, eg reading package formats they do not know. They run on
+	// the extracted layers when AnalyzeLayers is set.
+	LayerAnalyzers []LayerAnalyzer
+
+	// DetectBinaryLinkage annotates the ELF executables of image layers
+	// with whether they are statically linked when AnalyzeLayers is set.
+	// Each executable is read from the layer to a temporary file.
+	DetectBinaryLinkage bool
+
+	// ScanGoBinaries describes the modules compiled into the Go binaries
+	// found in the layers of images, read from their build information,
+	// with the commit they were built from.
+	ScanGoBinaries bool
+
+	// ScanStaticImages describes the Go binaries of images built FROM
+	// scratch, with a few files and no package database, instead of
+	// probing them for OS packages. Each executable is read from the layer
+	// to a temporary file.
+	ScanStaticImages bool
+
+	// DedupeLayerFiles describes the files found with the same path and
+	// contents in several layers of an image only once, in the lowest layer
+	// holding them. The upper layers still contain them by reference.
+	DedupeLayerFiles bool
+
+	// SkipLayerVerification does not check the layers extracted from image
+	// archives against the digests listed in the image config. Verifying
+	// them catches truncated or corrupt archives, at the cost of reading
+	// each layer once more.
+	SkipLayerVerification bool
+
+	// DedupeGoDependencies describes each go module version found in the
+	// directories scanned with the same SPDX client only once, keyed by its
+	// purl. The packages of the directories scanned after the first one
+	// depending on it refer to the same package. All the directories must
+	// go in the same document.
+	DedupeGoDependencies bool
+
+	// TempStorageMode selects how the files of the image layers are kept in
+	// the temporary directories while they are scanned. TempStorageCompressed
+	// keeps them gzipped to save disk space, at the cost of CPU.
+	TempStorageMode TempStorageMode
+
+	// DetectSecrets reports the files and environment variables of images
+	// that look like embedded credentials as security warnings. The secret
+	// values are never recorded, they are redacted from the image history.
+	DetectSecrets bool
+
+	// ScanNestedArchives adds a package for each archive (zip files and
+	// tarballs) found in the scanned archives, down to five levels deep.
+	// NestedArchiveWorkers caps the archives extracted and scanned at the
+	// same time (default 2), their files are hashed under MaxCPUJobs.
+	ScanNestedArchives   bool
+	NestedArchiveWorkers int
+
+	// NormalizeVersions records the versions of the packages generated in
+	// a form meant for matching them (see NormalizeVersion). The original
+	// versions are kept in an annotation of each package.
+	NormalizeVersions bool
+
+	// PurlBuilder customizes the purl of every package generated (optional)
+	PurlBuilder PurlBuilder
+
+	// PackagePurl is the purl of the package of a scanned directory, eg
+	// pkg:golang/example.com/module@v1.0.0 to describe a checkout of a go
+	// module. It is validated before scanning. Packages without a version
+	// take the version of the purl.
+	PackagePurl string
+
+	// PriorDocument is the document of a previous scan. Files in scanned
+	// directories whose size and modification time did not change since
+	// reuse its checksums and licenses instead of being read again.
+	PriorDocument *Document
+
+	// DryRun resolves what the scans would read without reading it: the
+	// packages of directories list the files left after the ignore
+	// patterns, those of image references the images and layers to pull,
+	// in their Plan. Nothing is checksummed, classified or downloaded.
+	DryRun bool
+
+	// ProgressFn is called as the items of the slow phases complete (image
+	// downloads, layer, file and license scans) to report their progress
+	ProgressFn func(ProgressEvent)
 }
 
 func (spdx *SPDX) Options() *Options {
@@ -120,18 +457,31 @@ type ArchiveManifest struct {
 	ConfigFilename string   `json:"Config"`
 	RepoTags       []string `json:"RepoTags"`
 	LayerFiles     []string `json:"Layers"`
+	ManifestDigest string   `json:"-"` // Digest of the image manifest, only known in OCI layouts
+
+	// Annotations of the image manifest, only known in OCI layouts
+	Annotations map[string]string `json:"-"`
 }
 
 // ImageOptions set of options for processing tar files
 type TarballOptions struct {
-	ExtractDir string // Directory where the docker tar archive will be extracted
-	AddFiles   bool
+	ExtractDir      string // Directory where the docker tar archive will be extracted
+	AddFiles        bool
+	TempStorageMode TempStorageMode // How the files added are kept on disk while scanned (default plain)
+	layer           bool            // The tarball is an image layer, only its entries in the IncludePaths are extracted
 }
 
 // buildIDString takes a list of seed strings and builds a
 // valid SPDX ID string from them. If none is supplied, an
 // ID using an UUID will be returned
 func buildIDString(seeds ...string) string {
+	id, _ := buildIDSeeds(seeds...)
+	return id
+}
+
+// buildIDSeeds builds an ID as buildIDString does, also returning true when
+// no seed was usable and the ID ends with a random UUID instead
+func buildIDSeeds(seeds ...string) (id string, random bool) {
 	validSeeds := []string{}
 	numValidSeeds := 0
 	for _, s := range seeds {
@@ -159,52 +509,209 @@ func buildIDString(seeds ...string) string {
 	// If we did not get any seeds, use an UUID
 	if numValidSeeds == 0 {
 		validSeeds = append(validSeeds, uuid.New().String())
+		random = true
 	}
 
-	id := ""
 	for _, s := range validSeeds {
 		if id != "" {
 			id += "-"
 		}
 		id += s
 	}
-	return id
+	return id, random
 }
 
 // PackageFromDirectory indexes all files in a directory and builds a
 // SPDX package describing its contents
 func (spdx *SPDX) PackageFromDirectory(dirPath string) (pkg *Package, err error) {
-	pkg, err = spdx.impl.PackageFromDirectory(spdx.options, dirPath)
+	return spdx.packageFromDirectory(spdx.Options(), dirPath)
+}
+
+// packageFromDirectory generates the package of a directory using opts
+func (spdx *SPDX) packageFromDirectory(opts *Options, dirPath string) (pkg *Package, err error) {
+	packagePurl, err := parsePackagePurl(opts.PackagePurl)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err = spdx.impl.PackageFromDirectory(opts, dirPath)
 	if err != nil {
 		return nil, fmt.Errorf("generating SPDX package from directory: %w", err)
 	}
+	if opts.DryRun {
+		return pkg, nil
+	}
+
+	// Go modules are named after their module path, the directory name
+	// is often not meaningful (eg a checkout in a CI workspace)
+	if modulePath := goModulePath(dirPath); modulePath != "" {
+		pkg.AddAnnotation(newToolAnnotation(directoryNameAnnotation + pkg.Name))
+		pkg.Name = modulePath
+	}
 
 	// Scan the directory contents and if it is a go module, process the
 	// dependencies
-	if util.Exists(filepath.Join(dirPath, GoModFileName)) && spdx.Options().ProcessGoModules {
-		logrus.Info("Directory contains a go module. Scanning go packages")
-		deps, err := spdx.impl.GetGoDependencies(dirPath, spdx.Options())
+	if util.Exists(filepath.Join(dirPath, GoModFileName)) && opts.ProcessGoModules {
+		logger(opts).Info("Directory contains a go module. Scanning go packages")
+		deps, err := spdx.impl.GetGoDependencies(dirPath, opts)
 		if err != nil {
 			return nil, fmt.Errorf("scanning go packages: %w", err)
 		}
-		logrus.Infof("Go module built list of %d dependencies", len(deps))
+		logger(opts).Infof("Go module built list of %d dependencies", len(deps))
+		shared := 0
 		for _, dep := range deps {
-			if err := pkg.AddDependency(dep); err != nil {
+			// Modules already described only get a relationship
+			if opts.DedupeGoDependencies {
+				if first, seen := spdx.goDependencies.add(dep); seen {
+					if err := pkg.addScopedDependency(first, dep.dependencyType, false); err != nil {
+						return nil, fmt.Errorf("adding go dependency: %w", err)
+					}
+					shared++
+					continue
+				}
+			}
+			// Test-only dependencies and build tools are related as such
+			if err := pkg.addScopedDependency(dep, dep.dependencyType, true); err != nil {
 				return nil, fmt.Errorf("adding go dependency: %w", err)
 			}
 		}
+		if shared > 0 {
+			logger(opts).Infof("%d go dependencies of %s were already described, added by reference", shared, pkg.Name)
+		}
+	}
+
+	// Same for the dependencies of node projects listed in their lockfile
+	if opts.ProcessNPMModules && npmLockFile(dirPath) != "" {
+		logger(opts).Info("Directory contains an npm lockfile. Scanning npm packages")
+		deps, err := spdx.impl.GetNPMDependencies(dirPath, opts)
+		if err != nil {
+			return nil, fmt.Errorf("scanning npm packages: %w", err)
+		}
+		logger(opts).Infof("npm lockfile lists %d dependencies", len(deps))
+		for _, dep := range deps {
+			if err := pkg.AddDependency(dep); err != nil {
+				return nil, fmt.Errorf("adding npm dependency: %w", err)
+			}
+		}
+	}
+
+	// And for python projects, from their lockfile or requirements
+	if opts.ProcessPython && pythonDependencyFile(dirPath) != "" {
+		logger(opts).Info("Directory contains python dependencies. Scanning python packages")
+		deps, err := spdx.impl.GetPythonDependencies(dirPath, opts)
+		if err != nil {
+			return nil, fmt.Errorf("scanning python packages: %w", err)
+		}
+		logger(opts).Infof("Python project lists %d dependencies", len(deps))
+		for _, dep := range deps {
+			if err := pkg.AddDependency(dep); err != nil {
+				return nil, fmt.Errorf("adding python dependency: %w", err)
+			}
+		}
 	}
 
+	setPackagePurl(pkg, packagePurl)
+	normalizeVersions(opts, pkg)
+	applyPurlBuilder(opts, pkg)
+	if err := applyDefaultEntities(opts, pkg); err != nil {
+		return nil, err
+	}
+	if err := checkRequiredLicenses(opts, pkg); err != nil {
+		return nil, err
+	}
 	return pkg, nil
 }
 
+// PackageFromFile returns a SPDX package describing a single file, eg a
+// release binary, named after the file
+func (spdx *SPDX) PackageFromFile(filePath string) (*Package, error) {
+	opts := spdx.Options()
+	packagePurl, err := parsePackagePurl(opts.PackagePurl)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := spdx.impl.PackageFromFile(opts, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("generating SPDX package from file: %w", err)
+	}
+	setPackagePurl(pkg, packagePurl)
+	normalizeVersions(opts, pkg)
+	applyPurlBuilder(opts, pkg)
+	if err := applyDefaultEntities(opts, pkg); err != nil {
+		return nil, err
+	}
+	if err := checkRequiredLicenses(opts, pkg); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// GoDependencies returns the dependencies of the go module in dirPath as
+// SPDX packages, along with the errors of those that could not be converted
+// (as *DependencyError) and were left out. Callers can tell from them how
+// many dependencies are missing and why.
+func (spdx *SPDX) GoDependencies(dirPath string) (deps []*Package, dropped []error, err error) {
+	return spdx.impl.GetGoDependenciesWithErrors(dirPath, spdx.Options())
+}
+
+// PackageFromDirectories scans each directory into its own package and
+// returns a document describing all of them, eg to describe an artifact
+// built from several source trees. Each directory is scanned as with
+// PackageFromDirectory: its own .gitignore and license apply to its files.
+func (spdx *SPDX) PackageFromDirectories(dirPaths []string) (*Document, error) {
+	if len(dirPaths) == 0 {
+		return nil, errors.New("no directories to scan")
+	}
+	doc := NewDocument()
+	names := []string{}
+	for _, dirPath := range dirPaths {
+		pkg, err := spdx.packageFromDirectory(spdx.Options(), dirPath)
+		if err != nil {
+			return nil, fmt.Errorf("scanning directory %s: %w", dirPath, err)
+		}
+		// Directories may share their base name, the package IDs of
+		// the document have to be unique
+		doc.ensureUniqueElementID(pkg)
+		if err := doc.AddPackage(pkg); err != nil {
+			return nil, fmt.Errorf("adding package of %s to document: %w", dirPath, err)
+		}
+		names = append(names, pkg.Name)
+	}
+	doc.Name = strings.Join(names, ", ")
+	return doc, nil
+}
+
 // PackageFromImageTarball returns a SPDX package from a tarball
 func (spdx *SPDX) PackageFromImageTarball(tarPath string) (imagePackage *Package, err error) {
-	return spdx.impl.PackageFromImageTarball(spdx.Options(), tarPath)
+	imagePackage, err = spdx.impl.PackageFromImageTarball(spdx.Options(), tarPath)
+	if err != nil {
+		return nil, err
+	}
+	normalizeVersions(spdx.Options(), imagePackage)
+	computeVerificationCodes(spdx.Options(), imagePackage)
+	applyPurlBuilder(spdx.Options(), imagePackage)
+	if err := applyDefaultEntities(spdx.Options(), imagePackage); err != nil {
+		return nil, err
+	}
+	return imagePackage, nil
+}
+
+// PackageFromArchive returns a SPDX package from a tarball, a zip-based
+// archive (zip, jar, wheel, nupkg...) or a filesystem image (squashfs, ext4)
+func (spdx *SPDX) PackageFromArchive(archivePath string) (pkg *Package, err error) {
+	pkg, err = spdx.packageFromArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	normalizeVersions(spdx.Options(), pkg)
+	computeVerificationCodes(spdx.Options(), pkg)
+	applyPurlBuilder(spdx.Options(), pkg)
+	if err := checkRequiredLicenses(spdx.Options(), pkg); err != nil {
+		return nil, err
+	}
+	return pkg, nil
 }
 
-// PackageFromArchive returns a SPDX package from a tarball
-func (spdx *SPDX) PackageFromArchive(archivePath string) (imagePackage *Package, err error) {
+func (spdx *SPDX) packageFromArchive(archivePath string) (*Package, error) {
 	if strings.HasSuffix(archivePath, "tar") || strings.HasSuffix(archivePath, "tar.gz") {
 		return spdx.impl.PackageFromTarball(
 			spdx.Options(), &TarballOptions{
@@ -212,7 +719,62 @@ func (spdx *SPDX) PackageFromArchive(archivePath string) (imagePackage *Package,
 			}, archivePath,
 		)
 	}
-	return nil, fmt.Errorf("unable to create spdx package from archive, only tar archives are supported: %w", err)
+	for _, ext := range zipArchiveExtensions {
+		if strings.HasSuffix(strings.ToLower(archivePath), ext) {
+			return spdx.impl.PackageFromZip(spdx.Options(), archivePath)
+		}
+	}
+	// Filesystem images go by many extensions (.img, .sqfs, .ext4...),
+	// they are recognized by the magic number in their superblock
+	if _, err := fsimage.DetectFileType(archivePath); err == nil {
+		return spdx.impl.PackageFromFilesystemImage(spdx.Options(), archivePath)
+	} else if !errors.Is(err, fsimage.ErrUnknownType) {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	return nil, errors.New(
+		"unable to create spdx package from archive, only tar and zip archives and filesystem images are supported",
+	)
+}
+
+// PackageFromGoBinary returns a SPDX package describing a Go binary and
+// the modules compiled into it, read from its embedded build information
+func (spdx *SPDX) PackageFromGoBinary(binaryPath string) (*Package, error) {
+	pkg, err := spdx.impl.PackageFromGoBinary(spdx.Options(), binaryPath)
+	if err != nil {
+		return nil, err
+	}
+	normalizeVersions(spdx.Options(), pkg)
+	applyPurlBuilder(spdx.Options(), pkg)
+	if err := checkRequiredLicenses(spdx.Options(), pkg); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// PackageFromContentStore returns a SPDX package describing the image whose
+// manifest digest is specified, reading its blobs from a content store
+func (spdx *SPDX) PackageFromContentStore(src BlobSource, manifestDigest string) (*Package, error) {
+	pkg, err := spdx.impl.PackageFromContentStore(spdx.Options(), src, manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	normalizeVersions(spdx.Options(), pkg)
+	computeVerificationCodes(spdx.Options(), pkg)
+	applyPurlBuilder(spdx.Options(), pkg)
+	return pkg, nil
+}
+
+// PackageFromImageFiles returns a SPDX package describing the image made
+// of the specified config file and layer blobs
+func (spdx *SPDX) PackageFromImageFiles(configPath string, layerPaths []string) (*Package, error) {
+	pkg, err := spdx.impl.PackageFromImageFiles(spdx.Options(), configPath, layerPaths)
+	if err != nil {
+		return nil, err
+	}
+	normalizeVersions(spdx.Options(), pkg)
+	computeVerificationCodes(spdx.Options(), pkg)
+	applyPurlBuilder(spdx.Options(), pkg)
+	return pkg, nil
 }
 
 // FileFromPath creates a File object from a path
@@ -230,19 +792,56 @@ func (spdx *SPDX) FileFromPath(filePath string) (*File, error) {
 // AnalyzeLayer uses the collection of image analyzers to see if
 //
 //	it matches a known image from which a spdx package can be
-//	enriched with more information
+//	enriched with more information. The ELF executables of the
+//	layer are annotated with whether they are statically linked
+//	when the options set DetectBinaryLinkage.
+//	The LayerAnalyzers of the options run after the built-in ones.
 func (spdx *SPDX) AnalyzeImageLayer(layerPath string, pkg *Package) error {
-	return spdx.impl.AnalyzeImageLayer(layerPath, pkg)
+	if err := spdx.impl.AnalyzeImageLayer(layerPath, pkg, spdx.Options()); err != nil {
+		return err
+	}
+	if spdx.Options().DetectBinaryLinkage {
+		if err := annotateLayerLinkage(spdx.Options(), layerPath, pkg); err != nil {
+			return fmt.Errorf("reading the linkage of layer binaries: %w", err)
+		}
+	}
+	return runLayerAnalyzers(spdx.Options().LayerAnalyzers, layerPath, pkg)
 }
 
 // ExtractTarballTmp extracts a tarball to a temp file
 func (spdx *SPDX) ExtractTarballTmp(tarPath string) (tmpDir string, err error) {
-	return spdx.impl.ExtractTarballTmp(tarPath)
+	return spdx.impl.ExtractTarballTmp(spdx.Options(), tarPath)
+}
+
+// ExtractLayersTmp extracts image layers on top of each other to a temp
+// directory, applying the whiteouts of the upper layers
+func (spdx *SPDX) ExtractLayersTmp(layerPaths []string) (tmpDir string, err error) {
+	return spdx.impl.ExtractLayersTmp(spdx.Options(), layerPaths)
 }
 
 // PullImagesToArchive downloads all the images found from a reference to disk
 func (spdx *SPDX) PullImagesToArchive(reference, path string) (*ImageReferenceInfo, error) {
-	return spdx.impl.PullImagesToArchive(reference, path)
+	return spdx.PullImagesToArchiveContext(context.Background(), reference, path)
+}
+
+// PullImagesToArchiveContext downloads all the images found from a reference
+// to disk, aborting the downloads when the context is done
+func (spdx *SPDX) PullImagesToArchiveContext(
+	ctx context.Context, reference, path string,
+) (*ImageReferenceInfo, error) {
+	return spdx.impl.PullImagesToArchive(ctx, spdx.Options(), reference, path)
+}
+
+// ResolveImageReferences returns the images a reference points to without
+// pulling them. Image indexes list the digest, architecture and OS of each
+// of their images, filtered by the platforms in the options. The images
+// have no archive as nothing is downloaded.
+func ResolveImageReferences(reference string, opts *Options) (*ImageReferenceInfo, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	impl := spdxDefaultImplementation{}
+	return impl.resolveImageReferences(context.Background(), opts, reference)
 }
 
 // ImageRefToPackage gets an image reference (tag or digest) and returns
@@ -252,8 +851,73 @@ func (spdx *SPDX) PullImagesToArchive(reference, path string) (*ImageReferenceIn
 //   - When the reference is an image index, the returned package is a
 //     package referencing each of the images, each in its own packages.
 //     All subpackages are returned with a relationship of VARIANT_OF
+//
+// References prefixed with daemon:// or docker-daemon: (or any reference
+// when the options set FromDaemon) are read from the local container daemon.
 func (spdx *SPDX) ImageRefToPackage(reference string) (pkg *Package, err error) {
-	return spdx.impl.ImageRefToPackage(reference, spdx.Options())
+	return spdx.ImageRefToPackageContext(context.Background(), reference)
+}
+
+// ImageRefToPackageContext works as ImageRefToPackage. The context is
+// used for the requests to the registry, cancelling it aborts the image
+// downloads in flight.
+func (spdx *SPDX) ImageRefToPackageContext(ctx context.Context, reference string) (pkg *Package, err error) {
+	pkg, err = spdx.impl.ImageRefToPackage(ctx, reference, spdx.Options())
+	if err != nil {
+		return nil, err
+	}
+	normalizeVersions(spdx.Options(), pkg)
+	computeVerificationCodes(spdx.Options(), pkg)
+	applyPurlBuilder(spdx.Options(), pkg)
+	return pkg, nil
+}
+
+// ImageOSPackages returns the operating system packages installed in the
+// images pointed to by reference. This is a lightweight alternative to
+// ImageRefToPackage when only the OS package inventory is needed.
+func (spdx *SPDX) ImageOSPackages(reference string) ([]osinfo.PackageDBEntry, error) {
+	return spdx.ImageOSPackagesContext(context.Background(), reference)
+}
+
+// ImageOSPackagesContext works as ImageOSPackages, aborting the image
+// downloads when the context is done
+func (spdx *SPDX) ImageOSPackagesContext(ctx context.Context, reference string) ([]osinfo.PackageDBEntry, error) {
+	return spdx.impl.ImageOSPackages(ctx, reference, spdx.Options())
+}
+
+// Warnings returns the problems found while generating packages which did
+// not stop the process. Warnings are only recorded when the CollectWarnings
+// option is set.
+func (spdx *SPDX) Warnings() []Warning {
+	return spdx.impl.Warnings()
+}
+
+// Stats returns the time spent in each phase of the scans run so far.
+// Durations are only recorded when the CollectStats option is set.
+func (spdx *SPDX) Stats() Stats {
+	return spdx.impl.Stats()
+}
+
+// AnnotateLicenseVersions records in the document the versions of the
+// license classifier and of the SPDX license list used to identify
+// licenses. They explain why a later scan with other versions may find
+// different licenses.
+func (spdx *SPDX) AnnotateLicenseVersions(doc *Document) error {
+	reader, err := spdx.impl.LicenseReader(spdx.Options())
+	if err != nil {
+		return fmt.Errorf("creating license reader: %w", err)
+	}
+	classifierVersion := license.ClassifierVersion()
+	if classifierVersion == "" {
+		classifierVersion = NOASSERTION
+	}
+	listVersion := reader.ListVersion()
+	if listVersion == "" {
+		listVersion = NOASSERTION
+	}
+	doc.AddAnnotation(newToolAnnotation(licenseClassifierAnnotation + classifierVersion))
+	doc.AddAnnotation(newToolAnnotation(licenseListAnnotation + listVersion))
+	return nil
 }
 
 func Banner() string {
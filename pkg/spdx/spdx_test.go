@@ -17,11 +17,17 @@ limitations under the License.
 package spdx_test
 
 import (
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	purl "github.com/package-url/packageurl-go"
 	"github.com/stretchr/testify/require"
 
+	"sigs.k8s.io/bom/pkg/osinfo"
 	"sigs.k8s.io/bom/pkg/spdx"
 	"sigs.k8s.io/bom/pkg/spdx/spdxfakes"
 )
@@ -62,6 +68,123 @@ func TestPackageFromImageTarball(t *testing.T) {
 	}
 }
 
+func TestPurlBuilder(t *testing.T) {
+	image := spdx.NewPackage()
+	image.Name = "image"
+	image.ExternalRefs = []spdx.ExternalRef{
+		{Category: spdx.CatPackageManager, Type: "purl", Locator: "pkg:oci/image@sha256%3Aabcd"},
+	}
+	ospkg := spdx.NewPackage()
+	ospkg.Name = "base-files"
+	ospkg.ExternalRefs = []spdx.ExternalRef{
+		{Category: spdx.CatPackageManager, Type: "purl", Locator: "pkg:deb/debian/base-files@12.4"},
+		{Category: "SECURITY", Type: "cpe23Type", Locator: "cpe:2.3:a:base-files:base-files:12.4:*:*:*:*:*:*:*"},
+	}
+	nopurl := spdx.NewPackage()
+	nopurl.Name = "no-purl"
+	require.NoError(t, image.AddPackage(ospkg))
+	require.NoError(t, image.AddPackage(nopurl))
+
+	sut := spdx.NewSPDX()
+	mock := &spdxfakes.FakeSpdxImplementation{}
+	mock.PackageFromImageTarballReturns(image, nil)
+	sut.SetImplementation(mock)
+	sut.Options().PurlBuilder = func(pkg *spdx.Package, p *purl.PackageURL) *purl.PackageURL {
+		if p == nil {
+			return nil
+		}
+		p.Namespace = "internal/" + p.Namespace
+		return p
+	}
+	defer func() { sut.Options().PurlBuilder = nil }()
+
+	pkg, err := sut.PackageFromImageTarball("mock.tar")
+	require.NoError(t, err)
+	require.Equal(t, "pkg:oci/internal/image@sha256:abcd", pkg.Purl().ToString())
+	require.Equal(t, "pkg:deb/internal/debian/base-files@12.4", ospkg.Purl().ToString())
+	require.Len(t, ospkg.ExternalRefs, 2)
+	require.Nil(t, nopurl.Purl())
+	require.Empty(t, nopurl.ExternalRefs)
+}
+
+func TestPackagePurl(t *testing.T) {
+	dirPackage := spdx.NewPackage()
+	dirPackage.Name = "module"
+	dirPackage.ExternalRefs = []spdx.ExternalRef{
+		{Category: spdx.CatPackageManager, Type: "purl", Locator: "pkg:generic/module"},
+	}
+
+	sut := spdx.NewSPDX()
+	mock := &spdxfakes.FakeSpdxImplementation{}
+	mock.PackageFromDirectoryReturns(dirPackage, nil)
+	sut.SetImplementation(mock)
+	defer func() { sut.Options().PackagePurl = "" }()
+
+	// The purl replaces the one of the package, which takes its version
+	sut.Options().PackagePurl = "pkg:golang/example.com/module@v1.2.3"
+	pkg, err := sut.PackageFromDirectory(t.TempDir())
+	require.NoError(t, err)
+	require.Len(t, pkg.ExternalRefs, 1)
+	require.Equal(t, "pkg:golang/example.com/module@v1.2.3", pkg.Purl().ToString())
+	require.Equal(t, "v1.2.3", pkg.Version)
+
+	// Invalid purls fail before scanning the directory
+	for _, invalid := range []string{"example.com/module", "pkg:golang", "pkg:/module@v1"} {
+		sut.Options().PackagePurl = invalid
+		_, err = sut.PackageFromDirectory(t.TempDir())
+		require.Error(t, err, invalid)
+	}
+	require.Equal(t, 1, mock.PackageFromDirectoryCallCount())
+}
+
+func TestGoDependencies(t *testing.T) {
+	dep := spdx.NewPackage()
+	dep.Name = "example.com/module"
+	dropped := []error{&spdx.DependencyError{Dependency: "nohostname/pkg", Err: err}}
+
+	sut := spdx.NewSPDX()
+	mock := &spdxfakes.FakeSpdxImplementation{}
+	mock.GetGoDependenciesWithErrorsReturns([]*spdx.Package{dep}, dropped, nil)
+	sut.SetImplementation(mock)
+
+	deps, errs, scanErr := sut.GoDependencies("module")
+	require.NoError(t, scanErr)
+	require.Equal(t, []*spdx.Package{dep}, deps)
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], err)
+	path, _ := mock.GetGoDependenciesWithErrorsArgsForCall(0)
+	require.Equal(t, "module", path)
+
+	mock.GetGoDependenciesWithErrorsReturns(nil, nil, err)
+	_, _, scanErr = sut.GoDependencies("module")
+	require.Error(t, scanErr)
+}
+
+func TestAnalyzeImageLayer(t *testing.T) {
+	sut := spdx.NewSPDX()
+	mock := &spdxfakes.FakeSpdxImplementation{}
+	sut.SetImplementation(mock)
+	analyzed := []string{}
+	sut.Options().LayerAnalyzers = []spdx.LayerAnalyzer{{
+		Name: "custom",
+		Analyze: func(layerPath string, _ *spdx.Package) error {
+			analyzed = append(analyzed, layerPath)
+			return nil
+		},
+	}}
+	defer func() { sut.Options().LayerAnalyzers = nil }()
+
+	// The custom analyzers run after the built-in ones
+	require.NoError(t, sut.AnalyzeImageLayer("layer.tar", spdx.NewPackage()))
+	require.Equal(t, 1, mock.AnalyzeImageLayerCallCount())
+	require.Equal(t, []string{"layer.tar"}, analyzed)
+
+	// and not when those fail
+	mock.AnalyzeImageLayerReturns(err)
+	require.Error(t, sut.AnalyzeImageLayer("layer.tar", spdx.NewPackage()))
+	require.Len(t, analyzed, 1)
+}
+
 func TestExtractTarballTmp(t *testing.T) {
 	for _, tc := range []struct {
 		prepare     func(*spdxfakes.FakeSpdxImplementation)
@@ -127,3 +250,93 @@ func TestPullImagesToArchive(t *testing.T) {
 		}
 	}
 }
+
+func TestImageOSPackages(t *testing.T) {
+	sut := spdx.NewSPDX()
+	mock := &spdxfakes.FakeSpdxImplementation{}
+	mock.ImageOSPackagesReturns([]osinfo.PackageDBEntry{{Package: "bash", Version: "5.1-2"}}, nil)
+	sut.SetImplementation(mock)
+
+	packages, callErr := sut.ImageOSPackages("registry.k8s.io/pause:3.9")
+	require.NoError(t, callErr)
+	require.Len(t, packages, 1)
+	require.Equal(t, "bash", packages[0].Package)
+	_, ref, _ := mock.ImageOSPackagesArgsForCall(0)
+	require.Equal(t, "registry.k8s.io/pause:3.9", ref)
+
+	// The context reaches the implementation
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "scan")
+	_, callErr = sut.ImageOSPackagesContext(ctx, "registry.k8s.io/pause:3.9")
+	require.NoError(t, callErr)
+	gotCtx, _, _ := mock.ImageOSPackagesArgsForCall(1)
+	require.Equal(t, "scan", gotCtx.Value(ctxKey{}))
+
+	mock.ImageOSPackagesReturns(nil, err)
+	_, callErr = sut.ImageOSPackages("registry.k8s.io/pause:3.9")
+	require.Error(t, callErr)
+}
+
+func TestPackageFromDirectoryDedupeGoDependencies(t *testing.T) {
+	goDependency := func(name, version string) *spdx.Package {
+		p := spdx.NewPackage()
+		p.Name = name
+		p.Version = version
+		p.BuildID(name, version)
+		p.ExternalRefs = []spdx.ExternalRef{{
+			Category: spdx.CatPackageManager, Type: "purl", Locator: "pkg:golang/" + name + "@" + version,
+		}}
+		return p
+	}
+
+	for _, dedupe := range []bool{true, false} {
+		mock := &spdxfakes.FakeSpdxImplementation{}
+		mock.PackageFromDirectoryStub = func(_ *spdx.Options, dirPath string) (*spdx.Package, error) {
+			p := spdx.NewPackage()
+			p.Name = filepath.Base(dirPath)
+			p.BuildID(p.Name)
+			return p, nil
+		}
+		mock.GetGoDependenciesCalls(func(dirPath string, _ *spdx.Options) ([]*spdx.Package, error) {
+			deps := []*spdx.Package{goDependency("example.com/shared", "v1.0.0")}
+			if filepath.Base(dirPath) == "b" {
+				deps = append(deps, goDependency("example.com/other", "v2.0.0"))
+			}
+			return deps, nil
+		})
+		sut := spdx.NewSPDX()
+		sut.SetImplementation(mock)
+		sut.Options().ProcessGoModules = true
+		sut.Options().DedupeGoDependencies = dedupe
+		defer func() {
+			sut.Options().ProcessGoModules = false
+			sut.Options().DedupeGoDependencies = false
+		}()
+
+		doc := spdx.NewDocument()
+		for _, name := range []string{"a", "b"} {
+			dir := filepath.Join(t.TempDir(), name)
+			require.NoError(t, os.MkdirAll(dir, os.FileMode(0o755)))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("go 1.20\n"), os.FileMode(0o644)))
+			pkg, err := sut.PackageFromDirectory(dir)
+			require.NoError(t, err)
+			require.NoError(t, doc.AddPackage(pkg))
+
+			// Both packages depend on the shared module
+			found := false
+			for _, rel := range pkg.Relationships {
+				found = found || (rel.Type == spdx.DEPENDS_ON && rel.Peer.SPDXID() == "SPDXRef-Package-example.com-shared-v1.0.0")
+			}
+			require.True(t, found, name)
+		}
+
+		out, err := doc.Render()
+		require.NoError(t, err)
+		expected := 2
+		if dedupe {
+			expected = 1
+		}
+		require.Equal(t, expected, strings.Count(out, "PackageName: example.com/shared\n"), dedupe)
+		require.Equal(t, 1, strings.Count(out, "PackageName: example.com/other\n"), dedupe)
+	}
+}
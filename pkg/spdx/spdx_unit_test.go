@@ -18,17 +18,48 @@ package spdx
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/fake"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/require"
 
+	"sigs.k8s.io/bom/pkg/license"
+	"sigs.k8s.io/bom/pkg/license/licensefakes"
+	"sigs.k8s.io/bom/pkg/osinfo"
+	"sigs.k8s.io/release-utils/hash"
 	"sigs.k8s.io/release-utils/util"
 )
 
@@ -76,6 +107,118 @@ func TestUnitExtractTarballTmp(t *testing.T) {
 	}
 }
 
+func TestExtractTarballTmpZstd(t *testing.T) {
+	var compressed bytes.Buffer
+	zw, err := zstd.NewWriter(&compressed)
+	require.NoError(t, err)
+	_, err = zw.Write(testLayerData(t, 0, 20))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.Equal(t, []byte{0x28, 0xb5, 0x2f, 0xfd}, compressed.Bytes()[:4])
+
+	tarPath := filepath.Join(t.TempDir(), "layer.tar")
+	require.NoError(t, os.WriteFile(tarPath, compressed.Bytes(), os.FileMode(0o644)))
+
+	impl := spdxDefaultImplementation{}
+	dir, err := impl.ExtractTarballTmp(&Options{}, tarPath)
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for i := 0; i < 20; i++ {
+		require.FileExists(t, filepath.Join(dir, fmt.Sprintf("layer0/file%d.txt", i)))
+	}
+}
+
+func TestExtractTarballTmpGzipMembers(t *testing.T) {
+	// The layer is split in two gzip members, followed by padding
+	data := testLayerData(t, 0, 20)
+	var compressed bytes.Buffer
+	for _, part := range [][]byte{data[:len(data)/3], data[len(data)/3:]} {
+		zw := gzip.NewWriter(&compressed)
+		_, err := zw.Write(part)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+	}
+	membersLen := compressed.Len()
+
+	for name, trailer := range map[string][]byte{
+		"two members":      nil,
+		"trailing zeros":   make([]byte, 1024),
+		"trailing garbage": []byte("not a gzip member"),
+	} {
+		tarPath := filepath.Join(t.TempDir(), "layer.tar.gz")
+		blob := append(append([]byte{}, compressed.Bytes()[:membersLen]...), trailer...)
+		require.NoError(t, os.WriteFile(tarPath, blob, os.FileMode(0o644)))
+
+		impl := spdxDefaultImplementation{}
+		dir, err := impl.ExtractTarballTmp(&Options{}, tarPath)
+		require.NoError(t, err, name)
+		for i := 0; i < 20; i++ {
+			require.FileExists(t, filepath.Join(dir, fmt.Sprintf("layer0/file%d.txt", i)), name)
+		}
+		require.NoError(t, os.RemoveAll(dir))
+	}
+
+	// The decompressed stream is the data of all the members
+	r, err := decompressedStream(bytes.NewReader(append(compressed.Bytes(), 0, 0, 0)))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, data, decompressed)
+
+	// Corrupt members still fail
+	corrupt := append([]byte{}, compressed.Bytes()...)
+	corrupt[membersLen-5] ^= 0xff
+	r, err = decompressedStream(bytes.NewReader(corrupt))
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.Error(t, err)
+}
+
+func TestPackageFromImageTarballFilesystem(t *testing.T) {
+	writeLayer := func(files ...string) []byte {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, f := range files {
+			data := []byte("contents of " + f)
+			require.NoError(t, tw.WriteHeader(&tar.Header{
+				Name: f, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+			}))
+			_, err := tw.Write(data)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		return buf.Bytes()
+	}
+	tarPath := writeTestDockerArchive(t, []string{"layer1.tar", "layer2.tar"}, [][]byte{
+		writeLayer("etc/keep", "etc/remove"),
+		writeLayer("etc/.wh.remove", "usr/bin/tool"),
+	})
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromImageTarball(&Options{SkipLicenseScan: true}, tarPath)
+	require.NoError(t, err)
+	for _, rel := range pkg.Relationships {
+		require.NotEqual(t, "filesystem", rel.Peer.(*Package).Name)
+	}
+
+	pkg, err = impl.PackageFromImageTarball(&Options{SkipLicenseScan: true, ScanImageFilesystem: true}, tarPath)
+	require.NoError(t, err)
+	var fsPackage *Package
+	for _, rel := range pkg.Relationships {
+		if p, ok := rel.Peer.(*Package); ok && p.Name == "filesystem" {
+			require.Equal(t, CONTAINS, rel.Type)
+			fsPackage = p
+		}
+	}
+	require.NotNil(t, fsPackage)
+	files := []string{}
+	for _, f := range fsPackage.Files() {
+		files = append(files, f.FileName)
+	}
+	sort.Strings(files)
+	require.Equal(t, []string{"etc/keep", "usr/bin/tool"}, files)
+}
+
 func TestReadArchiveManifest(t *testing.T) {
 	f, err := os.CreateTemp(os.TempDir(), "sample-manifest-*.json")
 	require.Nil(t, err)
@@ -238,6 +381,15 @@ func TestRelationshipRender(t *testing.T) {
 			// Relationships without a type should err
 			Relationship{FullRender: false, PeerReference: dummyref}, true, "",
 		},
+		{
+			// Inverse relationships render from the peer to the host
+			Relationship{Type: TEST_DEPENDENCY_OF, Peer: peer, Inverse: true},
+			false, fmt.Sprintf("Relationship: %s TEST_DEPENDENCY_OF %s\n", peer.SPDXID(), host.SPDXID()),
+		},
+		{
+			// Inverse relationships need the peer in the document
+			Relationship{Type: TEST_DEPENDENCY_OF, PeerReference: dummyref, Inverse: true}, true, "",
+		},
 	}
 
 	for _, tc := range cases {
@@ -273,7 +425,7 @@ var sampleManifest = `[{"Config":"386bcf5c63de46c7066c42d4ae1c38af0689836e88fed3
 `
 
 func TestGetImageReferences(t *testing.T) {
-	references, err := getImageReferences("registry.k8s.io/kube-apiserver:v1.23.0-alpha.3")
+	references, err := getImageReferences(context.Background(), nil, "registry.k8s.io/kube-apiserver:v1.23.0-alpha.3")
 	images := map[string]struct {
 		arch string
 		os   string
@@ -300,7 +452,7 @@ func TestGetImageReferences(t *testing.T) {
 
 	// Test a sha reference. This is the linux/ppc64le image
 	singleRef := "registry.k8s.io/kube-apiserver@sha256:1a61b61491042e2b1e659c4d57d426d01d9467fb381404bff029be4d00ead519"
-	references, err = getImageReferences(singleRef)
+	references, err = getImageReferences(context.Background(), nil, singleRef)
 	require.NoError(t, err)
 	require.Len(t, references.Images, 0)
 	require.Equal(t, singleRef, references.Digest)
@@ -309,7 +461,7 @@ func TestGetImageReferences(t *testing.T) {
 	require.Equal(t, "linux", references.OS)
 
 	// Tag with a single image. Image 1.0 is a single image
-	references, err = getImageReferences("registry.k8s.io/pause:1.0")
+	references, err = getImageReferences(context.Background(), nil, "registry.k8s.io/pause:1.0")
 	require.NoError(t, err)
 	require.Len(t, references.Images, 0)
 	require.Equal(t, "registry.k8s.io/pause@sha256:a78c2d6208eff9b672de43f880093100050983047b7b0afe0217d3656e1b0d5f", references.Digest)
@@ -318,11 +470,19 @@ func TestGetImageReferences(t *testing.T) {
 	require.Equal(t, "linux", references.OS)
 }
 
+func TestDownloadConcurrency(t *testing.T) {
+	require.Equal(t, 4, downloadConcurrency(nil))
+	require.Equal(t, 4, downloadConcurrency(&Options{}))
+	require.Equal(t, 4, downloadConcurrency(&Options{DownloadConcurrency: -1}))
+	require.Equal(t, 1, downloadConcurrency(&Options{DownloadConcurrency: 1}))
+	require.Equal(t, 16, downloadConcurrency(&Options{DownloadConcurrency: 16}))
+}
+
 func TestPullImagesToArchive(t *testing.T) {
 	impl := spdxDefaultImplementation{}
 
 	// First. If the tag does not represent an image, expect an error
-	_, err := impl.PullImagesToArchive("registry.k8s.io/pause:0.0", "/tmp")
+	_, err := impl.PullImagesToArchive(context.Background(), &Options{}, "registry.k8s.io/pause:0.0", "/tmp")
 	require.Error(t, err)
 
 	// Create a temp workdir
@@ -331,7 +491,7 @@ func TestPullImagesToArchive(t *testing.T) {
 	defer os.RemoveAll(dir)
 
 	// The pause 1.0 image is a single image
-	images, err := impl.PullImagesToArchive("registry.k8s.io/pause:1.0", dir)
+	images, err := impl.PullImagesToArchive(context.Background(), &Options{}, "registry.k8s.io/pause:1.0", dir)
 	require.NoError(t, err)
 	require.Equal(t, "registry.k8s.io/pause@sha256:a78c2d6208eff9b672de43f880093100050983047b7b0afe0217d3656e1b0d5f", images.Digest)
 	require.Equal(t, "amd64", images.Arch)
@@ -383,12 +543,46 @@ func TestGetDirectoryTree(t *testing.T) {
 	}
 
 	impl := spdxDefaultImplementation{}
-	readFiles, err := impl.GetDirectoryTree(dir)
+	readFiles, err := impl.GetDirectoryTree(dir, false, false)
 	require.NoError(t, err)
 	// Now, compare contents of th array is the same
 	require.ElementsMatch(t, files, readFiles)
 }
 
+func TestGetDirectoryTreeFollowSymlinks(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	for _, path := range []string{
+		filepath.Join(dir, "src", "main.go"),
+		filepath.Join(dir, "lib", "util.go"),
+		filepath.Join(outside, "pkg", "ext.go"),
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(path, []byte("package main\n"), os.FileMode(0o644)))
+	}
+	for link, target := range map[string]string{
+		"src/util.go": "../lib/util.go",          // File in the tree
+		"vendor":      filepath.Join(outside),    // Directory out of the tree
+		"src/loop":    "..",                      // Cycle back to the root
+		"src/lib":     filepath.Join(dir, "lib"), // Directory already scanned
+		"dangling":    "missing.go",
+	} {
+		require.NoError(t, os.Symlink(target, filepath.Join(dir, link)))
+	}
+
+	impl := spdxDefaultImplementation{}
+
+	// Symlinks are skipped by default
+	tree, err := impl.GetDirectoryTree(dir, false, false)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"src/main.go", "lib/util.go"}, tree)
+
+	tree, err = impl.GetDirectoryTree(dir, false, true)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		"src/main.go", "lib/util.go", "src/util.go", "vendor/pkg/ext.go",
+	}, tree)
+}
+
 func TestIgnorePatterns(t *testing.T) {
 	dir, err := os.MkdirTemp("", "dir-tree-")
 	require.NoError(t, err)
@@ -396,12 +590,12 @@ func TestIgnorePatterns(t *testing.T) {
 	impl := spdxDefaultImplementation{}
 
 	// First, a dir without a gitignore should return no patterns, but not err
-	p, err := impl.IgnorePatterns(dir, []string{}, false)
+	p, err := impl.IgnorePatterns(dir, []string{}, false, false)
 	require.NoError(t, err)
 	require.Len(t, p, 0)
 
 	// If we pass an extra pattern, we should get it back
-	p, err = impl.IgnorePatterns(dir, []string{".vscode"}, false)
+	p, err = impl.IgnorePatterns(dir, []string{".vscode"}, false, false)
 	require.NoError(t, err)
 	require.Len(t, p, 1)
 
@@ -411,11 +605,74 @@ func TestIgnorePatterns(t *testing.T) {
 		[]byte("# NFS\n.nfs*\n\n# OSX leaves these everywhere on SMB shares\n._*\n\n# OSX trash\n.DS_Store\n"),
 		os.FileMode(0o755),
 	))
-	p, err = impl.IgnorePatterns(dir, nil, false)
+	p, err = impl.IgnorePatterns(dir, nil, false, false)
 	require.NoError(t, err)
 	require.Len(t, p, 4)
 }
 
+func TestIgnorePatternsNestedGitignore(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":          "*.log\nignored/\n",
+		"sub/.gitignore":      "!keep.log\ngen/\n",
+		"sub/deep/.gitignore": "*.txt\n",
+		"ignored/.gitignore":  "!*\n",
+		"a.log":               "",
+		"notes.txt":           "",
+		"gen/main.go":         "",
+		"ignored/main.go":     "",
+		"sub/keep.log":        "",
+		"sub/other.log":       "",
+		"sub/gen/main.go":     "",
+		"sub/deep/notes.txt":  "",
+		"sub/deep/main.go":    "",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+	}
+
+	impl := spdxDefaultImplementation{}
+	tree, err := impl.GetDirectoryTree(dir, false, false)
+	require.NoError(t, err)
+	patterns, err := impl.IgnorePatterns(dir, nil, false, false)
+	require.NoError(t, err)
+	// .git/ and the patterns of the files outside ignored/
+	require.Len(t, patterns, 6)
+
+	// The patterns apply under the directory of their file, deeper files
+	// override the ones above them and the .gitignore in an ignored
+	// directory is not read
+	files := impl.ApplyIgnorePatterns(tree, patterns, false)
+	sort.Strings(files)
+	require.Equal(t, []string{
+		".gitignore", "gen/main.go", "notes.txt", "sub/.gitignore",
+		"sub/deep/.gitignore", "sub/deep/main.go", "sub/keep.log",
+	}, files)
+}
+
+func TestGitignorePatternsFromReader(t *testing.T) {
+	patterns, err := ReadIgnorePatterns(strings.NewReader("# Build output\nbuild/\n\n*.log\n!keep.log\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"build/", "*.log", "!keep.log"}, patterns)
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "build"), os.FileMode(0o755)))
+	for _, f := range []string{"main.go", "debug.log", "keep.log", "notes.txt", "build/app", ".gitignore"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, f), []byte(f), os.FileMode(0o644)))
+	}
+	// The rules supplied in the options replace those in the directory
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("notes.txt\n"), os.FileMode(0o644)))
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{GitignorePatterns: patterns}, dir)
+	require.NoError(t, err)
+	files := []string{}
+	for _, f := range pkg.Files() {
+		files = append(files, f.FileName)
+	}
+	require.ElementsMatch(t, []string{"main.go", "keep.log", "notes.txt", ".gitignore"}, files)
+}
+
 func TestRecursiveSearch(t *testing.T) {
 	p := NewPackage()
 	p.SetSPDXID("p-top")
@@ -491,9 +748,1763 @@ func TestPurlFromImage(t *testing.T) {
 			},
 			"pkg:oci/nginx@sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57?arch=amd64&os=darwin&repository_url=index.docker.io%2Flibrary",
 		},
+		{
+			ImageReferenceInfo{
+				Digest: "registry.example.com/app/web@sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57",
+				Tag:    "v1.0.0",
+			},
+			"pkg:oci/web@sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57?repository_url=registry.example.com%2Fapp&tag=v1.0.0",
+		},
 	} {
 		impl := spdxDefaultImplementation{}
-		p := impl.purlFromImage(&tc.info)
+		p := impl.purlFromImage(context.Background(), nil, &tc.info)
 		require.Equal(t, tc.expected, p)
 	}
 }
+
+func TestPurlFromImageNormalization(t *testing.T) {
+	hash := "sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57"
+	for _, tc := range []struct {
+		name     string
+		refs     []string
+		expected string
+	}{
+		{
+			name: "docker hub shorthand",
+			refs: []string{
+				"nginx", "library/nginx", "docker.io/nginx", "docker.io/library/nginx",
+				"index.docker.io/nginx", "registry-1.docker.io/nginx", "registry-1.docker.io/library/nginx",
+			},
+			expected: "pkg:oci/nginx@sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57?repository_url=index.docker.io%2Flibrary",
+		},
+		{
+			name:     "docker hub user image",
+			refs:     []string{"someone/tool", "docker.io/someone/tool", "registry-1.docker.io/someone/tool"},
+			expected: "pkg:oci/tool@sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57?repository_url=index.docker.io%2Fsomeone",
+		},
+		{
+			name:     "ghcr",
+			refs:     []string{"ghcr.io/owner/app", "GHCR.io/owner/app", "ghcr.io:443/owner/app"},
+			expected: "pkg:oci/app@sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57?repository_url=ghcr.io%2Fowner",
+		},
+		{
+			name:     "quay",
+			refs:     []string{"quay.io/org/team/app", "Quay.IO/org/team/app", "quay.io:443/org/team/app"},
+			expected: "pkg:oci/app@sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57?repository_url=quay.io%2Forg%2Fteam",
+		},
+		{
+			name:     "custom port",
+			refs:     []string{"registry.example.com:5000/app/web", "Registry.Example.com:5000/app/web"},
+			expected: "pkg:oci/web@sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57?repository_url=registry.example.com:5000%2Fapp",
+		},
+		{
+			name:     "no namespace",
+			refs:     []string{"registry.example.com/web", "registry.example.com:80/web"},
+			expected: "pkg:oci/web@sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57?repository_url=registry.example.com",
+		},
+	} {
+		impl := spdxDefaultImplementation{}
+		for _, ref := range tc.refs {
+			p := impl.purlFromImage(context.Background(), nil, &ImageReferenceInfo{Digest: ref + "@" + hash})
+			require.Equal(t, tc.expected, p, "%s: %s", tc.name, ref)
+		}
+	}
+}
+
+func TestManifestArtifactType(t *testing.T) {
+	for mediaType, expected := range map[types.MediaType]string{
+		"":                     "",
+		types.OCIConfigJSON:    "",
+		types.DockerConfigJSON: "",
+		"application/vnd.cncf.helm.config.v1+json": "application/vnd.cncf.helm.config.v1+json",
+	} {
+		manifest := &v1.Manifest{Config: v1.Descriptor{MediaType: mediaType}}
+		require.Equal(t, expected, manifestArtifactType(manifest), string(mediaType))
+	}
+}
+
+func TestReferenceTag(t *testing.T) {
+	hash := "sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57"
+	for _, tc := range []struct {
+		ref    string
+		digest string
+		tag    string
+	}{
+		{"registry.example.com/app/web:v1.0.0@" + hash, hash, "v1.0.0"},
+		{"registry.example.com:5000/app/web:v2@" + hash, hash, "v2"},
+		{"registry.example.com:5000/app/web@" + hash, hash, ""},
+		{"registry.example.com/app/web:v3", "", "v3"},
+	} {
+		ref, err := name.ParseReference(tc.ref)
+		require.NoError(t, err, tc.ref)
+		require.Equal(t, tc.tag, referenceTag(ref), tc.ref)
+		if tc.digest != "" {
+			// The digest remains the identity of the reference
+			d, ok := ref.(name.Digest)
+			require.True(t, ok, tc.ref)
+			require.Equal(t, tc.digest, d.DigestStr())
+			require.Equal(t, "app/web", d.Context().RepositoryStr())
+		}
+	}
+}
+
+func TestReferenceInfoToPackageTag(t *testing.T) {
+	impl := spdxDefaultImplementation{}
+	info := &ImageReferenceInfo{
+		Digest:  "registry.example.com/app/web@sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57",
+		Tag:     "v1.0.0",
+		Archive: writeTestImageTarball(t, "../osinfo/testdata/link-with-no-dots.tar.gz"),
+		Arch:    "amd64",
+		OS:      "linux",
+	}
+	pkg, err := impl.referenceInfoToPackage(context.Background(), &Options{}, info)
+	require.NoError(t, err)
+	require.Equal(t, "sha256:c183d71d4173c3148b73d17aba0f37c83ca8291d1f303d74a3fac4f5e1d01f57", pkg.Name)
+
+	comments := []string{}
+	for _, a := range pkg.Annotations {
+		comments = append(comments, a.Comment)
+	}
+	require.Contains(t, comments, imageTagAnnotation+"v1.0.0")
+	require.Contains(t, pkg.Purl().ToString(), "tag=v1.0.0")
+
+	// An image without platform is warned about once
+	log, hook := logtest.NewNullLogger()
+	info.Arch, info.OS = "", ""
+	_, err = impl.referenceInfoToPackage(context.Background(), &Options{Logger: log, CollectWarnings: true}, info)
+	require.NoError(t, err)
+	collected := 0
+	for _, w := range impl.Warnings() {
+		if strings.Contains(w.Message, unknownPlatformAnnotation) {
+			collected++
+		}
+	}
+	require.Equal(t, 1, collected)
+	logged := 0
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel && strings.Contains(entry.Message, unknownPlatformAnnotation) {
+			logged++
+		}
+	}
+	require.Equal(t, 1, logged)
+}
+
+func TestPackageFromDirectoryEmbeddedLicenses(t *testing.T) {
+	dir := t.TempDir()
+	list, err := license.EmbeddedLicenseList()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "LICENSE"), []byte(list.Licenses["MIT"].LicenseText), os.FileMode(0o644),
+	))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "main.go"), []byte("package main\n"), os.FileMode(0o644),
+	))
+
+	// The default options download the license list. When that fails,
+	// the reader classifies using the embedded list.
+	opts := defaultSPDXOptions
+	opts.LicenseCacheDir = t.TempDir()
+	opts.LicenseData = t.TempDir()
+	breakLicenseDownload(t, opts.LicenseCacheDir)
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&opts, dir)
+	require.NoError(t, err)
+	require.Equal(t, "MIT", pkg.LicenseConcluded)
+	require.Len(t, pkg.Files(), 2)
+}
+
+// breakLicenseDownload makes the license list downloads using cacheDir
+// fail without reaching the network, by caching an invalid answer to the
+// lookup of the latest list version
+func breakLicenseDownload(t *testing.T, cacheDir string) {
+	t.Helper()
+	cacheFile := filepath.Join(
+		cacheDir, fmt.Sprintf("%x.json", sha256.New().Sum([]byte(license.LatestReleaseURL))),
+	)
+	require.NoError(t, os.WriteFile(cacheFile, []byte("not json"), os.FileMode(0o644)))
+}
+
+func TestPackageFromDirectoryFileLicenses(t *testing.T) {
+	// Files are scanned concurrently, run with -race to check the
+	// license of a file does not leak into the others
+	dir := t.TempDir()
+	list, err := license.EmbeddedLicenseList()
+	require.NoError(t, err)
+	licenseIDs := []string{"MIT", "ISC", "BSD-2-Clause", "Zlib", NONE}
+	expected := map[string]string{}
+	for i := 0; i < 40; i++ {
+		id := licenseIDs[i%len(licenseIDs)]
+		name := fmt.Sprintf("file-%02d.txt", i)
+		content := "no license here\n"
+		if id != NONE {
+			content = list.Licenses[id].LicenseText
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+		expected[name] = id
+	}
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{}, dir)
+	require.NoError(t, err)
+	require.Len(t, pkg.Files(), len(expected))
+	for _, f := range pkg.Files() {
+		require.Equal(t, expected[filepath.Base(f.SourceFile)], f.LicenseInfoInFile, f.SourceFile)
+	}
+}
+
+func TestPackageFromDirectorySkipLicenseScan(t *testing.T) {
+	dir := t.TempDir()
+	list, err := license.EmbeddedLicenseList()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "LICENSE"), []byte(list.Licenses["MIT"].LicenseText), os.FileMode(0o644),
+	))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "main.go"), []byte("package main\n"), os.FileMode(0o644),
+	))
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{SkipLicenseScan: true, CollectStats: true}, dir)
+	require.NoError(t, err)
+	require.Equal(t, NOASSERTION, pkg.LicenseConcluded)
+	require.Len(t, pkg.Files(), 2)
+	for _, f := range pkg.Files() {
+		require.Equal(t, NOASSERTION, f.LicenseInfoInFile, f.Name)
+		require.Equal(t, NOASSERTION, f.LicenseConcluded, f.Name)
+		require.NotEmpty(t, f.Checksum["SHA256"], f.Name)
+	}
+	require.Zero(t, impl.Stats().LicenseClassification)
+	require.NotZero(t, impl.Stats().FileScan)
+
+	// The document is still valid without the license data
+	doc := NewDocument()
+	doc.Name = "test-document"
+	doc.Namespace = "https://example.com/spdx/test"
+	pkg.DownloadLocation = NOASSERTION
+	require.NoError(t, doc.AddPackage(pkg))
+	require.Empty(t, doc.Validate())
+}
+
+func TestPackageFromDirectoryDualLicense(t *testing.T) {
+	list, err := license.EmbeddedLicenseList()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	for name, id := range map[string]string{
+		"LICENSE-APACHE": "Apache-2.0",
+		"LICENSE-MIT":    "MIT",
+		// The same license in another file is only reported once
+		"COPYING.MIT": "MIT",
+	} {
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, name), []byte(list.Licenses[id].LicenseText), os.FileMode(0o644),
+		))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), os.FileMode(0o644)))
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{}, dir)
+	require.NoError(t, err)
+	require.Equal(t, "Apache-2.0 AND MIT", pkg.LicenseConcluded)
+
+	// The licenses of the license files are listed in the rendered package
+	pkg.BuildID("dual")
+	rendered, err := pkg.Render()
+	require.NoError(t, err)
+	require.Contains(t, rendered, "PackageLicenseConcluded: Apache-2.0 AND MIT\n")
+	require.Contains(t, rendered, "PackageLicenseInfoFromFiles: Apache-2.0\nPackageLicenseInfoFromFiles: MIT\n")
+	require.Equal(t, []string{"Apache-2.0", "MIT"}, pkg.LicenseInfoFromFiles)
+
+	// A single license file is concluded as is
+	require.NoError(t, os.Remove(filepath.Join(dir, "LICENSE-APACHE")))
+	pkg, err = impl.PackageFromDirectory(&Options{}, dir)
+	require.NoError(t, err)
+	require.Equal(t, "MIT", pkg.LicenseConcluded)
+	pkg.BuildID("dual")
+	rendered, err = pkg.Render()
+	require.NoError(t, err)
+	require.Contains(t, rendered, "PackageLicenseInfoFromFiles: MIT\n")
+	require.NotContains(t, rendered, "PackageLicenseInfoFromFiles: Apache-2.0")
+}
+
+func TestGetDirectoryLicenseExceptions(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"LICENSE", "COPYING", "LICENSE.exception"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("license text\n"), os.FileMode(0o644)))
+	}
+	fake := &licensefakes.FakeReaderImplementation{}
+	fake.LicenseFromLabelCalls(func(label string) *license.License {
+		return &license.License{LicenseID: label}
+	})
+	reader := &license.Reader{}
+	require.NoError(t, reader.SetImplementation(fake))
+
+	for _, tc := range []struct {
+		found    []string
+		expected string
+		detected []string
+		warning  string
+	}{
+		{[]string{"GPL-2.0-only", "Classpath-exception-2.0"}, "GPL-2.0-only WITH Classpath-exception-2.0", []string{"GPL-2.0-only"}, ""},
+		{[]string{"GPL-2.0-only", "Linux-syscall-note", "Classpath-exception-2.0"}, "GPL-2.0-only WITH Classpath-exception-2.0 AND GPL-2.0-only WITH Linux-syscall-note", []string{"GPL-2.0-only"}, ""},
+		{[]string{"MIT", "BSD-3-Clause"}, "BSD-3-Clause AND MIT", []string{"BSD-3-Clause", "MIT"}, ""},
+		{[]string{"Apache-2.0 OR MIT", "Zlib"}, "(Apache-2.0 OR MIT) AND Zlib", []string{"Apache-2.0 OR MIT", "Zlib"}, ""},
+		// Exceptions are left out when the license they apply to is not known
+		{[]string{"Apache-2.0", "MIT", "LLVM-exception"}, "Apache-2.0 AND MIT", []string{"Apache-2.0", "MIT"}, "Not concluding license exceptions LLVM-exception"},
+	} {
+		results := []*license.ClassifyResult{}
+		for _, id := range tc.found {
+			results = append(results, &license.ClassifyResult{License: &license.License{LicenseID: id}})
+		}
+		fake.ClassifyLicenseFilesReturns(results, nil, nil)
+		impl := &spdxDefaultImplementation{}
+		lic, detected, err := impl.directoryLicenses(reader, dir, &Options{CollectWarnings: true})
+		require.NoError(t, err)
+		require.NotNil(t, lic)
+		require.Equal(t, tc.expected, lic.LicenseID)
+		require.Equal(t, tc.detected, detected)
+		if tc.warning == "" {
+			require.Empty(t, impl.Warnings())
+		} else {
+			require.Len(t, impl.Warnings(), 1)
+			require.Contains(t, impl.Warnings()[0].Message, tc.warning)
+		}
+	}
+}
+
+func writeTestZip(t *testing.T, entries map[string]string) string {
+	zipPath := filepath.Join(t.TempDir(), "test.jar")
+	f, err := os.Create(zipPath)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return zipPath
+}
+
+func TestExtractZipTmp(t *testing.T) {
+	zipPath := writeTestZip(t, map[string]string{
+		"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\n",
+		"com/example/App.txt":  "Hello",
+		"../../evil.txt":       "zip slip",
+	})
+
+	impl := spdxDefaultImplementation{}
+	dir, err := impl.ExtractZipTmp(zipPath)
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.FileExists(t, filepath.Join(dir, "META-INF/MANIFEST.MF"))
+	data, err := os.ReadFile(filepath.Join(dir, "com/example/App.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "Hello", string(data))
+
+	// The traversal entry must not be written anywhere
+	require.NoFileExists(t, filepath.Join(filepath.Dir(dir), "evil.txt"))
+	require.NoFileExists(t, filepath.Join(dir, "evil.txt"))
+	tree, err := impl.GetDirectoryTree(dir, false, false)
+	require.NoError(t, err)
+	require.Len(t, tree, 2)
+}
+
+func TestExtractTempDir(t *testing.T) {
+	root := t.TempDir()
+	opts := &Options{TempDir: root}
+	impl := spdxDefaultImplementation{}
+
+	tarPath := writeTestImageTarball(t, "../osinfo/testdata/link-with-no-dots.tar.gz")
+	dir, err := impl.ExtractTarballTmp(opts, tarPath)
+	require.NoError(t, err)
+	require.Equal(t, root, filepath.Dir(dir))
+	impl.tempPaths.remove(dir)
+
+	zipPath := writeTestZip(t, map[string]string{"App.txt": "Hello"})
+	dir, err = impl.extractZipTmp(opts, zipPath)
+	require.NoError(t, err)
+	require.Equal(t, root, filepath.Dir(dir))
+	impl.tempPaths.remove(dir)
+
+	// Scans work in the directory and leave nothing behind
+	_, err = impl.PackageFromImageTarball(opts, tarPath)
+	require.NoError(t, err)
+	_, err = impl.PackageFromZip(opts, zipPath)
+	require.NoError(t, err)
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// A missing directory fails the scans instead of using the default
+	opts.TempDir = filepath.Join(root, "missing")
+	_, err = impl.PackageFromImageTarball(opts, tarPath)
+	require.Error(t, err)
+}
+
+func TestPackageFromZip(t *testing.T) {
+	zipPath := writeTestZip(t, map[string]string{
+		"pkg/__init__.py": "",
+		"pkg/module.py":   "print('hello')\n",
+	})
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromZip(&Options{}, zipPath)
+	require.NoError(t, err)
+	require.Equal(t, "test.jar", pkg.Name)
+	require.Equal(t, "test.jar", pkg.FileName)
+	require.Len(t, pkg.Files(), 2)
+	require.NotEmpty(t, pkg.Checksum["SHA256"])
+}
+
+// writeTestImageTarball builds a docker image archive from a list of
+// layer tarballs and returns its path
+func writeTestImageTarball(t *testing.T, layerFiles ...string) string {
+	layers := []v1.Layer{}
+	for _, lf := range layerFiles {
+		layer, err := tarball.LayerFromFile(lf)
+		require.NoError(t, err)
+		layers = append(layers, layer)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	require.NoError(t, err)
+
+	tag, err := name.NewTag("registry.example.com/test/image:v1.0.0")
+	require.NoError(t, err)
+	tarPath := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, tarball.WriteToFile(tarPath, tag, img))
+	return tarPath
+}
+
+func TestPullImagesToArchiveContext(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/image:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Registry requests fail once the context is done
+	impl := spdxDefaultImplementation{}
+	_, err = impl.ImageRefToPackage(ctx, ref.String(), &Options{})
+	require.Error(t, err)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// Variants of an index are not downloaded after the context is done
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	variant := ref.Context().Digest(digest.String()).String()
+	impl = spdxDefaultImplementation{referenceCache: referenceCache{
+		resolve: func(_ context.Context, ref string) (*ImageReferenceInfo, error) {
+			return &ImageReferenceInfo{
+				Digest: variant,
+				Images: []ImageReferenceInfo{{Digest: variant}, {Digest: variant}},
+			}, nil
+		},
+	}}
+	_, err = impl.PullImagesToArchive(ctx, &Options{DownloadConcurrency: 1}, ref.String(), t.TempDir())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), context.Canceled.Error())
+
+	// And they are with a live context
+	refs, err := impl.PullImagesToArchive(context.Background(), &Options{}, ref.String(), t.TempDir())
+	require.NoError(t, err)
+	require.Len(t, refs.Images, 2)
+}
+
+func TestResolveImageReferences(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	var index v1.ImageIndex = empty.Index
+	digests := map[string]string{}
+	for _, arch := range []string{"amd64", "arm64"} {
+		img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{OS: "linux", Architecture: arch})
+		require.NoError(t, err)
+		img, err = mutate.AppendLayers(img, static.NewLayer([]byte(arch), types.DockerLayer))
+		require.NoError(t, err)
+		d, err := img.Digest()
+		require.NoError(t, err)
+		digests[arch] = d.String()
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: arch}},
+		})
+	}
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/index:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, index))
+
+	info, err := ResolveImageReferences(ref.String(), nil)
+	require.NoError(t, err)
+	require.Len(t, info.Images, 2)
+	for _, img := range info.Images {
+		require.Equal(t, "linux", img.OS)
+		require.Equal(t, digests[img.Arch], strings.TrimPrefix(img.Digest, ref.Context().String()+"@"))
+		require.Empty(t, img.Archive)
+	}
+
+	info, err = ResolveImageReferences(ref.String(), &Options{Platforms: []string{"linux/arm64"}})
+	require.NoError(t, err)
+	require.Len(t, info.Images, 1)
+	require.Equal(t, "arm64", info.Images[0].Arch)
+
+	_, err = ResolveImageReferences(ref.String(), &Options{Platforms: []string{"windows/amd64"}})
+	require.Error(t, err)
+}
+
+func TestResolveImageReferencesAttestations(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{OS: "linux", Architecture: "amd64"})
+	require.NoError(t, err)
+	attestation, err := mutate.AppendLayers(empty.Image, static.NewLayer([]byte("{}"), "application/vnd.in-toto+json"))
+	require.NoError(t, err)
+	attestationDigest, err := attestation.Digest()
+	require.NoError(t, err)
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{
+			Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+		},
+		mutate.IndexAddendum{
+			Add: attestation, Descriptor: v1.Descriptor{
+				Platform:    &v1.Platform{OS: "unknown", Architecture: "unknown"},
+				Annotations: map[string]string{"vnd.docker.reference.type": "attestation-manifest"},
+			},
+		},
+	)
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/signed:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, index))
+
+	info, err := ResolveImageReferences(ref.String(), nil)
+	require.NoError(t, err)
+	require.Len(t, info.Images, 1)
+	require.Equal(t, "amd64", info.Images[0].Arch)
+	require.Len(t, info.Attestations, 1)
+	require.Equal(t, ref.Context().Digest(attestationDigest.String()).String(), info.Attestations[0].Digest)
+
+	// An index of attestations only is not read as an image
+	attestationsRef, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/attestations:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(attestationsRef, mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{
+			Add: attestation, Descriptor: v1.Descriptor{
+				Annotations: map[string]string{"vnd.docker.reference.type": "attestation-manifest"},
+			},
+		},
+	)))
+	_, err = ResolveImageReferences(attestationsRef.String(), nil)
+	require.ErrorContains(t, err, "only 1 signatures and attestations")
+
+	for _, tc := range []struct {
+		descriptor  v1.Descriptor
+		attestation bool
+	}{
+		{v1.Descriptor{MediaType: types.OCIManifestSchema1, Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}, false},
+		{v1.Descriptor{MediaType: types.DockerManifestSchema2}, false},
+		{v1.Descriptor{MediaType: types.OCIManifestSchema1, ArtifactType: "application/vnd.dev.cosign.artifact.sig.v1+json"}, true},
+		{v1.Descriptor{MediaType: types.OCIManifestSchema1, ArtifactType: "application/vnd.in-toto+json"}, true},
+		{v1.Descriptor{MediaType: "application/vnd.dev.cosign.simplesigning.v1+json"}, true},
+		{v1.Descriptor{MediaType: types.OCIManifestSchema1, Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}}, true},
+	} {
+		tc := tc
+		require.Equal(t, tc.attestation, isAttestationManifest(&tc.descriptor), tc.descriptor)
+	}
+}
+
+// staticKeychain resolves the same credentials for every registry
+type staticKeychain struct {
+	auth authn.Authenticator
+}
+
+func (kc staticKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return kc.auth, nil
+}
+
+func TestPackageFromImageTarballIncludePaths(t *testing.T) {
+	tarPath := writeTestImageTarball(t, "../osinfo/testdata/link-with-no-dots.tar.gz")
+
+	// The include paths filter the files of the layers, the image archive
+	// itself is extracted whole to read its manifest
+	impl := spdxDefaultImplementation{}
+	for _, mode := range []TempStorageMode{TempStoragePlain, TempStorageCompressed} {
+		pkg, err := impl.PackageFromImageTarball(&Options{
+			AddTarFiles: true, IncludePaths: []string{"/usr"}, TempStorageMode: mode,
+		}, tarPath)
+		require.NoError(t, err, mode)
+		files := []string{}
+		for _, rel := range pkg.Relationships {
+			if layer, ok := rel.Peer.(*Package); ok {
+				for _, f := range layer.Files() {
+					files = append(files, f.FileName)
+				}
+			}
+		}
+		require.Len(t, files, 1, mode)
+		require.True(t, strings.HasSuffix(files[0], "usr/lib/os-release"), files[0])
+	}
+}
+
+func TestPackageFromImageTarballPlainFallback(t *testing.T) {
+	tarPath := filepath.Join(t.TempDir(), "tool.tar")
+	require.NoError(t, os.WriteFile(tarPath, testLayerData(t, 0, 1), os.FileMode(0o644)))
+
+	// Without the fallback a tarball with no manifest is an error
+	impl := spdxDefaultImplementation{}
+	_, err := impl.PackageFromImageTarball(&Options{AddTarFiles: true}, tarPath)
+	require.Error(t, err)
+
+	pkg, err := impl.PackageFromImageTarball(&Options{AddTarFiles: true, PlainTarballFallback: true}, tarPath)
+	require.NoError(t, err)
+	require.Equal(t, "tool.tar", pkg.Name)
+	require.NotEmpty(t, pkg.Checksum["SHA256"])
+	require.Len(t, pkg.Files(), 1)
+	require.Equal(t, "layer0/file0.txt", pkg.Files()[0].FileName)
+}
+
+func TestImageTarballOSPackages(t *testing.T) {
+	tarPath := writeTestImageTarball(t,
+		"../osinfo/testdata/link-with-no-dots.tar.gz",
+		"../osinfo/testdata/dpkg-layer1.tar.gz",
+	)
+	impl := spdxDefaultImplementation{}
+	packages, err := impl.imageTarballOSPackages(nil, tarPath)
+	require.NoError(t, err)
+	require.Len(t, packages, 83)
+	for _, p := range packages {
+		require.Equal(t, "deb", p.Type)
+		require.NotEmpty(t, p.Package)
+		require.NotEmpty(t, p.Version)
+	}
+
+	// An image without package data returns an empty list
+	tarPath = writeTestImageTarball(t, "../osinfo/testdata/link-with-no-dots.tar.gz")
+	packages, err = impl.imageTarballOSPackages(nil, tarPath)
+	require.NoError(t, err)
+	require.Empty(t, packages)
+}
+
+// writeTestDockerArchive writes a docker archive with the layers stored
+// verbatim (ie, without recompressing them) under the specified names
+func writeTestDockerArchive(t testing.TB, layerNames []string, layerData [][]byte) string {
+	manifest := []ArchiveManifest{{
+		ConfigFilename: "config.json",
+		RepoTags:       []string{"registry.example.com/test/image:v1.0.0"},
+		LayerFiles:     layerNames,
+	}}
+	manifestData, err := json.Marshal(manifest)
+	require.NoError(t, err)
+
+	tarPath := filepath.Join(t.TempDir(), "archive.tar")
+	f, err := os.Create(tarPath)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	files := map[string][]byte{
+		archiveManifestFilename: manifestData,
+		"config.json":           []byte(`{"architecture":"amd64","os":"linux"}`),
+	}
+	for i, name := range layerNames {
+		files[name] = layerData[i]
+	}
+	for name, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return tarPath
+}
+
+// extractionDiskUsage returns the size of the files in the directories
+// created by the extractions in tmpDir, leaving out the data of others
+// such as the license readers
+func extractionDiskUsage(tmpDir string) int64 {
+	var size int64
+	entries, _ := os.ReadDir(tmpDir) //nolint:errcheck
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), "spdx-") {
+			continue
+		}
+		filepath.WalkDir(filepath.Join(tmpDir, e.Name()), func(_ string, d fs.DirEntry, err error) error { //nolint:errcheck
+			if err == nil && !d.IsDir() {
+				if info, err := d.Info(); err == nil {
+					size += info.Size()
+				}
+			}
+			return nil
+		})
+	}
+	return size
+}
+
+func TestPackageFromImageTarballMixedCompression(t *testing.T) {
+	// The first layer is gzipped, the second one is a plain tar
+	gzLayer, err := os.ReadFile("../osinfo/testdata/dpkg-layer1.tar.gz")
+	require.NoError(t, err)
+	plainTar := writeTestTarball(t, false)
+	defer os.Remove(plainTar.Name())
+	plainLayer, err := os.ReadFile(plainTar.Name())
+	require.NoError(t, err)
+	osLayer, err := os.ReadFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+
+	// Layer names don't hint the compression: the gzipped layers
+	// are named .tar and the plain layer .tar.gz
+	tarPath := writeTestDockerArchive(t,
+		[]string{"os/layer.tar", "dpkg/layer.tar", "plain/layer.tar.gz"},
+		[][]byte{osLayer, gzLayer, plainLayer},
+	)
+
+	impl := spdxDefaultImplementation{}
+	for _, opts := range []*Options{
+		{ScanImages: true, AddTarFiles: true},
+		{ScanImages: true, AnalyzeLayers: true},
+		{ScanImages: true, AddTarFiles: true, LayerWorkers: 3},
+	} {
+		pkg, err := impl.PackageFromImageTarball(opts, tarPath)
+		require.NoError(t, err)
+
+		layers := []*Package{}
+		for _, rel := range pkg.Relationships {
+			if p, ok := rel.Peer.(*Package); ok {
+				layers = append(layers, p)
+			}
+		}
+		require.Len(t, layers, 3)
+
+		// Layers are listed in the manifest order
+		for i, data := range [][]byte{osLayer, gzLayer, plainLayer} {
+			require.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(data)), layers[i].Name)
+		}
+
+		// OS packages are read from the gzipped layer
+		osPackages := 0
+		for _, rel := range layers[1].Relationships {
+			if _, ok := rel.Peer.(*Package); ok {
+				osPackages++
+			}
+		}
+		require.Equal(t, 83, osPackages)
+	}
+}
+
+func TestPackageFromImageTarballMultipleImages(t *testing.T) {
+	// writeArchive writes an archive with the manifest entries
+	writeArchive := func(manifest []ArchiveManifest) string {
+		manifestData, err := json.Marshal(manifest)
+		require.NoError(t, err)
+		tarPath := filepath.Join(t.TempDir(), "images.tar")
+		f, err := os.Create(tarPath)
+		require.NoError(t, err)
+		defer f.Close()
+		tw := tar.NewWriter(f)
+		files := map[string][]byte{
+			archiveManifestFilename: manifestData,
+			"config.json":           []byte(`{"architecture":"amd64","os":"linux"}`),
+			"base/layer.tar":        testLayerData(t, 0, 2),
+			"app/layer.tar":         testLayerData(t, 1, 3),
+		}
+		for name, data := range files {
+			require.NoError(t, tw.WriteHeader(&tar.Header{
+				Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+			}))
+			_, err := tw.Write(data)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		return tarPath
+	}
+
+	// Two images saved together, sharing their base layer
+	tarPath := writeArchive([]ArchiveManifest{
+		{
+			ConfigFilename: "config.json",
+			RepoTags:       []string{"registry.example.com/test/base:v1"},
+			LayerFiles:     []string{"base/layer.tar"},
+		},
+		{
+			ConfigFilename: "config.json",
+			RepoTags:       []string{"registry.example.com/test/app:v1"},
+			LayerFiles:     []string{"base/layer.tar", "app/layer.tar"},
+		},
+	})
+
+	impl := spdxDefaultImplementation{}
+	manifests, err := impl.ReadArchiveManifests(
+		filepath.Join(t.TempDir(), "missing", archiveManifestFilename),
+	)
+	require.Error(t, err)
+	require.Nil(t, manifests)
+
+	for _, opts := range []*Options{
+		{AddTarFiles: true},
+		{AddTarFiles: true, LazyLayers: true},
+		{StreamLayers: true},
+	} {
+		pkg, err := impl.PackageFromImageTarball(opts, tarPath)
+		require.NoError(t, err)
+		require.Equal(t, "images.tar", pkg.Name)
+
+		// Each image gets its package under the archive, with its layers
+		images := map[string][]string{}
+		for _, rel := range pkg.Relationships {
+			image, ok := rel.Peer.(*Package)
+			if !ok {
+				continue
+			}
+			images[image.Name] = []string{}
+			for _, layerRel := range image.Relationships {
+				if layer, ok := layerRel.Peer.(*Package); ok {
+					images[image.Name] = append(images[image.Name], layer.Name)
+				}
+			}
+		}
+		baseLayer := fmt.Sprintf("sha256:%x", sha256.Sum256(testLayerData(t, 0, 2)))
+		appLayer := fmt.Sprintf("sha256:%x", sha256.Sum256(testLayerData(t, 1, 3)))
+		require.Equal(t, map[string][]string{
+			"registry.example.com/test/base:v1": {baseLayer},
+			"registry.example.com/test/app:v1":  {baseLayer, appLayer},
+		}, images)
+	}
+
+	// Manifests listing no images fail
+	for _, opts := range []*Options{{}, {LazyLayers: true}, {StreamLayers: true}} {
+		_, err := impl.PackageFromImageTarball(opts, writeArchive([]ArchiveManifest{}))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "lists no images")
+	}
+}
+
+// testLayerData returns a plain tar layer with numFiles small text files
+func testLayerData(t testing.TB, layer, numFiles int) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < numFiles; i++ {
+		data := []byte(fmt.Sprintf("File %d of layer %d\n", i, layer))
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     fmt.Sprintf("layer%d/file%d.txt", layer, i),
+			Mode:     0o644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func BenchmarkPackageFromImageTarball(b *testing.B) {
+	const numLayers = 16
+	names := []string{}
+	layers := [][]byte{}
+	for i := 0; i < numLayers; i++ {
+		names = append(names, fmt.Sprintf("layer%d/layer.tar", i))
+		layers = append(layers, testLayerData(b, i, 20))
+	}
+	tarPath := writeTestDockerArchive(b, names, layers)
+
+	impl := spdxDefaultImplementation{}
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			opts := &Options{AddTarFiles: true, LayerWorkers: workers}
+			for i := 0; i < b.N; i++ {
+				if _, err := impl.PackageFromImageTarball(opts, tarPath); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPackageFromImageTarballOSPackageLayers(t *testing.T) {
+	// The dpkg database is updated in the last layer
+	tarPath := writeTestImageTarball(t,
+		"../osinfo/testdata/link-with-no-dots.tar.gz",
+		"../osinfo/testdata/dpkg-layer1.tar.gz",
+		"../osinfo/testdata/dpkg-layer2.tar.gz",
+	)
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromImageTarball(&Options{ScanImages: true}, tarPath)
+	require.NoError(t, err)
+
+	layerPackages := []map[string]struct{}{}
+	for _, rel := range pkg.Relationships {
+		layer, ok := rel.Peer.(*Package)
+		if !ok {
+			continue
+		}
+		names := map[string]struct{}{}
+		for _, lrel := range layer.Relationships {
+			if p, ok := lrel.Peer.(*Package); ok {
+				names[p.Name] = struct{}{}
+			}
+		}
+		layerPackages = append(layerPackages, names)
+	}
+	require.Len(t, layerPackages, 3)
+	require.Empty(t, layerPackages[0])
+	require.Len(t, layerPackages[1], 83)
+	require.Len(t, layerPackages[2], 4)
+	for _, name := range []string{"ca-certificates", "libssl1.1", "netbase", "openssl"} {
+		require.Contains(t, layerPackages[2], name)
+	}
+}
+
+func TestGoPackagesToSPDXWarnings(t *testing.T) {
+	goPackages := []*GoPackage{
+		// Import paths without a hostname cannot be converted
+		{ImportPath: "nohostname/pkg", Revision: "v1.0.0"},
+		{ImportPath: "github.com/example/pkg", Revision: "v1.2.0"},
+	}
+
+	// Without the option, warnings are only logged
+	impl := spdxDefaultImplementation{}
+	packages, dropped := impl.goPackagesToSPDX(&Options{}, goPackages)
+	require.Len(t, packages, 1)
+	require.Empty(t, impl.Warnings())
+
+	// The packages left out are returned as errors
+	require.Len(t, dropped, 1)
+	depErr := &DependencyError{}
+	require.ErrorAs(t, dropped[0], &depErr)
+	require.Equal(t, "nohostname/pkg", depErr.Dependency)
+	require.Error(t, errors.Unwrap(dropped[0]))
+	require.Contains(t, dropped[0].Error(), "nohostname/pkg: ")
+
+	impl = spdxDefaultImplementation{}
+	packages, dropped = impl.goPackagesToSPDX(&Options{CollectWarnings: true}, goPackages)
+	require.Len(t, packages, 1)
+	require.Len(t, dropped, 1)
+	require.Equal(t, "github.com/example/pkg", packages[0].Name)
+	warnings := impl.Warnings()
+	require.Len(t, warnings, 1)
+	require.Equal(t, "nohostname/pkg", warnings[0].Element)
+	require.Contains(t, warnings[0].Message, "converting go dependency to spdx package")
+	require.Contains(t, warnings[0].String(), "nohostname/pkg: ")
+}
+
+func TestGoPackagesToSPDXOrder(t *testing.T) {
+	goPackages := []*GoPackage{}
+	expectedNames, expectedDropped := []string{}, []string{}
+	for i := 0; i < 100; i++ {
+		if i%7 == 0 {
+			importPath := fmt.Sprintf("nohostname/pkg%d", i)
+			goPackages = append(goPackages, &GoPackage{ImportPath: importPath, Revision: "v1.0.0"})
+			expectedDropped = append(expectedDropped, importPath)
+			continue
+		}
+		importPath := fmt.Sprintf("github.com/example/pkg%d", i)
+		goPackages = append(goPackages, &GoPackage{ImportPath: importPath, Revision: "v1.0.0"})
+		expectedNames = append(expectedNames, importPath)
+	}
+
+	// The packages are converted in parallel but keep their order
+	for _, opts := range []*Options{{}, {MaxConcurrency: 3}} {
+		impl := spdxDefaultImplementation{}
+		packages, dropped := impl.goPackagesToSPDX(opts, goPackages)
+		names := []string{}
+		for _, p := range packages {
+			names = append(names, p.Name)
+		}
+		require.Equal(t, expectedNames, names)
+		droppedNames := []string{}
+		for _, err := range dropped {
+			depErr := &DependencyError{}
+			require.ErrorAs(t, err, &depErr)
+			droppedNames = append(droppedNames, depErr.Dependency)
+		}
+		require.Equal(t, expectedDropped, droppedNames)
+	}
+
+	// Modules without packages have no dependencies
+	impl := spdxDefaultImplementation{}
+	packages, dropped := impl.goPackagesToSPDX(&Options{}, nil)
+	require.Empty(t, packages)
+	require.Empty(t, dropped)
+
+	require.Equal(t, defaultGoModuleWorkers, (&GoModuleOptions{}).workers())
+	require.Equal(t, 3, (&GoModuleOptions{Workers: 3}).workers())
+}
+
+// memoryBlobSource is a content store keeping the blobs in memory
+type memoryBlobSource map[v1.Hash][]byte
+
+func (ms memoryBlobSource) Blob(digest v1.Hash) (io.ReadCloser, error) {
+	data, ok := ms[digest]
+	if !ok {
+		return nil, fmt.Errorf("blob %s not found", digest)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (ms memoryBlobSource) add(t *testing.T, data []byte) v1.Descriptor {
+	digest, size, err := v1.SHA256(bytes.NewReader(data))
+	require.NoError(t, err)
+	ms[digest] = data
+	return v1.Descriptor{Digest: digest, Size: size}
+}
+
+// writeTestContentStore stores an image with the specified layers in
+// a memory content store and returns the digest of its manifest
+func writeTestContentStore(t *testing.T, store memoryBlobSource, layerFiles ...string) v1.Hash {
+	manifest := v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.oci.image.manifest.v1+json",
+	}
+	config := v1.ConfigFile{Architecture: "amd64", OS: "linux"}
+	for i, lf := range layerFiles {
+		data, err := os.ReadFile(lf)
+		require.NoError(t, err)
+		layer := store.add(t, data)
+		layer.MediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+		manifest.Layers = append(manifest.Layers, layer)
+		config.History = append(config.History, v1.History{CreatedBy: fmt.Sprintf("step %d", i)})
+	}
+	configData, err := json.Marshal(config)
+	require.NoError(t, err)
+	manifest.Config = store.add(t, configData)
+	manifest.Config.MediaType = "application/vnd.oci.image.config.v1+json"
+	manifestData, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	return store.add(t, manifestData).Digest
+}
+
+// panickingBlobSource is a content store which panics when reading a blob
+type panickingBlobSource struct {
+	memoryBlobSource
+	panicDigest v1.Hash
+}
+
+func (ps panickingBlobSource) Blob(digest v1.Hash) (io.ReadCloser, error) {
+	if digest == ps.panicDigest {
+		panic("reading blob " + digest.String())
+	}
+	return ps.memoryBlobSource.Blob(digest)
+}
+
+func TestLinkImageVariant(t *testing.T) {
+	for _, tc := range []struct {
+		opts           *Options
+		variantType    RelationshipType
+		variantComment string
+		indexType      RelationshipType
+		indexComment   string
+	}{
+		{nil, CONTAINS, "Container image lager", VARIANT_OF, "Image index"},
+		{
+			&Options{
+				ImageVariantRelationship: &RelationshipTemplate{Comment: "Platform build"},
+				ImageIndexRelationship:   &RelationshipTemplate{Type: DESCENDANT_OF, Comment: "Built from index"},
+			},
+			CONTAINS, "Platform build", DESCENDANT_OF, "Built from index",
+		},
+	} {
+		index := &Package{Entity: Entity{Name: "index"}}
+		variant := &Package{Entity: Entity{Name: "variant"}}
+		linkImageVariant(tc.opts, index, variant)
+
+		require.Len(t, index.Relationships, 1)
+		require.Equal(t, tc.variantType, index.Relationships[0].Type)
+		require.Equal(t, tc.variantComment, index.Relationships[0].Comment)
+		require.True(t, index.Relationships[0].FullRender)
+		require.Equal(t, variant, index.Relationships[0].Peer)
+
+		require.Len(t, variant.Relationships, 1)
+		require.Equal(t, tc.indexType, variant.Relationships[0].Type)
+		require.Equal(t, tc.indexComment, variant.Relationships[0].Comment)
+		require.Equal(t, index, variant.Relationships[0].Peer)
+	}
+}
+
+func TestImagePackageRelationshipTemplates(t *testing.T) {
+	store := memoryBlobSource{}
+	digest := writeTestContentStore(t, store,
+		"../osinfo/testdata/link-with-no-dots.tar.gz",
+		"../osinfo/testdata/dpkg-layer1.tar.gz",
+	)
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromContentStore(&Options{
+		ScanImages:               true,
+		ImageLayerRelationship:   &RelationshipTemplate{Comment: "Build layer"},
+		LayerPackageRelationship: &RelationshipTemplate{Type: OTHER, Comment: "Installed with dpkg"},
+	}, store, digest.String())
+	require.NoError(t, err)
+
+	require.Len(t, pkg.Relationships, 2)
+	for _, rel := range pkg.Relationships {
+		require.Equal(t, CONTAINS, rel.Type)
+		require.Equal(t, "Build layer", rel.Comment)
+	}
+	layer, ok := pkg.Relationships[1].Peer.(*Package)
+	require.True(t, ok)
+
+	osPackages := 0
+	for _, rel := range layer.Relationships {
+		if _, ok := rel.Peer.(*Package); !ok {
+			require.Equal(t, CONTAINS, rel.Type)
+			continue
+		}
+		require.Equal(t, OTHER, rel.Type)
+		require.Equal(t, "Installed with dpkg", rel.Comment)
+		osPackages++
+	}
+	require.Equal(t, 83, osPackages)
+}
+
+func TestPackageFromDirectorySkipEmptyFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test\n"), os.FileMode(0o644)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".keep"), []byte{}, os.FileMode(0o644)))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "empty", "nested"), os.FileMode(0o755)))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "ignored"), os.FileMode(0o755)))
+	t.Setenv(sourceDateEpochEnv, "1672531200")
+
+	impl := spdxDefaultImplementation{}
+	for _, tc := range []struct{ skip, annotate bool }{{false, false}, {true, false}, {false, true}} {
+		pkg, err := impl.PackageFromDirectory(&Options{
+			SkipEmptyFiles: tc.skip, AnnotateEmptyFiles: tc.annotate, Reproducible: true,
+			IgnorePatterns: []string{"ignored/"},
+		}, dir)
+		require.NoError(t, err)
+
+		names := map[string]*File{}
+		for _, f := range pkg.Files() {
+			names[f.FileName] = f
+		}
+		require.Contains(t, names, "README.md")
+		require.Empty(t, names["README.md"].Annotations)
+		if tc.skip {
+			require.Len(t, names, 1)
+			continue
+		}
+		require.Len(t, names, 2)
+		require.Contains(t, names, ".keep")
+		if !tc.annotate {
+			require.Empty(t, names[".keep"].Annotations)
+			require.Empty(t, pkg.Annotations)
+			continue
+		}
+
+		// Zero-byte files and empty directories are flagged when asked,
+		// dated at the reproducible time
+		require.Len(t, names[".keep"].Annotations, 1)
+		require.Equal(t, emptyFileAnnotation, names[".keep"].Annotations[0].Comment)
+		require.Equal(t, "2023-01-01T00:00:00Z", names[".keep"].Annotations[0].Date)
+		comments := []string{}
+		for _, a := range pkg.Annotations {
+			comments = append(comments, a.Comment)
+			require.Equal(t, "2023-01-01T00:00:00Z", a.Date)
+		}
+		require.Equal(t, []string{emptyDirectoryAnnotation + "empty/nested"}, comments)
+	}
+}
+
+func TestPackageFromFilesystemImage(t *testing.T) {
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromFilesystemImage(
+		&Options{ScanImages: true}, "../fsimage/testdata/rootfs.squashfs",
+	)
+	require.NoError(t, err)
+	require.Equal(t, "rootfs.squashfs", pkg.Name)
+	require.Equal(t, "rootfs.squashfs", pkg.FileName)
+
+	files := map[string]struct{}{}
+	for _, f := range pkg.Files() {
+		files[f.FileName] = struct{}{}
+	}
+	require.Contains(t, files, "usr/bin/hello")
+	require.Contains(t, files, "var/lib/dpkg/status")
+
+	osPackages := map[string]string{}
+	for _, rel := range pkg.Relationships {
+		if p, ok := rel.Peer.(*Package); ok {
+			osPackages[p.Name] = p.Version
+		}
+	}
+	require.Contains(t, osPackages, "base-files")
+	require.Equal(t, "12.4", osPackages["base-files"])
+}
+
+func TestPackageFromArchiveFilesystemImage(t *testing.T) {
+	squashfs, err := os.ReadFile("../fsimage/testdata/rootfs.squashfs")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "disk.img"), squashfs, os.FileMode(0o644)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.img"), []byte("not an image\n"), os.FileMode(0o644)))
+
+	// Images are recognized by their superblock, not their extension
+	sut := &SPDX{impl: &spdxDefaultImplementation{}, options: &Options{}}
+	pkg, err := sut.PackageFromArchive(filepath.Join(dir, "disk.img"))
+	require.NoError(t, err)
+	require.Equal(t, "disk.img", pkg.Name)
+	require.NotEmpty(t, pkg.Files())
+
+	_, err = sut.PackageFromArchive(filepath.Join(dir, "notes.img"))
+	require.ErrorContains(t, err, "only tar and zip archives and filesystem images are supported")
+}
+
+func TestImagePlatform(t *testing.T) {
+	log, hook := logtest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+	opts := &Options{Logger: log}
+
+	// Config fetch fails: the platform is empty and the reason logged,
+	// the warning is left to the description of the image
+	im := &fake.FakeImage{}
+	im.ConfigFileReturns(nil, errors.New("blob unknown"))
+	arch, osid := imagePlatform(opts, "registry.example.com/test/image:v1.0.0", im)
+	require.Empty(t, arch)
+	require.Empty(t, osid)
+	require.NotNil(t, hook.LastEntry())
+	require.Equal(t, logrus.DebugLevel, hook.LastEntry().Level)
+	require.Contains(t, hook.LastEntry().Message, "Unable to fetch the config")
+
+	// Config without platform data
+	hook.Reset()
+	im.ConfigFileReturns(&v1.ConfigFile{}, nil)
+	arch, osid = imagePlatform(opts, "registry.example.com/test/image:v1.0.0", im)
+	require.Empty(t, arch)
+	require.Empty(t, osid)
+	require.NotNil(t, hook.LastEntry())
+	require.Equal(t, logrus.DebugLevel, hook.LastEntry().Level)
+	require.Contains(t, hook.LastEntry().Message, "does not define its platform")
+
+	// Config with platform
+	hook.Reset()
+	im.ConfigFileReturns(&v1.ConfigFile{Architecture: "arm64", OS: "linux"}, nil)
+	arch, osid = imagePlatform(opts, "registry.example.com/test/image:v1.0.0", im)
+	require.Equal(t, "arm64", arch)
+	require.Equal(t, "linux", osid)
+	require.Nil(t, hook.LastEntry())
+}
+
+func TestAnnotateLicenseVersions(t *testing.T) {
+	// Without license data, the reader uses the embedded license list
+	spdx := &SPDX{impl: &spdxDefaultImplementation{}, options: &Options{}}
+	doc := NewDocument()
+	require.NoError(t, spdx.AnnotateLicenseVersions(doc))
+	require.Len(t, doc.Annotations, 2)
+
+	comments := []string{}
+	for _, a := range doc.Annotations {
+		comments = append(comments, a.Comment)
+	}
+	require.Contains(t, comments, licenseListAnnotation+license.EmbeddedListVersion)
+	require.Contains(t, comments, licenseClassifierAnnotation+license.ClassifierVersion())
+	require.NotEmpty(t, license.ClassifierVersion())
+
+	pkg := NewPackage()
+	pkg.Name = "root"
+	pkg.BuildID("root")
+	require.NoError(t, doc.AddPackage(pkg))
+	out, err := doc.Render()
+	require.NoError(t, err)
+	for _, comment := range comments {
+		require.Contains(t, out, fmt.Sprintf("SPDXREF: %s\nAnnotationComment: <text>%s</text>\n", doc.ID, comment))
+	}
+}
+
+// containedPackages returns the packages related to pkg with CONTAINS
+func containedPackages(pkg *Package) []*Package {
+	ret := []*Package{}
+	for _, rel := range pkg.Relationships {
+		if sub, ok := rel.Peer.(*Package); ok && rel.Type == CONTAINS {
+			ret = append(ret, sub)
+		}
+	}
+	return ret
+}
+
+// writeTestOCILayoutTarball writes img to an OCI layout, tagged with ref
+// when it is not empty, and returns the path of a tarball of the layout
+func writeTestOCILayoutTarball(t *testing.T, img v1.Image, ref string) string {
+	dir := t.TempDir()
+	lp, err := layout.Write(dir, empty.Index)
+	require.NoError(t, err)
+	opts := []layout.Option{}
+	if ref != "" {
+		opts = append(opts, layout.WithAnnotations(map[string]string{ociRefNameAnnotation: ref}))
+	}
+	require.NoError(t, lp.AppendImage(img, opts...))
+
+	tarPath := filepath.Join(t.TempDir(), "oci.tar")
+	f, err := os.Create(tarPath)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: filepath.ToSlash(rel), Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+		}))
+		_, err = tw.Write(data)
+		return err
+	}))
+	require.NoError(t, tw.Close())
+	return tarPath
+}
+
+func TestGetImageReferencesImplicitTag(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	repo := strings.TrimPrefix(server.URL, "http://") + "/test/image"
+	ref, err := name.ParseReference(repo + ":latest")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	// A bare image name resolves the default tag, pinned to its digest
+	references, err := getImageReferences(context.Background(), nil, repo)
+	require.NoError(t, err)
+	require.Equal(t, "latest", references.Tag)
+	require.True(t, references.ImplicitTag)
+	require.Equal(t, repo+"@"+digest.String(), references.Digest)
+
+	references, err = getImageReferences(context.Background(), nil, repo+":latest")
+	require.NoError(t, err)
+	require.Equal(t, "latest", references.Tag)
+	require.False(t, references.ImplicitTag)
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.ImageRefToPackage(context.Background(), repo, &Options{})
+	require.NoError(t, err)
+	comments := []string{}
+	for _, a := range pkg.Annotations {
+		comments = append(comments, a.Comment)
+	}
+	require.Contains(t, comments, imageTagAnnotation+"latest")
+	require.Contains(t, comments, implicitTagAnnotation+"latest")
+	require.Contains(t, pkg.Purl().ToString(), "tag=latest")
+}
+
+func TestPackageFromDirectories(t *testing.T) {
+	list, err := license.EmbeddedLicenseList()
+	require.NoError(t, err)
+	dirs := []string{}
+	for _, tc := range []struct {
+		license string
+		ignore  string
+	}{
+		{"MIT", "*.log\n"},
+		{"Apache-2.0", "build/\n"},
+	} {
+		// Both directories have the same base name
+		dir := filepath.Join(t.TempDir(), "src")
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "build"), os.FileMode(0o755)))
+		for name, content := range map[string]string{
+			"LICENSE":        list.Licenses[tc.license].LicenseText,
+			".gitignore":     tc.ignore,
+			"main.go":        "package main\n",
+			"debug.log":      "log\n",
+			"build/main.out": "binary\n",
+		} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+		}
+		dirs = append(dirs, dir)
+	}
+
+	sut := &SPDX{impl: &spdxDefaultImplementation{}, options: &Options{}}
+	doc, err := sut.PackageFromDirectories(dirs)
+	require.NoError(t, err)
+	require.Len(t, doc.Packages, 2)
+	require.Equal(t, "src, src", doc.Name)
+
+	// Each directory applies its own license and ignore patterns
+	found := map[string][]string{}
+	for _, pkg := range doc.Packages {
+		require.Equal(t, "src", pkg.Name)
+		files := []string{}
+		for _, f := range pkg.Files() {
+			files = append(files, f.Name)
+		}
+		sort.Strings(files)
+		found[pkg.LicenseConcluded] = files
+	}
+	require.Equal(t, map[string][]string{
+		"MIT":        {".gitignore", "LICENSE", "build/main.out", "main.go"},
+		"Apache-2.0": {".gitignore", "LICENSE", "debug.log", "main.go"},
+	}, found)
+
+	_, err = sut.PackageFromDirectories(nil)
+	require.Error(t, err)
+}
+
+func TestPackageFromDirectoryScanExtensions(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":               "package main\n",
+		"api/service.proto":     "syntax = \"proto3\";\n",
+		"api/SERVICE.PROTO":     "syntax = \"proto3\";\n",
+		"api/generated.pb.go":   "package api\n",
+		"vendor/lib/lib.go":     "package lib\n",
+		"README.md":             "# readme\n",
+		"Makefile":              "all:\n",
+		"scripts/build.sh":      "#!/bin/sh\n",
+		"testdata/archive.go.1": "not go\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+	}
+
+	scannedFiles := func(opts *Options) []string {
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromDirectory(opts, dir)
+		require.NoError(t, err)
+		files := []string{}
+		for _, f := range pkg.Files() {
+			files = append(files, f.Name)
+		}
+		sort.Strings(files)
+		return files
+	}
+
+	// Extensions match with or without the dot and ignoring case
+	require.Equal(t, []string{
+		"api/SERVICE.PROTO", "api/generated.pb.go", "api/service.proto", "main.go", "vendor/lib/lib.go",
+	}, scannedFiles(&Options{ScanExtensions: []string{".go", "proto"}}))
+
+	// The ignore patterns still apply to the files with the extensions
+	require.Equal(t, []string{
+		"api/SERVICE.PROTO", "api/generated.pb.go", "api/service.proto", "main.go",
+	}, scannedFiles(&Options{ScanExtensions: []string{".go", "proto"}, IgnorePatterns: []string{"vendor/"}}))
+
+	// Multi part extensions are matched as suffixes of the names
+	require.Equal(t, []string{"api/generated.pb.go"}, scannedFiles(&Options{ScanExtensions: []string{".pb.go"}}))
+
+	// Without extensions all the files are scanned
+	require.Len(t, scannedFiles(&Options{}), 9)
+
+	// Scanning fails when no file has the extensions
+	impl := spdxDefaultImplementation{}
+	_, err := impl.PackageFromDirectory(&Options{ScanExtensions: []string{".rs"}}, dir)
+	require.Error(t, err)
+}
+
+func TestPackageFromDirectoryExcludeFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":           "package main\n",
+		"docs/diagram.PNG":  "PNG",
+		"media/intro.mp4":   strings.Repeat("0", 4096),
+		"testdata/blob.bin": strings.Repeat("0", 2048),
+		"empty.txt":         "",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+	}
+
+	scannedFiles := func(opts *Options) []string {
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromDirectory(opts, dir)
+		require.NoError(t, err)
+		files := []string{}
+		for _, f := range pkg.Files() {
+			files = append(files, f.Name)
+		}
+		sort.Strings(files)
+		return files
+	}
+
+	// A zero size does not limit the files
+	require.Len(t, scannedFiles(&Options{}), 5)
+
+	// Files over the limit are skipped
+	require.Equal(t, []string{
+		"docs/diagram.PNG", "empty.txt", "main.go", "testdata/blob.bin",
+	}, scannedFiles(&Options{MaxFileSize: 2048}))
+
+	// Extensions are excluded with or without the dot and ignoring case
+	require.Equal(t, []string{
+		"empty.txt", "main.go", "testdata/blob.bin",
+	}, scannedFiles(&Options{ExcludeExtensions: []string{".png", "MP4"}}))
+
+	// The filters combine with the other ones
+	require.Equal(t, []string{"main.go"}, scannedFiles(&Options{
+		ExcludeExtensions: []string{"png"}, MaxFileSize: 1024, SkipEmptyFiles: true,
+	}))
+	require.Equal(t, []string{"main.go"}, scannedFiles(&Options{
+		ScanExtensions: []string{".go", ".png"}, ExcludeExtensions: []string{".png"},
+	}))
+}
+
+func TestPackageFromDirectoryContinueOnFileError(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), os.FileMode(0o644)))
+	}
+	// Sockets are listed as files but opening them always fails
+	l, err := net.Listen("unix", filepath.Join(dir, "server.sock"))
+	require.NoError(t, err)
+	defer l.Close()
+
+	// By default the first file that fails stops the scan
+	impl := spdxDefaultImplementation{}
+	_, err = impl.PackageFromDirectory(&Options{}, dir)
+	require.Error(t, err)
+	fe := &FileError{}
+	require.True(t, errors.As(err, &fe))
+	require.Equal(t, "server.sock", fe.Path)
+
+	// Continuing leaves the file out of the package with a warning
+	impl = spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{ContinueOnFileError: true, CollectWarnings: true}, dir)
+	require.NoError(t, err)
+	files := []string{}
+	for _, f := range pkg.Files() {
+		files = append(files, f.Name)
+	}
+	sort.Strings(files)
+	require.Equal(t, []string{"a.txt", "b.txt"}, files)
+	skipped := []string{}
+	for _, w := range impl.Warnings() {
+		if strings.HasPrefix(w.Message, "Skipping file") {
+			skipped = append(skipped, w.Element)
+		}
+	}
+	require.Equal(t, []string{"server.sock"}, skipped)
+}
+
+func TestPackageFromDirectoryGoModuleName(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "checkout")
+	require.NoError(t, os.MkdirAll(dir, os.FileMode(0o755)))
+	for name, content := range map[string]string{
+		GoModFileName: "module example.com/org/project\n\ngo 1.20\n",
+		"main.go":     "package main\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+	}
+
+	sut := &SPDX{impl: &spdxDefaultImplementation{}, options: &Options{}}
+	pkg, err := sut.PackageFromDirectory(dir)
+	require.NoError(t, err)
+	require.Equal(t, "example.com/org/project", pkg.Name)
+	require.Len(t, pkg.Annotations, 1)
+	require.Equal(t, directoryNameAnnotation+"checkout", pkg.Annotations[0].Comment)
+
+	// Without a go.mod the package keeps the directory name
+	require.NoError(t, os.Remove(filepath.Join(dir, GoModFileName)))
+	pkg, err = sut.PackageFromDirectory(dir)
+	require.NoError(t, err)
+	require.Equal(t, "checkout", pkg.Name)
+	require.Empty(t, pkg.Annotations)
+}
+
+func TestOSPackageFromDBEntryInstallReason(t *testing.T) {
+	entry := &osinfo.PackageDBEntry{
+		Package: "libcurl4", Version: "7.88.1-10", Architecture: "amd64",
+		Type: "deb", Namespace: "debian", InstallReason: osinfo.InstallReasonDependency,
+	}
+	pkg := osPackageFromDBEntry(entry)
+	require.Len(t, pkg.Annotations, 1)
+	require.Equal(t, installReasonAnnotation+osinfo.InstallReasonDependency, pkg.Annotations[0].Comment)
+
+	// Packages with an unknown reason are not annotated
+	entry.InstallReason = ""
+	require.Empty(t, osPackageFromDBEntry(entry).Annotations)
+}
+
+func TestGetDirectoryLicenseExpression(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "LICENSE"), []byte("license text\n"), os.FileMode(0o644)))
+
+	fake := &licensefakes.FakeReaderImplementation{}
+	fake.LicenseFromLabelCalls(func(label string) *license.License {
+		if label == "MIT" || label == "Apache-2.0" {
+			return &license.License{LicenseID: label}
+		}
+		return nil
+	})
+	reader := &license.Reader{}
+	require.NoError(t, reader.SetImplementation(fake))
+
+	for _, tc := range []struct {
+		licenseID string
+		valid     bool
+		warning   string
+	}{
+		{"MIT", true, ""},
+		{"Apache-2.0 OR MIT", true, ""},
+		{"LicenseRef-custom", true, ""},
+		// Unknown licenses are reported but kept
+		{"Apache-2.0 AND Made-Up-1.0", true, "not in the SPDX license list: Made-Up-1.0"},
+		// Malformed expressions are discarded
+		{"MIT OR", false, "Discarding the license"},
+		{"GPL 2", false, "Discarding the license"},
+	} {
+		fake.ClassifyLicenseFilesReturns(
+			[]*license.ClassifyResult{{License: &license.License{LicenseID: tc.licenseID}}}, nil, nil,
+		)
+		impl := &spdxDefaultImplementation{}
+		lic, err := impl.GetDirectoryLicense(reader, dir, &Options{CollectWarnings: true})
+		require.NoError(t, err, tc.licenseID)
+		if tc.valid {
+			require.NotNil(t, lic, tc.licenseID)
+			require.Equal(t, tc.licenseID, lic.LicenseID)
+		} else {
+			require.Nil(t, lic, tc.licenseID)
+		}
+		if tc.warning == "" {
+			require.Empty(t, impl.Warnings(), tc.licenseID)
+		} else {
+			require.Len(t, impl.Warnings(), 1, tc.licenseID)
+			require.Contains(t, impl.Warnings()[0].Message, tc.warning)
+		}
+	}
+}
+
+func TestPackageFromDirectoryVerificationCode(t *testing.T) {
+	dir := t.TempDir()
+	shas := []string{}
+	for name, content := range map[string]string{
+		"main.go":       "package main\n",
+		"docs/index.md": "# Docs\n",
+		"bom.spdx":      "SPDXVersion: SPDX-2.3\n",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), os.FileMode(0o755)))
+		require.NoError(t, os.WriteFile(path, []byte(content), os.FileMode(0o644)))
+		if name != "bom.spdx" {
+			sum, err := hash.SHA1ForFile(path)
+			require.NoError(t, err)
+			shas = append(shas, sum)
+		}
+	}
+	sort.Strings(shas)
+	expected := fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(shas, ""))))
+
+	// The code is set when the package is generated, without the
+	// files excluded in the options
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{
+		SkipLicenseScan: true, VerificationExcludedFiles: []string{"./bom.spdx"},
+	}, dir)
+	require.NoError(t, err)
+	require.Len(t, pkg.Files(), 3)
+	require.Equal(t, expected, pkg.VerificationCode)
+	require.Equal(t, []string{"bom.spdx"}, pkg.VerificationCodeExcludedFiles)
+	pkg.BuildID("test")
+	out, err := pkg.Render()
+	require.NoError(t, err)
+	require.Contains(t, out, "PackageVerificationCode: "+expected+" (excludes: bom.spdx)\n")
+
+	// Parsing the document keeps the excluded files
+	doc := NewDocument()
+	require.NoError(t, doc.AddPackage(pkg))
+	docPath := filepath.Join(t.TempDir(), "doc.spdx")
+	require.NoError(t, doc.Write(docPath))
+	parsed, err := OpenDoc(docPath)
+	require.NoError(t, err)
+	require.Equal(t, expected, parsed.Packages[pkg.SPDXID()].VerificationCode)
+	require.Equal(t, []string{"bom.spdx"}, parsed.Packages[pkg.SPDXID()].VerificationCodeExcludedFiles)
+}
+
+func TestVerificationExcludedFilesImagesAndArchives(t *testing.T) {
+	img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{Architecture: "amd64", OS: "linux"})
+	require.NoError(t, err)
+	layer, err := tarball.LayerFromFile("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	img, err = mutate.AppendLayers(img, layer)
+	require.NoError(t, err)
+	tag, err := name.NewTag("registry.example.com/test/image:v1.0.0")
+	require.NoError(t, err)
+	imagePath := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, tarball.WriteToFile(imagePath, tag, img))
+
+	sut := NewSPDX()
+	sut.Options().SkipLicenseScan = true
+	sut.Options().AnalyzeLayers = false
+	sut.Options().ScanImages = false
+	sut.Options().VerificationExcludedFiles = []string{"usr/lib/os-release"}
+
+	// The exclusions apply to the files of the image layers, whether
+	// they are extracted or streamed
+	for _, stream := range []bool{false, true} {
+		sut.Options().AddTarFiles = !stream
+		sut.Options().StreamLayers = stream
+		pkg, err := sut.PackageFromImageTarball(imagePath)
+		require.NoError(t, err)
+		layers := 0
+		for _, rel := range pkg.Relationships {
+			if layerPkg, ok := rel.Peer.(*Package); ok && len(layerPkg.Files()) > 0 {
+				layers++
+				require.Equal(t, []string{"usr/lib/os-release"}, layerPkg.VerificationCodeExcludedFiles)
+			}
+		}
+		require.Equal(t, 1, layers, "stream: %v", stream)
+	}
+
+	// And to the files of archives
+	pkg, err := sut.PackageFromArchive("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	require.Equal(t, []string{"usr/lib/os-release"}, pkg.VerificationCodeExcludedFiles)
+	withExclusion := pkg.VerificationCode
+	sut.Options().VerificationExcludedFiles = nil
+	pkg, err = sut.PackageFromArchive("../osinfo/testdata/link-with-no-dots.tar.gz")
+	require.NoError(t, err)
+	require.Empty(t, pkg.VerificationCodeExcludedFiles)
+	require.NotEqual(t, withExclusion, pkg.VerificationCode)
+}
+
+func TestPackageFromDirectorySelfFileName(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), os.FileMode(0o644)))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sbom"), os.FileMode(0o755)))
+	sbomPath := filepath.Join(dir, "sbom", "bom.spdx")
+	require.NoError(t, os.WriteFile(sbomPath, []byte("SPDXVersion: SPDX-2.3\n"), os.FileMode(0o644)))
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{SkipLicenseScan: true}, dir)
+	require.NoError(t, err)
+	require.Len(t, pkg.Files(), 2)
+	withSBOM := pkg.VerificationCode
+
+	pkg, err = impl.PackageFromDirectory(&Options{SkipLicenseScan: true, SelfFileName: sbomPath}, dir)
+	require.NoError(t, err)
+	require.Len(t, pkg.Files(), 1)
+	require.Equal(t, "main.go", pkg.Files()[0].Name)
+	require.NotEqual(t, withSBOM, pkg.VerificationCode)
+	require.Empty(t, pkg.VerificationCodeExcludedFiles)
+
+	// The verification code does not change when the SBOM is rewritten
+	code := pkg.VerificationCode
+	require.NoError(t, os.WriteFile(sbomPath, []byte("SPDXVersion: SPDX-2.2\n"), os.FileMode(0o644)))
+	pkg, err = impl.PackageFromDirectory(&Options{SkipLicenseScan: true, SelfFileName: sbomPath}, dir)
+	require.NoError(t, err)
+	require.Equal(t, code, pkg.VerificationCode)
+
+	// SBOMs written out of the directory change nothing
+	pkg, err = impl.PackageFromDirectory(&Options{
+		SkipLicenseScan: true, SelfFileName: filepath.Join(t.TempDir(), "bom.spdx"),
+	}, dir)
+	require.NoError(t, err)
+	require.Len(t, pkg.Files(), 2)
+}
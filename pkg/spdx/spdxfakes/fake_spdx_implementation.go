@@ -18,19 +18,22 @@ limitations under the License.
 package spdxfakes
 
 import (
+	"context"
 	"sync"
 
 	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	"sigs.k8s.io/bom/pkg/license"
+	"sigs.k8s.io/bom/pkg/osinfo"
 	"sigs.k8s.io/bom/pkg/spdx"
 )
 
 type FakeSpdxImplementation struct {
-	AnalyzeImageLayerStub        func(string, *spdx.Package) error
+	AnalyzeImageLayerStub        func(string, *spdx.Package, *spdx.Options) error
 	analyzeImageLayerMutex       sync.RWMutex
 	analyzeImageLayerArgsForCall []struct {
 		arg1 string
 		arg2 *spdx.Package
+		arg3 *spdx.Options
 	}
 	analyzeImageLayerReturns struct {
 		result1 error
@@ -38,11 +41,12 @@ type FakeSpdxImplementation struct {
 	analyzeImageLayerReturnsOnCall map[int]struct {
 		result1 error
 	}
-	ApplyIgnorePatternsStub        func([]string, []gitignore.Pattern) []string
+	ApplyIgnorePatternsStub        func([]string, []gitignore.Pattern, bool) []string
 	applyIgnorePatternsMutex       sync.RWMutex
 	applyIgnorePatternsArgsForCall []struct {
 		arg1 []string
 		arg2 []gitignore.Pattern
+		arg3 bool
 	}
 	applyIgnorePatternsReturns struct {
 		result1 []string
@@ -50,10 +54,25 @@ type FakeSpdxImplementation struct {
 	applyIgnorePatternsReturnsOnCall map[int]struct {
 		result1 []string
 	}
-	ExtractTarballTmpStub        func(string) (string, error)
+	ExtractLayersTmpStub        func(*spdx.Options, []string) (string, error)
+	extractLayersTmpMutex       sync.RWMutex
+	extractLayersTmpArgsForCall []struct {
+		arg1 *spdx.Options
+		arg2 []string
+	}
+	extractLayersTmpReturns struct {
+		result1 string
+		result2 error
+	}
+	extractLayersTmpReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	ExtractTarballTmpStub        func(*spdx.Options, string) (string, error)
 	extractTarballTmpMutex       sync.RWMutex
 	extractTarballTmpArgsForCall []struct {
-		arg1 string
+		arg1 *spdx.Options
+		arg2 string
 	}
 	extractTarballTmpReturns struct {
 		result1 string
@@ -63,6 +82,19 @@ type FakeSpdxImplementation struct {
 		result1 string
 		result2 error
 	}
+	ExtractZipTmpStub        func(string) (string, error)
+	extractZipTmpMutex       sync.RWMutex
+	extractZipTmpArgsForCall []struct {
+		arg1 string
+	}
+	extractZipTmpReturns struct {
+		result1 string
+		result2 error
+	}
+	extractZipTmpReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
 	GetDirectoryLicenseStub        func(*license.Reader, string, *spdx.Options) (*license.License, error)
 	getDirectoryLicenseMutex       sync.RWMutex
 	getDirectoryLicenseArgsForCall []struct {
@@ -78,10 +110,12 @@ type FakeSpdxImplementation struct {
 		result1 *license.License
 		result2 error
 	}
-	GetDirectoryTreeStub        func(string) ([]string, error)
+	GetDirectoryTreeStub        func(string, bool, bool) ([]string, error)
 	getDirectoryTreeMutex       sync.RWMutex
 	getDirectoryTreeArgsForCall []struct {
 		arg1 string
+		arg2 bool
+		arg3 bool
 	}
 	getDirectoryTreeReturns struct {
 		result1 []string
@@ -105,12 +139,57 @@ type FakeSpdxImplementation struct {
 		result1 []*spdx.Package
 		result2 error
 	}
-	IgnorePatternsStub        func(string, []string, bool) ([]gitignore.Pattern, error)
+	GetGoDependenciesWithErrorsStub        func(string, *spdx.Options) ([]*spdx.Package, []error, error)
+	getGoDependenciesWithErrorsMutex       sync.RWMutex
+	getGoDependenciesWithErrorsArgsForCall []struct {
+		arg1 string
+		arg2 *spdx.Options
+	}
+	getGoDependenciesWithErrorsReturns struct {
+		result1 []*spdx.Package
+		result2 []error
+		result3 error
+	}
+	getGoDependenciesWithErrorsReturnsOnCall map[int]struct {
+		result1 []*spdx.Package
+		result2 []error
+		result3 error
+	}
+	GetNPMDependenciesStub        func(string, *spdx.Options) ([]*spdx.Package, error)
+	getNPMDependenciesMutex       sync.RWMutex
+	getNPMDependenciesArgsForCall []struct {
+		arg1 string
+		arg2 *spdx.Options
+	}
+	getNPMDependenciesReturns struct {
+		result1 []*spdx.Package
+		result2 error
+	}
+	getNPMDependenciesReturnsOnCall map[int]struct {
+		result1 []*spdx.Package
+		result2 error
+	}
+	GetPythonDependenciesStub        func(string, *spdx.Options) ([]*spdx.Package, error)
+	getPythonDependenciesMutex       sync.RWMutex
+	getPythonDependenciesArgsForCall []struct {
+		arg1 string
+		arg2 *spdx.Options
+	}
+	getPythonDependenciesReturns struct {
+		result1 []*spdx.Package
+		result2 error
+	}
+	getPythonDependenciesReturnsOnCall map[int]struct {
+		result1 []*spdx.Package
+		result2 error
+	}
+	IgnorePatternsStub        func(string, []string, bool, bool) ([]gitignore.Pattern, error)
 	ignorePatternsMutex       sync.RWMutex
 	ignorePatternsArgsForCall []struct {
 		arg1 string
 		arg2 []string
 		arg3 bool
+		arg4 bool
 	}
 	ignorePatternsReturns struct {
 		result1 []gitignore.Pattern
@@ -120,11 +199,27 @@ type FakeSpdxImplementation struct {
 		result1 []gitignore.Pattern
 		result2 error
 	}
-	ImageRefToPackageStub        func(string, *spdx.Options) (*spdx.Package, error)
+	ImageOSPackagesStub        func(context.Context, string, *spdx.Options) ([]osinfo.PackageDBEntry, error)
+	imageOSPackagesMutex       sync.RWMutex
+	imageOSPackagesArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 *spdx.Options
+	}
+	imageOSPackagesReturns struct {
+		result1 []osinfo.PackageDBEntry
+		result2 error
+	}
+	imageOSPackagesReturnsOnCall map[int]struct {
+		result1 []osinfo.PackageDBEntry
+		result2 error
+	}
+	ImageRefToPackageStub        func(context.Context, string, *spdx.Options) (*spdx.Package, error)
 	imageRefToPackageMutex       sync.RWMutex
 	imageRefToPackageArgsForCall []struct {
-		arg1 string
-		arg2 *spdx.Options
+		arg1 context.Context
+		arg2 string
+		arg3 *spdx.Options
 	}
 	imageRefToPackageReturns struct {
 		result1 *spdx.Package
@@ -147,6 +242,21 @@ type FakeSpdxImplementation struct {
 		result1 *license.Reader
 		result2 error
 	}
+	PackageFromContentStoreStub        func(*spdx.Options, spdx.BlobSource, string) (*spdx.Package, error)
+	packageFromContentStoreMutex       sync.RWMutex
+	packageFromContentStoreArgsForCall []struct {
+		arg1 *spdx.Options
+		arg2 spdx.BlobSource
+		arg3 string
+	}
+	packageFromContentStoreReturns struct {
+		result1 *spdx.Package
+		result2 error
+	}
+	packageFromContentStoreReturnsOnCall map[int]struct {
+		result1 *spdx.Package
+		result2 error
+	}
 	PackageFromDirectoryStub        func(*spdx.Options, string) (*spdx.Package, error)
 	packageFromDirectoryMutex       sync.RWMutex
 	packageFromDirectoryArgsForCall []struct {
@@ -161,6 +271,63 @@ type FakeSpdxImplementation struct {
 		result1 *spdx.Package
 		result2 error
 	}
+	PackageFromFileStub        func(*spdx.Options, string) (*spdx.Package, error)
+	packageFromFileMutex       sync.RWMutex
+	packageFromFileArgsForCall []struct {
+		arg1 *spdx.Options
+		arg2 string
+	}
+	packageFromFileReturns struct {
+		result1 *spdx.Package
+		result2 error
+	}
+	packageFromFileReturnsOnCall map[int]struct {
+		result1 *spdx.Package
+		result2 error
+	}
+	PackageFromFilesystemImageStub        func(*spdx.Options, string) (*spdx.Package, error)
+	packageFromFilesystemImageMutex       sync.RWMutex
+	packageFromFilesystemImageArgsForCall []struct {
+		arg1 *spdx.Options
+		arg2 string
+	}
+	packageFromFilesystemImageReturns struct {
+		result1 *spdx.Package
+		result2 error
+	}
+	packageFromFilesystemImageReturnsOnCall map[int]struct {
+		result1 *spdx.Package
+		result2 error
+	}
+	PackageFromGoBinaryStub        func(*spdx.Options, string) (*spdx.Package, error)
+	packageFromGoBinaryMutex       sync.RWMutex
+	packageFromGoBinaryArgsForCall []struct {
+		arg1 *spdx.Options
+		arg2 string
+	}
+	packageFromGoBinaryReturns struct {
+		result1 *spdx.Package
+		result2 error
+	}
+	packageFromGoBinaryReturnsOnCall map[int]struct {
+		result1 *spdx.Package
+		result2 error
+	}
+	PackageFromImageFilesStub        func(*spdx.Options, string, []string) (*spdx.Package, error)
+	packageFromImageFilesMutex       sync.RWMutex
+	packageFromImageFilesArgsForCall []struct {
+		arg1 *spdx.Options
+		arg2 string
+		arg3 []string
+	}
+	packageFromImageFilesReturns struct {
+		result1 *spdx.Package
+		result2 error
+	}
+	packageFromImageFilesReturnsOnCall map[int]struct {
+		result1 *spdx.Package
+		result2 error
+	}
 	PackageFromImageTarballStub        func(*spdx.Options, string) (*spdx.Package, error)
 	packageFromImageTarballMutex       sync.RWMutex
 	packageFromImageTarballArgsForCall []struct {
@@ -190,11 +357,27 @@ type FakeSpdxImplementation struct {
 		result1 *spdx.Package
 		result2 error
 	}
-	PullImagesToArchiveStub        func(string, string) (*spdx.ImageReferenceInfo, error)
+	PackageFromZipStub        func(*spdx.Options, string) (*spdx.Package, error)
+	packageFromZipMutex       sync.RWMutex
+	packageFromZipArgsForCall []struct {
+		arg1 *spdx.Options
+		arg2 string
+	}
+	packageFromZipReturns struct {
+		result1 *spdx.Package
+		result2 error
+	}
+	packageFromZipReturnsOnCall map[int]struct {
+		result1 *spdx.Package
+		result2 error
+	}
+	PullImagesToArchiveStub        func(context.Context, *spdx.Options, string, string) (*spdx.ImageReferenceInfo, error)
 	pullImagesToArchiveMutex       sync.RWMutex
 	pullImagesToArchiveArgsForCall []struct {
-		arg1 string
-		arg2 string
+		arg1 context.Context
+		arg2 *spdx.Options
+		arg3 string
+		arg4 string
 	}
 	pullImagesToArchiveReturns struct {
 		result1 *spdx.ImageReferenceInfo
@@ -217,23 +400,57 @@ type FakeSpdxImplementation struct {
 		result1 *spdx.ArchiveManifest
 		result2 error
 	}
+	ReadArchiveManifestsStub        func(string) ([]spdx.ArchiveManifest, error)
+	readArchiveManifestsMutex       sync.RWMutex
+	readArchiveManifestsArgsForCall []struct {
+		arg1 string
+	}
+	readArchiveManifestsReturns struct {
+		result1 []spdx.ArchiveManifest
+		result2 error
+	}
+	readArchiveManifestsReturnsOnCall map[int]struct {
+		result1 []spdx.ArchiveManifest
+		result2 error
+	}
+	StatsStub        func() spdx.Stats
+	statsMutex       sync.RWMutex
+	statsArgsForCall []struct {
+	}
+	statsReturns struct {
+		result1 spdx.Stats
+	}
+	statsReturnsOnCall map[int]struct {
+		result1 spdx.Stats
+	}
+	WarningsStub        func() []spdx.Warning
+	warningsMutex       sync.RWMutex
+	warningsArgsForCall []struct {
+	}
+	warningsReturns struct {
+		result1 []spdx.Warning
+	}
+	warningsReturnsOnCall map[int]struct {
+		result1 []spdx.Warning
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeSpdxImplementation) AnalyzeImageLayer(arg1 string, arg2 *spdx.Package) error {
+func (fake *FakeSpdxImplementation) AnalyzeImageLayer(arg1 string, arg2 *spdx.Package, arg3 *spdx.Options) error {
 	fake.analyzeImageLayerMutex.Lock()
 	ret, specificReturn := fake.analyzeImageLayerReturnsOnCall[len(fake.analyzeImageLayerArgsForCall)]
 	fake.analyzeImageLayerArgsForCall = append(fake.analyzeImageLayerArgsForCall, struct {
 		arg1 string
 		arg2 *spdx.Package
-	}{arg1, arg2})
+		arg3 *spdx.Options
+	}{arg1, arg2, arg3})
 	stub := fake.AnalyzeImageLayerStub
 	fakeReturns := fake.analyzeImageLayerReturns
-	fake.recordInvocation("AnalyzeImageLayer", []interface{}{arg1, arg2})
+	fake.recordInvocation("AnalyzeImageLayer", []interface{}{arg1, arg2, arg3})
 	fake.analyzeImageLayerMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1
@@ -247,17 +464,17 @@ func (fake *FakeSpdxImplementation) AnalyzeImageLayerCallCount() int {
 	return len(fake.analyzeImageLayerArgsForCall)
 }
 
-func (fake *FakeSpdxImplementation) AnalyzeImageLayerCalls(stub func(string, *spdx.Package) error) {
+func (fake *FakeSpdxImplementation) AnalyzeImageLayerCalls(stub func(string, *spdx.Package, *spdx.Options) error) {
 	fake.analyzeImageLayerMutex.Lock()
 	defer fake.analyzeImageLayerMutex.Unlock()
 	fake.AnalyzeImageLayerStub = stub
 }
 
-func (fake *FakeSpdxImplementation) AnalyzeImageLayerArgsForCall(i int) (string, *spdx.Package) {
+func (fake *FakeSpdxImplementation) AnalyzeImageLayerArgsForCall(i int) (string, *spdx.Package, *spdx.Options) {
 	fake.analyzeImageLayerMutex.RLock()
 	defer fake.analyzeImageLayerMutex.RUnlock()
 	argsForCall := fake.analyzeImageLayerArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeSpdxImplementation) AnalyzeImageLayerReturns(result1 error) {
@@ -283,7 +500,7 @@ func (fake *FakeSpdxImplementation) AnalyzeImageLayerReturnsOnCall(i int, result
 	}{result1}
 }
 
-func (fake *FakeSpdxImplementation) ApplyIgnorePatterns(arg1 []string, arg2 []gitignore.Pattern) []string {
+func (fake *FakeSpdxImplementation) ApplyIgnorePatterns(arg1 []string, arg2 []gitignore.Pattern, arg3 bool) []string {
 	var arg1Copy []string
 	if arg1 != nil {
 		arg1Copy = make([]string, len(arg1))
@@ -299,13 +516,14 @@ func (fake *FakeSpdxImplementation) ApplyIgnorePatterns(arg1 []string, arg2 []gi
 	fake.applyIgnorePatternsArgsForCall = append(fake.applyIgnorePatternsArgsForCall, struct {
 		arg1 []string
 		arg2 []gitignore.Pattern
-	}{arg1Copy, arg2Copy})
+		arg3 bool
+	}{arg1Copy, arg2Copy, arg3})
 	stub := fake.ApplyIgnorePatternsStub
 	fakeReturns := fake.applyIgnorePatternsReturns
-	fake.recordInvocation("ApplyIgnorePatterns", []interface{}{arg1Copy, arg2Copy})
+	fake.recordInvocation("ApplyIgnorePatterns", []interface{}{arg1Copy, arg2Copy, arg3})
 	fake.applyIgnorePatternsMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1
@@ -319,17 +537,17 @@ func (fake *FakeSpdxImplementation) ApplyIgnorePatternsCallCount() int {
 	return len(fake.applyIgnorePatternsArgsForCall)
 }
 
-func (fake *FakeSpdxImplementation) ApplyIgnorePatternsCalls(stub func([]string, []gitignore.Pattern) []string) {
+func (fake *FakeSpdxImplementation) ApplyIgnorePatternsCalls(stub func([]string, []gitignore.Pattern, bool) []string) {
 	fake.applyIgnorePatternsMutex.Lock()
 	defer fake.applyIgnorePatternsMutex.Unlock()
 	fake.ApplyIgnorePatternsStub = stub
 }
 
-func (fake *FakeSpdxImplementation) ApplyIgnorePatternsArgsForCall(i int) ([]string, []gitignore.Pattern) {
+func (fake *FakeSpdxImplementation) ApplyIgnorePatternsArgsForCall(i int) ([]string, []gitignore.Pattern, bool) {
 	fake.applyIgnorePatternsMutex.RLock()
 	defer fake.applyIgnorePatternsMutex.RUnlock()
 	argsForCall := fake.applyIgnorePatternsArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeSpdxImplementation) ApplyIgnorePatternsReturns(result1 []string) {
@@ -355,18 +573,89 @@ func (fake *FakeSpdxImplementation) ApplyIgnorePatternsReturnsOnCall(i int, resu
 	}{result1}
 }
 
-func (fake *FakeSpdxImplementation) ExtractTarballTmp(arg1 string) (string, error) {
+func (fake *FakeSpdxImplementation) ExtractLayersTmp(arg1 *spdx.Options, arg2 []string) (string, error) {
+	var arg2Copy []string
+	if arg2 != nil {
+		arg2Copy = make([]string, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.extractLayersTmpMutex.Lock()
+	ret, specificReturn := fake.extractLayersTmpReturnsOnCall[len(fake.extractLayersTmpArgsForCall)]
+	fake.extractLayersTmpArgsForCall = append(fake.extractLayersTmpArgsForCall, struct {
+		arg1 *spdx.Options
+		arg2 []string
+	}{arg1, arg2Copy})
+	stub := fake.ExtractLayersTmpStub
+	fakeReturns := fake.extractLayersTmpReturns
+	fake.recordInvocation("ExtractLayersTmp", []interface{}{arg1, arg2Copy})
+	fake.extractLayersTmpMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) ExtractLayersTmpCallCount() int {
+	fake.extractLayersTmpMutex.RLock()
+	defer fake.extractLayersTmpMutex.RUnlock()
+	return len(fake.extractLayersTmpArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) ExtractLayersTmpCalls(stub func(*spdx.Options, []string) (string, error)) {
+	fake.extractLayersTmpMutex.Lock()
+	defer fake.extractLayersTmpMutex.Unlock()
+	fake.ExtractLayersTmpStub = stub
+}
+
+func (fake *FakeSpdxImplementation) ExtractLayersTmpArgsForCall(i int) (*spdx.Options, []string) {
+	fake.extractLayersTmpMutex.RLock()
+	defer fake.extractLayersTmpMutex.RUnlock()
+	argsForCall := fake.extractLayersTmpArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSpdxImplementation) ExtractLayersTmpReturns(result1 string, result2 error) {
+	fake.extractLayersTmpMutex.Lock()
+	defer fake.extractLayersTmpMutex.Unlock()
+	fake.ExtractLayersTmpStub = nil
+	fake.extractLayersTmpReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) ExtractLayersTmpReturnsOnCall(i int, result1 string, result2 error) {
+	fake.extractLayersTmpMutex.Lock()
+	defer fake.extractLayersTmpMutex.Unlock()
+	fake.ExtractLayersTmpStub = nil
+	if fake.extractLayersTmpReturnsOnCall == nil {
+		fake.extractLayersTmpReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.extractLayersTmpReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) ExtractTarballTmp(arg1 *spdx.Options, arg2 string) (string, error) {
 	fake.extractTarballTmpMutex.Lock()
 	ret, specificReturn := fake.extractTarballTmpReturnsOnCall[len(fake.extractTarballTmpArgsForCall)]
 	fake.extractTarballTmpArgsForCall = append(fake.extractTarballTmpArgsForCall, struct {
-		arg1 string
-	}{arg1})
+		arg1 *spdx.Options
+		arg2 string
+	}{arg1, arg2})
 	stub := fake.ExtractTarballTmpStub
 	fakeReturns := fake.extractTarballTmpReturns
-	fake.recordInvocation("ExtractTarballTmp", []interface{}{arg1})
+	fake.recordInvocation("ExtractTarballTmp", []interface{}{arg1, arg2})
 	fake.extractTarballTmpMutex.Unlock()
 	if stub != nil {
-		return stub(arg1)
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -380,17 +669,17 @@ func (fake *FakeSpdxImplementation) ExtractTarballTmpCallCount() int {
 	return len(fake.extractTarballTmpArgsForCall)
 }
 
-func (fake *FakeSpdxImplementation) ExtractTarballTmpCalls(stub func(string) (string, error)) {
+func (fake *FakeSpdxImplementation) ExtractTarballTmpCalls(stub func(*spdx.Options, string) (string, error)) {
 	fake.extractTarballTmpMutex.Lock()
 	defer fake.extractTarballTmpMutex.Unlock()
 	fake.ExtractTarballTmpStub = stub
 }
 
-func (fake *FakeSpdxImplementation) ExtractTarballTmpArgsForCall(i int) string {
+func (fake *FakeSpdxImplementation) ExtractTarballTmpArgsForCall(i int) (*spdx.Options, string) {
 	fake.extractTarballTmpMutex.RLock()
 	defer fake.extractTarballTmpMutex.RUnlock()
 	argsForCall := fake.extractTarballTmpArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeSpdxImplementation) ExtractTarballTmpReturns(result1 string, result2 error) {
@@ -419,6 +708,70 @@ func (fake *FakeSpdxImplementation) ExtractTarballTmpReturnsOnCall(i int, result
 	}{result1, result2}
 }
 
+func (fake *FakeSpdxImplementation) ExtractZipTmp(arg1 string) (string, error) {
+	fake.extractZipTmpMutex.Lock()
+	ret, specificReturn := fake.extractZipTmpReturnsOnCall[len(fake.extractZipTmpArgsForCall)]
+	fake.extractZipTmpArgsForCall = append(fake.extractZipTmpArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ExtractZipTmpStub
+	fakeReturns := fake.extractZipTmpReturns
+	fake.recordInvocation("ExtractZipTmp", []interface{}{arg1})
+	fake.extractZipTmpMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) ExtractZipTmpCallCount() int {
+	fake.extractZipTmpMutex.RLock()
+	defer fake.extractZipTmpMutex.RUnlock()
+	return len(fake.extractZipTmpArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) ExtractZipTmpCalls(stub func(string) (string, error)) {
+	fake.extractZipTmpMutex.Lock()
+	defer fake.extractZipTmpMutex.Unlock()
+	fake.ExtractZipTmpStub = stub
+}
+
+func (fake *FakeSpdxImplementation) ExtractZipTmpArgsForCall(i int) string {
+	fake.extractZipTmpMutex.RLock()
+	defer fake.extractZipTmpMutex.RUnlock()
+	argsForCall := fake.extractZipTmpArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeSpdxImplementation) ExtractZipTmpReturns(result1 string, result2 error) {
+	fake.extractZipTmpMutex.Lock()
+	defer fake.extractZipTmpMutex.Unlock()
+	fake.ExtractZipTmpStub = nil
+	fake.extractZipTmpReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) ExtractZipTmpReturnsOnCall(i int, result1 string, result2 error) {
+	fake.extractZipTmpMutex.Lock()
+	defer fake.extractZipTmpMutex.Unlock()
+	fake.ExtractZipTmpStub = nil
+	if fake.extractZipTmpReturnsOnCall == nil {
+		fake.extractZipTmpReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.extractZipTmpReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeSpdxImplementation) GetDirectoryLicense(arg1 *license.Reader, arg2 string, arg3 *spdx.Options) (*license.License, error) {
 	fake.getDirectoryLicenseMutex.Lock()
 	ret, specificReturn := fake.getDirectoryLicenseReturnsOnCall[len(fake.getDirectoryLicenseArgsForCall)]
@@ -485,18 +838,20 @@ func (fake *FakeSpdxImplementation) GetDirectoryLicenseReturnsOnCall(i int, resu
 	}{result1, result2}
 }
 
-func (fake *FakeSpdxImplementation) GetDirectoryTree(arg1 string) ([]string, error) {
+func (fake *FakeSpdxImplementation) GetDirectoryTree(arg1 string, arg2 bool, arg3 bool) ([]string, error) {
 	fake.getDirectoryTreeMutex.Lock()
 	ret, specificReturn := fake.getDirectoryTreeReturnsOnCall[len(fake.getDirectoryTreeArgsForCall)]
 	fake.getDirectoryTreeArgsForCall = append(fake.getDirectoryTreeArgsForCall, struct {
 		arg1 string
-	}{arg1})
+		arg2 bool
+		arg3 bool
+	}{arg1, arg2, arg3})
 	stub := fake.GetDirectoryTreeStub
 	fakeReturns := fake.getDirectoryTreeReturns
-	fake.recordInvocation("GetDirectoryTree", []interface{}{arg1})
+	fake.recordInvocation("GetDirectoryTree", []interface{}{arg1, arg2, arg3})
 	fake.getDirectoryTreeMutex.Unlock()
 	if stub != nil {
-		return stub(arg1)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -510,17 +865,17 @@ func (fake *FakeSpdxImplementation) GetDirectoryTreeCallCount() int {
 	return len(fake.getDirectoryTreeArgsForCall)
 }
 
-func (fake *FakeSpdxImplementation) GetDirectoryTreeCalls(stub func(string) ([]string, error)) {
+func (fake *FakeSpdxImplementation) GetDirectoryTreeCalls(stub func(string, bool, bool) ([]string, error)) {
 	fake.getDirectoryTreeMutex.Lock()
 	defer fake.getDirectoryTreeMutex.Unlock()
 	fake.GetDirectoryTreeStub = stub
 }
 
-func (fake *FakeSpdxImplementation) GetDirectoryTreeArgsForCall(i int) string {
+func (fake *FakeSpdxImplementation) GetDirectoryTreeArgsForCall(i int) (string, bool, bool) {
 	fake.getDirectoryTreeMutex.RLock()
 	defer fake.getDirectoryTreeMutex.RUnlock()
 	argsForCall := fake.getDirectoryTreeArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeSpdxImplementation) GetDirectoryTreeReturns(result1 []string, result2 error) {
@@ -614,49 +969,248 @@ func (fake *FakeSpdxImplementation) GetGoDependenciesReturnsOnCall(i int, result
 	}{result1, result2}
 }
 
-func (fake *FakeSpdxImplementation) IgnorePatterns(arg1 string, arg2 []string, arg3 bool) ([]gitignore.Pattern, error) {
-	var arg2Copy []string
-	if arg2 != nil {
-		arg2Copy = make([]string, len(arg2))
-		copy(arg2Copy, arg2)
-	}
-	fake.ignorePatternsMutex.Lock()
-	ret, specificReturn := fake.ignorePatternsReturnsOnCall[len(fake.ignorePatternsArgsForCall)]
-	fake.ignorePatternsArgsForCall = append(fake.ignorePatternsArgsForCall, struct {
+func (fake *FakeSpdxImplementation) GetGoDependenciesWithErrors(arg1 string, arg2 *spdx.Options) ([]*spdx.Package, []error, error) {
+	fake.getGoDependenciesWithErrorsMutex.Lock()
+	ret, specificReturn := fake.getGoDependenciesWithErrorsReturnsOnCall[len(fake.getGoDependenciesWithErrorsArgsForCall)]
+	fake.getGoDependenciesWithErrorsArgsForCall = append(fake.getGoDependenciesWithErrorsArgsForCall, struct {
 		arg1 string
-		arg2 []string
-		arg3 bool
-	}{arg1, arg2Copy, arg3})
-	stub := fake.IgnorePatternsStub
-	fakeReturns := fake.ignorePatternsReturns
-	fake.recordInvocation("IgnorePatterns", []interface{}{arg1, arg2Copy, arg3})
-	fake.ignorePatternsMutex.Unlock()
+		arg2 *spdx.Options
+	}{arg1, arg2})
+	stub := fake.GetGoDependenciesWithErrorsStub
+	fakeReturns := fake.getGoDependenciesWithErrorsReturns
+	fake.recordInvocation("GetGoDependenciesWithErrors", []interface{}{arg1, arg2})
+	fake.getGoDependenciesWithErrorsMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2, arg3)
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
-		return ret.result1, ret.result2
+		return ret.result1, ret.result2, ret.result3
 	}
-	return fakeReturns.result1, fakeReturns.result2
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
 }
 
-func (fake *FakeSpdxImplementation) IgnorePatternsCallCount() int {
-	fake.ignorePatternsMutex.RLock()
-	defer fake.ignorePatternsMutex.RUnlock()
-	return len(fake.ignorePatternsArgsForCall)
+func (fake *FakeSpdxImplementation) GetGoDependenciesWithErrorsCallCount() int {
+	fake.getGoDependenciesWithErrorsMutex.RLock()
+	defer fake.getGoDependenciesWithErrorsMutex.RUnlock()
+	return len(fake.getGoDependenciesWithErrorsArgsForCall)
 }
 
-func (fake *FakeSpdxImplementation) IgnorePatternsCalls(stub func(string, []string, bool) ([]gitignore.Pattern, error)) {
-	fake.ignorePatternsMutex.Lock()
-	defer fake.ignorePatternsMutex.Unlock()
-	fake.IgnorePatternsStub = stub
+func (fake *FakeSpdxImplementation) GetGoDependenciesWithErrorsCalls(stub func(string, *spdx.Options) ([]*spdx.Package, []error, error)) {
+	fake.getGoDependenciesWithErrorsMutex.Lock()
+	defer fake.getGoDependenciesWithErrorsMutex.Unlock()
+	fake.GetGoDependenciesWithErrorsStub = stub
 }
 
-func (fake *FakeSpdxImplementation) IgnorePatternsArgsForCall(i int) (string, []string, bool) {
-	fake.ignorePatternsMutex.RLock()
-	defer fake.ignorePatternsMutex.RUnlock()
-	argsForCall := fake.ignorePatternsArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+func (fake *FakeSpdxImplementation) GetGoDependenciesWithErrorsArgsForCall(i int) (string, *spdx.Options) {
+	fake.getGoDependenciesWithErrorsMutex.RLock()
+	defer fake.getGoDependenciesWithErrorsMutex.RUnlock()
+	argsForCall := fake.getGoDependenciesWithErrorsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSpdxImplementation) GetGoDependenciesWithErrorsReturns(result1 []*spdx.Package, result2 []error, result3 error) {
+	fake.getGoDependenciesWithErrorsMutex.Lock()
+	defer fake.getGoDependenciesWithErrorsMutex.Unlock()
+	fake.GetGoDependenciesWithErrorsStub = nil
+	fake.getGoDependenciesWithErrorsReturns = struct {
+		result1 []*spdx.Package
+		result2 []error
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeSpdxImplementation) GetGoDependenciesWithErrorsReturnsOnCall(i int, result1 []*spdx.Package, result2 []error, result3 error) {
+	fake.getGoDependenciesWithErrorsMutex.Lock()
+	defer fake.getGoDependenciesWithErrorsMutex.Unlock()
+	fake.GetGoDependenciesWithErrorsStub = nil
+	if fake.getGoDependenciesWithErrorsReturnsOnCall == nil {
+		fake.getGoDependenciesWithErrorsReturnsOnCall = make(map[int]struct {
+			result1 []*spdx.Package
+			result2 []error
+			result3 error
+		})
+	}
+	fake.getGoDependenciesWithErrorsReturnsOnCall[i] = struct {
+		result1 []*spdx.Package
+		result2 []error
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeSpdxImplementation) GetNPMDependencies(arg1 string, arg2 *spdx.Options) ([]*spdx.Package, error) {
+	fake.getNPMDependenciesMutex.Lock()
+	ret, specificReturn := fake.getNPMDependenciesReturnsOnCall[len(fake.getNPMDependenciesArgsForCall)]
+	fake.getNPMDependenciesArgsForCall = append(fake.getNPMDependenciesArgsForCall, struct {
+		arg1 string
+		arg2 *spdx.Options
+	}{arg1, arg2})
+	stub := fake.GetNPMDependenciesStub
+	fakeReturns := fake.getNPMDependenciesReturns
+	fake.recordInvocation("GetNPMDependencies", []interface{}{arg1, arg2})
+	fake.getNPMDependenciesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) GetNPMDependenciesCallCount() int {
+	fake.getNPMDependenciesMutex.RLock()
+	defer fake.getNPMDependenciesMutex.RUnlock()
+	return len(fake.getNPMDependenciesArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) GetNPMDependenciesCalls(stub func(string, *spdx.Options) ([]*spdx.Package, error)) {
+	fake.getNPMDependenciesMutex.Lock()
+	defer fake.getNPMDependenciesMutex.Unlock()
+	fake.GetNPMDependenciesStub = stub
+}
+
+func (fake *FakeSpdxImplementation) GetNPMDependenciesArgsForCall(i int) (string, *spdx.Options) {
+	fake.getNPMDependenciesMutex.RLock()
+	defer fake.getNPMDependenciesMutex.RUnlock()
+	argsForCall := fake.getNPMDependenciesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSpdxImplementation) GetNPMDependenciesReturns(result1 []*spdx.Package, result2 error) {
+	fake.getNPMDependenciesMutex.Lock()
+	defer fake.getNPMDependenciesMutex.Unlock()
+	fake.GetNPMDependenciesStub = nil
+	fake.getNPMDependenciesReturns = struct {
+		result1 []*spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) GetNPMDependenciesReturnsOnCall(i int, result1 []*spdx.Package, result2 error) {
+	fake.getNPMDependenciesMutex.Lock()
+	defer fake.getNPMDependenciesMutex.Unlock()
+	fake.GetNPMDependenciesStub = nil
+	if fake.getNPMDependenciesReturnsOnCall == nil {
+		fake.getNPMDependenciesReturnsOnCall = make(map[int]struct {
+			result1 []*spdx.Package
+			result2 error
+		})
+	}
+	fake.getNPMDependenciesReturnsOnCall[i] = struct {
+		result1 []*spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) GetPythonDependencies(arg1 string, arg2 *spdx.Options) ([]*spdx.Package, error) {
+	fake.getPythonDependenciesMutex.Lock()
+	ret, specificReturn := fake.getPythonDependenciesReturnsOnCall[len(fake.getPythonDependenciesArgsForCall)]
+	fake.getPythonDependenciesArgsForCall = append(fake.getPythonDependenciesArgsForCall, struct {
+		arg1 string
+		arg2 *spdx.Options
+	}{arg1, arg2})
+	stub := fake.GetPythonDependenciesStub
+	fakeReturns := fake.getPythonDependenciesReturns
+	fake.recordInvocation("GetPythonDependencies", []interface{}{arg1, arg2})
+	fake.getPythonDependenciesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) GetPythonDependenciesCallCount() int {
+	fake.getPythonDependenciesMutex.RLock()
+	defer fake.getPythonDependenciesMutex.RUnlock()
+	return len(fake.getPythonDependenciesArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) GetPythonDependenciesCalls(stub func(string, *spdx.Options) ([]*spdx.Package, error)) {
+	fake.getPythonDependenciesMutex.Lock()
+	defer fake.getPythonDependenciesMutex.Unlock()
+	fake.GetPythonDependenciesStub = stub
+}
+
+func (fake *FakeSpdxImplementation) GetPythonDependenciesArgsForCall(i int) (string, *spdx.Options) {
+	fake.getPythonDependenciesMutex.RLock()
+	defer fake.getPythonDependenciesMutex.RUnlock()
+	argsForCall := fake.getPythonDependenciesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSpdxImplementation) GetPythonDependenciesReturns(result1 []*spdx.Package, result2 error) {
+	fake.getPythonDependenciesMutex.Lock()
+	defer fake.getPythonDependenciesMutex.Unlock()
+	fake.GetPythonDependenciesStub = nil
+	fake.getPythonDependenciesReturns = struct {
+		result1 []*spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) GetPythonDependenciesReturnsOnCall(i int, result1 []*spdx.Package, result2 error) {
+	fake.getPythonDependenciesMutex.Lock()
+	defer fake.getPythonDependenciesMutex.Unlock()
+	fake.GetPythonDependenciesStub = nil
+	if fake.getPythonDependenciesReturnsOnCall == nil {
+		fake.getPythonDependenciesReturnsOnCall = make(map[int]struct {
+			result1 []*spdx.Package
+			result2 error
+		})
+	}
+	fake.getPythonDependenciesReturnsOnCall[i] = struct {
+		result1 []*spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) IgnorePatterns(arg1 string, arg2 []string, arg3 bool, arg4 bool) ([]gitignore.Pattern, error) {
+	var arg2Copy []string
+	if arg2 != nil {
+		arg2Copy = make([]string, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.ignorePatternsMutex.Lock()
+	ret, specificReturn := fake.ignorePatternsReturnsOnCall[len(fake.ignorePatternsArgsForCall)]
+	fake.ignorePatternsArgsForCall = append(fake.ignorePatternsArgsForCall, struct {
+		arg1 string
+		arg2 []string
+		arg3 bool
+		arg4 bool
+	}{arg1, arg2Copy, arg3, arg4})
+	stub := fake.IgnorePatternsStub
+	fakeReturns := fake.ignorePatternsReturns
+	fake.recordInvocation("IgnorePatterns", []interface{}{arg1, arg2Copy, arg3, arg4})
+	fake.ignorePatternsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) IgnorePatternsCallCount() int {
+	fake.ignorePatternsMutex.RLock()
+	defer fake.ignorePatternsMutex.RUnlock()
+	return len(fake.ignorePatternsArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) IgnorePatternsCalls(stub func(string, []string, bool, bool) ([]gitignore.Pattern, error)) {
+	fake.ignorePatternsMutex.Lock()
+	defer fake.ignorePatternsMutex.Unlock()
+	fake.IgnorePatternsStub = stub
+}
+
+func (fake *FakeSpdxImplementation) IgnorePatternsArgsForCall(i int) (string, []string, bool, bool) {
+	fake.ignorePatternsMutex.RLock()
+	defer fake.ignorePatternsMutex.RUnlock()
+	argsForCall := fake.ignorePatternsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
 func (fake *FakeSpdxImplementation) IgnorePatternsReturns(result1 []gitignore.Pattern, result2 error) {
@@ -685,19 +1239,86 @@ func (fake *FakeSpdxImplementation) IgnorePatternsReturnsOnCall(i int, result1 [
 	}{result1, result2}
 }
 
-func (fake *FakeSpdxImplementation) ImageRefToPackage(arg1 string, arg2 *spdx.Options) (*spdx.Package, error) {
+func (fake *FakeSpdxImplementation) ImageOSPackages(arg1 context.Context, arg2 string, arg3 *spdx.Options) ([]osinfo.PackageDBEntry, error) {
+	fake.imageOSPackagesMutex.Lock()
+	ret, specificReturn := fake.imageOSPackagesReturnsOnCall[len(fake.imageOSPackagesArgsForCall)]
+	fake.imageOSPackagesArgsForCall = append(fake.imageOSPackagesArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 *spdx.Options
+	}{arg1, arg2, arg3})
+	stub := fake.ImageOSPackagesStub
+	fakeReturns := fake.imageOSPackagesReturns
+	fake.recordInvocation("ImageOSPackages", []interface{}{arg1, arg2, arg3})
+	fake.imageOSPackagesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) ImageOSPackagesCallCount() int {
+	fake.imageOSPackagesMutex.RLock()
+	defer fake.imageOSPackagesMutex.RUnlock()
+	return len(fake.imageOSPackagesArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) ImageOSPackagesCalls(stub func(context.Context, string, *spdx.Options) ([]osinfo.PackageDBEntry, error)) {
+	fake.imageOSPackagesMutex.Lock()
+	defer fake.imageOSPackagesMutex.Unlock()
+	fake.ImageOSPackagesStub = stub
+}
+
+func (fake *FakeSpdxImplementation) ImageOSPackagesArgsForCall(i int) (context.Context, string, *spdx.Options) {
+	fake.imageOSPackagesMutex.RLock()
+	defer fake.imageOSPackagesMutex.RUnlock()
+	argsForCall := fake.imageOSPackagesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeSpdxImplementation) ImageOSPackagesReturns(result1 []osinfo.PackageDBEntry, result2 error) {
+	fake.imageOSPackagesMutex.Lock()
+	defer fake.imageOSPackagesMutex.Unlock()
+	fake.ImageOSPackagesStub = nil
+	fake.imageOSPackagesReturns = struct {
+		result1 []osinfo.PackageDBEntry
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) ImageOSPackagesReturnsOnCall(i int, result1 []osinfo.PackageDBEntry, result2 error) {
+	fake.imageOSPackagesMutex.Lock()
+	defer fake.imageOSPackagesMutex.Unlock()
+	fake.ImageOSPackagesStub = nil
+	if fake.imageOSPackagesReturnsOnCall == nil {
+		fake.imageOSPackagesReturnsOnCall = make(map[int]struct {
+			result1 []osinfo.PackageDBEntry
+			result2 error
+		})
+	}
+	fake.imageOSPackagesReturnsOnCall[i] = struct {
+		result1 []osinfo.PackageDBEntry
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) ImageRefToPackage(arg1 context.Context, arg2 string, arg3 *spdx.Options) (*spdx.Package, error) {
 	fake.imageRefToPackageMutex.Lock()
 	ret, specificReturn := fake.imageRefToPackageReturnsOnCall[len(fake.imageRefToPackageArgsForCall)]
 	fake.imageRefToPackageArgsForCall = append(fake.imageRefToPackageArgsForCall, struct {
-		arg1 string
-		arg2 *spdx.Options
-	}{arg1, arg2})
+		arg1 context.Context
+		arg2 string
+		arg3 *spdx.Options
+	}{arg1, arg2, arg3})
 	stub := fake.ImageRefToPackageStub
 	fakeReturns := fake.imageRefToPackageReturns
-	fake.recordInvocation("ImageRefToPackage", []interface{}{arg1, arg2})
+	fake.recordInvocation("ImageRefToPackage", []interface{}{arg1, arg2, arg3})
 	fake.imageRefToPackageMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -711,17 +1332,17 @@ func (fake *FakeSpdxImplementation) ImageRefToPackageCallCount() int {
 	return len(fake.imageRefToPackageArgsForCall)
 }
 
-func (fake *FakeSpdxImplementation) ImageRefToPackageCalls(stub func(string, *spdx.Options) (*spdx.Package, error)) {
+func (fake *FakeSpdxImplementation) ImageRefToPackageCalls(stub func(context.Context, string, *spdx.Options) (*spdx.Package, error)) {
 	fake.imageRefToPackageMutex.Lock()
 	defer fake.imageRefToPackageMutex.Unlock()
 	fake.ImageRefToPackageStub = stub
 }
 
-func (fake *FakeSpdxImplementation) ImageRefToPackageArgsForCall(i int) (string, *spdx.Options) {
+func (fake *FakeSpdxImplementation) ImageRefToPackageArgsForCall(i int) (context.Context, string, *spdx.Options) {
 	fake.imageRefToPackageMutex.RLock()
 	defer fake.imageRefToPackageMutex.RUnlock()
 	argsForCall := fake.imageRefToPackageArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeSpdxImplementation) ImageRefToPackageReturns(result1 *spdx.Package, result2 error) {
@@ -814,6 +1435,72 @@ func (fake *FakeSpdxImplementation) LicenseReaderReturnsOnCall(i int, result1 *l
 	}{result1, result2}
 }
 
+func (fake *FakeSpdxImplementation) PackageFromContentStore(arg1 *spdx.Options, arg2 spdx.BlobSource, arg3 string) (*spdx.Package, error) {
+	fake.packageFromContentStoreMutex.Lock()
+	ret, specificReturn := fake.packageFromContentStoreReturnsOnCall[len(fake.packageFromContentStoreArgsForCall)]
+	fake.packageFromContentStoreArgsForCall = append(fake.packageFromContentStoreArgsForCall, struct {
+		arg1 *spdx.Options
+		arg2 spdx.BlobSource
+		arg3 string
+	}{arg1, arg2, arg3})
+	stub := fake.PackageFromContentStoreStub
+	fakeReturns := fake.packageFromContentStoreReturns
+	fake.recordInvocation("PackageFromContentStore", []interface{}{arg1, arg2, arg3})
+	fake.packageFromContentStoreMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) PackageFromContentStoreCallCount() int {
+	fake.packageFromContentStoreMutex.RLock()
+	defer fake.packageFromContentStoreMutex.RUnlock()
+	return len(fake.packageFromContentStoreArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) PackageFromContentStoreCalls(stub func(*spdx.Options, spdx.BlobSource, string) (*spdx.Package, error)) {
+	fake.packageFromContentStoreMutex.Lock()
+	defer fake.packageFromContentStoreMutex.Unlock()
+	fake.PackageFromContentStoreStub = stub
+}
+
+func (fake *FakeSpdxImplementation) PackageFromContentStoreArgsForCall(i int) (*spdx.Options, spdx.BlobSource, string) {
+	fake.packageFromContentStoreMutex.RLock()
+	defer fake.packageFromContentStoreMutex.RUnlock()
+	argsForCall := fake.packageFromContentStoreArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeSpdxImplementation) PackageFromContentStoreReturns(result1 *spdx.Package, result2 error) {
+	fake.packageFromContentStoreMutex.Lock()
+	defer fake.packageFromContentStoreMutex.Unlock()
+	fake.PackageFromContentStoreStub = nil
+	fake.packageFromContentStoreReturns = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) PackageFromContentStoreReturnsOnCall(i int, result1 *spdx.Package, result2 error) {
+	fake.packageFromContentStoreMutex.Lock()
+	defer fake.packageFromContentStoreMutex.Unlock()
+	fake.PackageFromContentStoreStub = nil
+	if fake.packageFromContentStoreReturnsOnCall == nil {
+		fake.packageFromContentStoreReturnsOnCall = make(map[int]struct {
+			result1 *spdx.Package
+			result2 error
+		})
+	}
+	fake.packageFromContentStoreReturnsOnCall[i] = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeSpdxImplementation) PackageFromDirectory(arg1 *spdx.Options, arg2 string) (*spdx.Package, error) {
 	fake.packageFromDirectoryMutex.Lock()
 	ret, specificReturn := fake.packageFromDirectoryReturnsOnCall[len(fake.packageFromDirectoryArgsForCall)]
@@ -879,6 +1566,272 @@ func (fake *FakeSpdxImplementation) PackageFromDirectoryReturnsOnCall(i int, res
 	}{result1, result2}
 }
 
+func (fake *FakeSpdxImplementation) PackageFromFile(arg1 *spdx.Options, arg2 string) (*spdx.Package, error) {
+	fake.packageFromFileMutex.Lock()
+	ret, specificReturn := fake.packageFromFileReturnsOnCall[len(fake.packageFromFileArgsForCall)]
+	fake.packageFromFileArgsForCall = append(fake.packageFromFileArgsForCall, struct {
+		arg1 *spdx.Options
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.PackageFromFileStub
+	fakeReturns := fake.packageFromFileReturns
+	fake.recordInvocation("PackageFromFile", []interface{}{arg1, arg2})
+	fake.packageFromFileMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFileCallCount() int {
+	fake.packageFromFileMutex.RLock()
+	defer fake.packageFromFileMutex.RUnlock()
+	return len(fake.packageFromFileArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFileCalls(stub func(*spdx.Options, string) (*spdx.Package, error)) {
+	fake.packageFromFileMutex.Lock()
+	defer fake.packageFromFileMutex.Unlock()
+	fake.PackageFromFileStub = stub
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFileArgsForCall(i int) (*spdx.Options, string) {
+	fake.packageFromFileMutex.RLock()
+	defer fake.packageFromFileMutex.RUnlock()
+	argsForCall := fake.packageFromFileArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFileReturns(result1 *spdx.Package, result2 error) {
+	fake.packageFromFileMutex.Lock()
+	defer fake.packageFromFileMutex.Unlock()
+	fake.PackageFromFileStub = nil
+	fake.packageFromFileReturns = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFileReturnsOnCall(i int, result1 *spdx.Package, result2 error) {
+	fake.packageFromFileMutex.Lock()
+	defer fake.packageFromFileMutex.Unlock()
+	fake.PackageFromFileStub = nil
+	if fake.packageFromFileReturnsOnCall == nil {
+		fake.packageFromFileReturnsOnCall = make(map[int]struct {
+			result1 *spdx.Package
+			result2 error
+		})
+	}
+	fake.packageFromFileReturnsOnCall[i] = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFilesystemImage(arg1 *spdx.Options, arg2 string) (*spdx.Package, error) {
+	fake.packageFromFilesystemImageMutex.Lock()
+	ret, specificReturn := fake.packageFromFilesystemImageReturnsOnCall[len(fake.packageFromFilesystemImageArgsForCall)]
+	fake.packageFromFilesystemImageArgsForCall = append(fake.packageFromFilesystemImageArgsForCall, struct {
+		arg1 *spdx.Options
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.PackageFromFilesystemImageStub
+	fakeReturns := fake.packageFromFilesystemImageReturns
+	fake.recordInvocation("PackageFromFilesystemImage", []interface{}{arg1, arg2})
+	fake.packageFromFilesystemImageMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFilesystemImageCallCount() int {
+	fake.packageFromFilesystemImageMutex.RLock()
+	defer fake.packageFromFilesystemImageMutex.RUnlock()
+	return len(fake.packageFromFilesystemImageArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFilesystemImageCalls(stub func(*spdx.Options, string) (*spdx.Package, error)) {
+	fake.packageFromFilesystemImageMutex.Lock()
+	defer fake.packageFromFilesystemImageMutex.Unlock()
+	fake.PackageFromFilesystemImageStub = stub
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFilesystemImageArgsForCall(i int) (*spdx.Options, string) {
+	fake.packageFromFilesystemImageMutex.RLock()
+	defer fake.packageFromFilesystemImageMutex.RUnlock()
+	argsForCall := fake.packageFromFilesystemImageArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFilesystemImageReturns(result1 *spdx.Package, result2 error) {
+	fake.packageFromFilesystemImageMutex.Lock()
+	defer fake.packageFromFilesystemImageMutex.Unlock()
+	fake.PackageFromFilesystemImageStub = nil
+	fake.packageFromFilesystemImageReturns = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) PackageFromFilesystemImageReturnsOnCall(i int, result1 *spdx.Package, result2 error) {
+	fake.packageFromFilesystemImageMutex.Lock()
+	defer fake.packageFromFilesystemImageMutex.Unlock()
+	fake.PackageFromFilesystemImageStub = nil
+	if fake.packageFromFilesystemImageReturnsOnCall == nil {
+		fake.packageFromFilesystemImageReturnsOnCall = make(map[int]struct {
+			result1 *spdx.Package
+			result2 error
+		})
+	}
+	fake.packageFromFilesystemImageReturnsOnCall[i] = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) PackageFromGoBinary(arg1 *spdx.Options, arg2 string) (*spdx.Package, error) {
+	fake.packageFromGoBinaryMutex.Lock()
+	ret, specificReturn := fake.packageFromGoBinaryReturnsOnCall[len(fake.packageFromGoBinaryArgsForCall)]
+	fake.packageFromGoBinaryArgsForCall = append(fake.packageFromGoBinaryArgsForCall, struct {
+		arg1 *spdx.Options
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.PackageFromGoBinaryStub
+	fakeReturns := fake.packageFromGoBinaryReturns
+	fake.recordInvocation("PackageFromGoBinary", []interface{}{arg1, arg2})
+	fake.packageFromGoBinaryMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) PackageFromGoBinaryCallCount() int {
+	fake.packageFromGoBinaryMutex.RLock()
+	defer fake.packageFromGoBinaryMutex.RUnlock()
+	return len(fake.packageFromGoBinaryArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) PackageFromGoBinaryCalls(stub func(*spdx.Options, string) (*spdx.Package, error)) {
+	fake.packageFromGoBinaryMutex.Lock()
+	defer fake.packageFromGoBinaryMutex.Unlock()
+	fake.PackageFromGoBinaryStub = stub
+}
+
+func (fake *FakeSpdxImplementation) PackageFromGoBinaryArgsForCall(i int) (*spdx.Options, string) {
+	fake.packageFromGoBinaryMutex.RLock()
+	defer fake.packageFromGoBinaryMutex.RUnlock()
+	argsForCall := fake.packageFromGoBinaryArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSpdxImplementation) PackageFromGoBinaryReturns(result1 *spdx.Package, result2 error) {
+	fake.packageFromGoBinaryMutex.Lock()
+	defer fake.packageFromGoBinaryMutex.Unlock()
+	fake.PackageFromGoBinaryStub = nil
+	fake.packageFromGoBinaryReturns = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) PackageFromGoBinaryReturnsOnCall(i int, result1 *spdx.Package, result2 error) {
+	fake.packageFromGoBinaryMutex.Lock()
+	defer fake.packageFromGoBinaryMutex.Unlock()
+	fake.PackageFromGoBinaryStub = nil
+	if fake.packageFromGoBinaryReturnsOnCall == nil {
+		fake.packageFromGoBinaryReturnsOnCall = make(map[int]struct {
+			result1 *spdx.Package
+			result2 error
+		})
+	}
+	fake.packageFromGoBinaryReturnsOnCall[i] = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) PackageFromImageFiles(arg1 *spdx.Options, arg2 string, arg3 []string) (*spdx.Package, error) {
+	var arg3Copy []string
+	if arg3 != nil {
+		arg3Copy = make([]string, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.packageFromImageFilesMutex.Lock()
+	ret, specificReturn := fake.packageFromImageFilesReturnsOnCall[len(fake.packageFromImageFilesArgsForCall)]
+	fake.packageFromImageFilesArgsForCall = append(fake.packageFromImageFilesArgsForCall, struct {
+		arg1 *spdx.Options
+		arg2 string
+		arg3 []string
+	}{arg1, arg2, arg3Copy})
+	stub := fake.PackageFromImageFilesStub
+	fakeReturns := fake.packageFromImageFilesReturns
+	fake.recordInvocation("PackageFromImageFiles", []interface{}{arg1, arg2, arg3Copy})
+	fake.packageFromImageFilesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) PackageFromImageFilesCallCount() int {
+	fake.packageFromImageFilesMutex.RLock()
+	defer fake.packageFromImageFilesMutex.RUnlock()
+	return len(fake.packageFromImageFilesArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) PackageFromImageFilesCalls(stub func(*spdx.Options, string, []string) (*spdx.Package, error)) {
+	fake.packageFromImageFilesMutex.Lock()
+	defer fake.packageFromImageFilesMutex.Unlock()
+	fake.PackageFromImageFilesStub = stub
+}
+
+func (fake *FakeSpdxImplementation) PackageFromImageFilesArgsForCall(i int) (*spdx.Options, string, []string) {
+	fake.packageFromImageFilesMutex.RLock()
+	defer fake.packageFromImageFilesMutex.RUnlock()
+	argsForCall := fake.packageFromImageFilesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeSpdxImplementation) PackageFromImageFilesReturns(result1 *spdx.Package, result2 error) {
+	fake.packageFromImageFilesMutex.Lock()
+	defer fake.packageFromImageFilesMutex.Unlock()
+	fake.PackageFromImageFilesStub = nil
+	fake.packageFromImageFilesReturns = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) PackageFromImageFilesReturnsOnCall(i int, result1 *spdx.Package, result2 error) {
+	fake.packageFromImageFilesMutex.Lock()
+	defer fake.packageFromImageFilesMutex.Unlock()
+	fake.PackageFromImageFilesStub = nil
+	if fake.packageFromImageFilesReturnsOnCall == nil {
+		fake.packageFromImageFilesReturnsOnCall = make(map[int]struct {
+			result1 *spdx.Package
+			result2 error
+		})
+	}
+	fake.packageFromImageFilesReturnsOnCall[i] = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeSpdxImplementation) PackageFromImageTarball(arg1 *spdx.Options, arg2 string) (*spdx.Package, error) {
 	fake.packageFromImageTarballMutex.Lock()
 	ret, specificReturn := fake.packageFromImageTarballReturnsOnCall[len(fake.packageFromImageTarballArgsForCall)]
@@ -1010,19 +1963,86 @@ func (fake *FakeSpdxImplementation) PackageFromTarballReturnsOnCall(i int, resul
 	}{result1, result2}
 }
 
-func (fake *FakeSpdxImplementation) PullImagesToArchive(arg1 string, arg2 string) (*spdx.ImageReferenceInfo, error) {
+func (fake *FakeSpdxImplementation) PackageFromZip(arg1 *spdx.Options, arg2 string) (*spdx.Package, error) {
+	fake.packageFromZipMutex.Lock()
+	ret, specificReturn := fake.packageFromZipReturnsOnCall[len(fake.packageFromZipArgsForCall)]
+	fake.packageFromZipArgsForCall = append(fake.packageFromZipArgsForCall, struct {
+		arg1 *spdx.Options
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.PackageFromZipStub
+	fakeReturns := fake.packageFromZipReturns
+	fake.recordInvocation("PackageFromZip", []interface{}{arg1, arg2})
+	fake.packageFromZipMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) PackageFromZipCallCount() int {
+	fake.packageFromZipMutex.RLock()
+	defer fake.packageFromZipMutex.RUnlock()
+	return len(fake.packageFromZipArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) PackageFromZipCalls(stub func(*spdx.Options, string) (*spdx.Package, error)) {
+	fake.packageFromZipMutex.Lock()
+	defer fake.packageFromZipMutex.Unlock()
+	fake.PackageFromZipStub = stub
+}
+
+func (fake *FakeSpdxImplementation) PackageFromZipArgsForCall(i int) (*spdx.Options, string) {
+	fake.packageFromZipMutex.RLock()
+	defer fake.packageFromZipMutex.RUnlock()
+	argsForCall := fake.packageFromZipArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSpdxImplementation) PackageFromZipReturns(result1 *spdx.Package, result2 error) {
+	fake.packageFromZipMutex.Lock()
+	defer fake.packageFromZipMutex.Unlock()
+	fake.PackageFromZipStub = nil
+	fake.packageFromZipReturns = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) PackageFromZipReturnsOnCall(i int, result1 *spdx.Package, result2 error) {
+	fake.packageFromZipMutex.Lock()
+	defer fake.packageFromZipMutex.Unlock()
+	fake.PackageFromZipStub = nil
+	if fake.packageFromZipReturnsOnCall == nil {
+		fake.packageFromZipReturnsOnCall = make(map[int]struct {
+			result1 *spdx.Package
+			result2 error
+		})
+	}
+	fake.packageFromZipReturnsOnCall[i] = struct {
+		result1 *spdx.Package
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) PullImagesToArchive(arg1 context.Context, arg2 *spdx.Options, arg3 string, arg4 string) (*spdx.ImageReferenceInfo, error) {
 	fake.pullImagesToArchiveMutex.Lock()
 	ret, specificReturn := fake.pullImagesToArchiveReturnsOnCall[len(fake.pullImagesToArchiveArgsForCall)]
 	fake.pullImagesToArchiveArgsForCall = append(fake.pullImagesToArchiveArgsForCall, struct {
-		arg1 string
-		arg2 string
-	}{arg1, arg2})
+		arg1 context.Context
+		arg2 *spdx.Options
+		arg3 string
+		arg4 string
+	}{arg1, arg2, arg3, arg4})
 	stub := fake.PullImagesToArchiveStub
 	fakeReturns := fake.pullImagesToArchiveReturns
-	fake.recordInvocation("PullImagesToArchive", []interface{}{arg1, arg2})
+	fake.recordInvocation("PullImagesToArchive", []interface{}{arg1, arg2, arg3, arg4})
 	fake.pullImagesToArchiveMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3, arg4)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -1036,17 +2056,17 @@ func (fake *FakeSpdxImplementation) PullImagesToArchiveCallCount() int {
 	return len(fake.pullImagesToArchiveArgsForCall)
 }
 
-func (fake *FakeSpdxImplementation) PullImagesToArchiveCalls(stub func(string, string) (*spdx.ImageReferenceInfo, error)) {
+func (fake *FakeSpdxImplementation) PullImagesToArchiveCalls(stub func(context.Context, *spdx.Options, string, string) (*spdx.ImageReferenceInfo, error)) {
 	fake.pullImagesToArchiveMutex.Lock()
 	defer fake.pullImagesToArchiveMutex.Unlock()
 	fake.PullImagesToArchiveStub = stub
 }
 
-func (fake *FakeSpdxImplementation) PullImagesToArchiveArgsForCall(i int) (string, string) {
+func (fake *FakeSpdxImplementation) PullImagesToArchiveArgsForCall(i int) (context.Context, *spdx.Options, string, string) {
 	fake.pullImagesToArchiveMutex.RLock()
 	defer fake.pullImagesToArchiveMutex.RUnlock()
 	argsForCall := fake.pullImagesToArchiveArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
 func (fake *FakeSpdxImplementation) PullImagesToArchiveReturns(result1 *spdx.ImageReferenceInfo, result2 error) {
@@ -1139,6 +2159,176 @@ func (fake *FakeSpdxImplementation) ReadArchiveManifestReturnsOnCall(i int, resu
 	}{result1, result2}
 }
 
+func (fake *FakeSpdxImplementation) ReadArchiveManifests(arg1 string) ([]spdx.ArchiveManifest, error) {
+	fake.readArchiveManifestsMutex.Lock()
+	ret, specificReturn := fake.readArchiveManifestsReturnsOnCall[len(fake.readArchiveManifestsArgsForCall)]
+	fake.readArchiveManifestsArgsForCall = append(fake.readArchiveManifestsArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ReadArchiveManifestsStub
+	fakeReturns := fake.readArchiveManifestsReturns
+	fake.recordInvocation("ReadArchiveManifests", []interface{}{arg1})
+	fake.readArchiveManifestsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSpdxImplementation) ReadArchiveManifestsCallCount() int {
+	fake.readArchiveManifestsMutex.RLock()
+	defer fake.readArchiveManifestsMutex.RUnlock()
+	return len(fake.readArchiveManifestsArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) ReadArchiveManifestsCalls(stub func(string) ([]spdx.ArchiveManifest, error)) {
+	fake.readArchiveManifestsMutex.Lock()
+	defer fake.readArchiveManifestsMutex.Unlock()
+	fake.ReadArchiveManifestsStub = stub
+}
+
+func (fake *FakeSpdxImplementation) ReadArchiveManifestsArgsForCall(i int) string {
+	fake.readArchiveManifestsMutex.RLock()
+	defer fake.readArchiveManifestsMutex.RUnlock()
+	argsForCall := fake.readArchiveManifestsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeSpdxImplementation) ReadArchiveManifestsReturns(result1 []spdx.ArchiveManifest, result2 error) {
+	fake.readArchiveManifestsMutex.Lock()
+	defer fake.readArchiveManifestsMutex.Unlock()
+	fake.ReadArchiveManifestsStub = nil
+	fake.readArchiveManifestsReturns = struct {
+		result1 []spdx.ArchiveManifest
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) ReadArchiveManifestsReturnsOnCall(i int, result1 []spdx.ArchiveManifest, result2 error) {
+	fake.readArchiveManifestsMutex.Lock()
+	defer fake.readArchiveManifestsMutex.Unlock()
+	fake.ReadArchiveManifestsStub = nil
+	if fake.readArchiveManifestsReturnsOnCall == nil {
+		fake.readArchiveManifestsReturnsOnCall = make(map[int]struct {
+			result1 []spdx.ArchiveManifest
+			result2 error
+		})
+	}
+	fake.readArchiveManifestsReturnsOnCall[i] = struct {
+		result1 []spdx.ArchiveManifest
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSpdxImplementation) Stats() spdx.Stats {
+	fake.statsMutex.Lock()
+	ret, specificReturn := fake.statsReturnsOnCall[len(fake.statsArgsForCall)]
+	fake.statsArgsForCall = append(fake.statsArgsForCall, struct {
+	}{})
+	stub := fake.StatsStub
+	fakeReturns := fake.statsReturns
+	fake.recordInvocation("Stats", []interface{}{})
+	fake.statsMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeSpdxImplementation) StatsCallCount() int {
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
+	return len(fake.statsArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) StatsCalls(stub func() spdx.Stats) {
+	fake.statsMutex.Lock()
+	defer fake.statsMutex.Unlock()
+	fake.StatsStub = stub
+}
+
+func (fake *FakeSpdxImplementation) StatsReturns(result1 spdx.Stats) {
+	fake.statsMutex.Lock()
+	defer fake.statsMutex.Unlock()
+	fake.StatsStub = nil
+	fake.statsReturns = struct {
+		result1 spdx.Stats
+	}{result1}
+}
+
+func (fake *FakeSpdxImplementation) StatsReturnsOnCall(i int, result1 spdx.Stats) {
+	fake.statsMutex.Lock()
+	defer fake.statsMutex.Unlock()
+	fake.StatsStub = nil
+	if fake.statsReturnsOnCall == nil {
+		fake.statsReturnsOnCall = make(map[int]struct {
+			result1 spdx.Stats
+		})
+	}
+	fake.statsReturnsOnCall[i] = struct {
+		result1 spdx.Stats
+	}{result1}
+}
+
+func (fake *FakeSpdxImplementation) Warnings() []spdx.Warning {
+	fake.warningsMutex.Lock()
+	ret, specificReturn := fake.warningsReturnsOnCall[len(fake.warningsArgsForCall)]
+	fake.warningsArgsForCall = append(fake.warningsArgsForCall, struct {
+	}{})
+	stub := fake.WarningsStub
+	fakeReturns := fake.warningsReturns
+	fake.recordInvocation("Warnings", []interface{}{})
+	fake.warningsMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeSpdxImplementation) WarningsCallCount() int {
+	fake.warningsMutex.RLock()
+	defer fake.warningsMutex.RUnlock()
+	return len(fake.warningsArgsForCall)
+}
+
+func (fake *FakeSpdxImplementation) WarningsCalls(stub func() []spdx.Warning) {
+	fake.warningsMutex.Lock()
+	defer fake.warningsMutex.Unlock()
+	fake.WarningsStub = stub
+}
+
+func (fake *FakeSpdxImplementation) WarningsReturns(result1 []spdx.Warning) {
+	fake.warningsMutex.Lock()
+	defer fake.warningsMutex.Unlock()
+	fake.WarningsStub = nil
+	fake.warningsReturns = struct {
+		result1 []spdx.Warning
+	}{result1}
+}
+
+func (fake *FakeSpdxImplementation) WarningsReturnsOnCall(i int, result1 []spdx.Warning) {
+	fake.warningsMutex.Lock()
+	defer fake.warningsMutex.Unlock()
+	fake.WarningsStub = nil
+	if fake.warningsReturnsOnCall == nil {
+		fake.warningsReturnsOnCall = make(map[int]struct {
+			result1 []spdx.Warning
+		})
+	}
+	fake.warningsReturnsOnCall[i] = struct {
+		result1 []spdx.Warning
+	}{result1}
+}
+
 func (fake *FakeSpdxImplementation) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -1146,30 +2336,60 @@ func (fake *FakeSpdxImplementation) Invocations() map[string][][]interface{} {
 	defer fake.analyzeImageLayerMutex.RUnlock()
 	fake.applyIgnorePatternsMutex.RLock()
 	defer fake.applyIgnorePatternsMutex.RUnlock()
+	fake.extractLayersTmpMutex.RLock()
+	defer fake.extractLayersTmpMutex.RUnlock()
 	fake.extractTarballTmpMutex.RLock()
 	defer fake.extractTarballTmpMutex.RUnlock()
+	fake.extractZipTmpMutex.RLock()
+	defer fake.extractZipTmpMutex.RUnlock()
 	fake.getDirectoryLicenseMutex.RLock()
 	defer fake.getDirectoryLicenseMutex.RUnlock()
 	fake.getDirectoryTreeMutex.RLock()
 	defer fake.getDirectoryTreeMutex.RUnlock()
 	fake.getGoDependenciesMutex.RLock()
 	defer fake.getGoDependenciesMutex.RUnlock()
+	fake.getGoDependenciesWithErrorsMutex.RLock()
+	defer fake.getGoDependenciesWithErrorsMutex.RUnlock()
+	fake.getNPMDependenciesMutex.RLock()
+	defer fake.getNPMDependenciesMutex.RUnlock()
+	fake.getPythonDependenciesMutex.RLock()
+	defer fake.getPythonDependenciesMutex.RUnlock()
 	fake.ignorePatternsMutex.RLock()
 	defer fake.ignorePatternsMutex.RUnlock()
+	fake.imageOSPackagesMutex.RLock()
+	defer fake.imageOSPackagesMutex.RUnlock()
 	fake.imageRefToPackageMutex.RLock()
 	defer fake.imageRefToPackageMutex.RUnlock()
 	fake.licenseReaderMutex.RLock()
 	defer fake.licenseReaderMutex.RUnlock()
+	fake.packageFromContentStoreMutex.RLock()
+	defer fake.packageFromContentStoreMutex.RUnlock()
 	fake.packageFromDirectoryMutex.RLock()
 	defer fake.packageFromDirectoryMutex.RUnlock()
+	fake.packageFromFileMutex.RLock()
+	defer fake.packageFromFileMutex.RUnlock()
+	fake.packageFromFilesystemImageMutex.RLock()
+	defer fake.packageFromFilesystemImageMutex.RUnlock()
+	fake.packageFromGoBinaryMutex.RLock()
+	defer fake.packageFromGoBinaryMutex.RUnlock()
+	fake.packageFromImageFilesMutex.RLock()
+	defer fake.packageFromImageFilesMutex.RUnlock()
 	fake.packageFromImageTarballMutex.RLock()
 	defer fake.packageFromImageTarballMutex.RUnlock()
 	fake.packageFromTarballMutex.RLock()
 	defer fake.packageFromTarballMutex.RUnlock()
+	fake.packageFromZipMutex.RLock()
+	defer fake.packageFromZipMutex.RUnlock()
 	fake.pullImagesToArchiveMutex.RLock()
 	defer fake.pullImagesToArchiveMutex.RUnlock()
 	fake.readArchiveManifestMutex.RLock()
 	defer fake.readArchiveManifestMutex.RUnlock()
+	fake.readArchiveManifestsMutex.RLock()
+	defer fake.readArchiveManifestsMutex.RUnlock()
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
+	fake.warningsMutex.RLock()
+	defer fake.warningsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
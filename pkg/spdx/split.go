@@ -0,0 +1,216 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Split returns a standalone document for each top level package of the
+// document, in the order of their IDs. Each document describes one package
+// and contains the whole tree of elements related to it. Relationships
+// pointing to another top level package are rewritten to reference the
+// document holding it through an ExternalDocumentRef, so the set of split
+// documents preserves all the relationships of the original.
+//
+// Files listed directly on the document are not part of any package and
+// are not included in the split documents. The original document is not
+// modified.
+func (d *Document) Split() []*Document {
+	ids := d.sortedPackageIDs()
+	roots := map[Object]int{}
+	for i, id := range ids {
+		roots[d.Packages[id]] = i
+	}
+
+	// Without a namespace, the split documents get one derived from the
+	// contents of the document, the same one when splitting it again
+	namespace := d.Namespace
+	if namespace == "" {
+		seed, err := d.contentSeed()
+		if err != nil {
+			logrus.Warnf("Using a random namespace for the split documents: %v", err)
+			seed = uuid.NewString()
+		}
+		namespace = "https://spdx.org/spdxdocs/k8s-releng-bom-" + reproducibleUUID(seed)
+	}
+
+	docs := make([]*Document, len(ids))
+	targets := make([]map[int]struct{}, len(ids))
+	for i, id := range ids {
+		root := d.Packages[id]
+		s := &splitter{roots: roots, root: i, copies: map[Object]Object{}, targets: map[int]struct{}{}}
+		pkg, ok := s.copyObject(root).(*Package)
+		if !ok {
+			continue
+		}
+
+		name := root.Name
+		if name == "" {
+			name = root.SPDXID()
+		}
+		if d.Name != "" {
+			name = d.Name + "-" + root.Name
+		}
+		doc := &Document{
+			Version:            d.Version,
+			DataLicense:        d.DataLicense,
+			ID:                 d.ID,
+			Name:               name,
+			Namespace:          namespace + "/" + root.SPDXID(),
+			Creator:            d.Creator,
+			Created:            d.Created,
+			LicenseListVersion: d.LicenseListVersion,
+			Packages:           map[string]*Package{pkg.SPDXID(): pkg},
+			ExternalDocRefs:    append([]ExternalDocumentRef{}, d.ExternalDocRefs...),
+		}
+		if doc.ID == "" {
+			doc.ID = "SPDXRef-DOCUMENT"
+		}
+		docs[i] = doc
+		targets[i] = s.targets
+	}
+
+	// The references to other documents carry their checksums, so the
+	// referenced documents are completed before the ones pointing to them
+	checksums := make([]string, len(docs))
+	state := make([]int, len(docs)) // 0: pending, 1: in progress, 2: done
+	var complete func(i int)
+	complete = func(i int) {
+		state[i] = 1
+		refs := make([]int, 0, len(targets[i]))
+		for j := range targets[i] {
+			refs = append(refs, j)
+		}
+		sort.Ints(refs)
+		for _, j := range refs {
+			if state[j] == 0 {
+				complete(j)
+			}
+			if state[j] == 1 {
+				// Documents referencing each other cannot all carry the
+				// final checksum of their peers. The checksum is computed
+				// leaving out the references still missing.
+				logrus.Warnf(
+					"Split documents %s and %s reference each other, the checksum of %s leaves out its pending references",
+					docs[i].Name, docs[j].Name, docs[j].Name,
+				)
+				checksums[j] = splitDocumentChecksum(docs[j])
+			}
+			docs[i].ExternalDocRefs = append(docs[i].ExternalDocRefs, ExternalDocumentRef{
+				ID:        splitDocumentRefID(docs[j]),
+				URI:       docs[j].Namespace,
+				Checksums: map[string]string{"SHA1": checksums[j]},
+			})
+		}
+		checksums[i] = splitDocumentChecksum(docs[i])
+		state[i] = 2
+	}
+	for i := range docs {
+		if docs[i] != nil && state[i] == 0 {
+			complete(i)
+		}
+	}
+
+	split := []*Document{}
+	for _, doc := range docs {
+		if doc != nil {
+			split = append(split, doc)
+		}
+	}
+	return split
+}
+
+// splitDocumentRefID returns the ID used to reference a split document
+// from the others. It is built from the ID of the package it describes.
+func splitDocumentRefID(doc *Document) string {
+	for id := range doc.Packages {
+		return strings.TrimPrefix(id, "SPDXRef-")
+	}
+	return ""
+}
+
+// splitDocumentChecksum returns the SHA1 of the rendered document. External
+// references still lacking a checksum are left out of the render.
+func splitDocumentChecksum(doc *Document) string {
+	render := *doc
+	render.ExternalDocRefs = []ExternalDocumentRef{}
+	for _, ref := range doc.ExternalDocRefs {
+		if ref.String() != "" {
+			render.ExternalDocRefs = append(render.ExternalDocRefs, ref)
+		}
+	}
+	content, err := render.Render()
+	if err != nil {
+		logrus.Warnf("Rendering split document %s to compute its checksum: %v", doc.Name, err)
+		return ""
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(content)))
+}
+
+// splitter copies the tree of a top level package for a split document
+type splitter struct {
+	roots   map[Object]int    // Top level packages of the original document
+	root    int               // Index of the package being copied
+	copies  map[Object]Object // Elements already copied
+	targets map[int]struct{}  // Top level packages referenced from the tree
+}
+
+// copyObject returns a copy of a package or file whose relationships
+// point to copies of their peers. Relationships to other top level
+// packages become references to the documents holding them.
+func (s *splitter) copyObject(o Object) Object {
+	if cp, ok := s.copies[o]; ok {
+		return cp
+	}
+
+	var cp Object
+	var entity *Entity
+	switch e := o.(type) {
+	case *Package:
+		p := copyPackage(e)
+		cp, entity = p, &p.Entity
+	case *File:
+		f := copyFile(e)
+		cp, entity = f, &f.Entity
+	default:
+		return o
+	}
+	s.copies[o] = cp
+
+	for _, rel := range entity.Relationships {
+		if rel == nil || rel.Peer == nil {
+			continue
+		}
+		if i, ok := s.roots[rel.Peer]; ok && i != s.root {
+			rel.FullRender = false
+			rel.PeerReference = rel.Peer.SPDXID()
+			rel.PeerExtReference = strings.TrimPrefix(rel.Peer.SPDXID(), "SPDXRef-")
+			rel.Peer = nil
+			s.targets[i] = struct{}{}
+		} else {
+			rel.Peer = s.copyObject(rel.Peer)
+		}
+	}
+	return cp
+}
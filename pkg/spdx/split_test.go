@@ -0,0 +1,138 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	newPkg := func(name string) *Package {
+		p := NewPackage()
+		p.Name = name
+		p.BuildID(name)
+		return p
+	}
+	app, lib, tool, shared := newPkg("app"), newPkg("lib"), newPkg("tool"), newPkg("shared")
+	f := NewFile()
+	f.Name = "main.go"
+	f.FileName = "main.go"
+	require.NoError(t, app.AddFile(f))
+	require.NoError(t, app.AddPackage(shared))
+	require.NoError(t, lib.AddPackage(shared))
+	app.AddRelationship(&Relationship{Peer: lib, Type: DEPENDS_ON})
+	tool.AddRelationship(&Relationship{Peer: app, Type: BUILD_TOOL_OF})
+
+	doc := NewDocument()
+	doc.Name = "combined"
+	doc.Namespace = "https://example.com/combined"
+	for _, p := range []*Package{app, lib, tool} {
+		require.NoError(t, doc.AddPackage(p))
+	}
+	original, err := doc.Render()
+	require.NoError(t, err)
+
+	docs := doc.Split()
+	require.Len(t, docs, 3)
+	byName := map[string]*Document{}
+	for _, d := range docs {
+		require.NoError(t, d.ValidateDescribes())
+		require.Len(t, d.DescribedIDs(), 1)
+		byName[d.Name] = d
+	}
+	appDoc, libDoc, toolDoc := byName["combined-app"], byName["combined-lib"], byName["combined-tool"]
+	require.NotNil(t, appDoc)
+	require.NotNil(t, libDoc)
+	require.NotNil(t, toolDoc)
+	require.Equal(t, "https://example.com/combined/"+app.SPDXID(), appDoc.Namespace)
+
+	// The subtree of each package is kept whole, shared packages are
+	// copied to every document including them
+	require.NotNil(t, appDoc.GetElementByID(f.SPDXID()))
+	require.NotNil(t, appDoc.GetElementByID(shared.SPDXID()))
+	require.NotNil(t, libDoc.GetElementByID(shared.SPDXID()))
+	require.Nil(t, appDoc.GetElementByID(lib.SPDXID()))
+	require.Nil(t, toolDoc.GetElementByID(app.SPDXID()))
+
+	// Relationships to other top level packages point to the
+	// documents holding them, with their checksums
+	renders := map[*Document]string{}
+	for _, d := range docs {
+		out, err := d.Render()
+		require.NoError(t, err)
+		renders[d] = out
+	}
+	for _, tc := range []struct {
+		from, to *Document
+		rel      string
+	}{
+		{appDoc, libDoc, fmt.Sprintf("Relationship: %s DEPENDS_ON DocumentRef-Package-lib:%s\n", app.SPDXID(), lib.SPDXID())},
+		{toolDoc, appDoc, fmt.Sprintf("Relationship: %s BUILD_TOOL_OF DocumentRef-Package-app:%s\n", tool.SPDXID(), app.SPDXID())},
+	} {
+		require.Contains(t, renders[tc.from], tc.rel)
+		require.Len(t, tc.from.ExternalDocRefs, 1)
+		ref := tc.from.ExternalDocRefs[0]
+		require.Equal(t, tc.to.Namespace, ref.URI)
+		require.Equal(t, fmt.Sprintf("%x", sha1.Sum([]byte(renders[tc.to]))), ref.Checksums["SHA1"])
+		require.Contains(t, renders[tc.from], "ExternalDocumentRef:"+ref.String()+"\n")
+	}
+	require.Empty(t, libDoc.ExternalDocRefs)
+
+	// The split documents parse back as standalone documents
+	for _, d := range docs {
+		path := filepath.Join(t.TempDir(), "split.spdx")
+		require.NoError(t, d.Write(path))
+		parsed, err := OpenDoc(path)
+		require.NoError(t, err)
+		require.NoError(t, parsed.ValidateDescribes())
+		require.Equal(t, d.DescribedIDs(), parsed.DescribedIDs())
+	}
+
+	// The original document is left untouched
+	after, err := doc.Render()
+	require.NoError(t, err)
+	require.Equal(t, original, after)
+
+	// The copies keep the fields not rendered, and their slices and
+	// maps are not shared with the originals
+	shared.dependencyType = DEV_DEPENDENCY_OF
+	shared.directoryLicenses = []string{"MIT"}
+	shared.Plan = &ScanPlan{}
+	shared.Checksum = map[string]string{"SHA1": "da39a3ee5e6b4b0d3255bfef95601890afd80709"}
+	copies := 0
+	for _, d := range doc.Split() {
+		cp, ok := d.GetElementByID(shared.SPDXID()).(*Package)
+		if !ok {
+			continue
+		}
+		copies++
+		require.NotSame(t, shared, cp)
+		require.Equal(t, DEV_DEPENDENCY_OF, cp.dependencyType)
+		require.Equal(t, []string{"MIT"}, cp.directoryLicenses)
+		require.Same(t, shared.Plan, cp.Plan)
+		cp.Checksum["SHA1"] = "changed"
+		cp.directoryLicenses[0] = "changed"
+	}
+	require.Equal(t, 2, copies)
+	require.Equal(t, "da39a3ee5e6b4b0d3255bfef95601890afd80709", shared.Checksum["SHA1"])
+	require.Equal(t, []string{"MIT"}, shared.directoryLicenses)
+}
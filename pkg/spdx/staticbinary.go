@@ -0,0 +1,262 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"runtime/debug"
+	"strings"
+)
+
+// maxStaticImageFiles is the most files an image can have to be handled
+// as a static binary built FROM scratch or on a distroless base
+const maxStaticImageFiles = 32
+
+// maxGoBinarySize is the size of the largest executable of a layer whose
+// Go build information is read
+const maxGoBinarySize = 1 << 30
+
+// staticImagePackageDBs are the paths where the package managers keep
+// their databases. Images with any of them are not static.
+var staticImagePackageDBs = []string{
+	"var/lib/dpkg/", "lib/apk/db/", "usr/lib/apk/db/", "var/lib/rpm/", "usr/lib/sysimage/rpm/",
+}
+
+// staticBinary is a Go binary found in a static image, when the options
+// ask for them, or in any image when the options ask for Go binaries
+type staticBinary struct {
+	Layer    int                  // Index of the layer holding the binary
+	Path     string               // Path of the binary in the image
+	Size     int64                // Size of the binary
+	Checksum map[string]string    // SHA1 and SHA256 of the binary
+	Info     *buildinfo.BuildInfo // Build information embedded in the binary
+}
+
+// newStaticBinary describes the Go binary at path. Its size bytes are
+// streamed from r to read the build information and the checksums, they
+// are not kept in memory.
+func newStaticBinary(binaryPath string, r io.ReaderAt, size int64) (staticBinary, error) {
+	info, err := buildinfo.Read(r)
+	if err != nil {
+		return staticBinary{}, fmt.Errorf("reading Go build information: %w", err)
+	}
+	sha1Hash, sha256Hash := sha1.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(sha1Hash, sha256Hash), io.NewSectionReader(r, 0, size)); err != nil {
+		return staticBinary{}, fmt.Errorf("hashing binary: %w", err)
+	}
+	return staticBinary{
+		Path: binaryPath,
+		Size: size,
+		Checksum: map[string]string{
+			"SHA1":   hexSum(sha1Hash),
+			"SHA256": hexSum(sha256Hash),
+		},
+		Info: info,
+	}, nil
+}
+
+// hexSum returns the hex encoded sum of h
+func hexSum(h hash.Hash) string {
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// findStaticBinaries checks if the image made of the layers follows the
+// FROM scratch pattern: a few files, no package database and at least one
+// Go binary. It returns the Go binaries found in it, or nil when the image
+// does not match the pattern.
+func findStaticBinaries(opts *Options, layerPaths []string) ([]staticBinary, error) {
+	numFiles := 0
+	for _, layerPath := range layerPaths {
+		static, n, err := scanStaticLayer(layerPath, maxStaticImageFiles-numFiles)
+		if err != nil {
+			return nil, err
+		}
+		if !static {
+			return nil, nil
+		}
+		numFiles += n
+	}
+
+	return findLayerGoBinaries(opts, layerPaths)
+}
+
+// findLayerGoBinaries returns the Go binaries in the layers, or nil if
+// there are none
+func findLayerGoBinaries(opts *Options, layerPaths []string) ([]staticBinary, error) {
+	binaries := []staticBinary{}
+	for i, layerPath := range layerPaths {
+		layerBinaries, err := readLayerGoBinaries(opts, layerPath)
+		if err != nil {
+			return nil, err
+		}
+		for j := range layerBinaries {
+			layerBinaries[j].Layer = i
+		}
+		binaries = append(binaries, layerBinaries...)
+	}
+	if len(binaries) == 0 {
+		return nil, nil
+	}
+	return binaries, nil
+}
+
+// scanStaticLayer reads the headers of a layer and returns false if it
+// holds a package database or more than maxFiles files
+func scanStaticLayer(layerPath string, maxFiles int) (static bool, numFiles int, err error) {
+	f, err := os.Open(layerPath)
+	if err != nil {
+		return false, 0, fmt.Errorf("opening layer: %w", err)
+	}
+	defer f.Close()
+	tr, err := newTarReader(f)
+	if err != nil {
+		return false, 0, err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return true, numFiles, nil
+		}
+		if err != nil {
+			return false, 0, fmt.Errorf("reading layer %s: %w", layerPath, err)
+		}
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "/")
+		for _, db := range staticImagePackageDBs {
+			if strings.HasPrefix(name+"/", db) {
+				return false, numFiles, nil
+			}
+		}
+		if hdr.FileInfo().Mode().IsRegular() {
+			numFiles++
+			if numFiles > maxFiles {
+				return false, numFiles, nil
+			}
+		}
+	}
+}
+
+// readLayerGoBinaries returns the executables of a layer with Go build
+// information
+func readLayerGoBinaries(opts *Options, layerPath string) ([]staticBinary, error) {
+	binaries := []staticBinary{}
+	err := readLayerExecutables(opts, layerPath, maxGoBinarySize, func(name string, r io.ReaderAt, size int64) error {
+		bin, err := newStaticBinary(name, r, size)
+		if err != nil {
+			logger(opts).Debugf("%s is not a Go binary: %v", name, err)
+			return nil
+		}
+		binaries = append(binaries, bin)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return binaries, nil
+}
+
+// spdxPackage builds a package for the main module of the binary. The
+// package contains the binary file and depends on the modules compiled
+// into it, as recorded in its build information. The repositories of the
+// modules are resolved with resolver.
+func (sb *staticBinary) spdxPackage(resolver *goRepositoryResolver) (*Package, error) {
+	mainPkg := goModulePackage(resolver, &sb.Info.Main)
+	if sb.Info.Main.Path == "" {
+		mainPkg.Name = sb.Info.Path
+		mainPkg.BuildID(sb.Info.Path)
+	}
+	mainPkg.Comment = fmt.Sprintf("Go module of binary %s built with %s", sb.Path, sb.Info.GoVersion)
+	mainPkg.PrimaryPurpose = "APPLICATION"
+	mainPkg.SourceInfo = goVCSSourceInfo(sb.Info.Settings)
+
+	binFile := NewFile()
+	binFile.Name = sb.Path
+	binFile.FileName = sb.Path
+	binFile.FileType = []string{"BINARY"}
+	binFile.Size = sb.Size
+	binFile.Checksum = sb.Checksum
+	if err := mainPkg.AddFile(binFile); err != nil {
+		return nil, fmt.Errorf("adding binary to package: %w", err)
+	}
+
+	for _, dep := range sb.Info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		depPkg := goModulePackage(resolver, dep)
+		depPkg.PrimaryPurpose = "LIBRARY"
+		if err := mainPkg.AddDependency(depPkg); err != nil {
+			return nil, fmt.Errorf("adding dependency %s: %w", dep.Path, err)
+		}
+	}
+	return mainPkg, nil
+}
+
+// goVCSSourceInfo describes the commit a binary was built from, as
+// stamped by the go command in the vcs build settings. It returns an
+// empty string if the binary has no VCS stamp.
+func goVCSSourceInfo(settings []debug.BuildSetting) string {
+	vcs := map[string]string{}
+	for _, s := range settings {
+		vcs[s.Key] = s.Value
+	}
+	if vcs["vcs"] == "" || vcs["vcs.revision"] == "" {
+		return ""
+	}
+	info := fmt.Sprintf("Built from %s revision %s", vcs["vcs"], vcs["vcs.revision"])
+	if vcs["vcs.time"] != "" {
+		info += " committed at " + vcs["vcs.time"]
+	}
+	if vcs["vcs.modified"] == "true" {
+		info += ", with uncommitted changes"
+	}
+	return info
+}
+
+// goModulePackage builds the package of a module compiled into a binary
+func goModulePackage(resolver *goRepositoryResolver, mod *debug.Module) *Package {
+	goPkg := &GoPackage{ImportPath: mod.Path, Revision: mod.Version}
+	if mod.Version == "(devel)" {
+		goPkg.Revision = ""
+	}
+
+	spdxPackage := NewPackage()
+	spdxPackage.Options().Prefix = "gomod"
+	spdxPackage.Name = mod.Path
+	spdxPackage.Version = goPkg.Revision
+	spdxPackage.BuildID(mod.Path, goPkg.Revision)
+	spdxPackage.DownloadLocation = goDownloadLocation(resolver, mod.Path, goPkg.Revision)
+	// The h1: sum hashes the list of the files of the module, it is not a
+	// checksum of a downloadable artifact, so it is recorded as is
+	if mod.Sum != "" {
+		spdxPackage.AddAnnotation(newToolAnnotation(goModuleSumAnnotation + mod.Sum))
+	}
+	if packageurl := goPkg.PackageURL(); packageurl != "" {
+		spdxPackage.ExternalRefs = append(spdxPackage.ExternalRefs, ExternalRef{
+			Category: CatPackageManager,
+			Type:     "purl",
+			Locator:  packageurl,
+		})
+	}
+	return spdxPackage
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromImageTarballStaticBinary(t *testing.T) {
+	// A scratch image holding one Go binary, the test binary itself
+	binPath, err := os.Executable()
+	require.NoError(t, err)
+	binData, err := os.ReadFile(binPath)
+	require.NoError(t, err)
+	info, err := buildinfo.ReadFile(binPath)
+	require.NoError(t, err)
+	require.NotEmpty(t, info.Deps)
+
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name: "app", Mode: 0o755, Size: int64(len(binData)), Typeflag: tar.TypeReg,
+	}))
+	_, err = tw.Write(binData)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	tarPath := writeTestDockerArchive(t, []string{"layer1/layer.tar"}, [][]byte{layer.Bytes()})
+
+	// Without the option the binary is not described
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromImageTarball(&Options{ScanImages: true}, tarPath)
+	require.NoError(t, err)
+	layers := containedPackages(pkg)
+	require.Len(t, layers, 1)
+	require.Empty(t, containedPackages(layers[0]))
+
+	pkg, err = impl.PackageFromImageTarball(&Options{ScanImages: true, ScanStaticImages: true}, tarPath)
+	require.NoError(t, err)
+
+	var layerPkg *Package
+	for _, rel := range pkg.Relationships {
+		if p, ok := rel.Peer.(*Package); ok {
+			layerPkg = p
+		}
+	}
+	require.NotNil(t, layerPkg)
+	require.Len(t, layerPkg.Relationships, 1)
+	mainPkg, ok := layerPkg.Relationships[0].Peer.(*Package)
+	require.True(t, ok)
+	require.Equal(t, "sigs.k8s.io/bom", mainPkg.Name)
+
+	deps := map[string]string{}
+	for _, rel := range mainPkg.Relationships {
+		switch peer := rel.Peer.(type) {
+		case *File:
+			require.Equal(t, CONTAINS, rel.Type)
+			require.Equal(t, "app", peer.Name)
+			require.Equal(t, fmt.Sprintf("%x", sha256.Sum256(binData)), peer.Checksum["SHA256"])
+		case *Package:
+			require.Equal(t, DEPENDS_ON, rel.Type)
+			deps[peer.Name] = peer.Version
+		}
+	}
+	require.Len(t, deps, len(info.Deps))
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		require.Contains(t, deps, dep.Path)
+		if dep.Version != "(devel)" {
+			require.Equal(t, dep.Version, deps[dep.Path])
+		}
+	}
+}
+
+func TestGoVCSSourceInfo(t *testing.T) {
+	require.Empty(t, goVCSSourceInfo(nil))
+	require.Empty(t, goVCSSourceInfo([]debug.BuildSetting{{Key: "vcs", Value: "git"}}))
+	require.Equal(t,
+		"Built from git revision 0123abc committed at 2023-05-01T10:00:00Z, with uncommitted changes",
+		goVCSSourceInfo([]debug.BuildSetting{
+			{Key: "-trimpath", Value: "true"},
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "0123abc"},
+			{Key: "vcs.time", Value: "2023-05-01T10:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		}),
+	)
+	require.Equal(t, "Built from hg revision 42", goVCSSourceInfo([]debug.BuildSetting{
+		{Key: "vcs", Value: "hg"}, {Key: "vcs.revision", Value: "42"}, {Key: "vcs.modified", Value: "false"},
+	}))
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds the time spent in each phase of the scans. The durations of
+// a phase running in parallel (eg hashing the files of a directory) are
+// added up, so they can exceed the time the scan took.
+type Stats struct {
+	Download              time.Duration // Pulling images from registries
+	Extract               time.Duration // Extracting tarballs and image layers
+	OSScan                time.Duration // Reading the OS package databases of images
+	FileScan              time.Duration // Reading and hashing files
+	LicenseClassification time.Duration // Classifying the licenses of files and directories
+}
+
+// scanPhase identifies one of the phases timed in the Stats
+type scanPhase int
+
+const (
+	phaseDownload scanPhase = iota
+	phaseExtract
+	phaseOSScan
+	phaseFileScan
+	phaseLicenseClassification
+	numScanPhases
+)
+
+// statsRecorder accumulates the duration of the scan phases when the
+// options enable CollectStats. It is safe for concurrent use.
+type statsRecorder struct {
+	durations [numScanPhases]int64
+}
+
+// start begins timing a phase and returns the function that stops it.
+// When stats are not collected, no clock is read.
+func (sr *statsRecorder) start(opts *Options, phase scanPhase) (stop func()) {
+	if opts == nil || !opts.CollectStats {
+		return func() {}
+	}
+	begin := time.Now()
+	return func() {
+		atomic.AddInt64(&sr.durations[phase], int64(time.Since(begin)))
+	}
+}
+
+// stats returns the durations recorded so far
+func (sr *statsRecorder) stats() Stats {
+	d := func(phase scanPhase) time.Duration {
+		return time.Duration(atomic.LoadInt64(&sr.durations[phase]))
+	}
+	return Stats{
+		Download:              d(phaseDownload),
+		Extract:               d(phaseExtract),
+		OSScan:                d(phaseOSScan),
+		FileScan:              d(phaseFileScan),
+		LicenseClassification: d(phaseLicenseClassification),
+	}
+}
+
+// Stats returns the time spent in each phase by the implementation
+func (di *spdxDefaultImplementation) Stats() Stats {
+	return di.stats.stats()
+}
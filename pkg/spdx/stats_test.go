@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	layers := []v1.Layer{}
+	for _, lf := range []string{
+		"../osinfo/testdata/link-with-no-dots.tar.gz",
+		"../osinfo/testdata/dpkg-layer1.tar.gz",
+	} {
+		layer, err := tarball.LayerFromFile(lf)
+		require.NoError(t, err)
+		layers = append(layers, layer)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/test/image:v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	// Nothing is recorded unless the option is set
+	impl := spdxDefaultImplementation{}
+	_, err = impl.ImageRefToPackage(context.Background(), ref.String(), &Options{ScanImages: true, AddTarFiles: true})
+	require.NoError(t, err)
+	require.Equal(t, Stats{}, impl.Stats())
+
+	_, err = impl.ImageRefToPackage(context.Background(), ref.String(), &Options{
+		ScanImages: true, AddTarFiles: true, CollectStats: true,
+	})
+	require.NoError(t, err)
+	stats := impl.Stats()
+	for phase, d := range map[string]time.Duration{
+		"download":               stats.Download,
+		"extract":                stats.Extract,
+		"os scan":                stats.OSScan,
+		"file scan":              stats.FileScan,
+		"license classification": stats.LicenseClassification,
+	} {
+		require.Positive(t, d, phase)
+	}
+}
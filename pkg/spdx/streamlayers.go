@@ -0,0 +1,263 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/bom/pkg/osinfo"
+)
+
+// packageFromImageTarballStream builds the package of an image archive
+// reading the entries of its layers straight from the tarball. The files
+// of each layer are hashed as they are read, nothing but the manifest and
+// the config of the image is written to disk.
+func (di *spdxDefaultImplementation) packageFromImageTarballStream(
+	spdxOpts *Options, tarPath string,
+) (*Package, error) {
+	algorithms, err := normalizeChecksumAlgorithms(spdxOpts.ChecksumAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	defer di.tempPaths.cleanupOnPanic(spdxOpts)
+	tmpDir, err := di.tempPaths.mkdirTemp(spdxOpts, "spdx-stream-layers-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer di.tempPaths.remove(tmpDir)
+
+	manifests, err := di.readLazyArchiveManifests(tarPath, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	imagePackage := NewPackage()
+	if err := di.cpuLimiter.run(spdxOpts, func() error { return imagePackage.ReadSourceFile(tarPath) }); err != nil {
+		return nil, fmt.Errorf("reading source file %s: %w", tarPath, err)
+	}
+	imagePackage.Name = filepath.Base(tarPath)
+	imagePackage.Comment = "Container image archive"
+
+	if err := describeArchiveImages(spdxOpts, imagePackage, manifests, func(
+		manifest *ArchiveManifest, imagePackage *Package, repoTag string,
+	) error {
+		logger(spdxOpts).Infof("Package describes image %s, streaming its %d layers", repoTag, len(manifest.LayerFiles))
+		config, err := readArchiveConfig(tarPath, tmpDir, manifest)
+		if err != nil {
+			return err
+		}
+		diffIDs := layerVerificationDiffIDs(spdxOpts, config, manifest)
+		progress := newProgressCounter(progressFn(spdxOpts), ProgressPhaseLayerScan, len(manifest.LayerFiles))
+		layerPackages := []*Package{}
+		for i, layerFile := range manifest.LayerFiles {
+			ls := &layerStream{algorithms: algorithms, recordMetadata: spdxOpts.RecordFileMetadata}
+			if diffIDs != nil {
+				ls.diffID = diffIDs[i]
+			}
+			var pkg *Package
+			if err := di.cpuLimiter.run(spdxOpts, func() (err error) {
+				pkg, err = streamLayerPackage(tarPath, layerFile, ls)
+				return err
+			}); err != nil {
+				return fmt.Errorf("streaming layer %d: %w", i, err)
+			}
+			pkg.BuildID(repoTag, pkg.Name)
+			layerPackages = append(layerPackages, pkg)
+			progress.done()
+		}
+
+		if err := addLayerPackages(spdxOpts, imagePackage, layerPackages); err != nil {
+			return err
+		}
+		if config != nil {
+			di.recordImageHistory(spdxOpts, config, manifest.Annotations, imagePackage, layerPackages)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return imagePackage, nil
+}
+
+// streamLayerPackage reads the layer called layerFile in the image archive
+// at tarPath, returning a package listing the regular files in the layer
+// as configured by ls. The layer itself is hashed while it is read.
+func streamLayerPackage(tarPath, layerFile string, ls *layerStream) (*Package, error) {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening tarball: %w", err)
+	}
+	defer f.Close()
+
+	archive, err := newTarReader(f)
+	if err != nil {
+		return nil, err
+	}
+	layerFile = strings.TrimPrefix(layerFile, "./")
+	for {
+		hdr, err := archive.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in %s", layerFile, tarPath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tarfile %s: %w", tarPath, err)
+		}
+		if strings.TrimPrefix(hdr.Name, "./") == layerFile {
+			break
+		}
+	}
+
+	pkg, err := streamLayerBlob(archive, layerFile, ls)
+	if err != nil {
+		return nil, err
+	}
+	pkg.FileName = layerFile
+	pkg.Comment = "Container image layer from archive"
+	return pkg, nil
+}
+
+// layerStream configures how streamLayerBlob reads a layer
+type layerStream struct {
+	algorithms     []string              // Checksums computed for the files
+	recordMetadata bool                  // Annotate the files with the metadata of their entries
+	osScanner      *osinfo.StreamScanner // Scanner passed the entries it wants (optional)
+	layer          int                   // Index of the layer in the image, for the OS scanner
+	diffID         string                // Digest of the uncompressed layer checked when read to the end (optional)
+}
+
+// streamLayerBlob reads the layer blob called name from r, returning a
+// package listing the regular files in the layer with their checksums.
+// The blob itself is hashed while it is read, and its uncompressed
+// contents checked against the diff ID of the layer if known.
+func streamLayerBlob(r io.Reader, name string, ls *layerStream) (*Package, error) {
+	// Hash the layer blob as its entries are read
+	layerHashes, layerHasher, err := newChecksumHashes(defaultChecksumAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+	blob := io.TeeReader(r, layerHasher)
+	contents, err := decompressedStream(blob)
+	if err != nil {
+		return nil, fmt.Errorf("opening layer %s: %w", name, err)
+	}
+	contents = newDiffIDReader(contents, ls.diffID)
+	layer := tar.NewReader(contents)
+
+	files := []*File{}
+	for {
+		hdr, err := layer.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %s: %w", name, err)
+		}
+		var data io.Reader = layer
+		if ls.osScanner != nil && ls.osScanner.Wants(hdr.Name) {
+			// The package databases are read by the OS scanner and
+			// hashed, they are kept in memory
+			buf, err := io.ReadAll(layer)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s from layer %s: %w", hdr.Name, name, err)
+			}
+			if err := ls.osScanner.AddEntry(ls.layer, hdr, bytes.NewReader(buf)); err != nil {
+				return nil, fmt.Errorf("scanning layer %s for OS packages: %w", name, err)
+			}
+			data = bytes.NewReader(buf)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		file, err := streamLayerFile(data, hdr, ls.algorithms)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s from layer %s: %w", hdr.Name, name, err)
+		}
+		if ls.recordMetadata {
+			file.setMetadata(newFileMetadata(hdr.FileInfo().Mode(), hdr.ModTime))
+		}
+		files = append(files, file)
+	}
+	// Read the padding after the last entry, it is part of the layer blob
+	// and of its uncompressed contents
+	if _, err := io.Copy(io.Discard, contents); err != nil {
+		return nil, fmt.Errorf("reading layer %s: %w", name, err)
+	}
+	if _, err := io.Copy(io.Discard, blob); err != nil {
+		return nil, fmt.Errorf("reading layer %s: %w", name, err)
+	}
+
+	pkg := NewPackage()
+	pkg.Checksum = hexChecksums(layerHashes)
+	pkg.Name = "sha256:" + pkg.Checksum["SHA256"]
+	for _, file := range files {
+		if err := pkg.AddFile(file); err != nil {
+			return nil, fmt.Errorf("adding %s to layer package: %w", file.Name, err)
+		}
+	}
+	return pkg, nil
+}
+
+// streamLayerFile describes the file at the current entry of a layer,
+// hashing its contents with the algorithms
+func streamLayerFile(layer io.Reader, hdr *tar.Header, algorithms []string) (*File, error) {
+	return readerFile(strings.TrimPrefix(path.Clean("/"+hdr.Name), "/"), layer, algorithms)
+}
+
+// readerFile describes the file called name whose contents are read from r,
+// hashing them with the algorithms
+func readerFile(name string, r io.Reader, algorithms []string) (*File, error) {
+	// The first bytes are kept to sniff the type of files without extension
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	checksums, err := readerChecksums(io.MultiReader(bytes.NewReader(head[:n]), r), algorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	file := NewFile()
+	file.Name = name
+	file.FileName = file.Name
+	file.Checksum = checksums
+	file.FileType = streamFileTypes(file.Name, head, n)
+	return file, nil
+}
+
+// streamFileTypes returns the SPDX file types of a file read from a stream,
+// from its extension or the n bytes read into head as getFileTypes does
+// on disk
+func streamFileTypes(name string, head []byte, n int) []string {
+	fileExtension := strings.TrimLeft(path.Ext(name), ".")
+	if fileExtension == "" {
+		if n == 0 {
+			return []string{"OTHER"}
+		}
+		fileExtension = contentTypeExtension(http.DetectContentType(head))
+	}
+	return extensionFileTypes(fileExtension)
+}
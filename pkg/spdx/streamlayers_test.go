@@ -0,0 +1,101 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromImageTarballStreamLayers(t *testing.T) {
+	// The layers are stored plain, gzipped and zstd compressed
+	plainLayer := testLayerData(t, 0, 3)
+	var gzLayer, zstdLayer bytes.Buffer
+	gw := gzip.NewWriter(&gzLayer)
+	_, err := gw.Write(testLayerData(t, 1, 4))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	zw, err := zstd.NewWriter(&zstdLayer)
+	require.NoError(t, err)
+	_, err = zw.Write(testLayerData(t, 2, 5))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	layers := [][]byte{plainLayer, gzLayer.Bytes(), zstdLayer.Bytes()}
+	tarPath := writeTestDockerArchive(t,
+		[]string{"plain/layer.tar", "gzip/layer.tar", "zstd/layer.tar"}, layers,
+	)
+
+	// layerFiles returns the checksums of the files in each layer
+	layerFiles := func(pkg *Package) map[string]map[string]map[string]string {
+		files := map[string]map[string]map[string]string{}
+		for _, rel := range pkg.Relationships {
+			layer, ok := rel.Peer.(*Package)
+			if !ok {
+				continue
+			}
+			files[layer.Name] = map[string]map[string]string{}
+			for _, f := range layer.Files() {
+				files[layer.Name][f.Name] = f.Checksum
+			}
+		}
+		return files
+	}
+
+	impl := spdxDefaultImplementation{}
+	streamPkg, err := impl.PackageFromImageTarball(&Options{StreamLayers: true}, tarPath)
+	require.NoError(t, err)
+	eagerPkg, err := impl.PackageFromImageTarball(&Options{AddTarFiles: true}, tarPath)
+	require.NoError(t, err)
+
+	// Streaming describes the same layers and files as extracting them
+	streamed := layerFiles(streamPkg)
+	require.Len(t, streamed, 3)
+	for i, data := range layers {
+		name := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+		require.Len(t, streamed[name], 3+i, name)
+	}
+	require.Equal(t, layerFiles(eagerPkg), streamed)
+	require.Equal(t, eagerPkg.Checksum, streamPkg.Checksum)
+
+	// The checksum algorithms chosen apply to the streamed files
+	sha512Pkg, err := impl.PackageFromImageTarball(
+		&Options{StreamLayers: true, ChecksumAlgorithms: []string{"sha-512"}}, tarPath,
+	)
+	require.NoError(t, err)
+	for _, files := range layerFiles(sha512Pkg) {
+		for name, checksums := range files {
+			require.Len(t, checksums, 2, name)
+			require.Contains(t, checksums, "SHA512", name)
+			require.Contains(t, checksums, "SHA1", name)
+		}
+	}
+
+	// Options needing the layers on disk disable streaming
+	impl = spdxDefaultImplementation{}
+	_, err = impl.PackageFromImageTarball(
+		&Options{StreamLayers: true, AddTarFiles: true, CollectWarnings: true}, tarPath,
+	)
+	require.NoError(t, err)
+	require.NotEmpty(t, impl.Warnings())
+	require.Contains(t, impl.Warnings()[0].Message, "Not streaming the layers")
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+// StripFiles removes the files of the package and of the packages under
+// it, with the relationships pointing to them, leaving a packages-only
+// view of the tree. The packages keep their relationships to each other,
+// their external references (eg purls) and their licensing data. As they
+// have no files left, they are marked as not having their files analyzed,
+// which clears their verification codes and the licenses read from files.
+func (p *Package) StripFiles() {
+	stripFiles([]*Package{p})
+}
+
+// StripFiles removes the files of the document and of all its packages,
+// as Package.StripFiles does. When keepOriginal is true, the document is
+// not modified and a packages-only copy of it is returned instead.
+func (d *Document) StripFiles(keepOriginal bool) *Document {
+	doc := d
+	if keepOriginal {
+		doc = d.copyDocument()
+	}
+	doc.Files = map[string]*File{}
+	roots := make([]*Package, 0, len(doc.Packages))
+	for _, id := range doc.sortedPackageIDs() {
+		roots = append(roots, doc.Packages[id])
+	}
+	stripFiles(roots)
+	return doc
+}
+
+// copyDocument returns a copy of the document with copies of all its
+// elements, so they can be modified without changing the original
+func (d *Document) copyDocument() *Document {
+	doc := *d
+	doc.Packages = make(map[string]*Package, len(d.Packages))
+	doc.Files = make(map[string]*File, len(d.Files))
+	doc.ExternalDocRefs = append([]ExternalDocumentRef{}, d.ExternalDocRefs...)
+	doc.Annotations = append([]Annotation{}, d.Annotations...)
+	doc.Warnings = append([]Warning{}, d.Warnings...)
+
+	// The elements are copied as when splitting documents, with a single
+	// splitter so the elements shared by several packages are copied once
+	s := &splitter{roots: map[Object]int{}, root: -1, copies: map[Object]Object{}, targets: map[int]struct{}{}}
+	for id, p := range d.Packages {
+		doc.Packages[id] = s.copyObject(p).(*Package)
+	}
+	for id, f := range d.Files {
+		doc.Files[id] = s.copyObject(f).(*File)
+	}
+	return &doc
+}
+
+// stripFiles removes the files from the packages in roots and from all the
+// packages related to them. The relationships between the files and other
+// elements are not lost: they are moved to the packages owning the files,
+// eg a package depending on a file of another package depends on that
+// package once stripped.
+func stripFiles(roots []*Package) {
+	// Find the packages of the trees and the owners of their files first,
+	// the relationships referencing files by ID only are rewritten too
+	packages := []*Package{}
+	seen := map[*Package]struct{}{}
+	owners := map[string]*Package{}
+	files := map[string]*File{}
+	var walk func(p *Package)
+	walk = func(p *Package) {
+		if _, ok := seen[p]; ok {
+			return
+		}
+		seen[p] = struct{}{}
+		packages = append(packages, p)
+		p.RLock()
+		rels := append([]*Relationship{}, p.Relationships...)
+		p.RUnlock()
+		for _, rel := range rels {
+			switch peer := rel.Peer.(type) {
+			case *File:
+				if peer == nil || peer.SPDXID() == "" {
+					continue
+				}
+				files[peer.SPDXID()] = peer
+				if _, ok := owners[peer.SPDXID()]; !ok && rel.Type == CONTAINS && !rel.Inverse {
+					owners[peer.SPDXID()] = p
+				}
+			case *Package:
+				if peer != nil {
+					walk(peer)
+				}
+			}
+		}
+	}
+	for _, p := range roots {
+		walk(p)
+	}
+
+	// fileOwner returns the package owning the file a relationship points
+	// to, and whether the relationship points to a file at all
+	fileOwner := func(rel *Relationship) (*Package, bool) {
+		if f, ok := rel.Peer.(*File); ok {
+			if f == nil {
+				return nil, true
+			}
+			return owners[f.SPDXID()], true
+		}
+		if rel.Peer == nil && rel.PeerExtReference == "" {
+			if _, ok := files[rel.PeerReference]; ok {
+				return owners[rel.PeerReference], true
+			}
+		}
+		return nil, false
+	}
+
+	// The relationships kept are collected first, the files are read while
+	// the packages are rewritten
+	kept := map[*Package][]*Relationship{}
+	for _, p := range packages {
+		p.RLock()
+		for _, rel := range p.Relationships {
+			owner, toFile := fileOwner(rel)
+			switch {
+			case !toFile:
+				kept[p] = appendStrippedRelationship(kept[p], p, rel)
+			case rel.Type == CONTAINS && !rel.Inverse:
+				// The files contained are the ones removed
+			case owner != nil && owner != p:
+				kept[p] = appendStrippedRelationship(kept[p], p, retargetRelationship(rel, owner))
+			}
+		}
+		p.RUnlock()
+	}
+	for id, f := range files {
+		from := owners[id]
+		if from == nil {
+			continue
+		}
+		for _, rel := range f.Relationships {
+			owner, toFile := fileOwner(rel)
+			if !toFile {
+				kept[from] = appendStrippedRelationship(kept[from], from, rel)
+			} else if owner != nil && owner != from {
+				kept[from] = appendStrippedRelationship(kept[from], from, retargetRelationship(rel, owner))
+			}
+		}
+	}
+
+	for _, p := range packages {
+		p.Lock()
+		p.Relationships = kept[p]
+		p.FilesAnalyzed = false
+		p.VerificationCode = ""
+		p.LicenseInfoFromFiles = []string{}
+		p.Unlock()
+	}
+}
+
+// retargetRelationship returns a copy of a relationship to a file pointing
+// to the package owning it instead
+func retargetRelationship(rel *Relationship, owner *Package) *Relationship {
+	r := *rel
+	r.Peer = owner
+	r.PeerReference = ""
+	r.PeerExtReference = ""
+	return &r
+}
+
+// appendStrippedRelationship adds a relationship of host to rels, unless
+// it points to host itself or rels already has the same relationship
+func appendStrippedRelationship(rels []*Relationship, host *Package, rel *Relationship) []*Relationship {
+	if rel.Peer == Object(host) {
+		return rels
+	}
+	for _, r := range rels {
+		if r.Type == rel.Type && r.Inverse == rel.Inverse && r.PeerExtReference == rel.PeerExtReference &&
+			r.PeerReference == rel.PeerReference && r.Peer == rel.Peer {
+			return rels
+		}
+	}
+	return append(rels, rel)
+}
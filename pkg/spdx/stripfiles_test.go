@@ -0,0 +1,100 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripFiles(t *testing.T) {
+	newFile := func(name, sha1 string) *File {
+		f := NewFile()
+		f.Name = name
+		f.BuildID("image", name)
+		f.Checksum = map[string]string{"SHA1": sha1}
+		f.LicenseInfoInFile = "MIT"
+		return f
+	}
+	doc := NewDocument()
+	doc.Name = "image"
+	image := NewPackage()
+	image.Name = "image"
+	image.BuildID("image")
+	image.FilesAnalyzed = true
+	mainFile := newFile("main.go", strings.Repeat("a", 40))
+	require.NoError(t, image.AddFile(mainFile))
+
+	layer := NewPackage()
+	layer.Name = "layer"
+	layer.BuildID("image", "layer")
+	layer.FilesAnalyzed = true
+	require.NoError(t, layer.AddFile(newFile("etc/os-release", strings.Repeat("b", 40))))
+	// A relationship to a file by its ID only
+	layer.AddRelationship(&Relationship{PeerReference: mainFile.SPDXID(), Type: DEPENDS_ON})
+	require.NoError(t, image.AddPackage(layer))
+
+	osPackage := NewPackage()
+	osPackage.Name = "base-files"
+	osPackage.BuildID("image", "base-files")
+	osPackage.ExternalRefs = []ExternalRef{{Category: CatPackageManager, Type: "purl", Locator: "pkg:deb/debian/base-files@12.4"}}
+	require.NoError(t, layer.AddPackage(osPackage))
+	// A relationship of a file to a package
+	mainFile.AddRelationship(&Relationship{Peer: osPackage, Type: DEPENDS_ON})
+	require.NoError(t, doc.AddPackage(image))
+	require.NoError(t, doc.AddFile(newFile("README", strings.Repeat("c", 40))))
+
+	original, err := doc.Render()
+	require.NoError(t, err)
+	require.Contains(t, original, "FileName: main.go")
+	require.Contains(t, original, "PackageVerificationCode: ")
+
+	// The copy has no files, the original is not modified
+	stripped := doc.StripFiles(true)
+	require.NotSame(t, doc, stripped)
+	render, err := doc.Render()
+	require.NoError(t, err)
+	require.Equal(t, original, render)
+	require.Len(t, image.Files(), 1)
+
+	render, err = stripped.Render()
+	require.NoError(t, err)
+	require.NotContains(t, render, "FileName: ")
+	require.NotContains(t, render, "PackageVerificationCode: ")
+	require.NotContains(t, render, "PackageLicenseInfoFromFiles: ")
+	require.NotContains(t, render, mainFile.SPDXID())
+	require.Contains(t, render, "FilesAnalyzed: false")
+	require.Contains(t, render, "Relationship: "+image.SPDXID()+" CONTAINS "+layer.SPDXID())
+	require.Contains(t, render, "Relationship: "+layer.SPDXID()+" CONTAINS "+osPackage.SPDXID())
+	require.Contains(t, render, "ExternalRef: PACKAGE-MANAGER purl pkg:deb/debian/base-files@12.4")
+	require.Empty(t, stripped.Files)
+
+	// The relationships of the files move to the packages owning them
+	require.Contains(t, render, "Relationship: "+layer.SPDXID()+" DEPENDS_ON "+image.SPDXID())
+	require.Contains(t, render, "Relationship: "+image.SPDXID()+" DEPENDS_ON "+osPackage.SPDXID())
+
+	// Stripping in place modifies the document itself
+	require.Same(t, doc, doc.StripFiles(false))
+	require.Empty(t, image.Files())
+	require.Empty(t, layer.Files())
+	require.False(t, image.FilesAnalyzed)
+	render, err = doc.Render()
+	require.NoError(t, err)
+	require.NotContains(t, render, "FileName: ")
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// entityRegExp matches the SPDX form of package suppliers and originators:
+// "Person: name (email)" or "Organization: name (email)", the email being
+// optional
+var entityRegExp = regexp.MustCompile(`^(Person|Organization):\s*([^()]*[^()\s])\s*(\(([^()]*)\))?$`)
+
+// parseEntity returns the type (Person or Organization) and the name,
+// with its email when it has one, of a supplier or originator
+func parseEntity(value string) (kind, name string, err error) {
+	match := entityRegExp.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return "", "", fmt.Errorf(
+			"invalid entity %q, it must be 'Organization: name' or 'Person: name (email)'", value,
+		)
+	}
+	name = match[2]
+	if match[3] != "" {
+		email := strings.TrimSpace(match[4])
+		if email != "" && !strings.Contains(email, "@") {
+			return "", "", fmt.Errorf("invalid email %q in entity %q", email, value)
+		}
+		name += " (" + email + ")"
+	}
+	return match[1], name, nil
+}
+
+// applyDefaultEntities sets the default supplier and originator of the
+// options on the package when it has none of its own. Malformed defaults
+// are an error even when the package does not need them.
+func applyDefaultEntities(opts *Options, pkg *Package) error {
+	for _, def := range []struct {
+		what, value          string
+		person, organization *string
+	}{
+		{"supplier", opts.DefaultSupplier, &pkg.Supplier.Person, &pkg.Supplier.Organization},
+		{"originator", opts.DefaultOriginator, &pkg.Originator.Person, &pkg.Originator.Organization},
+	} {
+		if def.value == "" {
+			continue
+		}
+		kind, name, err := parseEntity(def.value)
+		if err != nil {
+			return fmt.Errorf("parsing default %s: %w", def.what, err)
+		}
+		if *def.person != "" || *def.organization != "" {
+			continue
+		}
+		if kind == entPerson {
+			*def.person = name
+		} else {
+			*def.organization = name
+		}
+	}
+	return nil
+}
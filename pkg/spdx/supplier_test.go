@@ -0,0 +1,82 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEntity(t *testing.T) {
+	for _, tc := range []struct {
+		value, kind, name string
+		shouldErr         bool
+	}{
+		{"Organization: Example Inc.", entOrganization, "Example Inc.", false},
+		{"Person: Jane Doe (jane@example.com)", entPerson, "Jane Doe (jane@example.com)", false},
+		{"Person:Jane Doe", entPerson, "Jane Doe", false},
+		{"Person: Jane Doe ()", entPerson, "Jane Doe ()", false},
+		{"Example Inc.", "", "", true},
+		{"Tool: bom", "", "", true},
+		{"Organization: ", "", "", true},
+		{"Person: Jane Doe (jane)", "", "", true},
+		{"Person: Jane (Doe) (jane@example.com)", "", "", true},
+	} {
+		kind, name, err := parseEntity(tc.value)
+		if tc.shouldErr {
+			require.Error(t, err, tc.value)
+			continue
+		}
+		require.NoError(t, err, tc.value)
+		require.Equal(t, tc.kind, kind, tc.value)
+		require.Equal(t, tc.name, name, tc.value)
+	}
+}
+
+func TestPackageFromDirectoryDefaultEntities(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), os.FileMode(0o644)))
+
+	// The options are copied, the defaults are shared by the SPDX objects
+	opts := defaultSPDXOptions
+	sut := &SPDX{impl: &spdxDefaultImplementation{}, options: &opts}
+	sut.Options().SkipLicenseScan = true
+	sut.Options().DefaultSupplier = "Organization: Example Inc."
+	sut.Options().DefaultOriginator = "Person: Jane Doe (jane@example.com)"
+	pkg, err := sut.PackageFromDirectory(dir)
+	require.NoError(t, err)
+	require.Equal(t, "Example Inc.", pkg.Supplier.Organization)
+	require.Empty(t, pkg.Supplier.Person)
+	require.Equal(t, "Jane Doe (jane@example.com)", pkg.Originator.Person)
+	require.Empty(t, pkg.Originator.Organization)
+
+	// The package keeps its own supplier
+	pkg = NewPackage()
+	pkg.Supplier.Person = "John Doe"
+	require.NoError(t, applyDefaultEntities(sut.Options(), pkg))
+	require.Equal(t, "John Doe", pkg.Supplier.Person)
+	require.Empty(t, pkg.Supplier.Organization)
+
+	// Malformed defaults are rejected, even if they are not needed
+	sut.Options().DefaultSupplier = "Example Inc."
+	_, err = sut.PackageFromDirectory(dir)
+	require.Error(t, err)
+	require.Error(t, applyDefaultEntities(sut.Options(), pkg))
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha1" //nolint:gosec // SWHIDs are defined on SHA1
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// swhidAnnotation prefixes the annotation recording the Software Heritage
+// identifier of a file (https://www.swhid.org)
+const swhidAnnotation = "SWHID: "
+
+// swhidContentPrefix prefixes the SWHIDs of file contents, followed by
+// the hex digest of newSWHIDHash
+const swhidContentPrefix = "swh:1:cnt:"
+
+// newSWHIDHash returns the hash of the SWHID of size bytes of content:
+// the SHA1 of the content prefixed by a git blob header, as git hashes
+// the objects it stores
+func newSWHIDHash(size int64) hash.Hash {
+	h := sha1.New() //nolint:gosec // SWHIDs are defined on SHA1
+	fmt.Fprintf(h, "blob %d\x00", size)
+	return h
+}
+
+// fileChecksumsWithSWHID hashes a file with the algorithms and computes
+// its SWHID, reading it only once
+func fileChecksumsWithSWHID(path string, algorithms []string) (map[string]string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, "", err
+	}
+	hashes, w, err := newChecksumHashes(algorithms)
+	if err != nil {
+		return nil, "", err
+	}
+	swhid := newSWHIDHash(info.Size())
+	n, err := io.Copy(io.MultiWriter(w, swhid), f)
+	if err != nil {
+		return nil, "", err
+	}
+	// The header is only right if the file did not change while read
+	if n != info.Size() {
+		return nil, "", fmt.Errorf("file %s changed while it was read", path)
+	}
+	return hexChecksums(hashes), swhidContentPrefix + hex.EncodeToString(swhid.Sum(nil)), nil
+}
+
+// SWHID returns the Software Heritage identifier annotated on the entity,
+// or an empty string if it has none
+func (e *Entity) SWHID() string {
+	for _, a := range e.Annotations {
+		if strings.HasPrefix(a.Comment, swhidAnnotation) {
+			return strings.TrimPrefix(a.Comment, swhidAnnotation)
+		}
+	}
+	return ""
+}
+
+// setSWHID annotates the entity with its SWHID, replacing the one it had
+func (e *Entity) setSWHID(swhid string) {
+	for i := range e.Annotations {
+		if strings.HasPrefix(e.Annotations[i].Comment, swhidAnnotation) {
+			e.Annotations[i] = newToolAnnotation(swhidAnnotation + swhid)
+			return
+		}
+	}
+	e.AddAnnotation(newToolAnnotation(swhidAnnotation + swhid))
+}
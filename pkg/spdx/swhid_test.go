@@ -0,0 +1,60 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromDirectorySWHID(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello\n"), os.FileMode(0o644)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "empty.txt"), []byte{}, os.FileMode(0o644)))
+
+	// SWHIDs are the git blob hashes of the files (git hash-object)
+	expected := map[string]string{
+		"hello.txt": "swh:1:cnt:ce013625030ba8dba906f756967f9e9ca394464a",
+		"empty.txt": "swh:1:cnt:e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+	}
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{SkipLicenseScan: true, ComputeSWHID: true}, dir)
+	require.NoError(t, err)
+	require.Len(t, pkg.Files(), 2)
+	for _, f := range pkg.Files() {
+		require.Equal(t, expected[f.Name], f.SWHID(), f.Name)
+		require.NotEmpty(t, f.Checksum["SHA1"])
+	}
+
+	// Without the option the files have no SWHID
+	pkg, err = impl.PackageFromDirectory(&Options{SkipLicenseScan: true}, dir)
+	require.NoError(t, err)
+	for _, f := range pkg.Files() {
+		require.Empty(t, f.SWHID())
+	}
+
+	// Reading the file again replaces its SWHID
+	f := NewFile()
+	f.Options().ComputeSWHID = true
+	require.NoError(t, f.ReadChecksums(filepath.Join(dir, "hello.txt")))
+	require.NoError(t, f.ReadChecksums(filepath.Join(dir, "hello.txt")))
+	require.Len(t, f.Annotations, 1)
+	require.Equal(t, expected["hello.txt"], f.SWHID())
+}
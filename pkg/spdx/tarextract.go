@@ -0,0 +1,653 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultExtractBufferSize is the size of the largest tarball entry
+// buffered in memory to be written by the extraction workers
+const defaultExtractBufferSize = 1 << 20
+
+// sparseBlockSize is the size of the runs of zeros left as holes when
+// writing sparse files
+const sparseBlockSize = 4096
+
+// errExtractionLimit is returned when a tarball holds more data than the
+// options allow to extract, eg a tar bomb filling the disk
+var errExtractionLimit = errors.New("tarball exceeds the extraction size limit")
+
+const (
+	// whiteoutPrefix marks an entry deleting a path of the lower layers
+	whiteoutPrefix = ".wh."
+	// whiteoutOpaque hides all the contents the lower layers have in
+	// the directory holding it
+	whiteoutOpaque = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// tarExtractor writes the entries of a tarball to disk. Reading the archive
+// is sequential, but with more than one worker the entries that fit in the
+// buffer are written in parallel while the next ones are read. Writes to the
+// same path are kept in archive order so later entries still win.
+type tarExtractor struct {
+	sync.Mutex
+	bufferSize int64
+	slots      chan struct{}            // Worker slots, nil when extracting serially
+	pending    map[string]chan struct{} // Last write dispatched for each path
+	wg         sync.WaitGroup
+	err        error
+	tempPaths  *tempRegistry       // Temporary directories removed if a worker panics
+	opts       *Options            // Options of the scan, to remove its directories on panic
+	root       string              // Directory the tarball is being extracted to
+	written    map[string]struct{} // Paths written by the tarball being extracted
+	maxTotal   int64               // Largest total size of the files extracted, 0 for no limit
+	maxEntry   int64               // Largest file extracted, 0 for no limit
+	extracted  int64               // Bytes extracted from the tarball so far
+	compress   bool                // Files are written gzip compressed
+	metadata   bool                // The mode and modification time of the entries are recorded for the scans
+	include    []string            // Paths and globs of the entries extracted, all when empty. Only set for image layers.
+	log        logrus.FieldLogger  // Logger of the extraction messages
+	di         *spdxDefaultImplementation
+}
+
+func newTarExtractor(opts *Options, di *spdxDefaultImplementation) *tarExtractor {
+	ex := &tarExtractor{
+		bufferSize: defaultExtractBufferSize,
+		pending:    map[string]chan struct{}{},
+		tempPaths:  &di.tempPaths,
+		opts:       opts,
+		log:        logger(opts),
+		di:         di,
+	}
+	if opts != nil && opts.ExtractBufferSize > 0 {
+		ex.bufferSize = int64(opts.ExtractBufferSize)
+	}
+	if opts != nil && opts.ExtractWorkers > 1 {
+		ex.slots = make(chan struct{}, opts.ExtractWorkers)
+	}
+	if opts != nil {
+		ex.maxTotal = opts.MaxExtractedBytes
+		ex.maxEntry = opts.MaxEntryBytes
+		ex.metadata = opts.RecordFileMetadata
+	}
+	return ex
+}
+
+// extractAll writes the files in the tarball to dir and returns the number
+// of files extracted. It returns once all the writes have finished.
+//
+// Whiteout entries are applied to what dir already holds, as OverlayFS does
+// when mounting an image, so extracting the layers of an image in order to
+// the same directory leaves the files a running container would see.
+func (ex *tarExtractor) extractAll(tr *tar.Reader, dir string) (numFiles int, err error) {
+	for _, pattern := range ex.include {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return 0, fmt.Errorf("invalid include path %q: %w", pattern, err)
+		}
+	}
+	ex.root = dir
+	ex.written = map[string]struct{}{}
+	ex.extracted = 0
+	numFiles, err = ex.readEntries(tr, dir)
+	ex.wg.Wait()
+	if err != nil {
+		return numFiles, err
+	}
+	return numFiles, ex.err
+}
+
+func (ex *tarExtractor) readEntries(tr *tar.Reader, dir string) (numFiles int, err error) {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return numFiles, nil
+		}
+		if err != nil {
+			return numFiles, fmt.Errorf("reading tarfile: %w", err)
+		}
+
+		if hdr.FileInfo().IsDir() {
+			continue
+		}
+
+		if strings.HasPrefix(path.Base(hdr.Name), whiteoutPrefix) {
+			if err := ex.applyWhiteout(dir, hdr.Name); err != nil {
+				return numFiles, err
+			}
+			continue
+		}
+
+		// Whiteouts apply to all paths, the entries outside of the
+		// included ones are not written
+		if !ex.included(hdr.Name) {
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeSymlink, tar.TypeLink:
+			created, err := ex.extractLink(dir, hdr)
+			if err != nil {
+				return numFiles, err
+			}
+			// Hard links are files of their own in the archive
+			if created && hdr.Typeflag == tar.TypeLink {
+				numFiles++
+			}
+			continue
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			ex.log.Debugf("Skipping extraction of special file %s", hdr.Name)
+			continue
+		}
+
+		// The tar reader expands the sparse formats it knows, the data
+		// of others is the sparse map and the fragments of the file
+		if unsupportedSparseEntry(hdr) {
+			ex.di.warn(
+				ex.opts, hdr.Name, "Skipping %s, its sparse file format %s.%s is not supported",
+				hdr.Name, hdr.PAXRecords[paxGNUSparseMajor], hdr.PAXRecords[paxGNUSparseMinor],
+			)
+			continue
+		}
+
+		targetFile, err := sanitizeExtractPath(dir, hdr.Name)
+		if err != nil {
+			return numFiles, err
+		}
+		if err := ex.checkSize(hdr); err != nil {
+			return numFiles, err
+		}
+		ex.markWritten(dir, targetFile)
+		complete, err := ex.extract(targetFile, tr, hdr)
+		if err != nil {
+			return numFiles, err
+		}
+		// A truncated entry is the end of the archive
+		if !complete {
+			return numFiles, nil
+		}
+		numFiles++
+	}
+}
+
+// included returns true if the entry name is under one of the paths to
+// extract, or matches one of their globs
+func (ex *tarExtractor) included(name string) bool {
+	if len(ex.include) == 0 {
+		return true
+	}
+	name = path.Clean("/" + name)[1:]
+	for _, pattern := range ex.include {
+		for p := name; p != "." && p != ""; p = path.Dir(p) {
+			if match, _ := path.Match(pattern, p); match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeIncludePaths returns the include paths relative to the root of
+// the tarballs, as their entries are named
+func normalizeIncludePaths(patterns []string) []string {
+	normalized := []string{}
+	for _, pattern := range patterns {
+		if p := path.Clean("/" + filepath.ToSlash(pattern))[1:]; p != "" {
+			normalized = append(normalized, p)
+		}
+	}
+	return normalized
+}
+
+// checkSize accounts the size of an entry about to be written and fails if
+// it goes over the limits of the extraction. The entries are written with
+// the size in their header, so no more data than checked reaches the disk.
+func (ex *tarExtractor) checkSize(hdr *tar.Header) error {
+	if ex.maxEntry > 0 && hdr.Size > ex.maxEntry {
+		return fmt.Errorf(
+			"%w: %s is %d bytes, the largest file allowed is %d bytes",
+			errExtractionLimit, hdr.Name, hdr.Size, ex.maxEntry,
+		)
+	}
+	ex.extracted += hdr.Size
+	if ex.maxTotal > 0 && ex.extracted > ex.maxTotal {
+		return fmt.Errorf(
+			"%w: extracting %s goes over the limit of %d bytes for the tarball",
+			errExtractionLimit, hdr.Name, ex.maxTotal,
+		)
+	}
+	return nil
+}
+
+// markWritten records that the tarball writes path, and so holds the
+// directories leading to it
+func (ex *tarExtractor) markWritten(dir, target string) {
+	dir = filepath.Clean(dir)
+	for p := target; p != dir && strings.HasPrefix(p, dir); p = filepath.Dir(p) {
+		if _, ok := ex.written[p]; ok {
+			return
+		}
+		ex.written[p] = struct{}{}
+	}
+}
+
+// applyWhiteout deletes from dir the paths hidden by a whiteout entry.
+// Opaque markers clear the contents of their directory except for the
+// entries written by the tarball itself. Other whiteout entries, like the
+// AUFS metadata, are skipped.
+func (ex *tarExtractor) applyWhiteout(dir, name string) error {
+	// The writes still in flight may target the paths to delete
+	ex.wg.Wait()
+	ex.pending = map[string]chan struct{}{}
+	ex.Lock()
+	err := ex.err
+	ex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	parent, base := path.Split(name)
+	if base == whiteoutOpaque {
+		parentDir := filepath.Clean(dir)
+		if parent != "" {
+			parentDir, err = sanitizeExtractPath(dir, parent)
+			if err != nil {
+				return err
+			}
+		}
+		ex.log.Debugf("Applying opaque whiteout to %s", parent)
+		return ex.clearHidden(parentDir)
+	}
+	if strings.HasPrefix(base, whiteoutPrefix+whiteoutPrefix) {
+		ex.log.Debugf("Skipping extraction of whiteout metadata %s", name)
+		return nil
+	}
+
+	hidden, err := sanitizeExtractPath(dir, parent+strings.TrimPrefix(base, whiteoutPrefix))
+	if err != nil {
+		return err
+	}
+	ex.log.Debugf("Applying whiteout of %s", hidden)
+	if err := os.RemoveAll(hidden); err != nil {
+		return fmt.Errorf("applying whiteout %s: %w", name, err)
+	}
+	return nil
+}
+
+// clearHidden removes the contents of dirPath not written by the tarball
+func (ex *tarExtractor) clearHidden(dirPath string) error {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading directory to apply opaque whiteout: %w", err)
+	}
+	for _, e := range entries {
+		p := filepath.Join(dirPath, e.Name())
+		if _, ok := ex.written[p]; !ok {
+			if err := os.RemoveAll(p); err != nil {
+				return fmt.Errorf("applying opaque whiteout: %w", err)
+			}
+			continue
+		}
+		if e.IsDir() {
+			if err := ex.clearHidden(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// extract writes the data of the entry hdr from r to path. It returns false
+// when the archive ended before all the data of the entry was read. Sparse
+// entries are always written serially, their holes are not buffered.
+func (ex *tarExtractor) extract(path string, r io.Reader, hdr *tar.Header) (complete bool, err error) {
+	size, sparse := hdr.Size, isSparseEntry(hdr)
+	ex.Lock()
+	err = ex.err
+	ex.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	if sparse {
+		ex.waitPending(path)
+		return ex.writeFile(path, r, hdr, true)
+	}
+	if ex.slots == nil || size > ex.bufferSize {
+		ex.waitPending(path)
+		return ex.writeFile(path, r, hdr, false)
+	}
+
+	ex.slots <- struct{}{}
+	buf := &bytes.Buffer{}
+	buf.Grow(int(size))
+	if _, err := io.CopyN(buf, r, size); err != nil {
+		<-ex.slots
+		if err != io.EOF {
+			return false, fmt.Errorf("extracting image data: %w", err)
+		}
+		// Keep what was read of the truncated entry, as the serial
+		// extraction does
+		ex.waitPending(path)
+		_, err := ex.writeEntry(path, buf, int64(buf.Len()), false)
+		return false, err
+	}
+
+	prev := ex.pending[path]
+	done := make(chan struct{})
+	ex.pending[path] = done
+	ex.wg.Add(1)
+	go func() {
+		defer ex.tempPaths.cleanupOnPanic(ex.opts)
+		defer func() {
+			close(done)
+			<-ex.slots
+			ex.wg.Done()
+		}()
+		if prev != nil {
+			<-prev
+		}
+		if _, err := ex.writeFile(path, buf, hdr, false); err != nil {
+			ex.Lock()
+			if ex.err == nil {
+				ex.err = err
+			}
+			ex.Unlock()
+		}
+	}()
+	return true, nil
+}
+
+// extractLink creates the symbolic or hard link of an entry in dir, returning
+// false if it was skipped. Links are resolved as if dir was the root of the
+// filesystem, as in a container: absolute symlinks point to the path under
+// dir, and the parent of the root is the root itself. Symlinks are written
+// relative to the directory they are created in, once the links in its path
+// are resolved, so they never point out of dir.
+func (ex *tarExtractor) extractLink(dir string, hdr *tar.Header) (created bool, err error) {
+	linkPath, err := sanitizeExtractPath(dir, hdr.Name)
+	if err != nil {
+		return false, err
+	}
+	target := path.Clean("/" + hdr.Linkname)
+	if hdr.Typeflag == tar.TypeSymlink && !path.IsAbs(hdr.Linkname) {
+		target = path.Clean("/" + path.Dir(hdr.Name) + "/" + hdr.Linkname)
+	}
+	// Rooted and cleaned, the target has no .. elements left
+	targetPath := filepath.Join(dir, filepath.FromSlash(target))
+	if hdr.Typeflag == tar.TypeLink {
+		// Hard links are made to the file itself, the links leading
+		// to it have to stay in dir too
+		targetPath, err = sanitizeExtractPath(dir, target)
+		if err != nil {
+			ex.log.Warnf("Skipping hard link %s: %v", hdr.Name, err)
+			return false, nil
+		}
+	}
+
+	ex.markWritten(dir, linkPath)
+	ex.waitPending(linkPath)
+	if err := os.MkdirAll(filepath.Dir(linkPath), os.FileMode(0o755)); err != nil {
+		return false, fmt.Errorf("creating image directory structure: %w", err)
+	}
+	if err := checkInRoot(dir, filepath.Dir(linkPath)); err != nil {
+		return false, err
+	}
+	if err := removeExisting(linkPath); err != nil {
+		return false, fmt.Errorf("replacing %s: %w", hdr.Name, err)
+	}
+
+	if hdr.Typeflag == tar.TypeSymlink {
+		rel, err := filepath.Rel(filepath.Dir(linkPath), targetPath)
+		if err != nil {
+			return false, fmt.Errorf("resolving symlink %s: %w", hdr.Name, err)
+		}
+		if err := os.Symlink(rel, linkPath); err != nil {
+			ex.log.Warnf("Skipping symlink %s: %v", hdr.Name, err)
+			return false, nil
+		}
+		return true, nil
+	}
+
+	// The file linked has to be fully written before linking it
+	ex.waitPending(targetPath)
+	if !ex.included(target) {
+		ex.log.Debugf("Skipping hard link %s, its target %s is not in the included paths", hdr.Name, hdr.Linkname)
+		return false, nil
+	}
+	if info, err := os.Lstat(targetPath); err != nil || !info.Mode().IsRegular() {
+		ex.log.Warnf("Skipping hard link %s, its target %s is not a file extracted before", hdr.Name, hdr.Linkname)
+		return false, nil
+	}
+	if err := os.Link(targetPath, linkPath); err != nil {
+		ex.log.Warnf("Skipping hard link %s: %v", hdr.Name, err)
+		return false, nil
+	}
+	ex.recordMetadata(linkPath, hdr)
+	return true, nil
+}
+
+// removeExisting removes the file or link a lower layer left at path, so it
+// is replaced instead of written through. Hard links to it keep the data.
+func removeExisting(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// waitPending blocks until the write dispatched last to path finishes
+func (ex *tarExtractor) waitPending(path string) {
+	if done, ok := ex.pending[path]; ok {
+		<-done
+		delete(ex.pending, path)
+	}
+}
+
+// writeFile writes the data of the entry hdr read from r to path and, if
+// the extractor keeps them, records the mode and modification time of the
+// entry for the scan of the file. They are never set on the file itself,
+// an archive could make it setuid or writable by everyone.
+func (ex *tarExtractor) writeFile(path string, r io.Reader, hdr *tar.Header, sparse bool) (complete bool, err error) {
+	complete, err = ex.writeEntry(path, r, hdr.Size, sparse)
+	if err != nil || !complete {
+		return complete, err
+	}
+	ex.recordMetadata(path, hdr)
+	return true, nil
+}
+
+// recordMetadata records the mode and modification time of the entry hdr
+// extracted to path, if the extractor keeps them
+func (ex *tarExtractor) recordMetadata(path string, hdr *tar.Header) {
+	if ex.metadata && ex.tempPaths != nil {
+		ex.tempPaths.setFileMetadata(ex.root, path, newFileMetadata(hdr.FileInfo().Mode(), hdr.ModTime))
+	}
+}
+
+// writeEntry writes size bytes read from r to path as the extractor keeps
+// its files. Compressed files are never sparse, their zeros compress well.
+func (ex *tarExtractor) writeEntry(path string, r io.Reader, size int64, sparse bool) (complete bool, err error) {
+	switch {
+	case ex.compress:
+		return writeCompressedTarEntry(ex.root, path, r, size)
+	case sparse:
+		return writeSparseTarEntry(ex.root, path, r, size)
+	}
+	return writeTarEntry(ex.root, path, r, size)
+}
+
+// createTarEntryFile creates the file at path, replacing what a lower layer
+// left there. The directories leading to it are checked to still be under
+// root, a link extracted since the path was resolved could move them.
+func createTarEntryFile(root, path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0o755)); err != nil {
+		return nil, fmt.Errorf("creating image directory structure: %w", err)
+	}
+	if err := checkInRoot(root, filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	if err := removeExisting(path); err != nil {
+		return nil, fmt.Errorf("replacing image layer file: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating image layer file: %w", err)
+	}
+	return f, nil
+}
+
+// writeTarEntry creates the file at path under root with size bytes read
+// from r
+func writeTarEntry(root, path string, r io.Reader, size int64) (complete bool, err error) {
+	f, err := createTarEntryFile(root, path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	if _, err := io.CopyN(f, r, size); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, fmt.Errorf("extracting image data: %w", err)
+	}
+	return true, nil
+}
+
+// writeCompressedTarEntry creates the file at path under root with size
+// bytes read from r, gzip compressed
+func writeCompressedTarEntry(root, path string, r io.Reader, size int64) (complete bool, err error) {
+	f, err := createTarEntryFile(root, path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// Extraction is bound by the disk, so the fastest level does
+	gw, err := gzip.NewWriterLevel(f, gzip.BestSpeed)
+	if err != nil {
+		return false, fmt.Errorf("creating gzip writer: %w", err)
+	}
+	complete = true
+	if _, err := io.CopyN(gw, r, size); err != nil {
+		if err != io.EOF {
+			return false, fmt.Errorf("extracting image data: %w", err)
+		}
+		complete = false
+	}
+	if err := gw.Close(); err != nil {
+		return false, fmt.Errorf("compressing image data: %w", err)
+	}
+	return complete, nil
+}
+
+const (
+	paxGNUSparseMajor = "GNU.sparse.major"
+	paxGNUSparseMinor = "GNU.sparse.minor"
+)
+
+// isSparseEntry returns true if the entry is a sparse file, either in the
+// old GNU format or in one of the GNU PAX formats
+func isSparseEntry(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// unsupportedSparseEntry returns true if the entry is a GNU sparse file in
+// a PAX format version the tar reader does not expand (0.0, 0.1 and 1.0
+// are read)
+func unsupportedSparseEntry(hdr *tar.Header) bool {
+	major, minor := hdr.PAXRecords[paxGNUSparseMajor], hdr.PAXRecords[paxGNUSparseMinor]
+	switch {
+	case major == "" && minor == "":
+		return false
+	case major == "0" && (minor == "0" || minor == "1"):
+		return false
+	case major == "1" && minor == "0":
+		return false
+	}
+	return true
+}
+
+// writeSparseTarEntry creates the file at path under root with size bytes
+// read from r like writeTarEntry, but seeks over the blocks of zeros instead
+// of writing them. The holes of sparse files stay holes on disk, so files
+// like /var/log/lastlog that are large but mostly empty do not fill it.
+func writeSparseTarEntry(root, path string, r io.Reader, size int64) (complete bool, err error) {
+	f, err := createTarEntryFile(root, path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf, zeros := make([]byte, sparseBlockSize), make([]byte, sparseBlockSize)
+	var written int64
+	for written < size {
+		chunk := buf
+		if rest := size - written; rest < int64(len(chunk)) {
+			chunk = buf[:rest]
+		}
+		n, readErr := io.ReadFull(r, chunk)
+		if n > 0 {
+			if bytes.Equal(chunk[:n], zeros[:n]) {
+				_, err = f.Seek(int64(n), io.SeekCurrent)
+			} else {
+				_, err = f.Write(chunk[:n])
+			}
+			if err != nil {
+				return false, fmt.Errorf("extracting image data: %w", err)
+			}
+			written += int64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return false, fmt.Errorf("extracting image data: %w", readErr)
+		}
+	}
+	// Seeking past the end does not grow the file, set its size in case
+	// it ends in a hole
+	if err := f.Truncate(written); err != nil {
+		return false, fmt.Errorf("setting the size of sparse file: %w", err)
+	}
+	return written == size, nil
+}
@@ -0,0 +1,446 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractTarballTmpParallel(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeEntry := func(name, content string) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	for i := 0; i < 100; i++ {
+		writeEntry(fmt.Sprintf("dir%d/file%d.txt", i%10, i), fmt.Sprintf("file %d", i))
+	}
+	// Rewritten entries must end with the data of the last one, whether
+	// they are buffered or larger than the buffer
+	writeEntry("dir0/file0.txt", "rewritten")
+	writeEntry("dir1/file1.txt", "rewritten with more data than fits in the buffer")
+	writeEntry("dir1/file1.txt", "small again")
+	require.NoError(t, tw.Close())
+	tarPath := filepath.Join(t.TempDir(), "layer.tar")
+	require.NoError(t, os.WriteFile(tarPath, buf.Bytes(), os.FileMode(0o644)))
+
+	impl := spdxDefaultImplementation{}
+	for _, workers := range []int{0, 4} {
+		dir, err := impl.ExtractTarballTmp(&Options{ExtractWorkers: workers, ExtractBufferSize: 16}, tarPath)
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		for i := 2; i < 100; i++ {
+			data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("dir%d/file%d.txt", i%10, i)))
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("file %d", i), string(data))
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "dir0/file0.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "rewritten", string(data))
+		data, err = os.ReadFile(filepath.Join(dir, "dir1/file1.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "small again", string(data))
+	}
+}
+
+func TestExtractTarballTmpSizeLimits(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < 4; i++ {
+		data := bytes.Repeat([]byte{'a'}, 1024)
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: fmt.Sprintf("file%d.bin", i), Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	tarPath := filepath.Join(t.TempDir(), "layer.tar")
+	require.NoError(t, os.WriteFile(tarPath, buf.Bytes(), os.FileMode(0o644)))
+
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	impl := spdxDefaultImplementation{}
+	for _, workers := range []int{0, 4} {
+		// Without limits everything is extracted
+		dir, err := impl.ExtractTarballTmp(&Options{ExtractWorkers: workers}, tarPath)
+		require.NoError(t, err)
+		impl.tempPaths.remove(dir)
+
+		dir, err = impl.ExtractTarballTmp(&Options{ExtractWorkers: workers, MaxExtractedBytes: 4096}, tarPath)
+		require.NoError(t, err)
+		impl.tempPaths.remove(dir)
+
+		for _, opts := range []*Options{
+			{ExtractWorkers: workers, MaxExtractedBytes: 3000},
+			{ExtractWorkers: workers, MaxEntryBytes: 1000},
+		} {
+			dir, err = impl.ExtractTarballTmp(opts, tarPath)
+			require.Error(t, err)
+			require.ErrorIs(t, err, errExtractionLimit)
+			require.Empty(t, dir)
+
+			// The partial extraction is removed
+			entries, err := os.ReadDir(tmpDir)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+		}
+	}
+}
+
+func TestExtractTarballTmpSparse(t *testing.T) {
+	expected := make([]byte, 1<<20+5)
+	copy(expected, "hello")
+	copy(expected[1<<20:], "world")
+
+	// The same sparse file in a GNU PAX format version the tar reader
+	// does not know, its data is not the file contents
+	data, err := os.ReadFile("testdata/sparse-pax.tar")
+	require.NoError(t, err)
+	require.Equal(t, 1, bytes.Count(data, []byte("GNU.sparse.major=1\n")))
+	unsupported := filepath.Join(t.TempDir(), "unsupported.tar")
+	require.NoError(t, os.WriteFile(
+		unsupported, bytes.Replace(data, []byte("GNU.sparse.major=1\n"), []byte("GNU.sparse.major=2\n"), 1),
+		os.FileMode(0o644),
+	))
+
+	impl := spdxDefaultImplementation{}
+	for _, workers := range []int{0, 4} {
+		// Written with tar --sparse in the old GNU and in the PAX 1.0 formats
+		for _, tarPath := range []string{"testdata/sparse-gnu.tar", "testdata/sparse-pax.tar"} {
+			dir, err := impl.ExtractTarballTmp(&Options{ExtractWorkers: workers}, tarPath)
+			require.NoError(t, err)
+			extracted, err := os.ReadFile(filepath.Join(dir, "sparse.bin"))
+			require.NoError(t, err)
+			require.Len(t, extracted, len(expected))
+			require.Equal(t, sha256.Sum256(expected), sha256.Sum256(extracted))
+			impl.tempPaths.remove(dir)
+		}
+
+		dir, err := impl.ExtractTarballTmp(&Options{ExtractWorkers: workers, CollectWarnings: true}, unsupported)
+		require.NoError(t, err)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+		impl.tempPaths.remove(dir)
+	}
+
+	// The skipped file is reported in the warnings of the scan
+	warnings := impl.Warnings()
+	require.Len(t, warnings, 2)
+	require.Contains(t, warnings[0].Element, "sparse.bin")
+	require.Contains(t, warnings[0].Message, "sparse file format 2.0 is not supported")
+}
+
+func TestExtractTarballTmpLinks(t *testing.T) {
+	// writeLayer writes a tarball with the entries
+	writeLayer := func(hdrs []*tar.Header, data map[string]string) string {
+		tarPath := filepath.Join(t.TempDir(), "layer.tar")
+		f, err := os.Create(tarPath)
+		require.NoError(t, err)
+		defer f.Close()
+		tw := tar.NewWriter(f)
+		for _, hdr := range hdrs {
+			hdr.Mode = 0o644
+			hdr.Size = int64(len(data[hdr.Name]))
+			require.NoError(t, tw.WriteHeader(hdr))
+			_, err := tw.Write([]byte(data[hdr.Name]))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		return tarPath
+	}
+	base := writeLayer([]*tar.Header{
+		{Name: "usr/bin/tool", Typeflag: tar.TypeReg},
+		{Name: "usr/bin/tool-link", Typeflag: tar.TypeLink, Linkname: "usr/bin/tool"},
+		{Name: "bin/tool", Typeflag: tar.TypeSymlink, Linkname: "/usr/bin/tool"},
+		{Name: "usr/lib/tool", Typeflag: tar.TypeSymlink, Linkname: "../bin/tool"},
+		{Name: "escape", Typeflag: tar.TypeSymlink, Linkname: "../../../etc/passwd"},
+		{Name: "dev/null", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3},
+		{Name: "broken", Typeflag: tar.TypeLink, Linkname: "missing"},
+	}, map[string]string{"usr/bin/tool": "tool v1\n"})
+	upper := writeLayer([]*tar.Header{
+		{Name: "usr/bin/tool", Typeflag: tar.TypeReg},
+	}, map[string]string{"usr/bin/tool": "tool v2\n"})
+
+	impl := spdxDefaultImplementation{}
+	for _, workers := range []int{0, 4} {
+		dir, err := impl.ExtractTarballTmp(&Options{ExtractWorkers: workers}, base)
+		require.NoError(t, err)
+
+		// Links point to the files in the extracted tree
+		for _, name := range []string{"usr/bin/tool-link", "bin/tool", "usr/lib/tool"} {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			require.NoError(t, err, name)
+			require.Equal(t, "tool v1\n", string(data), name)
+		}
+		target, err := os.Readlink(filepath.Join(dir, "bin/tool"))
+		require.NoError(t, err)
+		require.Equal(t, filepath.FromSlash("../usr/bin/tool"), target)
+		target, err = os.Readlink(filepath.Join(dir, "escape"))
+		require.NoError(t, err)
+		require.Equal(t, filepath.FromSlash("etc/passwd"), target)
+
+		// Device nodes and dangling hard links are not created
+		for _, name := range []string{"dev/null", "broken"} {
+			_, err := os.Lstat(filepath.Join(dir, name))
+			require.True(t, os.IsNotExist(err), name)
+		}
+
+		// Symlinks are not scanned as files
+		files, err := impl.GetDirectoryTree(dir, false, false)
+		require.NoError(t, err)
+		sort.Strings(files)
+		require.Equal(t, []string{"usr/bin/tool", "usr/bin/tool-link"}, files)
+		impl.tempPaths.remove(dir)
+
+		// Replacing the file in an upper layer leaves its hard link alone
+		dir, err = impl.ExtractLayersTmp(&Options{ExtractWorkers: workers}, []string{base, upper})
+		require.NoError(t, err)
+		for name, expected := range map[string]string{
+			"usr/bin/tool": "tool v2\n", "usr/bin/tool-link": "tool v1\n", "bin/tool": "tool v2\n",
+		} {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			require.NoError(t, err, name)
+			require.Equal(t, expected, string(data), name)
+		}
+		impl.tempPaths.remove(dir)
+	}
+}
+
+func TestExtractTarballTmpSymlinkChains(t *testing.T) {
+	// writeLayer writes a tarball with the entries, the files hold root
+	writeLayer := func(hdrs []*tar.Header) string {
+		tarPath := filepath.Join(t.TempDir(), "layer.tar")
+		f, err := os.Create(tarPath)
+		require.NoError(t, err)
+		defer f.Close()
+		tw := tar.NewWriter(f)
+		for _, hdr := range hdrs {
+			hdr.Mode = 0o644
+			if hdr.Typeflag == tar.TypeReg {
+				hdr.Size = 5
+			}
+			require.NoError(t, tw.WriteHeader(hdr))
+			if hdr.Size > 0 {
+				_, err := tw.Write([]byte("root\n"))
+				require.NoError(t, err)
+			}
+		}
+		require.NoError(t, tw.Close())
+		return tarPath
+	}
+	// Each link is harmless on its own, but followed through the links
+	// extracted before them they point out of the extraction directory
+	chain := writeLayer([]*tar.Header{
+		{Name: "s", Typeflag: tar.TypeSymlink, Linkname: "/"},
+		{Name: "s/s/s/up", Typeflag: tar.TypeSymlink, Linkname: "/"},
+		{Name: "up/etc/passwd", Typeflag: tar.TypeReg},
+		{Name: "s/s/passwd-link", Typeflag: tar.TypeLink, Linkname: "s/s/up/etc/passwd"},
+	})
+	loop := writeLayer([]*tar.Header{
+		{Name: "loop-a", Typeflag: tar.TypeSymlink, Linkname: "loop-b"},
+		{Name: "loop-b", Typeflag: tar.TypeSymlink, Linkname: "loop-a"},
+		{Name: "loop-a/file", Typeflag: tar.TypeReg},
+	})
+
+	impl := spdxDefaultImplementation{}
+	for _, workers := range []int{0, 4} {
+		base := t.TempDir()
+		tempDir := filepath.Join(base, "a", "b", "c")
+		require.NoError(t, os.MkdirAll(tempDir, 0o755))
+		opts := &Options{ExtractWorkers: workers, TempDir: tempDir}
+		dir, err := impl.ExtractTarballTmp(opts, chain)
+		require.NoError(t, err)
+
+		// Nothing is written out of the extraction directory
+		_, err = os.Lstat(filepath.Join(base, "a", "etc"))
+		require.True(t, os.IsNotExist(err))
+		for _, name := range []string{"etc/passwd", "passwd-link"} {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			require.NoError(t, err, name)
+			require.Equal(t, "root\n", string(data), name)
+		}
+		target, err := os.Readlink(filepath.Join(dir, "up"))
+		require.NoError(t, err)
+		require.Equal(t, ".", target)
+		impl.tempPaths.remove(dir)
+
+		_, err = impl.ExtractTarballTmp(opts, loop)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "too many levels of symbolic links")
+	}
+}
+
+func TestExtractLayersTmpWhiteouts(t *testing.T) {
+	writeLayer := func(name string, files ...string) string {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, f := range files {
+			data := []byte("contents of " + f)
+			require.NoError(t, tw.WriteHeader(&tar.Header{
+				Name: f, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+			}))
+			_, err := tw.Write(data)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		layerPath := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(layerPath, buf.Bytes(), os.FileMode(0o644)))
+		return layerPath
+	}
+	layers := []string{
+		writeLayer(
+			"layer1.tar", "etc/keep", "etc/remove", "etc/removed-dir/file",
+			"var/cache/old", "var/cache/sub/old", "var/cache/sub/replaced",
+		),
+		writeLayer(
+			"layer2.tar", "etc/.wh.remove", "etc/.wh.removed-dir", "etc/.wh..wh.plnk",
+			"var/cache/sub/replaced", "var/cache/.wh..wh..opq", "var/cache/new",
+		),
+	}
+
+	for _, workers := range []int{1, 4} {
+		impl := spdxDefaultImplementation{}
+		dir, err := impl.ExtractLayersTmp(&Options{ExtractWorkers: workers}, layers)
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		files := []string{}
+		require.NoError(t, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			require.NoError(t, err)
+			if !info.IsDir() {
+				rel, err := filepath.Rel(dir, path)
+				require.NoError(t, err)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return nil
+		}))
+		require.ElementsMatch(t, []string{"etc/keep", "var/cache/new", "var/cache/sub/replaced"}, files)
+
+		data, err := os.ReadFile(filepath.Join(dir, "var/cache/sub/replaced"))
+		require.NoError(t, err)
+		require.Equal(t, "contents of var/cache/sub/replaced", string(data))
+	}
+}
+
+func TestExtractLayersTmpIncludePaths(t *testing.T) {
+	writeLayer := func(name string, files ...string) string {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, f := range files {
+			data := []byte("contents of " + f)
+			require.NoError(t, tw.WriteHeader(&tar.Header{
+				Name: f, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+			}))
+			_, err := tw.Write(data)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		layerPath := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(layerPath, buf.Bytes(), os.FileMode(0o644)))
+		return layerPath
+	}
+	layers := []string{
+		writeLayer(
+			"layer1.tar", "./usr/bin/tool", "usr/bin/removed", "usr/lib/libtool.so",
+			"app/main", "app/data/old", "opt/a/lib/liba.so", "opt/b/share/doc",
+		),
+		writeLayer(
+			"layer2.tar", "usr/bin/.wh.removed", "app/data/.wh..wh..opq", "app/data/new",
+			"etc/config", "usr/bin2/other",
+		),
+	}
+
+	readFiles := func(dir string) []string {
+		files := []string{}
+		require.NoError(t, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			require.NoError(t, err)
+			if !info.IsDir() {
+				rel, err := filepath.Rel(dir, path)
+				require.NoError(t, err)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return nil
+		}))
+		return files
+	}
+
+	for _, workers := range []int{1, 4} {
+		impl := spdxDefaultImplementation{}
+		dir, err := impl.ExtractLayersTmp(&Options{
+			ExtractWorkers: workers, IncludePaths: []string{"/usr/bin", "app/", "opt/*/lib"},
+		}, layers)
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		require.ElementsMatch(t, []string{
+			"usr/bin/tool", "app/main", "app/data/new", "opt/a/lib/liba.so",
+		}, readFiles(dir))
+	}
+
+	// Without include paths, or only the root, all is extracted
+	for _, include := range [][]string{nil, {"/"}} {
+		impl := spdxDefaultImplementation{}
+		dir, err := impl.ExtractLayersTmp(&Options{IncludePaths: include}, layers[:1])
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		require.Len(t, readFiles(dir), 7)
+	}
+
+	// Tarballs other than image layers are extracted whole
+	impl := spdxDefaultImplementation{}
+	dir, err := impl.ExtractTarballTmp(&Options{IncludePaths: []string{"/usr/bin"}}, layers[0])
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.Len(t, readFiles(dir), 7)
+
+	_, err = impl.ExtractLayersTmp(&Options{IncludePaths: []string{"usr/[bin"}}, layers[:1])
+	require.ErrorContains(t, err, "invalid include path")
+}
+
+func BenchmarkExtractTarballTmp(b *testing.B) {
+	tarPath := filepath.Join(b.TempDir(), "layer.tar")
+	require.NoError(b, os.WriteFile(tarPath, testLayerData(b, 0, 5000), os.FileMode(0o644)))
+
+	impl := spdxDefaultImplementation{}
+	for _, workers := range []int{1, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			opts := &Options{ExtractWorkers: workers}
+			for i := 0; i < b.N; i++ {
+				dir, err := impl.ExtractTarballTmp(opts, tarPath)
+				if err != nil {
+					b.Fatal(err)
+				}
+				os.RemoveAll(dir)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"sync"
+)
+
+// tempRegistry tracks the temporary directories created while scanning.
+// Each scan removes its directories when done, the registry makes sure
+// they are removed too when a panic interrupts the scan, including
+// panics in the goroutines of the throttlers which would otherwise
+// crash the program skipping the deferred removals of other goroutines.
+//
+// The directories are tracked by the options of the scan creating them, a
+// panic only removes the directories of its own scan and leaves those of
+// the scans running at the same time with other options.
+//
+// It also keeps the metadata of the tarball entries extracted to each
+// directory, which is recorded in the files scanned instead of being set
+// on disk: the modes in a tarball are not to be trusted.
+type tempRegistry struct {
+	sync.Mutex
+	paths    map[string]*Options                // Options of the scan creating each directory
+	metadata map[string]map[string]FileMetadata // Metadata of the extracted files, by temporary directory and path
+}
+
+// tempRoot returns the directory where the temporary directories of the
+// scans are created: the one in the options or the system default
+func tempRoot(opts *Options) string {
+	if opts != nil && opts.TempDir != "" {
+		return opts.TempDir
+	}
+	return os.TempDir()
+}
+
+// mkdirTemp creates a temporary directory for the scan run with opts in
+// its temporary root, as os.MkdirTemp does, and registers it to be
+// removed if the scan panics
+func (tr *tempRegistry) mkdirTemp(opts *Options, pattern string) (string, error) {
+	path, err := os.MkdirTemp(tempRoot(opts), pattern)
+	if err != nil {
+		return path, err
+	}
+	tr.Lock()
+	defer tr.Unlock()
+	if tr.paths == nil {
+		tr.paths = map[string]*Options{}
+	}
+	tr.paths[path] = opts
+	return path, nil
+}
+
+// setFileMetadata records the metadata of the tarball entry extracted to
+// path in the temporary directory dir
+func (tr *tempRegistry) setFileMetadata(dir, path string, m FileMetadata) {
+	tr.Lock()
+	defer tr.Unlock()
+	if tr.metadata == nil {
+		tr.metadata = map[string]map[string]FileMetadata{}
+	}
+	if tr.metadata[dir] == nil {
+		tr.metadata[dir] = map[string]FileMetadata{}
+	}
+	tr.metadata[dir][path] = m
+}
+
+// fileMetadata returns the metadata recorded for the file at path in the
+// temporary directory dir, if it was extracted from a tarball entry
+func (tr *tempRegistry) fileMetadata(dir, path string) (FileMetadata, bool) {
+	tr.Lock()
+	defer tr.Unlock()
+	m, ok := tr.metadata[dir][path]
+	return m, ok
+}
+
+// remove deletes a temporary directory and stops tracking it
+func (tr *tempRegistry) remove(path string) {
+	tr.Lock()
+	owner := tr.paths[path]
+	delete(tr.paths, path)
+	delete(tr.metadata, path)
+	tr.Unlock()
+	if err := os.RemoveAll(path); err != nil {
+		logger(owner).Warnf("Removing temporary directory %s: %v", path, err)
+	}
+}
+
+// removeScan deletes the tracked directories of the scan run with opts
+func (tr *tempRegistry) removeScan(opts *Options) {
+	tr.Lock()
+	paths := []string{}
+	for path, owner := range tr.paths {
+		if owner == opts {
+			paths = append(paths, path)
+			delete(tr.paths, path)
+			delete(tr.metadata, path)
+		}
+	}
+	tr.Unlock()
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			logger(opts).Warnf("Removing temporary directory %s: %v", path, err)
+		}
+	}
+}
+
+// cleanupOnPanic removes the tracked directories of the scan run with opts
+// when the function deferring it panics, then resumes panicking. It has to
+// be deferred directly for the panic to be recovered:
+//
+//	defer di.tempPaths.cleanupOnPanic(opts)
+func (tr *tempRegistry) cleanupOnPanic(opts *Options) {
+	if r := recover(); r != nil {
+		tr.removeScan(opts)
+		panic(r)
+	}
+}
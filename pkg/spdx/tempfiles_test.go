@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTempRegistryCleanupOnPanic(t *testing.T) {
+	opts := &Options{TempDir: t.TempDir()}
+	reg := tempRegistry{}
+	dirs := []string{}
+	for i := 0; i < 3; i++ {
+		dir, err := reg.mkdirTemp(opts, "scan-")
+		require.NoError(t, err)
+		dirs = append(dirs, dir)
+	}
+
+	// Another scan running at the same time with other options
+	other, err := reg.mkdirTemp(&Options{TempDir: opts.TempDir}, "other-")
+	require.NoError(t, err)
+	reg.remove(dirs[0])
+	require.NoDirExists(t, dirs[0])
+	require.DirExists(t, dirs[1])
+
+	// Panics are not swallowed, they continue once the dirs are removed
+	require.PanicsWithValue(t, "scan failed", func() {
+		defer reg.cleanupOnPanic(opts)
+		panic("scan failed")
+	})
+	for _, dir := range dirs {
+		require.NoDirExists(t, dir)
+	}
+
+	// The directories of the other scan are left alone
+	require.DirExists(t, other)
+	require.Len(t, reg.paths, 1)
+
+	// Without a panic nothing is removed
+	dir, err := reg.mkdirTemp(opts, "scan-")
+	require.NoError(t, err)
+	func() {
+		defer reg.cleanupOnPanic(opts)
+	}()
+	require.DirExists(t, dir)
+}
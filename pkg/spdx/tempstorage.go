@@ -0,0 +1,135 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// TempStorageMode selects how the files extracted from tarballs are kept
+// on disk while they are scanned
+type TempStorageMode string
+
+const (
+	// TempStoragePlain writes the files as they are in the tarball
+	TempStoragePlain TempStorageMode = "plain"
+
+	// TempStorageCompressed keeps the files gzip compressed while the
+	// tarball is extracted, decompressing them one at a time to scan
+	// them. It trades CPU for disk space when scanning large images.
+	TempStorageCompressed TempStorageMode = "compressed"
+)
+
+// packageFromCompressedTarball builds the package of the files in a
+// tarball under the include paths, extracting them compressed. They are
+// decompressed to another directory, each compressed copy removed once
+// written, which is scanned as the plain extractions are.
+func (di *spdxDefaultImplementation) packageFromCompressedTarball(
+	opts *Options, tarFile string, include []string,
+) (*Package, error) {
+	tmp, err := di.extractTarballTmp(opts, tarFile, TempStorageCompressed, include)
+	if err != nil {
+		return nil, fmt.Errorf("extracting tarball to temporary archive: %w", err)
+	}
+	defer di.tempPaths.remove(tmp)
+
+	spool, err := di.decompressDirectory(opts, tmp)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing tar contents: %w", err)
+	}
+	defer di.tempPaths.remove(spool)
+	pkg, err := di.PackageFromDirectory(opts, spool)
+	if err != nil {
+		return nil, fmt.Errorf("generating package from tar contents: %w", err)
+	}
+	if err := di.addNestedArchives(opts, pkg, spool, 1); err != nil {
+		return nil, fmt.Errorf("scanning archives in tarball: %w", err)
+	}
+	return pkg, nil
+}
+
+// decompressDirectory decompresses the files extracted compressed to
+// dirPath into a new temporary directory, which is returned. Each file is
+// removed once decompressed, so the files take their full size on disk
+// only once. The metadata recorded for the extracted files is kept.
+func (di *spdxDefaultImplementation) decompressDirectory(opts *Options, dirPath string) (string, error) {
+	spool, err := di.tempPaths.mkdirTemp(opts, "spdx-decompressed-")
+	if err != nil {
+		return "", fmt.Errorf("creating temporary directory: %w", err)
+	}
+	err = filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(spool, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(dest, os.FileMode(0o755))
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dest)
+		case !d.Type().IsRegular():
+			return nil
+		}
+		if err := decompressFile(path, dest); err != nil {
+			return err
+		}
+		if m, ok := di.tempPaths.fileMetadata(dirPath, path); ok {
+			di.tempPaths.setFileMetadata(spool, dest, m)
+		}
+		return os.Remove(path)
+	})
+	if err != nil {
+		di.tempPaths.remove(spool)
+		return "", err
+	}
+	return spool, nil
+}
+
+// decompressFile writes the contents of the gzipped file at path to dest
+func decompressFile(path, dest string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("decompressing %s: %w", path, err)
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", dest, err)
+	}
+	if _, err := io.Copy(out, gr); err != nil {
+		out.Close()
+		return fmt.Errorf("decompressing %s: %w", path, err)
+	}
+	return out.Close()
+}
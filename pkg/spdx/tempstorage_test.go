@@ -0,0 +1,96 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/bom/pkg/license"
+)
+
+func TestPackageFromImageTarballCompressedTempStorage(t *testing.T) {
+	list, err := license.EmbeddedLicenseList()
+	require.NoError(t, err)
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	for name, data := range map[string]string{
+		"usr/share/big.txt": strings.Repeat("compressible contents\n", 1<<16),
+		"LICENSE":           list.Licenses["Apache-2.0"].LicenseText,
+		"etc/motd":          "Welcome\n",
+		"etc/empty":         "",
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	// The files are kept gzipped on disk
+	layerPath := filepath.Join(t.TempDir(), "layer.tar")
+	require.NoError(t, os.WriteFile(layerPath, layer.Bytes(), os.FileMode(0o644)))
+	impl := spdxDefaultImplementation{}
+	dir, err := impl.extractTarballTmp(&Options{}, layerPath, TempStorageCompressed, nil)
+	require.NoError(t, err)
+	defer impl.tempPaths.remove(dir)
+	info, err := os.Stat(filepath.Join(dir, "usr/share/big.txt"))
+	require.NoError(t, err)
+	require.Less(t, info.Size(), int64(64*1024))
+	f, err := os.Open(filepath.Join(dir, "usr/share/big.txt"))
+	require.NoError(t, err)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	require.NoError(t, err)
+	require.Len(t, data, 22<<16)
+
+	// Both modes describe the same files
+	tarPath := writeTestDockerArchive(t, []string{"layer/layer.tar"}, [][]byte{layer.Bytes()})
+	layerFiles := func(opts *Options) map[string]*File {
+		pkg, err := impl.PackageFromImageTarball(opts, tarPath)
+		require.NoError(t, err)
+		layers := containedPackages(pkg)
+		require.Len(t, layers, 1)
+		files := map[string]*File{}
+		for _, f := range layers[0].Files() {
+			files[f.Name] = f
+		}
+		return files
+	}
+	plain := layerFiles(&Options{AddTarFiles: true})
+	compressed := layerFiles(&Options{AddTarFiles: true, TempStorageMode: TempStorageCompressed})
+	require.Len(t, compressed, 4)
+	for name, f := range plain {
+		require.Contains(t, compressed, name)
+		require.Equal(t, f.Checksum, compressed[name].Checksum, name)
+		require.Equal(t, f.FileType, compressed[name].FileType, name)
+		require.Equal(t, f.Size, compressed[name].Size, name)
+		require.Equal(t, f.LicenseInfoInFile, compressed[name].LicenseInfoInFile, name)
+	}
+	require.Equal(t, "Apache-2.0", compressed["LICENSE"].LicenseInfoInFile)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+)
+
+// TreeHash returns a hash of the package computed recursively from its data
+// and the data of the files and packages it has relationships with. Two
+// packages describing the same tree hash equally, regardless of the SPDX IDs
+// of their elements, the order of their relationships or the dates of their
+// annotations, which makes the hash useful to detect changes between SBOMs.
+func (p *Package) TreeHash() string {
+	th := &treeHasher{
+		done:     map[Object]string{},
+		visiting: map[Object]struct{}{},
+	}
+	return th.hash(p)
+}
+
+// treeHasher computes the hashes of a tree of elements. Elements reached
+// more than once are only hashed the first time.
+type treeHasher struct {
+	done     map[Object]string
+	visiting map[Object]struct{}
+}
+
+// writeFields writes strings to the hash prefixed with their length
+// to avoid ambiguities between consecutive fields
+func writeFields(h hash.Hash, fields ...string) {
+	for _, f := range fields {
+		h.Write([]byte(strconv.Itoa(len(f)) + ":" + f + ";"))
+	}
+}
+
+// writeSorted writes a list of strings to the hash in sorted order
+func writeSorted(h hash.Hash, fields []string) {
+	sorted := append([]string{}, fields...)
+	sort.Strings(sorted)
+	writeFields(h, strconv.Itoa(len(sorted)))
+	writeFields(h, sorted...)
+}
+
+func (th *treeHasher) hash(o Object) string {
+	if sum, ok := th.done[o]; ok {
+		return sum
+	}
+	// Relationships pointing back to an element being hashed do not
+	// recurse, the cycle is recorded with a marker instead
+	if _, ok := th.visiting[o]; ok {
+		return "cycle"
+	}
+	th.visiting[o] = struct{}{}
+	defer delete(th.visiting, o)
+
+	h := sha256.New()
+	var entity *Entity
+	switch e := o.(type) {
+	case *Package:
+		entity = &e.Entity
+		refs := []string{}
+		for _, er := range e.ExternalRefs {
+			refs = append(refs, er.Category+" "+er.Type+" "+er.Locator)
+		}
+		writeFields(h, "package", strconv.FormatBool(e.FilesAnalyzed), e.VerificationCode,
+			e.LicenseDeclared, e.Version, e.Comment, e.HomePage, e.PrimaryPurpose,
+			e.Supplier.Person, e.Supplier.Organization,
+			e.Originator.Person, e.Originator.Organization)
+		writeSorted(h, e.LicenseInfoFromFiles)
+		writeSorted(h, refs)
+	case *File:
+		entity = &e.Entity
+		writeFields(h, "file", e.LicenseInfoInFile)
+		writeSorted(h, e.FileType)
+	default:
+		return fmt.Sprintf("%T", o)
+	}
+
+	writeFields(h, entity.Name, entity.DownloadLocation, entity.CopyrightText,
+		entity.FileName, entity.LicenseConcluded, entity.LicenseComments)
+	checksums := []string{}
+	for algo, value := range entity.Checksum {
+		checksums = append(checksums, algo+":"+value)
+	}
+	writeSorted(h, checksums)
+	annotations := []string{}
+	for _, a := range entity.Annotations {
+		annotations = append(annotations, a.Type+" "+a.Comment)
+	}
+	writeSorted(h, annotations)
+
+	relationships := []string{}
+	for _, rel := range entity.Relationships {
+		peer := rel.PeerReference
+		if rel.Peer != nil {
+			peer = th.hash(rel.Peer)
+		}
+		relationships = append(relationships,
+			fmt.Sprintf("%s %s %s %s", rel.Type, rel.PeerExtReference, peer, rel.Comment))
+	}
+	writeSorted(h, relationships)
+
+	sum := fmt.Sprintf("%x", h.Sum(nil))
+	th.done[o] = sum
+	return sum
+}
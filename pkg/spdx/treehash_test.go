@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeHash(t *testing.T) {
+	// buildTree returns a package with a file and a subpackage. The order
+	// of the relationships and the IDs depend on reverse.
+	buildTree := func(reverse bool) (*Package, *Package) {
+		root := NewPackage()
+		root.Name = "root"
+		root.ID = fmt.Sprintf("SPDXRef-Package-root-%t", reverse)
+		root.Version = "v1.0.0"
+
+		f := NewFile()
+		f.Name = "README.md"
+		f.ID = fmt.Sprintf("SPDXRef-File-readme-%t", reverse)
+		f.Checksum = map[string]string{"SHA256": "abcd", "SHA1": "ef01"}
+
+		sub := NewPackage()
+		sub.Name = "sub"
+		sub.ID = fmt.Sprintf("SPDXRef-Package-sub-%t", reverse)
+		sub.Version = "v0.1.0"
+		sub.AddAnnotation(Annotation{Type: AnnotationTypeOther, Comment: "note", Date: fmt.Sprintf("%t", reverse)})
+		// Relationships back to the root do not loop
+		sub.AddRelationship(&Relationship{Type: DEPENDENCY_OF, Peer: root})
+
+		if reverse {
+			require.NoError(t, root.AddPackage(sub))
+			require.NoError(t, root.AddFile(f))
+		} else {
+			require.NoError(t, root.AddFile(f))
+			require.NoError(t, root.AddPackage(sub))
+		}
+		return root, sub
+	}
+
+	tree1, _ := buildTree(false)
+	tree2, sub := buildTree(true)
+	require.Len(t, tree1.TreeHash(), 64)
+	require.Equal(t, tree1.TreeHash(), tree2.TreeHash())
+
+	// Changing an element deep in the tree changes the hash
+	sub.Version = "v0.2.0"
+	require.NotEqual(t, tree1.TreeHash(), tree2.TreeHash())
+	sub.Version = "v0.1.0"
+	require.Equal(t, tree1.TreeHash(), tree2.TreeHash())
+
+	// So does adding a relationship
+	extra := NewPackage()
+	extra.Name = "extra"
+	require.NoError(t, tree2.AddPackage(extra))
+	require.NotEqual(t, tree1.TreeHash(), tree2.TreeHash())
+}
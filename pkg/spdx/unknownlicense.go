@@ -0,0 +1,59 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import "fmt"
+
+// UnknownLicensePolicy selects how the license fields of files and
+// packages are written when the license classifier finds no license.
+//
+// The two SPDX values state different things: NONE asserts there is no
+// license information, the file or package was examined and carries none.
+// NOASSERTION makes no claim at all, the license was not determined and
+// may exist in a form the classifier did not recognize. Some compliance
+// regimes treat an unlicensed file as a finding, others a missing answer.
+//
+// When no policy is set, files without a license get NONE as the license
+// in the file and the license of the directory as their concluded one,
+// which is NOASSERTION when the directory has none.
+type UnknownLicensePolicy string
+
+const (
+	// UnknownLicenseNone writes NONE in all the license fields
+	UnknownLicenseNone UnknownLicensePolicy = "none"
+
+	// UnknownLicenseNoAssertion writes NOASSERTION in all the license fields
+	UnknownLicenseNoAssertion UnknownLicensePolicy = "noassertion"
+)
+
+// unknownLicenseTags returns the license found in the files and the
+// license concluded for the elements without one under the policy
+func unknownLicenseTags(policy UnknownLicensePolicy) (inFile, concluded string, err error) {
+	switch policy {
+	case "":
+		return NONE, "", nil
+	case UnknownLicenseNone:
+		return NONE, NONE, nil
+	case UnknownLicenseNoAssertion:
+		return NOASSERTION, NOASSERTION, nil
+	default:
+		return "", "", fmt.Errorf(
+			"invalid unknown license policy %q, must be %q or %q",
+			policy, UnknownLicenseNone, UnknownLicenseNoAssertion,
+		)
+	}
+}
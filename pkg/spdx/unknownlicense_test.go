@@ -0,0 +1,60 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFromDirectoryUnknownLicensePolicy(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), os.FileMode(0o644)))
+
+	for _, tc := range []struct {
+		policy           UnknownLicensePolicy
+		inFile           string
+		concluded        string
+		packageConcluded string
+		shouldError      bool
+	}{
+		{policy: "", inFile: NONE, concluded: "", packageConcluded: ""},
+		{policy: UnknownLicenseNone, inFile: NONE, concluded: NONE, packageConcluded: NONE},
+		{policy: UnknownLicenseNoAssertion, inFile: NOASSERTION, concluded: NOASSERTION, packageConcluded: NOASSERTION},
+		{policy: "unknown", shouldError: true},
+	} {
+		impl := spdxDefaultImplementation{}
+		pkg, err := impl.PackageFromDirectory(&Options{UnknownLicensePolicy: tc.policy}, dir)
+		if tc.shouldError {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err, tc.policy)
+		require.Equal(t, tc.packageConcluded, pkg.LicenseConcluded, tc.policy)
+		require.Len(t, pkg.Files(), 1)
+		f := pkg.Files()[0]
+		require.Equal(t, tc.inFile, f.LicenseInfoInFile, tc.policy)
+		require.Equal(t, tc.concluded, f.LicenseConcluded, tc.policy)
+
+		// The package lists the value of its files
+		require.NoError(t, pkg.ComputeLicenseList())
+		require.Equal(t, []string{tc.inFile}, pkg.LicenseInfoFromFiles, tc.policy)
+	}
+}
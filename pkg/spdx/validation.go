@@ -0,0 +1,261 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/bom/pkg/license"
+)
+
+// spdxIDRe matches the identifiers of SPDX elements
+var spdxIDRe = regexp.MustCompile(`^SPDXRef-[a-zA-Z0-9.-]+$`)
+
+// ValidationError is a problem found in a document that makes it invalid
+// under the SPDX 2.3 specification
+type ValidationError struct {
+	ElementID string // SPDX ID of the element with the problem
+	Field     string // Field of the element with the problem, if any
+	Message   string // Description of the problem
+}
+
+func (ve ValidationError) Error() string {
+	var prefix string
+	switch {
+	case ve.ElementID != "" && ve.Field != "":
+		prefix = ve.ElementID + " " + ve.Field + ": "
+	case ve.ElementID != "":
+		prefix = ve.ElementID + ": "
+	case ve.Field != "":
+		prefix = ve.Field + ": "
+	}
+	return prefix + ve.Message
+}
+
+// Validate checks the document against the SPDX 2.3 specification. It
+// walks the packages and files of the document and their relationships
+// looking for missing mandatory fields, malformed license expressions,
+// elements sharing an SPDX ID and relationships to elements not defined
+// in the document. It returns the problems found, the document is valid
+// when the list is empty.
+func (d *Document) Validate() []ValidationError {
+	v := documentValidator{
+		doc:      d,
+		errors:   []ValidationError{},
+		elements: map[string]Object{},
+		seen:     map[Object]struct{}{},
+	}
+	v.checkDocument()
+
+	// Collect all the elements first so relationships can point to
+	// elements found later in the walk
+	objects := []Object{}
+	for _, id := range d.sortedPackageIDs() {
+		objects = v.collect(d.Packages[id], objects)
+	}
+	for _, id := range d.sortedFileIDs() {
+		objects = v.collect(d.Files[id], objects)
+	}
+	for _, o := range objects {
+		switch e := o.(type) {
+		case *Package:
+			v.checkPackage(e)
+		case *File:
+			v.checkFile(e)
+		}
+		v.checkRelationships(o)
+	}
+	return v.errors
+}
+
+// documentValidator accumulates the validation errors of a document
+type documentValidator struct {
+	doc      *Document
+	errors   []ValidationError
+	elements map[string]Object   // Elements of the document by SPDX ID
+	seen     map[Object]struct{} // Elements already collected
+}
+
+func (v *documentValidator) add(id, field, format string, args ...interface{}) {
+	v.errors = append(v.errors, ValidationError{
+		ElementID: id, Field: field, Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// collect appends to objects the element and all the elements related to
+// it, recording their IDs to look for duplicates
+func (v *documentValidator) collect(o Object, objects []Object) []Object {
+	if o == nil {
+		return objects
+	}
+	if _, ok := v.seen[o]; ok {
+		return objects
+	}
+	v.seen[o] = struct{}{}
+	objects = append(objects, o)
+
+	if id := o.SPDXID(); id != "" {
+		if prev, ok := v.elements[id]; ok && prev != o {
+			v.add(id, "SPDXID", "SPDX ID is used by more than one element")
+		} else {
+			v.elements[id] = o
+		}
+	}
+	for _, rel := range *o.GetRelationships() {
+		objects = v.collect(rel.Peer, objects)
+	}
+	return objects
+}
+
+// checkDocument checks the mandatory fields of the document creation info
+func (v *documentValidator) checkDocument() {
+	d := v.doc
+	id := d.ID
+	if id == "" {
+		v.add("", "SPDXID", "document has no SPDX ID")
+	} else if id != "SPDXRef-DOCUMENT" {
+		v.add(id, "SPDXID", "document SPDX ID must be SPDXRef-DOCUMENT")
+	}
+	if d.Version == "" {
+		v.add(id, "SPDXVersion", "field is missing")
+	}
+	if d.Name == "" {
+		v.add(id, "DocumentName", "field is missing")
+	}
+	if d.Namespace == "" {
+		v.add(id, "DocumentNamespace", "field is missing")
+	} else if !isURL(d.Namespace) {
+		v.add(id, "DocumentNamespace", "%q is not a URI", d.Namespace)
+	}
+	if d.Creator.Person == "" && d.Creator.Organization == "" && len(d.Creator.Tool) == 0 {
+		v.add(id, "Creator", "document has no creators")
+	}
+	if d.Created.IsZero() {
+		v.add(id, "Created", "field is missing")
+	}
+	for _, ref := range d.ExternalDocRefs {
+		if ref.URI == "" {
+			v.add(id, "ExternalDocumentRef", "reference %s has no URI", ref.ID)
+		}
+		if ref.Checksums["SHA1"] == "" {
+			v.add(id, "ExternalDocumentRef", "reference %s has no SHA1 checksum", ref.ID)
+		}
+	}
+}
+
+// checkID checks the SPDX ID of an element
+func (v *documentValidator) checkID(id string) {
+	if id == "" {
+		v.add("", "SPDXID", "element has no SPDX ID")
+		return
+	}
+	if !spdxIDRe.MatchString(id) {
+		v.add(id, "SPDXID", "%q is not a valid SPDX ID", id)
+	}
+}
+
+// checkPackage checks the mandatory fields and licenses of a package. An
+// empty download location or concluded license is not missing, they are
+// rendered as NONE and NOASSERTION.
+func (v *documentValidator) checkPackage(p *Package) {
+	id := p.SPDXID()
+	v.checkID(id)
+	if p.Name == "" {
+		v.add(id, "PackageName", "field is missing")
+	}
+	v.checkLicense(id, "PackageLicenseConcluded", p.LicenseConcluded)
+	v.checkLicense(id, "PackageLicenseDeclared", p.LicenseDeclared)
+	for _, l := range p.LicenseInfoFromFiles {
+		if l != NONE && l != NOASSERTION && !license.IsLicenseID(l) {
+			v.add(id, "PackageLicenseInfoFromFiles", "%q is not a license identifier", l)
+		}
+	}
+}
+
+// checkFile checks the mandatory fields and licenses of a file
+func (v *documentValidator) checkFile(f *File) {
+	id := f.SPDXID()
+	v.checkID(id)
+	if f.Name == "" {
+		v.add(id, "FileName", "field is missing")
+	}
+	if f.Checksum["SHA1"] == "" {
+		v.add(id, "FileChecksum", "file has no SHA1 checksum")
+	}
+	v.checkLicense(id, "LicenseConcluded", f.LicenseConcluded)
+	v.checkLicense(id, "LicenseInfoInFile", f.LicenseInfoInFile)
+}
+
+// checkLicense records an error if a non empty license field does not
+// hold a valid license expression
+func (v *documentValidator) checkLicense(id, field, expression string) {
+	if expression == "" {
+		return
+	}
+	if _, err := license.ParseLicenseExpression(expression); err != nil {
+		v.add(id, field, "invalid license expression: %v", err)
+	}
+}
+
+// checkRelationships checks that the relationships of an element have a
+// type and point to an element defined in the document or in one of its
+// external document references
+func (v *documentValidator) checkRelationships(o Object) {
+	id := o.SPDXID()
+	for _, rel := range *o.GetRelationships() {
+		if rel.Type == "" {
+			v.add(id, "Relationship", "relationship has no type")
+		}
+		if rel.Peer != nil {
+			if rel.Peer.SPDXID() == "" {
+				v.add(id, "Relationship", "%s peer has no SPDX ID", rel.Type)
+			}
+			continue
+		}
+		if rel.PeerReference == "" {
+			v.add(id, "Relationship", "%s relationship has no peer", rel.Type)
+			continue
+		}
+		if rel.PeerExtReference != "" {
+			if !v.hasExternalDocRef(rel.PeerExtReference) {
+				v.add(
+					id, "Relationship", "%s peer DocumentRef-%s:%s is in an undefined external document",
+					rel.Type, strings.TrimPrefix(rel.PeerExtReference, "DocumentRef-"), rel.PeerReference,
+				)
+			}
+			continue
+		}
+		if _, ok := v.elements[rel.PeerReference]; !ok && rel.PeerReference != v.doc.ID &&
+			rel.PeerReference != NONE && rel.PeerReference != NOASSERTION {
+			v.add(id, "Relationship", "%s peer %s is not defined in the document", rel.Type, rel.PeerReference)
+		}
+	}
+}
+
+// hasExternalDocRef returns true if the document declares the external
+// document reference
+func (v *documentValidator) hasExternalDocRef(ref string) bool {
+	ref = strings.TrimPrefix(ref, "DocumentRef-")
+	for _, ed := range v.doc.ExternalDocRefs {
+		if strings.TrimPrefix(ed.ID, "DocumentRef-") == ref {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDocument(t *testing.T) {
+	newValidDoc := func() (*Document, *Package, *File) {
+		doc := NewDocument()
+		doc.Name = "test-document"
+		doc.Namespace = "https://example.com/spdx/test"
+		p := NewPackage()
+		p.SetSPDXID("SPDXRef-Package-app")
+		p.Name = "app"
+		p.DownloadLocation = NOASSERTION
+		p.LicenseConcluded = "Apache-2.0 AND (MIT OR GPL-2.0-only WITH Classpath-exception-2.0)"
+		p.LicenseDeclared = "LicenseRef-custom"
+		f := NewFile()
+		f.SetSPDXID("SPDXRef-File-main.go")
+		f.Name = "main.go"
+		f.Checksum = map[string]string{"SHA1": "da39a3ee5e6b4b0d3255bfef95601890afd80709"}
+		f.LicenseConcluded = NOASSERTION
+		require.NoError(t, p.AddFile(f))
+		require.NoError(t, doc.AddPackage(p))
+		return doc, p, f
+	}
+
+	fields := func(errs []ValidationError) []string {
+		ret := []string{}
+		for _, e := range errs {
+			ret = append(ret, e.ElementID+" "+e.Field)
+		}
+		return ret
+	}
+
+	for _, tc := range []struct {
+		name     string
+		mutate   func(*Document, *Package, *File)
+		expected []string
+	}{
+		{
+			name:     "valid document",
+			mutate:   func(*Document, *Package, *File) {},
+			expected: []string{},
+		},
+		{
+			name: "missing mandatory fields",
+			mutate: func(d *Document, p *Package, f *File) {
+				d.Namespace = ""
+				p.Name = ""
+				f.Checksum = map[string]string{}
+			},
+			expected: []string{
+				"SPDXRef-DOCUMENT DocumentNamespace",
+				"SPDXRef-Package-app PackageName",
+				"SPDXRef-File-main.go FileChecksum",
+			},
+		},
+		{
+			// They are rendered as NONE and NOASSERTION
+			name: "empty download location and concluded license",
+			mutate: func(d *Document, p *Package, f *File) {
+				p.LicenseConcluded = ""
+				p.DownloadLocation = ""
+			},
+			expected: []string{},
+		},
+		{
+			name: "duplicate ids",
+			mutate: func(d *Document, p *Package, f *File) {
+				dup := NewPackage()
+				dup.SetSPDXID("SPDXRef-File-main.go")
+				dup.Name = "dup"
+				dup.DownloadLocation = NONE
+				dup.LicenseConcluded = NONE
+				require.NoError(t, p.AddPackage(dup))
+			},
+			expected: []string{"SPDXRef-File-main.go SPDXID"},
+		},
+		{
+			name: "dangling relationships",
+			mutate: func(d *Document, p *Package, f *File) {
+				p.AddRelationship(&Relationship{PeerReference: "SPDXRef-missing", Type: DEPENDS_ON})
+				p.AddRelationship(&Relationship{
+					PeerReference: "SPDXRef-other", PeerExtReference: "other-doc", Type: DEPENDS_ON,
+				})
+				f.AddRelationship(&Relationship{PeerReference: "SPDXRef-Package-app", Type: CONTAINED_BY})
+				f.AddRelationship(&Relationship{Type: GENERATED_FROM})
+			},
+			expected: []string{
+				"SPDXRef-Package-app Relationship",
+				"SPDXRef-Package-app Relationship",
+				"SPDXRef-File-main.go Relationship",
+			},
+		},
+		{
+			name: "external document references",
+			mutate: func(d *Document, p *Package, f *File) {
+				d.ExternalDocRefs = []ExternalDocumentRef{{
+					ID: "other-doc", URI: "https://example.com/other",
+					Checksums: map[string]string{"SHA1": "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+				}}
+				p.AddRelationship(&Relationship{
+					PeerReference: "SPDXRef-other", PeerExtReference: "other-doc", Type: DEPENDS_ON,
+				})
+			},
+			expected: []string{},
+		},
+		{
+			name: "malformed license expressions",
+			mutate: func(d *Document, p *Package, f *File) {
+				p.LicenseConcluded = "(MIT OR Apache-2.0"
+				p.LicenseDeclared = "MIT AND"
+				p.LicenseInfoFromFiles = []string{"MIT", "GPL 2"}
+				f.LicenseInfoInFile = "MIT WITH"
+			},
+			expected: []string{
+				"SPDXRef-Package-app PackageLicenseConcluded",
+				"SPDXRef-Package-app PackageLicenseDeclared",
+				"SPDXRef-Package-app PackageLicenseInfoFromFiles",
+				"SPDXRef-File-main.go LicenseInfoInFile",
+			},
+		},
+		{
+			name: "invalid ids",
+			mutate: func(d *Document, p *Package, f *File) {
+				f.SetSPDXID("SPDXRef-main_go")
+			},
+			expected: []string{"SPDXRef-main_go SPDXID"},
+		},
+	} {
+		doc, p, f := newValidDoc()
+		tc.mutate(doc, p, f)
+		errs := doc.Validate()
+		require.Equal(t, tc.expected, fields(errs), tc.name)
+		for _, e := range errs {
+			require.NotEmpty(t, e.Error(), tc.name)
+		}
+	}
+}
+
+func TestValidateGeneratedDocument(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), os.FileMode(0o644)))
+
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromDirectory(&Options{}, dir)
+	require.NoError(t, err)
+	require.Empty(t, pkg.DownloadLocation)
+	doc := NewDocument()
+	doc.Name = "generated"
+	doc.Namespace = "https://example.com/spdx/generated"
+	require.NoError(t, doc.AddPackage(pkg))
+	require.Empty(t, doc.Validate())
+
+	// The document parsed back from tag-value is valid as well
+	docPath := filepath.Join(t.TempDir(), "doc.spdx")
+	require.NoError(t, doc.Write(docPath))
+	parsed, err := OpenDoc(docPath)
+	require.NoError(t, err)
+	require.Empty(t, parsed.Validate())
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"regexp"
+	"strings"
+)
+
+// rawVersionAnnotation prefixes the annotation recording the version of
+// a package before it was normalized
+const rawVersionAnnotation = "Version before normalization: "
+
+// semverRegex matches semantic versions with an optional v prefix
+var semverRegex = regexp.MustCompile(
+	`^v?(\d+)\.(\d+)\.(\d+)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`,
+)
+
+// NormalizeVersion returns the version of a package of the ecosystem
+// (the purl type, eg golang or deb) in a form meant for comparisons:
+//
+//   - Debian, RPM and Alpine versions lose their epoch (1:2.3-4 is 2.3-4)
+//   - Go versions lose the v prefix and the +incompatible suffix
+//   - Semantic versions lose the v prefix and the build metadata, which
+//     does not take part in their precedence
+//
+// Other versions are returned unchanged. The normalized versions are not
+// meant to be installed, the same version may be released with different
+// epochs.
+func NormalizeVersion(ecosystem, version string) string {
+	version = strings.TrimSpace(version)
+	if version == "" || noAssertion(version) {
+		return version
+	}
+
+	switch ecosystem {
+	case "deb", "rpm", "apk", "alpm":
+		// The epoch is the number before the first colon
+		if i := strings.Index(version, ":"); i > 0 && isDigits(version[:i]) {
+			version = version[i+1:]
+		}
+		return version
+	case "golang":
+		version = strings.TrimSuffix(version, "+incompatible")
+		if semverRegex.MatchString(version) {
+			return stripBuildMetadata(strings.TrimPrefix(version, "v"))
+		}
+		return version
+	}
+
+	if semverRegex.MatchString(version) {
+		return stripBuildMetadata(strings.TrimPrefix(version, "v"))
+	}
+	return version
+}
+
+// stripBuildMetadata removes the build metadata from a semantic version
+func stripBuildMetadata(version string) string {
+	if i := strings.Index(version, "+"); i >= 0 {
+		return version[:i]
+	}
+	return version
+}
+
+// isDigits returns true if s is made of decimal digits only
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// normalizeVersions replaces the versions of pkg and all the packages
+// related to it with their normalized form when the options ask for it.
+// The original versions are kept in an annotation of each package.
+func normalizeVersions(opts *Options, pkg *Package) {
+	if opts == nil || !opts.NormalizeVersions || pkg == nil {
+		return
+	}
+	seen := map[*Package]struct{}{}
+	var apply func(*Package)
+	apply = func(p *Package) {
+		if _, ok := seen[p]; ok {
+			return
+		}
+		seen[p] = struct{}{}
+
+		ecosystem := ""
+		if pu := p.Purl(); pu != nil {
+			ecosystem = pu.Type
+		}
+		if normalized := NormalizeVersion(ecosystem, p.Version); normalized != p.Version {
+			p.AddAnnotation(newToolAnnotation(rawVersionAnnotation + p.Version))
+			p.Version = normalized
+		}
+
+		for _, rel := range p.Relationships {
+			if peer, ok := rel.Peer.(*Package); ok && peer != nil {
+				apply(peer)
+			}
+		}
+	}
+	apply(pkg)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeVersion(t *testing.T) {
+	for _, tc := range []struct {
+		ecosystem string
+		version   string
+		expected  string
+	}{
+		// Go modules
+		{"golang", "v1.2.3", "1.2.3"},
+		{"golang", "v2.0.0+incompatible", "2.0.0"},
+		{"golang", "v0.0.0-20210622060536-734e95fb86be", "0.0.0-20210622060536-734e95fb86be"},
+		{"golang", "(devel)", "(devel)"},
+		// Debian epochs and revisions
+		{"deb", "1:1.2-3", "1.2-3"},
+		{"deb", "1.2-3", "1.2-3"},
+		{"deb", "2:8.2.2434-3+deb11u1", "8.2.2434-3+deb11u1"},
+		{"deb", "1.2+dfsg-1", "1.2+dfsg-1"},
+		{"rpm", "1:3.0.7-6.el9", "3.0.7-6.el9"},
+		// Semantic versions
+		{"npm", "1.2.3+build.5", "1.2.3"},
+		{"", "v1.2.3-rc.1+sha.5114f85", "1.2.3-rc.1"},
+		{"cargo", "0.4.0", "0.4.0"},
+		// Versions not matching a known scheme
+		{"", "v1.2", "v1.2"},
+		{"", "NOASSERTION", "NOASSERTION"},
+		{"", "", ""},
+	} {
+		require.Equal(t, tc.expected, NormalizeVersion(tc.ecosystem, tc.version), tc.version)
+	}
+}
+
+func TestNormalizeVersions(t *testing.T) {
+	newPkg := func(name, version, purl string) *Package {
+		p := NewPackage()
+		p.Name = name
+		p.Version = version
+		p.BuildID(name)
+		if purl != "" {
+			p.ExternalRefs = append(p.ExternalRefs, ExternalRef{
+				Category: CatPackageManager, Type: "purl", Locator: purl,
+			})
+		}
+		return p
+	}
+	root := newPkg("root", "v1.0.0", "")
+	goDep := newPkg("golang.org/x/mod", "v0.8.0", "pkg:golang/golang.org/x/mod@v0.8.0")
+	debDep := newPkg("vim", "2:8.2-1", "pkg:deb/debian/vim@2:8.2-1")
+	require.NoError(t, root.AddDependency(goDep))
+	require.NoError(t, root.AddPackage(debDep))
+
+	// Nothing changes unless the option is set
+	normalizeVersions(&Options{}, root)
+	require.Equal(t, "v1.0.0", root.Version)
+
+	normalizeVersions(&Options{NormalizeVersions: true}, root)
+	for _, tc := range []struct {
+		pkg      *Package
+		version  string
+		original string
+	}{
+		{root, "1.0.0", "v1.0.0"},
+		{goDep, "0.8.0", "v0.8.0"},
+		{debDep, "8.2-1", "2:8.2-1"},
+	} {
+		require.Equal(t, tc.version, tc.pkg.Version)
+		require.Len(t, tc.pkg.Annotations, 1)
+		require.Equal(t, rawVersionAnnotation+tc.original, tc.pkg.Annotations[0].Comment)
+	}
+
+	// Normalized versions are left alone
+	normalizeVersions(&Options{NormalizeVersions: true}, root)
+	require.Len(t, root.Annotations, 1)
+}
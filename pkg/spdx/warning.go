@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Warning is a problem found while generating SBOM data that did not
+// stop the process, for example a dependency that could not be converted
+// or a directory without licensing information.
+type Warning struct {
+	Element  string // Name, path or reference of the element with the problem
+	Message  string // Description of the problem
+	Security bool   // The warning is a security finding, such as an embedded credential
+}
+
+func (w Warning) String() string {
+	if w.Element == "" {
+		return w.Message
+	}
+	return fmt.Sprintf("%s: %s", w.Element, w.Message)
+}
+
+// FileError is a file of a scanned directory that could not be read
+type FileError struct {
+	Path string // Path of the file, relative to the directory
+	Err  error  // Error found scanning the file
+}
+
+func (fe *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", fe.Path, fe.Err)
+}
+
+func (fe *FileError) Unwrap() error {
+	return fe.Err
+}
+
+// DependencyError is a dependency that could not be converted to an SPDX
+// package and was left out of the dependencies returned
+type DependencyError struct {
+	Dependency string // Name of the dependency, eg the import path of a go package
+	Err        error  // Error found converting the dependency
+}
+
+func (de *DependencyError) Error() string {
+	return fmt.Sprintf("%s: %v", de.Dependency, de.Err)
+}
+
+func (de *DependencyError) Unwrap() error {
+	return de.Err
+}
+
+// warningList accumulates the warnings found by the implementation when
+// the options enable CollectWarnings. It is safe for concurrent use.
+type warningList struct {
+	sync.Mutex
+	warnings []Warning
+}
+
+// add records a warning without logging it
+func (wl *warningList) add(opts *Options, w Warning) {
+	if opts == nil || !opts.CollectWarnings {
+		return
+	}
+	wl.Lock()
+	defer wl.Unlock()
+	wl.warnings = append(wl.warnings, w)
+}
+
+// list returns a copy of the recorded warnings
+func (wl *warningList) list() []Warning {
+	wl.Lock()
+	defer wl.Unlock()
+	return append([]Warning{}, wl.warnings...)
+}
+
+// warn logs a warning and records it when collecting warnings is enabled
+func (di *spdxDefaultImplementation) warn(opts *Options, element, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logger(opts).Warn(msg)
+	di.warnings.add(opts, Warning{Element: element, Message: msg})
+}
+
+// logger returns the logger set in the options, or the logrus standard
+// logger when they have none
+func logger(opts *Options) logrus.FieldLogger {
+	if opts == nil || opts.Logger == nil {
+		return logrus.StandardLogger()
+	}
+	return opts.Logger
+}
+
+// Warnings returns the warnings collected by the implementation
+func (di *spdxDefaultImplementation) Warnings() []Warning {
+	return di.warnings.list()
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsLogger(t *testing.T) {
+	globalHook := logtest.NewGlobal()
+	defer globalHook.Reset()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "main.go"), []byte("package main\n\n//go:embed data.txt\nvar data string\n"), os.FileMode(0o644),
+	))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.txt"), []byte("data\n"), os.FileMode(0o644)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "DATA.txt"), []byte("data\n"), os.FileMode(0o644)))
+
+	log, hook := logtest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+	opts := &Options{SkipLicenseScan: true, ProcessGoModules: true, Logger: log.WithField("scan", "test")}
+	impl := spdxDefaultImplementation{}
+	_, err := impl.PackageFromDirectory(opts, dir)
+	require.NoError(t, err)
+	_, err = impl.getDirectoryTree(logger(opts), dir, true, false)
+	require.NoError(t, err)
+	require.NoError(t, impl.AnalyzeImageLayer("testdata/sparse-gnu.tar", NewPackage(), opts))
+	impl.warn(opts, "main.go", "Something went wrong with %s", "main.go")
+
+	// The messages go to the logger of the options, with its fields
+	messages := []string{}
+	for _, e := range hook.AllEntries() {
+		require.Equal(t, "test", e.Data["scan"])
+		messages = append(messages, e.Message)
+	}
+	require.Contains(t, messages, "Scanning 3 files and adding them to the SPDX package")
+	require.Contains(t, messages, "File data.txt is embedded in main.go")
+	require.Contains(t, messages, "Skipping data.txt, path already listed with a different case")
+	require.Contains(t, messages, "Scanning layer with distroless")
+	require.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	require.Equal(t, "Something went wrong with main.go", hook.LastEntry().Message)
+	require.Empty(t, globalHook.AllEntries())
+
+	// Without a logger they go to the standard one
+	impl.warn(&Options{}, "main.go", "Something else")
+	require.Len(t, globalHook.AllEntries(), 1)
+}
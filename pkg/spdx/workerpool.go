@@ -0,0 +1,73 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"sync"
+)
+
+// workerPool bounds the goroutines doing the leaf work of a scan (image
+// downloads, go package conversions and directory files) to
+// Options.MaxConcurrency. The throttlers of each phase keep their own
+// limits, the pool caps the work in flight when the phases nest.
+//
+// The slots are a semaphore for each MaxConcurrency value, so scans with
+// different limits get a pool of their own size while the copies of the
+// options made during a scan share its slots. Starting work blocks while
+// all of them are taken.
+// Phases that start nested phases of their own (layers and nested
+// archives) do not hold a slot, so their nested work cannot deadlock
+// waiting for the slots of its parents.
+type workerPool struct {
+	sync.Mutex
+	slots map[int]chan struct{}
+}
+
+// spawn runs fn in a new goroutine holding a slot of the pool, waiting
+// for a free slot when all of them are taken. Without MaxConcurrency set,
+// fn always gets its own goroutine right away. fn must not spawn work
+// into the pool itself, phases nesting others start with spawnPhase.
+func (wp *workerPool) spawn(opts *Options, fn func()) {
+	if opts == nil || opts.MaxConcurrency < 1 {
+		go fn()
+		return
+	}
+
+	wp.Lock()
+	if wp.slots == nil {
+		wp.slots = map[int]chan struct{}{}
+	}
+	slots, ok := wp.slots[opts.MaxConcurrency]
+	if !ok {
+		slots = make(chan struct{}, opts.MaxConcurrency)
+		wp.slots[opts.MaxConcurrency] = slots
+	}
+	wp.Unlock()
+
+	slots <- struct{}{}
+	go func() {
+		defer func() { <-slots }()
+		fn()
+	}()
+}
+
+// spawnPhase runs fn, which starts nested phases of its own, in a new
+// goroutine outside of the pool slots. Its goroutines are bounded by the
+// throttler of its phase.
+func (wp *workerPool) spawnPhase(fn func()) {
+	go fn()
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spdx
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPool(t *testing.T) {
+	// Two nested levels of phases with four leaves each, sharing a pool of two
+	pool := workerPool{}
+	opts := &Options{MaxConcurrency: 2}
+	var active, peak int32
+	var spawnLevel func(level int, wg *sync.WaitGroup)
+	spawnLevel = func(level int, wg *sync.WaitGroup) {
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			if level < 2 {
+				pool.spawnPhase(func() {
+					defer wg.Done()
+					var nested sync.WaitGroup
+					spawnLevel(level+1, &nested)
+					nested.Wait()
+				})
+				continue
+			}
+			pool.spawn(opts, func() {
+				defer wg.Done()
+				n := atomic.AddInt32(&active, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&active, -1)
+			})
+		}
+	}
+	var wg sync.WaitGroup
+	spawnLevel(0, &wg)
+	wg.Wait()
+
+	// The leaf work never runs in more goroutines than the pool slots
+	require.LessOrEqual(t, peak, int32(2))
+
+	// Scans with another limit get slots of their own size, copies of the
+	// options share the slots of the scan
+	var done sync.WaitGroup
+	done.Add(2)
+	pool.spawn(&Options{MaxConcurrency: 5}, done.Done)
+	optsCopy := *opts
+	pool.spawn(&optsCopy, done.Done)
+	done.Wait()
+	require.Len(t, pool.slots, 2)
+	require.Equal(t, 2, cap(pool.slots[2]))
+	require.Equal(t, 5, cap(pool.slots[5]))
+
+	// Scanning an image with parallel layers and files does not deadlock
+	// with a single slot
+	names := []string{}
+	layers := [][]byte{}
+	for i := 0; i < 4; i++ {
+		names = append(names, fmt.Sprintf("layer%d/layer.tar", i))
+		layers = append(layers, testLayerData(t, i, 5))
+	}
+	tarPath := writeTestDockerArchive(t, names, layers)
+	impl := spdxDefaultImplementation{}
+	pkg, err := impl.PackageFromImageTarball(
+		&Options{AddTarFiles: true, LayerWorkers: 4, MaxConcurrency: 1}, tarPath,
+	)
+	require.NoError(t, err)
+	files := 0
+	for _, rel := range pkg.Relationships {
+		if layer, ok := rel.Peer.(*Package); ok {
+			files += len(layer.Files())
+		}
+	}
+	require.Equal(t, 20, files)
+}